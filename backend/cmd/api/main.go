@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/gaston-garcia-cegid/arnela/backend/config"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/handler"
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/middleware"
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository/postgres"
@@ -20,6 +21,7 @@ import (
 	"github.com/gaston-garcia-cegid/arnela/backend/pkg/database"
 	"github.com/gaston-garcia-cegid/arnela/backend/pkg/jwt"
 	"github.com/gaston-garcia-cegid/arnela/backend/pkg/queue"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/storage"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -142,6 +144,13 @@ func main() {
 	defer workerPool.Stop()
 	log.Println("✓ Worker pool started with 5 workers")
 
+	// Initialize file storage (local filesystem or S3-compatible bucket)
+	fileStorage, err := storage.NewStorage(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to initialize file storage: %v", err)
+	}
+	log.Printf("✓ File storage initialized (driver: %s)", cfg.Storage.Driver)
+
 	// Initialize JWT token manager
 	tokenManager := jwt.NewTokenManager(cfg.JWT.Secret, "arnela-api")
 
@@ -151,12 +160,27 @@ func main() {
 	appointmentRepo := postgres.NewAppointmentRepository(db)
 	employeeRepo := postgres.NewEmployeeRepository(db)
 	statsRepo := postgres.NewStatsRepository(db)
+	appointmentAttachmentRepo := postgres.NewAppointmentAttachmentRepository(db)
 
 	// Billing repositories
 	invoiceRepo := postgres.NewInvoiceRepository(db)
 	expenseRepo := postgres.NewExpenseRepository(db)
+	recurringExpenseRepo := postgres.NewRecurringExpenseRepository(db)
 	expenseCategoryRepo := postgres.NewExpenseCategoryRepository(db)
 	taskRepo := postgres.NewTaskRepository(db)
+	appointmentChargeRepo := postgres.NewAppointmentChargeRepository(db)
+	serviceTypeRepo := postgres.NewServiceTypeRepository(db)
+	billingSettingsRepo := postgres.NewBillingSettingsRepository(db)
+	invoiceSeriesRepo := postgres.NewInvoiceSeriesRepository(db)
+	invoiceRecordRepo := postgres.NewInvoiceRecordRepository(db)
+	paymentRepo := postgres.NewPaymentRepository(db)
+	invoiceReminderRepo := postgres.NewInvoiceReminderRepository(db)
+	sessionPackRepo := postgres.NewSessionPackRepository(db)
+	sepaRepo := postgres.NewSEPARepository(db)
+	bankStatementRepo := postgres.NewBankStatementRepository(db)
+	paymentLinkRepo := postgres.NewPaymentLinkRepository(db)
+	billingProfileRepo := postgres.NewBillingProfileRepository(db)
+	insurerRepo := postgres.NewInsurerRepository(db)
 
 	// Search repository
 	searchRepo := postgres.NewSearchRepository(db)
@@ -164,16 +188,171 @@ func main() {
 	// Initialize services
 	authService := service.NewAuthService(userRepo, clientRepo, tokenManager, cfg.JWT.TokenExpiry)
 	clientService := service.NewClientService(clientRepo, userRepo)
-	appointmentService := service.NewAppointmentService(appointmentRepo, clientRepo, employeeRepo)
 	employeeService := service.NewEmployeeService(employeeRepo, userRepo)
 	taskService := service.NewTaskService(taskRepo, employeeRepo)
 	statsService := service.NewStatsService(statsRepo)
 
 	// Billing services
-	invoiceService := service.NewInvoiceService(invoiceRepo, clientRepo)
+	serviceTypeService := service.NewServiceTypeService(serviceTypeRepo)
+	billingProfileService := service.NewBillingProfileService(billingProfileRepo, clientRepo)
+	invoiceSeriesService := service.NewInvoiceSeriesService(invoiceSeriesRepo)
+	invoiceRecordSender, err := service.NewInvoiceRecordSender(cfg.VeriFactu.Sender)
+	if err != nil {
+		log.Fatalf("Failed to initialize invoice record sender: %v", err)
+	}
+	invoiceRecordService := service.NewInvoiceRecordService(invoiceRecordRepo, invoiceRepo, billingSettingsRepo, invoiceRecordSender, []byte(cfg.VeriFactu.SigningKey))
+
+	// Every issued invoice is recorded in the VeriFactu chain
+	invoiceService := service.NewInvoiceService(invoiceRepo, clientRepo, billingProfileRepo, serviceTypeRepo, invoiceSeriesRepo, appointmentRepo, employeeRepo, sessionPackRepo, insurerRepo, service.InvoiceTaxPolicy{
+		IRPFRate:        cfg.Billing.IRPFRate,
+		PaymentTermDays: cfg.Billing.PaymentTermDays,
+	}, invoiceRecordService)
+	chargePolicy := service.ChargePolicy{
+		LateCancelWindow: cfg.Billing.LateCancelWindow,
+		LateCancelFee:    cfg.Billing.LateCancelFee,
+		NoShowFee:        cfg.Billing.NoShowFee,
+		PaymentTermDays:  cfg.Billing.PaymentTermDays,
+	}
+	appointmentChargeService := service.NewAppointmentChargeService(appointmentChargeRepo, invoiceService, chargePolicy)
+	sessionPackService := service.NewSessionPackService(sessionPackRepo, serviceTypeRepo, invoiceService)
+	insurerService := service.NewInsurerService(insurerRepo, billingProfileRepo, clientRepo, serviceTypeRepo, invoiceService)
+	insuranceCoverageConsumer := service.NewInsuranceCoverageConsumer(insurerService)
+	sessionPackConsumer := service.NewSessionPackConsumer(sessionPackService, insurerRepo)
+	appointmentInvoicer := service.NewAppointmentInvoicer(billingSettingsRepo, sessionPackRepo, insurerRepo, invoiceService)
 	expenseService := service.NewExpenseService(expenseRepo, expenseCategoryRepo)
+	recurringExpenseService := service.NewRecurringExpenseService(recurringExpenseRepo, expenseCategoryRepo)
+	expenseReceiptService := service.NewExpenseReceiptService(expenseRepo, fileStorage, storage.NewURLSigner(cfg.Storage.URLSigningKey), cfg.Storage.MaxUploadBytes, cfg.Storage.URLTTL)
 	expenseCategoryService := service.NewExpenseCategoryService(expenseCategoryRepo)
-	billingStatsService := service.NewBillingStatsService(invoiceRepo, expenseRepo, expenseCategoryRepo)
+	billingStatsService := service.NewBillingStatsService(invoiceRepo, expenseRepo)
+	taxReportService := service.NewTaxReportService(invoiceRepo, expenseRepo)
+	billingSettingsService := service.NewBillingSettingsService(billingSettingsRepo, fileStorage)
+	invoicePDFService := service.NewInvoicePDFService(invoiceRepo, clientRepo, billingSettingsRepo, fileStorage, cfg.VeriFactu.QRBaseURL)
+
+	// Facturae documents are signed only when a certificate is configured
+	var facturaeSigner *service.FacturaeSigner
+	if cfg.Facturae.CertPath != "" {
+		p12, err := os.ReadFile(cfg.Facturae.CertPath)
+		if err != nil {
+			log.Fatalf("Failed to read Facturae certificate: %v", err)
+		}
+		if facturaeSigner, err = service.NewFacturaeSigner(p12, cfg.Facturae.CertPassword); err != nil {
+			log.Fatalf("Failed to load Facturae certificate: %v", err)
+		}
+	}
+	facturaeService := service.NewFacturaeService(invoiceRepo, clientRepo, billingSettingsRepo, facturaeSigner)
+	paymentService := service.NewPaymentService(paymentRepo, invoiceRepo)
+	sepaService := service.NewSEPAService(sepaRepo, invoiceRepo, clientRepo, paymentRepo, billingSettingsRepo)
+	bankReconciliationService := service.NewBankReconciliationService(bankStatementRepo, invoiceRepo, expenseRepo)
+
+	// Online "pay now" links, settled by the webhook of the payment provider
+	paymentProvider, err := service.NewPaymentProvider(cfg.Payments.Provider, service.StripeConfig{
+		APIURL:        cfg.Payments.APIURL,
+		SecretKey:     cfg.Payments.SecretKey,
+		WebhookSecret: cfg.Payments.WebhookSecret,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize payment provider: %v", err)
+	}
+	paymentLinkService := service.NewPaymentLinkService(paymentLinkRepo, invoiceRepo, clientRepo, billingSettingsRepo, paymentProvider, workerPool, service.PaymentLinkSettings{
+		SuccessURL: cfg.Payments.SuccessURL,
+		CancelURL:  cfg.Payments.CancelURL,
+		TTL:        cfg.Payments.LinkTTL,
+	})
+
+	// Overdue invoices are chased with reminder emails queued for the workers
+	dunningSchedule := domain.DunningSchedule(cfg.Billing.DunningSchedule)
+	if err := dunningSchedule.Validate(); err != nil {
+		log.Fatalf("Invalid dunning schedule: %v", err)
+	}
+	dunningService := service.NewDunningService(invoiceReminderRepo, invoiceRepo, clientRepo, billingSettingsRepo, invoicePDFService, workerPool, service.DunningPolicy{
+		Schedule: dunningSchedule,
+	})
+
+	// Clients are invoiced monthly for their sessions not invoiced one by one
+	invoiceBatchService := service.NewInvoiceBatchService(appointmentRepo, invoiceService, service.InvoiceBatchPolicy{
+		Day:   cfg.Billing.BatchDay,
+		Draft: cfg.Billing.BatchDraft,
+	})
+
+	// Transmit pending invoice records to the tax agency in the background
+	if cfg.VeriFactu.SubmitInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.VeriFactu.SubmitInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				result, err := invoiceRecordService.SubmitPending(context.Background())
+				if err != nil {
+					log.Printf("[ERROR] Invoice record submission failed: %v", err)
+				} else if result.Accepted+result.Rejected > 0 {
+					log.Printf("Invoice records submitted: accepted=%d rejected=%d pending=%d",
+						result.Accepted, result.Rejected, result.Pending)
+				}
+			}
+		}()
+	}
+
+	// Flag overdue invoices and send the payment reminders due in the background
+	if cfg.Billing.DunningInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.Billing.DunningInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				result, err := dunningService.Run(context.Background())
+				if err != nil {
+					log.Printf("[ERROR] Dunning run failed: %v", err)
+				} else if result.MarkedOverdue+result.RemindersSent+result.Failed > 0 {
+					log.Printf("Dunning run: overdue=%d reminders=%d skipped=%d failed=%d",
+						result.MarkedOverdue, result.RemindersSent, result.Skipped, result.Failed)
+				}
+			}
+		}()
+	}
+
+	// Generate the recurring expenses due; runs are idempotent, so a missed run is caught
+	// up by the next one
+	if cfg.Billing.RecurringExpenseInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.Billing.RecurringExpenseInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				result, err := recurringExpenseService.GenerateDue(context.Background())
+				if err != nil {
+					log.Printf("[ERROR] Recurring expense generation failed: %v", err)
+				} else if result.Generated+result.Failed > 0 {
+					log.Printf("Recurring expenses generated: generated=%d failed=%d", result.Generated, result.Failed)
+				}
+			}
+		}()
+	}
+
+	// Invoice the previous month once the batch day is reached; runs are idempotent, so the
+	// daily check resumes an interrupted run and picks up sessions completed late
+	if cfg.Billing.BatchDay > 0 {
+		go func() {
+			ticker := time.NewTicker(24 * time.Hour)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				result, err := invoiceBatchService.RunScheduled(context.Background())
+				if err != nil {
+					log.Printf("[ERROR] Monthly invoicing failed: %v", err)
+				} else if result != nil && result.Invoiced+result.Failed > 0 {
+					log.Printf("Monthly invoicing %s: invoiced=%d failed=%d total=%s",
+						result.Period, result.Invoiced, result.Failed, result.Total)
+				}
+			}
+		}()
+	}
+
+	// New bookings warn about used up or expired insurance authorisations. Clients may cancel
+	// until the start when late cancellations are charged. Appointment transitions raise
+	// late-cancellation and no-show charges; completed appointments are covered by an
+	// insurer (invoicing the co-payment), consume a session of a pack or, otherwise, are invoiced
+	appointmentService := service.NewAppointmentService(appointmentRepo, clientRepo, employeeRepo, insurerService, chargePolicy.ClientCancellationCutoff(), appointmentChargeService, insuranceCoverageConsumer, sessionPackConsumer, appointmentInvoicer)
+	appointmentAttachmentService := service.NewAppointmentAttachmentService(appointmentAttachmentRepo, appointmentRepo, fileStorage, cfg.Storage.MaxUploadBytes)
 
 	// Search service
 	searchService := service.NewSearchService(searchRepo)
@@ -182,15 +361,35 @@ func main() {
 	authHandler := handler.NewAuthHandler(authService)
 	clientHandler := handler.NewClientHandler(clientService)
 	appointmentHandler := handler.NewAppointmentHandler(appointmentService)
+	appointmentAttachmentHandler := handler.NewAppointmentAttachmentHandler(appointmentAttachmentService, cfg.Storage.MaxUploadBytes)
 	employeeHandler := handler.NewEmployeeHandler(employeeService)
 	taskHandler := handler.NewTaskHandler(taskService)
 	statsHandler := handler.NewStatsHandler(statsService)
 
 	// Billing handlers
 	invoiceHandler := handler.NewInvoiceHandler(invoiceService)
+	serviceTypeHandler := handler.NewServiceTypeHandler(serviceTypeService)
+	billingProfileHandler := handler.NewBillingProfileHandler(billingProfileService)
+	sessionPackHandler := handler.NewSessionPackHandler(sessionPackService)
+	insurerHandler := handler.NewInsurerHandler(insurerService)
+	invoiceSeriesHandler := handler.NewInvoiceSeriesHandler(invoiceSeriesService)
+	appointmentChargeHandler := handler.NewAppointmentChargeHandler(appointmentChargeService)
 	expenseHandler := handler.NewExpenseHandler(expenseService)
+	recurringExpenseHandler := handler.NewRecurringExpenseHandler(recurringExpenseService)
+	expenseReceiptHandler := handler.NewExpenseReceiptHandler(expenseReceiptService, cfg.Storage.MaxUploadBytes)
 	expenseCategoryHandler := handler.NewExpenseCategoryHandler(expenseCategoryService)
 	billingStatsHandler := handler.NewBillingStatsHandler(billingStatsService)
+	taxReportHandler := handler.NewTaxReportHandler(taxReportService)
+	billingSettingsHandler := handler.NewBillingSettingsHandler(billingSettingsService)
+	invoicePDFHandler := handler.NewInvoicePDFHandler(invoicePDFService)
+	invoiceFacturaeHandler := handler.NewInvoiceFacturaeHandler(facturaeService)
+	paymentHandler := handler.NewPaymentHandler(paymentService)
+	sepaHandler := handler.NewSEPAHandler(sepaService)
+	bankReconciliationHandler := handler.NewBankReconciliationHandler(bankReconciliationService)
+	paymentLinkHandler := handler.NewPaymentLinkHandler(paymentLinkService)
+	dunningHandler := handler.NewDunningHandler(dunningService)
+	invoiceBatchHandler := handler.NewInvoiceBatchHandler(invoiceBatchService)
+	invoiceRecordHandler := handler.NewInvoiceRecordHandler(invoiceRecordService)
 
 	// Search handler
 	searchHandler := handler.NewSearchHandler(searchService)
@@ -260,12 +459,19 @@ func main() {
 			auth.GET("/me", authMiddleware.RequireAuth(), authHandler.Me)
 		}
 
+		// Payment provider callbacks (public, verified by their signature)
+		v1.POST("/webhooks/payments/:provider", paymentLinkHandler.HandleWebhook)
+
+		// Short-lived file downloads (public, verified by the signature of the URL)
+		v1.GET("/files/expense-receipts/:id", expenseReceiptHandler.DownloadReceipt)
+
 		// Client routes (authenticated)
 		clients := v1.Group("/clients")
 		clients.Use(authMiddleware.RequireAuth())
 		{
 			// Client self-service (any authenticated user can get their own client info)
 			clients.GET("/me", clientHandler.GetMyClient)
+			clients.GET("/me/session-packs", sessionPackHandler.GetMyBalance)
 
 			// Admin/Employee only routes
 			clients.POST("", authMiddleware.RequireRole("admin", "employee"), clientHandler.CreateClient)
@@ -273,6 +479,23 @@ func main() {
 			clients.GET("/:id", authMiddleware.RequireRole("admin", "employee"), clientHandler.GetClient)
 			clients.PUT("/:id", authMiddleware.RequireRole("admin", "employee"), clientHandler.UpdateClient)
 			clients.DELETE("/:id", authMiddleware.RequireRole("admin"), clientHandler.DeleteClient)
+
+			// Session packs (bonos) of a client: balance shown when booking, and sale
+			clients.GET("/:id/session-packs", authMiddleware.RequireRole("admin", "employee"), sessionPackHandler.GetClientBalance)
+			clients.POST("/:id/session-packs", authMiddleware.RequireRole("admin", "employee"), sessionPackHandler.SellSessionPack)
+
+			// SEPA direct debit mandates signed by a client
+			clients.GET("/:id/sepa-mandates", authMiddleware.RequireRole("admin", "employee"), sepaHandler.ListClientMandates)
+			clients.POST("/:id/sepa-mandates", authMiddleware.RequireRole("admin", "employee"), sepaHandler.CreateMandate)
+
+			// Payers of a client (parents, companies) invoiced instead of the client
+			clients.GET("/:id/billing-profiles", authMiddleware.RequireRole("admin", "employee"), billingProfileHandler.ListClientBillingProfiles)
+			clients.PUT("/:id/billing-profiles/:profileId", authMiddleware.RequireRole("admin", "employee"), billingProfileHandler.LinkClient)
+			clients.DELETE("/:id/billing-profiles/:profileId", authMiddleware.RequireRole("admin", "employee"), billingProfileHandler.UnlinkClient)
+
+			// Insurance policies of a client and their authorisations
+			clients.GET("/:id/insurance-policies", authMiddleware.RequireRole("admin", "employee"), insurerHandler.ListClientPolicies)
+			clients.POST("/:id/insurance-policies", authMiddleware.RequireRole("admin", "employee"), insurerHandler.CreatePolicy)
 		}
 
 		// Appointment routes (authenticated)
@@ -287,12 +510,20 @@ func main() {
 			appointments.PUT("/:id", authMiddleware.RequireRole("admin", "employee"), appointmentHandler.UpdateAppointment)
 			appointments.POST("/:id/cancel", appointmentHandler.CancelAppointment)
 
+			// Attachments (access to the appointment and file visibility checked by the service)
+			appointments.GET("/:id/attachments", appointmentAttachmentHandler.ListAttachments)
+			appointments.POST("/:id/attachments", appointmentAttachmentHandler.UploadAttachment)
+			appointments.GET("/:id/attachments/:attachmentId/download", appointmentAttachmentHandler.DownloadAttachment)
+			appointments.DELETE("/:id/attachments/:attachmentId", appointmentAttachmentHandler.DeleteAttachment)
+
 			// Client-specific endpoint
 			appointments.GET("/me", appointmentHandler.GetMyAppointments)
 
 			// Admin/Employee only routes
 			appointments.GET("", authMiddleware.RequireRole("admin", "employee"), appointmentHandler.ListAppointments)
 			appointments.POST("/:id/confirm", authMiddleware.RequireRole("admin", "employee"), appointmentHandler.ConfirmAppointment)
+			appointments.POST("/:id/no-show", authMiddleware.RequireRole("admin", "employee"), appointmentHandler.MarkNoShow)
+			appointments.POST("/:id/complete", authMiddleware.RequireRole("admin", "employee"), appointmentHandler.CompleteAppointment)
 		}
 
 		// Employee routes (authenticated)
@@ -348,13 +579,28 @@ func main() {
 			{
 				invoices.POST("", invoiceHandler.CreateInvoice)
 				invoices.GET("", invoiceHandler.ListInvoices)
+				invoices.POST("/batch", authMiddleware.RequireRole("admin"), invoiceBatchHandler.RunBatch)
 				invoices.GET("/:id", invoiceHandler.GetInvoice)
+				invoices.GET("/:id/pdf", invoicePDFHandler.GetInvoicePDF)
+				invoices.GET("/:id/facturae", invoiceFacturaeHandler.GetInvoiceFacturae)
 				invoices.GET("/number/:number", invoiceHandler.GetInvoiceByNumber)
 				invoices.PUT("/:id", invoiceHandler.UpdateInvoice)
 				invoices.DELETE("/:id", invoiceHandler.DeleteInvoice)
-				invoices.POST("/:id/mark-paid", invoiceHandler.MarkInvoiceAsPaid)
+				invoices.POST("/:id/mark-paid", paymentHandler.MarkInvoiceAsPaid)
+				invoices.GET("/:id/payments", paymentHandler.ListInvoicePayments)
+				invoices.POST("/:id/payments", paymentHandler.RecordPayment)
+				invoices.GET("/:id/payment-links", paymentLinkHandler.ListInvoicePaymentLinks)
+				invoices.POST("/:id/payment-links", paymentLinkHandler.CreatePaymentLink)
+				invoices.POST("/:id/issue", authMiddleware.RequireRole("admin"), invoiceHandler.IssueInvoice)
+				invoices.POST("/:id/rectify", invoiceHandler.CreateRectifyingInvoice)
+				invoices.GET("/:id/rectifications", invoiceHandler.GetRectifyingInvoices)
+				invoices.GET("/:id/records", invoiceRecordHandler.GetInvoiceRecords)
 				invoices.GET("/client/:clientId", invoiceHandler.GetClientInvoices)
+				invoices.GET("/appointment/:appointmentId", invoiceHandler.GetAppointmentInvoice)
+				invoices.POST("/appointment/:appointmentId", invoiceHandler.CreateInvoiceFromAppointment)
 				invoices.GET("/unpaid", invoiceHandler.GetUnpaidInvoices)
+				invoices.GET("/overdue", dunningHandler.GetOverdueInvoices)
+				invoices.GET("/:id/reminders", dunningHandler.GetInvoiceReminders)
 			}
 
 			// Expense routes
@@ -365,10 +611,28 @@ func main() {
 				expenses.GET("/:id", expenseHandler.GetExpense)
 				expenses.PUT("/:id", expenseHandler.UpdateExpense)
 				expenses.DELETE("/:id", expenseHandler.DeleteExpense)
+				expenses.POST("/:id/attachment", expenseReceiptHandler.UploadReceipt)
+				expenses.GET("/:id/attachment/url", expenseReceiptHandler.GetReceiptURL)
+				expenses.DELETE("/:id/attachment", expenseReceiptHandler.DeleteReceipt)
+				expenses.POST("/:id/review", expenseHandler.MarkExpenseReviewed)
 				expenses.GET("/category/:categoryId", expenseHandler.GetExpensesByCategory)
 				expenses.GET("/supplier/:supplier", expenseHandler.GetExpensesBySupplier)
 			}
 
+			// Recurring expense routes
+			recurringExpenses := billing.Group("/recurring-expenses")
+			{
+				recurringExpenses.POST("", recurringExpenseHandler.CreateRecurringExpense)
+				recurringExpenses.GET("", recurringExpenseHandler.ListRecurringExpenses)
+				recurringExpenses.POST("/generate", recurringExpenseHandler.GenerateDue)
+				recurringExpenses.GET("/:id", recurringExpenseHandler.GetRecurringExpense)
+				recurringExpenses.PUT("/:id", recurringExpenseHandler.UpdateRecurringExpense)
+				recurringExpenses.DELETE("/:id", recurringExpenseHandler.DeleteRecurringExpense)
+				recurringExpenses.GET("/:id/occurrences", recurringExpenseHandler.ListOccurrences)
+				recurringExpenses.PUT("/:id/occurrences/:period", recurringExpenseHandler.AdjustOccurrence)
+				recurringExpenses.DELETE("/:id/occurrences/:period", recurringExpenseHandler.ResetOccurrence)
+			}
+
 			// Expense Category routes
 			expenseCategories := billing.Group("/expense-categories")
 			{
@@ -382,11 +646,140 @@ func main() {
 				expenseCategories.GET("/:id", expenseCategoryHandler.GetExpenseCategory)
 			}
 
+			// Service type catalogue routes (prefill invoice lines)
+			serviceTypes := billing.Group("/service-types")
+			{
+				serviceTypes.GET("", serviceTypeHandler.ListServiceTypes)
+				serviceTypes.GET("/:id", serviceTypeHandler.GetServiceType)
+				serviceTypes.POST("", authMiddleware.RequireRole("admin"), serviceTypeHandler.CreateServiceType)
+				serviceTypes.PUT("/:id", authMiddleware.RequireRole("admin"), serviceTypeHandler.UpdateServiceType)
+				serviceTypes.DELETE("/:id", authMiddleware.RequireRole("admin"), serviceTypeHandler.DeleteServiceType)
+			}
+
+			// Billing profiles: payers invoiced instead of the clients they pay for
+			billingProfiles := billing.Group("/billing-profiles")
+			{
+				billingProfiles.GET("", billingProfileHandler.ListBillingProfiles)
+				billingProfiles.GET("/:id", billingProfileHandler.GetBillingProfile)
+				billingProfiles.POST("", billingProfileHandler.CreateBillingProfile)
+				billingProfiles.PUT("/:id", billingProfileHandler.UpdateBillingProfile)
+				billingProfiles.DELETE("/:id", authMiddleware.RequireRole("admin"), billingProfileHandler.DeleteBillingProfile)
+			}
+
+			// Insurers (mutuas) and the monthly settlement of the sessions they cover
+			insurers := billing.Group("/insurers")
+			{
+				insurers.GET("", insurerHandler.ListInsurers)
+				insurers.GET("/:id", insurerHandler.GetInsurer)
+				insurers.POST("", insurerHandler.CreateInsurer)
+				insurers.PUT("/:id", insurerHandler.UpdateInsurer)
+				insurers.DELETE("/:id", authMiddleware.RequireRole("admin"), insurerHandler.DeleteInsurer)
+				insurers.POST("/:id/settlement", insurerHandler.SettleInsurer)
+			}
+			insurancePolicies := billing.Group("/insurance-policies")
+			{
+				insurancePolicies.PUT("/:id", insurerHandler.UpdatePolicy)
+				insurancePolicies.DELETE("/:id", insurerHandler.DeletePolicy)
+				insurancePolicies.POST("/:id/authorisations", insurerHandler.CreateAuthorisation)
+			}
+			insuranceAuthorisations := billing.Group("/insurance-authorisations")
+			{
+				insuranceAuthorisations.PUT("/:id", insurerHandler.UpdateAuthorisation)
+				insuranceAuthorisations.DELETE("/:id", insurerHandler.DeleteAuthorisation)
+			}
+
+			// Session pack catalogue routes (bonos of prepaid sessions)
+			sessionPacks := billing.Group("/session-packs")
+			{
+				sessionPacks.GET("", sessionPackHandler.ListSessionPacks)
+				sessionPacks.GET("/:id", sessionPackHandler.GetSessionPack)
+				sessionPacks.POST("", authMiddleware.RequireRole("admin"), sessionPackHandler.CreateSessionPack)
+				sessionPacks.PUT("/:id", authMiddleware.RequireRole("admin"), sessionPackHandler.UpdateSessionPack)
+				sessionPacks.DELETE("/:id", authMiddleware.RequireRole("admin"), sessionPackHandler.DeleteSessionPack)
+			}
+
+			// Invoice numbering series routes
+			series := billing.Group("/series")
+			{
+				series.GET("", invoiceSeriesHandler.ListSeries)
+				series.GET("/:id", invoiceSeriesHandler.GetSeries)
+				series.POST("", authMiddleware.RequireRole("admin"), invoiceSeriesHandler.CreateSeries)
+				series.PUT("/:id", authMiddleware.RequireRole("admin"), invoiceSeriesHandler.UpdateSeries)
+			}
+
+			// VeriFactu invoice record chain routes
+			verifactu := billing.Group("/verifactu")
+			{
+				verifactu.GET("/verify", invoiceRecordHandler.VerifyChain)
+				verifactu.POST("/submit", authMiddleware.RequireRole("admin"), invoiceRecordHandler.SubmitPending)
+			}
+
+			// Payment reminder routes
+			dunning := billing.Group("/dunning")
+			{
+				dunning.POST("/run", authMiddleware.RequireRole("admin"), dunningHandler.RunDunning)
+			}
+
+			// Payment routes
+			payments := billing.Group("/payments")
+			{
+				payments.POST("/:id/reverse", authMiddleware.RequireRole("admin"), paymentHandler.ReversePayment)
+			}
+
+			// SEPA direct debit routes: batches (remesas) and returned debits
+			sepa := billing.Group("/sepa")
+			{
+				sepa.POST("/mandates/:id/revoke", sepaHandler.RevokeMandate)
+				sepa.GET("/batches", sepaHandler.ListBatches)
+				sepa.GET("/batches/:id", sepaHandler.GetBatch)
+				sepa.GET("/batches/:id/file", sepaHandler.DownloadBatch)
+				sepa.POST("/batches", authMiddleware.RequireRole("admin"), sepaHandler.CreateBatch)
+				sepa.POST("/returns", authMiddleware.RequireRole("admin"), sepaHandler.ImportReturns)
+			}
+
+			// Bank reconciliation routes: imported statements and the queue of unmatched movements
+			bank := billing.Group("/bank")
+			{
+				bank.GET("/statements", bankReconciliationHandler.ListStatements)
+				bank.GET("/statements/:id", bankReconciliationHandler.GetStatement)
+				bank.POST("/statements", authMiddleware.RequireRole("admin"), bankReconciliationHandler.ImportStatement)
+				bank.GET("/movements/pending", bankReconciliationHandler.ListQueue)
+				bank.POST("/movements/:id/match", bankReconciliationHandler.ConfirmMatch)
+				bank.POST("/movements/:id/ignore", bankReconciliationHandler.IgnoreMovement)
+			}
+
+			// Late-cancellation and no-show charge routes
+			charges := billing.Group("/charges")
+			{
+				charges.GET("", appointmentChargeHandler.ListCharges)
+				charges.GET("/:id", appointmentChargeHandler.GetCharge)
+				charges.POST("/:id/waive", authMiddleware.RequireRole("admin"), appointmentChargeHandler.WaiveCharge)
+				charges.POST("/:id/issue", authMiddleware.RequireRole("admin"), appointmentChargeHandler.IssueCharge)
+			}
+
+			// Fiscal data and invoice template routes
+			settings := billing.Group("/settings")
+			{
+				settings.GET("", billingSettingsHandler.GetSettings)
+				settings.PUT("", authMiddleware.RequireRole("admin"), billingSettingsHandler.UpdateSettings)
+				settings.GET("/logo", billingSettingsHandler.GetLogo)
+				settings.PUT("/logo", authMiddleware.RequireRole("admin"), billingSettingsHandler.UploadLogo)
+				settings.DELETE("/logo", authMiddleware.RequireRole("admin"), billingSettingsHandler.DeleteLogo)
+			}
+
 			// Billing Stats routes
 			billing.GET("/dashboard", billingStatsHandler.GetDashboardStats)
 			billing.GET("/revenue-by-month", billingStatsHandler.GetRevenueByMonth)
 			billing.GET("/expenses-by-category", billingStatsHandler.GetExpensesByCategory)
 			billing.GET("/balance", billingStatsHandler.GetBalance)
+
+			// Reports: quarterly tax returns (Modelo 303 and 130) and receivables aging
+			reports := billing.Group("/reports")
+			{
+				reports.GET("/tax", taxReportHandler.GetTaxReport)
+				reports.GET("/tax/boxes/:box", taxReportHandler.GetTaxReportBox)
+				reports.GET("/aging", billingStatsHandler.GetAgingReport)
+			}
 		}
 	}
 
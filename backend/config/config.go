@@ -4,15 +4,23 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	Redis    RedisConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	JWT       JWTConfig
+	Redis     RedisConfig
+	Billing   BillingConfig
+	Storage   StorageConfig
+	VeriFactu VeriFactuConfig
+	Facturae  FacturaeConfig
+	Payments  PaymentsConfig
 }
 
 // ServerConfig holds server-level configuration
@@ -46,6 +54,59 @@ type RedisConfig struct {
 	DB       int
 }
 
+// BillingConfig holds billing policy configuration
+type BillingConfig struct {
+	LateCancelWindow         time.Duration // Cancellations closer than this to the appointment are charged
+	LateCancelFee            money.Money   // Base amount for late cancellations (0 disables the charge)
+	NoShowFee                money.Money   // Base amount for no-shows (0 disables the charge)
+	PaymentTermDays          int           // Days until an automatically generated invoice is due
+	IRPFRate                 float64       // IRPF withholding (%) applied by default to invoices for businesses
+	DunningSchedule          []int         // Days after the due date at which each payment reminder is sent
+	DunningInterval          time.Duration // Time between runs of the overdue invoice job (0 disables it)
+	RecurringExpenseInterval time.Duration // Time between runs of the recurring expense job (0 disables it)
+	BatchDay                 int           // Day of the month from which the previous month is invoiced per client (0 disables it)
+	BatchDraft               bool          // Leave the monthly invoices as drafts to be reviewed and issued
+}
+
+// VeriFactuConfig holds the configuration of the invoice record chain and its transmission
+type VeriFactuConfig struct {
+	SigningKey     string        // HMAC key signing the records
+	QRBaseURL      string        // Verification service encoded in the invoice QR codes
+	Sender         string        // fake (local stand-in for the tax agency)
+	SubmitInterval time.Duration // Time between transmissions of pending records (0 disables them)
+}
+
+// FacturaeConfig holds the certificate signing Facturae e-invoices
+type FacturaeConfig struct {
+	CertPath     string // PKCS#12 file; documents are left unsigned when empty
+	CertPassword string
+}
+
+// PaymentsConfig holds the online payment provider that collects "pay now" links
+type PaymentsConfig struct {
+	Provider      string        // stripe; empty disables online payments
+	APIURL        string        // Provider API, replaceable by a local stand-in
+	SecretKey     string        // API key creating the checkouts
+	WebhookSecret string        // Key verifying the signature of the webhook callbacks
+	SuccessURL    string        // Page the client returns to after paying
+	CancelURL     string        // Page the client returns to when giving up
+	LinkTTL       time.Duration // Time a link can be paid
+}
+
+// StorageConfig holds file storage configuration
+type StorageConfig struct {
+	Driver         string // local or s3
+	LocalPath      string
+	S3Endpoint     string
+	S3Region       string
+	S3Bucket       string
+	S3AccessKey    string
+	S3SecretKey    string
+	MaxUploadBytes int64
+	URLSigningKey  string        // Signs the short-lived download URLs served by the API
+	URLTTL         time.Duration // Time a download URL stays valid
+}
+
 // LoadConfig loads configuration from environment variables
 func LoadConfig() (*Config, error) {
 	return &Config{
@@ -72,6 +133,49 @@ func LoadConfig() (*Config, error) {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
+		Billing: BillingConfig{
+			LateCancelWindow:         time.Duration(getEnvAsInt("LATE_CANCEL_WINDOW_HOURS", 24)) * time.Hour,
+			LateCancelFee:            getEnvAsMoney("LATE_CANCEL_FEE", money.Money{}),
+			NoShowFee:                getEnvAsMoney("NO_SHOW_FEE", money.Money{}),
+			PaymentTermDays:          getEnvAsInt("INVOICE_PAYMENT_TERM_DAYS", 15),
+			IRPFRate:                 getEnvAsFloat("IRPF_WITHHOLDING_RATE", 15),
+			DunningSchedule:          getEnvAsIntList("DUNNING_SCHEDULE_DAYS", []int{3, 15, 30}),
+			DunningInterval:          time.Duration(getEnvAsInt("DUNNING_INTERVAL_HOURS", 24)) * time.Hour,
+			RecurringExpenseInterval: time.Duration(getEnvAsInt("RECURRING_EXPENSES_INTERVAL_HOURS", 24)) * time.Hour,
+			BatchDay:                 getEnvAsInt("INVOICE_BATCH_DAY", 0),
+			BatchDraft:               getEnvAsBool("INVOICE_BATCH_DRAFT", true),
+		},
+		Storage: StorageConfig{
+			Driver:         getEnv("STORAGE_DRIVER", "local"),
+			LocalPath:      getEnv("STORAGE_LOCAL_PATH", "./uploads"),
+			S3Endpoint:     getEnv("S3_ENDPOINT", ""),
+			S3Region:       getEnv("S3_REGION", "eu-south-2"),
+			S3Bucket:       getEnv("S3_BUCKET", ""),
+			S3AccessKey:    getEnv("S3_ACCESS_KEY", ""),
+			S3SecretKey:    getEnv("S3_SECRET_KEY", ""),
+			MaxUploadBytes: int64(getEnvAsInt("STORAGE_MAX_UPLOAD_MB", 10)) << 20,
+			URLSigningKey:  getEnv("STORAGE_URL_SIGNING_KEY", "your-url-signing-key-change-in-production"),
+			URLTTL:         time.Duration(getEnvAsInt("STORAGE_URL_TTL_MINUTES", 5)) * time.Minute,
+		},
+		VeriFactu: VeriFactuConfig{
+			SigningKey:     getEnv("VERIFACTU_SIGNING_KEY", "your-signing-key-change-in-production"),
+			QRBaseURL:      getEnv("VERIFACTU_QR_BASE_URL", "https://www2.agenciatributaria.gob.es/wlpl/TIKE-CONT/ValidarQR"),
+			Sender:         getEnv("VERIFACTU_SENDER", "fake"),
+			SubmitInterval: time.Duration(getEnvAsInt("VERIFACTU_SUBMIT_INTERVAL_MINUTES", 5)) * time.Minute,
+		},
+		Facturae: FacturaeConfig{
+			CertPath:     getEnv("FACTURAE_CERT_PATH", ""),
+			CertPassword: getEnv("FACTURAE_CERT_PASSWORD", ""),
+		},
+		Payments: PaymentsConfig{
+			Provider:      getEnv("PAYMENTS_PROVIDER", ""),
+			APIURL:        getEnv("PAYMENTS_API_URL", "https://api.stripe.com"),
+			SecretKey:     getEnv("PAYMENTS_SECRET_KEY", ""),
+			WebhookSecret: getEnv("PAYMENTS_WEBHOOK_SECRET", ""),
+			SuccessURL:    getEnv("PAYMENTS_SUCCESS_URL", "http://localhost:3000/pago/completado"),
+			CancelURL:     getEnv("PAYMENTS_CANCEL_URL", "http://localhost:3000/pago/cancelado"),
+			LinkTTL:       time.Duration(getEnvAsInt("PAYMENTS_LINK_TTL_HOURS", 24)) * time.Hour,
+		},
 	}, nil
 }
 
@@ -105,3 +209,48 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsFloat gets an environment variable as a float64 with a default fallback
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsBool gets an environment variable as a bool with a default fallback
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsIntList gets a comma-separated list of ints (e.g. "3,15,30") with a default fallback
+func getEnvAsIntList(key string, defaultValue []int) []int {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	var values []int
+	for _, part := range strings.Split(valueStr, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return defaultValue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// getEnvAsMoney gets an environment variable as an exact amount (e.g. "30.50") with a default fallback
+func getEnvAsMoney(key string, defaultValue money.Money) money.Money {
+	valueStr := getEnv(key, "")
+	if value, err := money.Parse(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
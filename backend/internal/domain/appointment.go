@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -17,6 +18,15 @@ const (
 	AppointmentStatusCancelled   AppointmentStatus = "cancelled"
 	AppointmentStatusCompleted   AppointmentStatus = "completed"
 	AppointmentStatusRescheduled AppointmentStatus = "rescheduled"
+	AppointmentStatusNoShow      AppointmentStatus = "no_show"
+)
+
+// CancellationParty represents who cancelled an appointment
+type CancellationParty string
+
+const (
+	CancelledByClient CancellationParty = "client" // The client, also when the clinic records it on the client's behalf
+	CancelledByClinic CancellationParty = "clinic" // The clinic, e.g. the therapist is unavailable
 )
 
 // RoomType represents the room/office where the appointment takes place
@@ -61,27 +71,36 @@ func (ns *NullableString) UnmarshalJSON(data []byte) error {
 
 // Appointment represents an appointment between a client and employee (therapist)
 type Appointment struct {
-	ID                    uuid.UUID         `json:"id" db:"id"`
-	ClientID              uuid.UUID         `json:"clientId" db:"client_id"`
-	EmployeeID            uuid.UUID         `json:"employeeId" db:"employee_id"`
-	Title                 string            `json:"title" db:"title"`
-	Description           string            `json:"description" db:"description"`
-	StartTime             time.Time         `json:"startTime" db:"start_time"`
-	EndTime               time.Time         `json:"endTime" db:"end_time"`
-	DurationMinutes       int               `json:"durationMinutes" db:"duration_minutes"`
-	Status                AppointmentStatus `json:"status" db:"status"`
-	Room                  RoomType          `json:"room" db:"room"`
-	Notes                 NullableString    `json:"notes" db:"notes"`                                    // ✅ Custom type
-	CancellationReason    NullableString    `json:"cancellationReason" db:"cancellation_reason"`         // ✅ Custom type
-	GoogleCalendarEventID NullableString    `json:"googleCalendarEventId" db:"google_calendar_event_id"` // ✅ Custom type
-	CreatedBy             uuid.UUID         `json:"createdBy" db:"created_by"`
-	CreatedAt             time.Time         `json:"createdAt" db:"created_at"`
-	UpdatedAt             time.Time         `json:"updatedAt" db:"updated_at"`
-	DeletedAt             sql.NullTime      `json:"deletedAt,omitempty" db:"deleted_at"`
+	ID                    uuid.UUID          `json:"id" db:"id"`
+	ClientID              uuid.UUID          `json:"clientId" db:"client_id"`
+	EmployeeID            uuid.UUID          `json:"employeeId" db:"employee_id"`
+	Title                 string             `json:"title" db:"title"`
+	Description           string             `json:"description" db:"description"`
+	StartTime             time.Time          `json:"startTime" db:"start_time"`
+	EndTime               time.Time          `json:"endTime" db:"end_time"`
+	DurationMinutes       int                `json:"durationMinutes" db:"duration_minutes"`
+	Status                AppointmentStatus  `json:"status" db:"status"`
+	Room                  RoomType           `json:"room" db:"room"`
+	ServiceTypeID         *uuid.UUID         `json:"serviceTypeId,omitempty" db:"service_type_id"`        // Prices the appointment's invoice
+	Notes                 NullableString     `json:"notes" db:"notes"`                                    // ✅ Custom type
+	CancellationReason    NullableString     `json:"cancellationReason" db:"cancellation_reason"`         // ✅ Custom type
+	CancelledBy           *CancellationParty `json:"cancelledBy,omitempty" db:"cancelled_by"`             // Set when cancelled
+	GoogleCalendarEventID NullableString     `json:"googleCalendarEventId" db:"google_calendar_event_id"` // ✅ Custom type
+	CreatedBy             uuid.UUID          `json:"createdBy" db:"created_by"`
+	CreatedAt             time.Time          `json:"createdAt" db:"created_at"`
+	UpdatedAt             time.Time          `json:"updatedAt" db:"updated_at"`
+	DeletedAt             sql.NullTime       `json:"deletedAt,omitempty" db:"deleted_at"`
 
 	// Relations (not in DB)
 	Employee *Employee `json:"employee,omitempty" db:"-"`
 	Client   *Client   `json:"client,omitempty" db:"-"`
+
+	// Set when booking, e.g. the insurance authorisation of the client is used up (not in DB)
+	Warnings []BookingWarning `json:"warnings,omitempty" db:"-"`
+
+	// Loaded with the appointments to invoice when an insurer covers the session, which
+	// leaves only the co-payment to bill the client (not in the table)
+	CopayAmount *money.Money `json:"copayAmount,omitempty" db:"copay_amount"`
 }
 
 // IsDuringBusinessHours checks if appointment is Monday-Friday 9:00-18:00
@@ -103,17 +122,43 @@ func (a *Appointment) IsDuringBusinessHours() bool {
 }
 
 func (a *Appointment) IsEditable() bool {
-	if a.Status == AppointmentStatusCancelled || a.Status == AppointmentStatusCompleted {
+	if a.IsClosed() {
 		return false
 	}
 	return a.StartTime.After(time.Now())
 }
 
-func (a *Appointment) CanBeCancelledByClient() bool {
-	if a.Status == AppointmentStatusCancelled || a.Status == AppointmentStatusCompleted {
+// CanBeCancelledByClient returns true if the client may still cancel, at least cutoff before the start
+func (a *Appointment) CanBeCancelledByClient(cutoff time.Duration) bool {
+	if a.IsClosed() {
+		return false
+	}
+	return a.StartTime.After(time.Now().Add(cutoff))
+}
+
+// IsCancelledByClient returns true if the client cancelled the appointment
+func (a *Appointment) IsCancelledByClient() bool {
+	return a.Status == AppointmentStatusCancelled && a.CancelledBy != nil && *a.CancelledBy == CancelledByClient
+}
+
+// IsClosed returns true if the appointment reached a final status
+func (a *Appointment) IsClosed() bool {
+	return a.Status == AppointmentStatusCancelled ||
+		a.Status == AppointmentStatusCompleted ||
+		a.Status == AppointmentStatusNoShow
+}
+
+// CanBeMarkedNoShow returns true if the appointment has started and is still open
+func (a *Appointment) CanBeMarkedNoShow() bool {
+	if a.IsClosed() {
 		return false
 	}
-	return a.StartTime.After(time.Now().Add(24 * time.Hour))
+	return !a.StartTime.After(time.Now())
+}
+
+// CanBeCompleted returns true if the appointment has started and is still open
+func (a *Appointment) CanBeCompleted() bool {
+	return a.CanBeMarkedNoShow()
 }
 
 // CreateAppointmentRequest represents the request to create an appointment
@@ -125,6 +170,7 @@ type CreateAppointmentRequest struct {
 	StartTime       time.Time `json:"startTime" binding:"required"`
 	DurationMinutes int       `json:"durationMinutes" binding:"required,oneof=45 60"`
 	Room            string    `json:"room" binding:"required,oneof=gabinete_01 gabinete_02 gabinete_externo"`
+	ServiceTypeID   string    `json:"serviceTypeId"` // Optional: prices the appointment's invoice
 }
 
 // UpdateAppointmentRequest represents the request to update an appointment
@@ -135,11 +181,15 @@ type UpdateAppointmentRequest struct {
 	StartTime       time.Time `json:"startTime"`
 	DurationMinutes int       `json:"durationMinutes"`
 	Room            string    `json:"room"`
+	ServiceTypeID   string    `json:"serviceTypeId"`
 }
 
 // CancelAppointmentRequest represents the request to cancel an appointment
 type CancelAppointmentRequest struct {
 	Reason string `json:"reason" binding:"required"`
+	// OnBehalfOfClient records a cancellation the client asked the clinic for (staff only);
+	// cancellations made by clients themselves are always theirs
+	OnBehalfOfClient bool `json:"onBehalfOfClient,omitempty"`
 }
 
 // ConfirmAppointmentRequest represents the request to confirm an appointment
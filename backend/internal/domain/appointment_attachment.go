@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AttachmentVisibility controls who can see an appointment attachment
+type AttachmentVisibility string
+
+const (
+	AttachmentVisibilityStaffOnly AttachmentVisibility = "staff_only" // Solo personal del centro
+	AttachmentVisibilityClient    AttachmentVisibility = "client"     // Compartido con el cliente
+)
+
+// IsValid returns true if the visibility is a known value
+func (v AttachmentVisibility) IsValid() bool {
+	return v == AttachmentVisibilityStaffOnly || v == AttachmentVisibilityClient
+}
+
+// AppointmentAttachment represents a file attached to an appointment
+type AppointmentAttachment struct {
+	ID            uuid.UUID            `json:"id" db:"id"`
+	AppointmentID uuid.UUID            `json:"appointmentId" db:"appointment_id"`
+	FileName      string               `json:"fileName" db:"file_name"`
+	ContentType   string               `json:"contentType" db:"content_type"`
+	SizeBytes     int64                `json:"sizeBytes" db:"size_bytes"`
+	StorageKey    string               `json:"-" db:"storage_key"`
+	Visibility    AttachmentVisibility `json:"visibility" db:"visibility"`
+	UploadedBy    uuid.UUID            `json:"uploadedBy" db:"uploaded_by"`
+	CreatedAt     time.Time            `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time            `json:"updatedAt" db:"updated_at"`
+	DeletedAt     *time.Time           `json:"deletedAt,omitempty" db:"deleted_at"`
+}
+
+// IsSharedWithClient returns true if the client of the appointment can see the file
+func (a *AppointmentAttachment) IsSharedWithClient() bool {
+	return a.Visibility == AttachmentVisibilityClient
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// AppointmentChargeKind represents the reason an appointment is charged
+type AppointmentChargeKind string
+
+// AppointmentChargeStatus represents the review status of a charge
+type AppointmentChargeStatus string
+
+const (
+	ChargeKindLateCancellation AppointmentChargeKind = "late_cancellation" // Cancelación tardía
+	ChargeKindNoShow           AppointmentChargeKind = "no_show"           // No presentado
+
+	ChargeStatusPending AppointmentChargeStatus = "pending" // Draft invoice awaiting review
+	ChargeStatusWaived  AppointmentChargeStatus = "waived"  // Condonado
+	ChargeStatusIssued  AppointmentChargeStatus = "issued"  // Invoice issued to the client
+)
+
+// AppointmentCharge represents a fee raised automatically for an appointment
+type AppointmentCharge struct {
+	ID            uuid.UUID               `json:"id" db:"id"`
+	AppointmentID uuid.UUID               `json:"appointmentId" db:"appointment_id"`
+	ClientID      uuid.UUID               `json:"clientId" db:"client_id"`
+	Kind          AppointmentChargeKind   `json:"kind" db:"kind"`
+	Amount        money.Money             `json:"amount" db:"amount"`
+	Status        AppointmentChargeStatus `json:"status" db:"status"`
+	InvoiceID     *uuid.UUID              `json:"invoiceId,omitempty" db:"invoice_id"`
+	WaivedBy      *uuid.UUID              `json:"waivedBy,omitempty" db:"waived_by"`
+	WaiveReason   *string                 `json:"waiveReason,omitempty" db:"waive_reason"`
+	WaivedAt      *time.Time              `json:"waivedAt,omitempty" db:"waived_at"`
+	CreatedAt     time.Time               `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time               `json:"updatedAt" db:"updated_at"`
+
+	// Relationships (populated on demand, not stored in DB)
+	Invoice *Invoice `json:"invoice,omitempty" db:"-"`
+}
+
+// IsPending returns true if the charge has not been waived or issued yet
+func (c *AppointmentCharge) IsPending() bool {
+	return c.Status == ChargeStatusPending
+}
+
+// Description returns the invoice concept for the charge
+func (c *AppointmentCharge) Description(appointment *Appointment) string {
+	date := appointment.StartTime.Format("02/01/2006 15:04")
+	switch c.Kind {
+	case ChargeKindLateCancellation:
+		return "Cargo por cancelación tardía de la cita del " + date
+	case ChargeKindNoShow:
+		return "Cargo por no presentarse a la cita del " + date
+	default:
+		return "Cargo asociado a la cita del " + date
+	}
+}
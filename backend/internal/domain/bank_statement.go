@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// BankStatementFormat is the file format of an imported bank statement
+type BankStatementFormat string
+
+const (
+	BankStatementNorma43 BankStatementFormat = "norma43" // AEB Cuaderno 43, fixed width text
+	BankStatementCAMT053 BankStatementFormat = "camt053" // ISO 20022 camt.053 XML
+)
+
+// BankStatement is a bank statement file imported to reconcile its movements. The same
+// file cannot be imported twice.
+type BankStatement struct {
+	ID            uuid.UUID           `json:"id" db:"id"`
+	Format        BankStatementFormat `json:"format" db:"format"`
+	FileName      string              `json:"fileName" db:"file_name"`
+	ContentHash   string              `json:"-" db:"content_hash"`  // SHA-256 of the file
+	Account       string              `json:"account" db:"account"` // IBAN, or the CCC of Norma 43 files
+	FromDate      time.Time           `json:"fromDate" db:"from_date"`
+	ToDate        time.Time           `json:"toDate" db:"to_date"`
+	MovementCount int                 `json:"movementCount" db:"movement_count"`
+	ImportedBy    *uuid.UUID          `json:"importedBy,omitempty" db:"imported_by"`
+	ImportedAt    time.Time           `json:"importedAt" db:"imported_at"`
+
+	Movements []*BankMovement `json:"movements,omitempty" db:"-"`
+}
+
+// BankMovementStatus is the reconciliation state of a bank movement
+type BankMovementStatus string
+
+const (
+	BankMovementPending    BankMovementStatus = "pending"    // In the reconciliation queue
+	BankMovementReconciled BankMovementStatus = "reconciled" // Matched to an invoice or an expense
+	BankMovementIgnored    BankMovementStatus = "ignored"    // Nothing to reconcile, e.g. bank fees
+)
+
+// IsValid returns true if the status is supported
+func (s BankMovementStatus) IsValid() bool {
+	return s == BankMovementPending || s == BankMovementReconciled || s == BankMovementIgnored
+}
+
+// BankMovement is a line of a bank statement. Incoming movements (positive amounts) are
+// reconciled with invoices, recording a payment; outgoing ones with expenses.
+type BankMovement struct {
+	ID           uuid.UUID          `json:"id" db:"id"`
+	StatementID  uuid.UUID          `json:"statementId" db:"statement_id"`
+	BookingDate  time.Time          `json:"bookingDate" db:"booking_date"`
+	ValueDate    time.Time          `json:"valueDate" db:"value_date"`
+	Amount       money.Money        `json:"amount" db:"amount"` // Negative for outgoing movements
+	Concept      string             `json:"concept" db:"concept"`
+	Reference    string             `json:"reference,omitempty" db:"reference"`
+	Counterparty string             `json:"counterparty,omitempty" db:"counterparty"` // Payer or payee, when the bank reports it
+	Status       BankMovementStatus `json:"status" db:"status"`
+	InvoiceID    *uuid.UUID         `json:"invoiceId,omitempty" db:"invoice_id"`
+	PaymentID    *uuid.UUID         `json:"paymentId,omitempty" db:"payment_id"` // Payment recorded for the invoice
+	ExpenseID    *uuid.UUID         `json:"expenseId,omitempty" db:"expense_id"`
+	AutoMatched  bool               `json:"autoMatched" db:"auto_matched"`
+	ReconciledBy *uuid.UUID         `json:"reconciledBy,omitempty" db:"reconciled_by"` // Who confirmed or ignored it
+	ReconciledAt *time.Time         `json:"reconciledAt,omitempty" db:"reconciled_at"`
+	CreatedAt    time.Time          `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time          `json:"updatedAt" db:"updated_at"`
+}
+
+// IsIncoming returns true if the movement is money received
+func (m *BankMovement) IsIncoming() bool {
+	return m.Amount.IsPositive()
+}
+
+// IsPending returns true if the movement is waiting in the reconciliation queue
+func (m *BankMovement) IsPending() bool {
+	return m.Status == BankMovementPending
+}
+
+// Bank statement errors
+var (
+	ErrStatementAlreadyImported = errors.NewConflictError("bank statement has already been imported", errors.CodeConflict)
+	ErrMovementNotPending       = errors.NewConflictError("bank movement has already been reconciled or ignored", errors.CodeConflict)
+	ErrExpenseAlreadyReconciled = errors.NewConflictError("expense is already reconciled with another bank movement", errors.CodeConflict)
+	ErrMovementDirection        = errors.NewValidationError("incoming movements are reconciled with invoices and outgoing ones with expenses", nil)
+	ErrMovementAmountMismatch   = errors.NewValidationError("movement amount does not match the expense", nil)
+)
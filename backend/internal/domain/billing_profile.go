@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// BillingProfile is the fiscal identity paying for the sessions of one or more clients:
+// a parent paying for a minor, a company paying for an employee...
+type BillingProfile struct {
+	ID                     uuid.UUID      `json:"id" db:"id"`
+	Name                   string         `json:"name" db:"name"`    // Fiscal name printed on the invoices
+	TaxID                  string         `json:"taxId" db:"tax_id"` // NIF/CIF
+	Email                  string         `json:"email,omitempty" db:"email"`
+	Phone                  string         `json:"phone,omitempty" db:"phone"`
+	AddressStreet          string         `json:"addressStreet" db:"address_street"`
+	AddressCity            string         `json:"addressCity" db:"address_city"`
+	AddressProvince        string         `json:"addressProvince" db:"address_province"`
+	AddressPostalCode      string         `json:"addressPostalCode" db:"address_postal_code"`
+	AddressCountry         string         `json:"addressCountry" db:"address_country"` // ISO 3166-1 alpha-2
+	PreferredPaymentMethod *PaymentMethod `json:"preferredPaymentMethod,omitempty" db:"preferred_payment_method"`
+	Notes                  string         `json:"notes,omitempty" db:"notes"`
+	IsActive               bool           `json:"isActive" db:"is_active"`
+	CreatedAt              time.Time      `json:"createdAt" db:"created_at"`
+	UpdatedAt              time.Time      `json:"updatedAt" db:"updated_at"`
+	DeletedAt              *time.Time     `json:"-" db:"deleted_at"`
+}
+
+// Validate performs basic validation on the billing profile
+func (p *BillingProfile) Validate() error {
+	if strings.TrimSpace(p.Name) == "" || len(p.Name) > 200 {
+		return ErrInvalidBillingProfileName
+	}
+	if strings.TrimSpace(p.TaxID) == "" || len(p.TaxID) > 20 {
+		return ErrInvalidBillingProfileTaxID
+	}
+	if len(p.AddressCountry) > 2 {
+		return ErrInvalidCountryCode
+	}
+	if p.PreferredPaymentMethod != nil && !p.PreferredPaymentMethod.IsValid() {
+		return ErrInvalidPaymentMethod
+	}
+	return nil
+}
+
+// InvoiceParty returns the profile as the party an invoice is addressed to
+func (p *BillingProfile) InvoiceParty() InvoiceParty {
+	return InvoiceParty{
+		Name:       p.Name,
+		TaxID:      p.TaxID,
+		Email:      p.Email,
+		Phone:      p.Phone,
+		Street:     p.AddressStreet,
+		PostalCode: p.AddressPostalCode,
+		City:       p.AddressCity,
+		Province:   p.AddressProvince,
+		Country:    p.AddressCountry,
+	}
+}
+
+// ClientBillingProfile links a client to a billing profile paying for them
+type ClientBillingProfile struct {
+	ClientID         uuid.UUID `json:"clientId" db:"client_id"`
+	BillingProfileID uuid.UUID `json:"billingProfileId" db:"billing_profile_id"`
+	IsDefault        bool      `json:"isDefault" db:"is_default"` // Payer of the new invoices of the client
+	CreatedAt        time.Time `json:"createdAt" db:"created_at"`
+
+	Profile *BillingProfile `json:"profile,omitempty" db:"-"`
+}
+
+// InvoiceParty is the fiscal identity an invoice is addressed to: the payer of the
+// invoice or, when there is none, the client
+type InvoiceParty struct {
+	Name       string
+	FirstName  string // First and last name are only known apart for clients
+	LastName   string
+	TaxID      string
+	Email      string
+	Phone      string
+	Street     string
+	PostalCode string
+	City       string
+	Province   string
+	Country    string
+}
+
+// IsBusiness returns true if the party's tax ID is a company CIF
+func (p InvoiceParty) IsBusiness() bool {
+	return IsCompanyTaxID(p.TaxID)
+}
+
+// AddressLines returns the postal address formatted for printing
+func (p InvoiceParty) AddressLines() []string {
+	return formatAddressLines(p.Street, p.PostalCode, p.City, p.Province)
+}
+
+// Custom errors
+var (
+	ErrInvalidBillingProfileName  = errors.NewValidationError("billing profile name is required (max 200 characters)", nil)
+	ErrInvalidBillingProfileTaxID = errors.NewValidationError("billing profile NIF/CIF is required (max 20 characters)", nil)
+	ErrBillingProfileNotLinked    = errors.NewValidationError("billing profile does not pay for the client", map[string][]string{
+		"billingProfileId": {"link the billing profile to the client first"},
+	})
+	ErrBillingProfileInactive = errors.NewValidationError("billing profile is inactive", map[string][]string{
+		"billingProfileId": {"the billing profile is no longer used"},
+	})
+)
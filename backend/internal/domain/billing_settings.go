@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+)
+
+// DefaultInvoiceAccentColor is used by the invoice template when none is configured
+const DefaultInvoiceAccentColor = "#1f4e79"
+
+// AppointmentInvoicingMode decides what happens to the invoice of a completed appointment
+type AppointmentInvoicingMode string
+
+const (
+	AppointmentInvoicingOff   AppointmentInvoicingMode = "off"   // Appointments are invoiced by hand
+	AppointmentInvoicingDraft AppointmentInvoicingMode = "draft" // A draft is created for review
+	AppointmentInvoicingIssue AppointmentInvoicingMode = "issue" // The invoice is issued right away
+)
+
+// IsValid checks if the mode is one of the supported values
+func (m AppointmentInvoicingMode) IsValid() bool {
+	switch m {
+	case AppointmentInvoicingOff, AppointmentInvoicingDraft, AppointmentInvoicingIssue:
+		return true
+	}
+	return false
+}
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// BillingSettings holds the clinic's fiscal data as invoice issuer and the
+// customisable parts of the invoice template. There is a single row.
+type BillingSettings struct {
+	LegalName         string    `json:"legalName" db:"legal_name"` // Razón social
+	TradeName         string    `json:"tradeName" db:"trade_name"` // Nombre comercial
+	TaxID             string    `json:"taxId" db:"tax_id"`         // NIF/CIF
+	AddressStreet     string    `json:"addressStreet" db:"address_street"`
+	AddressCity       string    `json:"addressCity" db:"address_city"`
+	AddressProvince   string    `json:"addressProvince" db:"address_province"`
+	AddressPostalCode string    `json:"addressPostalCode" db:"address_postal_code"`
+	AddressCountry    string    `json:"addressCountry" db:"address_country"` // ISO 3166-1 alpha-2
+	Email             string    `json:"email" db:"email"`
+	Phone             string    `json:"phone" db:"phone"`
+	Website           string    `json:"website" db:"website"`
+	IBAN              string    `json:"iban" db:"iban"`                       // Printed on invoices for bank transfers
+	SEPACreditorID    string    `json:"sepaCreditorId" db:"sepa_creditor_id"` // Identificador de acreedor, for direct debits
+	RegistryInfo      string    `json:"registryInfo" db:"registry_info"`      // Registro Mercantil or nº de colegiado
+	LogoKey           *string   `json:"-" db:"logo_key"`                      // Storage key of the logo (nullable)
+	LogoContentType   *string   `json:"-" db:"logo_content_type"`
+	FooterText        string    `json:"footerText" db:"footer_text"`   // Printed at the bottom of every page
+	AccentColor       string    `json:"accentColor" db:"accent_color"` // #rrggbb used for headings and tables
+	UpdatedAt         time.Time `json:"updatedAt" db:"updated_at"`
+
+	// Invoicing of appointments marked completed
+	AppointmentInvoicing AppointmentInvoicingMode `json:"appointmentInvoicing" db:"appointment_invoicing"`
+}
+
+// HasLogo returns true if a logo has been uploaded
+func (s *BillingSettings) HasLogo() bool {
+	return s.LogoKey != nil && *s.LogoKey != ""
+}
+
+// DisplayName returns the trade name, or the legal name when there is none
+func (s *BillingSettings) DisplayName() string {
+	if s.TradeName != "" {
+		return s.TradeName
+	}
+	return s.LegalName
+}
+
+// AddressLines returns the postal address formatted for printing
+func (s *BillingSettings) AddressLines() []string {
+	return formatAddressLines(s.AddressStreet, s.AddressPostalCode, s.AddressCity, s.AddressProvince)
+}
+
+// CanCollectDirectDebits returns true if the creditor data of SEPA direct debits is present
+func (s *BillingSettings) CanCollectDirectDebits() bool {
+	return s.LegalName != "" && s.SEPACreditorID != "" && IsValidIBAN(NormalizeIBAN(s.IBAN))
+}
+
+// IsComplete returns true if the data required on a full invoice is present
+func (s *BillingSettings) IsComplete() bool {
+	return s.LegalName != "" && s.TaxID != "" && s.AddressStreet != "" && s.AddressCity != ""
+}
+
+// Validate performs basic validation on the settings
+func (s *BillingSettings) Validate() error {
+	if s.AccentColor != "" && !hexColorPattern.MatchString(s.AccentColor) {
+		return ErrInvalidAccentColor
+	}
+	if len(s.AddressCountry) > 2 {
+		return ErrInvalidCountryCode
+	}
+	if len(s.IBAN) > 34 {
+		return ErrInvalidSettingsIBAN
+	}
+	if s.SEPACreditorID != "" && !IsValidSEPACreditorID(s.SEPACreditorID) {
+		return ErrInvalidSEPACreditorID
+	}
+	if !s.AppointmentInvoicing.IsValid() {
+		return ErrInvalidAppointmentInvoicing
+	}
+	return nil
+}
+
+// formatAddressLines formats a street and a "postal code city (province)" line, skipping empty parts
+func formatAddressLines(street, postalCode, city, province string) []string {
+	var lines []string
+	if street != "" {
+		lines = append(lines, street)
+	}
+
+	locality := strings.TrimSpace(postalCode + " " + city)
+	if province != "" && !strings.EqualFold(province, city) {
+		if locality != "" {
+			locality += " (" + province + ")"
+		} else {
+			locality = province
+		}
+	}
+	if locality != "" {
+		lines = append(lines, locality)
+	}
+
+	return lines
+}
+
+// Custom errors
+var (
+	ErrInvalidAccentColor  = errors.NewValidationError("accent color must be a #rrggbb hex color", nil)
+	ErrInvalidCountryCode  = errors.NewValidationError("country must be a two-letter ISO code", nil)
+	ErrInvalidSettingsIBAN = errors.NewValidationError("IBAN cannot exceed 34 characters", nil)
+
+	ErrInvalidAppointmentInvoicing = errors.NewValidationError("appointment invoicing must be off, draft or issue", nil)
+)
@@ -3,6 +3,7 @@ package domain
 import (
 	"database/sql"
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -25,6 +26,9 @@ type Client struct {
 	AddressPostalCode string `json:"-" db:"address_postal_code"`
 	AddressCountry    string `json:"-" db:"address_country"`
 
+	// Last day of an agreed payment plan; overdue invoices are not chased until then
+	PaymentPlanUntil *time.Time `json:"paymentPlanUntil,omitempty" db:"payment_plan_until"`
+
 	Notes     string       `json:"notes" db:"notes"`
 	IsActive  bool         `json:"isActive" db:"is_active"`
 	CreatedAt time.Time    `json:"createdAt" db:"created_at"`
@@ -52,6 +56,55 @@ func (c *Client) SetAddress(addr Address) {
 	c.AddressCountry = addr.Country
 }
 
+// IsBusiness returns true if the client's tax ID is a company CIF (starts with an entity letter)
+func (c *Client) IsBusiness() bool {
+	return IsCompanyTaxID(c.DNICIF)
+}
+
+// IsCompanyTaxID returns true if a Spanish tax ID is a company CIF rather than a DNI or NIE
+func IsCompanyTaxID(taxID string) bool {
+	taxID = strings.ToUpper(strings.TrimSpace(taxID))
+	if len(taxID) != 9 {
+		return false
+	}
+	return strings.ContainsRune("ABCDEFGHJNPQRSUVW", rune(taxID[0]))
+}
+
+// HasPaymentPlan returns true if the client is in a payment plan on the given day
+func (c *Client) HasPaymentPlan(on time.Time) bool {
+	if c.PaymentPlanUntil == nil {
+		return false
+	}
+	return !c.PaymentPlanUntil.Before(dateOnly(on))
+}
+
+// FullName returns the client's first and last name
+func (c *Client) FullName() string {
+	return strings.TrimSpace(c.FirstName + " " + c.LastName)
+}
+
+// AddressLines returns the postal address formatted for printing (e.g. on invoices)
+func (c *Client) AddressLines() []string {
+	return formatAddressLines(c.AddressStreet, c.AddressPostalCode, c.AddressCity, c.AddressProvince)
+}
+
+// InvoiceParty returns the client as the party an invoice is addressed to
+func (c *Client) InvoiceParty() InvoiceParty {
+	return InvoiceParty{
+		Name:       c.FullName(),
+		FirstName:  strings.TrimSpace(c.FirstName),
+		LastName:   strings.TrimSpace(c.LastName),
+		TaxID:      c.DNICIF,
+		Email:      c.Email,
+		Phone:      c.Phone,
+		Street:     c.AddressStreet,
+		PostalCode: c.AddressPostalCode,
+		City:       c.AddressCity,
+		Province:   c.AddressProvince,
+		Country:    c.AddressCountry,
+	}
+}
+
 // MarshalJSON customizes JSON output to include nested Address
 func (c *Client) MarshalJSON() ([]byte, error) {
 	type Alias Client
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
@@ -58,23 +59,24 @@ func (s *StringArray) UnmarshalJSON(data []byte) error {
 
 // Employee represents an employee/therapist in the system
 type Employee struct {
-	ID          uuid.UUID   `json:"id" db:"id"`
-	UserID      *uuid.UUID  `json:"userId,omitempty" db:"user_id"`
-	FirstName   string      `json:"firstName" db:"first_name"`
-	LastName    string      `json:"lastName" db:"last_name"`
-	Email       string      `json:"email" db:"email"`
-	Phone       string      `json:"phone" db:"phone"`
-	DNI         string      `json:"dni" db:"dni"`
-	DateOfBirth *time.Time  `json:"dateOfBirth,omitempty" db:"date_of_birth"`
-	Position    *string     `json:"position,omitempty" db:"position"`
-	Specialties StringArray `json:"specialties" db:"specialties"`
-	IsActive    bool        `json:"isActive" db:"is_active"`
-	HireDate    *time.Time  `json:"hireDate,omitempty" db:"hire_date"`
-	Notes       *string     `json:"notes,omitempty" db:"notes"`
-	AvatarColor string      `json:"avatarColor" db:"avatar_color"`
-	CreatedAt   time.Time   `json:"createdAt" db:"created_at"`
-	UpdatedAt   time.Time   `json:"updatedAt" db:"updated_at"`
-	DeletedAt   *time.Time  `json:"deletedAt,omitempty" db:"deleted_at"`
+	ID          uuid.UUID    `json:"id" db:"id"`
+	UserID      *uuid.UUID   `json:"userId,omitempty" db:"user_id"`
+	FirstName   string       `json:"firstName" db:"first_name"`
+	LastName    string       `json:"lastName" db:"last_name"`
+	Email       string       `json:"email" db:"email"`
+	Phone       string       `json:"phone" db:"phone"`
+	DNI         string       `json:"dni" db:"dni"`
+	DateOfBirth *time.Time   `json:"dateOfBirth,omitempty" db:"date_of_birth"`
+	Position    *string      `json:"position,omitempty" db:"position"`
+	Specialties StringArray  `json:"specialties" db:"specialties"`
+	IsActive    bool         `json:"isActive" db:"is_active"`
+	HireDate    *time.Time   `json:"hireDate,omitempty" db:"hire_date"`
+	Notes       *string      `json:"notes,omitempty" db:"notes"`
+	AvatarColor string       `json:"avatarColor" db:"avatar_color"`
+	SessionRate *money.Money `json:"sessionRate,omitempty" db:"session_rate"` // Price per session before VAT
+	CreatedAt   time.Time    `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updatedAt" db:"updated_at"`
+	DeletedAt   *time.Time   `json:"deletedAt,omitempty" db:"deleted_at"`
 }
 
 // FullName returns the employee's full name
@@ -4,26 +4,41 @@ import (
 	"time"
 
 	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 )
 
 // Expense represents a business expense
 type Expense struct {
-	ID              uuid.UUID  `json:"id" db:"id"`
-	ExpenseDate     time.Time  `json:"expenseDate" db:"expense_date"`
-	SupplierInvoice *string    `json:"supplierInvoice,omitempty" db:"supplier_invoice"` // Nº Factura emisor (nullable)
-	Supplier        string     `json:"supplier" db:"supplier"`                          // Nombre del proveedor
-	Amount          float64    `json:"amount" db:"amount"`                              // Importe total
-	CategoryID      uuid.UUID  `json:"categoryId" db:"category_id"`
-	SubcategoryID   *uuid.UUID `json:"subcategoryId,omitempty" db:"subcategory_id"`   // Nullable
-	HasInvoice      bool       `json:"hasInvoice" db:"has_invoice"`                   // Si/No
-	AttachmentPath  *string    `json:"attachmentPath,omitempty" db:"attachment_path"` // PDF path (nullable)
-	Description     *string    `json:"description,omitempty" db:"description"`        // Nullable
-	PaymentMethod   *string    `json:"paymentMethod,omitempty" db:"payment_method"`   // Nullable
-	Notes           *string    `json:"notes,omitempty" db:"notes"`                    // Nullable
-	CreatedAt       time.Time  `json:"createdAt" db:"created_at"`
-	UpdatedAt       time.Time  `json:"updatedAt" db:"updated_at"`
-	DeletedAt       *time.Time `json:"-" db:"deleted_at"` // Soft delete timestamp
+	ID              uuid.UUID   `json:"id" db:"id"`
+	ExpenseDate     time.Time   `json:"expenseDate" db:"expense_date"`
+	SupplierInvoice *string     `json:"supplierInvoice,omitempty" db:"supplier_invoice"` // Nº Factura emisor (nullable)
+	Supplier        string      `json:"supplier" db:"supplier"`                          // Nombre del proveedor
+	Amount          money.Money `json:"amount" db:"amount"`                              // Importe total
+	VATAmount       money.Money `json:"vatAmount" db:"vat_amount"`                       // IVA soportado included in the amount
+	VATDeductible   bool        `json:"vatDeductible" db:"vat_deductible"`               // Deducted in Modelo 303
+	IRPFDeductible  bool        `json:"irpfDeductible" db:"irpf_deductible"`             // Deductible for IRPF (Modelo 130)
+	CategoryID      uuid.UUID   `json:"categoryId" db:"category_id"`
+	SubcategoryID   *uuid.UUID  `json:"subcategoryId,omitempty" db:"subcategory_id"`   // Nullable
+	HasInvoice      bool        `json:"hasInvoice" db:"has_invoice"`                   // Si/No
+	AttachmentPath  *string     `json:"attachmentPath,omitempty" db:"attachment_path"` // Storage key of the receipt (nullable)
+	Description     *string     `json:"description,omitempty" db:"description"`        // Nullable
+	PaymentMethod   *string     `json:"paymentMethod,omitempty" db:"payment_method"`   // Nullable
+	Notes           *string     `json:"notes,omitempty" db:"notes"`                    // Nullable
+	CreatedAt       time.Time   `json:"createdAt" db:"created_at"`
+	UpdatedAt       time.Time   `json:"updatedAt" db:"updated_at"`
+	DeletedAt       *time.Time  `json:"-" db:"deleted_at"` // Soft delete timestamp
+
+	// Set on expenses generated from a recurring expense until someone reviews them
+	RecurringExpenseID *uuid.UUID `json:"recurringExpenseId,omitempty" db:"recurring_expense_id"`
+	NeedsReview        bool       `json:"needsReview" db:"needs_review"`
+
+	// Uploaded receipt (nullable, set together with AttachmentPath)
+	AttachmentFileName    *string    `json:"attachmentFileName,omitempty" db:"attachment_file_name"`
+	AttachmentContentType *string    `json:"attachmentContentType,omitempty" db:"attachment_content_type"`
+	AttachmentSizeBytes   *int64     `json:"attachmentSizeBytes,omitempty" db:"attachment_size_bytes"`
+	AttachmentSHA256      *string    `json:"attachmentSha256,omitempty" db:"attachment_sha256"` // Hex digest of the content
+	AttachmentUploadedAt  *time.Time `json:"attachmentUploadedAt,omitempty" db:"attachment_uploaded_at"`
 
 	// Relationships (populated via joins, not stored in DB)
 	Category    *ExpenseCategory `json:"category,omitempty" db:"-"`
@@ -35,9 +50,15 @@ func (e *Expense) Validate() error {
 	if e.Supplier == "" {
 		return ErrInvalidSupplier
 	}
-	if e.Amount <= 0 {
+	if !e.Amount.IsPositive() {
 		return ErrInvalidExpenseAmount
 	}
+	if e.VATAmount.IsNegative() {
+		return ErrInvalidExpenseVAT
+	}
+	if cmp, err := e.VATAmount.Cmp(e.Amount); err != nil || cmp >= 0 {
+		return ErrInvalidExpenseVAT
+	}
 	if e.CategoryID == uuid.Nil {
 		return ErrInvalidCategory
 	}
@@ -47,11 +68,45 @@ func (e *Expense) Validate() error {
 	return nil
 }
 
+// BaseAmount returns the amount without the VAT it includes; it fails when the amount and
+// the VAT are in different currencies
+func (e *Expense) BaseAmount() (money.Money, error) {
+	return e.Amount.Sub(e.VATAmount)
+}
+
+// IRPFDeductibleAmount returns what the expense deducts for IRPF: its base when the VAT
+// is deducted in Modelo 303, otherwise the whole amount since the VAT is a cost too
+func (e *Expense) IRPFDeductibleAmount() (money.Money, error) {
+	if !e.IRPFDeductible {
+		return money.Money{}, nil
+	}
+	if e.VATDeductible {
+		return e.BaseAmount()
+	}
+	return e.Amount, nil
+}
+
 // HasAttachment returns true if the expense has an attached invoice PDF
 func (e *Expense) HasAttachment() bool {
 	return e.AttachmentPath != nil && *e.AttachmentPath != ""
 }
 
+// HasUploadedReceipt returns true if the attachment was uploaded, rather than being a path
+// recorded before uploads were supported
+func (e *Expense) HasUploadedReceipt() bool {
+	return e.HasAttachment() && e.AttachmentSHA256 != nil
+}
+
+// ClearAttachment removes the receipt from the expense
+func (e *Expense) ClearAttachment() {
+	e.AttachmentPath = nil
+	e.AttachmentFileName = nil
+	e.AttachmentContentType = nil
+	e.AttachmentSizeBytes = nil
+	e.AttachmentSHA256 = nil
+	e.AttachmentUploadedAt = nil
+}
+
 // ExpenseCategoryWithChildren represents a category with its subcategories
 type ExpenseCategoryWithChildren struct {
 	ExpenseCategory
@@ -62,6 +117,8 @@ type ExpenseCategoryWithChildren struct {
 var (
 	ErrInvalidSupplier      = errors.NewValidationError("supplier name is required", nil)
 	ErrInvalidExpenseAmount = errors.NewValidationError("amount must be greater than 0", nil)
+	ErrInvalidExpenseVAT    = errors.NewValidationError("VAT amount must be at least 0 and lower than the amount", nil)
 	ErrInvalidCategory      = errors.NewValidationError("category is required", nil)
 	ErrInvalidExpenseDate   = errors.NewValidationError("expense date is required", nil)
+	ErrExpenseHasNoReceipt  = errors.NewNotFoundError("expense has no uploaded receipt")
 )
@@ -0,0 +1,199 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// Insurer represents an insurance company (mutua) paying for the sessions of its insured
+// clients. Its fiscal data is a billing profile, the payer of the monthly settlement invoices.
+type Insurer struct {
+	ID               uuid.UUID   `json:"id" db:"id"`
+	Name             string      `json:"name" db:"name"`
+	BillingProfileID uuid.UUID   `json:"billingProfileId" db:"billing_profile_id"`
+	SessionRate      money.Money `json:"sessionRate" db:"session_rate"`          // Agreed price per session before VAT
+	PaymentTermDays  int         `json:"paymentTermDays" db:"payment_term_days"` // Days until a settlement invoice is due
+	Notes            *string     `json:"notes,omitempty" db:"notes"`
+	IsActive         bool        `json:"isActive" db:"is_active"`
+	CreatedAt        time.Time   `json:"createdAt" db:"created_at"`
+	UpdatedAt        time.Time   `json:"updatedAt" db:"updated_at"`
+	DeletedAt        *time.Time  `json:"-" db:"deleted_at"`
+}
+
+// Validate performs basic validation on the insurer
+func (i *Insurer) Validate() error {
+	if strings.TrimSpace(i.Name) == "" {
+		return ErrInvalidInsurerName
+	}
+	if i.BillingProfileID == uuid.Nil {
+		return ErrInvalidInsurerBillingProfile
+	}
+	if !i.SessionRate.IsPositive() {
+		return ErrInvalidInsurerSessionRate
+	}
+	if i.PaymentTermDays < 0 {
+		return ErrInvalidInsurerPaymentTerm
+	}
+	return nil
+}
+
+// InsurancePolicy represents the policy of a client with an insurer
+type InsurancePolicy struct {
+	ID           uuid.UUID   `json:"id" db:"id"`
+	ClientID     uuid.UUID   `json:"clientId" db:"client_id"`
+	InsurerID    uuid.UUID   `json:"insurerId" db:"insurer_id"`
+	PolicyNumber string      `json:"policyNumber" db:"policy_number"`
+	Copay        money.Money `json:"copay" db:"copay"` // Paid by the client for each covered session
+	ValidFrom    time.Time   `json:"validFrom" db:"valid_from"`
+	ValidUntil   *time.Time  `json:"validUntil,omitempty" db:"valid_until"` // Open-ended when nil
+	Notes        *string     `json:"notes,omitempty" db:"notes"`
+	IsActive     bool        `json:"isActive" db:"is_active"`
+	CreatedAt    time.Time   `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time   `json:"updatedAt" db:"updated_at"`
+	DeletedAt    *time.Time  `json:"-" db:"deleted_at"`
+
+	// Relations (not in DB)
+	Insurer        *Insurer                  `json:"insurer,omitempty" db:"-"`
+	Authorisations []*InsuranceAuthorisation `json:"authorisations,omitempty" db:"-"`
+}
+
+// Validate performs basic validation on the policy
+func (p *InsurancePolicy) Validate() error {
+	if strings.TrimSpace(p.PolicyNumber) == "" || len(p.PolicyNumber) > 50 {
+		return ErrInvalidPolicyNumber
+	}
+	if p.Copay.IsNegative() {
+		return ErrInvalidPolicyCopay
+	}
+	if p.ValidFrom.IsZero() || (p.ValidUntil != nil && p.ValidUntil.Before(p.ValidFrom)) {
+		return ErrInvalidPolicyValidity
+	}
+	return nil
+}
+
+// IsValidOn returns true if the policy is active and in force on the day of at
+func (p *InsurancePolicy) IsValidOn(at time.Time) bool {
+	if !p.IsActive {
+		return false
+	}
+	day := dateOf(at)
+	if day.Before(dateOf(p.ValidFrom)) {
+		return false
+	}
+	return p.ValidUntil == nil || !day.After(dateOf(*p.ValidUntil))
+}
+
+// InsuranceAuthorisation represents the sessions an insurer authorised for a policy
+type InsuranceAuthorisation struct {
+	ID                  uuid.UUID  `json:"id" db:"id"`
+	PolicyID            uuid.UUID  `json:"policyId" db:"policy_id"`
+	AuthorisationNumber string     `json:"authorisationNumber" db:"authorisation_number"`
+	ServiceTypeID       *uuid.UUID `json:"serviceTypeId,omitempty" db:"service_type_id"` // Sessions it covers; any when nil
+	SessionsAuthorised  int        `json:"sessionsAuthorised" db:"sessions_authorised"`
+	SessionsUsed        int        `json:"sessionsUsed" db:"sessions_used"`
+	ValidFrom           time.Time  `json:"validFrom" db:"valid_from"`
+	ValidUntil          time.Time  `json:"validUntil" db:"valid_until"` // Last day sessions can be taken
+	Notes               *string    `json:"notes,omitempty" db:"notes"`
+	CreatedAt           time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// Validate performs basic validation on the authorisation
+func (a *InsuranceAuthorisation) Validate() error {
+	if strings.TrimSpace(a.AuthorisationNumber) == "" || len(a.AuthorisationNumber) > 50 {
+		return ErrInvalidAuthorisationNumber
+	}
+	if a.SessionsAuthorised <= 0 || a.SessionsAuthorised < a.SessionsUsed {
+		return ErrInvalidAuthorisationSessions
+	}
+	if a.ValidFrom.IsZero() || a.ValidUntil.Before(a.ValidFrom) {
+		return ErrInvalidAuthorisationValidity
+	}
+	return nil
+}
+
+// SessionsRemaining returns the authorised sessions not taken yet
+func (a *InsuranceAuthorisation) SessionsRemaining() int {
+	return a.SessionsAuthorised - a.SessionsUsed
+}
+
+// Covers returns true if the authorisation applies to sessions of the service type
+func (a *InsuranceAuthorisation) Covers(serviceTypeID *uuid.UUID) bool {
+	return a.ServiceTypeID == nil || (serviceTypeID != nil && *a.ServiceTypeID == *serviceTypeID)
+}
+
+// IsExpired returns true if sessions can no longer be taken on the day of at
+func (a *InsuranceAuthorisation) IsExpired(at time.Time) bool {
+	return dateOf(at).After(dateOf(a.ValidUntil))
+}
+
+// IsValidOn returns true if a session can be taken on the day of at
+func (a *InsuranceAuthorisation) IsValidOn(at time.Time) bool {
+	return !dateOf(at).Before(dateOf(a.ValidFrom)) && !a.IsExpired(at) && a.SessionsRemaining() > 0
+}
+
+// CoveredSession records a completed appointment taken from an authorisation, with the
+// part of its price paid by the insurer and the co-payment of the client
+type CoveredSession struct {
+	ID                  uuid.UUID   `json:"id" db:"id"`
+	AuthorisationID     uuid.UUID   `json:"authorisationId" db:"authorisation_id"`
+	AppointmentID       uuid.UUID   `json:"appointmentId" db:"appointment_id"`
+	ClientID            uuid.UUID   `json:"clientId" db:"client_id"`
+	InsurerID           uuid.UUID   `json:"insurerId" db:"insurer_id"`
+	ServiceTypeID       *uuid.UUID  `json:"serviceTypeId,omitempty" db:"service_type_id"`
+	SessionDate         time.Time   `json:"sessionDate" db:"session_date"`
+	InsurerAmount       money.Money `json:"insurerAmount" db:"insurer_amount"`
+	CopayAmount         money.Money `json:"copayAmount" db:"copay_amount"`
+	SettlementInvoiceID *uuid.UUID  `json:"settlementInvoiceId,omitempty" db:"settlement_invoice_id"`
+	CreatedAt           time.Time   `json:"createdAt" db:"created_at"`
+
+	// Loaded with the sessions pending settlement (not in the table)
+	ClientName          string `json:"clientName,omitempty" db:"client_name"`
+	PolicyNumber        string `json:"policyNumber,omitempty" db:"policy_number"`
+	AuthorisationNumber string `json:"authorisationNumber,omitempty" db:"authorisation_number"`
+}
+
+// BookingWarningCode identifies why a booking may not be paid as expected
+type BookingWarningCode string
+
+const (
+	BookingWarningNoAuthorisation     BookingWarningCode = "no_authorisation"
+	BookingWarningAuthorisationUsedUp BookingWarningCode = "authorisation_exhausted"
+	BookingWarningAuthorisationExpiry BookingWarningCode = "authorisation_expired"
+)
+
+// BookingWarning flags a booking that is allowed but needs attention
+type BookingWarning struct {
+	Code    BookingWarningCode `json:"code"`
+	Message string             `json:"message"`
+}
+
+// dateOf truncates a time to its day in UTC, as dates are stored
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Insurer errors
+var (
+	ErrInvalidInsurerName           = errors.NewValidationError("insurer name is required", nil)
+	ErrInvalidInsurerBillingProfile = errors.NewValidationError("insurer billing profile is required", map[string][]string{
+		"billingProfileId": {"the fiscal data of the insurer is required"},
+	})
+	ErrInvalidInsurerSessionRate    = errors.NewValidationError("insurer session rate must be positive", nil)
+	ErrInvalidInsurerPaymentTerm    = errors.NewValidationError("insurer payment term cannot be negative", nil)
+	ErrInsurerInactive              = errors.NewValidationError("insurer is inactive", nil)
+	ErrInvalidPolicyNumber          = errors.NewValidationError("policy number is required (max 50 characters)", nil)
+	ErrInvalidPolicyCopay           = errors.NewValidationError("co-payment cannot be negative", nil)
+	ErrInvalidPolicyValidity        = errors.NewValidationError("policy must end after it starts", nil)
+	ErrInvalidAuthorisationNumber   = errors.NewValidationError("authorisation number is required (max 50 characters)", nil)
+	ErrInvalidAuthorisationSessions = errors.NewValidationError("authorisation must include at least one session and the ones already used", nil)
+	ErrInvalidAuthorisationValidity = errors.NewValidationError("authorisation must end after it starts", nil)
+	ErrAuthorisationInUse           = errors.NewConflictError("authorisation has covered sessions", errors.CodeConflict)
+	ErrNoUsableAuthorisation        = errors.NewNotFoundError("client has no insurance authorisation with sessions left")
+	ErrSessionAlreadyCovered        = errors.NewConflictError("appointment is already covered by an insurer", errors.CodeConflict)
+	ErrNothingToSettle              = errors.NewValidationError("insurer has no covered sessions to settle", nil)
+)
@@ -1,9 +1,12 @@
 package domain
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -11,60 +14,263 @@ import (
 type InvoiceStatus string
 
 const (
-	InvoiceStatusPaid   InvoiceStatus = "paid"   // Cobrado
-	InvoiceStatusUnpaid InvoiceStatus = "unpaid" // No Cobrado
+	InvoiceStatusDraft         InvoiceStatus = "draft"          // Borrador (pending review, no number assigned yet)
+	InvoiceStatusPaid          InvoiceStatus = "paid"           // Cobrado
+	InvoiceStatusPartiallyPaid InvoiceStatus = "partially_paid" // Cobrado parcialmente
+	InvoiceStatusUnpaid        InvoiceStatus = "unpaid"         // No Cobrado
 
-	// FixedVATRate is the fixed VAT rate of 21% for all invoices
-	FixedVATRate float64 = 0.21
+	// DraftInvoiceNumberPrefix marks the provisional number of a draft invoice
+	DraftInvoiceNumberPrefix = "BORRADOR_"
 )
 
+// InvoiceType distinguishes ordinary invoices from rectifying and simplified ones
+type InvoiceType string
+
+const (
+	InvoiceTypeOrdinary   InvoiceType = "ordinary"   // Factura ordinaria
+	InvoiceTypeRectifying InvoiceType = "rectifying" // Factura rectificativa
+	InvoiceTypeSimplified InvoiceType = "simplified" // Factura simplificada (ticket)
+)
+
+// IsValid returns true if the invoice type is supported
+func (t InvoiceType) IsValid() bool {
+	switch t {
+	case InvoiceTypeOrdinary, InvoiceTypeRectifying, InvoiceTypeSimplified:
+		return true
+	}
+	return false
+}
+
+// RectificationMode is how a rectifying invoice corrects the original one
+type RectificationMode string
+
+const (
+	RectificationModeCancellation RectificationMode = "cancellation" // Anulación total
+	RectificationModeDifference   RectificationMode = "difference"   // Rectificación por diferencias
+)
+
+// IsValid returns true if the rectification mode is supported
+func (m RectificationMode) IsValid() bool {
+	return m == RectificationModeCancellation || m == RectificationModeDifference
+}
+
 // Invoice represents a billing invoice for services rendered
 type Invoice struct {
 	ID            uuid.UUID     `json:"id" db:"id"`
-	InvoiceNumber string        `json:"invoiceNumber" db:"invoice_number"` // F_2025_0001
-	ClientID      uuid.UUID     `json:"clientId" db:"client_id"`
+	InvoiceNumber string        `json:"invoiceNumber" db:"invoice_number"`           // F_2025_0001, assigned by the series when issued
+	ClientID      uuid.UUID     `json:"clientId" db:"client_id"`                     // uuid.Nil on the settlement invoices of an insurer
 	AppointmentID *uuid.UUID    `json:"appointmentId,omitempty" db:"appointment_id"` // Nullable for manual invoices
 	IssueDate     time.Time     `json:"issueDate" db:"issue_date"`
-	DueDate       time.Time     `json:"dueDate" db:"due_date"` // Payment due date
-	Description   string        `json:"description" db:"description"`
-	BaseAmount    float64       `json:"baseAmount" db:"base_amount"`                 // Base imponible (sin IVA)
-	VATRate       float64       `json:"vatRate" db:"vat_rate"`                       // 21% by default
-	VATAmount     float64       `json:"vatAmount" db:"vat_amount"`                   // Calculated: BaseAmount * 0.21
-	TotalAmount   float64       `json:"totalAmount" db:"total_amount"`               // BaseAmount + VATAmount
-	Status        InvoiceStatus `json:"status" db:"status"`                          // paid/unpaid
-	PaymentMethod *string       `json:"paymentMethod,omitempty" db:"payment_method"` // Nullable payment method
+	DueDate       time.Time     `json:"dueDate" db:"due_date"`                       // Payment due date
+	Description   string        `json:"description" db:"description"`                // Summary of the lines
+	BaseAmount    money.Money   `json:"baseAmount" db:"base_amount"`                 // Base imponible (sin IVA): sum of the lines
+	VATRate       float64       `json:"vatRate" db:"vat_rate"`                       // Rate shared by all lines, 0 when they differ
+	VATAmount     money.Money   `json:"vatAmount" db:"vat_amount"`                   // Sum of the VAT of each rate group
+	IRPFRate      float64       `json:"irpfRate" db:"irpf_rate"`                     // IRPF withholding percentage (invoices to businesses)
+	IRPFAmount    money.Money   `json:"irpfAmount" db:"irpf_amount"`                 // Withheld amount, deducted from the total
+	TotalAmount   money.Money   `json:"totalAmount" db:"total_amount"`               // BaseAmount + VATAmount - IRPFAmount
+	PaidAmount    money.Money   `json:"paidAmount" db:"paid_amount"`                 // Sum of the payments not reversed
+	Status        InvoiceStatus `json:"status" db:"status"`                          // draft/unpaid/partially_paid/paid, derived from the payments once issued
+	PaymentMethod *string       `json:"paymentMethod,omitempty" db:"payment_method"` // Method of the latest payment
 	Notes         string        `json:"notes,omitempty" db:"notes"`
-	PDFPath       *string       `json:"pdfPath,omitempty" db:"pdf_path"` // Path to generated PDF (nullable)
-	CreatedAt     time.Time     `json:"createdAt" db:"created_at"`
-	UpdatedAt     time.Time     `json:"updatedAt" db:"updated_at"`
-	DeletedAt     *time.Time    `json:"-" db:"deleted_at"` // Soft delete timestamp
+	PDFPath       *string       `json:"pdfPath,omitempty" db:"pdf_path"`     // Path to generated PDF (nullable)
+	OverdueAt     *time.Time    `json:"overdueAt,omitempty" db:"overdue_at"` // Set by the dunning job once past the due date, cleared when paid
+
+	// Rectifying invoices reference the invoice they correct
+	InvoiceType         InvoiceType        `json:"invoiceType" db:"invoice_type"`
+	RectifiedInvoiceID  *uuid.UUID         `json:"rectifiedInvoiceId,omitempty" db:"rectified_invoice_id"`
+	RectificationMode   *RectificationMode `json:"rectificationMode,omitempty" db:"rectification_mode"`
+	RectificationReason string             `json:"rectificationReason,omitempty" db:"rectification_reason"`
+
+	// Numbering series and position in it; the number is assigned when the invoice is issued
+	SeriesID       *uuid.UUID `json:"seriesId,omitempty" db:"series_id"`
+	NumberYear     *int       `json:"numberYear,omitempty" db:"number_year"` // 0 for series without yearly reset
+	SequenceNumber *int       `json:"sequenceNumber,omitempty" db:"sequence_number"`
+
+	// Payer the invoice is addressed to when it is not the client, who is then the beneficiary.
+	// The fiscal data of the billing profile is copied, so issued invoices keep it.
+	BillingProfileID       *uuid.UUID `json:"billingProfileId,omitempty" db:"billing_profile_id"`
+	PayerName              string     `json:"payerName,omitempty" db:"payer_name"`
+	PayerTaxID             string     `json:"payerTaxId,omitempty" db:"payer_tax_id"`
+	PayerEmail             string     `json:"payerEmail,omitempty" db:"payer_email"`
+	PayerAddressStreet     string     `json:"payerAddressStreet,omitempty" db:"payer_address_street"`
+	PayerAddressCity       string     `json:"payerAddressCity,omitempty" db:"payer_address_city"`
+	PayerAddressProvince   string     `json:"payerAddressProvince,omitempty" db:"payer_address_province"`
+	PayerAddressPostalCode string     `json:"payerAddressPostalCode,omitempty" db:"payer_address_postal_code"`
+	PayerAddressCountry    string     `json:"payerAddressCountry,omitempty" db:"payer_address_country"`
+
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time  `json:"updatedAt" db:"updated_at"`
+	DeletedAt *time.Time `json:"-" db:"deleted_at"` // Soft delete timestamp
+
+	// Lines are stored in invoice_lines and loaded with the invoice
+	Lines []*InvoiceLine `json:"lines,omitempty" db:"-"`
 
 	// Relationships (populated via joins, not stored in DB)
 	Client      *Client      `json:"client,omitempty" db:"-"`
 	Appointment *Appointment `json:"appointment,omitempty" db:"-"`
 }
 
-// CalculateAmounts calculates VAT and total amounts based on base amount
-func (i *Invoice) CalculateAmounts() {
-	i.VATAmount = i.BaseAmount * (i.VATRate / 100)
-	i.TotalAmount = i.BaseAmount + i.VATAmount
+// CalculateAmounts calculates the line amounts and the invoice totals from its lines.
+// VAT is computed per rate on the summed base of each group, and IRPF on the total base.
+// It fails if the lines are priced in different currencies.
+func (i *Invoice) CalculateAmounts() error {
+	for idx, line := range i.Lines {
+		line.InvoiceID = i.ID
+		line.Position = idx + 1
+		if err := line.CalculateAmounts(); err != nil {
+			return err
+		}
+
+		if idx == 0 {
+			i.VATRate = line.VATRate
+		} else if line.VATRate != i.VATRate {
+			i.VATRate = 0
+		}
+	}
+
+	breakdown, err := i.TaxBreakdown()
+	if err != nil {
+		return err
+	}
+
+	bases := make([]money.Money, 0, len(breakdown))
+	quotas := make([]money.Money, 0, len(breakdown))
+	for _, group := range breakdown {
+		bases = append(bases, group.BaseAmount)
+		quotas = append(quotas, group.VATAmount)
+	}
+
+	if i.BaseAmount, err = money.Sum(bases...); err != nil {
+		return err
+	}
+	if i.VATAmount, err = money.Sum(quotas...); err != nil {
+		return err
+	}
+
+	i.IRPFAmount = i.BaseAmount.Percent(i.IRPFRate)
+	if i.TotalAmount, err = i.BaseAmount.Add(i.VATAmount); err != nil {
+		return err
+	}
+	i.TotalAmount, err = i.TotalAmount.Sub(i.IRPFAmount)
+	return err
+}
+
+// TaxBreakdown returns the taxable base and VAT of the invoice grouped by rate
+func (i *Invoice) TaxBreakdown() ([]TaxBreakdownItem, error) {
+	return buildTaxBreakdown(i.Lines)
 }
 
-// IsPaid returns true if the invoice has been paid
-func (i *Invoice) IsPaid() bool {
-	return i.Status == InvoiceStatusPaid
+// HasWithholding returns true if IRPF is withheld from the invoice
+func (i *Invoice) HasWithholding() bool {
+	return i.IRPFRate > 0
+}
+
+// SummarizeLines returns a description of the invoice built from its lines
+func (i *Invoice) SummarizeLines() string {
+	switch len(i.Lines) {
+	case 0:
+		return ""
+	case 1:
+		return i.Lines[0].Description
+	default:
+		return fmt.Sprintf("%s y %d conceptos más", i.Lines[0].Description, len(i.Lines)-1)
+	}
+}
+
+// DraftInvoiceNumber returns the provisional number used by a draft invoice
+func DraftInvoiceNumber(id uuid.UUID) string {
+	return DraftInvoiceNumberPrefix + id.String()
 }
 
-// MarkAsPaid marks the invoice as paid
-func (i *Invoice) MarkAsPaid() {
-	i.Status = InvoiceStatusPaid
-	i.UpdatedAt = time.Now()
+// IsDraft returns true if the invoice has not been issued yet
+func (i *Invoice) IsDraft() bool {
+	return i.Status == InvoiceStatusDraft
 }
 
-// MarkAsUnpaid marks the invoice as unpaid
-func (i *Invoice) MarkAsUnpaid() {
-	i.Status = InvoiceStatusUnpaid
-	i.UpdatedAt = time.Now()
+// IsIssued returns true if the invoice has been issued; issued invoices are immutable
+func (i *Invoice) IsIssued() bool {
+	return !i.IsDraft()
+}
+
+// IsRectifying returns true if the invoice corrects another invoice
+func (i *Invoice) IsRectifying() bool {
+	return i.InvoiceType == InvoiceTypeRectifying
+}
+
+// IsSimplified returns true if the invoice is a simplified invoice (ticket)
+func (i *Invoice) IsSimplified() bool {
+	return i.InvoiceType == InvoiceTypeSimplified
+}
+
+// HasPayer returns true if the invoice is addressed to a payer other than the client
+func (i *Invoice) HasPayer() bool {
+	return i.BillingProfileID != nil
+}
+
+// HasClient returns true if the invoice bills a client; the settlement invoices of an
+// insurer cover sessions of many clients and are addressed only to the insurer
+func (i *Invoice) HasClient() bool {
+	return i.ClientID != uuid.Nil
+}
+
+// SetPayer addresses the invoice to a billing profile, copying its fiscal data; nil
+// addresses it to the client
+func (i *Invoice) SetPayer(profile *BillingProfile) {
+	if profile == nil {
+		i.BillingProfileID = nil
+		i.PayerName, i.PayerTaxID, i.PayerEmail = "", "", ""
+		i.PayerAddressStreet, i.PayerAddressCity, i.PayerAddressProvince = "", "", ""
+		i.PayerAddressPostalCode, i.PayerAddressCountry = "", ""
+		return
+	}
+
+	id := profile.ID
+	i.BillingProfileID = &id
+	i.PayerName = profile.Name
+	i.PayerTaxID = profile.TaxID
+	i.PayerEmail = profile.Email
+	i.PayerAddressStreet = profile.AddressStreet
+	i.PayerAddressCity = profile.AddressCity
+	i.PayerAddressProvince = profile.AddressProvince
+	i.PayerAddressPostalCode = profile.AddressPostalCode
+	i.PayerAddressCountry = profile.AddressCountry
+}
+
+// CopyPayer addresses the invoice to the payer of another invoice, with the same data
+func (i *Invoice) CopyPayer(from *Invoice) {
+	i.BillingProfileID = from.BillingProfileID
+	i.PayerName = from.PayerName
+	i.PayerTaxID = from.PayerTaxID
+	i.PayerEmail = from.PayerEmail
+	i.PayerAddressStreet = from.PayerAddressStreet
+	i.PayerAddressCity = from.PayerAddressCity
+	i.PayerAddressProvince = from.PayerAddressProvince
+	i.PayerAddressPostalCode = from.PayerAddressPostalCode
+	i.PayerAddressCountry = from.PayerAddressCountry
+}
+
+// Recipient returns the party the invoice is addressed to: its payer or, when there is
+// none, its client
+func (i *Invoice) Recipient(client *Client) InvoiceParty {
+	if !i.HasPayer() {
+		return client.InvoiceParty()
+	}
+	return InvoiceParty{
+		Name:       i.PayerName,
+		TaxID:      i.PayerTaxID,
+		Email:      i.PayerEmail,
+		Street:     i.PayerAddressStreet,
+		PostalCode: i.PayerAddressPostalCode,
+		City:       i.PayerAddressCity,
+		Province:   i.PayerAddressProvince,
+		Country:    i.PayerAddressCountry,
+	}
+}
+
+// IsPaid returns true if the invoice has been paid in full
+func (i *Invoice) IsPaid() bool {
+	return i.Status == InvoiceStatusPaid
 }
 
 // IsManual returns true if the invoice was created manually (not from appointment)
@@ -77,12 +283,55 @@ func (i *Invoice) Validate() error {
 	if i.InvoiceNumber == "" {
 		return ErrInvalidInvoiceNumber
 	}
-	if i.ClientID == uuid.Nil {
+	if i.ClientID == uuid.Nil && !i.HasPayer() {
 		return ErrInvalidClientID
 	}
-	if i.BaseAmount <= 0 {
+	if !i.InvoiceType.IsValid() {
+		return ErrInvalidInvoiceType
+	}
+	if len(i.Lines) == 0 {
+		return ErrInvoiceWithoutLines
+	}
+	if i.IsRectifying() {
+		return i.validateRectification()
+	}
+	for _, line := range i.Lines {
+		if err := line.Validate(); err != nil {
+			return err
+		}
+	}
+	if !i.BaseAmount.IsPositive() {
 		return ErrInvalidAmount
 	}
+	return i.validateCommon()
+}
+
+// validateRectification validates a rectifying invoice, whose lines may subtract from the original
+func (i *Invoice) validateRectification() error {
+	if i.RectifiedInvoiceID == nil {
+		return ErrRectifiedInvoiceRequired
+	}
+	if i.RectificationMode == nil || !i.RectificationMode.IsValid() {
+		return ErrInvalidRectificationMode
+	}
+	if strings.TrimSpace(i.RectificationReason) == "" {
+		return ErrRectificationReasonRequired
+	}
+	for _, line := range i.Lines {
+		if err := line.ValidateCorrection(); err != nil {
+			return err
+		}
+	}
+	if i.BaseAmount.IsZero() {
+		return ErrEmptyRectification
+	}
+	return i.validateCommon()
+}
+
+func (i *Invoice) validateCommon() error {
+	if i.IRPFRate < 0 || i.IRPFRate > 100 {
+		return ErrInvalidIRPFRate
+	}
 	if i.Description == "" {
 		return ErrInvalidDescription
 	}
@@ -95,4 +344,11 @@ var (
 	ErrInvalidClientID      = errors.NewValidationError("client ID is required", nil)
 	ErrInvalidAmount        = errors.NewValidationError("base amount must be greater than 0", nil)
 	ErrInvalidDescription   = errors.NewValidationError("description is required", nil)
+	ErrInvalidIRPFRate      = errors.NewValidationError("IRPF rate must be between 0 and 100", nil)
+	ErrInvalidInvoiceType   = errors.NewValidationError("invoice type must be ordinary, rectifying or simplified", nil)
+
+	ErrRectifiedInvoiceRequired    = errors.NewValidationError("rectifying invoices must reference the rectified invoice", nil)
+	ErrInvalidRectificationMode    = errors.NewValidationError("rectification mode must be cancellation or difference", nil)
+	ErrRectificationReasonRequired = errors.NewValidationError("rectification reason is required", nil)
+	ErrEmptyRectification          = errors.NewValidationError("rectification does not change the invoice amount", nil)
 )
@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// InvoiceLine represents a concept billed in an invoice
+type InvoiceLine struct {
+	ID              uuid.UUID          `json:"id" db:"id"`
+	InvoiceID       uuid.UUID          `json:"invoiceId" db:"invoice_id"`
+	Position        int                `json:"position" db:"position"` // 1-based order in the invoice
+	Description     string             `json:"description" db:"description"`
+	Quantity        float64            `json:"quantity" db:"quantity"`
+	UnitPrice       money.Money        `json:"unitPrice" db:"unit_price"`                       // Without VAT
+	DiscountPercent float64            `json:"discountPercent" db:"discount_percent"`           // 0-100
+	VATRate         float64            `json:"vatRate" db:"vat_rate"`                           // VAT rate percentage (21/10/4/0)
+	VATExemption    *VATExemptionCause `json:"vatExemption,omitempty" db:"vat_exemption_cause"` // Set for exempt lines (rate 0)
+	BaseAmount      money.Money        `json:"baseAmount" db:"base_amount"`                     // Quantity * UnitPrice - discount
+	VATAmount       money.Money        `json:"vatAmount" db:"vat_amount"`
+	TotalAmount     money.Money        `json:"totalAmount" db:"total_amount"`
+	AppointmentID   *uuid.UUID         `json:"appointmentId,omitempty" db:"appointment_id"`
+	ServiceTypeID   *uuid.UUID         `json:"serviceTypeId,omitempty" db:"service_type_id"`
+	CreatedAt       time.Time          `json:"createdAt" db:"created_at"`
+	UpdatedAt       time.Time          `json:"updatedAt" db:"updated_at"`
+}
+
+// CalculateAmounts calculates the base, VAT and total amounts of the line. The base
+// (quantity * unit price - discount) and the VAT are each rounded once to cents.
+func (l *InvoiceLine) CalculateAmounts() error {
+	l.BaseAmount = l.UnitPrice.MulPercent(l.Quantity, 100-l.DiscountPercent)
+	l.VATAmount = money.New(0, l.UnitPrice.Currency())
+	if !l.IsExempt() {
+		l.VATAmount = l.BaseAmount.Percent(l.VATRate)
+	}
+
+	var err error
+	l.TotalAmount, err = l.BaseAmount.Add(l.VATAmount)
+	return err
+}
+
+// IsExempt returns true if the line is exempt from VAT
+func (l *InvoiceLine) IsExempt() bool {
+	return l.VATExemption != nil
+}
+
+// Negated returns a copy of the line that cancels it, for rectifying invoices
+func (l *InvoiceLine) Negated() *InvoiceLine {
+	negated := *l
+	negated.ID = uuid.New()
+	negated.Quantity = -l.Quantity
+	negated.BaseAmount = l.BaseAmount.Neg()
+	negated.VATAmount = l.VATAmount.Neg()
+	negated.TotalAmount = l.TotalAmount.Neg()
+	return &negated
+}
+
+// Validate performs basic validation on the invoice line
+func (l *InvoiceLine) Validate() error {
+	if l.Description == "" {
+		return ErrInvalidLineDescription
+	}
+	if l.Quantity <= 0 {
+		return ErrInvalidLineQuantity
+	}
+	if l.UnitPrice.IsNegative() {
+		return ErrInvalidLineUnitPrice
+	}
+	return l.validateCommon()
+}
+
+// ValidateCorrection validates a line of a rectifying invoice, which may subtract
+// from the original with a negative quantity or unit price
+func (l *InvoiceLine) ValidateCorrection() error {
+	if l.Description == "" {
+		return ErrInvalidLineDescription
+	}
+	if l.Quantity == 0 {
+		return ErrZeroLineQuantity
+	}
+	return l.validateCommon()
+}
+
+func (l *InvoiceLine) validateCommon() error {
+	if l.DiscountPercent < 0 || l.DiscountPercent > 100 {
+		return ErrInvalidLineDiscount
+	}
+	if !IsValidVATRate(l.VATRate) {
+		return ErrInvalidVATRate
+	}
+	if l.VATExemption != nil {
+		if !l.VATExemption.IsValid() {
+			return ErrInvalidVATExemption
+		}
+		if l.VATRate != VATRateZero {
+			return ErrExemptLineWithVAT
+		}
+	}
+	return nil
+}
+
+// Custom errors
+var (
+	ErrInvalidLineDescription = errors.NewValidationError("line description is required", nil)
+	ErrInvalidLineQuantity    = errors.NewValidationError("line quantity must be greater than 0", nil)
+	ErrInvalidLineUnitPrice   = errors.NewValidationError("line unit price cannot be negative", nil)
+	ErrZeroLineQuantity       = errors.NewValidationError("line quantity cannot be 0", nil)
+	ErrInvalidLineDiscount    = errors.NewValidationError("line discount must be between 0 and 100", nil)
+	ErrInvalidVATRate         = errors.NewValidationError("VAT rate must be 21, 10, 4 or 0", nil)
+	ErrInvalidVATExemption    = errors.NewValidationError("VAT exemption cause must be one of E1-E6", nil)
+	ErrExemptLineWithVAT      = errors.NewValidationError("exempt lines must have a VAT rate of 0", nil)
+	ErrInvoiceWithoutLines    = errors.NewValidationError("invoice must have at least one line", nil)
+)
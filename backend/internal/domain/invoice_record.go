@@ -0,0 +1,205 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"time"
+	_ "time/tzdata" // The record timestamps are fingerprinted in Spanish peninsular time
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// DefaultInvoiceQRBaseURL is the tax agency service where invoice QR codes are verified
+const DefaultInvoiceQRBaseURL = "https://www2.agenciatributaria.gob.es/wlpl/TIKE-CONT/ValidarQR"
+
+// InvoiceRecordType represents the event recorded in the invoice record chain
+type InvoiceRecordType string
+
+const (
+	InvoiceRecordTypeRegistration InvoiceRecordType = "registration" // Registro de alta
+	InvoiceRecordTypeCancellation InvoiceRecordType = "cancellation" // Registro de anulación
+)
+
+// InvoiceRecordSubmissionStatus represents the transmission status of a record to the tax agency
+type InvoiceRecordSubmissionStatus string
+
+const (
+	InvoiceRecordSubmissionPending  InvoiceRecordSubmissionStatus = "pending"
+	InvoiceRecordSubmissionAccepted InvoiceRecordSubmissionStatus = "accepted"
+	InvoiceRecordSubmissionRejected InvoiceRecordSubmissionStatus = "rejected"
+)
+
+// VeriFactu invoice type codes (TipoFactura)
+const (
+	InvoiceTypeCodeOrdinary   = "F1"
+	InvoiceTypeCodeSimplified = "F2"
+	InvoiceTypeCodeRectifying = "R1"
+)
+
+// recordLocation is the time zone of the generation timestamps
+var recordLocation = mustLoadLocation("Europe/Madrid")
+
+// InvoiceRecord is a VeriFactu billing record. Records form an append-only chain:
+// each one fingerprints the invoice data together with the hash of the previous record,
+// so altering or removing any record breaks every hash after it.
+type InvoiceRecord struct {
+	ID              uuid.UUID         `json:"id" db:"id"`
+	Sequence        int64             `json:"sequence" db:"sequence"` // Position in the chain, starting at 1
+	RecordType      InvoiceRecordType `json:"recordType" db:"record_type"`
+	InvoiceID       uuid.UUID         `json:"invoiceId" db:"invoice_id"`
+	IssuerTaxID     string            `json:"issuerTaxId" db:"issuer_tax_id"`         // IDEmisorFactura
+	InvoiceNumber   string            `json:"invoiceNumber" db:"invoice_number"`      // NumSerieFactura
+	IssueDate       time.Time         `json:"issueDate" db:"issue_date"`              // FechaExpedicionFactura
+	InvoiceTypeCode string            `json:"invoiceTypeCode" db:"invoice_type_code"` // TipoFactura (registrations only)
+	VATAmount       money.Money       `json:"vatAmount" db:"vat_amount"`              // CuotaTotal (registrations only)
+	TotalAmount     money.Money       `json:"totalAmount" db:"total_amount"`          // ImporteTotal, base plus VAT (registrations only)
+	PreviousHash    *string           `json:"previousHash,omitempty" db:"previous_hash"`
+	Hash            string            `json:"hash" db:"hash"` // Huella: SHA-256 of the fingerprint, uppercase hex
+	GeneratedAt     time.Time         `json:"generatedAt" db:"generated_at"`
+	Signature       string            `json:"-" db:"signature"` // HMAC-SHA256 of the hash with the clinic's signing key
+
+	SubmissionStatus    InvoiceRecordSubmissionStatus `json:"submissionStatus" db:"submission_status"`
+	SubmissionReference *string                       `json:"submissionReference,omitempty" db:"submission_reference"`
+	SubmissionError     *string                       `json:"submissionError,omitempty" db:"submission_error"`
+	SubmittedAt         *time.Time                    `json:"submittedAt,omitempty" db:"submitted_at"`
+
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// NewInvoiceRecord builds the unchained record of an invoice event; the issuer is the clinic's tax ID.
+// Cancellations only identify the invoice; registrations also carry its type and amounts.
+func NewInvoiceRecord(recordType InvoiceRecordType, invoice *Invoice, issuerTaxID string, generatedAt time.Time) (*InvoiceRecord, error) {
+	record := &InvoiceRecord{
+		ID:               uuid.New(),
+		RecordType:       recordType,
+		InvoiceID:        invoice.ID,
+		IssuerTaxID:      strings.ToUpper(strings.TrimSpace(issuerTaxID)),
+		InvoiceNumber:    invoice.InvoiceNumber,
+		IssueDate:        invoice.IssueDate,
+		GeneratedAt:      generatedAt.Truncate(time.Second),
+		SubmissionStatus: InvoiceRecordSubmissionPending,
+		CreatedAt:        generatedAt,
+	}
+
+	if recordType == InvoiceRecordTypeRegistration {
+		total, err := invoice.BaseAmount.Add(invoice.VATAmount)
+		if err != nil {
+			return nil, err
+		}
+		record.InvoiceTypeCode = InvoiceTypeCode(invoice)
+		record.VATAmount = invoice.VATAmount
+		record.TotalAmount = total
+	}
+
+	return record, nil
+}
+
+// InvoiceTypeCode returns the VeriFactu type code of an invoice
+func InvoiceTypeCode(invoice *Invoice) string {
+	switch invoice.InvoiceType {
+	case InvoiceTypeRectifying:
+		return InvoiceTypeCodeRectifying
+	case InvoiceTypeSimplified:
+		return InvoiceTypeCodeSimplified
+	default:
+		return InvoiceTypeCodeOrdinary
+	}
+}
+
+// Chain links the record after previous (nil for the first record) and computes its hash and signature
+func (r *InvoiceRecord) Chain(previous *InvoiceRecord, signingKey []byte) {
+	r.Sequence = 1
+	r.PreviousHash = nil
+	if previous != nil {
+		r.Sequence = previous.Sequence + 1
+		hash := previous.Hash
+		r.PreviousHash = &hash
+	}
+
+	r.Hash = r.ComputeHash()
+	r.Signature = r.ComputeSignature(signingKey)
+}
+
+// Fingerprint returns the string hashed into the record, with the fields and format
+// defined by the VeriFactu specification for registration and cancellation records
+func (r *InvoiceRecord) Fingerprint() string {
+	previous := ""
+	if r.PreviousHash != nil {
+		previous = *r.PreviousHash
+	}
+	generated := r.GeneratedAt.In(recordLocation).Format(time.RFC3339)
+	issueDate := r.IssueDate.Format("02-01-2006")
+
+	if r.RecordType == InvoiceRecordTypeCancellation {
+		return "IDEmisorFacturaAnulada=" + r.IssuerTaxID +
+			"&NumSerieFacturaAnulada=" + r.InvoiceNumber +
+			"&FechaExpedicionFacturaAnulada=" + issueDate +
+			"&Huella=" + previous +
+			"&FechaHoraHusoGenRegistro=" + generated
+	}
+
+	return "IDEmisorFactura=" + r.IssuerTaxID +
+		"&NumSerieFactura=" + r.InvoiceNumber +
+		"&FechaExpedicionFactura=" + issueDate +
+		"&TipoFactura=" + r.InvoiceTypeCode +
+		"&CuotaTotal=" + r.VATAmount.Decimal() +
+		"&ImporteTotal=" + r.TotalAmount.Decimal() +
+		"&Huella=" + previous +
+		"&FechaHoraHusoGenRegistro=" + generated
+}
+
+// ComputeHash returns the SHA-256 of the fingerprint as uppercase hex
+func (r *InvoiceRecord) ComputeHash() string {
+	sum := sha256.Sum256([]byte(r.Fingerprint()))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// ComputeSignature returns the HMAC-SHA256 of the hash with the signing key, as hex
+func (r *InvoiceRecord) ComputeSignature(signingKey []byte) string {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte(r.Hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HasValidSignature returns true if the record was signed with the key
+func (r *InvoiceRecord) HasValidSignature(signingKey []byte) bool {
+	return hmac.Equal([]byte(r.Signature), []byte(r.ComputeSignature(signingKey)))
+}
+
+// IsPendingSubmission returns true if the record has not been sent to the tax agency yet
+func (r *InvoiceRecord) IsPendingSubmission() bool {
+	return r.SubmissionStatus == InvoiceRecordSubmissionPending
+}
+
+// InvoiceQRURL returns the URL encoded in the QR code printed on an issued invoice,
+// which lets the recipient check the invoice with the tax agency
+func InvoiceQRURL(baseURL, issuerTaxID string, invoice *Invoice) (string, error) {
+	if baseURL == "" {
+		baseURL = DefaultInvoiceQRBaseURL
+	}
+
+	total, err := invoice.BaseAmount.Add(invoice.VATAmount)
+	if err != nil {
+		return "", err
+	}
+
+	// The tax agency expects the parameters in this order
+	query := "nif=" + url.QueryEscape(strings.ToUpper(strings.TrimSpace(issuerTaxID))) +
+		"&numserie=" + url.QueryEscape(invoice.InvoiceNumber) +
+		"&fecha=" + invoice.IssueDate.Format("02-01-2006") +
+		"&importe=" + total.Decimal()
+
+	return baseURL + "?" + query, nil
+}
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// DunningStage is the tone of a payment reminder
+type DunningStage string
+
+const (
+	DunningStageFriendly     DunningStage = "friendly"      // Recordatorio amistoso
+	DunningStageSecondNotice DunningStage = "second_notice" // Segundo aviso
+	DunningStageFinalNotice  DunningStage = "final_notice"  // Último aviso
+)
+
+// DunningSchedule holds the days after the due date at which each reminder level is sent,
+// e.g. [3, 15, 30] for a friendly reminder, a second notice and a final notice
+type DunningSchedule []int
+
+// Validate checks that the schedule has strictly increasing, positive steps
+func (s DunningSchedule) Validate() error {
+	for idx, days := range s {
+		if days <= 0 || (idx > 0 && days <= s[idx-1]) {
+			return errors.NewValidationError("dunning schedule must list increasing days after the due date", map[string][]string{
+				"schedule": {fmt.Sprintf("invalid step %d: %d days", idx+1, days)},
+			})
+		}
+	}
+	return nil
+}
+
+// NextLevel returns the level of the reminder to send for an invoice that already received
+// sent reminders and is daysOverdue days past its due date. Levels are sent in order, one at
+// a time, so a client always gets the friendly reminder before the final notice.
+func (s DunningSchedule) NextLevel(sent, daysOverdue int) (int, bool) {
+	if sent >= len(s) || daysOverdue < s[sent] {
+		return 0, false
+	}
+	return sent + 1, true
+}
+
+// Stage returns the tone of a reminder level: the first one is friendly and the last one final
+func (s DunningSchedule) Stage(level int) DunningStage {
+	switch {
+	case level <= 1:
+		return DunningStageFriendly
+	case level >= len(s):
+		return DunningStageFinalNotice
+	default:
+		return DunningStageSecondNotice
+	}
+}
+
+// InvoiceReminder is a payment reminder sent for an overdue invoice
+type InvoiceReminder struct {
+	ID                uuid.UUID   `json:"id" db:"id"`
+	InvoiceID         uuid.UUID   `json:"invoiceId" db:"invoice_id"`
+	Level             int         `json:"level" db:"level"` // 1 for the first reminder
+	DaysOverdue       int         `json:"daysOverdue" db:"days_overdue"`
+	Recipient         string      `json:"recipient" db:"recipient"`
+	OutstandingAmount money.Money `json:"outstandingAmount" db:"outstanding_amount"`
+	PaymentReference  string      `json:"paymentReference" db:"payment_reference"`
+	SentAt            time.Time   `json:"sentAt" db:"sent_at"`
+}
+
+// IsOverdue returns true if the invoice was found past its due date and is not paid yet
+func (i *Invoice) IsOverdue() bool {
+	return i.OverdueAt != nil && i.IsIssued() && !i.IsPaid()
+}
+
+// DaysOverdue returns the number of days between the due date and the given day (0 when not due yet)
+func (i *Invoice) DaysOverdue(on time.Time) int {
+	days := int(dateOnly(on).Sub(dateOnly(i.DueDate)).Hours() / 24)
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// PaymentReference returns the structured creditor reference (ISO 11649, "RF") that clients
+// quote when paying the invoice by bank transfer, built from the invoice number. Numbers too
+// long for a creditor reference are quoted as they are.
+func (i *Invoice) PaymentReference() string {
+	var ref strings.Builder
+	for _, r := range strings.ToUpper(i.InvoiceNumber) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			ref.WriteRune(r)
+		}
+	}
+	if ref.Len() == 0 || ref.Len() > 21 {
+		return i.InvoiceNumber
+	}
+
+	// Check digits: the reference followed by "RF00", letters as numbers (A=10...), mod 97
+	var digits strings.Builder
+	for _, r := range ref.String() + "RF00" {
+		if r >= 'A' && r <= 'Z' {
+			digits.WriteString(fmt.Sprint(r - 'A' + 10))
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, _ := new(big.Int).SetString(digits.String(), 10)
+	check := 98 - new(big.Int).Mod(n, big.NewInt(97)).Int64()
+
+	return fmt.Sprintf("RF%02d%s", check, ref.String())
+}
+
+// dateOnly returns the calendar day of t at midnight UTC, the way DATE columns are read
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Invoice reminder errors
+var (
+	ErrReminderAlreadySent = errors.NewConflictError("payment reminder has already been sent", errors.CodeConflict)
+)
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// DefaultSeriesPadding is the number of digits of the sequence in an invoice number
+const DefaultSeriesPadding = 4
+
+var seriesPrefixPattern = regexp.MustCompile(`^[A-Z0-9]{1,10}$`)
+
+// InvoiceSeries is a numbering series of invoices with its own prefix and counter
+type InvoiceSeries struct {
+	ID          uuid.UUID   `json:"id" db:"id"`
+	Name        string      `json:"name" db:"name"`
+	Prefix      string      `json:"prefix" db:"prefix"`               // F, R, S, FMAD...
+	InvoiceType InvoiceType `json:"invoiceType" db:"invoice_type"`    // Type of the invoices numbered in the series
+	Location    *string     `json:"location,omitempty" db:"location"` // Clinic location, nil for all of them
+	IsDefault   bool        `json:"isDefault" db:"is_default"`        // Used when no series is chosen
+	YearlyReset bool        `json:"yearlyReset" db:"yearly_reset"`    // Numbering restarts every calendar year
+	Padding     int         `json:"padding" db:"padding"`             // Digits of the sequence, zero padded
+	IsActive    bool        `json:"isActive" db:"is_active"`          // Inactive series cannot issue new invoices
+	CreatedAt   time.Time   `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time   `json:"updatedAt" db:"updated_at"`
+}
+
+// CounterYear returns the counter year of an invoice issued on the given date;
+// series without yearly reset share a single counter stored as year 0
+func (s *InvoiceSeries) CounterYear(issueDate time.Time) int {
+	if !s.YearlyReset {
+		return 0
+	}
+	return issueDate.Year()
+}
+
+// FormatNumber formats the invoice number for a position of the series:
+// PREFIX_YYYY_NNNN, or PREFIX_NNNN for series without yearly reset
+func (s *InvoiceSeries) FormatNumber(year, sequence int) string {
+	padding := s.Padding
+	if padding < 1 {
+		padding = DefaultSeriesPadding
+	}
+	if !s.YearlyReset {
+		return fmt.Sprintf("%s_%0*d", s.Prefix, padding, sequence)
+	}
+	return fmt.Sprintf("%s_%d_%0*d", s.Prefix, year, padding, sequence)
+}
+
+// MatchesLocation returns true if the series can be used at the location
+func (s *InvoiceSeries) MatchesLocation(location *string) bool {
+	return s.Location == nil || (location != nil && strings.EqualFold(*s.Location, *location))
+}
+
+// Validate performs basic validation on the series
+func (s *InvoiceSeries) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return ErrInvalidSeriesName
+	}
+	if !seriesPrefixPattern.MatchString(s.Prefix) || s.Prefix+"_" == DraftInvoiceNumberPrefix {
+		return ErrInvalidSeriesPrefix
+	}
+	if !s.InvoiceType.IsValid() {
+		return ErrInvalidInvoiceType
+	}
+	if s.Padding < 1 || s.Padding > 10 {
+		return ErrInvalidSeriesPadding
+	}
+	if s.IsDefault && !s.IsActive {
+		return ErrInactiveDefaultSeries
+	}
+	return nil
+}
+
+// Custom errors
+var (
+	ErrInvalidSeriesName     = errors.NewValidationError("series name is required", nil)
+	ErrInvalidSeriesPrefix   = errors.NewValidationError("series prefix must be 1 to 10 uppercase letters or digits", nil)
+	ErrInvalidSeriesPadding  = errors.NewValidationError("series padding must be between 1 and 10 digits", nil)
+	ErrInactiveDefaultSeries = errors.NewValidationError("the default series must be active", nil)
+)
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// PaymentMethod represents how a payment was made
+type PaymentMethod string
+
+const (
+	PaymentMethodCash        PaymentMethod = "cash"         // Efectivo
+	PaymentMethodCard        PaymentMethod = "card"         // Tarjeta
+	PaymentMethodTransfer    PaymentMethod = "transfer"     // Transferencia
+	PaymentMethodBizum       PaymentMethod = "bizum"        // Bizum
+	PaymentMethodDirectDebit PaymentMethod = "direct_debit" // Domiciliación bancaria
+	PaymentMethodOther       PaymentMethod = "other"        // Otro
+)
+
+// IsValid returns true if the payment method is supported
+func (m PaymentMethod) IsValid() bool {
+	switch m {
+	case PaymentMethodCash, PaymentMethodCard, PaymentMethodTransfer, PaymentMethodBizum,
+		PaymentMethodDirectDebit, PaymentMethodOther:
+		return true
+	}
+	return false
+}
+
+// Payment represents an amount collected for an invoice. Payments are never deleted:
+// a payment recorded by mistake is reversed and stops counting towards the balance.
+type Payment struct {
+	ID             uuid.UUID     `json:"id" db:"id"`
+	InvoiceID      uuid.UUID     `json:"invoiceId" db:"invoice_id"`
+	Amount         money.Money   `json:"amount" db:"amount"`
+	PaymentDate    time.Time     `json:"paymentDate" db:"payment_date"`
+	Method         PaymentMethod `json:"method" db:"method"`
+	Reference      string        `json:"reference,omitempty" db:"reference"` // Card receipt, transfer reference...
+	Notes          string        `json:"notes,omitempty" db:"notes"`
+	RecordedBy     *uuid.UUID    `json:"recordedBy,omitempty" db:"recorded_by"` // Nullable for payments migrated from the paid status
+	ReversedAt     *time.Time    `json:"reversedAt,omitempty" db:"reversed_at"`
+	ReversedBy     *uuid.UUID    `json:"reversedBy,omitempty" db:"reversed_by"`
+	ReversalReason string        `json:"reversalReason,omitempty" db:"reversal_reason"`
+	CreatedAt      time.Time     `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time     `json:"updatedAt" db:"updated_at"`
+}
+
+// IsReversed returns true if the payment has been reversed
+func (p *Payment) IsReversed() bool {
+	return p.ReversedAt != nil
+}
+
+// Validate performs basic validation on the payment
+func (p *Payment) Validate() error {
+	if p.InvoiceID == uuid.Nil {
+		return ErrPaymentInvoiceRequired
+	}
+	if !p.Amount.IsPositive() {
+		return ErrInvalidPaymentAmount
+	}
+	if !p.Method.IsValid() {
+		return ErrInvalidPaymentMethod
+	}
+	if p.PaymentDate.IsZero() {
+		return ErrPaymentDateRequired
+	}
+	return nil
+}
+
+// OutstandingAmount returns the amount of the invoice that has not been collected yet
+func (i *Invoice) OutstandingAmount() (money.Money, error) {
+	return i.TotalAmount.Sub(i.PaidAmount)
+}
+
+// CheckPayment verifies that the invoice can receive a payment of amount
+func (i *Invoice) CheckPayment(amount money.Money) error {
+	if i.IsDraft() {
+		return errors.NewValidationError("cannot record payments on a draft invoice", map[string][]string{
+			"status": {"invoice must be issued first"},
+		})
+	}
+
+	outstanding, err := i.OutstandingAmount()
+	if err != nil {
+		return err
+	}
+	if !outstanding.IsPositive() {
+		return errors.NewValidationError("invoice has no outstanding balance", map[string][]string{
+			"amount": {fmt.Sprintf("invoice %s is already settled", i.InvoiceNumber)},
+		})
+	}
+
+	exceeds, err := amount.Cmp(outstanding)
+	if err != nil {
+		return err
+	}
+	if exceeds > 0 {
+		return errors.NewValidationError("payment exceeds the outstanding balance", map[string][]string{
+			"amount": {fmt.Sprintf("outstanding balance of invoice %s is %s", i.InvoiceNumber, outstanding.Decimal())},
+		})
+	}
+
+	return nil
+}
+
+// ApplyPaidAmount sets the amount collected from the active payments and derives the status
+func (i *Invoice) ApplyPaidAmount(paid money.Money) error {
+	outstanding, err := i.TotalAmount.Sub(paid)
+	if err != nil {
+		return err
+	}
+
+	i.PaidAmount = paid
+	switch {
+	case i.IsDraft():
+	case !outstanding.IsPositive() && paid.IsPositive():
+		i.Status = InvoiceStatusPaid
+		i.OverdueAt = nil
+	case paid.IsPositive():
+		i.Status = InvoiceStatusPartiallyPaid
+	default:
+		i.Status = InvoiceStatusUnpaid
+	}
+	i.UpdatedAt = time.Now()
+	return nil
+}
+
+// Payment errors
+var (
+	ErrPaymentInvoiceRequired = errors.NewValidationError("invoice ID is required", nil)
+	ErrInvalidPaymentAmount   = errors.NewValidationError("payment amount must be greater than 0", nil)
+	ErrInvalidPaymentMethod   = errors.NewValidationError("payment method must be cash, card, transfer, bizum, direct_debit or other", nil)
+	ErrPaymentDateRequired    = errors.NewValidationError("payment date is required", nil)
+	ErrPaymentAlreadyReversed = errors.NewConflictError("payment has already been reversed", errors.CodeConflict)
+)
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// PaymentLinkStatus is the state of an online checkout
+type PaymentLinkStatus string
+
+const (
+	PaymentLinkOpen    PaymentLinkStatus = "open"    // Waiting for the client to pay
+	PaymentLinkPaid    PaymentLinkStatus = "paid"    // Paid; the payment is recorded on the invoice
+	PaymentLinkExpired PaymentLinkStatus = "expired" // Expired at the provider without being paid
+)
+
+// PaymentLink is a "pay now" checkout created at the payment provider for the outstanding
+// balance of an invoice. The provider reports the result through a signed webhook.
+type PaymentLink struct {
+	ID        uuid.UUID         `json:"id" db:"id"`
+	InvoiceID uuid.UUID         `json:"invoiceId" db:"invoice_id"`
+	Provider  string            `json:"provider" db:"provider"`
+	SessionID string            `json:"sessionId" db:"session_id"` // Checkout session at the provider
+	URL       string            `json:"url" db:"url"`              // Page where the client pays
+	Amount    money.Money       `json:"amount" db:"amount"`
+	Status    PaymentLinkStatus `json:"status" db:"status"`
+	ExpiresAt time.Time         `json:"expiresAt" db:"expires_at"`
+	PaymentID *uuid.UUID        `json:"paymentId,omitempty" db:"payment_id"` // Nil when paid after the invoice was settled otherwise
+	PaidAt    *time.Time        `json:"paidAt,omitempty" db:"paid_at"`
+	CreatedBy *uuid.UUID        `json:"createdBy,omitempty" db:"created_by"`
+	CreatedAt time.Time         `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time         `json:"updatedAt" db:"updated_at"`
+}
+
+// IsUsable returns true if the client can still pay with the link
+func (l *PaymentLink) IsUsable(now time.Time) bool {
+	return l.Status == PaymentLinkOpen && now.Before(l.ExpiresAt)
+}
+
+// Payment link errors
+var (
+	ErrOnlinePaymentsNotConfigured = errors.NewValidationError("online payments are not configured", map[string][]string{
+		"provider": {"set the payment provider and its keys"},
+	})
+	ErrInvalidWebhookSignature = errors.NewUnauthorizedError("invalid webhook signature")
+	ErrWebhookEventProcessed   = errors.NewConflictError("webhook event has already been processed", errors.CodeConflict)
+	ErrPaymentLinkNotOpen      = errors.NewConflictError("payment link is no longer open", errors.CodeConflict)
+)
@@ -0,0 +1,193 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// RecurringExpense is the template of an expense repeated every month (rent, utilities,
+// subscriptions). An expense is generated on its day of each month between its start and
+// end dates; single months can be skipped or adjusted with an occurrence.
+type RecurringExpense struct {
+	ID             uuid.UUID   `json:"id" db:"id"`
+	Supplier       string      `json:"supplier" db:"supplier"`
+	Amount         money.Money `json:"amount" db:"amount"`
+	VATAmount      money.Money `json:"vatAmount" db:"vat_amount"` // VAT included in the amount
+	VATDeductible  bool        `json:"vatDeductible" db:"vat_deductible"`
+	IRPFDeductible bool        `json:"irpfDeductible" db:"irpf_deductible"`
+	CategoryID     uuid.UUID   `json:"categoryId" db:"category_id"`
+	SubcategoryID  *uuid.UUID  `json:"subcategoryId,omitempty" db:"subcategory_id"`
+	DayOfMonth     int         `json:"dayOfMonth" db:"day_of_month"` // The last day of shorter months
+	StartDate      time.Time   `json:"startDate" db:"start_date"`
+	EndDate        *time.Time  `json:"endDate,omitempty" db:"end_date"` // Open-ended when nil
+	Description    *string     `json:"description,omitempty" db:"description"`
+	PaymentMethod  *string     `json:"paymentMethod,omitempty" db:"payment_method"`
+	Notes          *string     `json:"notes,omitempty" db:"notes"`
+	IsActive       bool        `json:"isActive" db:"is_active"` // Paused templates generate nothing
+	CreatedAt      time.Time   `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time   `json:"updatedAt" db:"updated_at"`
+	DeletedAt      *time.Time  `json:"-" db:"deleted_at"`
+}
+
+// Validate performs basic validation on the recurring expense
+func (r *RecurringExpense) Validate() error {
+	if strings.TrimSpace(r.Supplier) == "" {
+		return ErrInvalidSupplier
+	}
+	if !r.Amount.IsPositive() {
+		return ErrInvalidExpenseAmount
+	}
+	if r.VATAmount.IsNegative() {
+		return ErrInvalidExpenseVAT
+	}
+	if cmp, err := r.VATAmount.Cmp(r.Amount); err != nil || cmp >= 0 {
+		return ErrInvalidExpenseVAT
+	}
+	if r.CategoryID == uuid.Nil {
+		return ErrInvalidCategory
+	}
+	if r.DayOfMonth < 1 || r.DayOfMonth > 31 {
+		return ErrInvalidRecurringDay
+	}
+	if r.StartDate.IsZero() || (r.EndDate != nil && r.EndDate.Before(r.StartDate)) {
+		return ErrInvalidRecurringDates
+	}
+	return nil
+}
+
+// DueDate returns the day the expense is due in the month of period
+func (r *RecurringExpense) DueDate(period time.Time) time.Time {
+	first := PeriodOf(period)
+	lastDay := first.AddDate(0, 1, -1).Day()
+
+	day := r.DayOfMonth
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// OccursIn returns true if the expense is due in the month of period, within its start
+// and end dates
+func (r *RecurringExpense) OccursIn(period time.Time) bool {
+	due := r.DueDate(period)
+	if due.Before(dateOf(r.StartDate)) {
+		return false
+	}
+	return r.EndDate == nil || !due.After(dateOf(*r.EndDate))
+}
+
+// Materialise builds the expense of a month, applying the adjustments of its occurrence
+// if any. The expense is flagged for review.
+func (r *RecurringExpense) Materialise(period time.Time, occurrence *RecurringExpenseOccurrence, now time.Time) *Expense {
+	expense := &Expense{
+		ID:                 uuid.New(),
+		ExpenseDate:        r.DueDate(period),
+		Supplier:           r.Supplier,
+		Amount:             r.Amount,
+		VATAmount:          r.VATAmount,
+		VATDeductible:      r.VATDeductible,
+		IRPFDeductible:     r.IRPFDeductible,
+		CategoryID:         r.CategoryID,
+		SubcategoryID:      r.SubcategoryID,
+		Description:        r.Description,
+		PaymentMethod:      r.PaymentMethod,
+		Notes:              r.Notes,
+		RecurringExpenseID: &r.ID,
+		NeedsReview:        true,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+
+	if occurrence != nil {
+		if occurrence.ExpenseDate != nil {
+			expense.ExpenseDate = dateOf(*occurrence.ExpenseDate)
+		}
+		if occurrence.Amount != nil {
+			expense.Amount = *occurrence.Amount
+		}
+		if occurrence.VATAmount != nil {
+			expense.VATAmount = *occurrence.VATAmount
+		}
+		if occurrence.Notes != nil {
+			expense.Notes = occurrence.Notes
+		}
+	}
+
+	return expense
+}
+
+// OccurrenceStatus represents what happened to a month of a recurring expense
+type OccurrenceStatus string
+
+const (
+	OccurrenceStatusDue       OccurrenceStatus = "due"       // Follows the template (never stored)
+	OccurrenceStatusAdjusted  OccurrenceStatus = "adjusted"  // Generated with other values
+	OccurrenceStatusSkipped   OccurrenceStatus = "skipped"   // Not generated
+	OccurrenceStatusGenerated OccurrenceStatus = "generated" // The expense exists
+)
+
+// RecurringExpenseOccurrence records a month of a recurring expense that was adjusted,
+// skipped or generated. Adjusted values replace those of the template for that month only.
+type RecurringExpenseOccurrence struct {
+	ID                 uuid.UUID        `json:"id" db:"id"`
+	RecurringExpenseID uuid.UUID        `json:"recurringExpenseId" db:"recurring_expense_id"`
+	Period             time.Time        `json:"period" db:"period"` // First day of the month
+	Status             OccurrenceStatus `json:"status" db:"status"`
+	ExpenseDate        *time.Time       `json:"expenseDate,omitempty" db:"expense_date"`
+	Amount             *money.Money     `json:"amount,omitempty" db:"amount"`
+	VATAmount          *money.Money     `json:"vatAmount,omitempty" db:"vat_amount"`
+	Notes              *string          `json:"notes,omitempty" db:"notes"`
+	ExpenseID          *uuid.UUID       `json:"expenseId,omitempty" db:"expense_id"` // Set once generated
+	CreatedAt          time.Time        `json:"createdAt" db:"created_at"`
+	UpdatedAt          time.Time        `json:"updatedAt" db:"updated_at"`
+}
+
+// Validate checks the adjustments fit the month and the template
+func (o *RecurringExpenseOccurrence) Validate(template *RecurringExpense) error {
+	if o.ExpenseDate != nil && !PeriodOf(*o.ExpenseDate).Equal(PeriodOf(o.Period)) {
+		return ErrInvalidOccurrenceDate
+	}
+	if o.Amount != nil && !o.Amount.IsPositive() {
+		return ErrInvalidExpenseAmount
+	}
+
+	amount, vat := template.Amount, template.VATAmount
+	if o.Amount != nil {
+		amount = *o.Amount
+	}
+	if o.VATAmount != nil {
+		vat = *o.VATAmount
+	}
+	if vat.IsNegative() {
+		return ErrInvalidExpenseVAT
+	}
+	if cmp, err := vat.Cmp(amount); err != nil || cmp >= 0 {
+		return ErrInvalidExpenseVAT
+	}
+	return nil
+}
+
+// IsGenerated returns true if the expense of the month exists
+func (o *RecurringExpenseOccurrence) IsGenerated() bool {
+	return o.Status == OccurrenceStatusGenerated
+}
+
+// PeriodOf returns the first day of the month of t, identifying a month of a recurring
+// expense
+func PeriodOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Recurring expense errors
+var (
+	ErrInvalidRecurringDay   = errors.NewValidationError("day of month must be between 1 and 31", nil)
+	ErrInvalidRecurringDates = errors.NewValidationError("recurring expense must end after it starts", nil)
+	ErrInvalidOccurrenceDate = errors.NewValidationError("adjusted date must be within the month of the occurrence", nil)
+	ErrNoOccurrenceInPeriod  = errors.NewValidationError("recurring expense is not due in this month", nil)
+	ErrOccurrenceGenerated   = errors.NewConflictError("the expense of this month was already generated; edit or delete it instead", errors.CodeConflict)
+)
@@ -3,6 +3,7 @@ package domain
 import (
 	"time"
 
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -48,12 +49,12 @@ type SearchAppointment struct {
 
 // SearchInvoice represents an invoice in search results
 type SearchInvoice struct {
-	ID            uuid.UUID `json:"id"`
-	InvoiceNumber string    `json:"invoiceNumber"`
-	ClientName    string    `json:"clientName"`
-	TotalAmount   float64   `json:"totalAmount"`
-	Status        string    `json:"status"`
-	IssueDate     time.Time `json:"issueDate"`
+	ID            uuid.UUID   `json:"id"`
+	InvoiceNumber string      `json:"invoiceNumber"`
+	ClientName    string      `json:"clientName"`
+	TotalAmount   money.Money `json:"totalAmount"`
+	Status        string      `json:"status"`
+	IssueDate     time.Time   `json:"issueDate"`
 }
 
 // SearchService defines the interface for search operations
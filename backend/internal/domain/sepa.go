@@ -0,0 +1,213 @@
+package domain
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// SEPASequenceType tells the bank whether a direct debit is the first one of its mandate
+type SEPASequenceType string
+
+const (
+	SEPASequenceFirst     SEPASequenceType = "FRST" // First collection of a recurrent mandate
+	SEPASequenceRecurrent SEPASequenceType = "RCUR" // Following collections
+)
+
+// IsValid returns true if the sequence type is supported
+func (t SEPASequenceType) IsValid() bool {
+	return t == SEPASequenceFirst || t == SEPASequenceRecurrent
+}
+
+// sepaIdentifierPattern is the character set allowed in mandate references and SEPA identifiers
+var sepaIdentifierPattern = regexp.MustCompile(`^[A-Za-z0-9+?/:().,' -]{1,35}$`)
+
+// SEPAMandate is the authorisation signed by a client to collect their invoices by direct
+// debit (SEPA Core) from their account. A client has at most one active mandate.
+type SEPAMandate struct {
+	ID              uuid.UUID        `json:"id" db:"id"`
+	ClientID        uuid.UUID        `json:"clientId" db:"client_id"`
+	Reference       string           `json:"reference" db:"reference"`    // Mandate ID (MndtId), unique
+	DebtorName      string           `json:"debtorName" db:"debtor_name"` // Account holder
+	IBAN            string           `json:"iban" db:"iban"`              // Normalized, without spaces
+	BIC             string           `json:"bic,omitempty" db:"bic"`      // Optional for SEPA payments
+	SignedAt        time.Time        `json:"signedAt" db:"signed_at"`     // Date of signature
+	Sequence        SEPASequenceType `json:"sequence" db:"sequence"`      // Sequence type of the next collection
+	LastCollectedAt *time.Time       `json:"lastCollectedAt,omitempty" db:"last_collected_at"`
+	RevokedAt       *time.Time       `json:"revokedAt,omitempty" db:"revoked_at"`
+	CreatedAt       time.Time        `json:"createdAt" db:"created_at"`
+	UpdatedAt       time.Time        `json:"updatedAt" db:"updated_at"`
+}
+
+// IsActive returns true if the mandate can still be used to collect invoices
+func (m *SEPAMandate) IsActive() bool {
+	return m.RevokedAt == nil
+}
+
+// Validate performs basic validation on the mandate
+func (m *SEPAMandate) Validate() error {
+	if m.ClientID == uuid.Nil {
+		return ErrInvalidClientID
+	}
+	if !sepaIdentifierPattern.MatchString(m.Reference) {
+		return ErrInvalidMandateReference
+	}
+	if strings.TrimSpace(m.DebtorName) == "" || len(m.DebtorName) > 70 {
+		return ErrInvalidMandateDebtor
+	}
+	if !IsValidIBAN(m.IBAN) {
+		return ErrInvalidIBAN
+	}
+	if m.BIC != "" && !bicPattern.MatchString(m.BIC) {
+		return ErrInvalidBIC
+	}
+	if m.SignedAt.IsZero() {
+		return ErrMandateSignatureRequired
+	}
+	if !m.Sequence.IsValid() {
+		return ErrInvalidSequenceType
+	}
+	return nil
+}
+
+// SEPADebitStatus is the state of a direct debit sent to the bank
+type SEPADebitStatus string
+
+const (
+	SEPADebitPresented SEPADebitStatus = "presented" // Sent in a batch; the invoice counts as paid
+	SEPADebitReturned  SEPADebitStatus = "returned"  // Returned by the debtor's bank; the invoice is reopened
+)
+
+// SEPADebit is the collection of the outstanding balance of an invoice in a batch. The payment
+// recorded when the batch is generated is reversed if the debit is returned.
+type SEPADebit struct {
+	ID           uuid.UUID        `json:"id" db:"id"`
+	BatchID      uuid.UUID        `json:"batchId" db:"batch_id"`
+	InvoiceID    uuid.UUID        `json:"invoiceId" db:"invoice_id"`
+	MandateID    uuid.UUID        `json:"mandateId" db:"mandate_id"`
+	PaymentID    uuid.UUID        `json:"paymentId" db:"payment_id"`
+	EndToEndID   string           `json:"endToEndId" db:"end_to_end_id"` // Quoted by the bank when the debit is returned
+	Amount       money.Money      `json:"amount" db:"amount"`
+	Sequence     SEPASequenceType `json:"sequence" db:"sequence"`
+	Status       SEPADebitStatus  `json:"status" db:"status"`
+	ReturnReason *string          `json:"returnReason,omitempty" db:"return_reason"` // ISO reason code, e.g. AM04 or MD06
+	ReturnedAt   *time.Time       `json:"returnedAt,omitempty" db:"returned_at"`
+	CreatedAt    time.Time        `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time        `json:"updatedAt" db:"updated_at"`
+
+	// Set when the batch is generated, not stored with the debit
+	Invoice *Invoice     `json:"-" db:"-"`
+	Mandate *SEPAMandate `json:"-" db:"-"`
+	Payment *Payment     `json:"-" db:"-"`
+}
+
+// IsReturned returns true if the debit has been returned by the bank
+func (d *SEPADebit) IsReturned() bool {
+	return d.Status == SEPADebitReturned
+}
+
+// SEPABatch is a pain.008 file with the direct debits to collect on a date (remesa)
+type SEPABatch struct {
+	ID               uuid.UUID   `json:"id" db:"id"`
+	MessageID        string      `json:"messageId" db:"message_id"`
+	CollectionDate   time.Time   `json:"collectionDate" db:"collection_date"`
+	TransactionCount int         `json:"transactionCount" db:"transaction_count"`
+	TotalAmount      money.Money `json:"totalAmount" db:"total_amount"`
+	Content          []byte      `json:"-" db:"content"` // The pain.008.001.02 XML sent to the bank
+	CreatedBy        *uuid.UUID  `json:"createdBy,omitempty" db:"created_by"`
+	CreatedAt        time.Time   `json:"createdAt" db:"created_at"`
+
+	Debits []*SEPADebit `json:"debits,omitempty" db:"-"`
+}
+
+var bicPattern = regexp.MustCompile(`^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+
+// ibanLengths are the IBAN lengths of the SEPA countries most clients bank in
+var ibanLengths = map[string]int{
+	"AD": 24, "AT": 20, "BE": 16, "CH": 21, "DE": 22, "DK": 18, "ES": 24, "FI": 18, "FR": 27,
+	"GB": 22, "GI": 23, "IE": 22, "IT": 27, "LU": 20, "MC": 27, "NL": 18, "NO": 15, "PL": 28,
+	"PT": 25, "SE": 24,
+}
+
+// NormalizeIBAN removes spaces and upper-cases an IBAN as it is usually written
+func NormalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(iban), ""))
+}
+
+// IsValidIBAN returns true if a normalized IBAN has a valid structure, the length of its
+// country and correct check digits (ISO 13616, mod 97)
+func IsValidIBAN(iban string) bool {
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	for i, r := range iban {
+		switch {
+		case i < 2 && (r < 'A' || r > 'Z'):
+			return false
+		case i >= 2 && i < 4 && (r < '0' || r > '9'):
+			return false
+		case (r < 'A' || r > 'Z') && (r < '0' || r > '9'):
+			return false
+		}
+	}
+	if length, ok := ibanLengths[iban[:2]]; ok && len(iban) != length {
+		return false
+	}
+
+	return mod97(iban[4:]+iban[:4]) == 1
+}
+
+// IsValidSEPACreditorID returns true if a creditor identifier has valid check digits. It is
+// the country code, two check digits, a business code chosen by the creditor and the
+// national ID (the NIF in Spain); the business code is left out of the check digits.
+func IsValidSEPACreditorID(id string) bool {
+	if len(id) < 8 || len(id) > 35 || !sepaIdentifierPattern.MatchString(id) {
+		return false
+	}
+	country, check, national := id[:2], id[2:4], id[7:]
+	return mod97(strings.ToUpper(national)+country+check) == 1
+}
+
+// mod97 returns the remainder by 97 of an alphanumeric string with letters as numbers
+// (A=10...Z=35), or -1 when it has other characters
+func mod97(s string) int64 {
+	var digits strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			digits.WriteString(strconv.Itoa(int(r-'A') + 10))
+		default:
+			return -1
+		}
+	}
+
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	if !ok {
+		return -1
+	}
+	return new(big.Int).Mod(n, big.NewInt(97)).Int64()
+}
+
+// SEPA errors
+var (
+	ErrInvalidIBAN              = errors.NewValidationError("IBAN is not valid", map[string][]string{"iban": {"check the account number and its check digits"}})
+	ErrInvalidBIC               = errors.NewValidationError("BIC must have 8 or 11 characters", nil)
+	ErrInvalidMandateReference  = errors.NewValidationError("mandate reference must have 1 to 35 letters, digits or basic punctuation", nil)
+	ErrInvalidMandateDebtor     = errors.NewValidationError("account holder is required (at most 70 characters)", nil)
+	ErrMandateSignatureRequired = errors.NewValidationError("mandate signature date is required", nil)
+	ErrInvalidSequenceType      = errors.NewValidationError("sequence type must be FRST or RCUR", nil)
+	ErrInvalidSEPACreditorID    = errors.NewValidationError("SEPA creditor identifier is not valid", nil)
+	ErrMandateRevoked           = errors.NewValidationError("mandate has been revoked", nil)
+	ErrActiveMandateExists      = errors.NewConflictError("client already has an active mandate; revoke it first", errors.CodeConflict)
+	ErrMandateReferenceExists   = errors.NewConflictError("mandate reference already exists", errors.CodeConflict)
+	ErrDebitAlreadyReturned     = errors.NewConflictError("direct debit has already been returned", errors.CodeConflict)
+	ErrDirectDebitNotConfigured = errors.NewValidationError("billing settings need the legal name, a valid IBAN and the SEPA creditor identifier to collect direct debits", nil)
+)
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// ServiceType represents a billable service of the catalogue (session, report, assessment...)
+type ServiceType struct {
+	ID           uuid.UUID          `json:"id" db:"id"`
+	Name         string             `json:"name" db:"name"`
+	Description  *string            `json:"description,omitempty" db:"description"`
+	DefaultPrice money.Money        `json:"defaultPrice" db:"default_price"`                 // Unit price without VAT
+	VATRate      float64            `json:"vatRate" db:"vat_rate"`                           // VAT rate percentage applied by default
+	VATExemption *VATExemptionCause `json:"vatExemption,omitempty" db:"vat_exemption_cause"` // E.g. E1 for exempt psychology services
+	IsActive     bool               `json:"isActive" db:"is_active"`
+	CreatedAt    time.Time          `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time          `json:"updatedAt" db:"updated_at"`
+	DeletedAt    *time.Time         `json:"-" db:"deleted_at"`
+}
+
+// Validate performs basic validation on the service type
+func (s *ServiceType) Validate() error {
+	if s.Name == "" {
+		return ErrInvalidServiceTypeName
+	}
+	if s.DefaultPrice.IsNegative() {
+		return ErrInvalidServiceTypePrice
+	}
+	if !IsValidVATRate(s.VATRate) {
+		return ErrInvalidVATRate
+	}
+	if s.VATExemption != nil {
+		if !s.VATExemption.IsValid() {
+			return ErrInvalidVATExemption
+		}
+		if s.VATRate != VATRateZero {
+			return ErrExemptLineWithVAT
+		}
+	}
+	return nil
+}
+
+// Custom errors
+var (
+	ErrInvalidServiceTypeName  = errors.NewValidationError("service type name is required", nil)
+	ErrInvalidServiceTypePrice = errors.NewValidationError("default price cannot be negative", nil)
+)
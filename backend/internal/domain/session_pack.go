@@ -0,0 +1,127 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// SessionPack represents a pack of prepaid sessions of the catalogue (bono)
+type SessionPack struct {
+	ID            uuid.UUID          `json:"id" db:"id"`
+	Name          string             `json:"name" db:"name"`
+	Description   *string            `json:"description,omitempty" db:"description"`
+	ServiceTypeID *uuid.UUID         `json:"serviceTypeId,omitempty" db:"service_type_id"` // Sessions it covers; any when nil
+	Sessions      int                `json:"sessions" db:"sessions"`
+	Price         money.Money        `json:"price" db:"price"`                                // Price of the whole pack without VAT
+	VATRate       float64            `json:"vatRate" db:"vat_rate"`                           // VAT rate percentage of the sale
+	VATExemption  *VATExemptionCause `json:"vatExemption,omitempty" db:"vat_exemption_cause"` // E.g. E1 for exempt psychology services
+	ValidityDays  int                `json:"validityDays" db:"validity_days"`                 // Days the sessions can be used from the sale
+	IsActive      bool               `json:"isActive" db:"is_active"`
+	CreatedAt     time.Time          `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time          `json:"updatedAt" db:"updated_at"`
+	DeletedAt     *time.Time         `json:"-" db:"deleted_at"`
+}
+
+// Validate performs basic validation on the session pack
+func (p *SessionPack) Validate() error {
+	if p.Name == "" {
+		return ErrInvalidSessionPackName
+	}
+	if p.Sessions <= 0 {
+		return ErrInvalidSessionPackSessions
+	}
+	if p.Price.IsNegative() {
+		return ErrInvalidSessionPackPrice
+	}
+	if p.ValidityDays <= 0 {
+		return ErrInvalidSessionPackValidity
+	}
+	if !IsValidVATRate(p.VATRate) {
+		return ErrInvalidVATRate
+	}
+	if p.VATExemption != nil {
+		if !p.VATExemption.IsValid() {
+			return ErrInvalidVATExemption
+		}
+		if p.VATRate != VATRateZero {
+			return ErrExemptLineWithVAT
+		}
+	}
+	return nil
+}
+
+// ClientSessionPack represents a session pack sold to a client. The name, sessions and
+// coverage are copied from the pack, so later changes to the catalogue do not alter it.
+type ClientSessionPack struct {
+	ID                uuid.UUID  `json:"id" db:"id"`
+	ClientID          uuid.UUID  `json:"clientId" db:"client_id"`
+	SessionPackID     uuid.UUID  `json:"sessionPackId" db:"session_pack_id"`
+	InvoiceID         *uuid.UUID `json:"invoiceId,omitempty" db:"invoice_id"` // Invoice of the sale
+	Name              string     `json:"name" db:"name"`
+	ServiceTypeID     *uuid.UUID `json:"serviceTypeId,omitempty" db:"service_type_id"`
+	SessionsTotal     int        `json:"sessionsTotal" db:"sessions_total"`
+	SessionsRemaining int        `json:"sessionsRemaining" db:"sessions_remaining"`
+	PurchasedAt       time.Time  `json:"purchasedAt" db:"purchased_at"`
+	ExpiresAt         time.Time  `json:"expiresAt" db:"expires_at"` // Last day the sessions can be used
+	CreatedAt         time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// IsExpired returns true if the sessions can no longer be used on the day of at
+func (p *ClientSessionPack) IsExpired(at time.Time) bool {
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	expires := time.Date(p.ExpiresAt.Year(), p.ExpiresAt.Month(), p.ExpiresAt.Day(), 0, 0, 0, 0, time.UTC)
+	return day.After(expires)
+}
+
+// IsUsable returns true if the pack has sessions left that can be used on the day of at
+func (p *ClientSessionPack) IsUsable(at time.Time) bool {
+	return p.SessionsRemaining > 0 && !p.IsExpired(at)
+}
+
+// SessionPackUsage records a completed appointment paid with a session of a pack
+type SessionPackUsage struct {
+	ID                  uuid.UUID `json:"id" db:"id"`
+	ClientSessionPackID uuid.UUID `json:"clientSessionPackId" db:"client_session_pack_id"`
+	AppointmentID       uuid.UUID `json:"appointmentId" db:"appointment_id"`
+	CreatedAt           time.Time `json:"createdAt" db:"created_at"`
+}
+
+// SessionPackBalance summarises the prepaid sessions of a client
+type SessionPackBalance struct {
+	ClientID          uuid.UUID            `json:"clientId"`
+	SessionsRemaining int                  `json:"sessionsRemaining"`    // Sessions left in packs not expired
+	NextExpiry        *time.Time           `json:"nextExpiry,omitempty"` // Earliest expiry of a pack with sessions left
+	Packs             []*ClientSessionPack `json:"packs"`                // Every pack bought, newest first
+}
+
+// NewSessionPackBalance summarises the packs of a client as of the day of at
+func NewSessionPackBalance(clientID uuid.UUID, packs []*ClientSessionPack, at time.Time) *SessionPackBalance {
+	balance := &SessionPackBalance{ClientID: clientID, Packs: packs}
+	for _, pack := range packs {
+		if !pack.IsUsable(at) {
+			continue
+		}
+		balance.SessionsRemaining += pack.SessionsRemaining
+		if balance.NextExpiry == nil || pack.ExpiresAt.Before(*balance.NextExpiry) {
+			expiresAt := pack.ExpiresAt
+			balance.NextExpiry = &expiresAt
+		}
+	}
+	return balance
+}
+
+// Session pack errors
+var (
+	ErrInvalidSessionPackName     = errors.NewValidationError("session pack name is required", nil)
+	ErrInvalidSessionPackSessions = errors.NewValidationError("session pack must include at least one session", nil)
+	ErrInvalidSessionPackPrice    = errors.NewValidationError("session pack price cannot be negative", nil)
+	ErrInvalidSessionPackValidity = errors.NewValidationError("session pack validity must be at least one day", nil)
+	ErrSessionPackInactive        = errors.NewValidationError("session pack is no longer sold", nil)
+	ErrNoUsableSessionPack        = errors.NewNotFoundError("client has no session pack with sessions left")
+	ErrSessionAlreadyConsumed     = errors.NewConflictError("appointment has already consumed a session", errors.CodeConflict)
+	ErrSessionAlreadyInvoiced     = errors.NewConflictError("appointment has already been invoiced", errors.CodeConflict)
+)
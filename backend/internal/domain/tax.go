@@ -0,0 +1,127 @@
+package domain
+
+import (
+	"sort"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+)
+
+// Spanish VAT rates (percentages) accepted on invoice lines
+const (
+	VATRateGeneral      float64 = 21 // Tipo general
+	VATRateReduced      float64 = 10 // Tipo reducido
+	VATRateSuperReduced float64 = 4  // Tipo superreducido
+	VATRateZero         float64 = 0  // Tipo cero or exempt operations
+
+	// DefaultVATRate is applied to lines without an explicit rate or service type
+	DefaultVATRate = VATRateGeneral
+
+	// DefaultIRPFRate is the standard withholding for professional services
+	DefaultIRPFRate float64 = 15
+)
+
+// IsValidVATRate returns true if rate is one of the Spanish VAT rates
+func IsValidVATRate(rate float64) bool {
+	switch rate {
+	case VATRateGeneral, VATRateReduced, VATRateSuperReduced, VATRateZero:
+		return true
+	}
+	return false
+}
+
+// VATExemptionCause identifies why an operation is exempt from VAT (AEAT codes E1-E6)
+type VATExemptionCause string
+
+const (
+	VATExemptArticle20 VATExemptionCause = "E1" // Art. 20 LIVA (includes health and psychology services)
+	VATExemptArticle21 VATExemptionCause = "E2" // Art. 21 LIVA (exports)
+	VATExemptArticle22 VATExemptionCause = "E3" // Art. 22 LIVA (operations assimilated to exports)
+	VATExemptArticle23 VATExemptionCause = "E4" // Arts. 23 and 24 LIVA (customs regimes)
+	VATExemptArticle25 VATExemptionCause = "E5" // Art. 25 LIVA (intra-community supplies)
+	VATExemptOther     VATExemptionCause = "E6" // Other exemptions
+)
+
+// IsValid returns true if the cause is a known exemption code
+func (c VATExemptionCause) IsValid() bool {
+	switch c {
+	case VATExemptArticle20, VATExemptArticle21, VATExemptArticle22, VATExemptArticle23, VATExemptArticle25, VATExemptOther:
+		return true
+	}
+	return false
+}
+
+// LegalMention returns the text printed on invoices for the exemption
+func (c VATExemptionCause) LegalMention() string {
+	switch c {
+	case VATExemptArticle20:
+		return "Operación exenta de IVA en virtud del artículo 20.Uno.3º de la Ley 37/1992 del IVA"
+	case VATExemptArticle21:
+		return "Exportación exenta de IVA en virtud del artículo 21 de la Ley 37/1992 del IVA"
+	case VATExemptArticle22:
+		return "Operación exenta de IVA en virtud del artículo 22 de la Ley 37/1992 del IVA"
+	case VATExemptArticle23:
+		return "Operación exenta de IVA en virtud de los artículos 23 y 24 de la Ley 37/1992 del IVA"
+	case VATExemptArticle25:
+		return "Entrega intracomunitaria exenta de IVA en virtud del artículo 25 de la Ley 37/1992 del IVA"
+	default:
+		return "Operación exenta de IVA"
+	}
+}
+
+// TaxBreakdownItem groups the taxable base and VAT of the lines sharing a rate
+type TaxBreakdownItem struct {
+	VATRate      float64            `json:"vatRate"`
+	VATExemption *VATExemptionCause `json:"vatExemption,omitempty"`
+	BaseAmount   money.Money        `json:"baseAmount"`
+	VATAmount    money.Money        `json:"vatAmount"`
+}
+
+// IsExempt returns true if the group is exempt from VAT
+func (t TaxBreakdownItem) IsExempt() bool {
+	return t.VATExemption != nil
+}
+
+// buildTaxBreakdown groups lines by rate and exemption; the VAT of each group is
+// computed on its summed base and rounded once, as the invoice tax quota requires
+func buildTaxBreakdown(lines []*InvoiceLine) ([]TaxBreakdownItem, error) {
+	type key struct {
+		rate      float64
+		exemption VATExemptionCause
+	}
+
+	groups := map[key]*TaxBreakdownItem{}
+	for _, line := range lines {
+		k := key{rate: line.VATRate}
+		if line.VATExemption != nil {
+			k.exemption = *line.VATExemption
+		}
+
+		group, ok := groups[k]
+		if !ok {
+			group = &TaxBreakdownItem{VATRate: line.VATRate, VATExemption: line.VATExemption}
+			groups[k] = group
+		}
+
+		var err error
+		if group.BaseAmount, err = group.BaseAmount.Add(line.BaseAmount); err != nil {
+			return nil, err
+		}
+	}
+
+	breakdown := make([]TaxBreakdownItem, 0, len(groups))
+	for _, group := range groups {
+		if !group.IsExempt() {
+			group.VATAmount = group.BaseAmount.Percent(group.VATRate)
+		}
+		breakdown = append(breakdown, *group)
+	}
+
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].VATRate != breakdown[j].VATRate {
+			return breakdown[i].VATRate > breakdown[j].VATRate
+		}
+		return !breakdown[i].IsExempt() && breakdown[j].IsExempt()
+	})
+
+	return breakdown, nil
+}
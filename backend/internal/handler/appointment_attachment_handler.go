@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AppointmentAttachmentHandler handles appointment attachment HTTP requests
+type AppointmentAttachmentHandler struct {
+	attachmentService service.AppointmentAttachmentService
+	maxUploadBytes    int64
+}
+
+// NewAppointmentAttachmentHandler creates a new appointment attachment handler
+func NewAppointmentAttachmentHandler(attachmentService service.AppointmentAttachmentService, maxUploadBytes int64) *AppointmentAttachmentHandler {
+	if maxUploadBytes <= 0 {
+		maxUploadBytes = service.DefaultMaxAttachmentBytes
+	}
+
+	return &AppointmentAttachmentHandler{
+		attachmentService: attachmentService,
+		maxUploadBytes:    maxUploadBytes,
+	}
+}
+
+// UploadAttachment godoc
+// @Summary Upload an appointment attachment
+// @Description Attach a file (PDF, JPEG, PNG, plain text or DOCX) to an appointment. Files uploaded by clients are always shared.
+// @Tags attachments
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Appointment ID (UUID)"
+// @Param file formData file true "File to upload"
+// @Param visibility formData string false "Visibility (staff_only/client)" default(staff_only)
+// @Success 201 {object} domain.AppointmentAttachment
+// @Failure 400 {object} ErrorResponse "Invalid file, type or size"
+// @Failure 403 {object} ErrorResponse "No access to the appointment"
+// @Failure 404 {object} ErrorResponse "Appointment not found"
+// @Failure 413 {object} ErrorResponse "File too large"
+// @Router /appointments/{id}/attachments [post]
+func (h *AppointmentAttachmentHandler) UploadAttachment(c *gin.Context) {
+	appointmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid appointment ID"})
+		return
+	}
+
+	viewer, ok := attachmentViewer(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	// Leave room for the multipart envelope; the service enforces the exact file limit
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadBytes+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("file exceeds the maximum size of %d MB", h.maxUploadBytes>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "file is required"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.attachmentService.Upload(c.Request.Context(), appointmentID, &service.UploadAttachmentRequest{
+		FileName:   fileHeader.Filename,
+		Content:    file,
+		Visibility: domain.AttachmentVisibility(c.PostForm("visibility")),
+	}, viewer)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// ListAttachments godoc
+// @Summary List appointment attachments
+// @Description List the attachments of an appointment visible to the authenticated user
+// @Tags attachments
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Appointment ID (UUID)"
+// @Success 200 {array} domain.AppointmentAttachment
+// @Failure 403 {object} ErrorResponse "No access to the appointment"
+// @Failure 404 {object} ErrorResponse "Appointment not found"
+// @Router /appointments/{id}/attachments [get]
+func (h *AppointmentAttachmentHandler) ListAttachments(c *gin.Context) {
+	appointmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid appointment ID"})
+		return
+	}
+
+	viewer, ok := attachmentViewer(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	attachments, err := h.attachmentService.List(c.Request.Context(), appointmentID, viewer)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+// DownloadAttachment godoc
+// @Summary Download an appointment attachment
+// @Description Stream the content of an attachment visible to the authenticated user
+// @Tags attachments
+// @Security BearerAuth
+// @Produce octet-stream
+// @Param id path string true "Appointment ID (UUID)"
+// @Param attachmentId path string true "Attachment ID (UUID)"
+// @Success 200 {file} file
+// @Failure 403 {object} ErrorResponse "No access to the appointment"
+// @Failure 404 {object} ErrorResponse "Attachment not found"
+// @Router /appointments/{id}/attachments/{attachmentId}/download [get]
+func (h *AppointmentAttachmentHandler) DownloadAttachment(c *gin.Context) {
+	appointmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid appointment ID"})
+		return
+	}
+
+	attachmentID, err := uuid.Parse(c.Param("attachmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid attachment ID"})
+		return
+	}
+
+	viewer, ok := attachmentViewer(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	reader, attachment, err := h.attachmentService.Download(c.Request.Context(), appointmentID, attachmentID, viewer)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", strconv.Quote(attachment.FileName)))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, no-store")
+	c.DataFromReader(http.StatusOK, attachment.SizeBytes, attachment.ContentType, reader, nil)
+}
+
+// DeleteAttachment godoc
+// @Summary Delete an appointment attachment
+// @Description Delete an attachment (staff, or the client who uploaded it)
+// @Tags attachments
+// @Security BearerAuth
+// @Param id path string true "Appointment ID (UUID)"
+// @Param attachmentId path string true "Attachment ID (UUID)"
+// @Success 204 "No Content"
+// @Failure 403 {object} ErrorResponse "Not allowed to delete the attachment"
+// @Failure 404 {object} ErrorResponse "Attachment not found"
+// @Router /appointments/{id}/attachments/{attachmentId} [delete]
+func (h *AppointmentAttachmentHandler) DeleteAttachment(c *gin.Context) {
+	appointmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid appointment ID"})
+		return
+	}
+
+	attachmentID, err := uuid.Parse(c.Param("attachmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid attachment ID"})
+		return
+	}
+
+	viewer, ok := attachmentViewer(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	if err := h.attachmentService.Delete(c.Request.Context(), appointmentID, attachmentID, viewer); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// attachmentViewer builds the attachment viewer from the authenticated context
+func attachmentViewer(c *gin.Context) (service.AttachmentViewer, bool) {
+	userID, ok := c.Get("userID")
+	if !ok {
+		return service.AttachmentViewer{}, false
+	}
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		return service.AttachmentViewer{}, false
+	}
+
+	viewer := service.AttachmentViewer{UserID: id, Role: c.GetString("userRole")}
+	if clientID, exists := c.Get("clientID"); exists {
+		if cid, ok := clientID.(uuid.UUID); ok {
+			viewer.ClientID = &cid
+		}
+	}
+
+	return viewer, true
+}
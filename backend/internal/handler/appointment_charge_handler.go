@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AppointmentChargeHandler handles late-cancellation and no-show charge HTTP requests
+type AppointmentChargeHandler struct {
+	chargeService service.AppointmentChargeService
+}
+
+// NewAppointmentChargeHandler creates a new appointment charge handler
+func NewAppointmentChargeHandler(chargeService service.AppointmentChargeService) *AppointmentChargeHandler {
+	return &AppointmentChargeHandler{
+		chargeService: chargeService,
+	}
+}
+
+// ListCharges godoc
+// @Summary List appointment charges
+// @Description Get a paginated list of late-cancellation and no-show charges
+// @Tags charges
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "Charge status (pending/waived/issued)"
+// @Param kind query string false "Charge kind (late_cancellation/no_show)"
+// @Param clientId query string false "Client ID (UUID)"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size" default(20)
+// @Success 200 {object} PaginatedResponse
+// @Router /billing/charges [get]
+func (h *AppointmentChargeHandler) ListCharges(c *gin.Context) {
+	filters := repository.AppointmentChargeFilters{
+		Page:     1,
+		PageSize: 20,
+	}
+
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		filters.Page = page
+	}
+
+	if pageSize, err := strconv.Atoi(c.Query("pageSize")); err == nil && pageSize > 0 {
+		filters.PageSize = pageSize
+	}
+
+	if statusStr := c.Query("status"); statusStr != "" {
+		status := domain.AppointmentChargeStatus(statusStr)
+		filters.Status = &status
+	}
+
+	if kindStr := c.Query("kind"); kindStr != "" {
+		kind := domain.AppointmentChargeKind(kindStr)
+		filters.Kind = &kind
+	}
+
+	if clientIDStr := c.Query("clientId"); clientIDStr != "" {
+		if clientID, err := uuid.Parse(clientIDStr); err == nil {
+			filters.ClientID = &clientID
+		}
+	}
+
+	charges, total, err := h.chargeService.ListCharges(c.Request.Context(), filters)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:       charges,
+		Page:       filters.Page,
+		PageSize:   filters.PageSize,
+		Total:      int64(total),
+		TotalPages: (total + filters.PageSize - 1) / filters.PageSize,
+	})
+}
+
+// GetCharge godoc
+// @Summary Get an appointment charge by ID
+// @Description Retrieve a charge with its invoice
+// @Tags charges
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Charge ID (UUID)"
+// @Success 200 {object} domain.AppointmentCharge
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Charge not found"
+// @Router /billing/charges/{id} [get]
+func (h *AppointmentChargeHandler) GetCharge(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid charge ID"})
+		return
+	}
+
+	charge, err := h.chargeService.GetCharge(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, charge)
+}
+
+// WaiveCharge godoc
+// @Summary Waive an appointment charge
+// @Description Waive a pending charge and discard its draft invoice
+// @Tags charges
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge ID (UUID)"
+// @Param request body service.WaiveChargeRequest true "Waive request"
+// @Success 200 {object} domain.AppointmentCharge
+// @Failure 400 {object} ErrorResponse "Invalid request or charge not pending"
+// @Failure 404 {object} ErrorResponse "Charge not found"
+// @Router /billing/charges/{id}/waive [post]
+func (h *AppointmentChargeHandler) WaiveCharge(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid charge ID"})
+		return
+	}
+
+	var req service.WaiveChargeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	charge, err := h.chargeService.WaiveCharge(c.Request.Context(), id, &req, userID.(uuid.UUID))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, charge)
+}
+
+// IssueCharge godoc
+// @Summary Issue an appointment charge
+// @Description Issue the draft invoice of a pending charge
+// @Tags charges
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Charge ID (UUID)"
+// @Success 200 {object} domain.AppointmentCharge
+// @Failure 400 {object} ErrorResponse "Invalid ID or charge not pending"
+// @Failure 404 {object} ErrorResponse "Charge not found"
+// @Router /billing/charges/{id}/issue [post]
+func (h *AppointmentChargeHandler) IssueCharge(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid charge ID"})
+		return
+	}
+
+	charge, err := h.chargeService.IssueCharge(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, charge)
+}
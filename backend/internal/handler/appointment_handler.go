@@ -305,6 +305,80 @@ func (h *AppointmentHandler) ConfirmAppointment(c *gin.Context) {
 	c.JSON(http.StatusOK, appointment)
 }
 
+// MarkNoShow marks an appointment as not attended (admin/employee only)
+// @Summary      Mark appointment as no-show
+// @Description  Marks a started appointment as not attended by the client (admin/employee only)
+// @Tags         appointments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Appointment ID"
+// @Success      200 {object} domain.Appointment
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Router       /api/v1/appointments/{id}/no-show [post]
+func (h *AppointmentHandler) MarkNoShow(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		appErr := pkgerrors.NewValidationError("ID de cita inválido", nil)
+		pkgerrors.RespondWithAppError(c, appErr)
+		return
+	}
+
+	appointment, err := h.appointmentService.MarkNoShow(c.Request.Context(), id)
+	if err != nil {
+		if err.Error() == "cita no encontrada" {
+			appErr := pkgerrors.NewNotFoundError("Cita no encontrada")
+			pkgerrors.RespondWithAppError(c, appErr)
+			return
+		}
+		appErr := pkgerrors.NewValidationError(err.Error(), nil)
+		pkgerrors.RespondWithAppError(c, appErr)
+		return
+	}
+
+	c.JSON(http.StatusOK, appointment)
+}
+
+// CompleteAppointment marks an attended appointment as completed (admin/employee only)
+// @Summary      Complete appointment
+// @Description  Marks a started appointment as completed (admin/employee only). Depending on the billing settings, its invoice is then drafted or issued.
+// @Tags         appointments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Appointment ID"
+// @Success      200 {object} domain.Appointment
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Router       /api/v1/appointments/{id}/complete [post]
+func (h *AppointmentHandler) CompleteAppointment(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		appErr := pkgerrors.NewValidationError("ID de cita inválido", nil)
+		pkgerrors.RespondWithAppError(c, appErr)
+		return
+	}
+
+	appointment, err := h.appointmentService.CompleteAppointment(c.Request.Context(), id)
+	if err != nil {
+		if err.Error() == "cita no encontrada" {
+			appErr := pkgerrors.NewNotFoundError("Cita no encontrada")
+			pkgerrors.RespondWithAppError(c, appErr)
+			return
+		}
+		appErr := pkgerrors.NewValidationError(err.Error(), nil)
+		pkgerrors.RespondWithAppError(c, appErr)
+		return
+	}
+
+	c.JSON(http.StatusOK, appointment)
+}
+
 // ListAppointments lists all appointments with filters (admin/employee only)
 // @Summary      List all appointments
 // @Description  Lists all appointments with optional filters (admin/employee only)
@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// BankReconciliationHandler handles bank statement import and reconciliation HTTP requests
+type BankReconciliationHandler struct {
+	bankService service.BankReconciliationService
+}
+
+// NewBankReconciliationHandler creates a new bank reconciliation handler
+func NewBankReconciliationHandler(bankService service.BankReconciliationService) *BankReconciliationHandler {
+	return &BankReconciliationHandler{
+		bankService: bankService,
+	}
+}
+
+// ImportStatement godoc
+// @Summary Import a bank statement
+// @Description Upload a Norma 43 (Cuaderno 43) or camt.053 statement. Incoming movements are matched to outstanding invoices by amount, payment reference, invoice number in the concept and payer name, and outgoing ones to expenses. Movements matching a single candidate are reconciled, recording the payment of the invoice; the others go to the reconciliation queue. The same file cannot be imported twice.
+// @Tags bank
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Norma 43 or camt.053 file"
+// @Success 201 {object} service.BankStatementImport
+// @Failure 400 {object} ErrorResponse "Not a Norma 43 or camt.053 statement"
+// @Failure 409 {object} ErrorResponse "Statement already imported"
+// @Failure 413 {object} ErrorResponse "File too large"
+// @Router /billing/bank/statements [post]
+func (h *BankReconciliationHandler) ImportStatement(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxBankStatementFileBytes+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("file exceeds the maximum size of %d MB", service.MaxBankStatementFileBytes>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "file is required"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	result, err := h.bankService.ImportStatement(c.Request.Context(), fileHeader.Filename, file, userID.(uuid.UUID))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// ListStatements godoc
+// @Summary List imported bank statements
+// @Description Get the imported bank statements without their movements, newest first
+// @Tags bank
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} domain.BankStatement
+// @Router /billing/bank/statements [get]
+func (h *BankReconciliationHandler) ListStatements(c *gin.Context) {
+	statements, err := h.bankService.ListStatements(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statements)
+}
+
+// GetStatement godoc
+// @Summary Get a bank statement
+// @Description Get an imported bank statement with its movements and their reconciliation
+// @Tags bank
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Statement ID (UUID)"
+// @Success 200 {object} domain.BankStatement
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Statement not found"
+// @Router /billing/bank/statements/{id} [get]
+func (h *BankReconciliationHandler) GetStatement(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid statement ID"})
+		return
+	}
+
+	statement, err := h.bankService.GetStatement(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statement)
+}
+
+// ListQueue godoc
+// @Summary List the reconciliation queue
+// @Description Get the bank movements waiting to be reconciled, oldest first, each with the invoices or expenses it may match, best first
+// @Tags bank
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} service.BankQueueItem
+// @Router /billing/bank/movements/pending [get]
+func (h *BankReconciliationHandler) ListQueue(c *gin.Context) {
+	items, err := h.bankService.ListQueue(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+// ConfirmMatch godoc
+// @Summary Reconcile a bank movement
+// @Description Reconcile a pending incoming movement with an invoice, recording a transfer payment on the booking date, or an outgoing movement with an expense of the same amount
+// @Tags bank
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Movement ID (UUID)"
+// @Param request body service.ConfirmBankMatchRequest true "Invoice or expense"
+// @Success 200 {object} service.BankReconciliation
+// @Failure 400 {object} ErrorResponse "Wrong direction, amount above the balance or not matching the expense"
+// @Failure 404 {object} ErrorResponse "Movement, invoice or expense not found"
+// @Failure 409 {object} ErrorResponse "Movement or expense already reconciled"
+// @Router /billing/bank/movements/{id}/match [post]
+func (h *BankReconciliationHandler) ConfirmMatch(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid movement ID"})
+		return
+	}
+
+	var req service.ConfirmBankMatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	reconciliation, err := h.bankService.ConfirmMatch(c.Request.Context(), id, &req, userID.(uuid.UUID))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reconciliation)
+}
+
+// IgnoreMovement godoc
+// @Summary Ignore a bank movement
+// @Description Take a pending movement with nothing to reconcile, such as bank fees, out of the queue
+// @Tags bank
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Movement ID (UUID)"
+// @Success 200 {object} domain.BankMovement
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Movement not found"
+// @Failure 409 {object} ErrorResponse "Movement already reconciled or ignored"
+// @Router /billing/bank/movements/{id}/ignore [post]
+func (h *BankReconciliationHandler) IgnoreMovement(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid movement ID"})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	movement, err := h.bankService.IgnoreMovement(c.Request.Context(), id, userID.(uuid.UUID))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, movement)
+}
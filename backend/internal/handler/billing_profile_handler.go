@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// BillingProfileHandler handles billing profile (payer) HTTP requests
+type BillingProfileHandler struct {
+	billingProfileService service.BillingProfileService
+}
+
+// NewBillingProfileHandler creates a new billing profile handler
+func NewBillingProfileHandler(billingProfileService service.BillingProfileService) *BillingProfileHandler {
+	return &BillingProfileHandler{
+		billingProfileService: billingProfileService,
+	}
+}
+
+// CreateBillingProfile godoc
+// @Summary Create a billing profile
+// @Description Register a payer (a parent, a company...) with the fiscal name and NIF/CIF printed on the invoices of the clients it pays for
+// @Tags billing-profiles
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body service.CreateBillingProfileRequest true "Billing profile"
+// @Success 201 {object} domain.BillingProfile
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Router /billing/billing-profiles [post]
+func (h *BillingProfileHandler) CreateBillingProfile(c *gin.Context) {
+	var req service.CreateBillingProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	profile, err := h.billingProfileService.CreateBillingProfile(c.Request.Context(), &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, profile)
+}
+
+// GetBillingProfile godoc
+// @Summary Get a billing profile by ID
+// @Description Retrieve a payer with its fiscal data
+// @Tags billing-profiles
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Billing profile ID (UUID)"
+// @Success 200 {object} domain.BillingProfile
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Billing profile not found"
+// @Router /billing/billing-profiles/{id} [get]
+func (h *BillingProfileHandler) GetBillingProfile(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid billing profile ID"})
+		return
+	}
+
+	profile, err := h.billingProfileService.GetBillingProfile(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// ListBillingProfiles godoc
+// @Summary List billing profiles
+// @Description List the payers, searching by name or NIF/CIF
+// @Tags billing-profiles
+// @Security BearerAuth
+// @Produce json
+// @Param search query string false "Name or NIF/CIF"
+// @Param activeOnly query bool false "Only active profiles" default(false)
+// @Success 200 {array} domain.BillingProfile
+// @Router /billing/billing-profiles [get]
+func (h *BillingProfileHandler) ListBillingProfiles(c *gin.Context) {
+	filters := repository.BillingProfileFilters{
+		Search:     c.Query("search"),
+		ActiveOnly: c.Query("activeOnly") == "true",
+	}
+
+	profiles, err := h.billingProfileService.ListBillingProfiles(c.Request.Context(), filters)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profiles)
+}
+
+// UpdateBillingProfile godoc
+// @Summary Update a billing profile
+// @Description Update the fiscal data of a payer. Issued invoices keep the data they were issued with; drafts take the new data when issued.
+// @Tags billing-profiles
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Billing profile ID (UUID)"
+// @Param request body service.UpdateBillingProfileRequest true "Billing profile"
+// @Success 200 {object} domain.BillingProfile
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Failure 404 {object} ErrorResponse "Billing profile not found"
+// @Router /billing/billing-profiles/{id} [put]
+func (h *BillingProfileHandler) UpdateBillingProfile(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid billing profile ID"})
+		return
+	}
+
+	var req service.UpdateBillingProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	profile, err := h.billingProfileService.UpdateBillingProfile(c.Request.Context(), id, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// DeleteBillingProfile godoc
+// @Summary Delete a billing profile
+// @Description Soft delete a payer and unlink it from its clients; its invoices keep their payer data
+// @Tags billing-profiles
+// @Security BearerAuth
+// @Param id path string true "Billing profile ID (UUID)"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Billing profile not found"
+// @Router /billing/billing-profiles/{id} [delete]
+func (h *BillingProfileHandler) DeleteBillingProfile(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid billing profile ID"})
+		return
+	}
+
+	if err := h.billingProfileService.DeleteBillingProfile(c.Request.Context(), id); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListClientBillingProfiles godoc
+// @Summary List the payers of a client
+// @Description Get the billing profiles paying for a client, the default one first
+// @Tags billing-profiles
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Client ID (UUID)"
+// @Success 200 {array} domain.ClientBillingProfile
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Client not found"
+// @Router /clients/{id}/billing-profiles [get]
+func (h *BillingProfileHandler) ListClientBillingProfiles(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid client ID"})
+		return
+	}
+
+	links, err := h.billingProfileService.ListClientBillingProfiles(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+// LinkClient godoc
+// @Summary Link a payer to a client
+// @Description Make a billing profile a payer of the client. The default payer is used for the new invoices of the client, replacing the previous default.
+// @Tags billing-profiles
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Client ID (UUID)"
+// @Param profileId path string true "Billing profile ID (UUID)"
+// @Param request body service.LinkBillingProfileRequest false "Link"
+// @Success 200 {object} domain.ClientBillingProfile
+// @Failure 400 {object} ErrorResponse "Invalid ID format or inactive profile"
+// @Failure 404 {object} ErrorResponse "Client or billing profile not found"
+// @Router /clients/{id}/billing-profiles/{profileId} [put]
+func (h *BillingProfileHandler) LinkClient(c *gin.Context) {
+	clientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid client ID"})
+		return
+	}
+	profileID, err := uuid.Parse(c.Param("profileId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid billing profile ID"})
+		return
+	}
+
+	var req service.LinkBillingProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	link, err := h.billingProfileService.LinkClient(c.Request.Context(), clientID, profileID, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, link)
+}
+
+// UnlinkClient godoc
+// @Summary Unlink a payer from a client
+// @Description Stop a billing profile from paying for the client; invoices already created keep it as payer
+// @Tags billing-profiles
+// @Security BearerAuth
+// @Param id path string true "Client ID (UUID)"
+// @Param profileId path string true "Billing profile ID (UUID)"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Billing profile not linked to the client"
+// @Router /clients/{id}/billing-profiles/{profileId} [delete]
+func (h *BillingProfileHandler) UnlinkClient(c *gin.Context) {
+	clientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid client ID"})
+		return
+	}
+	profileID, err := uuid.Parse(c.Param("profileId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid billing profile ID"})
+		return
+	}
+
+	if err := h.billingProfileService.UnlinkClient(c.Request.Context(), clientID, profileID); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
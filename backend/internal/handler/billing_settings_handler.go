@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// BillingSettingsHandler handles the clinic's fiscal data and invoice template HTTP requests
+type BillingSettingsHandler struct {
+	settingsService service.BillingSettingsService
+}
+
+// NewBillingSettingsHandler creates a new billing settings handler
+func NewBillingSettingsHandler(settingsService service.BillingSettingsService) *BillingSettingsHandler {
+	return &BillingSettingsHandler{
+		settingsService: settingsService,
+	}
+}
+
+// GetSettings godoc
+// @Summary Get billing settings
+// @Description Get the clinic's fiscal data and invoice template printed on invoices
+// @Tags billing-settings
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} domain.BillingSettings
+// @Router /billing/settings [get]
+func (h *BillingSettingsHandler) GetSettings(c *gin.Context) {
+	settings, err := h.settingsService.GetSettings(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings godoc
+// @Summary Update billing settings
+// @Description Update the clinic's fiscal data, invoice footer and accent color (admin only)
+// @Tags billing-settings
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body service.UpdateBillingSettingsRequest true "Billing settings"
+// @Success 200 {object} domain.BillingSettings
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Router /billing/settings [put]
+func (h *BillingSettingsHandler) UpdateSettings(c *gin.Context) {
+	var req service.UpdateBillingSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	settings, err := h.settingsService.UpdateSettings(c.Request.Context(), &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UploadLogo godoc
+// @Summary Upload the invoice logo
+// @Description Upload the logo printed on invoices, PNG or JPEG up to 1 MB (admin only)
+// @Tags billing-settings
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Logo image"
+// @Success 200 {object} domain.BillingSettings
+// @Failure 400 {object} ErrorResponse "Invalid image"
+// @Failure 413 {object} ErrorResponse "File too large"
+// @Router /billing/settings/logo [put]
+func (h *BillingSettingsHandler) UploadLogo(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxInvoiceLogoBytes+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("logo exceeds the maximum size of %d MB", service.MaxInvoiceLogoBytes>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "file is required"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	settings, err := h.settingsService.UploadLogo(c.Request.Context(), file)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// GetLogo godoc
+// @Summary Get the invoice logo
+// @Description Download the logo printed on invoices
+// @Tags billing-settings
+// @Security BearerAuth
+// @Produce png
+// @Produce jpeg
+// @Success 200 {file} file
+// @Failure 404 {object} ErrorResponse "No logo configured"
+// @Router /billing/settings/logo [get]
+func (h *BillingSettingsHandler) GetLogo(c *gin.Context) {
+	reader, contentType, err := h.settingsService.GetLogo(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
+}
+
+// DeleteLogo godoc
+// @Summary Delete the invoice logo
+// @Description Remove the logo printed on invoices (admin only)
+// @Tags billing-settings
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} domain.BillingSettings
+// @Router /billing/settings/logo [delete]
+func (h *BillingSettingsHandler) DeleteLogo(c *gin.Context) {
+	settings, err := h.settingsService.DeleteLogo(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
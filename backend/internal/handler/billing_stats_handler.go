@@ -2,10 +2,13 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // BillingStatsHandler handles billing statistics HTTP requests
@@ -65,25 +68,32 @@ func (h *BillingStatsHandler) GetDashboardStats(c *gin.Context) {
 }
 
 // GetRevenueByMonth godoc
-// @Summary Get revenue grouped by month
-// @Description Retrieve monthly revenue data for charts
+// @Summary Get revenue grouped by period
+// @Description Retrieve issued and collected revenue, expenses and net balance by month (or week or quarter) for charts; periods without activity are zero
 // @Tags billing-stats
 // @Security BearerAuth
 // @Produce json
-// @Param fromDate query string false "From date (YYYY-MM-DD)"
-// @Param toDate query string false "To date (YYYY-MM-DD)"
-// @Success 200 {array} service.MonthlyRevenue
-// @Failure 400 {object} ErrorResponse "Invalid date format"
+// @Param fromDate query string false "From date (YYYY-MM-DD)" default(first day of the month 6 months ago)
+// @Param toDate query string false "To date (YYYY-MM-DD)" default(today)
+// @Param year query int false "Whole calendar year; overrides fromDate and toDate"
+// @Param groupBy query string false "week, month or quarter" default(month)
+// @Param compare query bool false "Include the same periods of the previous year" default(false)
+// @Success 200 {array} service.RevenuePeriod
+// @Failure 400 {object} ErrorResponse "Invalid date, year or grouping"
 // @Router /billing/revenue-by-month [get]
 func (h *BillingStatsHandler) GetRevenueByMonth(c *gin.Context) {
 	// Parse dates or use defaults (last 6 months)
 	now := time.Now()
-	fromDate := now.AddDate(0, -6, 0)
-	toDate := now
+	query := service.RevenueQuery{
+		GroupBy:     repository.PeriodGranularity(c.DefaultQuery("groupBy", string(repository.PeriodMonth))),
+		FromDate:    time.Date(now.Year(), now.Month()-6, 1, 0, 0, 0, 0, now.Location()),
+		ToDate:      now,
+		CompareYear: c.Query("compare") == "true",
+	}
 
 	if fromDateStr := c.Query("fromDate"); fromDateStr != "" {
 		if parsed, err := time.Parse("2006-01-02", fromDateStr); err == nil {
-			fromDate = parsed
+			query.FromDate = parsed
 		} else {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid fromDate format, use YYYY-MM-DD"})
 			return
@@ -92,14 +102,24 @@ func (h *BillingStatsHandler) GetRevenueByMonth(c *gin.Context) {
 
 	if toDateStr := c.Query("toDate"); toDateStr != "" {
 		if parsed, err := time.Parse("2006-01-02", toDateStr); err == nil {
-			toDate = parsed
+			query.ToDate = parsed
 		} else {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid toDate format, use YYYY-MM-DD"})
 			return
 		}
 	}
 
-	revenue, err := h.statsService.GetRevenueByMonth(c.Request.Context(), fromDate, toDate)
+	if yearStr := c.Query("year"); yearStr != "" {
+		year, err := strconv.Atoi(yearStr)
+		if err != nil || year < 2000 || year > 2100 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid year"})
+			return
+		}
+		query.FromDate = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		query.ToDate = time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	}
+
+	revenue, err := h.statsService.GetRevenueByPeriod(c.Request.Context(), query)
 	if err != nil {
 		handleError(c, err)
 		return
@@ -199,3 +219,75 @@ func (h *BillingStatsHandler) GetBalance(c *gin.Context) {
 		"toDate":   toDate.Format("2006-01-02"),
 	})
 }
+
+// GetAgingReport godoc
+// @Summary Get the accounts receivable aging report
+// @Description Bucket the outstanding balance of each client into not due, 1-30, 31-60, 61-90 and more than 90 days past due, with totals
+// @Tags billing-stats
+// @Security BearerAuth
+// @Produce json,text/csv
+// @Param asOf query string false "Day the balances and days past due are taken on (YYYY-MM-DD)" default(today)
+// @Param clientId query string false "Only invoices of this client (UUID)"
+// @Param employeeId query string false "Only invoices of appointments attended by this employee (UUID)"
+// @Param serviceTypeId query string false "Only invoices billing this service (UUID)"
+// @Param format query string false "json or csv" default(json)
+// @Success 200 {object} service.AgingReport
+// @Failure 400 {object} ErrorResponse "Invalid date or ID"
+// @Router /billing/reports/aging [get]
+func (h *BillingStatsHandler) GetAgingReport(c *gin.Context) {
+	var query service.AgingQuery
+
+	if asOfStr := c.Query("asOf"); asOfStr != "" {
+		parsed, err := time.Parse("2006-01-02", asOfStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid asOf format, use YYYY-MM-DD"})
+			return
+		}
+		query.AsOf = parsed
+	}
+
+	var err error
+	if query.Filters.ClientID, err = optionalUUIDQuery(c, "clientId"); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid clientId"})
+		return
+	}
+	if query.Filters.EmployeeID, err = optionalUUIDQuery(c, "employeeId"); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid employeeId"})
+		return
+	}
+	if query.Filters.ServiceTypeID, err = optionalUUIDQuery(c, "serviceTypeId"); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid serviceTypeId"})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		export, err := h.statsService.ExportAgingReportCSV(c.Request.Context(), query)
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+		sendCSV(c, export)
+		return
+	}
+
+	report, err := h.statsService.GetAgingReport(c.Request.Context(), query)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// optionalUUIDQuery parses a UUID query parameter, nil when it is not given
+func optionalUUIDQuery(c *gin.Context, param string) (*uuid.UUID, error) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// DunningHandler handles overdue invoice and payment reminder HTTP requests
+type DunningHandler struct {
+	dunningService service.DunningService
+}
+
+// NewDunningHandler creates a new dunning handler
+func NewDunningHandler(dunningService service.DunningService) *DunningHandler {
+	return &DunningHandler{
+		dunningService: dunningService,
+	}
+}
+
+// GetOverdueInvoices godoc
+// @Summary Get overdue invoices
+// @Description Retrieve the issued invoices past their due date that still have a balance outstanding, oldest due date first
+// @Tags dunning
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} domain.Invoice
+// @Router /billing/invoices/overdue [get]
+func (h *DunningHandler) GetOverdueInvoices(c *gin.Context) {
+	invoices, err := h.dunningService.ListOverdueInvoices(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invoices)
+}
+
+// GetInvoiceReminders godoc
+// @Summary Get the payment reminders of an invoice
+// @Description Retrieve the payment reminders sent for an invoice, first level first
+// @Tags dunning
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Invoice ID (UUID)"
+// @Success 200 {array} domain.InvoiceReminder
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Invoice not found"
+// @Router /billing/invoices/{id}/reminders [get]
+func (h *DunningHandler) GetInvoiceReminders(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid invoice ID"})
+		return
+	}
+
+	reminders, err := h.dunningService.GetInvoiceReminders(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reminders)
+}
+
+// RunDunning godoc
+// @Summary Run the dunning job
+// @Description Flag the invoices past their due date and queue the payment reminders due today, as the scheduled job does. Clients in a payment plan are not reminded.
+// @Tags dunning
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} service.DunningRun
+// @Router /billing/dunning/run [post]
+func (h *DunningHandler) RunDunning(c *gin.Context) {
+	result, err := h.dunningService.Run(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
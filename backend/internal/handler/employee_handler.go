@@ -60,6 +60,10 @@ func (h *EmployeeHandler) CreateEmployee(c *gin.Context) {
 			appErr := pkgerrors.NewValidationError("Formato de DNI inválido", nil)
 			pkgerrors.RespondWithAppError(c, appErr)
 			return
+		case service.ErrInvalidRate:
+			appErr := pkgerrors.NewValidationError("La tarifa por sesión no puede ser negativa", nil)
+			pkgerrors.RespondWithAppError(c, appErr)
+			return
 		case service.ErrEmailInUse:
 			appErr := pkgerrors.NewConflictError("El email ya está registrado", pkgerrors.CodeEmailAlreadyExists)
 			pkgerrors.RespondWithAppError(c, appErr)
@@ -235,6 +239,10 @@ func (h *EmployeeHandler) UpdateEmployee(c *gin.Context) {
 			appErr := pkgerrors.NewValidationError("Formato de DNI inválido", nil)
 			pkgerrors.RespondWithAppError(c, appErr)
 			return
+		case service.ErrInvalidRate:
+			appErr := pkgerrors.NewValidationError("La tarifa por sesión no puede ser negativa", nil)
+			pkgerrors.RespondWithAppError(c, appErr)
+			return
 		case service.ErrEmailInUse:
 			appErr := pkgerrors.NewConflictError("El email ya está registrado", pkgerrors.CodeEmailAlreadyExists)
 			pkgerrors.RespondWithAppError(c, appErr)
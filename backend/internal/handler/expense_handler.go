@@ -90,6 +90,7 @@ func (h *ExpenseHandler) GetExpense(c *gin.Context) {
 // @Param fromDate query string false "From date (YYYY-MM-DD)"
 // @Param toDate query string false "To date (YYYY-MM-DD)"
 // @Param hasInvoice query bool false "Has invoice (true/false)"
+// @Param needsReview query bool false "Generated from a recurring expense and not reviewed (true/false)"
 // @Param supplier query string false "Supplier name (partial match)"
 // @Param search query string false "Search in supplier, invoice, notes"
 // @Param page query int false "Page number" default(1)
@@ -150,6 +151,13 @@ func (h *ExpenseHandler) ListExpenses(c *gin.Context) {
 		}
 	}
 
+	// Parse needsReview
+	if needsReviewStr := c.Query("needsReview"); needsReviewStr != "" {
+		if needsReview, err := strconv.ParseBool(needsReviewStr); err == nil {
+			filters.NeedsReview = &needsReview
+		}
+	}
+
 	// Parse supplier and search
 	filters.Supplier = c.Query("supplier")
 	filters.Search = c.Query("search")
@@ -231,6 +239,33 @@ func (h *ExpenseHandler) DeleteExpense(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// MarkExpenseReviewed godoc
+// @Summary Mark an expense as reviewed
+// @Description Clear the review flag of an expense generated from a recurring expense
+// @Tags expenses
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Expense ID (UUID)"
+// @Success 200 {object} domain.Expense
+// @Failure 400 {object} ErrorResponse "Invalid ID"
+// @Failure 404 {object} ErrorResponse "Expense not found"
+// @Router /billing/expenses/{id}/review [post]
+func (h *ExpenseHandler) MarkExpenseReviewed(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid expense ID"})
+		return
+	}
+
+	expense, err := h.expenseService.MarkExpenseReviewed(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, expense)
+}
+
 // GetExpensesByCategory godoc
 // @Summary Get expenses by category
 // @Description Retrieve all expenses for a specific category
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ExpenseReceiptHandler handles expense receipt HTTP requests
+type ExpenseReceiptHandler struct {
+	receiptService service.ExpenseReceiptService
+	maxUploadBytes int64
+}
+
+// NewExpenseReceiptHandler creates a new expense receipt handler
+func NewExpenseReceiptHandler(receiptService service.ExpenseReceiptService, maxUploadBytes int64) *ExpenseReceiptHandler {
+	if maxUploadBytes <= 0 {
+		maxUploadBytes = service.DefaultMaxAttachmentBytes
+	}
+
+	return &ExpenseReceiptHandler{
+		receiptService: receiptService,
+		maxUploadBytes: maxUploadBytes,
+	}
+}
+
+// UploadReceipt godoc
+// @Summary Upload an expense receipt
+// @Description Attach the receipt (PDF, JPEG or PNG) of an expense, replacing the previous one. A receipt already attached to another expense is rejected as a likely duplicate.
+// @Tags expenses
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Expense ID (UUID)"
+// @Param file formData file true "Receipt to upload"
+// @Success 200 {object} domain.Expense
+// @Failure 400 {object} ErrorResponse "Invalid file, type or size"
+// @Failure 404 {object} ErrorResponse "Expense not found"
+// @Failure 409 {object} ErrorResponse "Receipt attached to another expense"
+// @Failure 413 {object} ErrorResponse "File too large"
+// @Router /billing/expenses/{id}/attachment [post]
+func (h *ExpenseReceiptHandler) UploadReceipt(c *gin.Context) {
+	expenseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid expense ID"})
+		return
+	}
+
+	// Leave room for the multipart envelope; the service enforces the exact file limit
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadBytes+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("file exceeds the maximum size of %d MB", h.maxUploadBytes>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "file is required"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	expense, err := h.receiptService.Upload(c.Request.Context(), expenseID, &service.UploadReceiptRequest{
+		FileName: fileHeader.Filename,
+		Content:  file,
+	})
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, expense)
+}
+
+// GetReceiptURL godoc
+// @Summary Get a download URL for an expense receipt
+// @Description Issue a short-lived URL to download the receipt of an expense without credentials
+// @Tags expenses
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Expense ID (UUID)"
+// @Success 200 {object} service.ReceiptURL
+// @Failure 404 {object} ErrorResponse "Expense not found or without receipt"
+// @Router /billing/expenses/{id}/attachment/url [get]
+func (h *ExpenseReceiptHandler) GetReceiptURL(c *gin.Context) {
+	expenseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid expense ID"})
+		return
+	}
+
+	url, err := h.receiptService.DownloadURL(c.Request.Context(), expenseID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, url)
+}
+
+// DownloadReceipt godoc
+// @Summary Download an expense receipt
+// @Description Stream a receipt through a signed URL issued by the download URL endpoint (local storage)
+// @Tags expenses
+// @Produce octet-stream
+// @Param id path string true "Expense ID (UUID)"
+// @Param expires query int true "Expiry of the URL (Unix seconds)"
+// @Param signature query string true "Signature of the URL"
+// @Success 200 {file} file
+// @Failure 403 {object} ErrorResponse "Invalid or expired link"
+// @Failure 404 {object} ErrorResponse "Receipt file not found"
+// @Router /files/expense-receipts/{id} [get]
+func (h *ExpenseReceiptHandler) DownloadReceipt(c *gin.Context) {
+	expenseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid expense ID"})
+		return
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "invalid download link"})
+		return
+	}
+
+	reader, expense, err := h.receiptService.Open(c.Request.Context(), expenseID, time.Unix(expires, 0), c.Query("signature"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", strconv.Quote(*expense.AttachmentFileName)))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, no-store")
+	c.DataFromReader(http.StatusOK, *expense.AttachmentSizeBytes, *expense.AttachmentContentType, reader, nil)
+}
+
+// DeleteReceipt godoc
+// @Summary Delete an expense receipt
+// @Description Remove the receipt of an expense
+// @Tags expenses
+// @Security BearerAuth
+// @Param id path string true "Expense ID (UUID)"
+// @Success 204 "No Content"
+// @Failure 404 {object} ErrorResponse "Expense not found or without receipt"
+// @Router /billing/expenses/{id}/attachment [delete]
+func (h *ExpenseReceiptHandler) DeleteReceipt(c *gin.Context) {
+	expenseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid expense ID"})
+		return
+	}
+
+	if err := h.receiptService.Delete(c.Request.Context(), expenseID); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,416 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InsurerHandler handles insurer (mutua), insurance policy and authorisation HTTP requests
+type InsurerHandler struct {
+	insurerService service.InsurerService
+}
+
+// NewInsurerHandler creates a new insurer handler
+func NewInsurerHandler(insurerService service.InsurerService) *InsurerHandler {
+	return &InsurerHandler{
+		insurerService: insurerService,
+	}
+}
+
+// CreateInsurer godoc
+// @Summary Create an insurer
+// @Description Register an insurance company paying for the sessions of its insured clients
+// @Tags insurers
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body service.CreateInsurerRequest true "Insurer creation request"
+// @Success 201 {object} domain.Insurer
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Failure 409 {object} ErrorResponse "Name already exists"
+// @Router /billing/insurers [post]
+func (h *InsurerHandler) CreateInsurer(c *gin.Context) {
+	var req service.CreateInsurerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	insurer, err := h.insurerService.CreateInsurer(c.Request.Context(), &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, insurer)
+}
+
+// GetInsurer godoc
+// @Summary Get an insurer by ID
+// @Description Retrieve an insurer
+// @Tags insurers
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Insurer ID (UUID)"
+// @Success 200 {object} domain.Insurer
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Insurer not found"
+// @Router /billing/insurers/{id} [get]
+func (h *InsurerHandler) GetInsurer(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid insurer ID"})
+		return
+	}
+
+	insurer, err := h.insurerService.GetInsurer(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, insurer)
+}
+
+// ListInsurers godoc
+// @Summary List insurers
+// @Description List the insurers ordered by name
+// @Tags insurers
+// @Security BearerAuth
+// @Produce json
+// @Param activeOnly query bool false "Only active insurers" default(false)
+// @Success 200 {array} domain.Insurer
+// @Router /billing/insurers [get]
+func (h *InsurerHandler) ListInsurers(c *gin.Context) {
+	activeOnly := c.Query("activeOnly") == "true"
+
+	insurers, err := h.insurerService.ListInsurers(c.Request.Context(), activeOnly)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, insurers)
+}
+
+// UpdateInsurer godoc
+// @Summary Update an insurer
+// @Description Update an insurer (sessions already covered keep their amounts)
+// @Tags insurers
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Insurer ID (UUID)"
+// @Param request body service.UpdateInsurerRequest true "Insurer update request"
+// @Success 200 {object} domain.Insurer
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Failure 404 {object} ErrorResponse "Insurer not found"
+// @Router /billing/insurers/{id} [put]
+func (h *InsurerHandler) UpdateInsurer(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid insurer ID"})
+		return
+	}
+
+	var req service.UpdateInsurerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	insurer, err := h.insurerService.UpdateInsurer(c.Request.Context(), id, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, insurer)
+}
+
+// DeleteInsurer godoc
+// @Summary Delete an insurer
+// @Description Soft delete an insurer; its settlement invoices and the policies of its clients are kept
+// @Tags insurers
+// @Security BearerAuth
+// @Param id path string true "Insurer ID (UUID)"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Insurer not found"
+// @Router /billing/insurers/{id} [delete]
+func (h *InsurerHandler) DeleteInsurer(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid insurer ID"})
+		return
+	}
+
+	if err := h.insurerService.DeleteInsurer(c.Request.Context(), id); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SettleInsurer godoc
+// @Summary Settle the sessions of an insurer
+// @Description Invoice an insurer the covered sessions up to the end of a month not settled yet, one line per session, or preview them
+// @Tags insurers
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Insurer ID (UUID)"
+// @Param request body service.SettleInsurerRequest true "Settlement period and options"
+// @Success 200 {object} service.InsurerSettlement "Preview"
+// @Success 201 {object} service.InsurerSettlement "Settlement invoice created"
+// @Failure 400 {object} ErrorResponse "Invalid period or nothing to settle"
+// @Failure 404 {object} ErrorResponse "Insurer not found"
+// @Router /billing/insurers/{id}/settlement [post]
+func (h *InsurerHandler) SettleInsurer(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid insurer ID"})
+		return
+	}
+
+	var req service.SettleInsurerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	settlement, err := h.insurerService.SettleInsurer(c.Request.Context(), id, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if req.Preview {
+		status = http.StatusOK
+	}
+	c.JSON(status, settlement)
+}
+
+// ListClientPolicies godoc
+// @Summary List the insurance policies of a client
+// @Description Retrieve the policies of a client with their authorisations and sessions left
+// @Tags insurers
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Client ID (UUID)"
+// @Success 200 {array} domain.InsurancePolicy
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Client not found"
+// @Router /clients/{id}/insurance-policies [get]
+func (h *InsurerHandler) ListClientPolicies(c *gin.Context) {
+	clientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid client ID"})
+		return
+	}
+
+	policies, err := h.insurerService.ListClientPolicies(c.Request.Context(), clientID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, policies)
+}
+
+// CreatePolicy godoc
+// @Summary Add an insurance policy to a client
+// @Description Record the policy of a client with an insurer and the co-payment of each session
+// @Tags insurers
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Client ID (UUID)"
+// @Param request body service.InsurancePolicyRequest true "Insurance policy"
+// @Success 201 {object} domain.InsurancePolicy
+// @Failure 400 {object} ErrorResponse "Invalid request, unknown or inactive insurer"
+// @Failure 404 {object} ErrorResponse "Client not found"
+// @Router /clients/{id}/insurance-policies [post]
+func (h *InsurerHandler) CreatePolicy(c *gin.Context) {
+	clientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid client ID"})
+		return
+	}
+
+	var req service.InsurancePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	policy, err := h.insurerService.CreatePolicy(c.Request.Context(), clientID, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, policy)
+}
+
+// UpdatePolicy godoc
+// @Summary Update an insurance policy
+// @Description Update the policy of a client (sessions already covered keep their co-payment)
+// @Tags insurers
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Policy ID (UUID)"
+// @Param request body service.InsurancePolicyRequest true "Insurance policy"
+// @Success 200 {object} domain.InsurancePolicy
+// @Failure 400 {object} ErrorResponse "Invalid request, unknown or inactive insurer"
+// @Failure 404 {object} ErrorResponse "Policy not found"
+// @Router /billing/insurance-policies/{id} [put]
+func (h *InsurerHandler) UpdatePolicy(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid policy ID"})
+		return
+	}
+
+	var req service.InsurancePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	policy, err := h.insurerService.UpdatePolicy(c.Request.Context(), id, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// DeletePolicy godoc
+// @Summary Delete an insurance policy
+// @Description Soft delete the policy of a client
+// @Tags insurers
+// @Security BearerAuth
+// @Param id path string true "Policy ID (UUID)"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Policy not found"
+// @Router /billing/insurance-policies/{id} [delete]
+func (h *InsurerHandler) DeletePolicy(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid policy ID"})
+		return
+	}
+
+	if err := h.insurerService.DeletePolicy(c.Request.Context(), id); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CreateAuthorisation godoc
+// @Summary Add an authorisation to a policy
+// @Description Record the pre-authorisation number of the insurer, the sessions it covers and its validity
+// @Tags insurers
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Policy ID (UUID)"
+// @Param request body service.InsuranceAuthorisationRequest true "Insurance authorisation"
+// @Success 201 {object} domain.InsuranceAuthorisation
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Failure 404 {object} ErrorResponse "Policy not found"
+// @Failure 409 {object} ErrorResponse "Authorisation number already exists"
+// @Router /billing/insurance-policies/{id}/authorisations [post]
+func (h *InsurerHandler) CreateAuthorisation(c *gin.Context) {
+	policyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid policy ID"})
+		return
+	}
+
+	var req service.InsuranceAuthorisationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	authorisation, err := h.insurerService.CreateAuthorisation(c.Request.Context(), policyID, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, authorisation)
+}
+
+// UpdateAuthorisation godoc
+// @Summary Update an authorisation
+// @Description Update an authorisation, e.g. when the insurer extends it; the sessions used are kept
+// @Tags insurers
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Authorisation ID (UUID)"
+// @Param request body service.InsuranceAuthorisationRequest true "Insurance authorisation"
+// @Success 200 {object} domain.InsuranceAuthorisation
+// @Failure 400 {object} ErrorResponse "Invalid request or fewer sessions than used"
+// @Failure 404 {object} ErrorResponse "Authorisation not found"
+// @Router /billing/insurance-authorisations/{id} [put]
+func (h *InsurerHandler) UpdateAuthorisation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid authorisation ID"})
+		return
+	}
+
+	var req service.InsuranceAuthorisationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	authorisation, err := h.insurerService.UpdateAuthorisation(c.Request.Context(), id, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, authorisation)
+}
+
+// DeleteAuthorisation godoc
+// @Summary Delete an authorisation
+// @Description Delete an authorisation recorded by mistake; authorisations that covered sessions cannot be deleted
+// @Tags insurers
+// @Security BearerAuth
+// @Param id path string true "Authorisation ID (UUID)"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Authorisation not found"
+// @Failure 409 {object} ErrorResponse "Authorisation has covered sessions"
+// @Router /billing/insurance-authorisations/{id} [delete]
+func (h *InsurerHandler) DeleteAuthorisation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid authorisation ID"})
+		return
+	}
+
+	if err := h.insurerService.DeleteAuthorisation(c.Request.Context(), id); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// InvoiceBatchHandler handles monthly batch invoicing HTTP requests
+type InvoiceBatchHandler struct {
+	batchService service.InvoiceBatchService
+}
+
+// NewInvoiceBatchHandler creates a new batch invoicing handler
+func NewInvoiceBatchHandler(batchService service.InvoiceBatchService) *InvoiceBatchHandler {
+	return &InvoiceBatchHandler{
+		batchService: batchService,
+	}
+}
+
+// RunBatch godoc
+// @Summary Invoice a month per client
+// @Description Invoice the completed sessions of a month that are not invoiced yet, with one invoice per client and a line per session.
+// @Description With preview=true the invoices are calculated and returned without being stored. Sessions invoiced by an earlier run are skipped, so a run can be repeated safely and resumes one that was interrupted.
+// @Tags invoices
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body service.InvoiceBatchRequest true "Period and options"
+// @Success 200 {object} service.InvoiceBatchRun
+// @Failure 400 {object} ErrorResponse "Invalid period"
+// @Router /billing/invoices/batch [post]
+func (h *InvoiceBatchHandler) RunBatch(c *gin.Context) {
+	var req service.InvoiceBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	result, err := h.batchService.Run(c.Request.Context(), &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
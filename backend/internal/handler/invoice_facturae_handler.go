@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InvoiceFacturaeHandler handles Facturae e-invoice HTTP requests
+type InvoiceFacturaeHandler struct {
+	facturaeService service.FacturaeService
+}
+
+// NewInvoiceFacturaeHandler creates a new Facturae handler
+func NewInvoiceFacturaeHandler(facturaeService service.FacturaeService) *InvoiceFacturaeHandler {
+	return &InvoiceFacturaeHandler{
+		facturaeService: facturaeService,
+	}
+}
+
+// GetInvoiceFacturae godoc
+// @Summary Download an invoice as Facturae XML
+// @Description Export an issued invoice in the Facturae 3.2.2 format for FACe and other e-invoice platforms. When a certificate is configured the document is signed with XAdES-EPES and sent as .xsig.
+// @Tags invoices
+// @Security BearerAuth
+// @Produce application/xml
+// @Param id path string true "Invoice ID (UUID)"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse "Invalid ID, draft invoice or incomplete fiscal data"
+// @Failure 404 {object} ErrorResponse "Invoice not found"
+// @Router /billing/invoices/{id}/facturae [get]
+func (h *InvoiceFacturaeHandler) GetInvoiceFacturae(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid invoice ID"})
+		return
+	}
+
+	facturae, err := h.facturaeService.GetInvoiceFacturae(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", strconv.Quote(facturae.FileName)))
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, "application/xml", facturae.Content)
+}
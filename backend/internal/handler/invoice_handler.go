@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -26,12 +27,14 @@ func NewInvoiceHandler(invoiceService service.InvoiceService) *InvoiceHandler {
 
 // CreateInvoice godoc
 // @Summary Create a new invoice
-// @Description Create a new invoice with automatic VAT calculation (21%)
+// @Description Create a new invoice from its lines, computing VAT per rate (21/10/4/0 or exempt) and IRPF withholding.
+// @Description The invoice is issued immediately unless draft=true; drafts stay editable until they are issued.
 // @Tags invoices
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param request body service.CreateInvoiceRequest true "Invoice creation request"
+// @Param draft query bool false "Create as an editable draft without number"
 // @Success 201 {object} domain.Invoice
 // @Failure 400 {object} ErrorResponse "Invalid request or validation error"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
@@ -43,7 +46,51 @@ func (h *InvoiceHandler) CreateInvoice(c *gin.Context) {
 		return
 	}
 
-	invoice, err := h.invoiceService.CreateInvoice(c.Request.Context(), &req)
+	create := h.invoiceService.CreateInvoice
+	if draft, _ := strconv.ParseBool(c.Query("draft")); draft {
+		create = h.invoiceService.CreateDraftInvoice
+	}
+
+	invoice, err := create(c.Request.Context(), &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invoice)
+}
+
+// CreateInvoiceFromAppointment godoc
+// @Summary Invoice an appointment
+// @Description Invoice an appointment at the price of its service type or, when it has none, at the session rate of its employee.
+// @Description An appointment is invoiced once; cancelled and missed appointments are charged through their fees instead.
+// @Tags invoices
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param appointmentId path string true "Appointment ID (UUID)"
+// @Param request body service.CreateInvoiceFromAppointmentRequest false "Price override and notes"
+// @Param draft query bool false "Create as an editable draft without number"
+// @Success 201 {object} domain.Invoice
+// @Failure 400 {object} ErrorResponse "Invalid request or appointment without a price"
+// @Failure 404 {object} ErrorResponse "Appointment not found"
+// @Failure 409 {object} ErrorResponse "Appointment already invoiced"
+// @Router /billing/invoices/appointment/{appointmentId} [post]
+func (h *InvoiceHandler) CreateInvoiceFromAppointment(c *gin.Context) {
+	appointmentID, err := uuid.Parse(c.Param("appointmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid appointment ID"})
+		return
+	}
+
+	var req service.CreateInvoiceFromAppointmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	req.Draft, _ = strconv.ParseBool(c.Query("draft"))
+
+	invoice, err := h.invoiceService.CreateInvoiceFromAppointment(c.Request.Context(), appointmentID, &req)
 	if err != nil {
 		handleError(c, err)
 		return
@@ -52,6 +99,33 @@ func (h *InvoiceHandler) CreateInvoice(c *gin.Context) {
 	c.JSON(http.StatusCreated, invoice)
 }
 
+// GetAppointmentInvoice godoc
+// @Summary Get the invoice of an appointment
+// @Description Retrieve the invoice created for an appointment
+// @Tags invoices
+// @Security BearerAuth
+// @Produce json
+// @Param appointmentId path string true "Appointment ID (UUID)"
+// @Success 200 {object} domain.Invoice
+// @Failure 400 {object} ErrorResponse "Invalid appointment ID"
+// @Failure 404 {object} ErrorResponse "Appointment not invoiced"
+// @Router /billing/invoices/appointment/{appointmentId} [get]
+func (h *InvoiceHandler) GetAppointmentInvoice(c *gin.Context) {
+	appointmentID, err := uuid.Parse(c.Param("appointmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid appointment ID"})
+		return
+	}
+
+	invoice, err := h.invoiceService.GetAppointmentInvoice(c.Request.Context(), appointmentID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invoice)
+}
+
 // GetInvoice godoc
 // @Summary Get an invoice by ID
 // @Description Retrieve an invoice by its ID
@@ -108,7 +182,9 @@ func (h *InvoiceHandler) GetInvoiceByNumber(c *gin.Context) {
 // @Tags invoices
 // @Security BearerAuth
 // @Produce json
-// @Param status query string false "Invoice status (paid/unpaid)"
+// @Param status query string false "Invoice status (draft/unpaid/partially_paid/paid)"
+// @Param invoiceType query string false "Invoice type (ordinary/rectifying/simplified)"
+// @Param seriesId query string false "Numbering series ID (UUID)"
 // @Param clientId query string false "Client ID (UUID)"
 // @Param fromDate query string false "From date (YYYY-MM-DD)"
 // @Param toDate query string false "To date (YYYY-MM-DD)"
@@ -143,6 +219,19 @@ func (h *InvoiceHandler) ListInvoices(c *gin.Context) {
 		filters.Status = &status
 	}
 
+	// Parse invoiceType
+	if typeStr := c.Query("invoiceType"); typeStr != "" {
+		invoiceType := domain.InvoiceType(typeStr)
+		filters.InvoiceType = &invoiceType
+	}
+
+	// Parse seriesId
+	if seriesIDStr := c.Query("seriesId"); seriesIDStr != "" {
+		if seriesID, err := uuid.Parse(seriesIDStr); err == nil {
+			filters.SeriesID = &seriesID
+		}
+	}
+
 	// Parse clientId
 	if clientIDStr := c.Query("clientId"); clientIDStr != "" {
 		if clientID, err := uuid.Parse(clientIDStr); err == nil {
@@ -182,8 +271,8 @@ func (h *InvoiceHandler) ListInvoices(c *gin.Context) {
 }
 
 // UpdateInvoice godoc
-// @Summary Update an invoice
-// @Description Update an existing invoice (cannot update paid invoices)
+// @Summary Update a draft invoice
+// @Description Update a draft invoice; issued invoices are immutable and are corrected with a rectifying invoice
 // @Tags invoices
 // @Security BearerAuth
 // @Accept json
@@ -218,8 +307,8 @@ func (h *InvoiceHandler) UpdateInvoice(c *gin.Context) {
 }
 
 // DeleteInvoice godoc
-// @Summary Delete an invoice
-// @Description Soft delete an invoice (cannot delete paid invoices)
+// @Summary Delete a draft invoice
+// @Description Soft delete a draft invoice; issued invoices cannot be deleted, only cancelled with a rectifying invoice
 // @Tags invoices
 // @Security BearerAuth
 // @Param id path string true "Invoice ID (UUID)"
@@ -243,18 +332,18 @@ func (h *InvoiceHandler) DeleteInvoice(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-// MarkInvoiceAsPaid godoc
-// @Summary Mark an invoice as paid
-// @Description Update invoice status to paid
+// IssueInvoice godoc
+// @Summary Issue a draft invoice
+// @Description Assign the next invoice number to a draft invoice and issue it as unpaid
 // @Tags invoices
 // @Security BearerAuth
 // @Produce json
 // @Param id path string true "Invoice ID (UUID)"
 // @Success 200 {object} domain.Invoice
-// @Failure 400 {object} ErrorResponse "Invalid ID or already paid"
+// @Failure 400 {object} ErrorResponse "Invalid ID or invoice is not a draft"
 // @Failure 404 {object} ErrorResponse "Invoice not found"
-// @Router /billing/invoices/{id}/mark-paid [post]
-func (h *InvoiceHandler) MarkInvoiceAsPaid(c *gin.Context) {
+// @Router /billing/invoices/{id}/issue [post]
+func (h *InvoiceHandler) IssueInvoice(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := uuid.Parse(idParam)
 	if err != nil {
@@ -262,7 +351,7 @@ func (h *InvoiceHandler) MarkInvoiceAsPaid(c *gin.Context) {
 		return
 	}
 
-	invoice, err := h.invoiceService.MarkAsPaid(c.Request.Context(), id)
+	invoice, err := h.invoiceService.IssueInvoice(c.Request.Context(), id)
 	if err != nil {
 		handleError(c, err)
 		return
@@ -271,6 +360,68 @@ func (h *InvoiceHandler) MarkInvoiceAsPaid(c *gin.Context) {
 	c.JSON(http.StatusOK, invoice)
 }
 
+// CreateRectifyingInvoice godoc
+// @Summary Rectify an issued invoice
+// @Description Create a draft rectifying invoice (factura rectificativa) referencing an issued invoice, either cancelling it
+// @Description entirely or adding correcting lines (negative quantities or prices subtract). It is numbered in the R series when issued.
+// @Tags invoices
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Invoice ID (UUID)"
+// @Param request body service.CreateRectifyingInvoiceRequest true "Rectification request"
+// @Success 201 {object} domain.Invoice
+// @Failure 400 {object} ErrorResponse "Invalid request, invoice not issued or rectification exceeding the original"
+// @Failure 404 {object} ErrorResponse "Invoice not found"
+// @Router /billing/invoices/{id}/rectify [post]
+func (h *InvoiceHandler) CreateRectifyingInvoice(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid invoice ID"})
+		return
+	}
+
+	var req service.CreateRectifyingInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	invoice, err := h.invoiceService.CreateRectifyingInvoice(c.Request.Context(), id, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invoice)
+}
+
+// GetRectifyingInvoices godoc
+// @Summary List the rectifying invoices of an invoice
+// @Description Retrieve the rectifying invoices (drafts included) that correct an invoice
+// @Tags invoices
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Invoice ID (UUID)"
+// @Success 200 {array} domain.Invoice
+// @Failure 404 {object} ErrorResponse "Invoice not found"
+// @Router /billing/invoices/{id}/rectifications [get]
+func (h *InvoiceHandler) GetRectifyingInvoices(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid invoice ID"})
+		return
+	}
+
+	invoices, err := h.invoiceService.GetRectifyingInvoices(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invoices)
+}
+
 // GetClientInvoices godoc
 // @Summary Get all invoices for a client
 // @Description Retrieve all invoices for a specific client
@@ -301,7 +452,7 @@ func (h *InvoiceHandler) GetClientInvoices(c *gin.Context) {
 
 // GetUnpaidInvoices godoc
 // @Summary Get all unpaid invoices
-// @Description Retrieve all invoices with unpaid status
+// @Description Retrieve the issued invoices with an outstanding balance (unpaid or partially paid)
 // @Tags invoices
 // @Security BearerAuth
 // @Produce json
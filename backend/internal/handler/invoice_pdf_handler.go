@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InvoicePDFHandler handles invoice PDF HTTP requests
+type InvoicePDFHandler struct {
+	pdfService service.InvoicePDFService
+}
+
+// NewInvoicePDFHandler creates a new invoice PDF handler
+func NewInvoicePDFHandler(pdfService service.InvoicePDFService) *InvoicePDFHandler {
+	return &InvoicePDFHandler{
+		pdfService: pdfService,
+	}
+}
+
+// GetInvoicePDF godoc
+// @Summary Download an invoice as PDF
+// @Description Render the invoice with the clinic's template. The PDF is cached and regenerated whenever the invoice or the template changes.
+// @Tags invoices
+// @Security BearerAuth
+// @Produce application/pdf
+// @Param id path string true "Invoice ID (UUID)"
+// @Param download query bool false "Send as attachment instead of inline"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse "Invalid ID or incomplete fiscal data"
+// @Failure 404 {object} ErrorResponse "Invoice not found"
+// @Router /billing/invoices/{id}/pdf [get]
+func (h *InvoicePDFHandler) GetInvoicePDF(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid invoice ID"})
+		return
+	}
+
+	invoicePDF, err := h.pdfService.GetInvoicePDF(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	disposition := "inline"
+	if download, _ := strconv.ParseBool(c.Query("download")); download {
+		disposition = "attachment"
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=%s", disposition, strconv.Quote(invoicePDF.FileName)))
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, "application/pdf", invoicePDF.Content)
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InvoiceRecordHandler handles VeriFactu invoice record HTTP requests
+type InvoiceRecordHandler struct {
+	recordService service.InvoiceRecordService
+}
+
+// NewInvoiceRecordHandler creates a new invoice record handler
+func NewInvoiceRecordHandler(recordService service.InvoiceRecordService) *InvoiceRecordHandler {
+	return &InvoiceRecordHandler{
+		recordService: recordService,
+	}
+}
+
+// GetInvoiceRecords godoc
+// @Summary Get the records of an invoice
+// @Description Retrieve the VeriFactu registration and cancellation records of an invoice, with their submission status
+// @Tags verifactu
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Invoice ID (UUID)"
+// @Success 200 {array} domain.InvoiceRecord
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Invoice not found"
+// @Router /billing/invoices/{id}/records [get]
+func (h *InvoiceRecordHandler) GetInvoiceRecords(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid invoice ID"})
+		return
+	}
+
+	records, err := h.recordService.GetInvoiceRecords(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, records)
+}
+
+// VerifyChain godoc
+// @Summary Verify the invoice record chain
+// @Description Recompute every hash, link and signature of the chain and list issued invoices without a record
+// @Tags verifactu
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} service.InvoiceChainVerification
+// @Router /billing/verifactu/verify [get]
+func (h *InvoiceRecordHandler) VerifyChain(c *gin.Context) {
+	result, err := h.recordService.VerifyChain(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// SubmitPending godoc
+// @Summary Submit pending records to the tax agency
+// @Description Send the records not yet transmitted, in chain order; delivery failures are retried on the next run
+// @Tags verifactu
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} service.InvoiceRecordSubmission
+// @Router /billing/verifactu/submit [post]
+func (h *InvoiceRecordHandler) SubmitPending(c *gin.Context) {
+	result, err := h.recordService.SubmitPending(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InvoiceSeriesHandler handles invoice numbering series HTTP requests
+type InvoiceSeriesHandler struct {
+	seriesService service.InvoiceSeriesService
+}
+
+// NewInvoiceSeriesHandler creates a new invoice series handler
+func NewInvoiceSeriesHandler(seriesService service.InvoiceSeriesService) *InvoiceSeriesHandler {
+	return &InvoiceSeriesHandler{
+		seriesService: seriesService,
+	}
+}
+
+// CreateSeries godoc
+// @Summary Create an invoice series
+// @Description Add a numbering series (ordinary, rectifying or simplified, optionally for a location)
+// @Tags invoice-series
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body service.CreateInvoiceSeriesRequest true "Series creation request"
+// @Success 201 {object} domain.InvoiceSeries
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Failure 409 {object} ErrorResponse "Prefix already exists"
+// @Router /billing/series [post]
+func (h *InvoiceSeriesHandler) CreateSeries(c *gin.Context) {
+	var req service.CreateInvoiceSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	series, err := h.seriesService.CreateSeries(c.Request.Context(), &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, series)
+}
+
+// GetSeries godoc
+// @Summary Get an invoice series by ID
+// @Description Retrieve a numbering series
+// @Tags invoice-series
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Series ID (UUID)"
+// @Success 200 {object} domain.InvoiceSeries
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Series not found"
+// @Router /billing/series/{id} [get]
+func (h *InvoiceSeriesHandler) GetSeries(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid series ID"})
+		return
+	}
+
+	series, err := h.seriesService.GetSeries(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// ListSeries godoc
+// @Summary List invoice series
+// @Description List the numbering series
+// @Tags invoice-series
+// @Security BearerAuth
+// @Produce json
+// @Param activeOnly query bool false "Only active series" default(false)
+// @Success 200 {array} domain.InvoiceSeries
+// @Router /billing/series [get]
+func (h *InvoiceSeriesHandler) ListSeries(c *gin.Context) {
+	activeOnly := c.Query("activeOnly") == "true"
+
+	series, err := h.seriesService.ListSeries(c.Request.Context(), activeOnly)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// UpdateSeries godoc
+// @Summary Update an invoice series
+// @Description Rename, relocate, make default or deactivate a series (the number format cannot change)
+// @Tags invoice-series
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Series ID (UUID)"
+// @Param request body service.UpdateInvoiceSeriesRequest true "Series update request"
+// @Success 200 {object} domain.InvoiceSeries
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Failure 404 {object} ErrorResponse "Series not found"
+// @Router /billing/series/{id} [put]
+func (h *InvoiceSeriesHandler) UpdateSeries(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid series ID"})
+		return
+	}
+
+	var req service.UpdateInvoiceSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	series, err := h.seriesService.UpdateSeries(c.Request.Context(), id, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
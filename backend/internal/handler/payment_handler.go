@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PaymentHandler handles invoice payment HTTP requests
+type PaymentHandler struct {
+	paymentService service.PaymentService
+}
+
+// NewPaymentHandler creates a new payment handler
+func NewPaymentHandler(paymentService service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{
+		paymentService: paymentService,
+	}
+}
+
+// ListInvoicePayments godoc
+// @Summary List the payments of an invoice
+// @Description Get the payments recorded for an invoice, including reversed ones, oldest first
+// @Tags payments
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Invoice ID (UUID)"
+// @Success 200 {array} domain.Payment
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Invoice not found"
+// @Router /billing/invoices/{id}/payments [get]
+func (h *PaymentHandler) ListInvoicePayments(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid invoice ID"})
+		return
+	}
+
+	payments, err := h.paymentService.ListInvoicePayments(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payments)
+}
+
+// RecordPayment godoc
+// @Summary Record a payment of an invoice
+// @Description Record a full or partial payment of an issued invoice. The invoice becomes partially paid or paid depending on its outstanding balance, which a payment cannot exceed.
+// @Tags payments
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Invoice ID (UUID)"
+// @Param request body service.RecordPaymentRequest true "Payment"
+// @Success 201 {object} service.InvoicePayment
+// @Failure 400 {object} ErrorResponse "Invalid request, draft invoice or amount above the outstanding balance"
+// @Failure 404 {object} ErrorResponse "Invoice not found"
+// @Router /billing/invoices/{id}/payments [post]
+func (h *PaymentHandler) RecordPayment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid invoice ID"})
+		return
+	}
+
+	var req service.RecordPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	result, err := h.paymentService.RecordPayment(c.Request.Context(), id, &req, userID.(uuid.UUID))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// MarkInvoiceAsPaid godoc
+// @Summary Mark an invoice as paid
+// @Description Record a payment of the whole outstanding balance of an issued invoice. The body is optional; the method defaults to other and the date to today.
+// @Tags invoices
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Invoice ID (UUID)"
+// @Param request body service.SettleInvoiceRequest false "Payment details"
+// @Success 200 {object} domain.Invoice
+// @Failure 400 {object} ErrorResponse "Invalid ID, draft invoice or already paid"
+// @Failure 404 {object} ErrorResponse "Invoice not found"
+// @Router /billing/invoices/{id}/mark-paid [post]
+func (h *PaymentHandler) MarkInvoiceAsPaid(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid invoice ID"})
+		return
+	}
+
+	var req service.SettleInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	invoice, err := h.paymentService.SettleInvoice(c.Request.Context(), id, &req, userID.(uuid.UUID))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invoice)
+}
+
+// ReversePayment godoc
+// @Summary Reverse a payment
+// @Description Reverse a payment recorded by mistake or returned by the bank. The payment is kept for the audit trail and the invoice balance and status are recomputed.
+// @Tags payments
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Payment ID (UUID)"
+// @Param request body service.ReversePaymentRequest true "Reversal"
+// @Success 200 {object} service.InvoicePayment
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 404 {object} ErrorResponse "Payment not found"
+// @Failure 409 {object} ErrorResponse "Payment already reversed"
+// @Router /billing/payments/{id}/reverse [post]
+func (h *PaymentHandler) ReversePayment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid payment ID"})
+		return
+	}
+
+	var req service.ReversePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	result, err := h.paymentService.ReversePayment(c.Request.Context(), id, &req, userID.(uuid.UUID))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPaymentService is a mock implementation of PaymentService
+type MockPaymentService struct {
+	mock.Mock
+}
+
+func (m *MockPaymentService) RecordPayment(ctx context.Context, invoiceID uuid.UUID, req *service.RecordPaymentRequest, recordedBy uuid.UUID) (*service.InvoicePayment, error) {
+	args := m.Called(ctx, invoiceID, req, recordedBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.InvoicePayment), args.Error(1)
+}
+
+func (m *MockPaymentService) SettleInvoice(ctx context.Context, invoiceID uuid.UUID, req *service.SettleInvoiceRequest, recordedBy uuid.UUID) (*domain.Invoice, error) {
+	args := m.Called(ctx, invoiceID, req, recordedBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Invoice), args.Error(1)
+}
+
+func (m *MockPaymentService) ReversePayment(ctx context.Context, paymentID uuid.UUID, req *service.ReversePaymentRequest, reversedBy uuid.UUID) (*service.InvoicePayment, error) {
+	args := m.Called(ctx, paymentID, req, reversedBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.InvoicePayment), args.Error(1)
+}
+
+func (m *MockPaymentService) ListInvoicePayments(ctx context.Context, invoiceID uuid.UUID) ([]*domain.Payment, error) {
+	args := m.Called(ctx, invoiceID)
+	return args.Get(0).([]*domain.Payment), args.Error(1)
+}
+
+func setupPaymentTestRouter(mockService *MockPaymentService, userID uuid.UUID) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	handler := NewPaymentHandler(mockService)
+
+	// Stands in for the auth middleware
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", userID)
+		c.Next()
+	})
+	r.POST("/api/v1/billing/invoices/:id/mark-paid", handler.MarkInvoiceAsPaid)
+
+	return r
+}
+
+func TestMarkInvoiceAsPaid(t *testing.T) {
+	invoiceID := uuid.New()
+	userID := uuid.New()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantReq    *service.SettleInvoiceRequest
+		settleErr  error
+		wantStatus int
+	}{
+		{
+			// The invoices page posts without a body
+			name:       "without body",
+			wantReq:    &service.SettleInvoiceRequest{},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "with payment details",
+			body:       `{"method":"transfer","reference":"TRF-0042"}`,
+			wantReq:    &service.SettleInvoiceRequest{Method: domain.PaymentMethodTransfer, Reference: "TRF-0042"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "already paid",
+			wantReq:    &service.SettleInvoiceRequest{},
+			settleErr:  errors.NewValidationError("invoice is already paid", nil),
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockPaymentService)
+			router := setupPaymentTestRouter(mockService, userID)
+
+			if tt.settleErr != nil {
+				mockService.On("SettleInvoice", mock.Anything, invoiceID, tt.wantReq, userID).Return(nil, tt.settleErr)
+			} else {
+				paid := &domain.Invoice{ID: invoiceID, Status: domain.InvoiceStatusPaid}
+				mockService.On("SettleInvoice", mock.Anything, invoiceID, tt.wantReq, userID).Return(paid, nil)
+			}
+
+			req, _ := http.NewRequest("POST", "/api/v1/billing/invoices/"+invoiceID.String()+"/mark-paid", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				var response domain.Invoice
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, domain.InvoiceStatusPaid, response.Status)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PaymentLinkHandler handles online payment link HTTP requests and provider webhooks
+type PaymentLinkHandler struct {
+	paymentLinkService service.PaymentLinkService
+}
+
+// NewPaymentLinkHandler creates a new payment link handler
+func NewPaymentLinkHandler(paymentLinkService service.PaymentLinkService) *PaymentLinkHandler {
+	return &PaymentLinkHandler{
+		paymentLinkService: paymentLinkService,
+	}
+}
+
+// CreatePaymentLink godoc
+// @Summary Create a "pay now" link
+// @Description Create a card checkout at the payment provider for the outstanding balance of an issued invoice, or return the link still open for the same amount. With send, the link is emailed to the client. The payment is recorded when the provider confirms it through the webhook.
+// @Tags payments
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Invoice ID (UUID)"
+// @Param request body service.CreatePaymentLinkRequest false "Options"
+// @Success 201 {object} domain.PaymentLink
+// @Failure 400 {object} ErrorResponse "Draft or settled invoice, client without email or online payments not configured"
+// @Failure 404 {object} ErrorResponse "Invoice not found"
+// @Router /billing/invoices/{id}/payment-links [post]
+func (h *PaymentLinkHandler) CreatePaymentLink(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid invoice ID"})
+		return
+	}
+
+	var req service.CreatePaymentLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	link, err := h.paymentLinkService.CreatePaymentLink(c.Request.Context(), id, &req, userID.(uuid.UUID))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, link)
+}
+
+// ListInvoicePaymentLinks godoc
+// @Summary List the payment links of an invoice
+// @Description Get the "pay now" links created for an invoice with their status, newest first
+// @Tags payments
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Invoice ID (UUID)"
+// @Success 200 {array} domain.PaymentLink
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Invoice not found"
+// @Router /billing/invoices/{id}/payment-links [get]
+func (h *PaymentLinkHandler) ListInvoicePaymentLinks(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid invoice ID"})
+		return
+	}
+
+	links, err := h.paymentLinkService.ListInvoicePaymentLinks(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+// HandleWebhook godoc
+// @Summary Payment provider webhook
+// @Description Callback of the payment provider, authenticated by its signature header. Paid checkouts record a card payment on the invoice and expired ones close the link. Retried callbacks are acknowledged without recording the payment twice.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider (stripe)"
+// @Success 200 {object} map[string]bool
+// @Failure 400 {object} ErrorResponse "Malformed event"
+// @Failure 401 {object} ErrorResponse "Invalid signature"
+// @Failure 404 {object} ErrorResponse "Provider not configured"
+// @Router /webhooks/payments/{provider} [post]
+func (h *PaymentLinkHandler) HandleWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxPaymentWebhookBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: "payload too large"})
+		return
+	}
+
+	if err := h.paymentLinkService.HandleWebhook(c.Request.Context(), c.Param("provider"), payload, c.Request.Header); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
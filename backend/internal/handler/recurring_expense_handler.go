@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RecurringExpenseHandler handles recurring expense HTTP requests
+type RecurringExpenseHandler struct {
+	recurringService service.RecurringExpenseService
+}
+
+// NewRecurringExpenseHandler creates a new recurring expense handler
+func NewRecurringExpenseHandler(recurringService service.RecurringExpenseService) *RecurringExpenseHandler {
+	return &RecurringExpenseHandler{
+		recurringService: recurringService,
+	}
+}
+
+// CreateRecurringExpense godoc
+// @Summary Create a recurring expense
+// @Description Create the template of an expense repeated every month (rent, utilities, subscriptions)
+// @Tags recurring-expenses
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body service.CreateRecurringExpenseRequest true "Recurring expense creation request"
+// @Success 201 {object} domain.RecurringExpense
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Router /billing/recurring-expenses [post]
+func (h *RecurringExpenseHandler) CreateRecurringExpense(c *gin.Context) {
+	var req service.CreateRecurringExpenseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	recurring, err := h.recurringService.CreateRecurringExpense(c.Request.Context(), &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, recurring)
+}
+
+// GetRecurringExpense godoc
+// @Summary Get a recurring expense by ID
+// @Description Retrieve a recurring expense
+// @Tags recurring-expenses
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Recurring expense ID (UUID)"
+// @Success 200 {object} domain.RecurringExpense
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Recurring expense not found"
+// @Router /billing/recurring-expenses/{id} [get]
+func (h *RecurringExpenseHandler) GetRecurringExpense(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid recurring expense ID"})
+		return
+	}
+
+	recurring, err := h.recurringService.GetRecurringExpense(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, recurring)
+}
+
+// ListRecurringExpenses godoc
+// @Summary List recurring expenses
+// @Description List the recurring expenses ordered by supplier
+// @Tags recurring-expenses
+// @Security BearerAuth
+// @Produce json
+// @Param activeOnly query bool false "Only active recurring expenses" default(false)
+// @Success 200 {array} domain.RecurringExpense
+// @Router /billing/recurring-expenses [get]
+func (h *RecurringExpenseHandler) ListRecurringExpenses(c *gin.Context) {
+	activeOnly := c.Query("activeOnly") == "true"
+
+	recurring, err := h.recurringService.ListRecurringExpenses(c.Request.Context(), activeOnly)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, recurring)
+}
+
+// UpdateRecurringExpense godoc
+// @Summary Update a recurring expense
+// @Description Update a recurring expense; the expenses already generated are not changed
+// @Tags recurring-expenses
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Recurring expense ID (UUID)"
+// @Param request body service.UpdateRecurringExpenseRequest true "Recurring expense update request"
+// @Success 200 {object} domain.RecurringExpense
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Failure 404 {object} ErrorResponse "Recurring expense not found"
+// @Router /billing/recurring-expenses/{id} [put]
+func (h *RecurringExpenseHandler) UpdateRecurringExpense(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid recurring expense ID"})
+		return
+	}
+
+	var req service.UpdateRecurringExpenseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	recurring, err := h.recurringService.UpdateRecurringExpense(c.Request.Context(), id, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, recurring)
+}
+
+// DeleteRecurringExpense godoc
+// @Summary Delete a recurring expense
+// @Description Soft delete a recurring expense; the expenses already generated are kept
+// @Tags recurring-expenses
+// @Security BearerAuth
+// @Param id path string true "Recurring expense ID (UUID)"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Recurring expense not found"
+// @Router /billing/recurring-expenses/{id} [delete]
+func (h *RecurringExpenseHandler) DeleteRecurringExpense(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid recurring expense ID"})
+		return
+	}
+
+	if err := h.recurringService.DeleteRecurringExpense(c.Request.Context(), id); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListOccurrences godoc
+// @Summary List the months of a recurring expense
+// @Description List the months of a recurring expense with their status (due, adjusted, skipped or generated)
+// @Tags recurring-expenses
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Recurring expense ID (UUID)"
+// @Param from query string false "First month (YYYY-MM), the current one by default"
+// @Param to query string false "Last month (YYYY-MM), five months after the first by default"
+// @Success 200 {array} domain.RecurringExpenseOccurrence
+// @Failure 400 {object} ErrorResponse "Invalid period range"
+// @Failure 404 {object} ErrorResponse "Recurring expense not found"
+// @Router /billing/recurring-expenses/{id}/occurrences [get]
+func (h *RecurringExpenseHandler) ListOccurrences(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid recurring expense ID"})
+		return
+	}
+
+	occurrences, err := h.recurringService.ListOccurrences(c.Request.Context(), id, c.Query("from"), c.Query("to"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, occurrences)
+}
+
+// AdjustOccurrence godoc
+// @Summary Skip or adjust a month of a recurring expense
+// @Description Skip one month or change its date, amounts or notes before it is generated, without changing the template
+// @Tags recurring-expenses
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Recurring expense ID (UUID)"
+// @Param period path string true "Month (YYYY-MM)"
+// @Param request body service.AdjustOccurrenceRequest true "Skip or adjustment"
+// @Success 200 {object} domain.RecurringExpenseOccurrence
+// @Failure 400 {object} ErrorResponse "Invalid request or month without occurrence"
+// @Failure 404 {object} ErrorResponse "Recurring expense not found"
+// @Failure 409 {object} ErrorResponse "Month already generated"
+// @Router /billing/recurring-expenses/{id}/occurrences/{period} [put]
+func (h *RecurringExpenseHandler) AdjustOccurrence(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid recurring expense ID"})
+		return
+	}
+
+	var req service.AdjustOccurrenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	occurrence, err := h.recurringService.AdjustOccurrence(c.Request.Context(), id, c.Param("period"), &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, occurrence)
+}
+
+// ResetOccurrence godoc
+// @Summary Undo the skip or adjustment of a month
+// @Description Make a month of a recurring expense follow the template again
+// @Tags recurring-expenses
+// @Security BearerAuth
+// @Param id path string true "Recurring expense ID (UUID)"
+// @Param period path string true "Month (YYYY-MM)"
+// @Success 204 "No Content"
+// @Failure 404 {object} ErrorResponse "Recurring expense or adjustment not found"
+// @Failure 409 {object} ErrorResponse "Month already generated"
+// @Router /billing/recurring-expenses/{id}/occurrences/{period} [delete]
+func (h *RecurringExpenseHandler) ResetOccurrence(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid recurring expense ID"})
+		return
+	}
+
+	if err := h.recurringService.ResetOccurrence(c.Request.Context(), id, c.Param("period")); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GenerateDue godoc
+// @Summary Generate the recurring expenses due
+// @Description Create the expenses of the recurring expenses due up to today, flagged for review (also run by the scheduled job)
+// @Tags recurring-expenses
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} service.RecurringExpenseRun
+// @Router /billing/recurring-expenses/generate [post]
+func (h *RecurringExpenseHandler) GenerateDue(c *gin.Context) {
+	run, err := h.recurringService.GenerateDue(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -89,7 +90,7 @@ func TestGlobalSearch_Success(t *testing.T) {
 				ID:            invoiceID,
 				InvoiceNumber: "FAC-2025-001",
 				ClientName:    "Juan Pérez",
-				TotalAmount:   50.00,
+				TotalAmount:   money.MustParse("50.00"),
 				Status:        "paid",
 				IssueDate:     time.Now(),
 			},
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SEPAHandler handles SEPA direct debit HTTP requests
+type SEPAHandler struct {
+	sepaService service.SEPAService
+}
+
+// NewSEPAHandler creates a new SEPA direct debit handler
+func NewSEPAHandler(sepaService service.SEPAService) *SEPAHandler {
+	return &SEPAHandler{
+		sepaService: sepaService,
+	}
+}
+
+// ListClientMandates godoc
+// @Summary List the direct debit mandates of a client
+// @Description Get the SEPA mandates signed by a client, including revoked ones, newest first
+// @Tags sepa
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Client ID (UUID)"
+// @Success 200 {array} domain.SEPAMandate
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Client not found"
+// @Router /clients/{id}/sepa-mandates [get]
+func (h *SEPAHandler) ListClientMandates(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid client ID"})
+		return
+	}
+
+	mandates, err := h.sepaService.ListClientMandates(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, mandates)
+}
+
+// CreateMandate godoc
+// @Summary Register a direct debit mandate
+// @Description Register the SEPA Core mandate signed by a client. The IBAN check digits are verified; the reference is generated and the account holder is the client when not given. A client has one active mandate at a time.
+// @Tags sepa
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Client ID (UUID)"
+// @Param request body service.CreateSEPAMandateRequest true "Mandate"
+// @Success 201 {object} domain.SEPAMandate
+// @Failure 400 {object} ErrorResponse "Invalid IBAN, BIC, reference or signature date"
+// @Failure 404 {object} ErrorResponse "Client not found"
+// @Failure 409 {object} ErrorResponse "Client already has an active mandate or reference in use"
+// @Router /clients/{id}/sepa-mandates [post]
+func (h *SEPAHandler) CreateMandate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid client ID"})
+		return
+	}
+
+	var req service.CreateSEPAMandateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	mandate, err := h.sepaService.CreateMandate(c.Request.Context(), id, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, mandate)
+}
+
+// RevokeMandate godoc
+// @Summary Revoke a direct debit mandate
+// @Description Revoke a SEPA mandate; the client's invoices can no longer be collected with it
+// @Tags sepa
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Mandate ID (UUID)"
+// @Success 200 {object} domain.SEPAMandate
+// @Failure 400 {object} ErrorResponse "Invalid ID or mandate already revoked"
+// @Failure 404 {object} ErrorResponse "Mandate not found"
+// @Router /billing/sepa/mandates/{id}/revoke [post]
+func (h *SEPAHandler) RevokeMandate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid mandate ID"})
+		return
+	}
+
+	mandate, err := h.sepaService.RevokeMandate(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, mandate)
+}
+
+// ListBatches godoc
+// @Summary List direct debit batches
+// @Description Get the SEPA direct debit batches (remesas), newest first
+// @Tags sepa
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} domain.SEPABatch
+// @Router /billing/sepa/batches [get]
+func (h *SEPAHandler) ListBatches(c *gin.Context) {
+	batches, err := h.sepaService.ListBatches(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, batches)
+}
+
+// CreateBatch godoc
+// @Summary Create a direct debit batch
+// @Description Collect the outstanding balance of a set of issued invoices by SEPA direct debit on a date. Every client needs an active mandate. The invoices are recorded as paid by direct debit and the pain.008.001.02 file is kept for download.
+// @Tags sepa
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body service.CreateSEPABatchRequest true "Invoices and collection date"
+// @Success 201 {object} domain.SEPABatch
+// @Failure 400 {object} ErrorResponse "Invoices that cannot be collected, past collection date or incomplete creditor data"
+// @Router /billing/sepa/batches [post]
+func (h *SEPAHandler) CreateBatch(c *gin.Context) {
+	var req service.CreateSEPABatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	batch, err := h.sepaService.CreateBatch(c.Request.Context(), &req, userID.(uuid.UUID))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, batch)
+}
+
+// GetBatch godoc
+// @Summary Get a direct debit batch
+// @Description Get a SEPA direct debit batch with its debits
+// @Tags sepa
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Batch ID (UUID)"
+// @Success 200 {object} domain.SEPABatch
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Batch not found"
+// @Router /billing/sepa/batches/{id} [get]
+func (h *SEPAHandler) GetBatch(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid batch ID"})
+		return
+	}
+
+	batch, err := h.sepaService.GetBatch(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
+
+// DownloadBatch godoc
+// @Summary Download the file of a direct debit batch
+// @Description Download the ISO 20022 pain.008.001.02 file of a batch to upload it to the bank
+// @Tags sepa
+// @Security BearerAuth
+// @Produce xml
+// @Param id path string true "Batch ID (UUID)"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Batch not found"
+// @Router /billing/sepa/batches/{id}/file [get]
+func (h *SEPAHandler) DownloadBatch(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid batch ID"})
+		return
+	}
+
+	file, err := h.sepaService.GetBatchFile(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", strconv.Quote(file.FileName)))
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, "application/xml", file.Content)
+}
+
+// ImportReturns godoc
+// @Summary Import returned direct debits
+// @Description Upload the pain.002 return file (devoluciones) from the bank. The payment of every returned debit is reversed and its invoice reopened; debits imported before or unknown are reported.
+// @Tags sepa
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "pain.002 file"
+// @Success 200 {object} service.SEPAReturnImport
+// @Failure 400 {object} ErrorResponse "Not a pain.002 file"
+// @Failure 413 {object} ErrorResponse "File too large"
+// @Router /billing/sepa/returns [post]
+func (h *SEPAHandler) ImportReturns(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxSEPAReturnFileBytes+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("file exceeds the maximum size of %d MB", service.MaxSEPAReturnFileBytes>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "file is required"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	result, err := h.sepaService.ImportReturns(c.Request.Context(), file, userID.(uuid.UUID))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
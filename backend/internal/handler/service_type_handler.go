@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ServiceTypeHandler handles service type catalogue HTTP requests
+type ServiceTypeHandler struct {
+	serviceTypeService service.ServiceTypeService
+}
+
+// NewServiceTypeHandler creates a new service type handler
+func NewServiceTypeHandler(serviceTypeService service.ServiceTypeService) *ServiceTypeHandler {
+	return &ServiceTypeHandler{
+		serviceTypeService: serviceTypeService,
+	}
+}
+
+// CreateServiceType godoc
+// @Summary Create a service type
+// @Description Add a billable service to the catalogue
+// @Tags service-types
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body service.CreateServiceTypeRequest true "Service type creation request"
+// @Success 201 {object} domain.ServiceType
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Failure 409 {object} ErrorResponse "Name already exists"
+// @Router /billing/service-types [post]
+func (h *ServiceTypeHandler) CreateServiceType(c *gin.Context) {
+	var req service.CreateServiceTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	serviceType, err := h.serviceTypeService.CreateServiceType(c.Request.Context(), &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, serviceType)
+}
+
+// GetServiceType godoc
+// @Summary Get a service type by ID
+// @Description Retrieve a service type of the catalogue
+// @Tags service-types
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Service type ID (UUID)"
+// @Success 200 {object} domain.ServiceType
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Service type not found"
+// @Router /billing/service-types/{id} [get]
+func (h *ServiceTypeHandler) GetServiceType(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid service type ID"})
+		return
+	}
+
+	serviceType, err := h.serviceTypeService.GetServiceType(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, serviceType)
+}
+
+// ListServiceTypes godoc
+// @Summary List service types
+// @Description List the catalogue of billable services
+// @Tags service-types
+// @Security BearerAuth
+// @Produce json
+// @Param activeOnly query bool false "Only active service types" default(false)
+// @Success 200 {array} domain.ServiceType
+// @Router /billing/service-types [get]
+func (h *ServiceTypeHandler) ListServiceTypes(c *gin.Context) {
+	activeOnly := c.Query("activeOnly") == "true"
+
+	serviceTypes, err := h.serviceTypeService.ListServiceTypes(c.Request.Context(), activeOnly)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, serviceTypes)
+}
+
+// UpdateServiceType godoc
+// @Summary Update a service type
+// @Description Update a service type of the catalogue (existing invoices are not changed)
+// @Tags service-types
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Service type ID (UUID)"
+// @Param request body service.UpdateServiceTypeRequest true "Service type update request"
+// @Success 200 {object} domain.ServiceType
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Failure 404 {object} ErrorResponse "Service type not found"
+// @Router /billing/service-types/{id} [put]
+func (h *ServiceTypeHandler) UpdateServiceType(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid service type ID"})
+		return
+	}
+
+	var req service.UpdateServiceTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	serviceType, err := h.serviceTypeService.UpdateServiceType(c.Request.Context(), id, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, serviceType)
+}
+
+// DeleteServiceType godoc
+// @Summary Delete a service type
+// @Description Soft delete a service type of the catalogue
+// @Tags service-types
+// @Security BearerAuth
+// @Param id path string true "Service type ID (UUID)"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Service type not found"
+// @Router /billing/service-types/{id} [delete]
+func (h *ServiceTypeHandler) DeleteServiceType(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid service type ID"})
+		return
+	}
+
+	if err := h.serviceTypeService.DeleteServiceType(c.Request.Context(), id); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	pkgerrors "github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SessionPackHandler handles session pack (bono) HTTP requests
+type SessionPackHandler struct {
+	sessionPackService service.SessionPackService
+}
+
+// NewSessionPackHandler creates a new session pack handler
+func NewSessionPackHandler(sessionPackService service.SessionPackService) *SessionPackHandler {
+	return &SessionPackHandler{
+		sessionPackService: sessionPackService,
+	}
+}
+
+// CreateSessionPack godoc
+// @Summary Create a session pack
+// @Description Add a pack of prepaid sessions to the catalogue
+// @Tags session-packs
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body service.CreateSessionPackRequest true "Session pack creation request"
+// @Success 201 {object} domain.SessionPack
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Failure 409 {object} ErrorResponse "Name already exists"
+// @Router /billing/session-packs [post]
+func (h *SessionPackHandler) CreateSessionPack(c *gin.Context) {
+	var req service.CreateSessionPackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	pack, err := h.sessionPackService.CreateSessionPack(c.Request.Context(), &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, pack)
+}
+
+// GetSessionPack godoc
+// @Summary Get a session pack by ID
+// @Description Retrieve a session pack of the catalogue
+// @Tags session-packs
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Session pack ID (UUID)"
+// @Success 200 {object} domain.SessionPack
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Session pack not found"
+// @Router /billing/session-packs/{id} [get]
+func (h *SessionPackHandler) GetSessionPack(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid session pack ID"})
+		return
+	}
+
+	pack, err := h.sessionPackService.GetSessionPack(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pack)
+}
+
+// ListSessionPacks godoc
+// @Summary List session packs
+// @Description List the catalogue of session packs
+// @Tags session-packs
+// @Security BearerAuth
+// @Produce json
+// @Param activeOnly query bool false "Only packs still sold" default(false)
+// @Success 200 {array} domain.SessionPack
+// @Router /billing/session-packs [get]
+func (h *SessionPackHandler) ListSessionPacks(c *gin.Context) {
+	activeOnly := c.Query("activeOnly") == "true"
+
+	packs, err := h.sessionPackService.ListSessionPacks(c.Request.Context(), activeOnly)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, packs)
+}
+
+// UpdateSessionPack godoc
+// @Summary Update a session pack
+// @Description Update a session pack of the catalogue (packs already sold are not changed)
+// @Tags session-packs
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Session pack ID (UUID)"
+// @Param request body service.UpdateSessionPackRequest true "Session pack update request"
+// @Success 200 {object} domain.SessionPack
+// @Failure 400 {object} ErrorResponse "Invalid request or validation error"
+// @Failure 404 {object} ErrorResponse "Session pack not found"
+// @Router /billing/session-packs/{id} [put]
+func (h *SessionPackHandler) UpdateSessionPack(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid session pack ID"})
+		return
+	}
+
+	var req service.UpdateSessionPackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	pack, err := h.sessionPackService.UpdateSessionPack(c.Request.Context(), id, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pack)
+}
+
+// DeleteSessionPack godoc
+// @Summary Delete a session pack
+// @Description Soft delete a session pack of the catalogue; clients keep the packs they bought
+// @Tags session-packs
+// @Security BearerAuth
+// @Param id path string true "Session pack ID (UUID)"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Failure 404 {object} ErrorResponse "Session pack not found"
+// @Router /billing/session-packs/{id} [delete]
+func (h *SessionPackHandler) DeleteSessionPack(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid session pack ID"})
+		return
+	}
+
+	if err := h.sessionPackService.DeleteSessionPack(c.Request.Context(), id); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SellSessionPack godoc
+// @Summary Sell a session pack to a client
+// @Description Invoice a session pack to a client and add its sessions to the client's balance
+// @Tags session-packs
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Client ID (UUID)"
+// @Param request body service.SellSessionPackRequest true "Session pack sale"
+// @Success 201 {object} domain.ClientSessionPack
+// @Failure 400 {object} ErrorResponse "Invalid request, inactive pack or unknown client"
+// @Failure 404 {object} ErrorResponse "Session pack not found"
+// @Router /clients/{id}/session-packs [post]
+func (h *SessionPackHandler) SellSessionPack(c *gin.Context) {
+	clientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid client ID"})
+		return
+	}
+
+	var req service.SellSessionPackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	clientPack, err := h.sessionPackService.SellSessionPack(c.Request.Context(), clientID, &req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, clientPack)
+}
+
+// GetClientBalance godoc
+// @Summary Get the session packs of a client
+// @Description Retrieve the packs bought by a client and the sessions left, e.g. when booking
+// @Tags session-packs
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Client ID (UUID)"
+// @Success 200 {object} domain.SessionPackBalance
+// @Failure 400 {object} ErrorResponse "Invalid ID format"
+// @Router /clients/{id}/session-packs [get]
+func (h *SessionPackHandler) GetClientBalance(c *gin.Context) {
+	clientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid client ID"})
+		return
+	}
+
+	balance, err := h.sessionPackService.GetClientBalance(c.Request.Context(), clientID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
+
+// GetMyBalance godoc
+// @Summary Get my session packs
+// @Description Retrieve the packs bought by the authenticated client and the sessions left (client role only)
+// @Tags session-packs
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} domain.SessionPackBalance
+// @Failure 403 {object} map[string]string
+// @Router /clients/me/session-packs [get]
+func (h *SessionPackHandler) GetMyBalance(c *gin.Context) {
+	clientID, exists := c.Get("clientID")
+	if !exists {
+		appErr := pkgerrors.NewForbiddenError("Solo clientes pueden consultar sus bonos")
+		pkgerrors.RespondWithAppError(c, appErr)
+		return
+	}
+
+	balance, err := h.sessionPackService.GetClientBalance(c.Request.Context(), clientID.(uuid.UUID))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// TaxReportHandler handles the quarterly tax report HTTP requests
+type TaxReportHandler struct {
+	taxReportService service.TaxReportService
+}
+
+// NewTaxReportHandler creates a new tax report handler
+func NewTaxReportHandler(taxReportService service.TaxReportService) *TaxReportHandler {
+	return &TaxReportHandler{
+		taxReportService: taxReportService,
+	}
+}
+
+// GetTaxReport godoc
+// @Summary Get the boxes of a quarterly tax return
+// @Description Compute the boxes of Modelo 303 (VAT) or Modelo 130 (IRPF installment) for a quarter from the issued invoices and the expenses; Modelo 130 is cumulative from January
+// @Tags tax-reports
+// @Security BearerAuth
+// @Produce json,text/csv
+// @Param model query string true "303 or 130"
+// @Param year query int true "Year"
+// @Param quarter query int true "Quarter (1-4)"
+// @Param format query string false "json or csv" default(json)
+// @Success 200 {object} service.TaxReport
+// @Failure 400 {object} ErrorResponse "Invalid model, year or quarter"
+// @Router /billing/reports/tax [get]
+func (h *TaxReportHandler) GetTaxReport(c *gin.Context) {
+	query, ok := parseTaxReportQuery(c)
+	if !ok {
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		export, err := h.taxReportService.ExportTaxReportCSV(c.Request.Context(), query)
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+		sendCSV(c, export)
+		return
+	}
+
+	report, err := h.taxReportService.GetTaxReport(c.Request.Context(), query)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetTaxReportBox godoc
+// @Summary Drill down into a box of a quarterly tax return
+// @Description List the invoices and expenses behind the amount of a box of Modelo 303 or 130
+// @Tags tax-reports
+// @Security BearerAuth
+// @Produce json,text/csv
+// @Param box path string true "Box number, e.g. 07, or exempt for the exempt base of Modelo 303"
+// @Param model query string true "303 or 130"
+// @Param year query int true "Year"
+// @Param quarter query int true "Quarter (1-4)"
+// @Param format query string false "json or csv" default(json)
+// @Success 200 {object} service.TaxReportBox
+// @Failure 400 {object} ErrorResponse "Invalid model, year or quarter"
+// @Failure 404 {object} ErrorResponse "Box not part of the model"
+// @Router /billing/reports/tax/boxes/{box} [get]
+func (h *TaxReportHandler) GetTaxReportBox(c *gin.Context) {
+	query, ok := parseTaxReportQuery(c)
+	if !ok {
+		return
+	}
+	box := c.Param("box")
+
+	if c.Query("format") == "csv" {
+		export, err := h.taxReportService.ExportTaxReportBoxCSV(c.Request.Context(), query, box)
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+		sendCSV(c, export)
+		return
+	}
+
+	reportBox, err := h.taxReportService.GetTaxReportBox(c.Request.Context(), query, box)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reportBox)
+}
+
+// parseTaxReportQuery reads the model, year and quarter, answering 400 when they are not numbers
+func parseTaxReportQuery(c *gin.Context) (service.TaxReportQuery, bool) {
+	query := service.TaxReportQuery{Model: service.TaxModel(c.Query("model"))}
+
+	year, err := strconv.Atoi(c.Query("year"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid year"})
+		return query, false
+	}
+	quarter, err := strconv.Atoi(c.Query("quarter"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid quarter"})
+		return query, false
+	}
+
+	query.Year, query.Quarter = year, quarter
+	return query, true
+}
+
+// sendCSV sends an exported report as a CSV download
+func sendCSV(c *gin.Context, export *service.CSVExport) {
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", strconv.Quote(export.FileName)))
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", export.Content)
+}
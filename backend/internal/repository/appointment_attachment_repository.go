@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// AppointmentAttachmentRepository defines the interface for appointment attachment data access
+type AppointmentAttachmentRepository interface {
+	// Create creates a new attachment record
+	Create(ctx context.Context, attachment *domain.AppointmentAttachment) error
+
+	// GetByID retrieves an attachment by ID (excluding soft-deleted)
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.AppointmentAttachment, error)
+
+	// ListByAppointment retrieves the attachments of an appointment, oldest first
+	ListByAppointment(ctx context.Context, appointmentID uuid.UUID) ([]*domain.AppointmentAttachment, error)
+
+	// Delete soft deletes an attachment
+	Delete(ctx context.Context, id uuid.UUID) error
+}
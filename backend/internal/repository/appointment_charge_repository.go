@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// AppointmentChargeFilters contains filters for listing appointment charges
+type AppointmentChargeFilters struct {
+	Status   *domain.AppointmentChargeStatus
+	Kind     *domain.AppointmentChargeKind
+	ClientID *uuid.UUID
+	Page     int
+	PageSize int
+}
+
+// AppointmentChargeRepository defines the interface for appointment charge data access
+type AppointmentChargeRepository interface {
+	// Create creates a new appointment charge
+	Create(ctx context.Context, charge *domain.AppointmentCharge) error
+
+	// GetByID retrieves a charge by ID
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.AppointmentCharge, error)
+
+	// GetByAppointmentAndKind retrieves the charge of a given kind for an appointment
+	GetByAppointmentAndKind(ctx context.Context, appointmentID uuid.UUID, kind domain.AppointmentChargeKind) (*domain.AppointmentCharge, error)
+
+	// List retrieves a paginated list of charges with filters
+	List(ctx context.Context, filters AppointmentChargeFilters) ([]*domain.AppointmentCharge, int, error)
+
+	// Update updates an existing charge
+	Update(ctx context.Context, charge *domain.AppointmentCharge) error
+}
@@ -51,4 +51,11 @@ type AppointmentRepository interface {
 
 	// UpdateStatus updates only the status of an appointment
 	UpdateStatus(ctx context.Context, id uuid.UUID, status domain.AppointmentStatus) error
+
+	// ListUninvoiced retrieves the completed appointments starting within [from, to) that no
+	// invoice bills yet (an invoice cancelled entirely by rectifying invoices no longer bills
+	// them) and that were not paid with a session pack, ordered by client and start time.
+	// Sessions covered by an insurer come with the CopayAmount left to the client; those
+	// without a co-payment are left out
+	ListUninvoiced(ctx context.Context, from, to time.Time) ([]*domain.Appointment, error)
 }
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// BankMovementFilters contains filters for listing bank movements
+type BankMovementFilters struct {
+	StatementID *uuid.UUID
+	Status      *domain.BankMovementStatus
+}
+
+// BankStatementRepository defines the interface for bank statement and reconciliation data access
+type BankStatementRepository interface {
+	// Create stores a statement with its movements; fails with
+	// domain.ErrStatementAlreadyImported when the same file was imported before
+	Create(ctx context.Context, statement *domain.BankStatement) error
+
+	// GetByID retrieves a statement with its movements
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.BankStatement, error)
+
+	// List retrieves the statements without their movements, newest first
+	List(ctx context.Context) ([]*domain.BankStatement, error)
+
+	// GetMovement retrieves a bank movement by ID
+	GetMovement(ctx context.Context, id uuid.UUID) (*domain.BankMovement, error)
+
+	// ListMovements retrieves bank movements ordered by booking date
+	ListMovements(ctx context.Context, filters BankMovementFilters) ([]*domain.BankMovement, error)
+
+	// ListUnreconciledExpenses retrieves the expenses dated between two dates that no bank
+	// movement has been reconciled with
+	ListUnreconciledExpenses(ctx context.Context, fromDate, toDate time.Time) ([]*domain.Expense, error)
+
+	// ReconcileInvoice records the payment of an incoming movement against its locked invoice
+	// and marks the movement as reconciled. Returns the updated invoice, or
+	// domain.ErrMovementNotPending when the movement was reconciled meanwhile.
+	ReconcileInvoice(ctx context.Context, movement *domain.BankMovement, payment *domain.Payment) (*domain.Invoice, error)
+
+	// ReconcileExpense marks an outgoing movement as reconciled with its expense; fails with
+	// domain.ErrExpenseAlreadyReconciled when another movement paid the expense
+	ReconcileExpense(ctx context.Context, movement *domain.BankMovement) error
+
+	// IgnoreMovement takes a pending movement out of the reconciliation queue
+	IgnoreMovement(ctx context.Context, movement *domain.BankMovement) error
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// BillingProfileFilters represents filters for listing billing profiles
+type BillingProfileFilters struct {
+	Search     string // Name or NIF/CIF
+	ActiveOnly bool
+}
+
+// BillingProfileRepository defines the interface for billing profiles and the clients they pay for
+type BillingProfileRepository interface {
+	// Create creates a new billing profile
+	Create(ctx context.Context, profile *domain.BillingProfile) error
+
+	// GetByID retrieves a billing profile by ID (excluding soft-deleted)
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.BillingProfile, error)
+
+	// List retrieves the billing profiles matching the filters, ordered by name
+	List(ctx context.Context, filters BillingProfileFilters) ([]*domain.BillingProfile, error)
+
+	// Update updates an existing billing profile
+	Update(ctx context.Context, profile *domain.BillingProfile) error
+
+	// Delete soft deletes a billing profile and unlinks it from its clients
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// GetClientLink retrieves the link between a client and a billing profile
+	GetClientLink(ctx context.Context, clientID, profileID uuid.UUID) (*domain.ClientBillingProfile, error)
+
+	// ListByClient retrieves the links of a client with their profiles, the default one first
+	ListByClient(ctx context.Context, clientID uuid.UUID) ([]*domain.ClientBillingProfile, error)
+
+	// GetDefaultForClient retrieves the default payer of a client
+	GetDefaultForClient(ctx context.Context, clientID uuid.UUID) (*domain.BillingProfile, error)
+
+	// LinkClient creates or updates the link between a client and a billing profile. A
+	// default link replaces the previous default payer of the client.
+	LinkClient(ctx context.Context, link *domain.ClientBillingProfile) error
+
+	// UnlinkClient removes the link between a client and a billing profile
+	UnlinkClient(ctx context.Context, clientID, profileID uuid.UUID) error
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+)
+
+// BillingSettingsRepository defines the interface for the billing settings (single row)
+type BillingSettingsRepository interface {
+	// Get retrieves the billing settings
+	Get(ctx context.Context) (*domain.BillingSettings, error)
+
+	// Update stores the billing settings
+	Update(ctx context.Context, settings *domain.BillingSettings) error
+}
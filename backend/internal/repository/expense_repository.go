@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -15,6 +16,7 @@ type ExpenseFilters struct {
 	FromDate      *time.Time
 	ToDate        *time.Time
 	HasInvoice    *bool
+	NeedsReview   *bool // Generated from a recurring expense and not reviewed yet
 	Supplier      string
 	Search        string
 	Page          int
@@ -42,11 +44,28 @@ type ExpenseRepository interface {
 	GetByCategory(ctx context.Context, categoryID uuid.UUID) ([]*domain.Expense, error)
 
 	// GetTotalByDateRange calculates total expenses between dates
-	GetTotalByDateRange(ctx context.Context, fromDate, toDate time.Time) (float64, error)
+	GetTotalByDateRange(ctx context.Context, fromDate, toDate time.Time) (money.Money, error)
 
-	// GetTotalByCategory calculates total expenses by category in a date range
-	GetTotalByCategory(ctx context.Context, fromDate, toDate time.Time) (map[uuid.UUID]float64, error)
+	// GetTotalByCategory calculates total expenses by category in a date range, with the
+	// category names, largest first
+	GetTotalByCategory(ctx context.Context, fromDate, toDate time.Time) ([]CategoryTotal, error)
+
+	// GetTotalByPeriod sums the expenses between dates, grouped by the period of their date
+	GetTotalByPeriod(ctx context.Context, granularity PeriodGranularity, fromDate, toDate time.Time) ([]PeriodTotal, error)
+
+	// ListByDateRange retrieves the expenses dated between two dates, oldest first
+	ListByDateRange(ctx context.Context, fromDate, toDate time.Time) ([]*domain.Expense, error)
 
 	// GetBySupplier retrieves expenses by supplier name
 	GetBySupplier(ctx context.Context, supplier string) ([]*domain.Expense, error)
+
+	// MarkReviewed clears the review flag of a generated expense
+	MarkReviewed(ctx context.Context, id uuid.UUID) error
+
+	// UpdateAttachment sets or clears the receipt of an expense; Update leaves it unchanged
+	UpdateAttachment(ctx context.Context, expense *domain.Expense) error
+
+	// ListByAttachmentHash retrieves the expenses whose receipt has the given SHA-256,
+	// including soft-deleted ones, which still reference the stored file
+	ListByAttachmentHash(ctx context.Context, sha256 string) ([]*domain.Expense, error)
 }
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// InsurerRepository defines the interface for insurers, the policies of the clients, their
+// authorisations and the sessions they cover
+type InsurerRepository interface {
+	// Create creates a new insurer
+	Create(ctx context.Context, insurer *domain.Insurer) error
+
+	// GetByID retrieves an insurer by ID (excluding soft-deleted)
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Insurer, error)
+
+	// List retrieves insurers ordered by name, optionally only active ones
+	List(ctx context.Context, activeOnly bool) ([]*domain.Insurer, error)
+
+	// Update updates an existing insurer
+	Update(ctx context.Context, insurer *domain.Insurer) error
+
+	// Delete soft deletes an insurer; the policies of its clients are kept
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// CreatePolicy creates a new policy of a client
+	CreatePolicy(ctx context.Context, policy *domain.InsurancePolicy) error
+
+	// GetPolicy retrieves a policy by ID (excluding soft-deleted)
+	GetPolicy(ctx context.Context, id uuid.UUID) (*domain.InsurancePolicy, error)
+
+	// ListClientPolicies retrieves the policies of a client with their insurer and
+	// authorisations, newest first
+	ListClientPolicies(ctx context.Context, clientID uuid.UUID) ([]*domain.InsurancePolicy, error)
+
+	// UpdatePolicy updates an existing policy
+	UpdatePolicy(ctx context.Context, policy *domain.InsurancePolicy) error
+
+	// DeletePolicy soft deletes a policy
+	DeletePolicy(ctx context.Context, id uuid.UUID) error
+
+	// CreateAuthorisation creates a new authorisation of a policy
+	CreateAuthorisation(ctx context.Context, authorisation *domain.InsuranceAuthorisation) error
+
+	// GetAuthorisation retrieves an authorisation by ID
+	GetAuthorisation(ctx context.Context, id uuid.UUID) (*domain.InsuranceAuthorisation, error)
+
+	// UpdateAuthorisation updates an existing authorisation; the sessions used are kept
+	UpdateAuthorisation(ctx context.Context, authorisation *domain.InsuranceAuthorisation) error
+
+	// DeleteAuthorisation deletes an authorisation. Returns domain.ErrAuthorisationInUse when
+	// it already covered sessions.
+	DeleteAuthorisation(ctx context.Context, id uuid.UUID) error
+
+	// CoverSession takes one session for an appointment from the authorisation that expires
+	// first among those of the client's active policies with active insurers, covering its
+	// service type and valid on its date. The authorisation is locked while its balance
+	// changes; the insurer and co-payment amounts are those of the insurer and the policy.
+	// Returns domain.ErrNoUsableAuthorisation when there is none and
+	// domain.ErrSessionAlreadyCovered when the appointment is already covered.
+	CoverSession(ctx context.Context, appointment *domain.Appointment, session *domain.CoveredSession) (*domain.InsuranceAuthorisation, error)
+
+	// GetCoveredSession retrieves the covered session of an appointment
+	GetCoveredSession(ctx context.Context, appointmentID uuid.UUID) (*domain.CoveredSession, error)
+
+	// ListUnsettled retrieves the sessions of an insurer up to a day (inclusive) not included
+	// in a settlement invoice yet, or whose invoice was deleted, ordered by date and client
+	ListUnsettled(ctx context.Context, insurerID uuid.UUID, until time.Time) ([]*domain.CoveredSession, error)
+
+	// SetSettlementInvoice records the settlement invoice including some sessions
+	SetSettlementInvoice(ctx context.Context, sessionIDs []uuid.UUID, invoiceID uuid.UUID) error
+}
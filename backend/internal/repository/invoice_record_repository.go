@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// InvoiceRecordRepository defines the interface for the append-only invoice record chain
+type InvoiceRecordRepository interface {
+	// Append adds a record at the end of the chain. Appends are serialised: seal is called
+	// with the last record (nil when the chain is empty) to link the new record before it is stored.
+	Append(ctx context.Context, record *domain.InvoiceRecord, seal func(previous *domain.InvoiceRecord)) error
+
+	// GetByInvoiceID retrieves the records of an invoice in chain order
+	GetByInvoiceID(ctx context.Context, invoiceID uuid.UUID) ([]*domain.InvoiceRecord, error)
+
+	// ListChain retrieves the records in chain order, starting after the given sequence
+	ListChain(ctx context.Context, afterSequence int64, limit int) ([]*domain.InvoiceRecord, error)
+
+	// GetPendingSubmission retrieves the oldest records not yet sent to the tax agency
+	GetPendingSubmission(ctx context.Context, limit int) ([]*domain.InvoiceRecord, error)
+
+	// UpdateSubmission stores the transmission result of a record
+	UpdateSubmission(ctx context.Context, record *domain.InvoiceRecord) error
+
+	// GetUnrecordedInvoiceIDs retrieves the invoices issued since the given date that have no registration record
+	GetUnrecordedInvoiceIDs(ctx context.Context, since time.Time) ([]uuid.UUID, error)
+}
+
+// InvoiceIssueRecorder builds the records of an invoice being issued. It is called inside the
+// issuing transaction, once the invoice is numbered, and must not access the database.
+type InvoiceIssueRecorder interface {
+	// IssueRecords builds the unchained records of the issue, in chain order
+	IssueRecords(invoice *domain.Invoice) ([]*domain.InvoiceRecord, error)
+
+	// SealRecord links a record to the last record of the chain (nil when the chain is empty)
+	SealRecord(record, previous *domain.InvoiceRecord)
+}
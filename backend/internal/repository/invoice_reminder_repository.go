@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// DunningInvoice is an overdue invoice together with the number of reminders already sent
+type DunningInvoice struct {
+	domain.Invoice
+	RemindersSent int `db:"reminders_sent"`
+}
+
+// InvoiceReminderRepository defines the interface for overdue invoices and their payment reminders
+type InvoiceReminderRepository interface {
+	// MarkOverdue flags the issued invoices with a balance outstanding whose due date is
+	// before today, and returns how many were flagged
+	MarkOverdue(ctx context.Context, today time.Time) (int, error)
+
+	// ListOverdue retrieves the overdue invoices with a balance outstanding, oldest due date first
+	ListOverdue(ctx context.Context) ([]*domain.Invoice, error)
+
+	// ListDunning retrieves the overdue invoices with a balance outstanding whose client is
+	// not in a payment plan on the given day
+	ListDunning(ctx context.Context, today time.Time) ([]*DunningInvoice, error)
+
+	// Create records a reminder; it fails with a conflict if its level was already sent
+	Create(ctx context.Context, reminder *domain.InvoiceReminder) error
+
+	// Delete removes a reminder that could not be delivered, so that it is sent again
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// ListByInvoice retrieves the reminders sent for an invoice, first level first
+	ListByInvoice(ctx context.Context, invoiceID uuid.UUID) ([]*domain.InvoiceReminder, error)
+}
@@ -5,12 +5,15 @@ import (
 	"time"
 
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 )
 
 // InvoiceFilters contains filters for listing invoices
 type InvoiceFilters struct {
 	Status      *domain.InvoiceStatus
+	InvoiceType *domain.InvoiceType
+	SeriesID    *uuid.UUID
 	ClientID    *uuid.UUID
 	FromDate    *time.Time
 	ToDate      *time.Time
@@ -20,9 +23,17 @@ type InvoiceFilters struct {
 	IncludePaid bool
 }
 
+// OutstandingInvoiceFilters narrows the invoices with an outstanding balance
+type OutstandingInvoiceFilters struct {
+	ClientID      *uuid.UUID
+	EmployeeID    *uuid.UUID // Employee who attended an appointment billed by the invoice
+	ServiceTypeID *uuid.UUID // Service billed by a line of the invoice or by its appointment
+	AsOf          *time.Time // Balances at the end of this day instead of the current ones
+}
+
 // InvoiceRepository defines the interface for invoice data access
 type InvoiceRepository interface {
-	// Create creates a new invoice
+	// Create creates a new invoice together with its lines
 	Create(ctx context.Context, invoice *domain.Invoice) error
 
 	// GetByID retrieves an invoice by ID
@@ -34,24 +45,51 @@ type InvoiceRepository interface {
 	// List retrieves a paginated list of invoices with filters
 	List(ctx context.Context, filters InvoiceFilters) ([]*domain.Invoice, int, error)
 
-	// Update updates an existing invoice
+	// Update updates an existing invoice; when Lines is set they replace the stored lines
 	Update(ctx context.Context, invoice *domain.Invoice) error
 
-	// Delete soft deletes an invoice
+	// UpdatePDFPath records the storage key of the cached PDF without touching the invoice data
+	UpdatePDFPath(ctx context.Context, id uuid.UUID, pdfPath *string) error
+
+	// Delete soft deletes a draft invoice; issued invoices cannot be deleted
 	Delete(ctx context.Context, id uuid.UUID) error
 
-	// GetNextInvoiceNumber generates the next invoice number for the given year
-	GetNextInvoiceNumber(ctx context.Context, year int) (string, error)
+	// Issue stores an issued invoice with the next number of its series, inserting it or
+	// updating its draft. The series counter stays locked until the invoice is stored, so
+	// concurrent calls never share a number and a failed call leaves no gap. The records
+	// built by recorder, when given, are chained in the same transaction.
+	Issue(ctx context.Context, invoice *domain.Invoice, recorder InvoiceIssueRecorder) error
+
+	// GetRectifyingInvoices retrieves the rectifying invoices of an invoice, with their lines
+	GetRectifyingInvoices(ctx context.Context, invoiceID uuid.UUID) ([]*domain.Invoice, error)
 
 	// GetByClientID retrieves all invoices for a specific client
 	GetByClientID(ctx context.Context, clientID uuid.UUID) ([]*domain.Invoice, error)
 
-	// GetByAppointmentID retrieves the invoice associated with an appointment
+	// GetByAppointmentID retrieves the invoice billing an appointment, alone or as a line of a grouped invoice;
+	// an invoice cancelled entirely by rectifying invoices no longer bills its appointments
 	GetByAppointmentID(ctx context.Context, appointmentID uuid.UUID) (*domain.Invoice, error)
 
 	// GetTotalRevenueByDateRange calculates total revenue between dates
-	GetTotalRevenueByDateRange(ctx context.Context, fromDate, toDate time.Time) (float64, error)
+	GetTotalRevenueByDateRange(ctx context.Context, fromDate, toDate time.Time) (money.Money, error)
 
-	// GetUnpaidInvoices retrieves all unpaid invoices
+	// GetIssuedByPeriod sums the totals of the invoices issued between dates, grouped by the
+	// period of their issue date; rectifying invoices subtract their corrections
+	GetIssuedByPeriod(ctx context.Context, granularity PeriodGranularity, fromDate, toDate time.Time) ([]PeriodTotal, error)
+
+	// GetCollectedByPeriod sums the payments not reversed made between dates, grouped by the
+	// period of their payment date
+	GetCollectedByPeriod(ctx context.Context, granularity PeriodGranularity, fromDate, toDate time.Time) ([]PeriodTotal, error)
+
+	// GetIssuedByDateRange retrieves the invoices issued between dates, rectifying ones
+	// included, with their lines and clients
+	GetIssuedByDateRange(ctx context.Context, fromDate, toDate time.Time) ([]*domain.Invoice, error)
+
+	// GetUnpaidInvoices retrieves the issued invoices with an outstanding balance (unpaid or partially paid)
 	GetUnpaidInvoices(ctx context.Context) ([]*domain.Invoice, error)
+
+	// GetOutstandingInvoices retrieves the issued invoices with an outstanding balance and
+	// their clients, filtered by client, employee or service. As of a day, only invoices issued
+	// by then are considered and their paid amount counts the payments made by then.
+	GetOutstandingInvoices(ctx context.Context, filters OutstandingInvoiceFilters) ([]*domain.Invoice, error)
 }
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// InvoiceSeriesRepository defines the interface for invoice numbering series data access
+type InvoiceSeriesRepository interface {
+	// Create creates a new series; a default series replaces the previous default of its type and location
+	Create(ctx context.Context, series *domain.InvoiceSeries) error
+
+	// GetByID retrieves a series by ID
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.InvoiceSeries, error)
+
+	// List retrieves the series ordered by type and prefix, optionally only active ones
+	List(ctx context.Context, activeOnly bool) ([]*domain.InvoiceSeries, error)
+
+	// GetDefault retrieves the default active series of an invoice type, preferring
+	// the default of the location over the one shared by all locations
+	GetDefault(ctx context.Context, invoiceType domain.InvoiceType, location *string) (*domain.InvoiceSeries, error)
+
+	// Update updates the name, location, default and active flags of a series
+	Update(ctx context.Context, series *domain.InvoiceSeries) error
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// PaymentLinkRepository defines the interface for online payment link data access
+type PaymentLinkRepository interface {
+	// Create stores a new payment link
+	Create(ctx context.Context, link *domain.PaymentLink) error
+
+	// GetBySession retrieves the link of a checkout session of a provider
+	GetBySession(ctx context.Context, provider, sessionID string) (*domain.PaymentLink, error)
+
+	// ListByInvoice retrieves the links of an invoice, newest first
+	ListByInvoice(ctx context.Context, invoiceID uuid.UUID) ([]*domain.PaymentLink, error)
+
+	// Complete marks an open link as paid for a webhook event and, when payment is not nil,
+	// records it against the locked invoice, returning the updated invoice. Fails with
+	// domain.ErrWebhookEventProcessed when the event was applied before and with
+	// domain.ErrPaymentLinkNotOpen when the link is no longer open.
+	Complete(ctx context.Context, link *domain.PaymentLink, payment *domain.Payment, eventID string) (*domain.Invoice, error)
+
+	// Expire marks an open link as expired for a webhook event, with the errors of Complete
+	Expire(ctx context.Context, link *domain.PaymentLink, eventID string) error
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// PaymentRepository defines the interface for payment data access
+type PaymentRepository interface {
+	// Create records a payment and updates the paid amount and status of its invoice,
+	// which is returned. The invoice stays locked while the payment is checked against
+	// its outstanding balance, so concurrent payments never exceed it.
+	Create(ctx context.Context, payment *domain.Payment) (*domain.Invoice, error)
+
+	// GetByID retrieves a payment by ID
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Payment, error)
+
+	// ListByInvoice retrieves the payments of an invoice, including reversed ones, oldest first
+	ListByInvoice(ctx context.Context, invoiceID uuid.UUID) ([]*domain.Payment, error)
+
+	// Reverse stores the reversal of a payment and updates the paid amount and status of
+	// its invoice, which is returned
+	Reverse(ctx context.Context, payment *domain.Payment) (*domain.Invoice, error)
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// PeriodGranularity is the length of the periods amounts are grouped by
+type PeriodGranularity string
+
+const (
+	PeriodWeek    PeriodGranularity = "week"    // ISO weeks, starting on Monday
+	PeriodMonth   PeriodGranularity = "month"   // Calendar months
+	PeriodQuarter PeriodGranularity = "quarter" // Calendar quarters
+)
+
+// IsValid returns true if the granularity is supported
+func (g PeriodGranularity) IsValid() bool {
+	switch g {
+	case PeriodWeek, PeriodMonth, PeriodQuarter:
+		return true
+	}
+	return false
+}
+
+// Truncate returns the first day of the period containing t, as PostgreSQL date_trunc does
+func (g PeriodGranularity) Truncate(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch g {
+	case PeriodWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case PeriodQuarter:
+		return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// Next returns the first day of the period following the one starting at start
+func (g PeriodGranularity) Next(start time.Time) time.Time {
+	switch g {
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	case PeriodQuarter:
+		return start.AddDate(0, 3, 0)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+// Label names the period starting at start: 2025-W09, 2025-03 or 2025-Q1
+func (g PeriodGranularity) Label(start time.Time) string {
+	switch g {
+	case PeriodWeek:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case PeriodQuarter:
+		return fmt.Sprintf("%d-Q%d", start.Year(), (int(start.Month())+2)/3)
+	default:
+		return start.Format("2006-01")
+	}
+}
+
+// PeriodTotal is an amount aggregated over the period starting on Start
+type PeriodTotal struct {
+	Start time.Time   `db:"period_start"`
+	Total money.Money `db:"total"`
+}
+
+// CategoryTotal is the amount of the expenses of a category
+type CategoryTotal struct {
+	CategoryID   uuid.UUID   `db:"category_id"`
+	CategoryName string      `db:"category_name"`
+	Total        money.Money `db:"total"`
+}
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type appointmentAttachmentRepository struct {
+	db *sqlx.DB
+}
+
+// NewAppointmentAttachmentRepository creates a new appointment attachment repository
+func NewAppointmentAttachmentRepository(db *sqlx.DB) repository.AppointmentAttachmentRepository {
+	return &appointmentAttachmentRepository{db: db}
+}
+
+// Create creates a new attachment record
+func (r *appointmentAttachmentRepository) Create(ctx context.Context, attachment *domain.AppointmentAttachment) error {
+	query := `
+		INSERT INTO appointment_attachments (
+			id, appointment_id, file_name, content_type, size_bytes, storage_key,
+			visibility, uploaded_by, created_at, updated_at
+		) VALUES (
+			:id, :appointment_id, :file_name, :content_type, :size_bytes, :storage_key,
+			:visibility, :uploaded_by, :created_at, :updated_at
+		)`
+
+	_, err := r.db.NamedExecContext(ctx, query, attachment)
+	if err != nil {
+		return fmt.Errorf("failed to create appointment attachment: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves an attachment by ID (excluding soft-deleted)
+func (r *appointmentAttachmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.AppointmentAttachment, error) {
+	var attachment domain.AppointmentAttachment
+	query := `SELECT * FROM appointment_attachments WHERE id = $1 AND deleted_at IS NULL`
+
+	err := r.db.GetContext(ctx, &attachment, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("attachment not found")
+		}
+		return nil, fmt.Errorf("failed to get appointment attachment: %w", err)
+	}
+
+	return &attachment, nil
+}
+
+// ListByAppointment retrieves the attachments of an appointment, oldest first
+func (r *appointmentAttachmentRepository) ListByAppointment(ctx context.Context, appointmentID uuid.UUID) ([]*domain.AppointmentAttachment, error) {
+	var attachments []*domain.AppointmentAttachment
+	query := `
+		SELECT * FROM appointment_attachments
+		WHERE appointment_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at ASC`
+
+	err := r.db.SelectContext(ctx, &attachments, query, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list appointment attachments: %w", err)
+	}
+
+	return attachments, nil
+}
+
+// Delete soft deletes an attachment
+func (r *appointmentAttachmentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE appointment_attachments SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete appointment attachment: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("attachment not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type appointmentChargeRepository struct {
+	db *sqlx.DB
+}
+
+// NewAppointmentChargeRepository creates a new appointment charge repository
+func NewAppointmentChargeRepository(db *sqlx.DB) repository.AppointmentChargeRepository {
+	return &appointmentChargeRepository{db: db}
+}
+
+// Create creates a new appointment charge
+func (r *appointmentChargeRepository) Create(ctx context.Context, charge *domain.AppointmentCharge) error {
+	query := `
+		INSERT INTO appointment_charges (
+			id, appointment_id, client_id, kind, amount, status, invoice_id,
+			waived_by, waive_reason, waived_at, created_at, updated_at
+		) VALUES (
+			:id, :appointment_id, :client_id, :kind, :amount, :status, :invoice_id,
+			:waived_by, :waive_reason, :waived_at, :created_at, :updated_at
+		)`
+
+	_, err := r.db.NamedExecContext(ctx, query, charge)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewConflictError("appointment already has a charge of this kind", errors.CodeConflict)
+		}
+		return fmt.Errorf("failed to create appointment charge: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a charge by ID
+func (r *appointmentChargeRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.AppointmentCharge, error) {
+	var charge domain.AppointmentCharge
+	query := `SELECT * FROM appointment_charges WHERE id = $1`
+
+	err := r.db.GetContext(ctx, &charge, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("appointment charge not found")
+		}
+		return nil, fmt.Errorf("failed to get appointment charge: %w", err)
+	}
+
+	return &charge, nil
+}
+
+// GetByAppointmentAndKind retrieves the charge of a given kind for an appointment
+func (r *appointmentChargeRepository) GetByAppointmentAndKind(ctx context.Context, appointmentID uuid.UUID, kind domain.AppointmentChargeKind) (*domain.AppointmentCharge, error) {
+	var charge domain.AppointmentCharge
+	query := `SELECT * FROM appointment_charges WHERE appointment_id = $1 AND kind = $2`
+
+	err := r.db.GetContext(ctx, &charge, query, appointmentID, kind)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("appointment charge not found")
+		}
+		return nil, fmt.Errorf("failed to get appointment charge: %w", err)
+	}
+
+	return &charge, nil
+}
+
+// List retrieves a paginated list of charges with filters
+func (r *appointmentChargeRepository) List(ctx context.Context, filters repository.AppointmentChargeFilters) ([]*domain.AppointmentCharge, int, error) {
+	conditions := []string{"1=1"}
+	args := []interface{}{}
+	argCount := 0
+
+	if filters.Status != nil {
+		argCount++
+		conditions = append(conditions, fmt.Sprintf("status = $%d", argCount))
+		args = append(args, *filters.Status)
+	}
+
+	if filters.Kind != nil {
+		argCount++
+		conditions = append(conditions, fmt.Sprintf("kind = $%d", argCount))
+		args = append(args, *filters.Kind)
+	}
+
+	if filters.ClientID != nil {
+		argCount++
+		conditions = append(conditions, fmt.Sprintf("client_id = $%d", argCount))
+		args = append(args, *filters.ClientID)
+	}
+
+	whereClause := strings.Join(conditions, " AND ")
+
+	// Get total count
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM appointment_charges WHERE %s", whereClause)
+	if err := r.db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count appointment charges: %w", err)
+	}
+
+	// Pagination
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PageSize < 1 {
+		filters.PageSize = 20
+	}
+	if filters.PageSize > 100 {
+		filters.PageSize = 100
+	}
+
+	offset := (filters.Page - 1) * filters.PageSize
+	args = append(args, filters.PageSize, offset)
+
+	query := fmt.Sprintf(`
+		SELECT * FROM appointment_charges
+		WHERE %s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d`,
+		whereClause, argCount+1, argCount+2)
+
+	var charges []*domain.AppointmentCharge
+	if err := r.db.SelectContext(ctx, &charges, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to list appointment charges: %w", err)
+	}
+
+	return charges, total, nil
+}
+
+// Update updates an existing charge
+func (r *appointmentChargeRepository) Update(ctx context.Context, charge *domain.AppointmentCharge) error {
+	query := `
+		UPDATE appointment_charges SET
+			amount = :amount,
+			status = :status,
+			invoice_id = :invoice_id,
+			waived_by = :waived_by,
+			waive_reason = :waive_reason,
+			waived_at = :waived_at,
+			updated_at = :updated_at
+		WHERE id = :id`
+
+	result, err := r.db.NamedExecContext(ctx, query, charge)
+	if err != nil {
+		return fmt.Errorf("failed to update appointment charge: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("appointment charge not found")
+	}
+
+	return nil
+}
@@ -25,8 +25,8 @@ func NewAppointmentRepository(db *sqlx.DB) repository.AppointmentRepository {
 // ✅ Columnas base para SELECT
 const appointmentColumns = `
     id, client_id, employee_id, title, description,
-    start_time, end_time, duration_minutes, status, room,
-    notes, cancellation_reason, google_calendar_event_id,
+    start_time, end_time, duration_minutes, status, room, service_type_id,
+    notes, cancellation_reason, cancelled_by, google_calendar_event_id,
     created_by, created_at, updated_at, deleted_at
 `
 
@@ -34,9 +34,9 @@ func (r *appointmentRepository) Create(ctx context.Context, appointment *domain.
 	query := `
         INSERT INTO appointments (
             id, client_id, employee_id, title, description,
-            start_time, end_time, duration_minutes, status, room,
+            start_time, end_time, duration_minutes, status, room, service_type_id,
             notes, created_by, created_at, updated_at
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
     `
 
 	_, err := r.db.ExecContext(ctx, query,
@@ -50,6 +50,7 @@ func (r *appointmentRepository) Create(ctx context.Context, appointment *domain.
 		appointment.DurationMinutes,
 		appointment.Status,
 		appointment.Room,
+		appointment.ServiceTypeID,
 		appointment.Notes,
 		appointment.CreatedBy,
 		appointment.CreatedAt,
@@ -89,7 +90,7 @@ func (r *appointmentRepository) GetByIDWithRelations(ctx context.Context, id uui
 	employeeQuery := `
         SELECT id, user_id, first_name, last_name, email, phone, dni,
                date_of_birth, position, specialties, is_active, hire_date, notes,
-               avatar_color, created_at, updated_at
+               avatar_color, session_rate, created_at, updated_at
         FROM employees
         WHERE id = $1 AND deleted_at IS NULL
     `
@@ -127,11 +128,13 @@ func (r *appointmentRepository) Update(ctx context.Context, appointment *domain.
 			duration_minutes = $6,
 			status = $7,
 			room = $8,
-			notes = $9,
-			cancellation_reason = $10,
-			google_calendar_event_id = $11,
-			updated_at = $12
-		WHERE id = $13 AND deleted_at IS NULL
+			service_type_id = $9,
+			notes = $10,
+			cancellation_reason = $11,
+			cancelled_by = $12,
+			google_calendar_event_id = $13,
+			updated_at = $14
+		WHERE id = $15 AND deleted_at IS NULL
 	`
 
 	result, err := r.db.ExecContext(ctx, query,
@@ -143,8 +146,10 @@ func (r *appointmentRepository) Update(ctx context.Context, appointment *domain.
 		appointment.DurationMinutes,
 		appointment.Status,
 		appointment.Room,
+		appointment.ServiceTypeID,
 		appointment.Notes,
 		appointment.CancellationReason,
+		appointment.CancelledBy,
 		appointment.GoogleCalendarEventID,
 		time.Now(),
 		appointment.ID,
@@ -318,7 +323,7 @@ func (r *appointmentRepository) ListWithRelations(ctx context.Context, filters d
 		employeeQuery := `
 			SELECT id, user_id, first_name, last_name, email, phone, dni,
 			       date_of_birth, position, specialties, is_active, hire_date, notes,
-			       avatar_color, created_at, updated_at
+			       avatar_color, session_rate, created_at, updated_at
 			FROM employees
 			WHERE id = $1 AND deleted_at IS NULL
 		`
@@ -496,3 +501,38 @@ func (r *appointmentRepository) GetByEmployeeID(ctx context.Context, employeeID
 
 	return appointments, nil
 }
+
+// ListUninvoiced retrieves the completed appointments starting within [from, to) that no
+// invoice references yet and that were not paid with a session pack, ordered by client and
+// start time. Rectifying invoices do not count: they correct the invoice that billed the
+// appointment. Covered sessions carry their co-payment, and those without one are skipped.
+func (r *appointmentRepository) ListUninvoiced(ctx context.Context, from, to time.Time) ([]*domain.Appointment, error) {
+	var appointments []*domain.Appointment
+
+	query := fmt.Sprintf(`
+		SELECT %s,
+		       (SELECT c.copay_amount FROM insurance_covered_sessions c WHERE c.appointment_id = a.id) AS copay_amount
+		FROM appointments a
+		WHERE a.deleted_at IS NULL AND a.status = $1
+		  AND a.start_time >= $2 AND a.start_time < $3
+		  AND NOT EXISTS (
+			SELECT 1 FROM invoices i
+			WHERE %s
+			  AND (i.appointment_id = a.id OR EXISTS (
+				SELECT 1 FROM invoice_lines l WHERE l.invoice_id = i.id AND l.appointment_id = a.id
+			  ))
+		  )
+		  AND NOT EXISTS (SELECT 1 FROM session_pack_usages u WHERE u.appointment_id = a.id)
+		  AND NOT EXISTS (
+			SELECT 1 FROM insurance_covered_sessions c WHERE c.appointment_id = a.id AND c.copay_amount = 0
+		  )
+		ORDER BY a.client_id, a.start_time
+	`, appointmentColumns, billsAppointments)
+
+	err := r.db.SelectContext(ctx, &appointments, query, domain.AppointmentStatusCompleted, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list uninvoiced appointments: %w", err)
+	}
+
+	return appointments, nil
+}
@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type bankStatementRepository struct {
+	db *sqlx.DB
+}
+
+// NewBankStatementRepository creates a new bank statement repository
+func NewBankStatementRepository(db *sqlx.DB) repository.BankStatementRepository {
+	return &bankStatementRepository{db: db}
+}
+
+// Create stores a statement with its movements; fails with
+// domain.ErrStatementAlreadyImported when the same file was imported before
+func (r *bankStatementRepository) Create(ctx context.Context, statement *domain.BankStatement) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO bank_statements (
+			id, format, file_name, content_hash, account, from_date, to_date, movement_count,
+			imported_by, imported_at
+		) VALUES (
+			:id, :format, :file_name, :content_hash, :account, :from_date, :to_date, :movement_count,
+			:imported_by, :imported_at
+		)`
+	if _, err := tx.NamedExecContext(ctx, query, statement); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			return domain.ErrStatementAlreadyImported
+		}
+		return fmt.Errorf("failed to create bank statement: %w", err)
+	}
+
+	movementQuery := `
+		INSERT INTO bank_movements (
+			id, statement_id, booking_date, value_date, amount, concept, reference, counterparty,
+			status, invoice_id, payment_id, expense_id, auto_matched, reconciled_by, reconciled_at,
+			created_at, updated_at
+		) VALUES (
+			:id, :statement_id, :booking_date, :value_date, :amount, :concept, :reference, :counterparty,
+			:status, :invoice_id, :payment_id, :expense_id, :auto_matched, :reconciled_by, :reconciled_at,
+			:created_at, :updated_at
+		)`
+	for _, movement := range statement.Movements {
+		if _, err := tx.NamedExecContext(ctx, movementQuery, movement); err != nil {
+			return fmt.Errorf("failed to create bank movement: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit bank statement: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a statement with its movements
+func (r *bankStatementRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.BankStatement, error) {
+	var statement domain.BankStatement
+	if err := r.db.GetContext(ctx, &statement, `SELECT * FROM bank_statements WHERE id = $1`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("bank statement not found")
+		}
+		return nil, fmt.Errorf("failed to get bank statement: %w", err)
+	}
+
+	movements, err := r.ListMovements(ctx, repository.BankMovementFilters{StatementID: &id})
+	if err != nil {
+		return nil, err
+	}
+	statement.Movements = movements
+
+	return &statement, nil
+}
+
+// List retrieves the statements without their movements, newest first
+func (r *bankStatementRepository) List(ctx context.Context) ([]*domain.BankStatement, error) {
+	statements := []*domain.BankStatement{}
+	query := `SELECT * FROM bank_statements ORDER BY imported_at DESC`
+
+	if err := r.db.SelectContext(ctx, &statements, query); err != nil {
+		return nil, fmt.Errorf("failed to list bank statements: %w", err)
+	}
+
+	return statements, nil
+}
+
+// GetMovement retrieves a bank movement by ID
+func (r *bankStatementRepository) GetMovement(ctx context.Context, id uuid.UUID) (*domain.BankMovement, error) {
+	var movement domain.BankMovement
+	if err := r.db.GetContext(ctx, &movement, `SELECT * FROM bank_movements WHERE id = $1`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("bank movement not found")
+		}
+		return nil, fmt.Errorf("failed to get bank movement: %w", err)
+	}
+
+	return &movement, nil
+}
+
+// ListMovements retrieves bank movements ordered by booking date
+func (r *bankStatementRepository) ListMovements(ctx context.Context, filters repository.BankMovementFilters) ([]*domain.BankMovement, error) {
+	conditions := []string{"1=1"}
+	args := []interface{}{}
+
+	if filters.StatementID != nil {
+		args = append(args, *filters.StatementID)
+		conditions = append(conditions, fmt.Sprintf("statement_id = $%d", len(args)))
+	}
+	if filters.Status != nil {
+		args = append(args, *filters.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	movements := []*domain.BankMovement{}
+	query := fmt.Sprintf(`
+		SELECT * FROM bank_movements
+		WHERE %s
+		ORDER BY booking_date ASC, created_at ASC`, strings.Join(conditions, " AND "))
+
+	if err := r.db.SelectContext(ctx, &movements, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list bank movements: %w", err)
+	}
+
+	return movements, nil
+}
+
+// ListUnreconciledExpenses retrieves the expenses dated between two dates that no bank
+// movement has been reconciled with
+func (r *bankStatementRepository) ListUnreconciledExpenses(ctx context.Context, fromDate, toDate time.Time) ([]*domain.Expense, error) {
+	expenses := []*domain.Expense{}
+	query := `
+		SELECT e.* FROM expenses e
+		WHERE e.expense_date >= $1
+		AND e.expense_date <= $2
+		AND e.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM bank_movements m WHERE m.expense_id = e.id)
+		ORDER BY e.expense_date ASC, e.created_at ASC`
+
+	if err := r.db.SelectContext(ctx, &expenses, query, fromDate, toDate); err != nil {
+		return nil, fmt.Errorf("failed to list unreconciled expenses: %w", err)
+	}
+
+	return expenses, nil
+}
+
+// ReconcileInvoice records the payment of an incoming movement against its locked invoice
+// and marks the movement as reconciled. Returns the updated invoice, or
+// domain.ErrMovementNotPending when the movement was reconciled meanwhile.
+func (r *bankStatementRepository) ReconcileInvoice(ctx context.Context, movement *domain.BankMovement, payment *domain.Payment) (*domain.Invoice, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invoice, err := lockInvoice(ctx, tx, payment.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := invoice.CheckPayment(payment.Amount); err != nil {
+		return nil, err
+	}
+	if err := insertPayment(ctx, tx, payment); err != nil {
+		return nil, err
+	}
+	if err := refreshInvoiceBalance(ctx, tx, invoice); err != nil {
+		return nil, err
+	}
+
+	if err := updatePendingMovement(ctx, tx, movement); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit bank reconciliation: %w", err)
+	}
+
+	return invoice, nil
+}
+
+// ReconcileExpense marks an outgoing movement as reconciled with its expense; fails with
+// domain.ErrExpenseAlreadyReconciled when another movement paid the expense
+func (r *bankStatementRepository) ReconcileExpense(ctx context.Context, movement *domain.BankMovement) error {
+	return updatePendingMovement(ctx, r.db, movement)
+}
+
+// IgnoreMovement takes a pending movement out of the reconciliation queue
+func (r *bankStatementRepository) IgnoreMovement(ctx context.Context, movement *domain.BankMovement) error {
+	return updatePendingMovement(ctx, r.db, movement)
+}
+
+// namedExecer is implemented by both *sqlx.DB and *sqlx.Tx
+type namedExecer interface {
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+}
+
+// updatePendingMovement stores the reconciliation of a movement still pending; fails with
+// domain.ErrMovementNotPending when it is no longer in the queue and with
+// domain.ErrExpenseAlreadyReconciled when its expense was paid by another movement
+func updatePendingMovement(ctx context.Context, db namedExecer, movement *domain.BankMovement) error {
+	query := `
+		UPDATE bank_movements SET
+			status = :status,
+			invoice_id = :invoice_id,
+			payment_id = :payment_id,
+			expense_id = :expense_id,
+			auto_matched = :auto_matched,
+			reconciled_by = :reconciled_by,
+			reconciled_at = :reconciled_at,
+			updated_at = :updated_at
+		WHERE id = :id AND status = 'pending'`
+	result, err := db.NamedExecContext(ctx, query, movement)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			return domain.ErrExpenseAlreadyReconciled
+		}
+		return fmt.Errorf("failed to update bank movement: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrMovementNotPending
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type billingProfileRepository struct {
+	db *sqlx.DB
+}
+
+// NewBillingProfileRepository creates a new billing profile repository
+func NewBillingProfileRepository(db *sqlx.DB) repository.BillingProfileRepository {
+	return &billingProfileRepository{db: db}
+}
+
+// Create creates a new billing profile
+func (r *billingProfileRepository) Create(ctx context.Context, profile *domain.BillingProfile) error {
+	query := `
+		INSERT INTO billing_profiles (
+			id, name, tax_id, email, phone, address_street, address_city, address_province,
+			address_postal_code, address_country, preferred_payment_method, notes, is_active,
+			created_at, updated_at
+		) VALUES (
+			:id, :name, :tax_id, :email, :phone, :address_street, :address_city, :address_province,
+			:address_postal_code, :address_country, :preferred_payment_method, :notes, :is_active,
+			:created_at, :updated_at
+		)`
+
+	if _, err := r.db.NamedExecContext(ctx, query, profile); err != nil {
+		return fmt.Errorf("failed to create billing profile: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a billing profile by ID (excluding soft-deleted)
+func (r *billingProfileRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.BillingProfile, error) {
+	var profile domain.BillingProfile
+	query := `SELECT * FROM billing_profiles WHERE id = $1 AND deleted_at IS NULL`
+
+	if err := r.db.GetContext(ctx, &profile, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("billing profile not found")
+		}
+		return nil, fmt.Errorf("failed to get billing profile: %w", err)
+	}
+
+	return &profile, nil
+}
+
+// List retrieves the billing profiles matching the filters, ordered by name
+func (r *billingProfileRepository) List(ctx context.Context, filters repository.BillingProfileFilters) ([]*domain.BillingProfile, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	args := []interface{}{}
+
+	if filters.Search != "" {
+		args = append(args, "%"+filters.Search+"%")
+		conditions = append(conditions, fmt.Sprintf("(name ILIKE $%[1]d OR tax_id ILIKE $%[1]d)", len(args)))
+	}
+	if filters.ActiveOnly {
+		conditions = append(conditions, "is_active = true")
+	}
+
+	profiles := []*domain.BillingProfile{}
+	query := fmt.Sprintf(`SELECT * FROM billing_profiles WHERE %s ORDER BY name ASC`, strings.Join(conditions, " AND "))
+	if err := r.db.SelectContext(ctx, &profiles, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list billing profiles: %w", err)
+	}
+
+	return profiles, nil
+}
+
+// Update updates an existing billing profile
+func (r *billingProfileRepository) Update(ctx context.Context, profile *domain.BillingProfile) error {
+	query := `
+		UPDATE billing_profiles SET
+			name = :name,
+			tax_id = :tax_id,
+			email = :email,
+			phone = :phone,
+			address_street = :address_street,
+			address_city = :address_city,
+			address_province = :address_province,
+			address_postal_code = :address_postal_code,
+			address_country = :address_country,
+			preferred_payment_method = :preferred_payment_method,
+			notes = :notes,
+			is_active = :is_active,
+			updated_at = :updated_at
+		WHERE id = :id AND deleted_at IS NULL`
+
+	result, err := r.db.NamedExecContext(ctx, query, profile)
+	if err != nil {
+		return fmt.Errorf("failed to update billing profile: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("billing profile not found")
+	}
+
+	return nil
+}
+
+// Delete soft deletes a billing profile and unlinks it from its clients; its invoices keep
+// their copy of the fiscal data
+func (r *billingProfileRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE billing_profiles SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	result, err := tx.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete billing profile: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("billing profile not found")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM client_billing_profiles WHERE billing_profile_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to unlink billing profile: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit billing profile deletion: %w", err)
+	}
+
+	return nil
+}
+
+// GetClientLink retrieves the link between a client and a billing profile
+func (r *billingProfileRepository) GetClientLink(ctx context.Context, clientID, profileID uuid.UUID) (*domain.ClientBillingProfile, error) {
+	var link domain.ClientBillingProfile
+	query := `SELECT * FROM client_billing_profiles WHERE client_id = $1 AND billing_profile_id = $2`
+
+	if err := r.db.GetContext(ctx, &link, query, clientID, profileID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("billing profile not linked to the client")
+		}
+		return nil, fmt.Errorf("failed to get client billing profile: %w", err)
+	}
+
+	return &link, nil
+}
+
+// ListByClient retrieves the links of a client with their profiles, the default one first
+func (r *billingProfileRepository) ListByClient(ctx context.Context, clientID uuid.UUID) ([]*domain.ClientBillingProfile, error) {
+	links := []*domain.ClientBillingProfile{}
+	query := `
+		SELECT l.* FROM client_billing_profiles l
+		JOIN billing_profiles p ON p.id = l.billing_profile_id
+		WHERE l.client_id = $1 AND p.deleted_at IS NULL
+		ORDER BY l.is_default DESC, p.name ASC`
+
+	if err := r.db.SelectContext(ctx, &links, query, clientID); err != nil {
+		return nil, fmt.Errorf("failed to list client billing profiles: %w", err)
+	}
+	if len(links) == 0 {
+		return links, nil
+	}
+
+	profiles := []*domain.BillingProfile{}
+	query = `
+		SELECT p.* FROM billing_profiles p
+		JOIN client_billing_profiles l ON l.billing_profile_id = p.id
+		WHERE l.client_id = $1 AND p.deleted_at IS NULL`
+	if err := r.db.SelectContext(ctx, &profiles, query, clientID); err != nil {
+		return nil, fmt.Errorf("failed to get client billing profiles: %w", err)
+	}
+
+	byID := make(map[uuid.UUID]*domain.BillingProfile, len(profiles))
+	for _, profile := range profiles {
+		byID[profile.ID] = profile
+	}
+	for _, link := range links {
+		link.Profile = byID[link.BillingProfileID]
+	}
+
+	return links, nil
+}
+
+// GetDefaultForClient retrieves the default payer of a client
+func (r *billingProfileRepository) GetDefaultForClient(ctx context.Context, clientID uuid.UUID) (*domain.BillingProfile, error) {
+	var profile domain.BillingProfile
+	query := `
+		SELECT p.* FROM billing_profiles p
+		JOIN client_billing_profiles l ON l.billing_profile_id = p.id
+		WHERE l.client_id = $1 AND l.is_default AND p.deleted_at IS NULL`
+
+	if err := r.db.GetContext(ctx, &profile, query, clientID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("client has no default billing profile")
+		}
+		return nil, fmt.Errorf("failed to get default billing profile: %w", err)
+	}
+
+	return &profile, nil
+}
+
+// LinkClient creates or updates the link between a client and a billing profile; a default
+// link replaces the previous default of the client in the same transaction
+func (r *billingProfileRepository) LinkClient(ctx context.Context, link *domain.ClientBillingProfile) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if link.IsDefault {
+		query := `UPDATE client_billing_profiles SET is_default = false WHERE client_id = $1 AND billing_profile_id <> $2 AND is_default`
+		if _, err := tx.ExecContext(ctx, query, link.ClientID, link.BillingProfileID); err != nil {
+			return fmt.Errorf("failed to clear default billing profile: %w", err)
+		}
+	}
+
+	query := `
+		INSERT INTO client_billing_profiles (client_id, billing_profile_id, is_default, created_at)
+		VALUES (:client_id, :billing_profile_id, :is_default, :created_at)
+		ON CONFLICT (client_id, billing_profile_id) DO UPDATE SET is_default = EXCLUDED.is_default`
+	if _, err := tx.NamedExecContext(ctx, query, link); err != nil {
+		return fmt.Errorf("failed to link billing profile: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit billing profile link: %w", err)
+	}
+
+	return nil
+}
+
+// UnlinkClient removes the link between a client and a billing profile
+func (r *billingProfileRepository) UnlinkClient(ctx context.Context, clientID, profileID uuid.UUID) error {
+	query := `DELETE FROM client_billing_profiles WHERE client_id = $1 AND billing_profile_id = $2`
+	result, err := r.db.ExecContext(ctx, query, clientID, profileID)
+	if err != nil {
+		return fmt.Errorf("failed to unlink billing profile: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("billing profile not linked to the client")
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/jmoiron/sqlx"
+)
+
+type billingSettingsRepository struct {
+	db *sqlx.DB
+}
+
+// NewBillingSettingsRepository creates a new billing settings repository
+func NewBillingSettingsRepository(db *sqlx.DB) repository.BillingSettingsRepository {
+	return &billingSettingsRepository{db: db}
+}
+
+// Get retrieves the billing settings
+func (r *billingSettingsRepository) Get(ctx context.Context) (*domain.BillingSettings, error) {
+	var settings domain.BillingSettings
+	query := `
+		SELECT legal_name, trade_name, tax_id, address_street, address_city, address_province,
+			address_postal_code, address_country, email, phone, website, iban, sepa_creditor_id, registry_info,
+			logo_key, logo_content_type, footer_text, accent_color, appointment_invoicing, updated_at
+		FROM billing_settings WHERE id = 1`
+
+	err := r.db.GetContext(ctx, &settings, query)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("billing settings not found")
+		}
+		return nil, fmt.Errorf("failed to get billing settings: %w", err)
+	}
+
+	return &settings, nil
+}
+
+// Update stores the billing settings
+func (r *billingSettingsRepository) Update(ctx context.Context, settings *domain.BillingSettings) error {
+	query := `
+		INSERT INTO billing_settings (
+			id, legal_name, trade_name, tax_id, address_street, address_city, address_province,
+			address_postal_code, address_country, email, phone, website, iban, sepa_creditor_id, registry_info,
+			logo_key, logo_content_type, footer_text, accent_color, appointment_invoicing, updated_at
+		) VALUES (
+			1, :legal_name, :trade_name, :tax_id, :address_street, :address_city, :address_province,
+			:address_postal_code, :address_country, :email, :phone, :website, :iban, :sepa_creditor_id, :registry_info,
+			:logo_key, :logo_content_type, :footer_text, :accent_color, :appointment_invoicing, :updated_at
+		)
+		ON CONFLICT (id) DO UPDATE SET
+			legal_name = EXCLUDED.legal_name,
+			trade_name = EXCLUDED.trade_name,
+			tax_id = EXCLUDED.tax_id,
+			address_street = EXCLUDED.address_street,
+			address_city = EXCLUDED.address_city,
+			address_province = EXCLUDED.address_province,
+			address_postal_code = EXCLUDED.address_postal_code,
+			address_country = EXCLUDED.address_country,
+			email = EXCLUDED.email,
+			phone = EXCLUDED.phone,
+			website = EXCLUDED.website,
+			iban = EXCLUDED.iban,
+			sepa_creditor_id = EXCLUDED.sepa_creditor_id,
+			registry_info = EXCLUDED.registry_info,
+			logo_key = EXCLUDED.logo_key,
+			logo_content_type = EXCLUDED.logo_content_type,
+			footer_text = EXCLUDED.footer_text,
+			accent_color = EXCLUDED.accent_color,
+			appointment_invoicing = EXCLUDED.appointment_invoicing,
+			updated_at = EXCLUDED.updated_at`
+
+	if _, err := r.db.NamedExecContext(ctx, query, settings); err != nil {
+		return fmt.Errorf("failed to update billing settings: %w", err)
+	}
+
+	return nil
+}
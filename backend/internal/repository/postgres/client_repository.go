@@ -26,7 +26,7 @@ func NewClientRepository(db *sqlx.DB) repository.ClientRepository {
 const clientColumns = `
     id, user_id, email, first_name, last_name, phone, dni_cif,
     address_street, address_city, address_province, address_postal_code, address_country,
-    notes, is_active, created_at, updated_at, deleted_at
+    notes, is_active, payment_plan_until, created_at, updated_at, deleted_at
 `
 
 func (r *clientRepository) Create(ctx context.Context, client *domain.Client) error {
@@ -34,8 +34,8 @@ func (r *clientRepository) Create(ctx context.Context, client *domain.Client) er
 		INSERT INTO clients (
 			id, user_id, email, first_name, last_name, phone, dni_cif,
 			address_street, address_city, address_province, address_postal_code, address_country,
-			notes, is_active, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+			notes, is_active, payment_plan_until, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 	`
 	_, err := r.db.ExecContext(ctx, query,
 		client.ID,
@@ -52,6 +52,7 @@ func (r *clientRepository) Create(ctx context.Context, client *domain.Client) er
 		client.AddressCountry,
 		client.Notes,
 		client.IsActive,
+		client.PaymentPlanUntil,
 		client.CreatedAt,
 		client.UpdatedAt,
 	)
@@ -135,8 +136,9 @@ func (r *clientRepository) Update(ctx context.Context, client *domain.Client) er
 			address_country = $11,
 			notes = $12,
 			is_active = $13,
-			updated_at = $14
-		WHERE id = $15 AND deleted_at IS NULL
+			payment_plan_until = $14,
+			updated_at = $15
+		WHERE id = $16 AND deleted_at IS NULL
 	`
 
 	result, err := r.db.ExecContext(ctx, query,
@@ -153,6 +155,7 @@ func (r *clientRepository) Update(ctx context.Context, client *domain.Client) er
 		client.AddressCountry,
 		client.Notes,
 		client.IsActive,
+		client.PaymentPlanUntil,
 		time.Now(),
 		client.ID,
 	)
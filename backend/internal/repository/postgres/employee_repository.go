@@ -27,11 +27,11 @@ func (r *employeeRepository) Create(ctx context.Context, employee *domain.Employ
 		INSERT INTO employees (
 			id, user_id, first_name, last_name, email, phone, dni,
 			date_of_birth, position, specialties, is_active, hire_date,
-			notes, avatar_color
+			notes, avatar_color, session_rate
 		) VALUES (
 			:id, :user_id, :first_name, :last_name, :email, :phone, :dni,
 			:date_of_birth, :position, :specialties, :is_active, :hire_date,
-			:notes, :avatar_color
+			:notes, :avatar_color, :session_rate
 		)
 		RETURNING created_at, updated_at
 	`
@@ -64,7 +64,7 @@ func (r *employeeRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain
 	query := `
 		SELECT id, user_id, first_name, last_name, email, phone, dni,
 		       date_of_birth, position, specialties, is_active, hire_date,
-		       notes, avatar_color, created_at, updated_at, deleted_at
+		       notes, avatar_color, session_rate, created_at, updated_at, deleted_at
 		FROM employees
 		WHERE id = $1 AND deleted_at IS NULL
 	`
@@ -85,7 +85,7 @@ func (r *employeeRepository) GetByUserID(ctx context.Context, userID uuid.UUID)
 	query := `
 		SELECT id, user_id, first_name, last_name, email, phone, dni,
 		       date_of_birth, position, specialties, is_active, hire_date,
-		       notes, avatar_color, created_at, updated_at, deleted_at
+		       notes, avatar_color, session_rate, created_at, updated_at, deleted_at
 		FROM employees
 		WHERE user_id = $1 AND deleted_at IS NULL
 	`
@@ -106,7 +106,7 @@ func (r *employeeRepository) GetByEmail(ctx context.Context, email string) (*dom
 	query := `
 		SELECT id, user_id, first_name, last_name, email, phone, dni,
 		       date_of_birth, position, specialties, is_active, hire_date,
-		       notes, avatar_color, created_at, updated_at, deleted_at
+		       notes, avatar_color, session_rate, created_at, updated_at, deleted_at
 		FROM employees
 		WHERE email = $1 AND deleted_at IS NULL
 	`
@@ -127,7 +127,7 @@ func (r *employeeRepository) GetByDNI(ctx context.Context, dni string) (*domain.
 	query := `
 		SELECT id, user_id, first_name, last_name, email, phone, dni,
 		       date_of_birth, position, specialties, is_active, hire_date,
-		       notes, avatar_color, created_at, updated_at, deleted_at
+		       notes, avatar_color, session_rate, created_at, updated_at, deleted_at
 		FROM employees
 		WHERE dni = $1 AND deleted_at IS NULL
 	`
@@ -158,7 +158,8 @@ func (r *employeeRepository) Update(ctx context.Context, employee *domain.Employ
 		    is_active = :is_active,
 		    hire_date = :hire_date,
 		    notes = :notes,
-		    avatar_color = :avatar_color
+		    avatar_color = :avatar_color,
+		    session_rate = :session_rate
 		WHERE id = :id AND deleted_at IS NULL
 		RETURNING updated_at
 	`
@@ -215,7 +216,7 @@ func (r *employeeRepository) List(ctx context.Context, limit, offset int) ([]*do
 	query := `
 		SELECT id, user_id, first_name, last_name, email, phone, dni,
 		       date_of_birth, position, specialties, is_active, hire_date,
-		       notes, avatar_color, created_at, updated_at, deleted_at
+		       notes, avatar_color, session_rate, created_at, updated_at, deleted_at
 		FROM employees
 		WHERE deleted_at IS NULL
 		ORDER BY created_at DESC
@@ -247,7 +248,7 @@ func (r *employeeRepository) GetBySpecialty(ctx context.Context, specialty strin
 	query := `
 		SELECT id, user_id, first_name, last_name, email, phone, dni,
 		       date_of_birth, position, specialties, is_active, hire_date,
-		       notes, avatar_color, created_at, updated_at, deleted_at
+		       notes, avatar_color, session_rate, created_at, updated_at, deleted_at
 		FROM employees
 		WHERE $1 = ANY(specialties) AND deleted_at IS NULL AND is_active = true
 		ORDER BY first_name, last_name
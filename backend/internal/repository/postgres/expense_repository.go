@@ -10,6 +10,7 @@ import (
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
 	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
@@ -23,20 +24,21 @@ func NewExpenseRepository(db *sqlx.DB) repository.ExpenseRepository {
 	return &expenseRepository{db: db}
 }
 
+// insertExpenseQuery inserts an expense; generated expenses are inserted with it too
+const insertExpenseQuery = `
+	INSERT INTO expenses (
+		id, expense_date, supplier_invoice, supplier, amount, vat_amount, vat_deductible, irpf_deductible,
+		category_id, subcategory_id, has_invoice, attachment_path, description, payment_method, notes,
+		recurring_expense_id, needs_review, created_at, updated_at
+	) VALUES (
+		:id, :expense_date, :supplier_invoice, :supplier, :amount, :vat_amount, :vat_deductible, :irpf_deductible,
+		:category_id, :subcategory_id, :has_invoice, :attachment_path, :description, :payment_method, :notes,
+		:recurring_expense_id, :needs_review, :created_at, :updated_at
+	)`
+
 // Create creates a new expense
 func (r *expenseRepository) Create(ctx context.Context, expense *domain.Expense) error {
-	query := `
-		INSERT INTO expenses (
-			id, expense_date, supplier_invoice, supplier, amount,
-			category_id, subcategory_id, has_invoice, attachment_path, notes,
-			created_at, updated_at
-		) VALUES (
-			:id, :expense_date, :supplier_invoice, :supplier, :amount,
-			:category_id, :subcategory_id, :has_invoice, :attachment_path, :notes,
-			:created_at, :updated_at
-		)`
-
-	_, err := r.db.NamedExecContext(ctx, query, expense)
+	_, err := r.db.NamedExecContext(ctx, insertExpenseQuery, expense)
 	if err != nil {
 		return fmt.Errorf("failed to create expense: %w", err)
 	}
@@ -97,6 +99,12 @@ func (r *expenseRepository) List(ctx context.Context, filters repository.Expense
 		args = append(args, *filters.HasInvoice)
 	}
 
+	if filters.NeedsReview != nil {
+		argCount++
+		conditions = append(conditions, fmt.Sprintf("needs_review = $%d", argCount))
+		args = append(args, *filters.NeedsReview)
+	}
+
 	if filters.Supplier != "" {
 		argCount++
 		conditions = append(conditions, fmt.Sprintf("supplier ILIKE $%d", argCount))
@@ -162,10 +170,14 @@ func (r *expenseRepository) Update(ctx context.Context, expense *domain.Expense)
 			supplier_invoice = :supplier_invoice,
 			supplier = :supplier,
 			amount = :amount,
+			vat_amount = :vat_amount,
+			vat_deductible = :vat_deductible,
+			irpf_deductible = :irpf_deductible,
 			category_id = :category_id,
 			subcategory_id = :subcategory_id,
 			has_invoice = :has_invoice,
-			attachment_path = :attachment_path,
+			description = :description,
+			payment_method = :payment_method,
 			notes = :notes,
 			updated_at = :updated_at
 		WHERE id = :id AND deleted_at IS NULL`
@@ -224,8 +236,8 @@ func (r *expenseRepository) GetByCategory(ctx context.Context, categoryID uuid.U
 }
 
 // GetTotalByDateRange calculates total expenses between dates
-func (r *expenseRepository) GetTotalByDateRange(ctx context.Context, fromDate, toDate time.Time) (float64, error) {
-	var total sql.NullFloat64
+func (r *expenseRepository) GetTotalByDateRange(ctx context.Context, fromDate, toDate time.Time) (money.Money, error) {
+	var total money.Money
 	query := `
 		SELECT COALESCE(SUM(amount), 0)
 		FROM expenses
@@ -235,43 +247,70 @@ func (r *expenseRepository) GetTotalByDateRange(ctx context.Context, fromDate, t
 
 	err := r.db.GetContext(ctx, &total, query, fromDate, toDate)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get total expenses: %w", err)
-	}
-
-	if !total.Valid {
-		return 0, nil
+		return money.Money{}, fmt.Errorf("failed to get total expenses: %w", err)
 	}
 
-	return total.Float64, nil
+	return total, nil
 }
 
-// GetTotalByCategory calculates total expenses by category in a date range
-func (r *expenseRepository) GetTotalByCategory(ctx context.Context, fromDate, toDate time.Time) (map[uuid.UUID]float64, error) {
-	type categoryTotal struct {
-		CategoryID uuid.UUID `db:"category_id"`
-		Total      float64   `db:"total"`
+// GetTotalByCategory calculates total expenses by category in a date range, with the
+// category names, largest first
+func (r *expenseRepository) GetTotalByCategory(ctx context.Context, fromDate, toDate time.Time) ([]repository.CategoryTotal, error) {
+	totals := []repository.CategoryTotal{}
+	query := `
+		SELECT e.category_id, c.name AS category_name, COALESCE(SUM(e.amount), 0) AS total
+		FROM expenses e
+		JOIN expense_categories c ON c.id = e.category_id
+		WHERE e.expense_date >= $1
+		AND e.expense_date <= $2
+		AND e.deleted_at IS NULL
+		GROUP BY e.category_id, c.name
+		ORDER BY total DESC, c.name ASC`
+
+	err := r.db.SelectContext(ctx, &totals, query, fromDate, toDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expenses by category: %w", err)
 	}
 
-	var results []categoryTotal
+	return totals, nil
+}
+
+// GetTotalByPeriod sums the expenses between dates, grouped by the period of their date
+func (r *expenseRepository) GetTotalByPeriod(ctx context.Context, granularity repository.PeriodGranularity, fromDate, toDate time.Time) ([]repository.PeriodTotal, error) {
+	totals := []repository.PeriodTotal{}
 	query := `
-		SELECT category_id, COALESCE(SUM(amount), 0) as total
+		SELECT date_trunc($1, expense_date::timestamp)::date AS period_start, COALESCE(SUM(amount), 0) AS total
 		FROM expenses
-		WHERE expense_date >= $1 
-		AND expense_date <= $2 
+		WHERE expense_date >= $2
+		AND expense_date <= $3
 		AND deleted_at IS NULL
-		GROUP BY category_id`
+		GROUP BY period_start
+		ORDER BY period_start`
 
-	err := r.db.SelectContext(ctx, &results, query, fromDate, toDate)
+	err := r.db.SelectContext(ctx, &totals, query, string(granularity), fromDate, toDate)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get expenses by category: %w", err)
+		return nil, fmt.Errorf("failed to get expenses by period: %w", err)
 	}
 
-	totals := make(map[uuid.UUID]float64)
-	for _, result := range results {
-		totals[result.CategoryID] = result.Total
+	return totals, nil
+}
+
+// ListByDateRange retrieves the expenses dated between two dates, oldest first
+func (r *expenseRepository) ListByDateRange(ctx context.Context, fromDate, toDate time.Time) ([]*domain.Expense, error) {
+	expenses := []*domain.Expense{}
+	query := `
+		SELECT * FROM expenses
+		WHERE expense_date >= $1
+		AND expense_date <= $2
+		AND deleted_at IS NULL
+		ORDER BY expense_date ASC, created_at ASC`
+
+	err := r.db.SelectContext(ctx, &expenses, query, fromDate, toDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expenses by date range: %w", err)
 	}
 
-	return totals, nil
+	return expenses, nil
 }
 
 // GetBySupplier retrieves expenses by supplier name
@@ -289,3 +328,57 @@ func (r *expenseRepository) GetBySupplier(ctx context.Context, supplier string)
 
 	return expenses, nil
 }
+
+// MarkReviewed clears the review flag of a generated expense
+func (r *expenseRepository) MarkReviewed(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE expenses SET needs_review = false, updated_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark expense as reviewed: %w", err)
+	}
+
+	return requireRow(result, "expense not found")
+}
+
+// UpdateAttachment sets or clears the receipt of an expense
+func (r *expenseRepository) UpdateAttachment(ctx context.Context, expense *domain.Expense) error {
+	query := `
+		UPDATE expenses SET
+			attachment_path = :attachment_path,
+			attachment_file_name = :attachment_file_name,
+			attachment_content_type = :attachment_content_type,
+			attachment_size_bytes = :attachment_size_bytes,
+			attachment_sha256 = :attachment_sha256,
+			attachment_uploaded_at = :attachment_uploaded_at,
+			updated_at = :updated_at
+		WHERE id = :id AND deleted_at IS NULL`
+
+	result, err := r.db.NamedExecContext(ctx, query, expense)
+	if err != nil {
+		return fmt.Errorf("failed to update expense receipt: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("expense not found")
+	}
+
+	return nil
+}
+
+// ListByAttachmentHash retrieves the expenses whose receipt has the given SHA-256,
+// including soft-deleted ones
+func (r *expenseRepository) ListByAttachmentHash(ctx context.Context, sha256 string) ([]*domain.Expense, error) {
+	expenses := []*domain.Expense{}
+	query := `SELECT * FROM expenses WHERE attachment_sha256 = $1 ORDER BY created_at ASC`
+
+	if err := r.db.SelectContext(ctx, &expenses, query, sha256); err != nil {
+		return nil, fmt.Errorf("failed to list expenses by receipt: %w", err)
+	}
+
+	return expenses, nil
+}
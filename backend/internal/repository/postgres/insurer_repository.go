@@ -0,0 +1,475 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type insurerRepository struct {
+	db *sqlx.DB
+}
+
+// NewInsurerRepository creates a new insurer repository
+func NewInsurerRepository(db *sqlx.DB) repository.InsurerRepository {
+	return &insurerRepository{db: db}
+}
+
+// Create creates a new insurer
+func (r *insurerRepository) Create(ctx context.Context, insurer *domain.Insurer) error {
+	query := `
+		INSERT INTO insurers (
+			id, name, billing_profile_id, session_rate, payment_term_days, notes, is_active,
+			created_at, updated_at
+		) VALUES (
+			:id, :name, :billing_profile_id, :session_rate, :payment_term_days, :notes, :is_active,
+			:created_at, :updated_at
+		)`
+
+	if _, err := r.db.NamedExecContext(ctx, query, insurer); err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewConflictError("insurer name already exists", errors.CodeConflict)
+		}
+		return fmt.Errorf("failed to create insurer: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves an insurer by ID (excluding soft-deleted)
+func (r *insurerRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Insurer, error) {
+	var insurer domain.Insurer
+	query := `SELECT * FROM insurers WHERE id = $1 AND deleted_at IS NULL`
+
+	if err := r.db.GetContext(ctx, &insurer, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("insurer not found")
+		}
+		return nil, fmt.Errorf("failed to get insurer: %w", err)
+	}
+
+	return &insurer, nil
+}
+
+// List retrieves insurers ordered by name, optionally only active ones
+func (r *insurerRepository) List(ctx context.Context, activeOnly bool) ([]*domain.Insurer, error) {
+	query := `SELECT * FROM insurers WHERE deleted_at IS NULL`
+	if activeOnly {
+		query += ` AND is_active = true`
+	}
+	query += ` ORDER BY name ASC`
+
+	insurers := []*domain.Insurer{}
+	if err := r.db.SelectContext(ctx, &insurers, query); err != nil {
+		return nil, fmt.Errorf("failed to list insurers: %w", err)
+	}
+
+	return insurers, nil
+}
+
+// Update updates an existing insurer
+func (r *insurerRepository) Update(ctx context.Context, insurer *domain.Insurer) error {
+	query := `
+		UPDATE insurers SET
+			name = :name,
+			billing_profile_id = :billing_profile_id,
+			session_rate = :session_rate,
+			payment_term_days = :payment_term_days,
+			notes = :notes,
+			is_active = :is_active,
+			updated_at = :updated_at
+		WHERE id = :id AND deleted_at IS NULL`
+
+	result, err := r.db.NamedExecContext(ctx, query, insurer)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewConflictError("insurer name already exists", errors.CodeConflict)
+		}
+		return fmt.Errorf("failed to update insurer: %w", err)
+	}
+
+	return requireRow(result, "insurer not found")
+}
+
+// Delete soft deletes an insurer; the policies of its clients are kept
+func (r *insurerRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE insurers SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete insurer: %w", err)
+	}
+
+	return requireRow(result, "insurer not found")
+}
+
+// CreatePolicy creates a new policy of a client
+func (r *insurerRepository) CreatePolicy(ctx context.Context, policy *domain.InsurancePolicy) error {
+	query := `
+		INSERT INTO insurance_policies (
+			id, client_id, insurer_id, policy_number, copay, valid_from, valid_until, notes,
+			is_active, created_at, updated_at
+		) VALUES (
+			:id, :client_id, :insurer_id, :policy_number, :copay, :valid_from, :valid_until, :notes,
+			:is_active, :created_at, :updated_at
+		)`
+
+	if _, err := r.db.NamedExecContext(ctx, query, policy); err != nil {
+		return fmt.Errorf("failed to create insurance policy: %w", err)
+	}
+
+	return nil
+}
+
+// GetPolicy retrieves a policy by ID (excluding soft-deleted)
+func (r *insurerRepository) GetPolicy(ctx context.Context, id uuid.UUID) (*domain.InsurancePolicy, error) {
+	var policy domain.InsurancePolicy
+	query := `SELECT * FROM insurance_policies WHERE id = $1 AND deleted_at IS NULL`
+
+	if err := r.db.GetContext(ctx, &policy, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("insurance policy not found")
+		}
+		return nil, fmt.Errorf("failed to get insurance policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// ListClientPolicies retrieves the policies of a client with their insurer and
+// authorisations, newest first
+func (r *insurerRepository) ListClientPolicies(ctx context.Context, clientID uuid.UUID) ([]*domain.InsurancePolicy, error) {
+	policies := []*domain.InsurancePolicy{}
+	query := `
+		SELECT * FROM insurance_policies
+		WHERE client_id = $1 AND deleted_at IS NULL
+		ORDER BY valid_from DESC, created_at DESC`
+
+	if err := r.db.SelectContext(ctx, &policies, query, clientID); err != nil {
+		return nil, fmt.Errorf("failed to list insurance policies: %w", err)
+	}
+	if len(policies) == 0 {
+		return policies, nil
+	}
+
+	policyIDs := make([]string, 0, len(policies))
+	insurerIDs := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		policyIDs = append(policyIDs, policy.ID.String())
+		insurerIDs = append(insurerIDs, policy.InsurerID.String())
+	}
+
+	// Deleted insurers are loaded too, since the policies remain
+	insurers := []*domain.Insurer{}
+	query = `SELECT * FROM insurers WHERE id = ANY($1::uuid[])`
+	if err := r.db.SelectContext(ctx, &insurers, query, pq.Array(insurerIDs)); err != nil {
+		return nil, fmt.Errorf("failed to get insurers of the policies: %w", err)
+	}
+
+	authorisations := []*domain.InsuranceAuthorisation{}
+	query = `
+		SELECT * FROM insurance_authorisations
+		WHERE policy_id = ANY($1::uuid[])
+		ORDER BY valid_until DESC, created_at DESC`
+	if err := r.db.SelectContext(ctx, &authorisations, query, pq.Array(policyIDs)); err != nil {
+		return nil, fmt.Errorf("failed to get authorisations of the policies: %w", err)
+	}
+
+	insurersByID := make(map[uuid.UUID]*domain.Insurer, len(insurers))
+	for _, insurer := range insurers {
+		insurersByID[insurer.ID] = insurer
+	}
+	policiesByID := make(map[uuid.UUID]*domain.InsurancePolicy, len(policies))
+	for _, policy := range policies {
+		policy.Insurer = insurersByID[policy.InsurerID]
+		policy.Authorisations = []*domain.InsuranceAuthorisation{}
+		policiesByID[policy.ID] = policy
+	}
+	for _, authorisation := range authorisations {
+		policy := policiesByID[authorisation.PolicyID]
+		policy.Authorisations = append(policy.Authorisations, authorisation)
+	}
+
+	return policies, nil
+}
+
+// UpdatePolicy updates an existing policy
+func (r *insurerRepository) UpdatePolicy(ctx context.Context, policy *domain.InsurancePolicy) error {
+	query := `
+		UPDATE insurance_policies SET
+			insurer_id = :insurer_id,
+			policy_number = :policy_number,
+			copay = :copay,
+			valid_from = :valid_from,
+			valid_until = :valid_until,
+			notes = :notes,
+			is_active = :is_active,
+			updated_at = :updated_at
+		WHERE id = :id AND deleted_at IS NULL`
+
+	result, err := r.db.NamedExecContext(ctx, query, policy)
+	if err != nil {
+		return fmt.Errorf("failed to update insurance policy: %w", err)
+	}
+
+	return requireRow(result, "insurance policy not found")
+}
+
+// DeletePolicy soft deletes a policy
+func (r *insurerRepository) DeletePolicy(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE insurance_policies SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete insurance policy: %w", err)
+	}
+
+	return requireRow(result, "insurance policy not found")
+}
+
+// CreateAuthorisation creates a new authorisation of a policy
+func (r *insurerRepository) CreateAuthorisation(ctx context.Context, authorisation *domain.InsuranceAuthorisation) error {
+	query := `
+		INSERT INTO insurance_authorisations (
+			id, policy_id, authorisation_number, service_type_id, sessions_authorised, sessions_used,
+			valid_from, valid_until, notes, created_at, updated_at
+		) VALUES (
+			:id, :policy_id, :authorisation_number, :service_type_id, :sessions_authorised, :sessions_used,
+			:valid_from, :valid_until, :notes, :created_at, :updated_at
+		)`
+
+	if _, err := r.db.NamedExecContext(ctx, query, authorisation); err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewConflictError("authorisation number already exists for the policy", errors.CodeConflict)
+		}
+		return fmt.Errorf("failed to create insurance authorisation: %w", err)
+	}
+
+	return nil
+}
+
+// GetAuthorisation retrieves an authorisation by ID
+func (r *insurerRepository) GetAuthorisation(ctx context.Context, id uuid.UUID) (*domain.InsuranceAuthorisation, error) {
+	var authorisation domain.InsuranceAuthorisation
+	query := `SELECT * FROM insurance_authorisations WHERE id = $1`
+
+	if err := r.db.GetContext(ctx, &authorisation, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("insurance authorisation not found")
+		}
+		return nil, fmt.Errorf("failed to get insurance authorisation: %w", err)
+	}
+
+	return &authorisation, nil
+}
+
+// UpdateAuthorisation updates an existing authorisation; the sessions used are kept
+func (r *insurerRepository) UpdateAuthorisation(ctx context.Context, authorisation *domain.InsuranceAuthorisation) error {
+	query := `
+		UPDATE insurance_authorisations SET
+			authorisation_number = :authorisation_number,
+			service_type_id = :service_type_id,
+			sessions_authorised = :sessions_authorised,
+			valid_from = :valid_from,
+			valid_until = :valid_until,
+			notes = :notes,
+			updated_at = :updated_at
+		WHERE id = :id`
+
+	result, err := r.db.NamedExecContext(ctx, query, authorisation)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewConflictError("authorisation number already exists for the policy", errors.CodeConflict)
+		}
+		if strings.Contains(err.Error(), "violates check constraint") {
+			return domain.ErrInvalidAuthorisationSessions
+		}
+		return fmt.Errorf("failed to update insurance authorisation: %w", err)
+	}
+
+	return requireRow(result, "insurance authorisation not found")
+}
+
+// DeleteAuthorisation deletes an authorisation that has not covered any session
+func (r *insurerRepository) DeleteAuthorisation(ctx context.Context, id uuid.UUID) error {
+	query := `
+		DELETE FROM insurance_authorisations a
+		WHERE a.id = $1 AND NOT EXISTS (
+			SELECT 1 FROM insurance_covered_sessions s WHERE s.authorisation_id = a.id
+		)`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete insurance authorisation: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	// Either missing or in use
+	if _, err := r.GetAuthorisation(ctx, id); err != nil {
+		return err
+	}
+	return domain.ErrAuthorisationInUse
+}
+
+// CoverSession takes one session for an appointment from the first usable authorisation
+func (r *insurerRepository) CoverSession(ctx context.Context, appointment *domain.Appointment, session *domain.CoveredSession) (*domain.InsuranceAuthorisation, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Authorisations without a service type cover any session
+	var match struct {
+		AuthorisationID uuid.UUID   `db:"id"`
+		InsurerID       uuid.UUID   `db:"insurer_id"`
+		SessionRate     money.Money `db:"session_rate"`
+		Copay           money.Money `db:"copay"`
+	}
+	query := `
+		SELECT a.id, p.insurer_id, i.session_rate, p.copay
+		FROM insurance_authorisations a
+		JOIN insurance_policies p ON p.id = a.policy_id
+		JOIN insurers i ON i.id = p.insurer_id
+		WHERE p.client_id = $1
+		  AND p.deleted_at IS NULL AND p.is_active
+		  AND p.valid_from <= $2::date AND (p.valid_until IS NULL OR p.valid_until >= $2::date)
+		  AND i.deleted_at IS NULL AND i.is_active
+		  AND a.sessions_used < a.sessions_authorised
+		  AND a.valid_from <= $2::date AND a.valid_until >= $2::date
+		  AND (a.service_type_id IS NULL OR a.service_type_id = $3)
+		ORDER BY a.valid_until ASC, a.created_at ASC
+		LIMIT 1
+		FOR UPDATE OF a`
+	err = tx.GetContext(ctx, &match, query, appointment.ClientID, appointment.StartTime, appointment.ServiceTypeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrNoUsableAuthorisation
+		}
+		return nil, fmt.Errorf("failed to get insurance authorisation: %w", err)
+	}
+
+	session.AuthorisationID = match.AuthorisationID
+	session.AppointmentID = appointment.ID
+	session.ClientID = appointment.ClientID
+	session.InsurerID = match.InsurerID
+	session.ServiceTypeID = appointment.ServiceTypeID
+	session.InsurerAmount = match.SessionRate
+	session.CopayAmount = match.Copay
+	query = `
+		INSERT INTO insurance_covered_sessions (
+			id, authorisation_id, appointment_id, client_id, insurer_id, service_type_id,
+			session_date, insurer_amount, copay_amount, created_at
+		) VALUES (
+			:id, :authorisation_id, :appointment_id, :client_id, :insurer_id, :service_type_id,
+			:session_date, :insurer_amount, :copay_amount, :created_at
+		)
+		ON CONFLICT (appointment_id) DO NOTHING`
+	result, err := tx.NamedExecContext(ctx, query, session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create covered session: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return nil, domain.ErrSessionAlreadyCovered
+	}
+
+	var authorisation domain.InsuranceAuthorisation
+	query = `
+		UPDATE insurance_authorisations SET sessions_used = sessions_used + 1, updated_at = $1
+		WHERE id = $2
+		RETURNING *`
+	if err := tx.GetContext(ctx, &authorisation, query, session.CreatedAt, match.AuthorisationID); err != nil {
+		return nil, fmt.Errorf("failed to update insurance authorisation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit covered session: %w", err)
+	}
+
+	return &authorisation, nil
+}
+
+// GetCoveredSession retrieves the covered session of an appointment
+func (r *insurerRepository) GetCoveredSession(ctx context.Context, appointmentID uuid.UUID) (*domain.CoveredSession, error) {
+	var session domain.CoveredSession
+	query := `SELECT * FROM insurance_covered_sessions WHERE appointment_id = $1`
+
+	if err := r.db.GetContext(ctx, &session, query, appointmentID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("covered session not found")
+		}
+		return nil, fmt.Errorf("failed to get covered session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// ListUnsettled retrieves the sessions of an insurer up to a day not settled yet
+func (r *insurerRepository) ListUnsettled(ctx context.Context, insurerID uuid.UUID, until time.Time) ([]*domain.CoveredSession, error) {
+	sessions := []*domain.CoveredSession{}
+	query := `
+		SELECT s.*, TRIM(c.first_name || ' ' || c.last_name) AS client_name, p.policy_number, a.authorisation_number
+		FROM insurance_covered_sessions s
+		JOIN insurance_authorisations a ON a.id = s.authorisation_id
+		JOIN insurance_policies p ON p.id = a.policy_id
+		JOIN clients c ON c.id = s.client_id
+		WHERE s.insurer_id = $1 AND s.session_date <= $2::date
+		  AND (s.settlement_invoice_id IS NULL OR EXISTS (
+			SELECT 1 FROM invoices i WHERE i.id = s.settlement_invoice_id AND i.deleted_at IS NOT NULL
+		  ))
+		ORDER BY s.session_date ASC, client_name ASC`
+
+	if err := r.db.SelectContext(ctx, &sessions, query, insurerID, until); err != nil {
+		return nil, fmt.Errorf("failed to list unsettled sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// SetSettlementInvoice records the settlement invoice including some sessions
+func (r *insurerRepository) SetSettlementInvoice(ctx context.Context, sessionIDs []uuid.UUID, invoiceID uuid.UUID) error {
+	ids := make([]string, 0, len(sessionIDs))
+	for _, id := range sessionIDs {
+		ids = append(ids, id.String())
+	}
+
+	query := `UPDATE insurance_covered_sessions SET settlement_invoice_id = $1 WHERE id = ANY($2::uuid[])`
+	if _, err := r.db.ExecContext(ctx, query, invoiceID, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to record settlement invoice: %w", err)
+	}
+
+	return nil
+}
+
+// requireRow returns a not found error when a statement changed no row
+func requireRow(result sql.Result, notFound string) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError(notFound)
+	}
+
+	return nil
+}
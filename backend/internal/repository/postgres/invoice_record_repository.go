@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var errInvoiceAlreadyRecorded = errors.NewConflictError("invoice event has already been recorded", errors.CodeConflict)
+
+type invoiceRecordRepository struct {
+	db *sqlx.DB
+}
+
+// NewInvoiceRecordRepository creates a new invoice record repository
+func NewInvoiceRecordRepository(db *sqlx.DB) repository.InvoiceRecordRepository {
+	return &invoiceRecordRepository{db: db}
+}
+
+// Append adds a record at the end of the chain
+func (r *invoiceRecordRepository) Append(ctx context.Context, record *domain.InvoiceRecord, seal func(previous *domain.InvoiceRecord)) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := appendInvoiceRecord(ctx, tx, record, seal); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit invoice record: %w", err)
+	}
+
+	return nil
+}
+
+// appendIssueRecords chains the records of an invoice issued in tx; events already recorded are skipped
+func appendIssueRecords(ctx context.Context, tx *sqlx.Tx, invoice *domain.Invoice, recorder repository.InvoiceIssueRecorder) error {
+	records, err := recorder.IssueRecords(invoice)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		err := appendInvoiceRecord(ctx, tx, record, func(previous *domain.InvoiceRecord) {
+			recorder.SealRecord(record, previous)
+		})
+		if err != nil && err != errInvoiceAlreadyRecorded {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendInvoiceRecord adds a record at the end of the chain in tx
+func appendInvoiceRecord(ctx context.Context, tx *sqlx.Tx, record *domain.InvoiceRecord, seal func(previous *domain.InvoiceRecord)) error {
+	// Readers are not blocked, but only one appender at a time can read the last record
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE invoice_records IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("failed to lock invoice records: %w", err)
+	}
+
+	// Checked before inserting, as a failed insert would abort the whole transaction
+	var recorded bool
+	recordedQuery := `SELECT EXISTS (SELECT 1 FROM invoice_records WHERE invoice_id = $1 AND record_type = $2)`
+	if err := tx.GetContext(ctx, &recorded, recordedQuery, record.InvoiceID, record.RecordType); err != nil {
+		return fmt.Errorf("failed to check invoice records: %w", err)
+	}
+	if recorded {
+		return errInvoiceAlreadyRecorded
+	}
+
+	var previous *domain.InvoiceRecord
+	var last domain.InvoiceRecord
+	err := tx.GetContext(ctx, &last, `SELECT * FROM invoice_records ORDER BY sequence DESC LIMIT 1`)
+	switch {
+	case err == nil:
+		previous = &last
+	case err != sql.ErrNoRows:
+		return fmt.Errorf("failed to get last invoice record: %w", err)
+	}
+
+	seal(previous)
+
+	query := `
+		INSERT INTO invoice_records (
+			id, sequence, record_type, invoice_id, issuer_tax_id, invoice_number, issue_date,
+			invoice_type_code, vat_amount, total_amount, previous_hash, hash, generated_at, signature,
+			submission_status, submission_reference, submission_error, submitted_at, created_at
+		) VALUES (
+			:id, :sequence, :record_type, :invoice_id, :issuer_tax_id, :invoice_number, :issue_date,
+			:invoice_type_code, :vat_amount, :total_amount, :previous_hash, :hash, :generated_at, :signature,
+			:submission_status, :submission_reference, :submission_error, :submitted_at, :created_at
+		)`
+
+	if _, err := tx.NamedExecContext(ctx, query, record); err != nil {
+		if strings.Contains(err.Error(), "invoice_records_invoice_record_type_key") {
+			return errInvoiceAlreadyRecorded
+		}
+		return fmt.Errorf("failed to append invoice record: %w", err)
+	}
+
+	return nil
+}
+
+// GetByInvoiceID retrieves the records of an invoice in chain order
+func (r *invoiceRecordRepository) GetByInvoiceID(ctx context.Context, invoiceID uuid.UUID) ([]*domain.InvoiceRecord, error) {
+	records := []*domain.InvoiceRecord{}
+	query := `SELECT * FROM invoice_records WHERE invoice_id = $1 ORDER BY sequence ASC`
+
+	if err := r.db.SelectContext(ctx, &records, query, invoiceID); err != nil {
+		return nil, fmt.Errorf("failed to get invoice records: %w", err)
+	}
+
+	return records, nil
+}
+
+// ListChain retrieves the records in chain order, starting after the given sequence
+func (r *invoiceRecordRepository) ListChain(ctx context.Context, afterSequence int64, limit int) ([]*domain.InvoiceRecord, error) {
+	records := []*domain.InvoiceRecord{}
+	query := `SELECT * FROM invoice_records WHERE sequence > $1 ORDER BY sequence ASC LIMIT $2`
+
+	if err := r.db.SelectContext(ctx, &records, query, afterSequence, limit); err != nil {
+		return nil, fmt.Errorf("failed to list invoice records: %w", err)
+	}
+
+	return records, nil
+}
+
+// GetPendingSubmission retrieves the oldest records not yet sent to the tax agency
+func (r *invoiceRecordRepository) GetPendingSubmission(ctx context.Context, limit int) ([]*domain.InvoiceRecord, error) {
+	records := []*domain.InvoiceRecord{}
+	query := `
+		SELECT * FROM invoice_records
+		WHERE submission_status = 'pending'
+		ORDER BY sequence ASC
+		LIMIT $1`
+
+	if err := r.db.SelectContext(ctx, &records, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to get pending invoice records: %w", err)
+	}
+
+	return records, nil
+}
+
+// UpdateSubmission stores the transmission result of a record; the chained data never changes
+func (r *invoiceRecordRepository) UpdateSubmission(ctx context.Context, record *domain.InvoiceRecord) error {
+	query := `
+		UPDATE invoice_records SET
+			submission_status = :submission_status,
+			submission_reference = :submission_reference,
+			submission_error = :submission_error,
+			submitted_at = :submitted_at
+		WHERE id = :id`
+
+	result, err := r.db.NamedExecContext(ctx, query, record)
+	if err != nil {
+		return fmt.Errorf("failed to update invoice record submission: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("invoice record not found")
+	}
+
+	return nil
+}
+
+// GetUnrecordedInvoiceIDs retrieves the invoices issued since the given date that have no registration record
+func (r *invoiceRecordRepository) GetUnrecordedInvoiceIDs(ctx context.Context, since time.Time) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	query := `
+		SELECT i.id FROM invoices i
+		WHERE i.status <> 'draft' AND i.deleted_at IS NULL AND i.issue_date >= $1
+			AND NOT EXISTS (
+				SELECT 1 FROM invoice_records ir
+				WHERE ir.invoice_id = i.id AND ir.record_type = 'registration'
+			)
+		ORDER BY i.issue_date ASC, i.invoice_number ASC`
+
+	if err := r.db.SelectContext(ctx, &ids, query, since); err != nil {
+		return nil, fmt.Errorf("failed to get unrecorded invoices: %w", err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoiceRecordRepository_AppendOnlyChain(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewInvoiceRecordRepository(db)
+	invoiceRepo := NewInvoiceRepository(db)
+	ctx := context.Background()
+	key := []byte("test-signing-key")
+
+	series := createTestSeries(t, db, false)
+	clientID := createTestClient(t, db)
+
+	var records []*domain.InvoiceRecord
+	for i := 0; i < 3; i++ {
+		invoice := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, time.Now())
+		require.NoError(t, invoiceRepo.Issue(ctx, invoice, nil))
+
+		record, err := domain.NewInvoiceRecord(domain.InvoiceRecordTypeRegistration, invoice, "B12345678", time.Now())
+		require.NoError(t, err)
+		require.NoError(t, repo.Append(ctx, record, func(previous *domain.InvoiceRecord) {
+			record.Chain(previous, key)
+		}))
+		records = append(records, record)
+
+		// The same event cannot be recorded twice
+		duplicate, err := domain.NewInvoiceRecord(domain.InvoiceRecordTypeRegistration, invoice, "B12345678", time.Now())
+		require.NoError(t, err)
+		err = repo.Append(ctx, duplicate, func(previous *domain.InvoiceRecord) { duplicate.Chain(previous, key) })
+		assert.Equal(t, errInvoiceAlreadyRecorded, err)
+	}
+
+	// Stored records hash the same as when they were built
+	stored, err := repo.ListChain(ctx, records[0].Sequence-1, 3)
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	for i, record := range stored {
+		assert.Equal(t, records[i].Hash, record.Hash)
+		assert.Equal(t, record.Hash, record.ComputeHash())
+		assert.True(t, record.HasValidSignature(key))
+		if i > 0 {
+			assert.Equal(t, stored[i-1].Hash, *record.PreviousHash)
+		}
+	}
+
+	// Only the submission status may change
+	accepted := *stored[0]
+	reference := "CSV123"
+	now := time.Now()
+	accepted.SubmissionStatus = domain.InvoiceRecordSubmissionAccepted
+	accepted.SubmissionReference = &reference
+	accepted.SubmittedAt = &now
+	require.NoError(t, repo.UpdateSubmission(ctx, &accepted))
+
+	_, err = db.Exec(`UPDATE invoice_records SET total_amount = 1 WHERE id = $1`, stored[1].ID)
+	assert.ErrorContains(t, err, "append-only")
+	_, err = db.Exec(`DELETE FROM invoice_records WHERE id = $1`, stored[1].ID)
+	assert.ErrorContains(t, err, "append-only")
+	_, err = db.Exec(`TRUNCATE invoice_records`)
+	assert.ErrorContains(t, err, "append-only")
+}
+
+// testIssueRecorder records registrations, optionally with the cancellation of another invoice
+type testIssueRecorder struct {
+	key       []byte
+	cancelled *domain.Invoice
+	err       error
+}
+
+func (r *testIssueRecorder) IssueRecords(invoice *domain.Invoice) ([]*domain.InvoiceRecord, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	registration, err := domain.NewInvoiceRecord(domain.InvoiceRecordTypeRegistration, invoice, "B12345678", time.Now())
+	if err != nil || r.cancelled == nil {
+		return []*domain.InvoiceRecord{registration}, err
+	}
+
+	cancellation, err := domain.NewInvoiceRecord(domain.InvoiceRecordTypeCancellation, r.cancelled, "B12345678", time.Now())
+	return []*domain.InvoiceRecord{registration, cancellation}, err
+}
+
+func (r *testIssueRecorder) SealRecord(record, previous *domain.InvoiceRecord) {
+	record.Chain(previous, r.key)
+}
+
+func TestInvoiceRepository_IssueRecordsInTheSameTransaction(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewInvoiceRecordRepository(db)
+	invoiceRepo := NewInvoiceRepository(db)
+	ctx := context.Background()
+	recorder := &testIssueRecorder{key: []byte("test-signing-key")}
+
+	series := createTestSeries(t, db, false)
+	clientID := createTestClient(t, db)
+
+	original := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, time.Now())
+	require.NoError(t, invoiceRepo.Issue(ctx, original, recorder))
+
+	records, err := repo.GetByInvoiceID(ctx, original.ID)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, original.InvoiceNumber, records[0].InvoiceNumber)
+	assert.True(t, records[0].HasValidSignature(recorder.key))
+
+	// Events already recorded are skipped without failing the issue
+	recorder.cancelled = original
+	for i := 0; i < 2; i++ {
+		cancelling := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, time.Now())
+		require.NoError(t, invoiceRepo.Issue(ctx, cancelling, recorder))
+	}
+	records, err = repo.GetByInvoiceID(ctx, original.ID)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	// An invoice whose records cannot be built is not issued and uses no number
+	recorder.err = assert.AnError
+	failed := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, time.Now())
+	assert.Equal(t, assert.AnError, invoiceRepo.Issue(ctx, failed, recorder))
+	assert.Equal(t, domain.DraftInvoiceNumber(failed.ID), failed.InvoiceNumber)
+
+	_, err = invoiceRepo.GetByID(ctx, failed.ID)
+	assert.Error(t, err)
+
+	recorder.err = nil
+	next := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, time.Now())
+	require.NoError(t, invoiceRepo.Issue(ctx, next, recorder))
+	assert.Equal(t, 4, *next.SequenceNumber)
+}
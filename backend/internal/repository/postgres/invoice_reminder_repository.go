@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// overdueInvoiceCondition selects the overdue invoices that still have a balance outstanding
+const overdueInvoiceCondition = `
+	i.overdue_at IS NOT NULL
+	AND i.status IN ('unpaid', 'partially_paid')
+	AND i.total_amount > i.paid_amount
+	AND i.deleted_at IS NULL`
+
+type invoiceReminderRepository struct {
+	db *sqlx.DB
+}
+
+// NewInvoiceReminderRepository creates a new invoice reminder repository
+func NewInvoiceReminderRepository(db *sqlx.DB) repository.InvoiceReminderRepository {
+	return &invoiceReminderRepository{db: db}
+}
+
+// MarkOverdue flags the issued invoices with a balance outstanding whose due date is before today
+func (r *invoiceReminderRepository) MarkOverdue(ctx context.Context, today time.Time) (int, error) {
+	query := `
+		UPDATE invoices SET overdue_at = NOW()
+		WHERE overdue_at IS NULL
+			AND status IN ('unpaid', 'partially_paid')
+			AND total_amount > paid_amount
+			AND due_date < $1
+			AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, today)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark overdue invoices: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rows), nil
+}
+
+// ListOverdue retrieves the overdue invoices with a balance outstanding, oldest due date first
+func (r *invoiceReminderRepository) ListOverdue(ctx context.Context) ([]*domain.Invoice, error) {
+	invoices := []*domain.Invoice{}
+	query := `SELECT i.* FROM invoices i WHERE ` + overdueInvoiceCondition + ` ORDER BY i.due_date ASC, i.invoice_number ASC`
+
+	if err := r.db.SelectContext(ctx, &invoices, query); err != nil {
+		return nil, fmt.Errorf("failed to list overdue invoices: %w", err)
+	}
+
+	return invoices, nil
+}
+
+// ListDunning retrieves the overdue invoices to chase, with the number of reminders already sent.
+// Clients in a payment plan are left out.
+func (r *invoiceReminderRepository) ListDunning(ctx context.Context, today time.Time) ([]*repository.DunningInvoice, error) {
+	invoices := []*repository.DunningInvoice{}
+	query := `
+		SELECT i.*, (SELECT COUNT(*) FROM invoice_reminders ir WHERE ir.invoice_id = i.id) AS reminders_sent
+		FROM invoices i
+		LEFT JOIN clients c ON c.id = i.client_id
+		WHERE ` + overdueInvoiceCondition + `
+			AND (c.payment_plan_until IS NULL OR c.payment_plan_until < $1)
+		ORDER BY i.due_date ASC, i.invoice_number ASC`
+
+	if err := r.db.SelectContext(ctx, &invoices, query, today); err != nil {
+		return nil, fmt.Errorf("failed to list invoices to remind: %w", err)
+	}
+
+	return invoices, nil
+}
+
+// Create records a reminder; a second reminder of the same level fails with a conflict
+func (r *invoiceReminderRepository) Create(ctx context.Context, reminder *domain.InvoiceReminder) error {
+	query := `
+		INSERT INTO invoice_reminders (
+			id, invoice_id, level, days_overdue, recipient, outstanding_amount, payment_reference, sent_at
+		) VALUES (
+			:id, :invoice_id, :level, :days_overdue, :recipient, :outstanding_amount, :payment_reference, :sent_at
+		)`
+
+	if _, err := r.db.NamedExecContext(ctx, query, reminder); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			return domain.ErrReminderAlreadySent
+		}
+		return fmt.Errorf("failed to create invoice reminder: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a reminder that could not be delivered
+func (r *invoiceReminderRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM invoice_reminders WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete invoice reminder: %w", err)
+	}
+	return nil
+}
+
+// ListByInvoice retrieves the reminders sent for an invoice, first level first
+func (r *invoiceReminderRepository) ListByInvoice(ctx context.Context, invoiceID uuid.UUID) ([]*domain.InvoiceReminder, error) {
+	reminders := []*domain.InvoiceReminder{}
+	query := `SELECT * FROM invoice_reminders WHERE invoice_id = $1 ORDER BY level ASC`
+
+	if err := r.db.SelectContext(ctx, &reminders, query, invoiceID); err != nil {
+		return nil, fmt.Errorf("failed to list invoice reminders: %w", err)
+	}
+
+	return reminders, nil
+}
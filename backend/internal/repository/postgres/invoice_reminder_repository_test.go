@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func findDunningInvoice(invoices []*repository.DunningInvoice, id uuid.UUID) *repository.DunningInvoice {
+	for _, invoice := range invoices {
+		if invoice.ID == id {
+			return invoice
+		}
+	}
+	return nil
+}
+
+func TestInvoiceReminderRepository_Dunning(t *testing.T) {
+	db := openTestDB(t)
+	invoiceRepo := NewInvoiceRepository(db)
+	paymentRepo := NewPaymentRepository(db)
+	repo := NewInvoiceReminderRepository(db)
+	ctx := context.Background()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	series := createTestSeries(t, db, false)
+
+	// Due 30 days ago
+	overdue := newTestInvoice(createTestClient(t, db), series, domain.InvoiceStatusUnpaid, today.AddDate(0, 0, -60))
+	require.NoError(t, invoiceRepo.Issue(ctx, overdue, nil))
+
+	// Due in 20 days
+	current := newTestInvoice(createTestClient(t, db), series, domain.InvoiceStatusUnpaid, today.AddDate(0, 0, -10))
+	require.NoError(t, invoiceRepo.Issue(ctx, current, nil))
+
+	// Overdue, but the client agreed a payment plan until next month
+	planned := newTestInvoice(createTestClient(t, db), series, domain.InvoiceStatusUnpaid, today.AddDate(0, 0, -60))
+	require.NoError(t, invoiceRepo.Issue(ctx, planned, nil))
+	_, err := db.Exec(`UPDATE clients SET payment_plan_until = $1 WHERE id = $2`, today.AddDate(0, 1, 0), planned.ClientID)
+	require.NoError(t, err)
+
+	marked, err := repo.MarkOverdue(ctx, today)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, marked, 2)
+
+	stored, err := invoiceRepo.GetByID(ctx, overdue.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.IsOverdue())
+	stored, err = invoiceRepo.GetByID(ctx, current.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.IsOverdue())
+
+	dunning, err := repo.ListDunning(ctx, today)
+	require.NoError(t, err)
+	require.NotNil(t, findDunningInvoice(dunning, overdue.ID))
+	assert.Nil(t, findDunningInvoice(dunning, current.ID), "not due yet")
+	assert.Nil(t, findDunningInvoice(dunning, planned.ID), "client in a payment plan")
+
+	// Each level is sent once
+	reminder := &domain.InvoiceReminder{
+		ID:                uuid.New(),
+		InvoiceID:         overdue.ID,
+		Level:             1,
+		DaysOverdue:       30,
+		Recipient:         "client@example.com",
+		OutstandingAmount: overdue.TotalAmount,
+		PaymentReference:  stored.PaymentReference(),
+		SentAt:            time.Now(),
+	}
+	require.NoError(t, repo.Create(ctx, reminder))
+
+	duplicate := *reminder
+	duplicate.ID = uuid.New()
+	assert.Equal(t, domain.ErrReminderAlreadySent, repo.Create(ctx, &duplicate))
+
+	dunning, err = repo.ListDunning(ctx, today)
+	require.NoError(t, err)
+	assert.Equal(t, 1, findDunningInvoice(dunning, overdue.ID).RemindersSent)
+
+	// Settling the invoice clears the overdue flag and stops the reminders
+	_, err = paymentRepo.Create(ctx, newTestPayment(overdue.ID, overdue.TotalAmount.Decimal()))
+	require.NoError(t, err)
+
+	stored, err = invoiceRepo.GetByID(ctx, overdue.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.OverdueAt)
+
+	dunning, err = repo.ListDunning(ctx, today)
+	require.NoError(t, err)
+	assert.Nil(t, findDunningInvoice(dunning, overdue.ID))
+}
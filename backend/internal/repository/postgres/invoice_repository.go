@@ -10,10 +10,46 @@ import (
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
 	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+var (
+	// errIssuedInvoiceImmutable is returned when the database refuses to change an issued invoice
+	errIssuedInvoiceImmutable = errors.NewConflictError("issued invoices cannot be modified or deleted; create a rectifying invoice instead", errors.CodeConflict)
+
+	errInvoiceAlreadyIssued      = errors.NewConflictError("invoice has already been issued", errors.CodeConflict)
+	errInvoiceSeriesRequired     = errors.NewValidationError("invoice series is required to issue an invoice", nil)
+	errInactiveInvoiceSeries     = errors.NewConflictError("invoice series is inactive", errors.CodeConflict)
+	errInvoiceSeriesTypeMismatch = errors.NewValidationError("invoice series does not match the invoice type", nil)
+
+	// errAppointmentPaidWithPack is returned when one of the appointments was paid with a session pack
+	errAppointmentPaidWithPack = errors.NewConflictError("appointment was paid with a session pack", errors.CodeConflict)
+)
+
+// errAppointmentAlreadyInvoiced is returned when another invoice already bills one of the appointments
+func errAppointmentAlreadyInvoiced(invoiceNumber string) error {
+	return errors.NewConflictError(fmt.Sprintf("appointment already invoiced in %s", invoiceNumber), errors.CodeConflict)
+}
+
+// billsAppointments holds for an invoice i that still bills its appointments: it is not
+// rectifying nor deleted and its issued rectifying invoices do not cancel it entirely
+const billsAppointments = `
+	i.deleted_at IS NULL AND i.invoice_type <> 'rectifying'
+	AND NOT EXISTS (
+		SELECT 1 FROM invoices r
+		WHERE r.rectified_invoice_id = i.id AND r.deleted_at IS NULL AND r.status <> 'draft'
+		GROUP BY r.rectified_invoice_id
+		HAVING bool_or(r.rectification_mode = 'cancellation') OR i.total_amount + SUM(r.total_amount) = 0
+	)`
+
+// isImmutableInvoiceError reports whether err was raised by the issued invoice triggers
+func isImmutableInvoiceError(err error) bool {
+	return strings.Contains(err.Error(), "issued invoices are immutable")
+}
+
 type invoiceRepository struct {
 	db *sqlx.DB
 }
@@ -23,25 +59,20 @@ func NewInvoiceRepository(db *sqlx.DB) repository.InvoiceRepository {
 	return &invoiceRepository{db: db}
 }
 
-// Create creates a new invoice
+// Create creates a new invoice together with its lines
 func (r *invoiceRepository) Create(ctx context.Context, invoice *domain.Invoice) error {
-	query := `
-		INSERT INTO invoices (
-			id, invoice_number, client_id, appointment_id, issue_date, due_date, description,
-			base_amount, vat_rate, vat_amount, total_amount, status, notes, created_at, updated_at
-		) VALUES (
-			:id, :invoice_number, :client_id, :appointment_id, :issue_date, :due_date, :description,
-			:base_amount, :vat_rate, :vat_amount, :total_amount, :status, :notes, :created_at, :updated_at
-		)`
-
-	_, err := r.db.NamedExecContext(ctx, query, invoice)
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			if strings.Contains(err.Error(), "invoice_number") {
-				return errors.NewConflictError("invoice number already exists", errors.CodeConflict)
-			}
-		}
-		return fmt.Errorf("failed to create invoice: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertInvoice(ctx, tx, invoice); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit invoice: %w", err)
 	}
 
 	return nil
@@ -60,6 +91,10 @@ func (r *invoiceRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.
 		return nil, fmt.Errorf("failed to get invoice: %w", err)
 	}
 
+	if err := r.loadLines(ctx, &invoice); err != nil {
+		return nil, err
+	}
+
 	return &invoice, nil
 }
 
@@ -76,6 +111,10 @@ func (r *invoiceRepository) GetByInvoiceNumber(ctx context.Context, invoiceNumbe
 		return nil, fmt.Errorf("failed to get invoice: %w", err)
 	}
 
+	if err := r.loadLines(ctx, &invoice); err != nil {
+		return nil, err
+	}
+
 	return &invoice, nil
 }
 
@@ -92,6 +131,18 @@ func (r *invoiceRepository) List(ctx context.Context, filters repository.Invoice
 		args = append(args, *filters.Status)
 	}
 
+	if filters.InvoiceType != nil {
+		argCount++
+		conditions = append(conditions, fmt.Sprintf("invoice_type = $%d", argCount))
+		args = append(args, *filters.InvoiceType)
+	}
+
+	if filters.SeriesID != nil {
+		argCount++
+		conditions = append(conditions, fmt.Sprintf("series_id = $%d", argCount))
+		args = append(args, *filters.SeriesID)
+	}
+
 	if filters.ClientID != nil {
 		argCount++
 		conditions = append(conditions, fmt.Sprintf("client_id = $%d", argCount))
@@ -161,48 +212,129 @@ func (r *invoiceRepository) List(ctx context.Context, filters repository.Invoice
 	return invoices, total, nil
 }
 
-// Update updates an existing invoice
+// Update updates an existing invoice; when Lines is set they replace the stored lines.
+// The database refuses changes to issued invoices other than their payment status.
 func (r *invoiceRepository) Update(ctx context.Context, invoice *domain.Invoice) error {
-	query := `
-		UPDATE invoices SET
-			invoice_number = :invoice_number,
-			client_id = :client_id,
-			appointment_id = :appointment_id,
-			issue_date = :issue_date,
-			due_date = :due_date,
-			description = :description,
-			base_amount = :base_amount,
-			vat_rate = :vat_rate,
-			vat_amount = :vat_amount,
-			total_amount = :total_amount,
-			status = :status,
-			notes = :notes,
-			updated_at = :updated_at
-		WHERE id = :id AND deleted_at IS NULL`
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateInvoice(ctx, tx, invoice); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit invoice: %w", err)
+	}
 
-	result, err := r.db.NamedExecContext(ctx, query, invoice)
+	return nil
+}
+
+// Issue stores an issued invoice with the next number of its series, inserting it or
+// updating its draft. The series counter stays locked until the invoice is stored, so
+// concurrent calls never share a number and a failed call leaves no gap. The records built
+// by recorder, when given, are chained in the same transaction.
+func (r *invoiceRepository) Issue(ctx context.Context, invoice *domain.Invoice, recorder repository.InvoiceIssueRecorder) (err error) {
+	if invoice.SeriesID == nil {
+		return errInvoiceSeriesRequired
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to update invoice: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the draft being issued so it cannot be issued twice
+	var status domain.InvoiceStatus
+	exists := true
+	err = tx.GetContext(ctx, &status, `SELECT status FROM invoices WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, invoice.ID)
+	switch {
+	case err == sql.ErrNoRows:
+		exists = false
+	case err != nil:
+		return fmt.Errorf("failed to lock invoice: %w", err)
+	case status != domain.InvoiceStatusDraft:
+		return errInvoiceAlreadyIssued
 	}
 
-	rows, err := result.RowsAffected()
+	var series domain.InvoiceSeries
+	if err := tx.GetContext(ctx, &series, `SELECT * FROM invoice_series WHERE id = $1`, *invoice.SeriesID); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.NewNotFoundError("invoice series not found")
+		}
+		return fmt.Errorf("failed to get invoice series: %w", err)
+	}
+	if !series.IsActive {
+		return errInactiveInvoiceSeries
+	}
+	if series.InvoiceType != invoice.InvoiceType {
+		return errInvoiceSeriesTypeMismatch
+	}
+
+	// The counter row stays locked by the UPDATE until commit, serialising the issuers of
+	// the series; a rollback also undoes the increment, so no number is ever skipped
+	year := series.CounterYear(invoice.IssueDate)
+	counterQuery := `
+		INSERT INTO invoice_series_counters (series_id, year, last_number)
+		VALUES ($1, $2, 0)
+		ON CONFLICT (series_id, year) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, counterQuery, series.ID, year); err != nil {
+		return fmt.Errorf("failed to create invoice series counter: %w", err)
+	}
+
+	var sequence int
+	nextQuery := `
+		UPDATE invoice_series_counters SET last_number = last_number + 1
+		WHERE series_id = $1 AND year = $2
+		RETURNING last_number`
+	if err := tx.GetContext(ctx, &sequence, nextQuery, series.ID, year); err != nil {
+		return fmt.Errorf("failed to get next invoice number: %w", err)
+	}
+
+	// Restore the draft number if the invoice cannot be stored
+	previousNumber, previousYear, previousSequence := invoice.InvoiceNumber, invoice.NumberYear, invoice.SequenceNumber
+	defer func() {
+		if err != nil {
+			invoice.InvoiceNumber, invoice.NumberYear, invoice.SequenceNumber = previousNumber, previousYear, previousSequence
+		}
+	}()
+
+	invoice.InvoiceNumber = series.FormatNumber(year, sequence)
+	invoice.NumberYear = &year
+	invoice.SequenceNumber = &sequence
+
+	if exists {
+		err = updateInvoice(ctx, tx, invoice)
+	} else {
+		err = insertInvoice(ctx, tx, invoice)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return err
 	}
 
-	if rows == 0 {
-		return errors.NewNotFoundError("invoice not found")
+	// An invoice is never issued without its records
+	if recorder != nil {
+		if err = appendIssueRecords(ctx, tx, invoice, recorder); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit invoice: %w", err)
 	}
 
 	return nil
 }
 
-// Delete soft deletes an invoice
-func (r *invoiceRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `UPDATE invoices SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
-	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+// UpdatePDFPath records the storage key of the cached PDF without touching the invoice data
+func (r *invoiceRepository) UpdatePDFPath(ctx context.Context, id uuid.UUID, pdfPath *string) error {
+	query := `UPDATE invoices SET pdf_path = $1 WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, pdfPath, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete invoice: %w", err)
+		return fmt.Errorf("failed to update invoice PDF path: %w", err)
 	}
 
 	rows, err := result.RowsAffected()
@@ -217,26 +349,27 @@ func (r *invoiceRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-// GetNextInvoiceNumber generates the next invoice number for the given year
-func (r *invoiceRepository) GetNextInvoiceNumber(ctx context.Context, year int) (string, error) {
-	var maxNumber sql.NullInt64
-	query := `
-		SELECT MAX(CAST(SUBSTRING(invoice_number FROM 8) AS INTEGER))
-		FROM invoices
-		WHERE invoice_number LIKE $1 AND deleted_at IS NULL`
+// Delete soft deletes a draft invoice; issued invoices cannot be deleted
+func (r *invoiceRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE invoices SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		if isImmutableInvoiceError(err) {
+			return errIssuedInvoiceImmutable
+		}
+		return fmt.Errorf("failed to delete invoice: %w", err)
+	}
 
-	pattern := fmt.Sprintf("F_%d_%%", year)
-	err := r.db.GetContext(ctx, &maxNumber, query, pattern)
+	rows, err := result.RowsAffected()
 	if err != nil {
-		return "", fmt.Errorf("failed to get next invoice number: %w", err)
+		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	nextNumber := 1
-	if maxNumber.Valid {
-		nextNumber = int(maxNumber.Int64) + 1
+	if rows == 0 {
+		return errors.NewNotFoundError("invoice not found")
 	}
 
-	return fmt.Sprintf("F_%d_%04d", year, nextNumber), nil
+	return nil
 }
 
 // GetByClientID retrieves all invoices for a specific client
@@ -255,10 +388,18 @@ func (r *invoiceRepository) GetByClientID(ctx context.Context, clientID uuid.UUI
 	return invoices, nil
 }
 
-// GetByAppointmentID retrieves the invoice associated with an appointment
+// GetByAppointmentID retrieves the invoice billing an appointment, alone or as a line of a
+// grouped invoice; rectifying invoices and invoices they cancel entirely are not considered
 func (r *invoiceRepository) GetByAppointmentID(ctx context.Context, appointmentID uuid.UUID) (*domain.Invoice, error) {
 	var invoice domain.Invoice
-	query := `SELECT * FROM invoices WHERE appointment_id = $1 AND deleted_at IS NULL`
+	query := fmt.Sprintf(`
+		SELECT * FROM invoices i
+		WHERE %s
+		  AND (i.appointment_id = $1 OR EXISTS (
+			SELECT 1 FROM invoice_lines l WHERE l.invoice_id = i.id AND l.appointment_id = $1
+		  ))
+		ORDER BY i.created_at
+		LIMIT 1`, billsAppointments)
 
 	err := r.db.GetContext(ctx, &invoice, query, appointmentID)
 	if err != nil {
@@ -268,12 +409,16 @@ func (r *invoiceRepository) GetByAppointmentID(ctx context.Context, appointmentI
 		return nil, fmt.Errorf("failed to get invoice by appointment: %w", err)
 	}
 
+	if err := r.loadLines(ctx, &invoice); err != nil {
+		return nil, err
+	}
+
 	return &invoice, nil
 }
 
 // GetTotalRevenueByDateRange calculates total revenue between dates
-func (r *invoiceRepository) GetTotalRevenueByDateRange(ctx context.Context, fromDate, toDate time.Time) (float64, error) {
-	var total sql.NullFloat64
+func (r *invoiceRepository) GetTotalRevenueByDateRange(ctx context.Context, fromDate, toDate time.Time) (money.Money, error) {
+	var total money.Money
 	query := `
 		SELECT COALESCE(SUM(total_amount), 0)
 		FROM invoices
@@ -284,22 +429,63 @@ func (r *invoiceRepository) GetTotalRevenueByDateRange(ctx context.Context, from
 
 	err := r.db.GetContext(ctx, &total, query, fromDate, toDate)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get total revenue: %w", err)
+		return money.Money{}, fmt.Errorf("failed to get total revenue: %w", err)
+	}
+
+	return total, nil
+}
+
+// GetIssuedByPeriod sums the totals of the invoices issued between dates, grouped by the
+// period of their issue date; rectifying invoices subtract their corrections
+func (r *invoiceRepository) GetIssuedByPeriod(ctx context.Context, granularity repository.PeriodGranularity, fromDate, toDate time.Time) ([]repository.PeriodTotal, error) {
+	totals := []repository.PeriodTotal{}
+	query := `
+		SELECT date_trunc($1, issue_date::timestamp)::date AS period_start, COALESCE(SUM(total_amount), 0) AS total
+		FROM invoices
+		WHERE issue_date >= $2
+		AND issue_date <= $3
+		AND status <> $4
+		AND deleted_at IS NULL
+		GROUP BY period_start
+		ORDER BY period_start`
+
+	err := r.db.SelectContext(ctx, &totals, query, string(granularity), fromDate, toDate, domain.InvoiceStatusDraft)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issued revenue by period: %w", err)
 	}
 
-	if !total.Valid {
-		return 0, nil
+	return totals, nil
+}
+
+// GetCollectedByPeriod sums the payments not reversed made between dates, grouped by the
+// period of their payment date
+func (r *invoiceRepository) GetCollectedByPeriod(ctx context.Context, granularity repository.PeriodGranularity, fromDate, toDate time.Time) ([]repository.PeriodTotal, error) {
+	totals := []repository.PeriodTotal{}
+	query := `
+		SELECT date_trunc($1, p.payment_date::timestamp)::date AS period_start, COALESCE(SUM(p.amount), 0) AS total
+		FROM payments p
+		JOIN invoices i ON i.id = p.invoice_id
+		WHERE p.payment_date >= $2
+		AND p.payment_date <= $3
+		AND p.reversed_at IS NULL
+		AND i.deleted_at IS NULL
+		GROUP BY period_start
+		ORDER BY period_start`
+
+	err := r.db.SelectContext(ctx, &totals, query, string(granularity), fromDate, toDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collected revenue by period: %w", err)
 	}
 
-	return total.Float64, nil
+	return totals, nil
 }
 
-// GetUnpaidInvoices retrieves all unpaid invoices
+// GetUnpaidInvoices retrieves the issued invoices with an outstanding balance (unpaid or partially paid)
 func (r *invoiceRepository) GetUnpaidInvoices(ctx context.Context) ([]*domain.Invoice, error) {
 	var invoices []*domain.Invoice
 	query := `
 		SELECT * FROM invoices 
-		WHERE status = 'unpaid' AND deleted_at IS NULL 
+		WHERE status IN ('unpaid', 'partially_paid') AND deleted_at IS NULL 
 		ORDER BY due_date ASC`
 
 	err := r.db.SelectContext(ctx, &invoices, query)
@@ -309,3 +495,418 @@ func (r *invoiceRepository) GetUnpaidInvoices(ctx context.Context) ([]*domain.In
 
 	return invoices, nil
 }
+
+// GetRectifyingInvoices retrieves the rectifying invoices of an invoice, with their lines
+func (r *invoiceRepository) GetRectifyingInvoices(ctx context.Context, invoiceID uuid.UUID) ([]*domain.Invoice, error) {
+	invoices := []*domain.Invoice{}
+	query := `
+		SELECT * FROM invoices
+		WHERE rectified_invoice_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at ASC`
+
+	if err := r.db.SelectContext(ctx, &invoices, query, invoiceID); err != nil {
+		return nil, fmt.Errorf("failed to get rectifying invoices: %w", err)
+	}
+
+	if err := r.loadLinesOf(ctx, invoices); err != nil {
+		return nil, fmt.Errorf("failed to get rectifying invoice lines: %w", err)
+	}
+
+	return invoices, nil
+}
+
+// GetIssuedByDateRange retrieves the invoices issued between dates, rectifying ones
+// included, with their lines and clients, ordered by issue date and number
+func (r *invoiceRepository) GetIssuedByDateRange(ctx context.Context, fromDate, toDate time.Time) ([]*domain.Invoice, error) {
+	invoices := []*domain.Invoice{}
+	query := `
+		SELECT * FROM invoices
+		WHERE issue_date >= $1
+		AND issue_date <= $2
+		AND status <> $3
+		AND deleted_at IS NULL
+		ORDER BY issue_date ASC, invoice_number ASC`
+
+	if err := r.db.SelectContext(ctx, &invoices, query, fromDate, toDate, domain.InvoiceStatusDraft); err != nil {
+		return nil, fmt.Errorf("failed to get issued invoices: %w", err)
+	}
+
+	if err := r.loadLinesOf(ctx, invoices); err != nil {
+		return nil, fmt.Errorf("failed to get issued invoice lines: %w", err)
+	}
+
+	if err := r.loadClientsOf(ctx, invoices); err != nil {
+		return nil, fmt.Errorf("failed to get clients of issued invoices: %w", err)
+	}
+
+	return invoices, nil
+}
+
+// GetOutstandingInvoices retrieves the issued invoices with an outstanding balance and their
+// clients, filtered by client, by the employee who attended their appointments or by the
+// service they bill, ordered by due date
+func (r *invoiceRepository) GetOutstandingInvoices(ctx context.Context, filters repository.OutstandingInvoiceFilters) ([]*domain.Invoice, error) {
+	conditions := []string{"i.deleted_at IS NULL"}
+	args := []interface{}{}
+
+	if filters.AsOf != nil {
+		// Invoices settled since then were still outstanding on the day
+		args = append(args, *filters.AsOf)
+		conditions = append(conditions, fmt.Sprintf(`i.status <> 'draft' AND i.issue_date <= $%[1]d::date AND (
+			i.status IN ('unpaid', 'partially_paid')
+			OR EXISTS (SELECT 1 FROM payments p WHERE p.invoice_id = i.id AND p.payment_date > $%[1]d::date)
+		)`, len(args)))
+	} else {
+		conditions = append(conditions, "i.status IN ('unpaid', 'partially_paid')")
+	}
+
+	if filters.ClientID != nil {
+		args = append(args, *filters.ClientID)
+		conditions = append(conditions, fmt.Sprintf("i.client_id = $%d", len(args)))
+	}
+
+	if filters.EmployeeID != nil {
+		args = append(args, *filters.EmployeeID)
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM appointments a
+			WHERE a.employee_id = $%d
+			  AND (a.id = i.appointment_id OR a.id IN (SELECT l.appointment_id FROM invoice_lines l WHERE l.invoice_id = i.id))
+		)`, len(args)))
+	}
+
+	if filters.ServiceTypeID != nil {
+		args = append(args, *filters.ServiceTypeID)
+		conditions = append(conditions, fmt.Sprintf(`(
+			EXISTS (SELECT 1 FROM invoice_lines l WHERE l.invoice_id = i.id AND l.service_type_id = $%[1]d)
+			OR EXISTS (SELECT 1 FROM appointments a WHERE a.id = i.appointment_id AND a.service_type_id = $%[1]d)
+		)`, len(args)))
+	}
+
+	invoices := []*domain.Invoice{}
+	query := fmt.Sprintf(`
+		SELECT i.* FROM invoices i
+		WHERE %s
+		ORDER BY i.due_date ASC, i.invoice_number ASC`, strings.Join(conditions, " AND "))
+
+	if err := r.db.SelectContext(ctx, &invoices, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get outstanding invoices: %w", err)
+	}
+
+	if filters.AsOf != nil {
+		if err := r.loadPaidAmountsAsOf(ctx, invoices, *filters.AsOf); err != nil {
+			return nil, fmt.Errorf("failed to get paid amounts of outstanding invoices: %w", err)
+		}
+	}
+
+	if err := r.loadClientsOf(ctx, invoices); err != nil {
+		return nil, fmt.Errorf("failed to get clients of outstanding invoices: %w", err)
+	}
+
+	return invoices, nil
+}
+
+// loadPaidAmountsAsOf sets the paid amount of several invoices to the payments made by the
+// end of a day, including those reversed later
+func (r *invoiceRepository) loadPaidAmountsAsOf(ctx context.Context, invoices []*domain.Invoice, asOf time.Time) error {
+	if len(invoices) == 0 {
+		return nil
+	}
+
+	invoiceIDs := make([]string, 0, len(invoices))
+	for _, invoice := range invoices {
+		invoiceIDs = append(invoiceIDs, invoice.ID.String())
+	}
+
+	paid := []struct {
+		InvoiceID uuid.UUID   `db:"invoice_id"`
+		Amount    money.Money `db:"amount"`
+	}{}
+	query := `
+		SELECT invoice_id, SUM(amount) AS amount FROM payments
+		WHERE invoice_id = ANY($1::uuid[]) AND payment_date <= $2::date
+		  AND (reversed_at IS NULL OR reversed_at::date > $2::date)
+		GROUP BY invoice_id`
+	if err := r.db.SelectContext(ctx, &paid, query, pq.Array(invoiceIDs), asOf); err != nil {
+		return err
+	}
+
+	byID := make(map[uuid.UUID]money.Money, len(paid))
+	for _, row := range paid {
+		byID[row.InvoiceID] = row.Amount
+	}
+	for _, invoice := range invoices {
+		invoice.PaidAmount = byID[invoice.ID]
+	}
+
+	return nil
+}
+
+// loadClientsOf populates the clients of several invoices with a single query; deleted
+// clients are loaded too, since their invoices remain
+func (r *invoiceRepository) loadClientsOf(ctx context.Context, invoices []*domain.Invoice) error {
+	if len(invoices) == 0 {
+		return nil
+	}
+
+	clientIDs := make([]string, 0, len(invoices))
+	for _, invoice := range invoices {
+		clientIDs = append(clientIDs, invoice.ClientID.String())
+	}
+
+	clients := []*domain.Client{}
+	query := fmt.Sprintf(`SELECT %s FROM clients WHERE id = ANY($1::uuid[])`, clientColumns)
+	if err := r.db.SelectContext(ctx, &clients, query, pq.Array(clientIDs)); err != nil {
+		return err
+	}
+
+	byID := make(map[uuid.UUID]*domain.Client, len(clients))
+	for _, client := range clients {
+		byID[client.ID] = client
+	}
+	for _, invoice := range invoices {
+		invoice.Client = byID[invoice.ClientID]
+	}
+
+	return nil
+}
+
+// loadLinesOf populates the lines of several invoices with a single query
+func (r *invoiceRepository) loadLinesOf(ctx context.Context, invoices []*domain.Invoice) error {
+	if len(invoices) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(invoices))
+	byID := make(map[uuid.UUID]*domain.Invoice, len(invoices))
+	for _, invoice := range invoices {
+		invoice.Lines = []*domain.InvoiceLine{}
+		ids = append(ids, invoice.ID.String())
+		byID[invoice.ID] = invoice
+	}
+
+	lines := []*domain.InvoiceLine{}
+	query := `SELECT * FROM invoice_lines WHERE invoice_id = ANY($1::uuid[]) ORDER BY invoice_id, position ASC`
+	if err := r.db.SelectContext(ctx, &lines, query, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		invoice := byID[line.InvoiceID]
+		invoice.Lines = append(invoice.Lines, line)
+	}
+
+	return nil
+}
+
+// loadLines populates the lines of an invoice ordered by position
+func (r *invoiceRepository) loadLines(ctx context.Context, invoice *domain.Invoice) error {
+	lines := []*domain.InvoiceLine{}
+	query := `SELECT * FROM invoice_lines WHERE invoice_id = $1 ORDER BY position ASC`
+
+	if err := r.db.SelectContext(ctx, &lines, query, invoice.ID); err != nil {
+		return fmt.Errorf("failed to get invoice lines: %w", err)
+	}
+
+	invoice.Lines = lines
+	return nil
+}
+
+// nilUUID is the client_id of invoices without a client, stored as NULL: the settlement
+// invoices of an insurer
+const nilUUID = `CAST('00000000-0000-0000-0000-000000000000' AS UUID)`
+
+// insertInvoice stores a new invoice and its lines inside a transaction
+func insertInvoice(ctx context.Context, tx *sqlx.Tx, invoice *domain.Invoice) error {
+	query := `
+		INSERT INTO invoices (
+			id, invoice_number, client_id, appointment_id, issue_date, due_date, description,
+			base_amount, vat_rate, vat_amount, irpf_rate, irpf_amount, total_amount, status, notes,
+			invoice_type, rectified_invoice_id, rectification_mode, rectification_reason,
+			series_id, number_year, sequence_number, billing_profile_id, payer_name, payer_tax_id,
+			payer_email, payer_address_street, payer_address_city, payer_address_province,
+			payer_address_postal_code, payer_address_country, created_at, updated_at
+		) VALUES (
+			:id, :invoice_number, NULLIF(:client_id, ` + nilUUID + `), :appointment_id, :issue_date, :due_date, :description,
+			:base_amount, :vat_rate, :vat_amount, :irpf_rate, :irpf_amount, :total_amount, :status, :notes,
+			:invoice_type, :rectified_invoice_id, :rectification_mode, :rectification_reason,
+			:series_id, :number_year, :sequence_number, :billing_profile_id, :payer_name, :payer_tax_id,
+			:payer_email, :payer_address_street, :payer_address_city, :payer_address_province,
+			:payer_address_postal_code, :payer_address_country, :created_at, :updated_at
+		)`
+
+	if err := lockInvoicedAppointments(ctx, tx, invoice); err != nil {
+		return err
+	}
+
+	if _, err := tx.NamedExecContext(ctx, query, invoice); err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			if strings.Contains(err.Error(), "invoice_number") || strings.Contains(err.Error(), "series_sequence") {
+				return errors.NewConflictError("invoice number already exists", errors.CodeConflict)
+			}
+		}
+		return fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	return insertInvoiceLines(ctx, tx, invoice.Lines)
+}
+
+// updateInvoice updates an invoice inside a transaction; when Lines is set they replace the stored lines
+func updateInvoice(ctx context.Context, tx *sqlx.Tx, invoice *domain.Invoice) error {
+	// Lines are replaced first, while the stored invoice is still a draft when it is being issued
+	if invoice.Lines != nil {
+		if err := lockInvoicedAppointments(ctx, tx, invoice); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM invoice_lines WHERE invoice_id = $1`, invoice.ID); err != nil {
+			if isImmutableInvoiceError(err) {
+				return errIssuedInvoiceImmutable
+			}
+			return fmt.Errorf("failed to replace invoice lines: %w", err)
+		}
+		if err := insertInvoiceLines(ctx, tx, invoice.Lines); err != nil {
+			if isImmutableInvoiceError(err) {
+				return errIssuedInvoiceImmutable
+			}
+			return err
+		}
+	}
+
+	query := `
+		UPDATE invoices SET
+			invoice_number = :invoice_number,
+			client_id = NULLIF(:client_id, ` + nilUUID + `),
+			appointment_id = :appointment_id,
+			issue_date = :issue_date,
+			due_date = :due_date,
+			description = :description,
+			base_amount = :base_amount,
+			vat_rate = :vat_rate,
+			vat_amount = :vat_amount,
+			irpf_rate = :irpf_rate,
+			irpf_amount = :irpf_amount,
+			total_amount = :total_amount,
+			status = :status,
+			notes = :notes,
+			invoice_type = :invoice_type,
+			rectified_invoice_id = :rectified_invoice_id,
+			rectification_mode = :rectification_mode,
+			rectification_reason = :rectification_reason,
+			series_id = :series_id,
+			number_year = :number_year,
+			sequence_number = :sequence_number,
+			billing_profile_id = :billing_profile_id,
+			payer_name = :payer_name,
+			payer_tax_id = :payer_tax_id,
+			payer_email = :payer_email,
+			payer_address_street = :payer_address_street,
+			payer_address_city = :payer_address_city,
+			payer_address_province = :payer_address_province,
+			payer_address_postal_code = :payer_address_postal_code,
+			payer_address_country = :payer_address_country,
+			updated_at = :updated_at
+		WHERE id = :id AND deleted_at IS NULL`
+
+	result, err := tx.NamedExecContext(ctx, query, invoice)
+	if err != nil {
+		if isImmutableInvoiceError(err) {
+			return errIssuedInvoiceImmutable
+		}
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			if strings.Contains(err.Error(), "invoice_number") || strings.Contains(err.Error(), "series_sequence") {
+				return errors.NewConflictError("invoice number already exists", errors.CodeConflict)
+			}
+		}
+		return fmt.Errorf("failed to update invoice: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("invoice not found")
+	}
+
+	return nil
+}
+
+// lockInvoicedAppointments locks the appointments billed by an invoice until its transaction
+// ends and refuses them when another invoice already bills one. Concurrent transactions
+// invoicing the same appointment wait for each other, so the later one sees the first
+// invoice; rectifying invoices may reference the appointments of the invoice they correct,
+// and the appointments of an entirely cancelled invoice may be invoiced again. Appointments
+// paid with a session pack are refused as well.
+func lockInvoicedAppointments(ctx context.Context, tx *sqlx.Tx, invoice *domain.Invoice) error {
+	if invoice.IsRectifying() {
+		return nil
+	}
+
+	appointmentIDs := []string{}
+	if invoice.AppointmentID != nil {
+		appointmentIDs = append(appointmentIDs, invoice.AppointmentID.String())
+	}
+	for _, line := range invoice.Lines {
+		if line.AppointmentID != nil {
+			appointmentIDs = append(appointmentIDs, line.AppointmentID.String())
+		}
+	}
+	if len(appointmentIDs) == 0 {
+		return nil
+	}
+
+	// Locked in a fixed order so two invoices sharing several appointments cannot deadlock
+	lockQuery := `SELECT id FROM appointments WHERE id = ANY($1::uuid[]) ORDER BY id FOR UPDATE`
+	if _, err := tx.ExecContext(ctx, lockQuery, pq.Array(appointmentIDs)); err != nil {
+		return fmt.Errorf("failed to lock invoiced appointments: %w", err)
+	}
+
+	var prepaid bool
+	prepaidQuery := `SELECT EXISTS (SELECT 1 FROM session_pack_usages WHERE appointment_id = ANY($1::uuid[]))`
+	if err := tx.GetContext(ctx, &prepaid, prepaidQuery, pq.Array(appointmentIDs)); err != nil {
+		return fmt.Errorf("failed to check session pack usages: %w", err)
+	}
+	if prepaid {
+		return errAppointmentPaidWithPack
+	}
+
+	var invoiceNumber string
+	query := fmt.Sprintf(`
+		SELECT i.invoice_number FROM invoices i
+		WHERE %s AND i.id <> $2
+		  AND (i.appointment_id = ANY($1::uuid[]) OR EXISTS (
+			SELECT 1 FROM invoice_lines l WHERE l.invoice_id = i.id AND l.appointment_id = ANY($1::uuid[])
+		  ))
+		LIMIT 1`, billsAppointments)
+	err := tx.GetContext(ctx, &invoiceNumber, query, pq.Array(appointmentIDs), invoice.ID)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to check invoiced appointments: %w", err)
+	}
+
+	return errAppointmentAlreadyInvoiced(invoiceNumber)
+}
+
+// insertInvoiceLines stores the lines of an invoice inside a transaction
+func insertInvoiceLines(ctx context.Context, tx *sqlx.Tx, lines []*domain.InvoiceLine) error {
+	query := `
+		INSERT INTO invoice_lines (
+			id, invoice_id, position, description, quantity, unit_price, discount_percent,
+			vat_rate, vat_exemption_cause, base_amount, vat_amount, total_amount, appointment_id,
+			service_type_id, created_at, updated_at
+		) VALUES (
+			:id, :invoice_id, :position, :description, :quantity, :unit_price, :discount_percent,
+			:vat_rate, :vat_exemption_cause, :base_amount, :vat_amount, :total_amount, :appointment_id,
+			:service_type_id, :created_at, :updated_at
+		)`
+
+	for _, line := range lines {
+		if _, err := tx.NamedExecContext(ctx, query, line); err != nil {
+			return fmt.Errorf("failed to create invoice line: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,430 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/database"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDB connects to the database of TEST_DATABASE_URL and applies the migrations;
+// tests using it are skipped when the variable is not set
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set; skipping PostgreSQL integration test")
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, database.RunMigrations(db.DB, "../../../migrations"))
+	return db
+}
+
+// createTestSeries creates a series with a unique prefix, so runs do not share counters
+func createTestSeries(t *testing.T, db *sqlx.DB, yearlyReset bool) *domain.InvoiceSeries {
+	t.Helper()
+
+	series := &domain.InvoiceSeries{
+		ID:          uuid.New(),
+		Name:        "Test series",
+		Prefix:      "T" + fmt.Sprintf("%08X", uuid.New().ID())[:6],
+		InvoiceType: domain.InvoiceTypeOrdinary,
+		YearlyReset: yearlyReset,
+		Padding:     4,
+		IsActive:    true,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	require.NoError(t, NewInvoiceSeriesRepository(db).Create(context.Background(), series))
+	return series
+}
+
+func createTestClient(t *testing.T, db *sqlx.DB) uuid.UUID {
+	t.Helper()
+
+	id := uuid.New()
+	_, err := db.Exec(`INSERT INTO clients (id, email, first_name, last_name) VALUES ($1, $2, 'Test', 'Client')`,
+		id, fmt.Sprintf("%s@example.com", id))
+	require.NoError(t, err)
+	return id
+}
+
+func newTestInvoice(clientID uuid.UUID, series *domain.InvoiceSeries, status domain.InvoiceStatus, issueDate time.Time) *domain.Invoice {
+	id := uuid.New()
+	invoice := &domain.Invoice{
+		ID:            id,
+		InvoiceNumber: domain.DraftInvoiceNumber(id),
+		ClientID:      clientID,
+		IssueDate:     issueDate,
+		DueDate:       issueDate.AddDate(0, 0, 30),
+		Description:   "Sesión",
+		Status:        status,
+		InvoiceType:   series.InvoiceType,
+		SeriesID:      &series.ID,
+		Lines: []*domain.InvoiceLine{
+			{ID: uuid.New(), Description: "Sesión", Quantity: 1, UnitPrice: money.MustParse("60"), VATRate: 21, CreatedAt: issueDate, UpdatedAt: issueDate},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := invoice.CalculateAmounts(); err != nil {
+		panic(err)
+	}
+	return invoice
+}
+
+func TestInvoiceRepository_Issue_ConcurrentNumbersAreGapFree(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewInvoiceRepository(db)
+	ctx := context.Background()
+
+	const invoices = 60
+	series := createTestSeries(t, db, true)
+	clientID := createTestClient(t, db)
+	issueDate := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	// Half of the invoices are issued directly and half are drafts issued later
+	drafts := make([]*domain.Invoice, 0, invoices/2)
+	for i := 0; i < invoices/2; i++ {
+		draft := newTestInvoice(clientID, series, domain.InvoiceStatusDraft, issueDate)
+		require.NoError(t, repo.Create(ctx, draft))
+		draft.Status = domain.InvoiceStatusUnpaid
+		drafts = append(drafts, draft)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, invoices)
+	for i := 0; i < invoices; i++ {
+		invoice := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, issueDate)
+		if i%2 == 0 {
+			invoice = drafts[i/2]
+		}
+
+		wg.Add(1)
+		go func(invoice *domain.Invoice) {
+			defer wg.Done()
+			errs <- repo.Issue(ctx, invoice, nil)
+		}(invoice)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	var sequences []int
+	err := db.Select(&sequences, `SELECT sequence_number FROM invoices WHERE series_id = $1 ORDER BY sequence_number`, series.ID)
+	require.NoError(t, err)
+	require.Len(t, sequences, invoices)
+	for i, sequence := range sequences {
+		assert.Equal(t, i+1, sequence)
+	}
+
+	var lastNumber int
+	err = db.Get(&lastNumber, `SELECT last_number FROM invoice_series_counters WHERE series_id = $1 AND year = 2026`, series.ID)
+	require.NoError(t, err)
+	assert.Equal(t, invoices, lastNumber)
+
+	issued, err := repo.GetByInvoiceNumber(ctx, series.FormatNumber(2026, invoices))
+	require.NoError(t, err)
+	assert.Equal(t, domain.InvoiceStatusUnpaid, issued.Status)
+}
+
+func TestInvoiceRepository_Issue_DraftIsIssuedOnce(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewInvoiceRepository(db)
+	ctx := context.Background()
+
+	series := createTestSeries(t, db, false)
+	clientID := createTestClient(t, db)
+	draft := newTestInvoice(clientID, series, domain.InvoiceStatusDraft, time.Now())
+	require.NoError(t, repo.Create(ctx, draft))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		attempt := *draft
+		attempt.Status = domain.InvoiceStatusUnpaid
+		wg.Add(1)
+		go func(invoice *domain.Invoice) {
+			defer wg.Done()
+			errs <- repo.Issue(ctx, invoice, nil)
+		}(&attempt)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		} else {
+			assert.Equal(t, errInvoiceAlreadyIssued, err)
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+
+	// The failed attempts did not consume numbers
+	var lastNumber int
+	err := db.Get(&lastNumber, `SELECT last_number FROM invoice_series_counters WHERE series_id = $1 AND year = 0`, series.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, lastNumber)
+
+	issued, err := repo.GetByID(ctx, draft.ID)
+	require.NoError(t, err)
+	assert.Equal(t, series.FormatNumber(0, 1), issued.InvoiceNumber)
+}
+
+func createTestAppointment(t *testing.T, db *sqlx.DB, clientID uuid.UUID) uuid.UUID {
+	t.Helper()
+
+	userID := uuid.New()
+	_, err := db.Exec(`INSERT INTO users (id, email, password_hash, first_name, last_name, role) VALUES ($1, $2, 'x', 'Test', 'Admin', 'admin')`,
+		userID, fmt.Sprintf("%s@example.com", userID))
+	require.NoError(t, err)
+
+	id := uuid.New()
+	start := time.Date(2026, 3, 10, 10, 0, 0, 0, time.UTC)
+	_, err = db.Exec(`
+		INSERT INTO appointments (id, client_id, title, start_time, end_time, duration_minutes, status, created_by)
+		VALUES ($1, $2, 'Sesión', $3, $4, 60, 'completed', $5)`,
+		id, clientID, start, start.Add(time.Hour), userID)
+	require.NoError(t, err)
+	return id
+}
+
+func TestInvoiceRepository_ConcurrentInvoicesOfAnAppointment(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewInvoiceRepository(db)
+	ctx := context.Background()
+
+	series := createTestSeries(t, db, false)
+	clientID := createTestClient(t, db)
+	appointmentID := createTestAppointment(t, db, clientID)
+
+	// Two batch runs, the completion hook and a manual invoice race for the same session,
+	// as drafts or issued directly
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		status := domain.InvoiceStatusDraft
+		if i%2 == 1 {
+			status = domain.InvoiceStatusUnpaid
+		}
+		invoice := newTestInvoice(clientID, series, status, time.Now())
+		invoice.Lines[0].AppointmentID = &appointmentID
+
+		wg.Add(1)
+		go func(invoice *domain.Invoice) {
+			defer wg.Done()
+			if invoice.Status == domain.InvoiceStatusDraft {
+				errs <- repo.Create(ctx, invoice)
+			} else {
+				errs <- repo.Issue(ctx, invoice, nil)
+			}
+		}(invoice)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		appErr, ok := err.(*errors.AppError)
+		require.True(t, ok, "unexpected error: %v", err)
+		assert.Equal(t, errors.CodeConflict, appErr.Code)
+	}
+	assert.Equal(t, 1, succeeded)
+
+	var invoiceIDs []uuid.UUID
+	err := db.Select(&invoiceIDs, `
+		SELECT DISTINCT i.id FROM invoices i JOIN invoice_lines l ON l.invoice_id = i.id
+		WHERE l.appointment_id = $1 AND i.deleted_at IS NULL`, appointmentID)
+	require.NoError(t, err)
+	require.Len(t, invoiceIDs, 1)
+
+	// A rectifying invoice may still reference the session
+	mode := domain.RectificationModeDifference
+	rectifying := newTestInvoice(clientID, series, domain.InvoiceStatusDraft, time.Now())
+	rectifying.InvoiceType = domain.InvoiceTypeRectifying
+	rectifying.RectifiedInvoiceID = &invoiceIDs[0]
+	rectifying.RectificationMode = &mode
+	rectifying.Lines[0].AppointmentID = &appointmentID
+	assert.NoError(t, repo.Create(ctx, rectifying))
+}
+
+func TestInvoiceRepository_CancelledInvoiceNoLongerBillsItsAppointment(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewInvoiceRepository(db)
+	appointmentRepo := NewAppointmentRepository(db)
+	ctx := context.Background()
+
+	series := createTestSeries(t, db, false)
+	rectifyingSeries := createTestSeries(t, db, false)
+	_, err := db.Exec(`UPDATE invoice_series SET invoice_type = 'rectifying' WHERE id = $1`, rectifyingSeries.ID)
+	require.NoError(t, err)
+	rectifyingSeries.InvoiceType = domain.InvoiceTypeRectifying
+
+	clientID := createTestClient(t, db)
+	appointmentID := createTestAppointment(t, db, clientID)
+	from, to := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	isUninvoiced := func() bool {
+		appointments, err := appointmentRepo.ListUninvoiced(ctx, from, to)
+		require.NoError(t, err)
+		for _, appointment := range appointments {
+			if appointment.ID == appointmentID {
+				return true
+			}
+		}
+		return false
+	}
+
+	original := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, time.Now())
+	original.Lines[0].AppointmentID = &appointmentID
+	require.NoError(t, repo.Issue(ctx, original, nil))
+	assert.False(t, isUninvoiced())
+
+	// A cancellation still in draft leaves the session invoiced
+	mode := domain.RectificationModeCancellation
+	cancelling := newTestInvoice(clientID, rectifyingSeries, domain.InvoiceStatusDraft, time.Now())
+	cancelling.RectifiedInvoiceID = &original.ID
+	cancelling.RectificationMode = &mode
+	cancelling.Lines[0].Quantity = -1
+	require.NoError(t, cancelling.CalculateAmounts())
+	require.NoError(t, repo.Create(ctx, cancelling))
+
+	invoiced, err := repo.GetByAppointmentID(ctx, appointmentID)
+	require.NoError(t, err)
+	assert.Equal(t, original.ID, invoiced.ID)
+
+	// Once issued, the session can be invoiced again
+	cancelling.Status = domain.InvoiceStatusUnpaid
+	require.NoError(t, repo.Issue(ctx, cancelling, nil))
+
+	_, err = repo.GetByAppointmentID(ctx, appointmentID)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok, "unexpected error: %v", err)
+	assert.Equal(t, errors.CodeNotFound, appErr.Code)
+	assert.True(t, isUninvoiced())
+
+	replacement := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, time.Now())
+	replacement.Lines[0].AppointmentID = &appointmentID
+	require.NoError(t, repo.Issue(ctx, replacement, nil))
+
+	invoiced, err = repo.GetByAppointmentID(ctx, appointmentID)
+	require.NoError(t, err)
+	assert.Equal(t, replacement.ID, invoiced.ID)
+	assert.False(t, isUninvoiced())
+}
+
+func TestInvoiceRepository_PackSessionsAreNotInvoiced(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewInvoiceRepository(db)
+	packRepo := NewSessionPackRepository(db)
+	appointmentRepo := NewAppointmentRepository(db)
+	ctx := context.Background()
+
+	series := createTestSeries(t, db, false)
+	clientID := createTestClient(t, db)
+
+	pack := &domain.SessionPack{
+		ID: uuid.New(), Name: "Bono 5 sesiones", Sessions: 5, Price: money.MustParse("250"),
+		VATRate: 21, ValidityDays: 365, IsActive: true, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}
+	require.NoError(t, packRepo.Create(ctx, pack))
+	require.NoError(t, packRepo.CreateClientPack(ctx, &domain.ClientSessionPack{
+		ID: uuid.New(), ClientID: clientID, SessionPackID: pack.ID, Name: pack.Name,
+		SessionsTotal: 5, SessionsRemaining: 5,
+		PurchasedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), ExpiresAt: time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+		CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}))
+
+	consume := func(appointmentID uuid.UUID) error {
+		appointment, err := appointmentRepo.GetByID(ctx, appointmentID)
+		require.NoError(t, err)
+		_, err = packRepo.ConsumeSession(ctx, appointment, &domain.SessionPackUsage{ID: uuid.New(), CreatedAt: time.Now()})
+		return err
+	}
+
+	// A session already invoiced does not consume the pack
+	invoicedID := createTestAppointment(t, db, clientID)
+	invoice := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, time.Now())
+	invoice.Lines[0].AppointmentID = &invoicedID
+	require.NoError(t, repo.Issue(ctx, invoice, nil))
+	assert.Equal(t, domain.ErrSessionAlreadyInvoiced, consume(invoicedID))
+
+	// A session paid with the pack cannot be invoiced, as a draft or issued
+	prepaidID := createTestAppointment(t, db, clientID)
+	require.NoError(t, consume(prepaidID))
+
+	draft := newTestInvoice(clientID, series, domain.InvoiceStatusDraft, time.Now())
+	draft.Lines[0].AppointmentID = &prepaidID
+	assert.Equal(t, errAppointmentPaidWithPack, repo.Create(ctx, draft))
+
+	issued := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, time.Now())
+	issued.Lines[0].AppointmentID = &prepaidID
+	assert.Equal(t, errAppointmentPaidWithPack, repo.Issue(ctx, issued, nil))
+}
+
+func TestAppointmentRepository_ListUninvoicedCarriesTheCopay(t *testing.T) {
+	db := openTestDB(t)
+	appointmentRepo := NewAppointmentRepository(db)
+	ctx := context.Background()
+
+	clientID := createTestClient(t, db)
+	withCopay := createTestAppointment(t, db, clientID)
+	withoutCopay := createTestAppointment(t, db, clientID)
+	uncovered := createTestAppointment(t, db, clientID)
+
+	var profileID, insurerID, policyID, authorisationID uuid.UUID
+	require.NoError(t, db.Get(&profileID, `INSERT INTO billing_profiles (name, tax_id) VALUES ('Mutua de prueba', 'A12345678') RETURNING id`))
+	require.NoError(t, db.Get(&insurerID, `INSERT INTO insurers (name, billing_profile_id, session_rate) VALUES ($1, $2, 45) RETURNING id`,
+		"Mutua "+uuid.NewString(), profileID))
+	require.NoError(t, db.Get(&policyID, `
+		INSERT INTO insurance_policies (client_id, insurer_id, policy_number, copay, valid_from)
+		VALUES ($1, $2, 'POL-1', 15, '2026-01-01') RETURNING id`, clientID, insurerID))
+	require.NoError(t, db.Get(&authorisationID, `
+		INSERT INTO insurance_authorisations (policy_id, authorisation_number, sessions_authorised, sessions_used, valid_from, valid_until)
+		VALUES ($1, 'AUT-1', 10, 2, '2026-01-01', '2026-12-31') RETURNING id`, policyID))
+	for appointmentID, copay := range map[uuid.UUID]string{withCopay: "15", withoutCopay: "0"} {
+		_, err := db.Exec(`
+			INSERT INTO insurance_covered_sessions (authorisation_id, appointment_id, client_id, insurer_id, session_date, insurer_amount, copay_amount)
+			VALUES ($1, $2, $3, $4, '2026-03-10', 45, $5)`, authorisationID, appointmentID, clientID, insurerID, copay)
+		require.NoError(t, err)
+	}
+
+	appointments, err := appointmentRepo.ListUninvoiced(ctx, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	listed := make(map[uuid.UUID]*domain.Appointment)
+	for _, appointment := range appointments {
+		listed[appointment.ID] = appointment
+	}
+	require.Contains(t, listed, withCopay)
+	require.NotNil(t, listed[withCopay].CopayAmount)
+	assert.Equal(t, "15.00", listed[withCopay].CopayAmount.Decimal())
+	assert.NotContains(t, listed, withoutCopay, "the insurer pays the whole session")
+	require.Contains(t, listed, uncovered)
+	assert.Nil(t, listed[uncovered].CopayAmount)
+}
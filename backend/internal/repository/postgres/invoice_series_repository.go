@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type invoiceSeriesRepository struct {
+	db *sqlx.DB
+}
+
+// NewInvoiceSeriesRepository creates a new invoice series repository
+func NewInvoiceSeriesRepository(db *sqlx.DB) repository.InvoiceSeriesRepository {
+	return &invoiceSeriesRepository{db: db}
+}
+
+// Create creates a new series; a default series replaces the previous default of its type and location
+func (r *invoiceSeriesRepository) Create(ctx context.Context, series *domain.InvoiceSeries) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if series.IsDefault {
+		if err := clearDefaultSeries(ctx, tx, series); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO invoice_series (
+			id, name, prefix, invoice_type, location, is_default, yearly_reset, padding, is_active,
+			created_at, updated_at
+		) VALUES (
+			:id, :name, :prefix, :invoice_type, :location, :is_default, :yearly_reset, :padding, :is_active,
+			:created_at, :updated_at
+		)`
+
+	if _, err := tx.NamedExecContext(ctx, query, series); err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewConflictError("series prefix already exists", errors.CodeConflict)
+		}
+		return fmt.Errorf("failed to create invoice series: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit invoice series: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a series by ID
+func (r *invoiceSeriesRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.InvoiceSeries, error) {
+	var series domain.InvoiceSeries
+	query := `SELECT * FROM invoice_series WHERE id = $1`
+
+	err := r.db.GetContext(ctx, &series, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("invoice series not found")
+		}
+		return nil, fmt.Errorf("failed to get invoice series: %w", err)
+	}
+
+	return &series, nil
+}
+
+// List retrieves the series ordered by type and prefix, optionally only active ones
+func (r *invoiceSeriesRepository) List(ctx context.Context, activeOnly bool) ([]*domain.InvoiceSeries, error) {
+	query := `SELECT * FROM invoice_series`
+	if activeOnly {
+		query += ` WHERE is_active = true`
+	}
+	query += ` ORDER BY invoice_type ASC, prefix ASC`
+
+	series := []*domain.InvoiceSeries{}
+	if err := r.db.SelectContext(ctx, &series, query); err != nil {
+		return nil, fmt.Errorf("failed to list invoice series: %w", err)
+	}
+
+	return series, nil
+}
+
+// GetDefault retrieves the default active series of an invoice type, preferring
+// the default of the location over the one shared by all locations
+func (r *invoiceSeriesRepository) GetDefault(ctx context.Context, invoiceType domain.InvoiceType, location *string) (*domain.InvoiceSeries, error) {
+	var series domain.InvoiceSeries
+	query := `
+		SELECT * FROM invoice_series
+		WHERE invoice_type = $1 AND is_default AND is_active
+			AND (location IS NULL OR LOWER(location) = LOWER($2))
+		ORDER BY location IS NULL ASC
+		LIMIT 1`
+
+	err := r.db.GetContext(ctx, &series, query, invoiceType, location)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError(fmt.Sprintf("no default series for %s invoices", invoiceType))
+		}
+		return nil, fmt.Errorf("failed to get default invoice series: %w", err)
+	}
+
+	return &series, nil
+}
+
+// Update updates the name, location, default and active flags of a series.
+// The prefix, type, yearly reset and padding shape the numbers already issued and never change.
+func (r *invoiceSeriesRepository) Update(ctx context.Context, series *domain.InvoiceSeries) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if series.IsDefault {
+		if err := clearDefaultSeries(ctx, tx, series); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE invoice_series SET
+			name = :name,
+			location = :location,
+			is_default = :is_default,
+			is_active = :is_active,
+			updated_at = :updated_at
+		WHERE id = :id`
+
+	result, err := tx.NamedExecContext(ctx, query, series)
+	if err != nil {
+		return fmt.Errorf("failed to update invoice series: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("invoice series not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit invoice series: %w", err)
+	}
+
+	return nil
+}
+
+// clearDefaultSeries removes the default flag from the other series of the same type and location
+func clearDefaultSeries(ctx context.Context, tx *sqlx.Tx, series *domain.InvoiceSeries) error {
+	query := `
+		UPDATE invoice_series SET is_default = false
+		WHERE is_default AND id <> $1 AND invoice_type = $2
+			AND COALESCE(location, '') = COALESCE($3, '')`
+
+	if _, err := tx.ExecContext(ctx, query, series.ID, series.InvoiceType, series.Location); err != nil {
+		return fmt.Errorf("failed to clear default invoice series: %w", err)
+	}
+
+	return nil
+}
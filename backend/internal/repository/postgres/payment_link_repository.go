@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type paymentLinkRepository struct {
+	db *sqlx.DB
+}
+
+// NewPaymentLinkRepository creates a new payment link repository
+func NewPaymentLinkRepository(db *sqlx.DB) repository.PaymentLinkRepository {
+	return &paymentLinkRepository{db: db}
+}
+
+// Create stores a new payment link
+func (r *paymentLinkRepository) Create(ctx context.Context, link *domain.PaymentLink) error {
+	query := `
+		INSERT INTO payment_links (
+			id, invoice_id, provider, session_id, url, amount, status, expires_at, payment_id,
+			paid_at, created_by, created_at, updated_at
+		) VALUES (
+			:id, :invoice_id, :provider, :session_id, :url, :amount, :status, :expires_at, :payment_id,
+			:paid_at, :created_by, :created_at, :updated_at
+		)`
+	if _, err := r.db.NamedExecContext(ctx, query, link); err != nil {
+		return fmt.Errorf("failed to create payment link: %w", err)
+	}
+	return nil
+}
+
+// GetBySession retrieves the link of a checkout session of a provider
+func (r *paymentLinkRepository) GetBySession(ctx context.Context, provider, sessionID string) (*domain.PaymentLink, error) {
+	var link domain.PaymentLink
+	query := `SELECT * FROM payment_links WHERE provider = $1 AND session_id = $2`
+
+	if err := r.db.GetContext(ctx, &link, query, provider, sessionID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("payment link not found")
+		}
+		return nil, fmt.Errorf("failed to get payment link: %w", err)
+	}
+
+	return &link, nil
+}
+
+// ListByInvoice retrieves the links of an invoice, newest first
+func (r *paymentLinkRepository) ListByInvoice(ctx context.Context, invoiceID uuid.UUID) ([]*domain.PaymentLink, error) {
+	links := []*domain.PaymentLink{}
+	query := `SELECT * FROM payment_links WHERE invoice_id = $1 ORDER BY created_at DESC`
+
+	if err := r.db.SelectContext(ctx, &links, query, invoiceID); err != nil {
+		return nil, fmt.Errorf("failed to list payment links: %w", err)
+	}
+
+	return links, nil
+}
+
+// Complete claims the webhook event, records the payment against the locked invoice and
+// marks the link as paid, all in one transaction
+func (r *paymentLinkRepository) Complete(ctx context.Context, link *domain.PaymentLink, payment *domain.Payment, eventID string) (*domain.Invoice, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := claimWebhookEvent(ctx, tx, link, eventID); err != nil {
+		return nil, err
+	}
+
+	var invoice *domain.Invoice
+	if payment != nil {
+		if invoice, err = lockInvoice(ctx, tx, payment.InvoiceID); err != nil {
+			return nil, err
+		}
+		if err := invoice.CheckPayment(payment.Amount); err != nil {
+			return nil, err
+		}
+		if err := insertPayment(ctx, tx, payment); err != nil {
+			return nil, err
+		}
+		if err := refreshInvoiceBalance(ctx, tx, invoice); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := updateOpenPaymentLink(ctx, tx, link); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit payment link: %w", err)
+	}
+
+	return invoice, nil
+}
+
+// Expire claims the webhook event and marks the link as expired
+func (r *paymentLinkRepository) Expire(ctx context.Context, link *domain.PaymentLink, eventID string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := claimWebhookEvent(ctx, tx, link, eventID); err != nil {
+		return err
+	}
+	if err := updateOpenPaymentLink(ctx, tx, link); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit payment link: %w", err)
+	}
+	return nil
+}
+
+// claimWebhookEvent records a provider event; fails with domain.ErrWebhookEventProcessed
+// when it was recorded before
+func claimWebhookEvent(ctx context.Context, tx *sqlx.Tx, link *domain.PaymentLink, eventID string) error {
+	query := `
+		INSERT INTO payment_webhook_events (provider, event_id, payment_link_id, received_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (provider, event_id) DO NOTHING`
+	result, err := tx.ExecContext(ctx, query, link.Provider, eventID, link.ID, link.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook event: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrWebhookEventProcessed
+	}
+	return nil
+}
+
+// updateOpenPaymentLink stores the new state of a link still open; fails with
+// domain.ErrPaymentLinkNotOpen when it was paid or expired meanwhile
+func updateOpenPaymentLink(ctx context.Context, tx *sqlx.Tx, link *domain.PaymentLink) error {
+	query := `
+		UPDATE payment_links SET
+			status = :status,
+			payment_id = :payment_id,
+			paid_at = :paid_at,
+			updated_at = :updated_at
+		WHERE id = :id AND status = 'open'`
+	result, err := tx.NamedExecContext(ctx, query, link)
+	if err != nil {
+		return fmt.Errorf("failed to update payment link: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrPaymentLinkNotOpen
+	}
+	return nil
+}
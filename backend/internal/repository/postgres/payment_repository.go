@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type paymentRepository struct {
+	db *sqlx.DB
+}
+
+// NewPaymentRepository creates a new payment repository
+func NewPaymentRepository(db *sqlx.DB) repository.PaymentRepository {
+	return &paymentRepository{db: db}
+}
+
+// Create records a payment and updates the paid amount and status of its invoice,
+// which is returned. The invoice stays locked while the payment is checked against
+// its outstanding balance, so concurrent payments never exceed it.
+func (r *paymentRepository) Create(ctx context.Context, payment *domain.Payment) (*domain.Invoice, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invoice, err := lockInvoice(ctx, tx, payment.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := invoice.CheckPayment(payment.Amount); err != nil {
+		return nil, err
+	}
+
+	if err := insertPayment(ctx, tx, payment); err != nil {
+		return nil, err
+	}
+
+	if err := refreshInvoiceBalance(ctx, tx, invoice); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit payment: %w", err)
+	}
+
+	return invoice, nil
+}
+
+// GetByID retrieves a payment by ID
+func (r *paymentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Payment, error) {
+	var payment domain.Payment
+	query := `SELECT * FROM payments WHERE id = $1`
+
+	err := r.db.GetContext(ctx, &payment, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("payment not found")
+		}
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	return &payment, nil
+}
+
+// ListByInvoice retrieves the payments of an invoice, including reversed ones, oldest first
+func (r *paymentRepository) ListByInvoice(ctx context.Context, invoiceID uuid.UUID) ([]*domain.Payment, error) {
+	payments := []*domain.Payment{}
+	query := `SELECT * FROM payments WHERE invoice_id = $1 ORDER BY payment_date ASC, created_at ASC`
+
+	if err := r.db.SelectContext(ctx, &payments, query, invoiceID); err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+// Reverse stores the reversal of a payment and updates the paid amount and status of
+// its invoice, which is returned
+func (r *paymentRepository) Reverse(ctx context.Context, payment *domain.Payment) (*domain.Invoice, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invoice, err := lockInvoice(ctx, tx, payment.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := reversePayment(ctx, tx, payment); err != nil {
+		return nil, err
+	}
+
+	if err := refreshInvoiceBalance(ctx, tx, invoice); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit payment reversal: %w", err)
+	}
+
+	return invoice, nil
+}
+
+// insertPayment stores a payment; the caller checks it against the locked invoice
+func insertPayment(ctx context.Context, tx *sqlx.Tx, payment *domain.Payment) error {
+	query := `
+		INSERT INTO payments (
+			id, invoice_id, amount, payment_date, method, reference, notes, recorded_by,
+			reversed_at, reversed_by, reversal_reason, created_at, updated_at
+		) VALUES (
+			:id, :invoice_id, :amount, :payment_date, :method, :reference, :notes, :recorded_by,
+			:reversed_at, :reversed_by, :reversal_reason, :created_at, :updated_at
+		)`
+	if _, err := tx.NamedExecContext(ctx, query, payment); err != nil {
+		return fmt.Errorf("failed to create payment: %w", err)
+	}
+	return nil
+}
+
+// reversePayment stores the reversal of a payment. Only the first reversal goes through;
+// later ones fail with domain.ErrPaymentAlreadyReversed.
+func reversePayment(ctx context.Context, tx *sqlx.Tx, payment *domain.Payment) error {
+	query := `
+		UPDATE payments SET
+			reversed_at = :reversed_at,
+			reversed_by = :reversed_by,
+			reversal_reason = :reversal_reason,
+			updated_at = :updated_at
+		WHERE id = :id AND reversed_at IS NULL`
+	result, err := tx.NamedExecContext(ctx, query, payment)
+	if err != nil {
+		return fmt.Errorf("failed to reverse payment: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrPaymentAlreadyReversed
+	}
+	return nil
+}
+
+// lockInvoice reads an invoice, without its lines, and locks it until the end of the transaction
+func lockInvoice(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (*domain.Invoice, error) {
+	var invoice domain.Invoice
+	query := `SELECT * FROM invoices WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+
+	if err := tx.GetContext(ctx, &invoice, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("invoice not found")
+		}
+		return nil, fmt.Errorf("failed to lock invoice: %w", err)
+	}
+
+	return &invoice, nil
+}
+
+// refreshInvoiceBalance recomputes the paid amount, status and payment method of an
+// invoice from its payments not reversed; settled invoices are no longer overdue
+func refreshInvoiceBalance(ctx context.Context, tx *sqlx.Tx, invoice *domain.Invoice) error {
+	var paid money.Money
+	sumQuery := `SELECT COALESCE(SUM(amount), 0) FROM payments WHERE invoice_id = $1 AND reversed_at IS NULL`
+	if err := tx.GetContext(ctx, &paid, sumQuery, invoice.ID); err != nil {
+		return fmt.Errorf("failed to get paid amount: %w", err)
+	}
+
+	var method *string
+	methodQuery := `
+		SELECT method FROM payments
+		WHERE invoice_id = $1 AND reversed_at IS NULL
+		ORDER BY payment_date DESC, created_at DESC
+		LIMIT 1`
+	if err := tx.GetContext(ctx, &method, methodQuery, invoice.ID); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get payment method: %w", err)
+	}
+
+	if err := invoice.ApplyPaidAmount(paid); err != nil {
+		return err
+	}
+	invoice.PaymentMethod = method
+
+	query := `
+		UPDATE invoices SET paid_amount = $1, status = $2, payment_method = $3, overdue_at = $4, updated_at = $5
+		WHERE id = $6`
+	if _, err := tx.ExecContext(ctx, query, invoice.PaidAmount, invoice.Status, invoice.PaymentMethod, invoice.OverdueAt, time.Now(), invoice.ID); err != nil {
+		if isImmutableInvoiceError(err) {
+			return errIssuedInvoiceImmutable
+		}
+		return fmt.Errorf("failed to update invoice balance: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPayment(invoiceID uuid.UUID, amount string) *domain.Payment {
+	return &domain.Payment{
+		ID:          uuid.New(),
+		InvoiceID:   invoiceID,
+		Amount:      money.MustParse(amount),
+		PaymentDate: time.Now().Truncate(24 * time.Hour),
+		Method:      domain.PaymentMethodCard,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+}
+
+func TestPaymentRepository_ConcurrentPaymentsNeverExceedTheBalance(t *testing.T) {
+	db := openTestDB(t)
+	invoiceRepo := NewInvoiceRepository(db)
+	repo := NewPaymentRepository(db)
+	ctx := context.Background()
+
+	// 60 + 21% VAT = 72.60
+	invoice := newTestInvoice(createTestClient(t, db), createTestSeries(t, db, false), domain.InvoiceStatusUnpaid, time.Now())
+	require.NoError(t, invoiceRepo.Issue(ctx, invoice, nil))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Create(ctx, newTestPayment(invoice.ID, "40"))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+
+	stored, err := invoiceRepo.GetByID(ctx, invoice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.InvoiceStatusPartiallyPaid, stored.Status)
+	assert.Equal(t, money.MustParse("40"), stored.PaidAmount)
+}
+
+func TestPaymentRepository_StatusFollowsTheBalance(t *testing.T) {
+	db := openTestDB(t)
+	invoiceRepo := NewInvoiceRepository(db)
+	repo := NewPaymentRepository(db)
+	ctx := context.Background()
+
+	invoice := newTestInvoice(createTestClient(t, db), createTestSeries(t, db, false), domain.InvoiceStatusUnpaid, time.Now())
+	require.NoError(t, invoiceRepo.Issue(ctx, invoice, nil))
+
+	first := newTestPayment(invoice.ID, "40")
+	updated, err := repo.Create(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, domain.InvoiceStatusPartiallyPaid, updated.Status)
+
+	second := newTestPayment(invoice.ID, "32.60")
+	second.Method = domain.PaymentMethodTransfer
+	updated, err = repo.Create(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, domain.InvoiceStatusPaid, updated.Status)
+	require.NotNil(t, updated.PaymentMethod)
+	assert.Equal(t, "transfer", *updated.PaymentMethod)
+
+	// Reversing the first payment reopens the balance
+	now := time.Now()
+	first.ReversedAt = &now
+	first.ReversalReason = "Cargo devuelto"
+	updated, err = repo.Reverse(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, domain.InvoiceStatusPartiallyPaid, updated.Status)
+	assert.Equal(t, money.MustParse("32.60"), updated.PaidAmount)
+
+	_, err = repo.Reverse(ctx, first)
+	assert.Equal(t, domain.ErrPaymentAlreadyReversed, err)
+
+	payments, err := repo.ListByInvoice(ctx, invoice.ID)
+	require.NoError(t, err)
+	require.Len(t, payments, 2)
+	assert.True(t, payments[0].IsReversed() || payments[1].IsReversed())
+
+	stored, err := invoiceRepo.GetByID(ctx, invoice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("32.60"), stored.PaidAmount)
+	assert.Equal(t, domain.InvoiceStatusPartiallyPaid, stored.Status)
+}
+
+func TestInvoiceRepository_OutstandingInvoicesAsOfADay(t *testing.T) {
+	db := openTestDB(t)
+	invoiceRepo := NewInvoiceRepository(db)
+	repo := NewPaymentRepository(db)
+	ctx := context.Background()
+
+	clientID := createTestClient(t, db)
+	series := createTestSeries(t, db, false)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	asOf := today.AddDate(0, 0, -10)
+
+	// 72.60 each: one paid after the day, one partially paid before it and one issued after it
+	paidLater := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, today.AddDate(0, 0, -30))
+	paidBefore := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, today.AddDate(0, 0, -30))
+	issuedLater := newTestInvoice(clientID, series, domain.InvoiceStatusUnpaid, today)
+	for _, invoice := range []*domain.Invoice{paidLater, paidBefore, issuedLater} {
+		require.NoError(t, invoiceRepo.Issue(ctx, invoice, nil))
+	}
+
+	_, err := repo.Create(ctx, newTestPayment(paidLater.ID, "72.60"))
+	require.NoError(t, err)
+	early := newTestPayment(paidBefore.ID, "20")
+	early.PaymentDate = asOf.AddDate(0, 0, -1)
+	_, err = repo.Create(ctx, early)
+	require.NoError(t, err)
+
+	invoices, err := invoiceRepo.GetOutstandingInvoices(ctx, repository.OutstandingInvoiceFilters{ClientID: &clientID, AsOf: &asOf})
+	require.NoError(t, err)
+
+	paid := map[uuid.UUID]string{}
+	for _, invoice := range invoices {
+		paid[invoice.ID] = invoice.PaidAmount.Decimal()
+	}
+	assert.Equal(t, map[uuid.UUID]string{paidLater.ID: "0.00", paidBefore.ID: "20.00"}, paid)
+
+	// Today only the partially paid invoice and the one issued later are owed
+	invoices, err = invoiceRepo.GetOutstandingInvoices(ctx, repository.OutstandingInvoiceFilters{ClientID: &clientID})
+	require.NoError(t, err)
+	assert.Len(t, invoices, 2)
+}
@@ -0,0 +1,251 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type recurringExpenseRepository struct {
+	db *sqlx.DB
+}
+
+// NewRecurringExpenseRepository creates a new recurring expense repository
+func NewRecurringExpenseRepository(db *sqlx.DB) repository.RecurringExpenseRepository {
+	return &recurringExpenseRepository{db: db}
+}
+
+// Create creates a new recurring expense
+func (r *recurringExpenseRepository) Create(ctx context.Context, recurring *domain.RecurringExpense) error {
+	query := `
+		INSERT INTO recurring_expenses (
+			id, supplier, amount, vat_amount, vat_deductible, irpf_deductible, category_id, subcategory_id,
+			day_of_month, start_date, end_date, description, payment_method, notes, is_active,
+			created_at, updated_at
+		) VALUES (
+			:id, :supplier, :amount, :vat_amount, :vat_deductible, :irpf_deductible, :category_id, :subcategory_id,
+			:day_of_month, :start_date, :end_date, :description, :payment_method, :notes, :is_active,
+			:created_at, :updated_at
+		)`
+
+	if _, err := r.db.NamedExecContext(ctx, query, recurring); err != nil {
+		return fmt.Errorf("failed to create recurring expense: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a recurring expense by ID (excluding soft-deleted)
+func (r *recurringExpenseRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.RecurringExpense, error) {
+	var recurring domain.RecurringExpense
+	query := `SELECT * FROM recurring_expenses WHERE id = $1 AND deleted_at IS NULL`
+
+	if err := r.db.GetContext(ctx, &recurring, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("recurring expense not found")
+		}
+		return nil, fmt.Errorf("failed to get recurring expense: %w", err)
+	}
+
+	return &recurring, nil
+}
+
+// List retrieves recurring expenses ordered by supplier, optionally only active ones
+func (r *recurringExpenseRepository) List(ctx context.Context, activeOnly bool) ([]*domain.RecurringExpense, error) {
+	query := `SELECT * FROM recurring_expenses WHERE deleted_at IS NULL`
+	if activeOnly {
+		query += ` AND is_active = true`
+	}
+	query += ` ORDER BY supplier ASC, day_of_month ASC`
+
+	recurring := []*domain.RecurringExpense{}
+	if err := r.db.SelectContext(ctx, &recurring, query); err != nil {
+		return nil, fmt.Errorf("failed to list recurring expenses: %w", err)
+	}
+
+	return recurring, nil
+}
+
+// Update updates an existing recurring expense
+func (r *recurringExpenseRepository) Update(ctx context.Context, recurring *domain.RecurringExpense) error {
+	query := `
+		UPDATE recurring_expenses SET
+			supplier = :supplier,
+			amount = :amount,
+			vat_amount = :vat_amount,
+			vat_deductible = :vat_deductible,
+			irpf_deductible = :irpf_deductible,
+			category_id = :category_id,
+			subcategory_id = :subcategory_id,
+			day_of_month = :day_of_month,
+			start_date = :start_date,
+			end_date = :end_date,
+			description = :description,
+			payment_method = :payment_method,
+			notes = :notes,
+			is_active = :is_active,
+			updated_at = :updated_at
+		WHERE id = :id AND deleted_at IS NULL`
+
+	result, err := r.db.NamedExecContext(ctx, query, recurring)
+	if err != nil {
+		return fmt.Errorf("failed to update recurring expense: %w", err)
+	}
+
+	return requireRow(result, "recurring expense not found")
+}
+
+// Delete soft deletes a recurring expense
+func (r *recurringExpenseRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE recurring_expenses SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete recurring expense: %w", err)
+	}
+
+	return requireRow(result, "recurring expense not found")
+}
+
+// ListOccurrences retrieves the occurrences of a recurring expense between two periods
+func (r *recurringExpenseRepository) ListOccurrences(ctx context.Context, recurringID uuid.UUID, from, to time.Time) ([]*domain.RecurringExpenseOccurrence, error) {
+	query := `
+		SELECT * FROM recurring_expense_occurrences
+		WHERE recurring_expense_id = $1 AND period >= $2::date AND period <= $3::date
+		ORDER BY period ASC`
+
+	occurrences := []*domain.RecurringExpenseOccurrence{}
+	if err := r.db.SelectContext(ctx, &occurrences, query, recurringID, from, to); err != nil {
+		return nil, fmt.Errorf("failed to list recurring expense occurrences: %w", err)
+	}
+
+	return occurrences, nil
+}
+
+// SaveOccurrence creates or replaces the adjustment or skip of a month
+func (r *recurringExpenseRepository) SaveOccurrence(ctx context.Context, occurrence *domain.RecurringExpenseOccurrence) error {
+	// Generated months are left untouched; the row returned is the one stored
+	query := `
+		INSERT INTO recurring_expense_occurrences (
+			id, recurring_expense_id, period, status, expense_date, amount, vat_amount, notes,
+			created_at, updated_at
+		) VALUES (
+			:id, :recurring_expense_id, :period, :status, :expense_date, :amount, :vat_amount, :notes,
+			:created_at, :updated_at
+		)
+		ON CONFLICT (recurring_expense_id, period) DO UPDATE SET
+			status = EXCLUDED.status,
+			expense_date = EXCLUDED.expense_date,
+			amount = EXCLUDED.amount,
+			vat_amount = EXCLUDED.vat_amount,
+			notes = EXCLUDED.notes,
+			updated_at = EXCLUDED.updated_at
+		WHERE recurring_expense_occurrences.status <> 'generated'
+		RETURNING id, created_at`
+
+	rows, err := r.db.NamedQueryContext(ctx, query, occurrence)
+	if err != nil {
+		return fmt.Errorf("failed to save recurring expense occurrence: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to save recurring expense occurrence: %w", err)
+		}
+		return domain.ErrOccurrenceGenerated
+	}
+
+	return rows.Scan(&occurrence.ID, &occurrence.CreatedAt)
+}
+
+// DeleteOccurrence removes the adjustment or skip of a month
+func (r *recurringExpenseRepository) DeleteOccurrence(ctx context.Context, recurringID uuid.UUID, period time.Time) error {
+	query := `
+		DELETE FROM recurring_expense_occurrences
+		WHERE recurring_expense_id = $1 AND period = $2::date AND status <> 'generated'`
+
+	result, err := r.db.ExecContext(ctx, query, recurringID, period)
+	if err != nil {
+		return fmt.Errorf("failed to delete recurring expense occurrence: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	// Tell a generated month apart from one that was never adjusted
+	var generated bool
+	query = `
+		SELECT EXISTS (
+			SELECT 1 FROM recurring_expense_occurrences
+			WHERE recurring_expense_id = $1 AND period = $2::date
+		)`
+	if err := r.db.GetContext(ctx, &generated, query, recurringID, period); err != nil {
+		return fmt.Errorf("failed to get recurring expense occurrence: %w", err)
+	}
+	if generated {
+		return domain.ErrOccurrenceGenerated
+	}
+
+	return errors.NewNotFoundError("occurrence not adjusted or skipped")
+}
+
+// Generate creates the expense of a month and records it in its occurrence
+func (r *recurringExpenseRepository) Generate(ctx context.Context, occurrence *domain.RecurringExpenseOccurrence, expense *domain.Expense) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.NamedExecContext(ctx, insertExpenseQuery, expense); err != nil {
+		return fmt.Errorf("failed to create expense: %w", err)
+	}
+
+	// Adjusted months keep their row; skipped and generated ones are not generated again
+	occurrence.Status = domain.OccurrenceStatusGenerated
+	occurrence.ExpenseID = &expense.ID
+	query := `
+		INSERT INTO recurring_expense_occurrences (
+			id, recurring_expense_id, period, status, expense_date, amount, vat_amount, notes,
+			expense_id, created_at, updated_at
+		) VALUES (
+			:id, :recurring_expense_id, :period, :status, :expense_date, :amount, :vat_amount, :notes,
+			:expense_id, :created_at, :updated_at
+		)
+		ON CONFLICT (recurring_expense_id, period) DO UPDATE SET
+			status = EXCLUDED.status,
+			expense_id = EXCLUDED.expense_id,
+			updated_at = EXCLUDED.updated_at
+		WHERE recurring_expense_occurrences.status = 'adjusted'`
+
+	result, err := tx.NamedExecContext(ctx, query, occurrence)
+	if err != nil {
+		return fmt.Errorf("failed to record recurring expense occurrence: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrOccurrenceGenerated
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit generated expense: %w", err)
+	}
+
+	return nil
+}
@@ -213,7 +213,8 @@ func (r *SearchRepository) SearchAppointments(ctx context.Context, query string,
 	return appointments, nil
 }
 
-// SearchInvoices searches for invoices by invoice number or client name
+// SearchInvoices searches for invoices by invoice number, client or payer name; settlement
+// invoices of an insurer show their payer as client
 func (r *SearchRepository) SearchInvoices(ctx context.Context, query string, limit int) ([]domain.SearchInvoice, error) {
 	queryPattern := "%" + query + "%"
 
@@ -221,18 +222,19 @@ func (r *SearchRepository) SearchInvoices(ctx context.Context, query string, lim
 		SELECT 
 			i.id,
 			i.invoice_number,
-			CONCAT(c.first_name, ' ', c.last_name) as client_name,
+			COALESCE(c.first_name || ' ' || c.last_name, i.payer_name) as client_name,
 			i.total_amount,
 			i.status,
 			i.issue_date
 		FROM invoices i
-		INNER JOIN clients c ON i.client_id = c.id
+		LEFT JOIN clients c ON i.client_id = c.id
 		WHERE 
 			i.deleted_at IS NULL
 			AND (
 				LOWER(i.invoice_number) LIKE LOWER($1)
 				OR LOWER(c.first_name) LIKE LOWER($1)
 				OR LOWER(c.last_name) LIKE LOWER($1)
+				OR LOWER(i.payer_name) LIKE LOWER($1)
 			)
 		ORDER BY 
 			CASE 
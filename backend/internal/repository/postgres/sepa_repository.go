@@ -0,0 +1,283 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// sepaBatchColumns are the columns of a batch without its file
+const sepaBatchColumns = `id, message_id, collection_date, transaction_count, total_amount, created_by, created_at`
+
+type sepaRepository struct {
+	db *sqlx.DB
+}
+
+// NewSEPARepository creates a new SEPA direct debit repository
+func NewSEPARepository(db *sqlx.DB) repository.SEPARepository {
+	return &sepaRepository{db: db}
+}
+
+// CreateMandate stores a mandate; fails with domain.ErrActiveMandateExists when the client
+// already has an active one and domain.ErrMandateReferenceExists for a repeated reference
+func (r *sepaRepository) CreateMandate(ctx context.Context, mandate *domain.SEPAMandate) error {
+	query := `
+		INSERT INTO sepa_mandates (
+			id, client_id, reference, debtor_name, iban, bic, signed_at, sequence,
+			last_collected_at, revoked_at, created_at, updated_at
+		) VALUES (
+			:id, :client_id, :reference, :debtor_name, :iban, :bic, :signed_at, :sequence,
+			:last_collected_at, :revoked_at, :created_at, :updated_at
+		)`
+
+	if _, err := r.db.NamedExecContext(ctx, query, mandate); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			if pqErr.Constraint == "idx_sepa_mandates_active_client" {
+				return domain.ErrActiveMandateExists
+			}
+			return domain.ErrMandateReferenceExists
+		}
+		return fmt.Errorf("failed to create SEPA mandate: %w", err)
+	}
+
+	return nil
+}
+
+// GetMandateByID retrieves a mandate by ID
+func (r *sepaRepository) GetMandateByID(ctx context.Context, id uuid.UUID) (*domain.SEPAMandate, error) {
+	return r.getMandate(ctx, `SELECT * FROM sepa_mandates WHERE id = $1`, id)
+}
+
+// GetActiveMandate retrieves the mandate not revoked of a client
+func (r *sepaRepository) GetActiveMandate(ctx context.Context, clientID uuid.UUID) (*domain.SEPAMandate, error) {
+	return r.getMandate(ctx, `SELECT * FROM sepa_mandates WHERE client_id = $1 AND revoked_at IS NULL`, clientID)
+}
+
+func (r *sepaRepository) getMandate(ctx context.Context, query string, arg interface{}) (*domain.SEPAMandate, error) {
+	var mandate domain.SEPAMandate
+	if err := r.db.GetContext(ctx, &mandate, query, arg); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("SEPA mandate not found")
+		}
+		return nil, fmt.Errorf("failed to get SEPA mandate: %w", err)
+	}
+
+	return &mandate, nil
+}
+
+// ListMandatesByClient retrieves the mandates of a client, including revoked ones, newest first
+func (r *sepaRepository) ListMandatesByClient(ctx context.Context, clientID uuid.UUID) ([]*domain.SEPAMandate, error) {
+	mandates := []*domain.SEPAMandate{}
+	query := `SELECT * FROM sepa_mandates WHERE client_id = $1 ORDER BY signed_at DESC, created_at DESC`
+
+	if err := r.db.SelectContext(ctx, &mandates, query, clientID); err != nil {
+		return nil, fmt.Errorf("failed to list SEPA mandates: %w", err)
+	}
+
+	return mandates, nil
+}
+
+// RevokeMandate stores the revocation of a mandate; fails with domain.ErrMandateRevoked
+// when it was already revoked
+func (r *sepaRepository) RevokeMandate(ctx context.Context, mandate *domain.SEPAMandate) error {
+	query := `
+		UPDATE sepa_mandates SET revoked_at = :revoked_at, updated_at = :updated_at
+		WHERE id = :id AND revoked_at IS NULL`
+
+	result, err := r.db.NamedExecContext(ctx, query, mandate)
+	if err != nil {
+		return fmt.Errorf("failed to revoke SEPA mandate: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrMandateRevoked
+	}
+
+	return nil
+}
+
+// CreateBatch stores a batch with its debits in a single transaction. The payment of each
+// debit is recorded against its locked invoice, and the mandates collected for the first
+// time move on to RCUR. Fails with domain.ErrMandateRevoked if a mandate was revoked meanwhile.
+func (r *sepaRepository) CreateBatch(ctx context.Context, batch *domain.SEPABatch) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	batchQuery := `
+		INSERT INTO sepa_batches (
+			id, message_id, collection_date, transaction_count, total_amount, content, created_by, created_at
+		) VALUES (
+			:id, :message_id, :collection_date, :transaction_count, :total_amount, :content, :created_by, :created_at
+		)`
+	if _, err := tx.NamedExecContext(ctx, batchQuery, batch); err != nil {
+		return fmt.Errorf("failed to create SEPA batch: %w", err)
+	}
+
+	debitQuery := `
+		INSERT INTO sepa_debits (
+			id, batch_id, invoice_id, mandate_id, payment_id, end_to_end_id, amount, sequence,
+			status, return_reason, returned_at, created_at, updated_at
+		) VALUES (
+			:id, :batch_id, :invoice_id, :mandate_id, :payment_id, :end_to_end_id, :amount, :sequence,
+			:status, :return_reason, :returned_at, :created_at, :updated_at
+		)`
+	mandateQuery := `
+		UPDATE sepa_mandates SET sequence = $1, last_collected_at = $2, updated_at = $3
+		WHERE id = $4 AND revoked_at IS NULL`
+
+	for _, debit := range batch.Debits {
+		// The balance is checked again while the invoice is locked
+		invoice, err := lockInvoice(ctx, tx, debit.InvoiceID)
+		if err != nil {
+			return err
+		}
+		if err := invoice.CheckPayment(debit.Amount); err != nil {
+			return err
+		}
+		if err := insertPayment(ctx, tx, debit.Payment); err != nil {
+			return err
+		}
+		if err := refreshInvoiceBalance(ctx, tx, invoice); err != nil {
+			return err
+		}
+		debit.Invoice = invoice
+
+		if _, err := tx.NamedExecContext(ctx, debitQuery, debit); err != nil {
+			return fmt.Errorf("failed to create SEPA debit: %w", err)
+		}
+
+		result, err := tx.ExecContext(ctx, mandateQuery, domain.SEPASequenceRecurrent, batch.CollectionDate, batch.CreatedAt, debit.MandateID)
+		if err != nil {
+			return fmt.Errorf("failed to update SEPA mandate: %w", err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rows == 0 {
+			return domain.ErrMandateRevoked
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit SEPA batch: %w", err)
+	}
+
+	return nil
+}
+
+// GetBatch retrieves a batch with its debits
+func (r *sepaRepository) GetBatch(ctx context.Context, id uuid.UUID) (*domain.SEPABatch, error) {
+	var batch domain.SEPABatch
+	if err := r.db.GetContext(ctx, &batch, `SELECT * FROM sepa_batches WHERE id = $1`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("SEPA batch not found")
+		}
+		return nil, fmt.Errorf("failed to get SEPA batch: %w", err)
+	}
+
+	batch.Debits = []*domain.SEPADebit{}
+	query := `SELECT * FROM sepa_debits WHERE batch_id = $1 ORDER BY created_at ASC, end_to_end_id ASC`
+	if err := r.db.SelectContext(ctx, &batch.Debits, query, id); err != nil {
+		return nil, fmt.Errorf("failed to get SEPA debits: %w", err)
+	}
+
+	return &batch, nil
+}
+
+// ListBatches retrieves the batches without their file, newest first
+func (r *sepaRepository) ListBatches(ctx context.Context) ([]*domain.SEPABatch, error) {
+	batches := []*domain.SEPABatch{}
+	query := `SELECT ` + sepaBatchColumns + ` FROM sepa_batches ORDER BY created_at DESC`
+
+	if err := r.db.SelectContext(ctx, &batches, query); err != nil {
+		return nil, fmt.Errorf("failed to list SEPA batches: %w", err)
+	}
+
+	return batches, nil
+}
+
+// GetDebitByEndToEndID retrieves the debit quoted by the bank
+func (r *sepaRepository) GetDebitByEndToEndID(ctx context.Context, endToEndID string) (*domain.SEPADebit, error) {
+	var debit domain.SEPADebit
+	query := `SELECT * FROM sepa_debits WHERE end_to_end_id = $1`
+
+	if err := r.db.GetContext(ctx, &debit, query, endToEndID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("SEPA debit not found")
+		}
+		return nil, fmt.Errorf("failed to get SEPA debit: %w", err)
+	}
+
+	return &debit, nil
+}
+
+// ReturnDebit marks a debit as returned and reverses its payment, reopening the invoice,
+// which is returned. A returned first debit sets its mandate back to FRST. Fails with
+// domain.ErrDebitAlreadyReturned when it was already returned.
+func (r *sepaRepository) ReturnDebit(ctx context.Context, debit *domain.SEPADebit, payment *domain.Payment) (*domain.Invoice, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invoice, err := lockInvoice(ctx, tx, debit.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE sepa_debits SET
+			status = :status,
+			return_reason = :return_reason,
+			returned_at = :returned_at,
+			updated_at = :updated_at
+		WHERE id = :id AND status = 'presented'`
+	result, err := tx.NamedExecContext(ctx, query, debit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to return SEPA debit: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return nil, domain.ErrDebitAlreadyReturned
+	}
+
+	// The payment may have been reversed by hand before the return was imported
+	if err := reversePayment(ctx, tx, payment); err != nil && err != domain.ErrPaymentAlreadyReversed {
+		return nil, err
+	}
+	if err := refreshInvoiceBalance(ctx, tx, invoice); err != nil {
+		return nil, err
+	}
+
+	if debit.Sequence == domain.SEPASequenceFirst {
+		mandateQuery := `UPDATE sepa_mandates SET sequence = $1, updated_at = $2 WHERE id = $3 AND revoked_at IS NULL`
+		if _, err := tx.ExecContext(ctx, mandateQuery, domain.SEPASequenceFirst, debit.UpdatedAt, debit.MandateID); err != nil {
+			return nil, fmt.Errorf("failed to update SEPA mandate: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit SEPA debit return: %w", err)
+	}
+
+	return invoice, nil
+}
@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type serviceTypeRepository struct {
+	db *sqlx.DB
+}
+
+// NewServiceTypeRepository creates a new service type repository
+func NewServiceTypeRepository(db *sqlx.DB) repository.ServiceTypeRepository {
+	return &serviceTypeRepository{db: db}
+}
+
+// Create creates a new service type
+func (r *serviceTypeRepository) Create(ctx context.Context, serviceType *domain.ServiceType) error {
+	query := `
+		INSERT INTO service_types (
+			id, name, description, default_price, vat_rate, vat_exemption_cause, is_active,
+			created_at, updated_at
+		) VALUES (
+			:id, :name, :description, :default_price, :vat_rate, :vat_exemption_cause, :is_active,
+			:created_at, :updated_at
+		)`
+
+	_, err := r.db.NamedExecContext(ctx, query, serviceType)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewConflictError("service type name already exists", errors.CodeConflict)
+		}
+		return fmt.Errorf("failed to create service type: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a service type by ID (excluding soft-deleted)
+func (r *serviceTypeRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.ServiceType, error) {
+	var serviceType domain.ServiceType
+	query := `SELECT * FROM service_types WHERE id = $1 AND deleted_at IS NULL`
+
+	err := r.db.GetContext(ctx, &serviceType, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("service type not found")
+		}
+		return nil, fmt.Errorf("failed to get service type: %w", err)
+	}
+
+	return &serviceType, nil
+}
+
+// List retrieves service types ordered by name, optionally only active ones
+func (r *serviceTypeRepository) List(ctx context.Context, activeOnly bool) ([]*domain.ServiceType, error) {
+	query := `SELECT * FROM service_types WHERE deleted_at IS NULL`
+	if activeOnly {
+		query += ` AND is_active = true`
+	}
+	query += ` ORDER BY name ASC`
+
+	var serviceTypes []*domain.ServiceType
+	if err := r.db.SelectContext(ctx, &serviceTypes, query); err != nil {
+		return nil, fmt.Errorf("failed to list service types: %w", err)
+	}
+
+	return serviceTypes, nil
+}
+
+// Update updates an existing service type
+func (r *serviceTypeRepository) Update(ctx context.Context, serviceType *domain.ServiceType) error {
+	query := `
+		UPDATE service_types SET
+			name = :name,
+			description = :description,
+			default_price = :default_price,
+			vat_rate = :vat_rate,
+			vat_exemption_cause = :vat_exemption_cause,
+			is_active = :is_active,
+			updated_at = :updated_at
+		WHERE id = :id AND deleted_at IS NULL`
+
+	result, err := r.db.NamedExecContext(ctx, query, serviceType)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewConflictError("service type name already exists", errors.CodeConflict)
+		}
+		return fmt.Errorf("failed to update service type: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("service type not found")
+	}
+
+	return nil
+}
+
+// Delete soft deletes a service type
+func (r *serviceTypeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE service_types SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete service type: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("service type not found")
+	}
+
+	return nil
+}
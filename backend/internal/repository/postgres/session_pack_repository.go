@@ -0,0 +1,264 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type sessionPackRepository struct {
+	db *sqlx.DB
+}
+
+// NewSessionPackRepository creates a new session pack repository
+func NewSessionPackRepository(db *sqlx.DB) repository.SessionPackRepository {
+	return &sessionPackRepository{db: db}
+}
+
+// Create creates a new session pack of the catalogue
+func (r *sessionPackRepository) Create(ctx context.Context, pack *domain.SessionPack) error {
+	query := `
+		INSERT INTO session_packs (
+			id, name, description, service_type_id, sessions, price, vat_rate, vat_exemption_cause,
+			validity_days, is_active, created_at, updated_at
+		) VALUES (
+			:id, :name, :description, :service_type_id, :sessions, :price, :vat_rate, :vat_exemption_cause,
+			:validity_days, :is_active, :created_at, :updated_at
+		)`
+
+	_, err := r.db.NamedExecContext(ctx, query, pack)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewConflictError("session pack name already exists", errors.CodeConflict)
+		}
+		return fmt.Errorf("failed to create session pack: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a session pack by ID (excluding soft-deleted)
+func (r *sessionPackRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.SessionPack, error) {
+	var pack domain.SessionPack
+	query := `SELECT * FROM session_packs WHERE id = $1 AND deleted_at IS NULL`
+
+	err := r.db.GetContext(ctx, &pack, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("session pack not found")
+		}
+		return nil, fmt.Errorf("failed to get session pack: %w", err)
+	}
+
+	return &pack, nil
+}
+
+// List retrieves session packs ordered by name, optionally only active ones
+func (r *sessionPackRepository) List(ctx context.Context, activeOnly bool) ([]*domain.SessionPack, error) {
+	query := `SELECT * FROM session_packs WHERE deleted_at IS NULL`
+	if activeOnly {
+		query += ` AND is_active = true`
+	}
+	query += ` ORDER BY name ASC`
+
+	packs := []*domain.SessionPack{}
+	if err := r.db.SelectContext(ctx, &packs, query); err != nil {
+		return nil, fmt.Errorf("failed to list session packs: %w", err)
+	}
+
+	return packs, nil
+}
+
+// Update updates an existing session pack
+func (r *sessionPackRepository) Update(ctx context.Context, pack *domain.SessionPack) error {
+	query := `
+		UPDATE session_packs SET
+			name = :name,
+			description = :description,
+			service_type_id = :service_type_id,
+			sessions = :sessions,
+			price = :price,
+			vat_rate = :vat_rate,
+			vat_exemption_cause = :vat_exemption_cause,
+			validity_days = :validity_days,
+			is_active = :is_active,
+			updated_at = :updated_at
+		WHERE id = :id AND deleted_at IS NULL`
+
+	result, err := r.db.NamedExecContext(ctx, query, pack)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewConflictError("session pack name already exists", errors.CodeConflict)
+		}
+		return fmt.Errorf("failed to update session pack: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("session pack not found")
+	}
+
+	return nil
+}
+
+// Delete soft deletes a session pack; packs already sold are kept
+func (r *sessionPackRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE session_packs SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete session pack: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.NewNotFoundError("session pack not found")
+	}
+
+	return nil
+}
+
+// CreateClientPack records a session pack sold to a client
+func (r *sessionPackRepository) CreateClientPack(ctx context.Context, clientPack *domain.ClientSessionPack) error {
+	query := `
+		INSERT INTO client_session_packs (
+			id, client_id, session_pack_id, invoice_id, name, service_type_id,
+			sessions_total, sessions_remaining, purchased_at, expires_at, created_at, updated_at
+		) VALUES (
+			:id, :client_id, :session_pack_id, :invoice_id, :name, :service_type_id,
+			:sessions_total, :sessions_remaining, :purchased_at, :expires_at, :created_at, :updated_at
+		)`
+
+	if _, err := r.db.NamedExecContext(ctx, query, clientPack); err != nil {
+		return fmt.Errorf("failed to create client session pack: %w", err)
+	}
+
+	return nil
+}
+
+// ListClientPacks retrieves the packs bought by a client, newest first
+func (r *sessionPackRepository) ListClientPacks(ctx context.Context, clientID uuid.UUID) ([]*domain.ClientSessionPack, error) {
+	packs := []*domain.ClientSessionPack{}
+	query := `SELECT * FROM client_session_packs WHERE client_id = $1 ORDER BY purchased_at DESC, created_at DESC`
+
+	if err := r.db.SelectContext(ctx, &packs, query, clientID); err != nil {
+		return nil, fmt.Errorf("failed to list client session packs: %w", err)
+	}
+
+	return packs, nil
+}
+
+// ConsumeSession takes one session for an appointment from the client pack that expires
+// first among those covering its service type and still valid on its date, unless an
+// invoice already bills the appointment
+func (r *sessionPackRepository) ConsumeSession(ctx context.Context, appointment *domain.Appointment, usage *domain.SessionPackUsage) (*domain.ClientSessionPack, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Invoices lock the appointments they bill too, so an appointment is never both
+	// invoiced and paid with a pack
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM appointments WHERE id = $1 FOR UPDATE`, appointment.ID); err != nil {
+		return nil, fmt.Errorf("failed to lock appointment: %w", err)
+	}
+
+	var invoiced bool
+	invoicedQuery := fmt.Sprintf(`
+		SELECT EXISTS (
+			SELECT 1 FROM invoices i
+			WHERE %s
+			  AND (i.appointment_id = $1 OR EXISTS (
+				SELECT 1 FROM invoice_lines l WHERE l.invoice_id = i.id AND l.appointment_id = $1
+			  ))
+		)`, billsAppointments)
+	if err := tx.GetContext(ctx, &invoiced, invoicedQuery, appointment.ID); err != nil {
+		return nil, fmt.Errorf("failed to check appointment invoices: %w", err)
+	}
+	if invoiced {
+		return nil, domain.ErrSessionAlreadyInvoiced
+	}
+
+	// Packs without a service type cover any session
+	var pack domain.ClientSessionPack
+	query := `
+		SELECT * FROM client_session_packs
+		WHERE client_id = $1
+		  AND sessions_remaining > 0
+		  AND expires_at >= $2::date
+		  AND (service_type_id IS NULL OR service_type_id = $3)
+		ORDER BY expires_at ASC, purchased_at ASC
+		LIMIT 1
+		FOR UPDATE`
+	err = tx.GetContext(ctx, &pack, query, appointment.ClientID, appointment.StartTime, appointment.ServiceTypeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrNoUsableSessionPack
+		}
+		return nil, fmt.Errorf("failed to get client session pack: %w", err)
+	}
+
+	usage.ClientSessionPackID = pack.ID
+	usage.AppointmentID = appointment.ID
+	query = `
+		INSERT INTO session_pack_usages (id, client_session_pack_id, appointment_id, created_at)
+		VALUES (:id, :client_session_pack_id, :appointment_id, :created_at)
+		ON CONFLICT (appointment_id) DO NOTHING`
+	result, err := tx.NamedExecContext(ctx, query, usage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session pack usage: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return nil, domain.ErrSessionAlreadyConsumed
+	}
+
+	pack.SessionsRemaining--
+	pack.UpdatedAt = usage.CreatedAt
+	query = `UPDATE client_session_packs SET sessions_remaining = $1, updated_at = $2 WHERE id = $3`
+	if _, err := tx.ExecContext(ctx, query, pack.SessionsRemaining, pack.UpdatedAt, pack.ID); err != nil {
+		return nil, fmt.Errorf("failed to update client session pack: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit session pack usage: %w", err)
+	}
+
+	return &pack, nil
+}
+
+// GetUsageByAppointment retrieves the session consumed by an appointment
+func (r *sessionPackRepository) GetUsageByAppointment(ctx context.Context, appointmentID uuid.UUID) (*domain.SessionPackUsage, error) {
+	var usage domain.SessionPackUsage
+	query := `SELECT * FROM session_pack_usages WHERE appointment_id = $1`
+
+	err := r.db.GetContext(ctx, &usage, query, appointmentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("session pack usage not found")
+		}
+		return nil, fmt.Errorf("failed to get session pack usage: %w", err)
+	}
+
+	return &usage, nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// RecurringExpenseRepository defines the interface for recurring expenses and the months
+// adjusted, skipped or generated from them
+type RecurringExpenseRepository interface {
+	// Create creates a new recurring expense
+	Create(ctx context.Context, recurring *domain.RecurringExpense) error
+
+	// GetByID retrieves a recurring expense by ID (excluding soft-deleted)
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.RecurringExpense, error)
+
+	// List retrieves recurring expenses ordered by supplier, optionally only active ones
+	List(ctx context.Context, activeOnly bool) ([]*domain.RecurringExpense, error)
+
+	// Update updates an existing recurring expense; the expenses already generated are kept
+	Update(ctx context.Context, recurring *domain.RecurringExpense) error
+
+	// Delete soft deletes a recurring expense; the expenses already generated are kept
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// ListOccurrences retrieves the occurrences of a recurring expense for the months
+	// between two periods (inclusive), oldest first
+	ListOccurrences(ctx context.Context, recurringID uuid.UUID, from, to time.Time) ([]*domain.RecurringExpenseOccurrence, error)
+
+	// SaveOccurrence creates or replaces the adjustment or skip of a month. Returns
+	// domain.ErrOccurrenceGenerated when its expense was already generated.
+	SaveOccurrence(ctx context.Context, occurrence *domain.RecurringExpenseOccurrence) error
+
+	// DeleteOccurrence removes the adjustment or skip of a month so it follows the template
+	// again. Returns domain.ErrOccurrenceGenerated when its expense was already generated.
+	DeleteOccurrence(ctx context.Context, recurringID uuid.UUID, period time.Time) error
+
+	// Generate creates the expense of a month and records it in its occurrence in one
+	// transaction. Returns domain.ErrOccurrenceGenerated when the month was generated or
+	// skipped concurrently.
+	Generate(ctx context.Context, occurrence *domain.RecurringExpenseOccurrence, expense *domain.Expense) error
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// SEPARepository defines the interface for direct debit mandates and batches
+type SEPARepository interface {
+	// CreateMandate stores a mandate; fails with domain.ErrActiveMandateExists when the client
+	// already has an active one and domain.ErrMandateReferenceExists for a repeated reference
+	CreateMandate(ctx context.Context, mandate *domain.SEPAMandate) error
+
+	// GetMandateByID retrieves a mandate by ID
+	GetMandateByID(ctx context.Context, id uuid.UUID) (*domain.SEPAMandate, error)
+
+	// GetActiveMandate retrieves the mandate not revoked of a client
+	GetActiveMandate(ctx context.Context, clientID uuid.UUID) (*domain.SEPAMandate, error)
+
+	// ListMandatesByClient retrieves the mandates of a client, including revoked ones, newest first
+	ListMandatesByClient(ctx context.Context, clientID uuid.UUID) ([]*domain.SEPAMandate, error)
+
+	// RevokeMandate stores the revocation of a mandate; fails with domain.ErrMandateRevoked
+	// when it was already revoked
+	RevokeMandate(ctx context.Context, mandate *domain.SEPAMandate) error
+
+	// CreateBatch stores a batch with its debits in a single transaction. The payment of each
+	// debit is recorded against its locked invoice, and the mandates collected for the first
+	// time move on to RCUR. Fails with domain.ErrMandateRevoked if a mandate was revoked meanwhile.
+	CreateBatch(ctx context.Context, batch *domain.SEPABatch) error
+
+	// GetBatch retrieves a batch with its debits
+	GetBatch(ctx context.Context, id uuid.UUID) (*domain.SEPABatch, error)
+
+	// ListBatches retrieves the batches without their file, newest first
+	ListBatches(ctx context.Context) ([]*domain.SEPABatch, error)
+
+	// GetDebitByEndToEndID retrieves the debit quoted by the bank
+	GetDebitByEndToEndID(ctx context.Context, endToEndID string) (*domain.SEPADebit, error)
+
+	// ReturnDebit marks a debit as returned and reverses its payment, reopening the invoice,
+	// which is returned. A returned first debit sets its mandate back to FRST. Fails with
+	// domain.ErrDebitAlreadyReturned when it was already returned.
+	ReturnDebit(ctx context.Context, debit *domain.SEPADebit, payment *domain.Payment) (*domain.Invoice, error)
+}
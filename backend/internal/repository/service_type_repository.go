@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// ServiceTypeRepository defines the interface for service type data access
+type ServiceTypeRepository interface {
+	// Create creates a new service type
+	Create(ctx context.Context, serviceType *domain.ServiceType) error
+
+	// GetByID retrieves a service type by ID (excluding soft-deleted)
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.ServiceType, error)
+
+	// List retrieves service types ordered by name, optionally only active ones
+	List(ctx context.Context, activeOnly bool) ([]*domain.ServiceType, error)
+
+	// Update updates an existing service type
+	Update(ctx context.Context, serviceType *domain.ServiceType) error
+
+	// Delete soft deletes a service type
+	Delete(ctx context.Context, id uuid.UUID) error
+}
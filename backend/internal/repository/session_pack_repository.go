@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/google/uuid"
+)
+
+// SessionPackRepository defines the interface for session pack data access
+type SessionPackRepository interface {
+	// Create creates a new session pack of the catalogue
+	Create(ctx context.Context, pack *domain.SessionPack) error
+
+	// GetByID retrieves a session pack by ID (excluding soft-deleted)
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.SessionPack, error)
+
+	// List retrieves session packs ordered by name, optionally only active ones
+	List(ctx context.Context, activeOnly bool) ([]*domain.SessionPack, error)
+
+	// Update updates an existing session pack
+	Update(ctx context.Context, pack *domain.SessionPack) error
+
+	// Delete soft deletes a session pack; packs already sold are kept
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// CreateClientPack records a session pack sold to a client
+	CreateClientPack(ctx context.Context, clientPack *domain.ClientSessionPack) error
+
+	// ListClientPacks retrieves the packs bought by a client, newest first
+	ListClientPacks(ctx context.Context, clientID uuid.UUID) ([]*domain.ClientSessionPack, error)
+
+	// ConsumeSession takes one session for an appointment from the client pack that expires
+	// first among those covering its service type and still valid on its date. The pack is
+	// locked while its balance changes. Returns domain.ErrNoUsableSessionPack when there is
+	// none, domain.ErrSessionAlreadyConsumed when the appointment already took a session and
+	// domain.ErrSessionAlreadyInvoiced when an invoice already bills it.
+	ConsumeSession(ctx context.Context, appointment *domain.Appointment, usage *domain.SessionPackUsage) (*domain.ClientSessionPack, error)
+
+	// GetUsageByAppointment retrieves the session consumed by an appointment
+	GetUsageByAppointment(ctx context.Context, appointmentID uuid.UUID) (*domain.SessionPackUsage, error)
+}
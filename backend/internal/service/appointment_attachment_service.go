@@ -0,0 +1,276 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// DefaultMaxAttachmentBytes is used when no upload limit is configured
+const DefaultMaxAttachmentBytes int64 = 10 << 20
+
+// allowedAttachmentTypes maps the sniffed MIME types accepted for attachments
+var allowedAttachmentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"text/plain":      true,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": true,
+}
+
+// AttachmentViewer identifies who is accessing appointment attachments
+type AttachmentViewer struct {
+	UserID   uuid.UUID
+	Role     string
+	ClientID *uuid.UUID // Set when the viewer is a client
+}
+
+// IsStaff returns true for admins and employees
+func (v AttachmentViewer) IsStaff() bool {
+	return v.Role == string(domain.RoleAdmin) || v.Role == string(domain.RoleEmployee)
+}
+
+// UploadAttachmentRequest represents a file uploaded to an appointment
+type UploadAttachmentRequest struct {
+	FileName   string
+	Content    io.Reader
+	Visibility domain.AttachmentVisibility
+}
+
+// AppointmentAttachmentService manages files attached to appointments
+type AppointmentAttachmentService interface {
+	// Upload stores a file and attaches it to the appointment
+	Upload(ctx context.Context, appointmentID uuid.UUID, req *UploadAttachmentRequest, viewer AttachmentViewer) (*domain.AppointmentAttachment, error)
+
+	// List retrieves the attachments of an appointment visible to the viewer
+	List(ctx context.Context, appointmentID uuid.UUID, viewer AttachmentViewer) ([]*domain.AppointmentAttachment, error)
+
+	// Download opens an attachment visible to the viewer; the caller must close the reader
+	Download(ctx context.Context, appointmentID, attachmentID uuid.UUID, viewer AttachmentViewer) (io.ReadCloser, *domain.AppointmentAttachment, error)
+
+	// Delete removes an attachment (staff or the user who uploaded it)
+	Delete(ctx context.Context, appointmentID, attachmentID uuid.UUID, viewer AttachmentViewer) error
+}
+
+type appointmentAttachmentService struct {
+	attachmentRepo  repository.AppointmentAttachmentRepository
+	appointmentRepo repository.AppointmentRepository
+	storage         storage.Storage
+	maxBytes        int64
+}
+
+// NewAppointmentAttachmentService creates a new appointment attachment service
+func NewAppointmentAttachmentService(
+	attachmentRepo repository.AppointmentAttachmentRepository,
+	appointmentRepo repository.AppointmentRepository,
+	store storage.Storage,
+	maxBytes int64,
+) AppointmentAttachmentService {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxAttachmentBytes
+	}
+
+	return &appointmentAttachmentService{
+		attachmentRepo:  attachmentRepo,
+		appointmentRepo: appointmentRepo,
+		storage:         store,
+		maxBytes:        maxBytes,
+	}
+}
+
+// Upload stores a file and attaches it to the appointment
+func (s *appointmentAttachmentService) Upload(ctx context.Context, appointmentID uuid.UUID, req *UploadAttachmentRequest, viewer AttachmentViewer) (*domain.AppointmentAttachment, error) {
+	if _, err := s.authorize(ctx, appointmentID, viewer); err != nil {
+		return nil, err
+	}
+
+	fileName := filepath.Base(strings.TrimSpace(req.FileName))
+	if fileName == "" || fileName == "." || fileName == "/" {
+		return nil, errors.NewValidationError("file name is required", nil)
+	}
+
+	// Clients can only share documents with the clinic, never hide them
+	visibility := req.Visibility
+	if !viewer.IsStaff() {
+		visibility = domain.AttachmentVisibilityClient
+	} else if visibility == "" {
+		visibility = domain.AttachmentVisibilityStaffOnly
+	}
+	if !visibility.IsValid() {
+		return nil, errors.NewValidationError("invalid visibility", map[string][]string{
+			"visibility": {"must be staff_only or client"},
+		})
+	}
+
+	// Read one byte past the limit to detect oversized files without trusting headers
+	content, err := io.ReadAll(io.LimitReader(req.Content, s.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if len(content) == 0 {
+		return nil, errors.NewValidationError("file is empty", nil)
+	}
+	if int64(len(content)) > s.maxBytes {
+		return nil, errors.NewValidationError(fmt.Sprintf("file exceeds the maximum size of %d MB", s.maxBytes>>20), nil)
+	}
+
+	contentType, ok := sniffAttachmentType(content, fileName)
+	if !ok {
+		return nil, errors.NewValidationError("file type not allowed", map[string][]string{
+			"file": {"allowed types: PDF, JPEG, PNG, plain text, DOCX"},
+		})
+	}
+
+	now := time.Now()
+	attachment := &domain.AppointmentAttachment{
+		ID:            uuid.New(),
+		AppointmentID: appointmentID,
+		FileName:      fileName,
+		ContentType:   contentType,
+		SizeBytes:     int64(len(content)),
+		Visibility:    visibility,
+		UploadedBy:    viewer.UserID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	attachment.StorageKey = fmt.Sprintf("appointments/%s/%s", appointmentID, attachment.ID)
+
+	if err := s.storage.Put(ctx, attachment.StorageKey, bytes.NewReader(content), attachment.SizeBytes, contentType); err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+
+	if err := s.attachmentRepo.Create(ctx, attachment); err != nil {
+		// Do not leave orphaned objects behind
+		if delErr := s.storage.Delete(ctx, attachment.StorageKey); delErr != nil {
+			log.Printf("[WARN] Failed to remove orphaned attachment %s: %v", attachment.StorageKey, delErr)
+		}
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// List retrieves the attachments of an appointment visible to the viewer
+func (s *appointmentAttachmentService) List(ctx context.Context, appointmentID uuid.UUID, viewer AttachmentViewer) ([]*domain.AppointmentAttachment, error) {
+	if _, err := s.authorize(ctx, appointmentID, viewer); err != nil {
+		return nil, err
+	}
+
+	attachments, err := s.attachmentRepo.ListByAppointment(ctx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]*domain.AppointmentAttachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		if viewer.IsStaff() || attachment.IsSharedWithClient() {
+			visible = append(visible, attachment)
+		}
+	}
+
+	return visible, nil
+}
+
+// Download opens an attachment visible to the viewer; the caller must close the reader
+func (s *appointmentAttachmentService) Download(ctx context.Context, appointmentID, attachmentID uuid.UUID, viewer AttachmentViewer) (io.ReadCloser, *domain.AppointmentAttachment, error) {
+	attachment, err := s.getVisible(ctx, appointmentID, attachmentID, viewer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, _, err := s.storage.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, nil, errors.NewNotFoundError("attachment file not found")
+		}
+		return nil, nil, fmt.Errorf("failed to open attachment: %w", err)
+	}
+
+	return reader, attachment, nil
+}
+
+// Delete removes an attachment (staff or the user who uploaded it)
+func (s *appointmentAttachmentService) Delete(ctx context.Context, appointmentID, attachmentID uuid.UUID, viewer AttachmentViewer) error {
+	attachment, err := s.getVisible(ctx, appointmentID, attachmentID, viewer)
+	if err != nil {
+		return err
+	}
+
+	if !viewer.IsStaff() && attachment.UploadedBy != viewer.UserID {
+		return errors.NewForbiddenError("only the uploader or staff can delete this attachment")
+	}
+
+	if err := s.attachmentRepo.Delete(ctx, attachmentID); err != nil {
+		return err
+	}
+
+	if err := s.storage.Delete(ctx, attachment.StorageKey); err != nil {
+		log.Printf("[WARN] Failed to remove attachment object %s: %v", attachment.StorageKey, err)
+	}
+
+	return nil
+}
+
+// authorize checks that the viewer can access the appointment's attachments
+func (s *appointmentAttachmentService) authorize(ctx context.Context, appointmentID uuid.UUID, viewer AttachmentViewer) (*domain.Appointment, error) {
+	appointment, err := s.appointmentRepo.GetByID(ctx, appointmentID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("appointment not found")
+	}
+
+	if viewer.IsStaff() {
+		return appointment, nil
+	}
+
+	if viewer.ClientID == nil || appointment.ClientID != *viewer.ClientID {
+		return nil, errors.NewForbiddenError("you do not have access to this appointment")
+	}
+
+	return appointment, nil
+}
+
+// getVisible retrieves an attachment of the appointment if the viewer can see it
+func (s *appointmentAttachmentService) getVisible(ctx context.Context, appointmentID, attachmentID uuid.UUID, viewer AttachmentViewer) (*domain.AppointmentAttachment, error) {
+	if _, err := s.authorize(ctx, appointmentID, viewer); err != nil {
+		return nil, err
+	}
+
+	attachment, err := s.attachmentRepo.GetByID(ctx, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hidden attachments are reported as missing so their existence is not leaked
+	if attachment.AppointmentID != appointmentID || (!viewer.IsStaff() && !attachment.IsSharedWithClient()) {
+		return nil, errors.NewNotFoundError("attachment not found")
+	}
+
+	return attachment, nil
+}
+
+// sniffAttachmentType detects the MIME type from the content and checks it is allowed
+func sniffAttachmentType(content []byte, fileName string) (string, bool) {
+	contentType := http.DetectContentType(content)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+
+	// DOCX files are zip archives; accept them only with the matching extension
+	if contentType == "application/zip" && strings.EqualFold(filepath.Ext(fileName), ".docx") {
+		contentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	}
+
+	return contentType, allowedAttachmentTypes[contentType]
+}
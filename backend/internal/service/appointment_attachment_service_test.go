@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAppointmentAttachmentRepository is a mock implementation of AppointmentAttachmentRepository
+type MockAppointmentAttachmentRepository struct {
+	mock.Mock
+}
+
+func (m *MockAppointmentAttachmentRepository) Create(ctx context.Context, attachment *domain.AppointmentAttachment) error {
+	args := m.Called(ctx, attachment)
+	return args.Error(0)
+}
+
+func (m *MockAppointmentAttachmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.AppointmentAttachment, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.AppointmentAttachment), args.Error(1)
+}
+
+func (m *MockAppointmentAttachmentRepository) ListByAppointment(ctx context.Context, appointmentID uuid.UUID) ([]*domain.AppointmentAttachment, error) {
+	args := m.Called(ctx, appointmentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.AppointmentAttachment), args.Error(1)
+}
+
+func (m *MockAppointmentAttachmentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+var pdfContent = []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\n%%EOF")
+
+func newAttachmentTestService(t *testing.T, maxBytes int64) (AppointmentAttachmentService, *MockAppointmentAttachmentRepository, *MockAppointmentRepository, storage.Storage) {
+	store, err := storage.NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+
+	attachmentRepo := new(MockAppointmentAttachmentRepository)
+	appointmentRepo := new(MockAppointmentRepository)
+
+	return NewAppointmentAttachmentService(attachmentRepo, appointmentRepo, store, maxBytes), attachmentRepo, appointmentRepo, store
+}
+
+func TestAppointmentAttachmentService_Upload_StaffStoresFile(t *testing.T) {
+	svc, attachmentRepo, appointmentRepo, store := newAttachmentTestService(t, 0)
+	ctx := context.Background()
+
+	appointment := &domain.Appointment{ID: uuid.New(), ClientID: uuid.New()}
+	staff := AttachmentViewer{UserID: uuid.New(), Role: string(domain.RoleEmployee)}
+
+	appointmentRepo.On("GetByID", ctx, appointment.ID).Return(appointment, nil)
+	attachmentRepo.On("Create", ctx, mock.AnythingOfType("*domain.AppointmentAttachment")).Return(nil)
+
+	attachment, err := svc.Upload(ctx, appointment.ID, &UploadAttachmentRequest{
+		FileName: "../../informe.pdf",
+		Content:  bytes.NewReader(pdfContent),
+	}, staff)
+
+	require.NoError(t, err)
+	assert.Equal(t, "informe.pdf", attachment.FileName)
+	assert.Equal(t, "application/pdf", attachment.ContentType)
+	assert.Equal(t, domain.AttachmentVisibilityStaffOnly, attachment.Visibility)
+	assert.Equal(t, int64(len(pdfContent)), attachment.SizeBytes)
+
+	reader, _, err := store.Get(ctx, attachment.StorageKey)
+	require.NoError(t, err)
+	defer reader.Close()
+	stored, _ := io.ReadAll(reader)
+	assert.Equal(t, pdfContent, stored)
+}
+
+func TestAppointmentAttachmentService_Upload_ClientUploadsAreShared(t *testing.T) {
+	svc, attachmentRepo, appointmentRepo, _ := newAttachmentTestService(t, 0)
+	ctx := context.Background()
+
+	clientID := uuid.New()
+	appointment := &domain.Appointment{ID: uuid.New(), ClientID: clientID}
+	client := AttachmentViewer{UserID: uuid.New(), Role: string(domain.RoleClient), ClientID: &clientID}
+
+	appointmentRepo.On("GetByID", ctx, appointment.ID).Return(appointment, nil)
+	attachmentRepo.On("Create", ctx, mock.AnythingOfType("*domain.AppointmentAttachment")).Return(nil)
+
+	attachment, err := svc.Upload(ctx, appointment.ID, &UploadAttachmentRequest{
+		FileName:   "derivacion.pdf",
+		Content:    bytes.NewReader(pdfContent),
+		Visibility: domain.AttachmentVisibilityStaffOnly,
+	}, client)
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.AttachmentVisibilityClient, attachment.Visibility)
+}
+
+func TestAppointmentAttachmentService_Upload_RejectsInvalidFiles(t *testing.T) {
+	ctx := context.Background()
+	staff := AttachmentViewer{UserID: uuid.New(), Role: string(domain.RoleAdmin)}
+
+	tests := []struct {
+		name     string
+		fileName string
+		content  []byte
+	}{
+		{name: "disallowed type", fileName: "script.html", content: []byte("<html><script>alert(1)</script></html>")},
+		{name: "renamed executable", fileName: "report.pdf", content: append([]byte("MZ\x90\x00"), bytes.Repeat([]byte{0}, 64)...)},
+		{name: "too large", fileName: "big.txt", content: []byte(strings.Repeat("a", 2048))},
+		{name: "empty", fileName: "empty.txt", content: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, attachmentRepo, appointmentRepo, _ := newAttachmentTestService(t, 1024)
+			appointment := &domain.Appointment{ID: uuid.New(), ClientID: uuid.New()}
+			appointmentRepo.On("GetByID", ctx, appointment.ID).Return(appointment, nil)
+
+			_, err := svc.Upload(ctx, appointment.ID, &UploadAttachmentRequest{
+				FileName: tt.fileName,
+				Content:  bytes.NewReader(tt.content),
+			}, staff)
+
+			require.Error(t, err)
+			appErr, ok := err.(*errors.AppError)
+			require.True(t, ok)
+			assert.Equal(t, errors.CodeValidationFailed, appErr.Code)
+			attachmentRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestAppointmentAttachmentService_ClientCannotAccessOtherAppointments(t *testing.T) {
+	svc, attachmentRepo, appointmentRepo, _ := newAttachmentTestService(t, 0)
+	ctx := context.Background()
+
+	clientID := uuid.New()
+	appointment := &domain.Appointment{ID: uuid.New(), ClientID: uuid.New()}
+	client := AttachmentViewer{UserID: uuid.New(), Role: string(domain.RoleClient), ClientID: &clientID}
+
+	appointmentRepo.On("GetByID", ctx, appointment.ID).Return(appointment, nil)
+
+	_, err := svc.List(ctx, appointment.ID, client)
+
+	require.Error(t, err)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, errors.CodeForbidden, appErr.Code)
+	attachmentRepo.AssertNotCalled(t, "ListByAppointment", mock.Anything, mock.Anything)
+}
+
+func TestAppointmentAttachmentService_ClientOnlySeesSharedFiles(t *testing.T) {
+	svc, attachmentRepo, appointmentRepo, _ := newAttachmentTestService(t, 0)
+	ctx := context.Background()
+
+	clientID := uuid.New()
+	appointment := &domain.Appointment{ID: uuid.New(), ClientID: clientID}
+	client := AttachmentViewer{UserID: uuid.New(), Role: string(domain.RoleClient), ClientID: &clientID}
+
+	shared := &domain.AppointmentAttachment{ID: uuid.New(), AppointmentID: appointment.ID, Visibility: domain.AttachmentVisibilityClient}
+	internal := &domain.AppointmentAttachment{ID: uuid.New(), AppointmentID: appointment.ID, Visibility: domain.AttachmentVisibilityStaffOnly}
+
+	appointmentRepo.On("GetByID", ctx, appointment.ID).Return(appointment, nil)
+	attachmentRepo.On("ListByAppointment", ctx, appointment.ID).Return([]*domain.AppointmentAttachment{shared, internal}, nil)
+	attachmentRepo.On("GetByID", ctx, internal.ID).Return(internal, nil)
+
+	attachments, err := svc.List(ctx, appointment.ID, client)
+	require.NoError(t, err)
+	require.Len(t, attachments, 1)
+	assert.Equal(t, shared.ID, attachments[0].ID)
+
+	// Staff-only files cannot be downloaded by guessing their ID
+	_, _, err = svc.Download(ctx, appointment.ID, internal.ID, client)
+	require.Error(t, err)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, errors.CodeNotFound, appErr.Code)
+}
+
+func TestAppointmentAttachmentService_Delete(t *testing.T) {
+	svc, attachmentRepo, appointmentRepo, store := newAttachmentTestService(t, 0)
+	ctx := context.Background()
+
+	clientID := uuid.New()
+	appointment := &domain.Appointment{ID: uuid.New(), ClientID: clientID}
+	client := AttachmentViewer{UserID: uuid.New(), Role: string(domain.RoleClient), ClientID: &clientID}
+
+	attachment := &domain.AppointmentAttachment{
+		ID:            uuid.New(),
+		AppointmentID: appointment.ID,
+		StorageKey:    "appointments/" + appointment.ID.String() + "/file",
+		Visibility:    domain.AttachmentVisibilityClient,
+		UploadedBy:    uuid.New(), // Uploaded by a therapist
+	}
+	require.NoError(t, store.Put(ctx, attachment.StorageKey, bytes.NewReader(pdfContent), int64(len(pdfContent)), "application/pdf"))
+
+	appointmentRepo.On("GetByID", ctx, appointment.ID).Return(appointment, nil)
+	attachmentRepo.On("GetByID", ctx, attachment.ID).Return(attachment, nil)
+	attachmentRepo.On("Delete", ctx, attachment.ID).Return(nil)
+
+	// A client cannot delete a shared file uploaded by staff
+	err := svc.Delete(ctx, appointment.ID, attachment.ID, client)
+	require.Error(t, err)
+	attachmentRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+
+	// The uploader can
+	attachment.UploadedBy = client.UserID
+	require.NoError(t, svc.Delete(ctx, appointment.ID, attachment.ID, client))
+
+	_, _, err = store.Get(ctx, attachment.StorageKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// ChargePolicy holds the clinic's late-cancellation and no-show fees
+type ChargePolicy struct {
+	LateCancelWindow time.Duration // Cancellations closer than this to the start time are charged
+	LateCancelFee    money.Money   // Base amount charged for a late cancellation (0 disables the rule)
+	NoShowFee        money.Money   // Base amount charged for a no-show (0 disables the rule)
+	PaymentTermDays  int           // Days between issue and due date of the generated invoice
+}
+
+// DefaultClientCancellationCutoff is how long before an appointment clients may still cancel it
+// when late cancellations are not charged
+const DefaultClientCancellationCutoff = 24 * time.Hour
+
+// ClientCancellationCutoff returns how long before the start clients may still cancel an
+// appointment. When late cancellations are charged clients may cancel until the start and
+// those inside the window are charged rather than refused.
+func (p ChargePolicy) ClientCancellationCutoff() time.Duration {
+	if p.LateCancelFee.IsPositive() {
+		return 0
+	}
+	return DefaultClientCancellationCutoff
+}
+
+// ChargeRule decides whether an appointment transition must be charged and for how much
+type ChargeRule interface {
+	// Kind returns the kind of charge raised by the rule
+	Kind() domain.AppointmentChargeKind
+
+	// Evaluate returns the fee for the transition and whether the rule applies
+	Evaluate(appointment *domain.Appointment, from domain.AppointmentStatus, at time.Time) (money.Money, bool)
+}
+
+// LateCancellationRule charges client cancellations made inside the policy window;
+// cancellations made by the clinic are never charged
+type LateCancellationRule struct {
+	Window time.Duration
+	Fee    money.Money
+}
+
+// Kind returns the kind of charge raised by the rule
+func (r LateCancellationRule) Kind() domain.AppointmentChargeKind {
+	return domain.ChargeKindLateCancellation
+}
+
+// Evaluate applies when the client cancels an open appointment less than Window before it starts
+func (r LateCancellationRule) Evaluate(appointment *domain.Appointment, from domain.AppointmentStatus, at time.Time) (money.Money, bool) {
+	if !r.Fee.IsPositive() || !appointment.IsCancelledByClient() {
+		return money.Money{}, false
+	}
+	if from == domain.AppointmentStatusCancelled || from == domain.AppointmentStatusCompleted || from == domain.AppointmentStatusNoShow {
+		return money.Money{}, false
+	}
+	if appointment.StartTime.Sub(at) >= r.Window {
+		return money.Money{}, false
+	}
+	return r.Fee, true
+}
+
+// NoShowRule charges appointments the client did not attend
+type NoShowRule struct {
+	Fee money.Money
+}
+
+// Kind returns the kind of charge raised by the rule
+func (r NoShowRule) Kind() domain.AppointmentChargeKind {
+	return domain.ChargeKindNoShow
+}
+
+// Evaluate applies whenever an appointment transitions to no-show
+func (r NoShowRule) Evaluate(appointment *domain.Appointment, from domain.AppointmentStatus, at time.Time) (money.Money, bool) {
+	if !r.Fee.IsPositive() || appointment.Status != domain.AppointmentStatusNoShow || from == domain.AppointmentStatusNoShow {
+		return money.Money{}, false
+	}
+	return r.Fee, true
+}
+
+// DefaultChargeRules builds the rule set for a charge policy
+func DefaultChargeRules(policy ChargePolicy) []ChargeRule {
+	return []ChargeRule{
+		LateCancellationRule{Window: policy.LateCancelWindow, Fee: policy.LateCancelFee},
+		NoShowRule{Fee: policy.NoShowFee},
+	}
+}
+
+// WaiveChargeRequest represents the request to waive an appointment charge
+type WaiveChargeRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// AppointmentChargeService raises and manages late-cancellation and no-show charges
+type AppointmentChargeService interface {
+	AppointmentTransitionHook
+
+	// GetCharge retrieves a charge by ID, including its invoice
+	GetCharge(ctx context.Context, id uuid.UUID) (*domain.AppointmentCharge, error)
+
+	// ListCharges retrieves a paginated list of charges with filters
+	ListCharges(ctx context.Context, filters repository.AppointmentChargeFilters) ([]*domain.AppointmentCharge, int, error)
+
+	// WaiveCharge cancels a pending charge and discards its draft invoice
+	WaiveCharge(ctx context.Context, id uuid.UUID, req *WaiveChargeRequest, waivedBy uuid.UUID) (*domain.AppointmentCharge, error)
+
+	// IssueCharge issues the draft invoice of a pending charge
+	IssueCharge(ctx context.Context, id uuid.UUID) (*domain.AppointmentCharge, error)
+}
+
+type appointmentChargeService struct {
+	chargeRepo     repository.AppointmentChargeRepository
+	invoiceService InvoiceService
+	rules          []ChargeRule
+	paymentTerm    int
+	now            func() time.Time
+}
+
+// NewAppointmentChargeService creates a new appointment charge service
+func NewAppointmentChargeService(
+	chargeRepo repository.AppointmentChargeRepository,
+	invoiceService InvoiceService,
+	policy ChargePolicy,
+) AppointmentChargeService {
+	paymentTerm := policy.PaymentTermDays
+	if paymentTerm <= 0 {
+		paymentTerm = 15
+	}
+
+	return &appointmentChargeService{
+		chargeRepo:     chargeRepo,
+		invoiceService: invoiceService,
+		rules:          DefaultChargeRules(policy),
+		paymentTerm:    paymentTerm,
+		now:            time.Now,
+	}
+}
+
+// OnAppointmentTransition evaluates the charge rules and creates a draft invoice for each match
+func (s *appointmentChargeService) OnAppointmentTransition(ctx context.Context, appointment *domain.Appointment, from domain.AppointmentStatus) error {
+	at := s.now()
+
+	for _, rule := range s.rules {
+		fee, applies := rule.Evaluate(appointment, from, at)
+		if !applies {
+			continue
+		}
+
+		// One charge per appointment and kind, even if the transition is replayed
+		if _, err := s.chargeRepo.GetByAppointmentAndKind(ctx, appointment.ID, rule.Kind()); err == nil {
+			continue
+		} else if !isNotFound(err) {
+			return err
+		}
+
+		if err := s.raiseCharge(ctx, appointment, rule.Kind(), fee, at); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// raiseCharge creates the draft invoice and the charge record linked to it
+func (s *appointmentChargeService) raiseCharge(ctx context.Context, appointment *domain.Appointment, kind domain.AppointmentChargeKind, fee money.Money, at time.Time) error {
+	charge := &domain.AppointmentCharge{
+		ID:            uuid.New(),
+		AppointmentID: appointment.ID,
+		ClientID:      appointment.ClientID,
+		Kind:          kind,
+		Amount:        fee,
+		Status:        domain.ChargeStatusPending,
+		CreatedAt:     at,
+		UpdatedAt:     at,
+	}
+
+	issueDate := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	appointmentID := appointment.ID
+
+	invoice, err := s.invoiceService.CreateDraftInvoice(ctx, &CreateInvoiceRequest{
+		ClientID:      appointment.ClientID,
+		AppointmentID: &appointmentID,
+		IssueDate:     issueDate,
+		DueDate:       issueDate.AddDate(0, 0, s.paymentTerm),
+		Lines: []InvoiceLineRequest{{
+			Description:   charge.Description(appointment),
+			UnitPrice:     &fee,
+			AppointmentID: &appointmentID,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create draft invoice for %s charge: %w", kind, err)
+	}
+
+	charge.InvoiceID = &invoice.ID
+
+	if err := s.chargeRepo.Create(ctx, charge); err != nil {
+		// Do not leave an orphan draft behind
+		_ = s.invoiceService.DeleteInvoice(ctx, invoice.ID)
+		return fmt.Errorf("failed to create %s charge: %w", kind, err)
+	}
+
+	return nil
+}
+
+// GetCharge retrieves a charge by ID, including its invoice
+func (s *appointmentChargeService) GetCharge(ctx context.Context, id uuid.UUID) (*domain.AppointmentCharge, error) {
+	charge, err := s.chargeRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if charge.InvoiceID != nil && charge.Status != domain.ChargeStatusWaived {
+		invoice, err := s.invoiceService.GetInvoice(ctx, *charge.InvoiceID)
+		if err == nil {
+			charge.Invoice = invoice
+		}
+	}
+
+	return charge, nil
+}
+
+// ListCharges retrieves a paginated list of charges with filters
+func (s *appointmentChargeService) ListCharges(ctx context.Context, filters repository.AppointmentChargeFilters) ([]*domain.AppointmentCharge, int, error) {
+	return s.chargeRepo.List(ctx, filters)
+}
+
+// WaiveCharge cancels a pending charge and discards its draft invoice
+func (s *appointmentChargeService) WaiveCharge(ctx context.Context, id uuid.UUID, req *WaiveChargeRequest, waivedBy uuid.UUID) (*domain.AppointmentCharge, error) {
+	charge, err := s.chargeRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !charge.IsPending() {
+		return nil, errors.NewValidationError("only pending charges can be waived", map[string][]string{
+			"status": {fmt.Sprintf("charge is already %s", charge.Status)},
+		})
+	}
+
+	if charge.InvoiceID != nil {
+		if err := s.invoiceService.DeleteInvoice(ctx, *charge.InvoiceID); err != nil && !isNotFound(err) {
+			return nil, fmt.Errorf("failed to discard draft invoice: %w", err)
+		}
+	}
+
+	now := s.now()
+	reason := req.Reason
+	charge.Status = domain.ChargeStatusWaived
+	charge.WaivedBy = &waivedBy
+	charge.WaiveReason = &reason
+	charge.WaivedAt = &now
+	charge.UpdatedAt = now
+
+	if err := s.chargeRepo.Update(ctx, charge); err != nil {
+		return nil, fmt.Errorf("failed to waive charge: %w", err)
+	}
+
+	return charge, nil
+}
+
+// IssueCharge issues the draft invoice of a pending charge
+func (s *appointmentChargeService) IssueCharge(ctx context.Context, id uuid.UUID) (*domain.AppointmentCharge, error) {
+	charge, err := s.chargeRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !charge.IsPending() {
+		return nil, errors.NewValidationError("only pending charges can be issued", map[string][]string{
+			"status": {fmt.Sprintf("charge is already %s", charge.Status)},
+		})
+	}
+
+	if charge.InvoiceID == nil {
+		return nil, errors.NewValidationError("charge has no draft invoice", nil)
+	}
+
+	invoice, err := s.invoiceService.IssueInvoice(ctx, *charge.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	charge.Status = domain.ChargeStatusIssued
+	charge.UpdatedAt = s.now()
+	charge.Invoice = invoice
+
+	if err := s.chargeRepo.Update(ctx, charge); err != nil {
+		return nil, fmt.Errorf("failed to update charge: %w", err)
+	}
+
+	return charge, nil
+}
+
+// isNotFound reports whether err is a not-found application error
+func isNotFound(err error) bool {
+	appErr, ok := err.(*errors.AppError)
+	return ok && appErr.Code == errors.CodeNotFound
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAppointmentChargeRepository is a mock implementation of AppointmentChargeRepository
+type MockAppointmentChargeRepository struct {
+	mock.Mock
+}
+
+func (m *MockAppointmentChargeRepository) Create(ctx context.Context, charge *domain.AppointmentCharge) error {
+	args := m.Called(ctx, charge)
+	return args.Error(0)
+}
+
+func (m *MockAppointmentChargeRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.AppointmentCharge, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.AppointmentCharge), args.Error(1)
+}
+
+func (m *MockAppointmentChargeRepository) GetByAppointmentAndKind(ctx context.Context, appointmentID uuid.UUID, kind domain.AppointmentChargeKind) (*domain.AppointmentCharge, error) {
+	args := m.Called(ctx, appointmentID, kind)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.AppointmentCharge), args.Error(1)
+}
+
+func (m *MockAppointmentChargeRepository) List(ctx context.Context, filters repository.AppointmentChargeFilters) ([]*domain.AppointmentCharge, int, error) {
+	args := m.Called(ctx, filters)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]*domain.AppointmentCharge), args.Int(1), args.Error(2)
+}
+
+func (m *MockAppointmentChargeRepository) Update(ctx context.Context, charge *domain.AppointmentCharge) error {
+	args := m.Called(ctx, charge)
+	return args.Error(0)
+}
+
+// MockInvoiceService is a mock implementation of InvoiceService
+type MockInvoiceService struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceService) invoiceResult(args mock.Arguments) (*domain.Invoice, error) {
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Invoice), args.Error(1)
+}
+
+func (m *MockInvoiceService) CreateInvoice(ctx context.Context, req *CreateInvoiceRequest) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, req))
+}
+
+func (m *MockInvoiceService) CreateDraftInvoice(ctx context.Context, req *CreateInvoiceRequest) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, req))
+}
+
+func (m *MockInvoiceService) IssueInvoice(ctx context.Context, id uuid.UUID) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, id))
+}
+
+func (m *MockInvoiceService) CreateInvoiceFromAppointment(ctx context.Context, appointmentID uuid.UUID, req *CreateInvoiceFromAppointmentRequest) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, appointmentID, req))
+}
+
+func (m *MockInvoiceService) InvoiceAppointments(ctx context.Context, clientID uuid.UUID, appointments []*domain.Appointment, req *InvoiceAppointmentsRequest) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, clientID, appointments, req))
+}
+
+func (m *MockInvoiceService) GetAppointmentInvoice(ctx context.Context, appointmentID uuid.UUID) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, appointmentID))
+}
+
+func (m *MockInvoiceService) GetInvoice(ctx context.Context, id uuid.UUID) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, id))
+}
+
+func (m *MockInvoiceService) GetInvoiceByNumber(ctx context.Context, invoiceNumber string) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, invoiceNumber))
+}
+
+func (m *MockInvoiceService) ListInvoices(ctx context.Context, filters repository.InvoiceFilters) ([]*domain.Invoice, int, error) {
+	args := m.Called(ctx, filters)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]*domain.Invoice), args.Int(1), args.Error(2)
+}
+
+func (m *MockInvoiceService) UpdateInvoice(ctx context.Context, id uuid.UUID, req *UpdateInvoiceRequest) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, id, req))
+}
+
+func (m *MockInvoiceService) DeleteInvoice(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockInvoiceService) CreateRectifyingInvoice(ctx context.Context, id uuid.UUID, req *CreateRectifyingInvoiceRequest) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, id, req))
+}
+
+func (m *MockInvoiceService) GetRectifyingInvoices(ctx context.Context, id uuid.UUID) ([]*domain.Invoice, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Invoice), args.Error(1)
+}
+
+func (m *MockInvoiceService) GetClientInvoices(ctx context.Context, clientID uuid.UUID) ([]*domain.Invoice, error) {
+	args := m.Called(ctx, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Invoice), args.Error(1)
+}
+
+func (m *MockInvoiceService) GetUnpaidInvoices(ctx context.Context) ([]*domain.Invoice, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Invoice), args.Error(1)
+}
+
+func newTestChargeService(chargeRepo *MockAppointmentChargeRepository, invoiceService *MockInvoiceService, now time.Time) *appointmentChargeService {
+	svc := NewAppointmentChargeService(chargeRepo, invoiceService, ChargePolicy{
+		LateCancelWindow: 24 * time.Hour,
+		LateCancelFee:    money.MustParse("30"),
+		NoShowFee:        money.MustParse("50"),
+	}).(*appointmentChargeService)
+	svc.now = func() time.Time { return now }
+	return svc
+}
+
+func cancelledBy(party domain.CancellationParty) *domain.CancellationParty {
+	return &party
+}
+
+func TestAppointmentChargeService_LateCancellationCreatesDraftInvoice(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	chargeRepo := new(MockAppointmentChargeRepository)
+	invoiceService := new(MockInvoiceService)
+	svc := newTestChargeService(chargeRepo, invoiceService, now)
+
+	appointment := &domain.Appointment{
+		ID:          uuid.New(),
+		ClientID:    uuid.New(),
+		StartTime:   now.Add(5 * time.Hour),
+		Status:      domain.AppointmentStatusCancelled,
+		CancelledBy: cancelledBy(domain.CancelledByClient),
+	}
+	draft := &domain.Invoice{ID: uuid.New(), Status: domain.InvoiceStatusDraft}
+
+	chargeRepo.On("GetByAppointmentAndKind", ctx, appointment.ID, domain.ChargeKindLateCancellation).
+		Return(nil, errors.NewNotFoundError("appointment charge not found"))
+	invoiceService.On("CreateDraftInvoice", ctx, mock.MatchedBy(func(req *CreateInvoiceRequest) bool {
+		return req.ClientID == appointment.ClientID &&
+			req.AppointmentID != nil && *req.AppointmentID == appointment.ID &&
+			len(req.Lines) == 1 && req.Lines[0].UnitPrice.Equal(money.MustParse("30")) &&
+			req.DueDate.Sub(req.IssueDate) == 15*24*time.Hour
+	})).Return(draft, nil)
+	chargeRepo.On("Create", ctx, mock.MatchedBy(func(charge *domain.AppointmentCharge) bool {
+		return charge.Kind == domain.ChargeKindLateCancellation &&
+			charge.Status == domain.ChargeStatusPending &&
+			charge.InvoiceID != nil && *charge.InvoiceID == draft.ID
+	})).Return(nil)
+
+	err := svc.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusConfirmed)
+
+	require.NoError(t, err)
+	chargeRepo.AssertExpectations(t)
+	invoiceService.AssertExpectations(t)
+}
+
+func TestAppointmentChargeService_CancellationOutsideWindowIsFree(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	chargeRepo := new(MockAppointmentChargeRepository)
+	invoiceService := new(MockInvoiceService)
+	svc := newTestChargeService(chargeRepo, invoiceService, now)
+
+	appointment := &domain.Appointment{
+		ID:          uuid.New(),
+		ClientID:    uuid.New(),
+		StartTime:   now.Add(48 * time.Hour),
+		Status:      domain.AppointmentStatusCancelled,
+		CancelledBy: cancelledBy(domain.CancelledByClient),
+	}
+
+	err := svc.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusPending)
+
+	require.NoError(t, err)
+	chargeRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	invoiceService.AssertNotCalled(t, "CreateDraftInvoice", mock.Anything, mock.Anything)
+}
+
+func TestAppointmentChargeService_ClinicCancellationIsFree(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	chargeRepo := new(MockAppointmentChargeRepository)
+	invoiceService := new(MockInvoiceService)
+	svc := newTestChargeService(chargeRepo, invoiceService, now)
+
+	for _, party := range []*domain.CancellationParty{cancelledBy(domain.CancelledByClinic), nil} {
+		appointment := &domain.Appointment{
+			ID:          uuid.New(),
+			ClientID:    uuid.New(),
+			StartTime:   now.Add(2 * time.Hour),
+			Status:      domain.AppointmentStatusCancelled,
+			CancelledBy: party,
+		}
+
+		require.NoError(t, svc.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusConfirmed))
+	}
+
+	chargeRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	invoiceService.AssertNotCalled(t, "CreateDraftInvoice", mock.Anything, mock.Anything)
+}
+
+func TestChargePolicy_ClientCancellationCutoff(t *testing.T) {
+	// Without a fee clients keep the 24h notice; with one they may cancel late and are charged
+	assert.Equal(t, DefaultClientCancellationCutoff, ChargePolicy{LateCancelWindow: 24 * time.Hour}.ClientCancellationCutoff())
+	assert.Equal(t, time.Duration(0), ChargePolicy{LateCancelWindow: 24 * time.Hour, LateCancelFee: money.MustParse("30")}.ClientCancellationCutoff())
+}
+
+func TestAppointmentChargeService_NoShowIsChargedOnce(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	chargeRepo := new(MockAppointmentChargeRepository)
+	invoiceService := new(MockInvoiceService)
+	svc := newTestChargeService(chargeRepo, invoiceService, now)
+
+	appointment := &domain.Appointment{
+		ID:        uuid.New(),
+		ClientID:  uuid.New(),
+		StartTime: now.Add(-time.Hour),
+		Status:    domain.AppointmentStatusNoShow,
+	}
+
+	chargeRepo.On("GetByAppointmentAndKind", ctx, appointment.ID, domain.ChargeKindNoShow).
+		Return(&domain.AppointmentCharge{ID: uuid.New(), Kind: domain.ChargeKindNoShow}, nil)
+
+	err := svc.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusConfirmed)
+
+	require.NoError(t, err)
+	invoiceService.AssertNotCalled(t, "CreateDraftInvoice", mock.Anything, mock.Anything)
+}
+
+func TestAppointmentChargeService_WaiveCharge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	chargeRepo := new(MockAppointmentChargeRepository)
+	invoiceService := new(MockInvoiceService)
+	svc := newTestChargeService(chargeRepo, invoiceService, now)
+
+	invoiceID := uuid.New()
+	adminID := uuid.New()
+	charge := &domain.AppointmentCharge{
+		ID:        uuid.New(),
+		Kind:      domain.ChargeKindNoShow,
+		Status:    domain.ChargeStatusPending,
+		InvoiceID: &invoiceID,
+	}
+
+	chargeRepo.On("GetByID", ctx, charge.ID).Return(charge, nil)
+	invoiceService.On("DeleteInvoice", ctx, invoiceID).Return(nil)
+	chargeRepo.On("Update", ctx, charge).Return(nil)
+
+	result, err := svc.WaiveCharge(ctx, charge.ID, &WaiveChargeRequest{Reason: "therapist was ill"}, adminID)
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.ChargeStatusWaived, result.Status)
+	assert.Equal(t, adminID, *result.WaivedBy)
+	assert.Equal(t, "therapist was ill", *result.WaiveReason)
+	invoiceService.AssertExpectations(t)
+
+	// A waived charge can no longer be issued
+	_, err = svc.IssueCharge(ctx, charge.ID)
+	assert.Error(t, err)
+}
+
+func TestAppointmentChargeService_IssueCharge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	chargeRepo := new(MockAppointmentChargeRepository)
+	invoiceService := new(MockInvoiceService)
+	svc := newTestChargeService(chargeRepo, invoiceService, now)
+
+	invoiceID := uuid.New()
+	charge := &domain.AppointmentCharge{
+		ID:        uuid.New(),
+		Kind:      domain.ChargeKindLateCancellation,
+		Status:    domain.ChargeStatusPending,
+		InvoiceID: &invoiceID,
+	}
+	issued := &domain.Invoice{ID: invoiceID, InvoiceNumber: "F_2025_0007", Status: domain.InvoiceStatusUnpaid}
+
+	chargeRepo.On("GetByID", ctx, charge.ID).Return(charge, nil)
+	invoiceService.On("IssueInvoice", ctx, invoiceID).Return(issued, nil)
+	chargeRepo.On("Update", ctx, charge).Return(nil)
+
+	result, err := svc.IssueCharge(ctx, charge.ID)
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.ChargeStatusIssued, result.Status)
+	assert.Equal(t, "F_2025_0007", result.Invoice.InvoiceNumber)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+)
+
+// appointmentInvoicer invoices completed appointments as set in the billing settings
+type appointmentInvoicer struct {
+	settingsRepo    repository.BillingSettingsRepository
+	sessionPackRepo repository.SessionPackRepository
+	insurerRepo     repository.InsurerRepository
+	invoiceService  InvoiceService
+}
+
+// NewAppointmentInvoicer creates the transition hook that drafts or issues the invoice of
+// an appointment when it is completed; it does nothing while the setting is off,
+// appointments paid with a session pack are not invoiced and those covered by an insurer
+// are invoiced only their co-payment
+func NewAppointmentInvoicer(
+	settingsRepo repository.BillingSettingsRepository,
+	sessionPackRepo repository.SessionPackRepository,
+	insurerRepo repository.InsurerRepository,
+	invoiceService InvoiceService,
+) AppointmentTransitionHook {
+	return &appointmentInvoicer{
+		settingsRepo:    settingsRepo,
+		sessionPackRepo: sessionPackRepo,
+		insurerRepo:     insurerRepo,
+		invoiceService:  invoiceService,
+	}
+}
+
+// OnAppointmentTransition invoices an appointment that has just been completed
+func (h *appointmentInvoicer) OnAppointmentTransition(ctx context.Context, appointment *domain.Appointment, from domain.AppointmentStatus) error {
+	if appointment.Status != domain.AppointmentStatusCompleted || from == domain.AppointmentStatusCompleted {
+		return nil
+	}
+
+	settings, err := h.settingsRepo.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get billing settings: %w", err)
+	}
+
+	mode := settings.AppointmentInvoicing
+	if mode != domain.AppointmentInvoicingDraft && mode != domain.AppointmentInvoicingIssue {
+		return nil
+	}
+
+	// The session was prepaid with the pack
+	_, err = h.sessionPackRepo.GetUsageByAppointment(ctx, appointment.ID)
+	if err == nil {
+		return nil
+	}
+	if !isNotFound(err) {
+		return fmt.Errorf("failed to get session pack usage: %w", err)
+	}
+
+	req := &CreateInvoiceFromAppointmentRequest{
+		Draft: mode == domain.AppointmentInvoicingDraft,
+	}
+
+	// The insurer pays the session but the co-payment, settled monthly
+	covered, err := h.insurerRepo.GetCoveredSession(ctx, appointment.ID)
+	if err == nil {
+		if covered.CopayAmount.IsZero() {
+			return nil
+		}
+		copay := covered.CopayAmount
+		req.UnitPrice = &copay
+		req.Notes = "Copago del paciente; el resto de la sesión lo abona su aseguradora"
+	} else if !isNotFound(err) {
+		return fmt.Errorf("failed to get covered session: %w", err)
+	}
+
+	_, err = h.invoiceService.CreateInvoiceFromAppointment(ctx, appointment.ID, req)
+	if appErr, ok := err.(*errors.AppError); ok && appErr.Code == errors.CodeConflict {
+		// Already invoiced by hand, e.g. paid in advance, or by a batch run at the same time;
+		// the repository locks the appointment, so only one of them stores an invoice
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newAppointmentInvoicerTest(mode domain.AppointmentInvoicingMode) (AppointmentTransitionHook, *MockInvoiceService) {
+	hook, _, invoiceService := newPrepaidAppointmentInvoicerTest(mode)
+	return hook, invoiceService
+}
+
+func newPrepaidAppointmentInvoicerTest(mode domain.AppointmentInvoicingMode) (AppointmentTransitionHook, *MockSessionPackRepository, *MockInvoiceService) {
+	settingsRepo := new(MockBillingSettingsRepository)
+	settingsRepo.On("Get", mock.Anything).Return(&domain.BillingSettings{AppointmentInvoicing: mode}, nil)
+	sessionPackRepo := new(MockSessionPackRepository)
+	sessionPackRepo.On("GetUsageByAppointment", mock.Anything, mock.Anything).
+		Return(nil, errors.NewNotFoundError("session pack usage not found")).Maybe()
+	invoiceService := new(MockInvoiceService)
+
+	return NewAppointmentInvoicer(settingsRepo, sessionPackRepo, newNotCoveredRepository(), invoiceService), sessionPackRepo, invoiceService
+}
+
+func TestAppointmentInvoicer_InvoicesCompletedAppointments(t *testing.T) {
+	ctx := context.Background()
+	appointment := &domain.Appointment{ID: uuid.New(), Status: domain.AppointmentStatusCompleted}
+
+	for mode, draft := range map[domain.AppointmentInvoicingMode]bool{
+		domain.AppointmentInvoicingDraft: true,
+		domain.AppointmentInvoicingIssue: false,
+	} {
+		hook, invoiceService := newAppointmentInvoicerTest(mode)
+		invoiceService.On("CreateInvoiceFromAppointment", ctx, appointment.ID, &CreateInvoiceFromAppointmentRequest{Draft: draft}).
+			Return(&domain.Invoice{ID: uuid.New()}, nil).Once()
+
+		assert.NoError(t, hook.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusConfirmed))
+		invoiceService.AssertExpectations(t)
+	}
+}
+
+func TestAppointmentInvoicer_SkipsWhenOffOrNotCompleted(t *testing.T) {
+	ctx := context.Background()
+
+	hook, invoiceService := newAppointmentInvoicerTest(domain.AppointmentInvoicingOff)
+	completed := &domain.Appointment{ID: uuid.New(), Status: domain.AppointmentStatusCompleted}
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, completed, domain.AppointmentStatusConfirmed))
+	invoiceService.AssertNotCalled(t, "CreateInvoiceFromAppointment", mock.Anything, mock.Anything, mock.Anything)
+
+	hook, invoiceService = newAppointmentInvoicerTest(domain.AppointmentInvoicingIssue)
+	noShow := &domain.Appointment{ID: uuid.New(), Status: domain.AppointmentStatusNoShow}
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, noShow, domain.AppointmentStatusConfirmed))
+	invoiceService.AssertNotCalled(t, "CreateInvoiceFromAppointment", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAppointmentInvoicer_IgnoresAppointmentsInvoicedByHand(t *testing.T) {
+	ctx := context.Background()
+	appointment := &domain.Appointment{ID: uuid.New(), Status: domain.AppointmentStatusCompleted}
+
+	hook, invoiceService := newAppointmentInvoicerTest(domain.AppointmentInvoicingIssue)
+	invoiceService.On("CreateInvoiceFromAppointment", ctx, appointment.ID, mock.Anything).
+		Return(nil, errors.NewConflictError("appointment already invoiced in F_2025_0004", errors.CodeConflict))
+
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusPending))
+}
+
+func TestAppointmentInvoicer_SkipsAppointmentsPaidWithAPack(t *testing.T) {
+	ctx := context.Background()
+	appointment := &domain.Appointment{ID: uuid.New(), Status: domain.AppointmentStatusCompleted}
+
+	hook, sessionPackRepo, invoiceService := newPrepaidAppointmentInvoicerTest(domain.AppointmentInvoicingIssue)
+	sessionPackRepo.ExpectedCalls = nil
+	sessionPackRepo.On("GetUsageByAppointment", ctx, appointment.ID).
+		Return(&domain.SessionPackUsage{ID: uuid.New(), AppointmentID: appointment.ID}, nil)
+
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusConfirmed))
+	invoiceService.AssertNotCalled(t, "CreateInvoiceFromAppointment", mock.Anything, mock.Anything, mock.Anything)
+}
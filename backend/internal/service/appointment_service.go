@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
@@ -22,6 +23,8 @@ type AppointmentServiceInterface interface {
 
 	// Admin operations
 	ConfirmAppointment(ctx context.Context, id uuid.UUID, req domain.ConfirmAppointmentRequest) (*domain.Appointment, error)
+	MarkNoShow(ctx context.Context, id uuid.UUID) (*domain.Appointment, error)
+	CompleteAppointment(ctx context.Context, id uuid.UUID) (*domain.Appointment, error)
 	ListAppointments(ctx context.Context, filters domain.AppointmentFilter) ([]*domain.Appointment, int, error)
 	GetAppointmentsByEmployee(ctx context.Context, employeeID uuid.UUID, startDate, endDate time.Time) ([]*domain.Appointment, error)
 	GetAvailableSlots(ctx context.Context, employeeID uuid.UUID, date time.Time, duration int) ([]time.Time, error)
@@ -31,17 +34,60 @@ type AppointmentServiceInterface interface {
 	ValidateAppointmentTime(ctx context.Context, employeeID uuid.UUID, startTime time.Time, duration int, excludeID *uuid.UUID) error
 }
 
+// AppointmentTransitionHook is notified after an appointment changes status
+type AppointmentTransitionHook interface {
+	OnAppointmentTransition(ctx context.Context, appointment *domain.Appointment, from domain.AppointmentStatus) error
+}
+
+// BookingAdvisor reviews a new booking for issues that do not prevent it, such as an
+// insurance authorisation of the client that is used up or expired
+type BookingAdvisor interface {
+	BookingWarnings(ctx context.Context, appointment *domain.Appointment) ([]domain.BookingWarning, error)
+}
+
 type appointmentService struct {
 	appointmentRepo repository.AppointmentRepository
 	clientRepo      repository.ClientRepository
 	employeeRepo    repository.EmployeeRepository
+	advisor         BookingAdvisor
+	hooks           []AppointmentTransitionHook
+
+	clientCancelCutoff time.Duration // How long before the start clients may still cancel
 }
 
-func NewAppointmentService(appointmentRepo repository.AppointmentRepository, clientRepo repository.ClientRepository, employeeRepo repository.EmployeeRepository) AppointmentServiceInterface {
+// NewAppointmentService creates a new appointment service; the advisor (optional) warns about
+// new bookings, clients may cancel until clientCancelCutoff before the start and hooks are
+// notified of status transitions
+func NewAppointmentService(appointmentRepo repository.AppointmentRepository, clientRepo repository.ClientRepository, employeeRepo repository.EmployeeRepository, advisor BookingAdvisor, clientCancelCutoff time.Duration, hooks ...AppointmentTransitionHook) AppointmentServiceInterface {
 	return &appointmentService{
-		appointmentRepo: appointmentRepo,
-		clientRepo:      clientRepo,
-		employeeRepo:    employeeRepo,
+		appointmentRepo:    appointmentRepo,
+		clientRepo:         clientRepo,
+		employeeRepo:       employeeRepo,
+		advisor:            advisor,
+		hooks:              hooks,
+		clientCancelCutoff: clientCancelCutoff,
+	}
+}
+
+// bookingWarnings asks the advisor about a booking; failures are logged and never block it
+func (s *appointmentService) bookingWarnings(ctx context.Context, appointment *domain.Appointment) []domain.BookingWarning {
+	if s.advisor == nil {
+		return nil
+	}
+	warnings, err := s.advisor.BookingWarnings(ctx, appointment)
+	if err != nil {
+		log.Printf("[WARN] Appointment %s booking checks failed: %v", appointment.ID, err)
+		return nil
+	}
+	return warnings
+}
+
+// notifyTransition runs the transition hooks; failures are logged and never undo the transition
+func (s *appointmentService) notifyTransition(ctx context.Context, appointment *domain.Appointment, from domain.AppointmentStatus) {
+	for _, hook := range s.hooks {
+		if err := hook.OnAppointmentTransition(ctx, appointment, from); err != nil {
+			log.Printf("[WARN] Appointment %s transition %s -> %s hook failed: %v", appointment.ID, from, appointment.Status, err)
+		}
 	}
 }
 
@@ -93,6 +139,16 @@ func (s *appointmentService) CreateAppointment(ctx context.Context, req domain.C
 		return nil, fmt.Errorf("la duración debe ser 45 o 60 minutos")
 	}
 
+	// Parse the optional service type
+	var serviceTypeID *uuid.UUID
+	if req.ServiceTypeID != "" {
+		parsed, err := uuid.Parse(req.ServiceTypeID)
+		if err != nil {
+			return nil, fmt.Errorf("serviceTypeId no válido")
+		}
+		serviceTypeID = &parsed
+	}
+
 	// Calculate end time
 	endTime := req.StartTime.Add(time.Duration(req.DurationMinutes) * time.Minute)
 
@@ -141,6 +197,7 @@ func (s *appointmentService) CreateAppointment(ctx context.Context, req domain.C
 	appointment.Description = req.Description
 	appointment.Status = domain.AppointmentStatusPending
 	appointment.Room = room
+	appointment.ServiceTypeID = serviceTypeID
 	appointment.CreatedBy = createdBy // User who created the appointment
 	appointment.CreatedAt = time.Now()
 	appointment.UpdatedAt = time.Now()
@@ -150,7 +207,13 @@ func (s *appointmentService) CreateAppointment(ctx context.Context, req domain.C
 	}
 
 	// Load relations for response
-	return s.appointmentRepo.GetByIDWithRelations(ctx, appointment.ID)
+	created, err := s.appointmentRepo.GetByIDWithRelations(ctx, appointment.ID)
+	if err != nil {
+		return nil, err
+	}
+	created.Warnings = s.bookingWarnings(ctx, created)
+
+	return created, nil
 }
 
 // GetAppointment retrieves an appointment by ID
@@ -216,6 +279,14 @@ func (s *appointmentService) UpdateAppointment(ctx context.Context, id uuid.UUID
 		appointment.EmployeeID = employeeID
 	}
 
+	if req.ServiceTypeID != "" {
+		serviceTypeID, err := uuid.Parse(req.ServiceTypeID)
+		if err != nil {
+			return nil, fmt.Errorf("serviceTypeId no válido")
+		}
+		appointment.ServiceTypeID = &serviceTypeID
+	}
+
 	// Handle room update
 	if req.Room != "" {
 		room := domain.RoomType(req.Room)
@@ -304,7 +375,17 @@ func (s *appointmentService) UpdateAppointment(ctx context.Context, id uuid.UUID
 		return nil, fmt.Errorf("failed to update appointment: %w", err)
 	}
 
-	return s.appointmentRepo.GetByIDWithRelations(ctx, id)
+	updated, err := s.appointmentRepo.GetByIDWithRelations(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// A new date or service may fall outside the authorisation of the client
+	if timeChanged || req.ServiceTypeID != "" {
+		updated.Warnings = s.bookingWarnings(ctx, updated)
+	}
+
+	return updated, nil
 }
 
 // CancelAppointment cancels an appointment
@@ -324,13 +405,21 @@ func (s *appointmentService) CancelAppointment(ctx context.Context, id uuid.UUID
 			return fmt.Errorf("no tienes permiso para cancelar esta cita")
 		}
 
-		if !appointment.CanBeCancelledByClient() {
+		if !appointment.CanBeCancelledByClient(s.clientCancelCutoff) {
 			return fmt.Errorf("la cita no puede ser cancelada (ya pasó o ya está cancelada)")
 		}
 	}
 
+	// Staff cancellations are the clinic's unless recorded on the client's behalf
+	cancelledBy := domain.CancelledByClient
+	if isAdmin && !req.OnBehalfOfClient {
+		cancelledBy = domain.CancelledByClinic
+	}
+
 	// Update appointment
+	previousStatus := appointment.Status
 	appointment.Status = domain.AppointmentStatusCancelled
+	appointment.CancelledBy = &cancelledBy
 	appointment.CancellationReason = domain.NullableString{
 		NullString: sql.NullString{
 			String: req.Reason,
@@ -343,6 +432,8 @@ func (s *appointmentService) CancelAppointment(ctx context.Context, id uuid.UUID
 		return fmt.Errorf("failed to cancel appointment: %w", err)
 	}
 
+	s.notifyTransition(ctx, appointment, previousStatus)
+
 	return nil
 }
 
@@ -399,6 +490,54 @@ func (s *appointmentService) ConfirmAppointment(ctx context.Context, id uuid.UUI
 	return s.appointmentRepo.GetByIDWithRelations(ctx, id)
 }
 
+// MarkNoShow marks an appointment whose client did not attend (admin/employee only)
+func (s *appointmentService) MarkNoShow(ctx context.Context, id uuid.UUID) (*domain.Appointment, error) {
+	appointment, err := s.appointmentRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("cita no encontrada")
+	}
+
+	if !appointment.CanBeMarkedNoShow() {
+		return nil, fmt.Errorf("solo se pueden marcar como no presentadas citas ya iniciadas y no cerradas")
+	}
+
+	previousStatus := appointment.Status
+	appointment.Status = domain.AppointmentStatusNoShow
+	appointment.UpdatedAt = time.Now()
+
+	if err := s.appointmentRepo.Update(ctx, appointment); err != nil {
+		return nil, fmt.Errorf("failed to mark appointment as no-show: %w", err)
+	}
+
+	s.notifyTransition(ctx, appointment, previousStatus)
+
+	return s.appointmentRepo.GetByIDWithRelations(ctx, id)
+}
+
+// CompleteAppointment marks an attended appointment as completed (admin/employee only)
+func (s *appointmentService) CompleteAppointment(ctx context.Context, id uuid.UUID) (*domain.Appointment, error) {
+	appointment, err := s.appointmentRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("cita no encontrada")
+	}
+
+	if !appointment.CanBeCompleted() {
+		return nil, fmt.Errorf("solo se pueden completar citas ya iniciadas y no cerradas")
+	}
+
+	previousStatus := appointment.Status
+	appointment.Status = domain.AppointmentStatusCompleted
+	appointment.UpdatedAt = time.Now()
+
+	if err := s.appointmentRepo.Update(ctx, appointment); err != nil {
+		return nil, fmt.Errorf("failed to complete appointment: %w", err)
+	}
+
+	s.notifyTransition(ctx, appointment, previousStatus)
+
+	return s.appointmentRepo.GetByIDWithRelations(ctx, id)
+}
+
 // ListAppointments lists all appointments with filters (admin only)
 func (s *appointmentService) ListAppointments(ctx context.Context, filters domain.AppointmentFilter) ([]*domain.Appointment, int, error) {
 	appointments, err := s.appointmentRepo.ListWithRelations(ctx, filters)
@@ -85,6 +85,14 @@ func (m *MockAppointmentRepository) GetByEmployeeID(ctx context.Context, employe
 	return args.Get(0).([]*domain.Appointment), args.Error(1)
 }
 
+func (m *MockAppointmentRepository) ListUninvoiced(ctx context.Context, from, to time.Time) ([]*domain.Appointment, error) {
+	args := m.Called(ctx, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Appointment), args.Error(1)
+}
+
 func (m *MockAppointmentRepository) GetByDateRange(ctx context.Context, startDate, endDate time.Time, employeeID *uuid.UUID) ([]*domain.Appointment, error) {
 	args := m.Called(ctx, startDate, endDate, employeeID)
 	if args.Get(0) == nil {
@@ -295,7 +303,7 @@ func TestCreateAppointment_Success(t *testing.T) {
 	mockAppointmentRepo := new(MockAppointmentRepository)
 	mockClientRepo := new(MockClientRepository)
 	mockEmployeeRepo := new(MockEmployeeRepository)
-	service := NewAppointmentService(mockAppointmentRepo, mockClientRepo, mockEmployeeRepo)
+	service := NewAppointmentService(mockAppointmentRepo, mockClientRepo, mockEmployeeRepo, nil, DefaultClientCancellationCutoff)
 
 	ctx := context.Background()
 	clientID := uuid.New()
@@ -363,7 +371,7 @@ func TestCreateAppointment_InvalidEmployee(t *testing.T) {
 	mockAppointmentRepo := new(MockAppointmentRepository)
 	mockClientRepo := new(MockClientRepository)
 	mockEmployeeRepo := new(MockEmployeeRepository)
-	service := NewAppointmentService(mockAppointmentRepo, mockClientRepo, mockEmployeeRepo)
+	service := NewAppointmentService(mockAppointmentRepo, mockClientRepo, mockEmployeeRepo, nil, DefaultClientCancellationCutoff)
 
 	ctx := context.Background()
 	clientID := uuid.New()
@@ -396,7 +404,7 @@ func TestCreateAppointment_WeekendRejected(t *testing.T) {
 	mockAppointmentRepo := new(MockAppointmentRepository)
 	mockClientRepo := new(MockClientRepository)
 	mockEmployeeRepo := new(MockEmployeeRepository)
-	service := NewAppointmentService(mockAppointmentRepo, mockClientRepo, mockEmployeeRepo)
+	service := NewAppointmentService(mockAppointmentRepo, mockClientRepo, mockEmployeeRepo, nil, DefaultClientCancellationCutoff)
 
 	ctx := context.Background()
 	clientID := uuid.New()
@@ -447,7 +455,7 @@ func TestGetAvailableSlots_Success(t *testing.T) {
 	mockAppointmentRepo := new(MockAppointmentRepository)
 	mockClientRepo := new(MockClientRepository)
 	mockEmployeeRepo := new(MockEmployeeRepository)
-	service := NewAppointmentService(mockAppointmentRepo, mockClientRepo, mockEmployeeRepo)
+	service := NewAppointmentService(mockAppointmentRepo, mockClientRepo, mockEmployeeRepo, nil, DefaultClientCancellationCutoff)
 
 	ctx := context.Background()
 	employeeID := uuid.New()
@@ -492,7 +500,7 @@ func TestConfirmAppointment_Success(t *testing.T) {
 	mockAppointmentRepo := new(MockAppointmentRepository)
 	mockClientRepo := new(MockClientRepository)
 	mockEmployeeRepo := new(MockEmployeeRepository)
-	service := NewAppointmentService(mockAppointmentRepo, mockClientRepo, mockEmployeeRepo)
+	service := NewAppointmentService(mockAppointmentRepo, mockClientRepo, mockEmployeeRepo, nil, DefaultClientCancellationCutoff)
 
 	ctx := context.Background()
 	appointmentID := uuid.New()
@@ -523,3 +531,71 @@ func TestConfirmAppointment_Success(t *testing.T) {
 	assert.Equal(t, domain.AppointmentStatusConfirmed, appointment.Status)
 	mockAppointmentRepo.AssertExpectations(t)
 }
+
+func TestCancelAppointment_RecordsWhoCancelled(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	client := &domain.Client{ID: uuid.New()}
+
+	tests := []struct {
+		name    string
+		isAdmin bool
+		req     domain.CancelAppointmentRequest
+		want    domain.CancellationParty
+	}{
+		{"client", false, domain.CancelAppointmentRequest{Reason: "Enfermedad"}, domain.CancelledByClient},
+		{"clinic", true, domain.CancelAppointmentRequest{Reason: "Terapeuta de baja"}, domain.CancelledByClinic},
+		{"clinic on behalf of the client", true, domain.CancelAppointmentRequest{Reason: "Llamó para cancelar", OnBehalfOfClient: true}, domain.CancelledByClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAppointmentRepo := new(MockAppointmentRepository)
+			mockClientRepo := new(MockClientRepository)
+			// Late cancellations are charged, so the client may cancel three hours before
+			service := NewAppointmentService(mockAppointmentRepo, mockClientRepo, new(MockEmployeeRepository), nil, 0)
+
+			appointment := &domain.Appointment{
+				ID:        uuid.New(),
+				ClientID:  client.ID,
+				StartTime: time.Now().Add(3 * time.Hour),
+				Status:    domain.AppointmentStatusConfirmed,
+			}
+			mockAppointmentRepo.On("GetByID", ctx, appointment.ID).Return(appointment, nil)
+			mockClientRepo.On("GetByUserID", ctx, userID).Return(client, nil).Maybe()
+			mockAppointmentRepo.On("Update", ctx, mock.AnythingOfType("*domain.Appointment")).Return(nil)
+
+			err := service.CancelAppointment(ctx, appointment.ID, tt.req, userID, tt.isAdmin)
+
+			assert.NoError(t, err)
+			assert.Equal(t, domain.AppointmentStatusCancelled, appointment.Status)
+			if assert.NotNil(t, appointment.CancelledBy) {
+				assert.Equal(t, tt.want, *appointment.CancelledBy)
+			}
+		})
+	}
+}
+
+func TestCancelAppointment_ClientCutoff(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	client := &domain.Client{ID: uuid.New()}
+
+	mockAppointmentRepo := new(MockAppointmentRepository)
+	mockClientRepo := new(MockClientRepository)
+	service := NewAppointmentService(mockAppointmentRepo, mockClientRepo, new(MockEmployeeRepository), nil, DefaultClientCancellationCutoff)
+
+	appointment := &domain.Appointment{
+		ID:        uuid.New(),
+		ClientID:  client.ID,
+		StartTime: time.Now().Add(3 * time.Hour),
+		Status:    domain.AppointmentStatusConfirmed,
+	}
+	mockAppointmentRepo.On("GetByID", ctx, appointment.ID).Return(appointment, nil)
+	mockClientRepo.On("GetByUserID", ctx, userID).Return(client, nil)
+
+	err := service.CancelAppointment(ctx, appointment.ID, domain.CancelAppointmentRequest{Reason: "Enfermedad"}, userID, false)
+
+	assert.Error(t, err)
+	mockAppointmentRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
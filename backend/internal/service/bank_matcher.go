@@ -0,0 +1,336 @@
+package service
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// BankMatchKind is what a bank movement is reconciled with
+type BankMatchKind string
+
+const (
+	BankMatchInvoice BankMatchKind = "invoice" // Incoming movements: records a payment
+	BankMatchExpense BankMatchKind = "expense" // Outgoing movements
+)
+
+// Reasons a movement matches a candidate, with the weight they add to its score
+const (
+	BankMatchReasonReference = "reference" // RF creditor reference of the invoice
+	BankMatchReasonNumber    = "number"    // Invoice or supplier invoice number in the concept
+	BankMatchReasonAmount    = "amount"    // Outstanding balance or expense amount
+	BankMatchReasonName      = "name"      // Payer or supplier name
+	bankMatchReferenceScore  = 50
+	bankMatchNumberScore     = 40
+	bankMatchAmountScore     = 40
+	bankMatchNameScore       = 20
+	bankSuggestionMinScore   = 40
+	bankMaxSuggestions       = 5
+)
+
+// BankMatchCandidate is an invoice or expense a bank movement may be reconciled with
+type BankMatchCandidate struct {
+	Kind    BankMatchKind `json:"kind"`
+	ID      uuid.UUID     `json:"id"`
+	Number  string        `json:"number,omitempty"` // Invoice number or supplier invoice number
+	Party   string        `json:"party"`            // Client or supplier
+	Amount  money.Money   `json:"amount"`           // Outstanding balance or expense amount
+	Score   int           `json:"score"`
+	Reasons []string      `json:"reasons"`
+}
+
+func (c *BankMatchCandidate) hasReason(reason string) bool {
+	for _, r := range c.Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// bankMatcher scores the outstanding invoices and unreconciled expenses against bank
+// movements. Reconciled candidates are settled so later movements do not match them again.
+type bankMatcher struct {
+	invoices []*invoiceTarget
+	expenses []*expenseTarget
+}
+
+type invoiceTarget struct {
+	invoice     *domain.Invoice
+	outstanding money.Money
+	reference   string // RF reference, upper case without spaces
+	number      *regexp.Regexp
+	name        []string
+}
+
+type expenseTarget struct {
+	expense *domain.Expense
+	number  *regexp.Regexp // nil without a supplier invoice number
+	name    []string
+}
+
+// newBankMatcher prepares the candidates; invoices without an outstanding balance are skipped
+func newBankMatcher(invoices []*domain.Invoice, expenses []*domain.Expense) (*bankMatcher, error) {
+	m := &bankMatcher{}
+	for _, invoice := range invoices {
+		if err := m.settleInvoice(invoice); err != nil {
+			return nil, err
+		}
+	}
+	for _, expense := range expenses {
+		target := &expenseTarget{expense: expense, name: nameTokens(expense.Supplier)}
+		if expense.SupplierInvoice != nil {
+			target.number = numberPattern(*expense.SupplierInvoice)
+		}
+		m.expenses = append(m.expenses, target)
+	}
+	return m, nil
+}
+
+// settleInvoice adds an invoice or replaces it with its updated balance
+func (m *bankMatcher) settleInvoice(invoice *domain.Invoice) error {
+	for i, target := range m.invoices {
+		if target.invoice.ID == invoice.ID {
+			m.invoices = append(m.invoices[:i], m.invoices[i+1:]...)
+			break
+		}
+	}
+	if !invoice.IsIssued() {
+		return nil
+	}
+
+	outstanding, err := invoice.OutstandingAmount()
+	if err != nil {
+		return err
+	}
+	if !outstanding.IsPositive() {
+		return nil
+	}
+
+	target := &invoiceTarget{
+		invoice:     invoice,
+		outstanding: outstanding,
+		number:      numberPattern(invoice.InvoiceNumber),
+	}
+	if ref := invoice.PaymentReference(); strings.HasPrefix(ref, "RF") {
+		target.reference = ref
+	}
+	// Transfers come from whoever pays the invoice, not always the client
+	if invoice.Client != nil || invoice.HasPayer() {
+		target.name = nameTokens(invoice.Recipient(invoice.Client).Name)
+	}
+	m.invoices = append(m.invoices, target)
+	return nil
+}
+
+// settleExpense removes a reconciled expense
+func (m *bankMatcher) settleExpense(id uuid.UUID) {
+	for i, target := range m.expenses {
+		if target.expense.ID == id {
+			m.expenses = append(m.expenses[:i], m.expenses[i+1:]...)
+			return
+		}
+	}
+}
+
+// candidates returns the invoices (incoming movements) or expenses (outgoing movements) the
+// movement may be reconciled with, best first
+func (m *bankMatcher) candidates(movement *domain.BankMovement) []*BankMatchCandidate {
+	text := foldText(joinText(movement.Concept, movement.Reference))
+	compact := alphanumeric(text)
+	words := wordSet(joinText(text, foldText(movement.Counterparty)))
+
+	var candidates []*BankMatchCandidate
+	if movement.IsIncoming() {
+		for _, target := range m.invoices {
+			if candidate := target.match(movement, text, compact, words); candidate != nil {
+				candidates = append(candidates, candidate)
+			}
+		}
+	} else {
+		for _, target := range m.expenses {
+			if candidate := target.match(movement, text, words); candidate != nil {
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+// match scores an incoming movement against the invoice. A movement above the balance, or
+// received before the invoice was issued, cannot pay it; one below the balance is only a
+// candidate when it names the invoice.
+func (t *invoiceTarget) match(movement *domain.BankMovement, text, compact string, words map[string]bool) *BankMatchCandidate {
+	if movement.BookingDate.Before(t.invoice.IssueDate) {
+		return nil
+	}
+	cmp, err := movement.Amount.Cmp(t.outstanding)
+	if err != nil || cmp > 0 {
+		return nil
+	}
+
+	candidate := &BankMatchCandidate{
+		Kind:    BankMatchInvoice,
+		ID:      t.invoice.ID,
+		Number:  t.invoice.InvoiceNumber,
+		Amount:  t.outstanding,
+		Reasons: []string{},
+	}
+	if t.invoice.Client != nil || t.invoice.HasPayer() {
+		candidate.Party = t.invoice.Recipient(t.invoice.Client).Name
+	}
+
+	switch {
+	case t.reference != "" && strings.Contains(compact, t.reference):
+		candidate.add(BankMatchReasonReference, bankMatchReferenceScore)
+	case t.number != nil && t.number.MatchString(text):
+		candidate.add(BankMatchReasonNumber, bankMatchNumberScore)
+	}
+	if cmp == 0 {
+		candidate.add(BankMatchReasonAmount, bankMatchAmountScore)
+	} else if candidate.Score == 0 {
+		return nil
+	}
+	if containsName(words, t.name) {
+		candidate.add(BankMatchReasonName, bankMatchNameScore)
+	}
+
+	return candidate
+}
+
+// match scores an outgoing movement against the expense, which is paid in full
+func (t *expenseTarget) match(movement *domain.BankMovement, text string, words map[string]bool) *BankMatchCandidate {
+	if !movement.Amount.Abs().Equal(t.expense.Amount) {
+		return nil
+	}
+
+	candidate := &BankMatchCandidate{
+		Kind:    BankMatchExpense,
+		ID:      t.expense.ID,
+		Party:   t.expense.Supplier,
+		Amount:  t.expense.Amount,
+		Reasons: []string{},
+	}
+	if t.expense.SupplierInvoice != nil {
+		candidate.Number = *t.expense.SupplierInvoice
+	}
+
+	if t.number != nil && t.number.MatchString(text) {
+		candidate.add(BankMatchReasonNumber, bankMatchNumberScore)
+	}
+	candidate.add(BankMatchReasonAmount, bankMatchAmountScore)
+	if containsName(words, t.name) {
+		candidate.add(BankMatchReasonName, bankMatchNameScore)
+	}
+
+	return candidate
+}
+
+func (c *BankMatchCandidate) add(reason string, score int) {
+	c.Reasons = append(c.Reasons, reason)
+	c.Score += score
+}
+
+// autoMatch returns the candidate a movement is reconciled with without review: the only
+// best one, matching the amount and at least the reference, number or name
+func autoMatch(candidates []*BankMatchCandidate) *BankMatchCandidate {
+	if len(candidates) == 0 {
+		return nil
+	}
+	best := candidates[0]
+	if len(candidates) > 1 && candidates[1].Score == best.Score {
+		return nil
+	}
+	if !best.hasReason(BankMatchReasonAmount) || len(best.Reasons) < 2 {
+		return nil
+	}
+	return best
+}
+
+// suggestions returns the best candidates worth showing in the reconciliation queue
+func suggestions(candidates []*BankMatchCandidate) []*BankMatchCandidate {
+	result := []*BankMatchCandidate{}
+	for _, candidate := range candidates {
+		if candidate.Score < bankSuggestionMinScore || len(result) == bankMaxSuggestions {
+			break
+		}
+		result = append(result, candidate)
+	}
+	return result
+}
+
+// foldText upper-cases a text and removes its accents
+func foldText(s string) string {
+	return strings.ToUpper(sepaAccents.Replace(s))
+}
+
+// alphanumeric keeps the letters and digits of a folded text
+func alphanumeric(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// numberPattern matches a document number in a folded text, with or without the separators
+// between its parts, but not inside a longer number (F_2025_0010 matches "F2025-0010")
+func numberPattern(number string) *regexp.Regexp {
+	parts := strings.FieldsFunc(foldText(number), func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9')
+	})
+	if len(parts) == 0 || len(strings.Join(parts, "")) < 4 {
+		return nil
+	}
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile(`(?:^|[^A-Z0-9])` + strings.Join(parts, `[^A-Z0-9]?`) + `(?:[^A-Z0-9]|$)`)
+}
+
+// nameTokens returns the significant words of a person or company name
+func nameTokens(name string) []string {
+	var tokens []string
+	for word := range wordSet(foldText(name)) {
+		if len(word) >= 3 && word != "DEL" && word != "LOS" && word != "LAS" {
+			tokens = append(tokens, word)
+		}
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// containsName reports whether the words include two words of the name, or its only one
+func containsName(words map[string]bool, name []string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	found := 0
+	for _, token := range name {
+		if words[token] {
+			found++
+		}
+	}
+	return found >= 2 || found == len(name)
+}
+
+// wordSet splits a folded text into its words
+func wordSet(s string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9')
+	}) {
+		words[word] = true
+	}
+	return words
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// MaxBankStatementFileBytes is the maximum size of an imported bank statement
+const MaxBankStatementFileBytes = 10 << 20
+
+// Expenses are matched when dated up to bankExpenseDaysBefore days before a movement, since
+// they are usually recorded when the supplier invoice arrives, or bankExpenseDaysAfter after
+const (
+	bankExpenseDaysBefore = 120
+	bankExpenseDaysAfter  = 30
+)
+
+// bankPaymentNotes are the notes of the payments recorded from a bank movement
+const bankPaymentNotes = "Conciliación bancaria"
+
+// BankStatementImport summarizes the import of a bank statement
+type BankStatementImport struct {
+	Statement   *domain.BankStatement `json:"statement"`
+	AutoMatched int                   `json:"autoMatched"` // Movements reconciled on import
+	Pending     int                   `json:"pending"`     // Movements left in the reconciliation queue
+}
+
+// BankQueueItem is a movement of the reconciliation queue with its best candidates
+type BankQueueItem struct {
+	Movement    *domain.BankMovement  `json:"movement"`
+	Suggestions []*BankMatchCandidate `json:"suggestions"`
+}
+
+// ConfirmBankMatchRequest represents the request to reconcile a movement with an invoice
+// (incoming movements) or an expense (outgoing movements)
+type ConfirmBankMatchRequest struct {
+	InvoiceID *uuid.UUID `json:"invoiceId,omitempty"`
+	ExpenseID *uuid.UUID `json:"expenseId,omitempty"`
+}
+
+// BankReconciliation is a reconciled movement with the payment it recorded
+type BankReconciliation struct {
+	Movement *domain.BankMovement `json:"movement"`
+	Payment  *domain.Payment      `json:"payment,omitempty"`
+	Invoice  *domain.Invoice      `json:"invoice,omitempty"`
+}
+
+// BankReconciliationService imports bank statements and reconciles their movements
+type BankReconciliationService interface {
+	// ImportStatement reads a Norma 43 or camt.053 statement. Movements that match a single
+	// outstanding invoice or unreconciled expense are reconciled, recording the payment of the
+	// invoice; the others are left in the reconciliation queue.
+	ImportStatement(ctx context.Context, fileName string, file io.Reader, importedBy uuid.UUID) (*BankStatementImport, error)
+
+	// GetStatement retrieves a statement with its movements
+	GetStatement(ctx context.Context, id uuid.UUID) (*domain.BankStatement, error)
+
+	// ListStatements retrieves the imported statements, newest first
+	ListStatements(ctx context.Context) ([]*domain.BankStatement, error)
+
+	// ListQueue retrieves the pending movements with the invoices or expenses they may match
+	ListQueue(ctx context.Context) ([]*BankQueueItem, error)
+
+	// ConfirmMatch reconciles a pending movement with an invoice, recording its payment, or
+	// with an expense
+	ConfirmMatch(ctx context.Context, movementID uuid.UUID, req *ConfirmBankMatchRequest, userID uuid.UUID) (*BankReconciliation, error)
+
+	// IgnoreMovement takes a pending movement with nothing to reconcile out of the queue
+	IgnoreMovement(ctx context.Context, movementID uuid.UUID, userID uuid.UUID) (*domain.BankMovement, error)
+}
+
+type bankReconciliationService struct {
+	bankRepo    repository.BankStatementRepository
+	invoiceRepo repository.InvoiceRepository
+	expenseRepo repository.ExpenseRepository
+	now         func() time.Time
+}
+
+// NewBankReconciliationService creates a new bank reconciliation service
+func NewBankReconciliationService(
+	bankRepo repository.BankStatementRepository,
+	invoiceRepo repository.InvoiceRepository,
+	expenseRepo repository.ExpenseRepository,
+) BankReconciliationService {
+	return &bankReconciliationService{
+		bankRepo:    bankRepo,
+		invoiceRepo: invoiceRepo,
+		expenseRepo: expenseRepo,
+		now:         time.Now,
+	}
+}
+
+// ImportStatement reads a statement and reconciles the movements matched without doubt
+func (s *bankReconciliationService) ImportStatement(ctx context.Context, fileName string, file io.Reader, importedBy uuid.UUID) (*BankStatementImport, error) {
+	content, err := io.ReadAll(io.LimitReader(file, MaxBankStatementFileBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bank statement: %w", err)
+	}
+	if len(content) > MaxBankStatementFileBytes {
+		return nil, errors.NewValidationError(fmt.Sprintf("bank statement exceeds the maximum size of %d MB", MaxBankStatementFileBytes>>20), nil)
+	}
+
+	statement, err := parseBankStatement(content)
+	if err != nil {
+		return nil, errors.NewValidationError("file is not a Norma 43 or camt.053 bank statement", map[string][]string{
+			"file": {err.Error()},
+		})
+	}
+
+	hash := sha256.Sum256(content)
+	now := s.now()
+	statement.ID = uuid.New()
+	statement.FileName = truncateRunes(fileName, 255)
+	statement.Account = truncateRunes(statement.Account, 34)
+	statement.ContentHash = hex.EncodeToString(hash[:])
+	statement.ImportedBy = &importedBy
+	statement.ImportedAt = now
+	for _, movement := range statement.Movements {
+		movement.ID = uuid.New()
+		movement.StatementID = statement.ID
+		movement.Concept = truncateRunes(movement.Concept, 500)
+		movement.Status = domain.BankMovementPending
+		movement.CreatedAt = now
+		movement.UpdatedAt = now
+	}
+
+	// Movements are stored pending first, so a failed match only leaves them in the queue
+	if err := s.bankRepo.Create(ctx, statement); err != nil {
+		return nil, err
+	}
+
+	matcher, err := s.matcher(ctx, statement.FromDate, statement.ToDate)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BankStatementImport{Statement: statement}
+	for _, movement := range statement.Movements {
+		candidate := autoMatch(matcher.candidates(movement))
+		if candidate == nil {
+			result.Pending++
+			continue
+		}
+
+		if err := s.reconcile(ctx, matcher, movement, candidate, nil); err != nil {
+			log.Printf("[WARN] Bank movement %s left pending: %v", movement.ID, err)
+			result.Pending++
+			continue
+		}
+		result.AutoMatched++
+	}
+
+	return result, nil
+}
+
+// GetStatement retrieves a statement with its movements
+func (s *bankReconciliationService) GetStatement(ctx context.Context, id uuid.UUID) (*domain.BankStatement, error) {
+	return s.bankRepo.GetByID(ctx, id)
+}
+
+// ListStatements retrieves the imported statements
+func (s *bankReconciliationService) ListStatements(ctx context.Context) ([]*domain.BankStatement, error) {
+	return s.bankRepo.List(ctx)
+}
+
+// ListQueue retrieves the pending movements, oldest first, with their suggestions
+func (s *bankReconciliationService) ListQueue(ctx context.Context) ([]*BankQueueItem, error) {
+	status := domain.BankMovementPending
+	movements, err := s.bankRepo.ListMovements(ctx, repository.BankMovementFilters{Status: &status})
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*BankQueueItem{}
+	if len(movements) == 0 {
+		return items, nil
+	}
+
+	// Movements are ordered by booking date
+	matcher, err := s.matcher(ctx, movements[0].BookingDate, movements[len(movements)-1].BookingDate)
+	if err != nil {
+		return nil, err
+	}
+	for _, movement := range movements {
+		items = append(items, &BankQueueItem{
+			Movement:    movement,
+			Suggestions: suggestions(matcher.candidates(movement)),
+		})
+	}
+
+	return items, nil
+}
+
+// ConfirmMatch reconciles a pending movement with the invoice or expense chosen by the user
+func (s *bankReconciliationService) ConfirmMatch(ctx context.Context, movementID uuid.UUID, req *ConfirmBankMatchRequest, userID uuid.UUID) (*BankReconciliation, error) {
+	if (req.InvoiceID == nil) == (req.ExpenseID == nil) {
+		return nil, errors.NewValidationError("choose either an invoice or an expense", map[string][]string{
+			"invoiceId": {"exactly one of invoiceId and expenseId is required"},
+		})
+	}
+
+	movement, err := s.bankRepo.GetMovement(ctx, movementID)
+	if err != nil {
+		return nil, err
+	}
+	if !movement.IsPending() {
+		return nil, domain.ErrMovementNotPending
+	}
+
+	if req.InvoiceID != nil {
+		if !movement.IsIncoming() {
+			return nil, domain.ErrMovementDirection
+		}
+		invoice, err := s.invoiceRepo.GetByID(ctx, *req.InvoiceID)
+		if err != nil {
+			return nil, err
+		}
+		if movement.BookingDate.Before(invoice.IssueDate) {
+			return nil, errors.NewValidationError("movement is before the invoice was issued", map[string][]string{
+				"invoiceId": {fmt.Sprintf("invoice %s was issued on %s", invoice.InvoiceNumber, invoice.IssueDate.Format("02/01/2006"))},
+			})
+		}
+		return s.reconcileInvoice(ctx, movement, invoice.ID, &userID)
+	}
+
+	if movement.IsIncoming() {
+		return nil, domain.ErrMovementDirection
+	}
+	expense, err := s.expenseRepo.GetByID(ctx, *req.ExpenseID)
+	if err != nil {
+		return nil, err
+	}
+	if !movement.Amount.Abs().Equal(expense.Amount) {
+		return nil, domain.ErrMovementAmountMismatch
+	}
+	if err := s.reconcileExpense(ctx, movement, expense.ID, &userID); err != nil {
+		return nil, err
+	}
+	return &BankReconciliation{Movement: movement}, nil
+}
+
+// IgnoreMovement takes a pending movement out of the queue
+func (s *bankReconciliationService) IgnoreMovement(ctx context.Context, movementID uuid.UUID, userID uuid.UUID) (*domain.BankMovement, error) {
+	movement, err := s.bankRepo.GetMovement(ctx, movementID)
+	if err != nil {
+		return nil, err
+	}
+	if !movement.IsPending() {
+		return nil, domain.ErrMovementNotPending
+	}
+
+	now := s.now()
+	movement.Status = domain.BankMovementIgnored
+	movement.ReconciledBy = &userID
+	movement.ReconciledAt = &now
+	movement.UpdatedAt = now
+
+	if err := s.bankRepo.IgnoreMovement(ctx, movement); err != nil {
+		return nil, err
+	}
+
+	return movement, nil
+}
+
+// matcher loads the candidates of movements booked between two dates
+func (s *bankReconciliationService) matcher(ctx context.Context, fromDate, toDate time.Time) (*bankMatcher, error) {
+	invoices, err := s.invoiceRepo.GetOutstandingInvoices(ctx, repository.OutstandingInvoiceFilters{})
+	if err != nil {
+		return nil, err
+	}
+	expenses, err := s.bankRepo.ListUnreconciledExpenses(ctx,
+		fromDate.AddDate(0, 0, -bankExpenseDaysBefore), toDate.AddDate(0, 0, bankExpenseDaysAfter))
+	if err != nil {
+		return nil, err
+	}
+
+	return newBankMatcher(invoices, expenses)
+}
+
+// reconcile applies an automatic match and settles the candidate in the matcher
+func (s *bankReconciliationService) reconcile(ctx context.Context, matcher *bankMatcher, movement *domain.BankMovement, candidate *BankMatchCandidate, userID *uuid.UUID) error {
+	if candidate.Kind == BankMatchExpense {
+		if err := s.reconcileExpense(ctx, movement, candidate.ID, userID); err != nil {
+			return err
+		}
+		matcher.settleExpense(candidate.ID)
+		return nil
+	}
+
+	reconciliation, err := s.reconcileInvoice(ctx, movement, candidate.ID, userID)
+	if err != nil {
+		return err
+	}
+	return matcher.settleInvoice(reconciliation.Invoice)
+}
+
+// reconcileInvoice records the movement as a transfer paying the invoice. userID is nil for
+// automatic matches.
+func (s *bankReconciliationService) reconcileInvoice(ctx context.Context, movement *domain.BankMovement, invoiceID uuid.UUID, userID *uuid.UUID) (*BankReconciliation, error) {
+	reference := movement.Reference
+	if reference == "" {
+		reference = movement.Concept
+	}
+
+	now := s.now()
+	payment := &domain.Payment{
+		ID:          uuid.New(),
+		InvoiceID:   invoiceID,
+		Amount:      movement.Amount,
+		PaymentDate: movement.BookingDate,
+		Method:      domain.PaymentMethodTransfer,
+		Reference:   truncateRunes(reference, 100),
+		Notes:       bankPaymentNotes,
+		RecordedBy:  userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	movement.Status = domain.BankMovementReconciled
+	movement.InvoiceID = &invoiceID
+	movement.PaymentID = &payment.ID
+	movement.AutoMatched = userID == nil
+	movement.ReconciledBy = userID
+	movement.ReconciledAt = &now
+	movement.UpdatedAt = now
+
+	// The repository checks the balance while the invoice is locked
+	invoice, err := s.bankRepo.ReconcileInvoice(ctx, movement, payment)
+	if err != nil {
+		resetMovement(movement)
+		return nil, err
+	}
+
+	return &BankReconciliation{Movement: movement, Payment: payment, Invoice: invoice}, nil
+}
+
+// reconcileExpense links the movement to the expense it paid
+func (s *bankReconciliationService) reconcileExpense(ctx context.Context, movement *domain.BankMovement, expenseID uuid.UUID, userID *uuid.UUID) error {
+	now := s.now()
+	movement.Status = domain.BankMovementReconciled
+	movement.ExpenseID = &expenseID
+	movement.AutoMatched = userID == nil
+	movement.ReconciledBy = userID
+	movement.ReconciledAt = &now
+	movement.UpdatedAt = now
+
+	if err := s.bankRepo.ReconcileExpense(ctx, movement); err != nil {
+		resetMovement(movement)
+		return err
+	}
+	return nil
+}
+
+// resetMovement puts back in the queue a movement whose reconciliation failed
+func resetMovement(movement *domain.BankMovement) {
+	movement.Status = domain.BankMovementPending
+	movement.InvoiceID = nil
+	movement.PaymentID = nil
+	movement.ExpenseID = nil
+	movement.AutoMatched = false
+	movement.ReconciledBy = nil
+	movement.ReconciledAt = nil
+}
@@ -0,0 +1,474 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockBankStatementRepository is a mock implementation of repository.BankStatementRepository
+type MockBankStatementRepository struct {
+	mock.Mock
+}
+
+func (m *MockBankStatementRepository) Create(ctx context.Context, statement *domain.BankStatement) error {
+	args := m.Called(ctx, statement)
+	return args.Error(0)
+}
+
+func (m *MockBankStatementRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.BankStatement, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BankStatement), args.Error(1)
+}
+
+func (m *MockBankStatementRepository) List(ctx context.Context) ([]*domain.BankStatement, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.BankStatement), args.Error(1)
+}
+
+func (m *MockBankStatementRepository) GetMovement(ctx context.Context, id uuid.UUID) (*domain.BankMovement, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BankMovement), args.Error(1)
+}
+
+func (m *MockBankStatementRepository) ListMovements(ctx context.Context, filters repository.BankMovementFilters) ([]*domain.BankMovement, error) {
+	args := m.Called(ctx, filters)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.BankMovement), args.Error(1)
+}
+
+func (m *MockBankStatementRepository) ListUnreconciledExpenses(ctx context.Context, fromDate, toDate time.Time) ([]*domain.Expense, error) {
+	args := m.Called(ctx, fromDate, toDate)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Expense), args.Error(1)
+}
+
+func (m *MockBankStatementRepository) ReconcileInvoice(ctx context.Context, movement *domain.BankMovement, payment *domain.Payment) (*domain.Invoice, error) {
+	args := m.Called(ctx, movement, payment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Invoice), args.Error(1)
+}
+
+func (m *MockBankStatementRepository) ReconcileExpense(ctx context.Context, movement *domain.BankMovement) error {
+	args := m.Called(ctx, movement)
+	return args.Error(0)
+}
+
+func (m *MockBankStatementRepository) IgnoreMovement(ctx context.Context, movement *domain.BankMovement) error {
+	args := m.Called(ctx, movement)
+	return args.Error(0)
+}
+
+type bankTestMocks struct {
+	bankRepo    *MockBankStatementRepository
+	invoiceRepo *MockInvoiceRepository
+	expenseRepo *MockExpenseRepository
+}
+
+func newBankTestService() (*bankReconciliationService, *bankTestMocks) {
+	m := &bankTestMocks{
+		bankRepo:    new(MockBankStatementRepository),
+		invoiceRepo: new(MockInvoiceRepository),
+		expenseRepo: new(MockExpenseRepository),
+	}
+	svc := NewBankReconciliationService(m.bankRepo, m.invoiceRepo, m.expenseRepo).(*bankReconciliationService)
+	svc.now = func() time.Time { return time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC) }
+	return svc, m
+}
+
+// bankTestInvoice is issuedTestInvoice (361.00) billed to Lucía Martín Núñez
+func bankTestInvoice(t *testing.T) *domain.Invoice {
+	invoice := issuedTestInvoice(t)
+	invoice.Client = &domain.Client{ID: invoice.ClientID, FirstName: "Lucía", LastName: "Martín Núñez"}
+	return invoice
+}
+
+func bankTestExpense() *domain.Expense {
+	supplierInvoice := "PROV-88"
+	return &domain.Expense{
+		ID:              uuid.New(),
+		ExpenseDate:     utcDay(2025, 3, 5),
+		SupplierInvoice: &supplierInvoice,
+		Supplier:        "Suministros Médicos S.L.",
+		Amount:          money.MustParse("121.00"),
+	}
+}
+
+// norma43Record pads the fields of a record to the 80 characters of the format
+func norma43Record(fields ...string) string {
+	return fmt.Sprintf("%-80s", strings.Join(fields, ""))
+}
+
+// norma43MovementRecord builds a movement record (22) with a credit (2) or debit (1) sign
+func norma43MovementRecord(date, sign string, cents int64, ref1, ref2 string) string {
+	return norma43Record("22", "    ", "0813", date, date, "02", "099", sign,
+		fmt.Sprintf("%014d", cents), "0000000000", fmt.Sprintf("%-12s", ref1), fmt.Sprintf("%-16s", ref2))
+}
+
+// norma43ConceptRecord builds a concept record (23)
+func norma43ConceptRecord(concept1, concept2 string) string {
+	return norma43Record("23", "01", fmt.Sprintf("%-38s", concept1), concept2)
+}
+
+func bankTestNorma43() string {
+	return strings.Join([]string{
+		norma43Record("11", "2100", "0813", "6101234567", "250301", "250331", "2", "00000000100000", "978", "3", "CLINICA ARNELA"),
+		norma43MovementRecord("250315", "2", 36100, "", "TRF-0001"),
+		norma43ConceptRecord("TRANSFERENCIA DE LUCIA MARTIN NUNEZ", "FRA F2025-0010"),
+		norma43MovementRecord("250318", "1", 12100, "", ""),
+		norma43ConceptRecord("PAGO SUMINISTROS MEDICOS SL", "PROV-88"),
+		norma43MovementRecord("250319", "2", 5000, "", ""),
+		norma43ConceptRecord("INGRESO EFECTIVO", ""),
+		norma43Record("33", "2100", "0813", "6101234567"),
+		norma43Record("88", "999999999999999999", "000008"),
+	}, "\r\n")
+}
+
+const bankTestCAMT053 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>STMT-2025-03</MsgId><CreDtTm>2025-04-01T08:00:00</CreDtTm></GrpHdr>
+    <Stmt>
+      <Id>STMT-2025-03</Id>
+      <Acct><Id><IBAN>ES7921000813610123456789</IBAN></Id></Acct>
+      <FrToDt><FrDtTm>2025-03-01T00:00:00</FrDtTm><ToDtTm>2025-03-31T23:59:59</ToDtTm></FrToDt>
+      <Ntry>
+        <Amt Ccy="EUR">361.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2025-03-15</Dt></BookgDt>
+        <ValDt><Dt>2025-03-16</Dt></ValDt>
+        <AcctSvcrRef>BANK-REF-1</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <Refs><EndToEndId>NOTPROVIDED</EndToEndId></Refs>
+          <RltdPties><Dbtr><Nm>Lucía Martín Núñez</Nm></Dbtr></RltdPties>
+          <RmtInf><Ustrd>Pago factura F_2025_0010</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">121.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2025-03-18</Dt></BookgDt>
+        <ValDt><Dt>2025-03-18</Dt></ValDt>
+        <NtryDtls><TxDtls>
+          <Refs><EndToEndId>E2E-PROV-88</EndToEndId></Refs>
+          <RltdPties><Cdtr><Nm>Suministros Médicos S.L.</Nm></Cdtr></RltdPties>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">80.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>PDNG</Sts>
+        <BookgDt><Dt>2025-03-31</Dt></BookgDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+func TestParseBankStatement(t *testing.T) {
+	t.Run("Norma 43", func(t *testing.T) {
+		statement, err := parseBankStatement([]byte(bankTestNorma43()))
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.BankStatementNorma43, statement.Format)
+		assert.Equal(t, "210008136101234567", statement.Account)
+		assert.Equal(t, utcDay(2025, 3, 1), statement.FromDate)
+		assert.Equal(t, utcDay(2025, 3, 31), statement.ToDate)
+		require.Len(t, statement.Movements, 3)
+		assert.Equal(t, 3, statement.MovementCount)
+
+		incoming := statement.Movements[0]
+		assert.Equal(t, utcDay(2025, 3, 15), incoming.BookingDate)
+		assert.Equal(t, money.MustParse("361.00"), incoming.Amount)
+		assert.Equal(t, "TRF-0001", incoming.Reference)
+		assert.Equal(t, "TRANSFERENCIA DE LUCIA MARTIN NUNEZ FRA F2025-0010", incoming.Concept)
+		assert.Equal(t, money.MustParse("-121.00"), statement.Movements[1].Amount)
+	})
+
+	t.Run("camt.053", func(t *testing.T) {
+		statement, err := parseBankStatement([]byte(bankTestCAMT053))
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.BankStatementCAMT053, statement.Format)
+		assert.Equal(t, "ES7921000813610123456789", statement.Account)
+		assert.Equal(t, utcDay(2025, 3, 31), statement.ToDate)
+		require.Len(t, statement.Movements, 2, "pending entries are not imported")
+
+		incoming := statement.Movements[0]
+		assert.Equal(t, money.MustParse("361.00"), incoming.Amount)
+		assert.Equal(t, utcDay(2025, 3, 16), incoming.ValueDate)
+		assert.Equal(t, "Lucía Martín Núñez", incoming.Counterparty)
+		assert.Equal(t, "Pago factura F_2025_0010", incoming.Concept)
+		assert.Equal(t, "BANK-REF-1", incoming.Reference)
+
+		outgoing := statement.Movements[1]
+		assert.Equal(t, money.MustParse("-121.00"), outgoing.Amount)
+		assert.Equal(t, "Suministros Médicos S.L.", outgoing.Counterparty)
+		assert.Equal(t, "E2E-PROV-88", outgoing.Reference)
+	})
+
+	t.Run("Invalid files", func(t *testing.T) {
+		for _, content := range []string{"", "not a statement", "<Document></Document>", norma43MovementRecord("250315", "2", 100, "", "")} {
+			_, err := parseBankStatement([]byte(content))
+			assert.Error(t, err, content)
+		}
+	})
+}
+
+func TestBankReconciliationService_ImportStatement(t *testing.T) {
+	ctx := context.Background()
+	importedBy := uuid.New()
+
+	t.Run("Reconciles the movements matching a single candidate", func(t *testing.T) {
+		svc, m := newBankTestService()
+		invoice := bankTestInvoice(t)
+		expense := bankTestExpense()
+		paid := *invoice
+		paid.PaidAmount = invoice.TotalAmount
+		paid.Status = domain.InvoiceStatusPaid
+
+		var payment *domain.Payment
+		m.bankRepo.On("Create", ctx, mock.AnythingOfType("*domain.BankStatement")).Return(nil)
+		m.invoiceRepo.On("GetOutstandingInvoices", ctx, repository.OutstandingInvoiceFilters{}).Return([]*domain.Invoice{invoice}, nil)
+		m.bankRepo.On("ListUnreconciledExpenses", ctx, utcDay(2024, 11, 1), utcDay(2025, 4, 30)).Return([]*domain.Expense{expense}, nil)
+		m.bankRepo.On("ReconcileInvoice", ctx, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { payment = args.Get(2).(*domain.Payment) }).
+			Return(&paid, nil)
+		m.bankRepo.On("ReconcileExpense", ctx, mock.Anything).Return(nil)
+
+		result, err := svc.ImportStatement(ctx, "marzo.n43", strings.NewReader(bankTestNorma43()), importedBy)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.AutoMatched)
+		assert.Equal(t, 1, result.Pending)
+		assert.Equal(t, "marzo.n43", result.Statement.FileName)
+		assert.Len(t, result.Statement.ContentHash, 64)
+
+		movements := result.Statement.Movements
+		assert.Equal(t, domain.BankMovementReconciled, movements[0].Status)
+		assert.True(t, movements[0].AutoMatched)
+		assert.Nil(t, movements[0].ReconciledBy)
+		assert.Equal(t, &invoice.ID, movements[0].InvoiceID)
+		require.NotNil(t, payment)
+		assert.Equal(t, &payment.ID, movements[0].PaymentID)
+		assert.Equal(t, money.MustParse("361.00"), payment.Amount)
+		assert.Equal(t, domain.PaymentMethodTransfer, payment.Method)
+		assert.Equal(t, utcDay(2025, 3, 15), payment.PaymentDate)
+		assert.Equal(t, "TRF-0001", payment.Reference)
+
+		assert.Equal(t, domain.BankMovementReconciled, movements[1].Status)
+		assert.Equal(t, &expense.ID, movements[1].ExpenseID)
+		assert.Equal(t, domain.BankMovementPending, movements[2].Status)
+	})
+
+	t.Run("Leaves ambiguous and failed matches pending", func(t *testing.T) {
+		svc, m := newBankTestService()
+		invoice := bankTestInvoice(t)
+		twin := bankTestInvoice(t)
+		twin.InvoiceNumber = "F_2025_0011"
+		expense := bankTestExpense()
+
+		m.bankRepo.On("Create", ctx, mock.Anything).Return(nil)
+		m.invoiceRepo.On("GetOutstandingInvoices", ctx, repository.OutstandingInvoiceFilters{}).Return([]*domain.Invoice{invoice}, nil)
+		m.bankRepo.On("ListUnreconciledExpenses", ctx, mock.Anything, mock.Anything).Return([]*domain.Expense{expense}, nil)
+		m.bankRepo.On("ReconcileInvoice", ctx, mock.Anything, mock.Anything).Return(nil, domain.ErrMovementNotPending)
+		m.bankRepo.On("ReconcileExpense", ctx, mock.Anything).Return(domain.ErrExpenseAlreadyReconciled)
+
+		result, err := svc.ImportStatement(ctx, "marzo.n43", strings.NewReader(bankTestNorma43()), importedBy)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.AutoMatched)
+		assert.Equal(t, 3, result.Pending)
+		for _, movement := range result.Statement.Movements {
+			assert.Equal(t, domain.BankMovementPending, movement.Status)
+			assert.Nil(t, movement.InvoiceID)
+			assert.Nil(t, movement.ExpenseID)
+		}
+
+		// Two invoices with the same balance, named only by the payer
+		m.invoiceRepo.ExpectedCalls = nil
+		m.invoiceRepo.On("GetOutstandingInvoices", ctx, repository.OutstandingInvoiceFilters{}).Return([]*domain.Invoice{invoice, twin}, nil)
+		matcher, err := svc.matcher(ctx, utcDay(2025, 3, 1), utcDay(2025, 3, 31))
+		require.NoError(t, err)
+		movement := &domain.BankMovement{BookingDate: utcDay(2025, 3, 15), Amount: money.MustParse("361.00"), Counterparty: "LUCIA MARTIN"}
+		candidates := matcher.candidates(movement)
+		require.Len(t, candidates, 2)
+		assert.Nil(t, autoMatch(candidates))
+	})
+
+	t.Run("Statement imported before", func(t *testing.T) {
+		svc, m := newBankTestService()
+		m.bankRepo.On("Create", ctx, mock.Anything).Return(domain.ErrStatementAlreadyImported)
+
+		_, err := svc.ImportStatement(ctx, "marzo.xml", strings.NewReader(bankTestCAMT053), importedBy)
+
+		assert.Equal(t, domain.ErrStatementAlreadyImported, err)
+		m.invoiceRepo.AssertNotCalled(t, "GetOutstandingInvoices", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Not a bank statement", func(t *testing.T) {
+		svc, _ := newBankTestService()
+
+		_, err := svc.ImportStatement(ctx, "factura.pdf", strings.NewReader("%PDF-1.4"), importedBy)
+
+		requireValidationError(t, err)
+	})
+}
+
+func TestBankReconciliationService_ListQueue(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newBankTestService()
+	invoice := bankTestInvoice(t)
+	other := bankTestInvoice(t)
+	other.InvoiceNumber = "F_2025_0042"
+	other.Client = &domain.Client{FirstName: "Pedro", LastName: "Gil"}
+
+	partial := &domain.BankMovement{ID: uuid.New(), BookingDate: utcDay(2025, 3, 10), Amount: money.MustParse("200.00"), Concept: "PAGO A CUENTA FRA F 2025 0010", Status: domain.BankMovementPending}
+	unknown := &domain.BankMovement{ID: uuid.New(), BookingDate: utcDay(2025, 3, 12), Amount: money.MustParse("15.00"), Concept: "ABONO", Status: domain.BankMovementPending}
+	fee := &domain.BankMovement{ID: uuid.New(), BookingDate: utcDay(2025, 3, 31), Amount: money.MustParse("-3.50"), Concept: "COMISION MANTENIMIENTO", Status: domain.BankMovementPending}
+
+	status := domain.BankMovementPending
+	m.bankRepo.On("ListMovements", ctx, repository.BankMovementFilters{Status: &status}).Return([]*domain.BankMovement{partial, unknown, fee}, nil)
+	m.invoiceRepo.On("GetOutstandingInvoices", ctx, repository.OutstandingInvoiceFilters{}).Return([]*domain.Invoice{invoice, other}, nil)
+	m.bankRepo.On("ListUnreconciledExpenses", ctx, utcDay(2024, 11, 10), utcDay(2025, 4, 30)).Return([]*domain.Expense{bankTestExpense()}, nil)
+
+	items, err := svc.ListQueue(ctx)
+
+	require.NoError(t, err)
+	require.Len(t, items, 3)
+	require.Len(t, items[0].Suggestions, 1, "a partial payment only matches the invoice it names")
+	assert.Equal(t, invoice.ID, items[0].Suggestions[0].ID)
+	assert.Equal(t, []string{BankMatchReasonNumber}, items[0].Suggestions[0].Reasons)
+	assert.Empty(t, items[1].Suggestions)
+	assert.Empty(t, items[2].Suggestions)
+}
+
+func TestBankReconciliationService_ConfirmMatch(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	incoming := func() *domain.BankMovement {
+		return &domain.BankMovement{ID: uuid.New(), BookingDate: utcDay(2025, 3, 15), Amount: money.MustParse("100.00"), Concept: "TRANSFERENCIA", Status: domain.BankMovementPending}
+	}
+	outgoing := func() *domain.BankMovement {
+		return &domain.BankMovement{ID: uuid.New(), BookingDate: utcDay(2025, 3, 18), Amount: money.MustParse("-121.00"), Status: domain.BankMovementPending}
+	}
+
+	t.Run("Records the payment of the invoice", func(t *testing.T) {
+		svc, m := newBankTestService()
+		invoice := bankTestInvoice(t)
+		movement := incoming()
+		m.bankRepo.On("GetMovement", ctx, movement.ID).Return(movement, nil)
+		m.invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+		m.bankRepo.On("ReconcileInvoice", ctx, movement, mock.Anything).Return(invoice, nil)
+
+		result, err := svc.ConfirmMatch(ctx, movement.ID, &ConfirmBankMatchRequest{InvoiceID: &invoice.ID}, userID)
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.BankMovementReconciled, movement.Status)
+		assert.False(t, movement.AutoMatched)
+		assert.Equal(t, &userID, movement.ReconciledBy)
+		assert.Equal(t, money.MustParse("100.00"), result.Payment.Amount)
+		assert.Equal(t, "TRANSFERENCIA", result.Payment.Reference)
+		assert.Equal(t, &userID, result.Payment.RecordedBy)
+	})
+
+	t.Run("Links the expense", func(t *testing.T) {
+		svc, m := newBankTestService()
+		expense := bankTestExpense()
+		movement := outgoing()
+		m.bankRepo.On("GetMovement", ctx, movement.ID).Return(movement, nil)
+		m.expenseRepo.On("GetByID", ctx, expense.ID).Return(expense, nil)
+		m.bankRepo.On("ReconcileExpense", ctx, movement).Return(nil)
+
+		result, err := svc.ConfirmMatch(ctx, movement.ID, &ConfirmBankMatchRequest{ExpenseID: &expense.ID}, userID)
+
+		require.NoError(t, err)
+		assert.Equal(t, &expense.ID, result.Movement.ExpenseID)
+		assert.Nil(t, result.Payment)
+	})
+
+	t.Run("Rejected matches", func(t *testing.T) {
+		svc, m := newBankTestService()
+		invoice := bankTestInvoice(t)
+		expense := bankTestExpense()
+		expense.Amount = money.MustParse("120.00")
+		in, out := incoming(), outgoing()
+		done := incoming()
+		done.Status = domain.BankMovementIgnored
+		early := incoming()
+		early.BookingDate = utcDay(2025, 2, 27)
+
+		m.bankRepo.On("GetMovement", ctx, in.ID).Return(in, nil)
+		m.bankRepo.On("GetMovement", ctx, out.ID).Return(out, nil)
+		m.bankRepo.On("GetMovement", ctx, done.ID).Return(done, nil)
+		m.bankRepo.On("GetMovement", ctx, early.ID).Return(early, nil)
+		m.invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+		m.expenseRepo.On("GetByID", ctx, expense.ID).Return(expense, nil)
+
+		_, err := svc.ConfirmMatch(ctx, in.ID, &ConfirmBankMatchRequest{InvoiceID: &invoice.ID, ExpenseID: &expense.ID}, userID)
+		requireValidationError(t, err)
+
+		_, err = svc.ConfirmMatch(ctx, in.ID, &ConfirmBankMatchRequest{ExpenseID: &expense.ID}, userID)
+		assert.Equal(t, domain.ErrMovementDirection, err)
+
+		_, err = svc.ConfirmMatch(ctx, out.ID, &ConfirmBankMatchRequest{InvoiceID: &invoice.ID}, userID)
+		assert.Equal(t, domain.ErrMovementDirection, err)
+
+		_, err = svc.ConfirmMatch(ctx, out.ID, &ConfirmBankMatchRequest{ExpenseID: &expense.ID}, userID)
+		assert.Equal(t, domain.ErrMovementAmountMismatch, err)
+
+		_, err = svc.ConfirmMatch(ctx, done.ID, &ConfirmBankMatchRequest{InvoiceID: &invoice.ID}, userID)
+		assert.Equal(t, domain.ErrMovementNotPending, err)
+
+		_, err = svc.ConfirmMatch(ctx, early.ID, &ConfirmBankMatchRequest{InvoiceID: &invoice.ID}, userID)
+		requireValidationError(t, err)
+
+		m.bankRepo.AssertNotCalled(t, "ReconcileInvoice", mock.Anything, mock.Anything, mock.Anything)
+		m.bankRepo.AssertNotCalled(t, "ReconcileExpense", mock.Anything, mock.Anything)
+	})
+}
+
+func TestBankReconciliationService_IgnoreMovement(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newBankTestService()
+	userID := uuid.New()
+	movement := &domain.BankMovement{ID: uuid.New(), Amount: money.MustParse("-3.50"), Status: domain.BankMovementPending}
+	m.bankRepo.On("GetMovement", ctx, movement.ID).Return(movement, nil)
+	m.bankRepo.On("IgnoreMovement", ctx, movement).Return(nil)
+
+	result, err := svc.IgnoreMovement(ctx, movement.ID, userID)
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.BankMovementIgnored, result.Status)
+	assert.Equal(t, &userID, result.ReconciledBy)
+
+	_, err = svc.IgnoreMovement(ctx, movement.ID, userID)
+	assert.Equal(t, domain.ErrMovementNotPending, err)
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+)
+
+// parseBankStatement reads a Norma 43 or camt.053 file, told apart by its first character,
+// into a statement with its movements; identifiers and statuses are left to the caller
+func parseBankStatement(content []byte) (*domain.BankStatement, error) {
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("empty file")
+	}
+
+	var statement *domain.BankStatement
+	var err error
+	if trimmed[0] == '<' {
+		statement, err = parseCAMT053(trimmed)
+	} else {
+		statement, err = parseNorma43(trimmed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(statement.Movements) == 0 {
+		return nil, fmt.Errorf("statement has no booked movements")
+	}
+
+	// Statements without a period cover the dates of their movements
+	for _, movement := range statement.Movements {
+		if statement.FromDate.IsZero() || movement.BookingDate.Before(statement.FromDate) {
+			statement.FromDate = movement.BookingDate
+		}
+		if statement.ToDate.IsZero() || movement.BookingDate.After(statement.ToDate) {
+			statement.ToDate = movement.BookingDate
+		}
+	}
+	statement.MovementCount = len(statement.Movements)
+
+	return statement, nil
+}
+
+// Norma 43 (AEB Cuaderno 43) records are 80 characters long, identified by their first two
+const (
+	norma43AccountHeader = "11"
+	norma43Movement      = "22"
+	norma43Concept       = "23"
+	norma43RecordLength  = 80
+	norma43DateLayout    = "060102"
+	norma43Debit         = '1' // Debe: money paid
+)
+
+// parseNorma43 reads the movements of every account of a Norma 43 file. The first account
+// and the widest period are kept for the statement; concept records are appended to the
+// concept of their movement.
+func parseNorma43(content []byte) (*domain.BankStatement, error) {
+	statement := &domain.BankStatement{Format: domain.BankStatementNorma43}
+	var current *domain.BankMovement
+	accounts := 0
+
+	for number, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if len(line) < norma43RecordLength {
+			line = append(line, bytes.Repeat([]byte(" "), norma43RecordLength-len(line))...)
+		}
+
+		switch string(line[0:2]) {
+		case norma43AccountHeader:
+			from, errFrom := time.Parse(norma43DateLayout, string(line[20:26]))
+			to, errTo := time.Parse(norma43DateLayout, string(line[26:32]))
+			if errFrom != nil || errTo != nil {
+				return nil, fmt.Errorf("line %d: invalid account period", number+1)
+			}
+			if accounts == 0 {
+				statement.Account = fmt.Sprintf("%s%s%s", line[2:6], line[6:10], line[10:20])
+			}
+			if statement.FromDate.IsZero() || from.Before(statement.FromDate) {
+				statement.FromDate = from
+			}
+			if to.After(statement.ToDate) {
+				statement.ToDate = to
+			}
+			accounts++
+
+		case norma43Movement:
+			if accounts == 0 {
+				return nil, fmt.Errorf("line %d: movement outside an account", number+1)
+			}
+			movement, err := parseNorma43Movement(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", number+1, err)
+			}
+			statement.Movements = append(statement.Movements, movement)
+			current = movement
+
+		case norma43Concept:
+			if current == nil {
+				return nil, fmt.Errorf("line %d: concept without a movement", number+1)
+			}
+			concept := joinText(latin1Text(line[4:42]), latin1Text(line[42:80]))
+			current.Concept = joinText(current.Concept, concept)
+
+		default:
+			// Currency equivalences (24), account totals (33) and end of file (88)
+			if !isDigits(string(line[0:2])) {
+				return nil, fmt.Errorf("line %d: not a Norma 43 record", number+1)
+			}
+		}
+	}
+
+	if accounts == 0 {
+		return nil, fmt.Errorf("no account header record")
+	}
+	return statement, nil
+}
+
+// parseNorma43Movement reads a main movement record (22)
+func parseNorma43Movement(line []byte) (*domain.BankMovement, error) {
+	bookingDate, err := time.Parse(norma43DateLayout, string(line[10:16]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid operation date")
+	}
+	valueDate, err := time.Parse(norma43DateLayout, string(line[16:22]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value date")
+	}
+	cents, err := strconv.ParseInt(string(line[28:42]), 10, 64)
+	if err != nil || cents == 0 {
+		return nil, fmt.Errorf("invalid amount")
+	}
+
+	amount := money.FromCents(cents)
+	if line[27] == norma43Debit {
+		amount = amount.Neg()
+	}
+
+	return &domain.BankMovement{
+		BookingDate: bookingDate,
+		ValueDate:   valueDate,
+		Amount:      amount,
+		Reference:   joinText(latin1Text(line[52:64]), latin1Text(line[64:80])),
+	}, nil
+}
+
+// latin1Text decodes a field of a file that is usually ISO-8859-1, unless it is valid UTF-8
+func latin1Text(field []byte) string {
+	if utf8.Valid(field) {
+		return strings.TrimSpace(string(field))
+	}
+	runes := make([]rune, len(field))
+	for i, b := range field {
+		runes[i] = rune(b)
+	}
+	return strings.TrimSpace(string(runes))
+}
+
+// joinText joins the non-empty texts with a space
+func joinText(texts ...string) string {
+	var parts []string
+	for _, text := range texts {
+		if text = strings.Join(strings.Fields(text), " "); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// camt053Document is an ISO 20022 bank to customer statement (camt.053). Only the elements
+// used for reconciliation are read; they have the same path in versions 02 to 08.
+type camt053Document struct {
+	XMLName    xml.Name           `xml:"Document"`
+	Statements []camt053Statement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camt053Statement struct {
+	IBAN         string         `xml:"Acct>Id>IBAN"`
+	OtherAccount string         `xml:"Acct>Id>Othr>Id"`
+	FromDateTime string         `xml:"FrToDt>FrDtTm"`
+	ToDateTime   string         `xml:"FrToDt>ToDtTm"`
+	Entries      []camt053Entry `xml:"Ntry"`
+}
+
+type camt053Entry struct {
+	Amount          string               `xml:"Amt"`
+	Indicator       string               `xml:"CdtDbtInd"` // CRDT or DBIT
+	Status          camt053Status        `xml:"Sts"`
+	BookingDate     string               `xml:"BookgDt>Dt"`
+	BookingDateTime string               `xml:"BookgDt>DtTm"`
+	ValueDate       string               `xml:"ValDt>Dt"`
+	ServicerRef     string               `xml:"AcctSvcrRef"`
+	AdditionalInfo  string               `xml:"AddtlNtryInf"`
+	Transactions    []camt053Transaction `xml:"NtryDtls>TxDtls"`
+}
+
+// camt053Status is BOOK for booked entries: the text of the element up to version 07, a
+// code element from version 08
+type camt053Status struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+type camt053Transaction struct {
+	EndToEndID   string   `xml:"Refs>EndToEndId"`
+	DebtorName   string   `xml:"RltdPties>Dbtr>Nm"`
+	CreditorName string   `xml:"RltdPties>Cdtr>Nm"`
+	Unstructured []string `xml:"RmtInf>Ustrd"`
+	CreditorRefs []string `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+}
+
+// parseCAMT053 reads the booked entries of every statement of a camt.053 file
+func parseCAMT053(content []byte) (*domain.BankStatement, error) {
+	var document camt053Document
+	if err := xml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("invalid XML: %w", err)
+	}
+	if len(document.Statements) == 0 {
+		return nil, fmt.Errorf("not a camt.053 statement")
+	}
+
+	statement := &domain.BankStatement{Format: domain.BankStatementCAMT053}
+	for i, stmt := range document.Statements {
+		if i == 0 {
+			statement.Account = stmt.IBAN
+			if statement.Account == "" {
+				statement.Account = stmt.OtherAccount
+			}
+		}
+		if from, ok := parseISODate(stmt.FromDateTime); ok && (statement.FromDate.IsZero() || from.Before(statement.FromDate)) {
+			statement.FromDate = from
+		}
+		if to, ok := parseISODate(stmt.ToDateTime); ok && to.After(statement.ToDate) {
+			statement.ToDate = to
+		}
+
+		for j, entry := range stmt.Entries {
+			status := strings.TrimSpace(entry.Status.Text)
+			if code := strings.TrimSpace(entry.Status.Code); code != "" {
+				status = code
+			}
+			if status != "" && status != "BOOK" {
+				continue
+			}
+
+			movement, err := parseCAMT053Entry(entry)
+			if err != nil {
+				return nil, fmt.Errorf("statement %d, entry %d: %w", i+1, j+1, err)
+			}
+			statement.Movements = append(statement.Movements, movement)
+		}
+	}
+
+	return statement, nil
+}
+
+// parseCAMT053Entry maps an entry; the counterparty and remittance information come from
+// its transaction details
+func parseCAMT053Entry(entry camt053Entry) (*domain.BankMovement, error) {
+	amount, err := money.Parse(strings.TrimSpace(entry.Amount))
+	if err != nil || !amount.IsPositive() {
+		return nil, fmt.Errorf("invalid amount")
+	}
+	if strings.TrimSpace(entry.Indicator) == "DBIT" {
+		amount = amount.Neg()
+	}
+
+	bookingDate, ok := parseISODate(entry.BookingDate)
+	if !ok {
+		if bookingDate, ok = parseISODate(entry.BookingDateTime); !ok {
+			return nil, fmt.Errorf("invalid booking date")
+		}
+	}
+	valueDate, ok := parseISODate(entry.ValueDate)
+	if !ok {
+		valueDate = bookingDate
+	}
+
+	movement := &domain.BankMovement{
+		BookingDate: bookingDate,
+		ValueDate:   valueDate,
+		Amount:      amount,
+	}
+
+	var concepts, references, parties []string
+	for _, tx := range entry.Transactions {
+		concepts = append(concepts, tx.Unstructured...)
+		references = append(references, tx.CreditorRefs...)
+		if id := strings.TrimSpace(tx.EndToEndID); id != "" && id != "NOTPROVIDED" {
+			references = append(references, id)
+		}
+		if amount.IsPositive() {
+			parties = append(parties, tx.DebtorName)
+		} else {
+			parties = append(parties, tx.CreditorName)
+		}
+	}
+	if len(references) == 0 {
+		references = append(references, entry.ServicerRef)
+	}
+
+	movement.Concept = joinText(append(concepts, entry.AdditionalInfo)...)
+	movement.Reference = truncateRunes(joinText(references...), 140)
+	movement.Counterparty = truncateRunes(joinText(parties...), 140)
+
+	return movement, nil
+}
+
+// parseISODate reads the date of an ISO 8601 date or date and time
+func parseISODate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 10 {
+		return time.Time{}, false
+	}
+	date, err := time.Parse("2006-01-02", value[:10])
+	return date, err == nil
+}
+
+// isDigits reports whether s is made of ASCII digits only
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
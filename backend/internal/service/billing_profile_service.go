@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// CreateBillingProfileRequest represents the request to create a billing profile
+type CreateBillingProfileRequest struct {
+	Name                   string                `json:"name" binding:"required"`  // Fiscal name
+	TaxID                  string                `json:"taxId" binding:"required"` // NIF/CIF
+	Email                  string                `json:"email" binding:"omitempty,email"`
+	Phone                  string                `json:"phone"`
+	AddressStreet          string                `json:"addressStreet"`
+	AddressCity            string                `json:"addressCity"`
+	AddressProvince        string                `json:"addressProvince"`
+	AddressPostalCode      string                `json:"addressPostalCode"`
+	AddressCountry         string                `json:"addressCountry"` // Defaults to ES
+	PreferredPaymentMethod *domain.PaymentMethod `json:"preferredPaymentMethod,omitempty"`
+	Notes                  string                `json:"notes"`
+}
+
+// UpdateBillingProfileRequest represents the request to update a billing profile. Invoices
+// already issued keep the data they were issued with.
+type UpdateBillingProfileRequest struct {
+	CreateBillingProfileRequest
+	IsActive bool `json:"isActive"`
+}
+
+// LinkBillingProfileRequest represents the request to link a billing profile to a client
+type LinkBillingProfileRequest struct {
+	IsDefault bool `json:"isDefault"` // Use it for the new invoices of the client
+}
+
+// BillingProfileService manages the payers of the clients
+type BillingProfileService interface {
+	// CreateBillingProfile creates a new billing profile
+	CreateBillingProfile(ctx context.Context, req *CreateBillingProfileRequest) (*domain.BillingProfile, error)
+
+	// GetBillingProfile retrieves a billing profile by ID
+	GetBillingProfile(ctx context.Context, id uuid.UUID) (*domain.BillingProfile, error)
+
+	// ListBillingProfiles retrieves the billing profiles matching the filters
+	ListBillingProfiles(ctx context.Context, filters repository.BillingProfileFilters) ([]*domain.BillingProfile, error)
+
+	// UpdateBillingProfile updates an existing billing profile
+	UpdateBillingProfile(ctx context.Context, id uuid.UUID, req *UpdateBillingProfileRequest) (*domain.BillingProfile, error)
+
+	// DeleteBillingProfile soft deletes a billing profile and unlinks it from its clients
+	DeleteBillingProfile(ctx context.Context, id uuid.UUID) error
+
+	// ListClientBillingProfiles retrieves the payers of a client, the default one first
+	ListClientBillingProfiles(ctx context.Context, clientID uuid.UUID) ([]*domain.ClientBillingProfile, error)
+
+	// LinkClient makes a billing profile a payer of a client, optionally the default one
+	LinkClient(ctx context.Context, clientID, profileID uuid.UUID, req *LinkBillingProfileRequest) (*domain.ClientBillingProfile, error)
+
+	// UnlinkClient stops a billing profile from paying for a client
+	UnlinkClient(ctx context.Context, clientID, profileID uuid.UUID) error
+}
+
+type billingProfileService struct {
+	profileRepo repository.BillingProfileRepository
+	clientRepo  repository.ClientRepository
+}
+
+// NewBillingProfileService creates a new billing profile service
+func NewBillingProfileService(profileRepo repository.BillingProfileRepository, clientRepo repository.ClientRepository) BillingProfileService {
+	return &billingProfileService{
+		profileRepo: profileRepo,
+		clientRepo:  clientRepo,
+	}
+}
+
+// CreateBillingProfile creates a new billing profile
+func (s *billingProfileService) CreateBillingProfile(ctx context.Context, req *CreateBillingProfileRequest) (*domain.BillingProfile, error) {
+	now := time.Now()
+	profile := &domain.BillingProfile{
+		ID:        uuid.New(),
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	applyBillingProfileRequest(profile, req)
+
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.profileRepo.Create(ctx, profile); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// applyBillingProfileRequest copies the normalized fields of a request to a profile
+func applyBillingProfileRequest(profile *domain.BillingProfile, req *CreateBillingProfileRequest) {
+	profile.Name = strings.TrimSpace(req.Name)
+	profile.TaxID = strings.ToUpper(strings.NewReplacer(" ", "", "-", "", ".", "").Replace(req.TaxID))
+	profile.Email = strings.TrimSpace(req.Email)
+	profile.Phone = strings.TrimSpace(req.Phone)
+	profile.AddressStreet = strings.TrimSpace(req.AddressStreet)
+	profile.AddressCity = strings.TrimSpace(req.AddressCity)
+	profile.AddressProvince = strings.TrimSpace(req.AddressProvince)
+	profile.AddressPostalCode = strings.TrimSpace(req.AddressPostalCode)
+	profile.AddressCountry = strings.ToUpper(strings.TrimSpace(req.AddressCountry))
+	if profile.AddressCountry == "" {
+		profile.AddressCountry = "ES"
+	}
+	profile.PreferredPaymentMethod = req.PreferredPaymentMethod
+	profile.Notes = req.Notes
+}
+
+// GetBillingProfile retrieves a billing profile by ID
+func (s *billingProfileService) GetBillingProfile(ctx context.Context, id uuid.UUID) (*domain.BillingProfile, error) {
+	return s.profileRepo.GetByID(ctx, id)
+}
+
+// ListBillingProfiles retrieves the billing profiles matching the filters
+func (s *billingProfileService) ListBillingProfiles(ctx context.Context, filters repository.BillingProfileFilters) ([]*domain.BillingProfile, error) {
+	return s.profileRepo.List(ctx, filters)
+}
+
+// UpdateBillingProfile updates an existing billing profile
+func (s *billingProfileService) UpdateBillingProfile(ctx context.Context, id uuid.UUID, req *UpdateBillingProfileRequest) (*domain.BillingProfile, error) {
+	profile, err := s.profileRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyBillingProfileRequest(profile, &req.CreateBillingProfileRequest)
+	profile.IsActive = req.IsActive
+	profile.UpdatedAt = time.Now()
+
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.profileRepo.Update(ctx, profile); err != nil {
+		return nil, fmt.Errorf("failed to update billing profile: %w", err)
+	}
+
+	return profile, nil
+}
+
+// DeleteBillingProfile soft deletes a billing profile; its invoices keep their payer data
+func (s *billingProfileService) DeleteBillingProfile(ctx context.Context, id uuid.UUID) error {
+	return s.profileRepo.Delete(ctx, id)
+}
+
+// ListClientBillingProfiles retrieves the payers of a client
+func (s *billingProfileService) ListClientBillingProfiles(ctx context.Context, clientID uuid.UUID) ([]*domain.ClientBillingProfile, error) {
+	if _, err := s.clientRepo.GetByID(ctx, clientID); err != nil {
+		return nil, err
+	}
+	return s.profileRepo.ListByClient(ctx, clientID)
+}
+
+// LinkClient makes a billing profile a payer of a client; linking it again only changes
+// whether it is the default one
+func (s *billingProfileService) LinkClient(ctx context.Context, clientID, profileID uuid.UUID, req *LinkBillingProfileRequest) (*domain.ClientBillingProfile, error) {
+	if _, err := s.clientRepo.GetByID(ctx, clientID); err != nil {
+		return nil, err
+	}
+
+	profile, err := s.profileRepo.GetByID(ctx, profileID)
+	if err != nil {
+		return nil, err
+	}
+	if !profile.IsActive {
+		return nil, domain.ErrBillingProfileInactive
+	}
+
+	link := &domain.ClientBillingProfile{
+		ClientID:         clientID,
+		BillingProfileID: profileID,
+		IsDefault:        req.IsDefault,
+		CreatedAt:        time.Now(),
+		Profile:          profile,
+	}
+	if err := s.profileRepo.LinkClient(ctx, link); err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+// UnlinkClient stops a billing profile from paying for a client; invoices already created
+// keep it as payer
+func (s *billingProfileService) UnlinkClient(ctx context.Context, clientID, profileID uuid.UUID) error {
+	return s.profileRepo.UnlinkClient(ctx, clientID, profileID)
+}
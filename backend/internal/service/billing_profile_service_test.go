@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockBillingProfileRepository is a mock implementation of repository.BillingProfileRepository
+type MockBillingProfileRepository struct {
+	mock.Mock
+}
+
+func (m *MockBillingProfileRepository) Create(ctx context.Context, profile *domain.BillingProfile) error {
+	args := m.Called(ctx, profile)
+	return args.Error(0)
+}
+
+func (m *MockBillingProfileRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.BillingProfile, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BillingProfile), args.Error(1)
+}
+
+func (m *MockBillingProfileRepository) List(ctx context.Context, filters repository.BillingProfileFilters) ([]*domain.BillingProfile, error) {
+	args := m.Called(ctx, filters)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.BillingProfile), args.Error(1)
+}
+
+func (m *MockBillingProfileRepository) Update(ctx context.Context, profile *domain.BillingProfile) error {
+	args := m.Called(ctx, profile)
+	return args.Error(0)
+}
+
+func (m *MockBillingProfileRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockBillingProfileRepository) GetClientLink(ctx context.Context, clientID, profileID uuid.UUID) (*domain.ClientBillingProfile, error) {
+	args := m.Called(ctx, clientID, profileID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ClientBillingProfile), args.Error(1)
+}
+
+func (m *MockBillingProfileRepository) ListByClient(ctx context.Context, clientID uuid.UUID) ([]*domain.ClientBillingProfile, error) {
+	args := m.Called(ctx, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.ClientBillingProfile), args.Error(1)
+}
+
+func (m *MockBillingProfileRepository) GetDefaultForClient(ctx context.Context, clientID uuid.UUID) (*domain.BillingProfile, error) {
+	args := m.Called(ctx, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BillingProfile), args.Error(1)
+}
+
+func (m *MockBillingProfileRepository) LinkClient(ctx context.Context, link *domain.ClientBillingProfile) error {
+	args := m.Called(ctx, link)
+	return args.Error(0)
+}
+
+func (m *MockBillingProfileRepository) UnlinkClient(ctx context.Context, clientID, profileID uuid.UUID) error {
+	args := m.Called(ctx, clientID, profileID)
+	return args.Error(0)
+}
+
+// newNoPayerRepository returns a billing profile repository where no client has a payer
+func newNoPayerRepository() *MockBillingProfileRepository {
+	profileRepo := new(MockBillingProfileRepository)
+	profileRepo.On("GetDefaultForClient", mock.Anything, mock.Anything).
+		Return(nil, errors.NewNotFoundError("client has no default billing profile")).Maybe()
+	return profileRepo
+}
+
+func testBillingProfile() *domain.BillingProfile {
+	return &domain.BillingProfile{
+		ID:                uuid.New(),
+		Name:              "Construcciones Norte SL",
+		TaxID:             "B12345678",
+		Email:             "administracion@norte.es",
+		AddressStreet:     "Calle Mayor 1",
+		AddressCity:       "Madrid",
+		AddressProvince:   "Madrid",
+		AddressPostalCode: "28013",
+		AddressCountry:    "ES",
+		IsActive:          true,
+	}
+}
+
+func TestBillingProfileService_CreateBillingProfile(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("normalizes the tax ID and defaults the country", func(t *testing.T) {
+		profileRepo := new(MockBillingProfileRepository)
+		svc := NewBillingProfileService(profileRepo, new(MockClientRepository))
+		profileRepo.On("Create", ctx, mock.AnythingOfType("*domain.BillingProfile")).Return(nil)
+
+		profile, err := svc.CreateBillingProfile(ctx, &CreateBillingProfileRequest{
+			Name:  "  María López García ",
+			TaxID: "12.345.678-z",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "María López García", profile.Name)
+		assert.Equal(t, "12345678Z", profile.TaxID)
+		assert.Equal(t, "ES", profile.AddressCountry)
+		assert.True(t, profile.IsActive)
+	})
+
+	t.Run("rejects an invalid payment method", func(t *testing.T) {
+		profileRepo := new(MockBillingProfileRepository)
+		svc := NewBillingProfileService(profileRepo, new(MockClientRepository))
+		method := domain.PaymentMethod("cheque")
+
+		_, err := svc.CreateBillingProfile(ctx, &CreateBillingProfileRequest{
+			Name:                   "Construcciones Norte SL",
+			TaxID:                  "B12345678",
+			PreferredPaymentMethod: &method,
+		})
+
+		requireValidationError(t, err)
+		profileRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
+
+func TestBillingProfileService_LinkClient(t *testing.T) {
+	ctx := context.Background()
+	clientID := uuid.New()
+
+	t.Run("links the profile as default payer", func(t *testing.T) {
+		profileRepo := new(MockBillingProfileRepository)
+		clientRepo := new(MockClientRepository)
+		svc := NewBillingProfileService(profileRepo, clientRepo)
+		profile := testBillingProfile()
+
+		clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+		profileRepo.On("GetByID", ctx, profile.ID).Return(profile, nil)
+		profileRepo.On("LinkClient", ctx, mock.AnythingOfType("*domain.ClientBillingProfile")).Return(nil)
+
+		link, err := svc.LinkClient(ctx, clientID, profile.ID, &LinkBillingProfileRequest{IsDefault: true})
+
+		require.NoError(t, err)
+		assert.Equal(t, clientID, link.ClientID)
+		assert.Equal(t, profile.ID, link.BillingProfileID)
+		assert.True(t, link.IsDefault)
+		assert.Same(t, profile, link.Profile)
+	})
+
+	t.Run("refuses an inactive profile", func(t *testing.T) {
+		profileRepo := new(MockBillingProfileRepository)
+		clientRepo := new(MockClientRepository)
+		svc := NewBillingProfileService(profileRepo, clientRepo)
+		profile := testBillingProfile()
+		profile.IsActive = false
+
+		clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+		profileRepo.On("GetByID", ctx, profile.ID).Return(profile, nil)
+
+		_, err := svc.LinkClient(ctx, clientID, profile.ID, &LinkBillingProfileRequest{IsDefault: true})
+
+		assert.Equal(t, domain.ErrBillingProfileInactive, err)
+		profileRepo.AssertNotCalled(t, "LinkClient", mock.Anything, mock.Anything)
+	})
+}
+
+func TestInvoiceService_CreateInvoice_Payer(t *testing.T) {
+	ctx := context.Background()
+	issueDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	minor := func(id uuid.UUID) *domain.Client {
+		return &domain.Client{ID: id, FirstName: "Lucía", LastName: "Pérez", DNICIF: "87654321X"}
+	}
+	request := func(clientID uuid.UUID) *CreateInvoiceRequest {
+		return &CreateInvoiceRequest{
+			ClientID:  clientID,
+			IssueDate: issueDate,
+			DueDate:   issueDate.AddDate(0, 0, 15),
+			Lines:     []InvoiceLineRequest{{Description: "Formación", UnitPrice: pricePtr("100"), VATRate: floatPtr(21)}},
+		}
+	}
+
+	t.Run("default payer is invoiced instead of the client", func(t *testing.T) {
+		svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+		profileRepo := new(MockBillingProfileRepository)
+		svc.profileRepo = profileRepo
+		clientID := uuid.New()
+		payer := testBillingProfile()
+
+		clientRepo.On("GetByID", ctx, clientID).Return(minor(clientID), nil)
+		profileRepo.On("GetDefaultForClient", ctx, clientID).Return(payer, nil)
+		profileRepo.On("GetByID", ctx, payer.ID).Return(payer, nil)
+		invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).Run(issueAs("F_2025_0001")).Return(nil)
+
+		invoice, err := svc.CreateInvoice(ctx, request(clientID))
+
+		require.NoError(t, err)
+		assert.Equal(t, clientID, invoice.ClientID)
+		assert.Equal(t, &payer.ID, invoice.BillingProfileID)
+		assert.Equal(t, "Construcciones Norte SL", invoice.PayerName)
+		assert.Equal(t, "B12345678", invoice.PayerTaxID)
+		assert.Equal(t, "28013", invoice.PayerAddressPostalCode)
+		// The payer is a company, so IRPF is withheld although the client is an individual
+		assert.Equal(t, 15.0, invoice.IRPFRate)
+		assert.Equal(t, "Construcciones Norte SL", invoice.Recipient(minor(clientID)).Name)
+	})
+
+	t.Run("inactive default payer is ignored", func(t *testing.T) {
+		svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+		profileRepo := new(MockBillingProfileRepository)
+		svc.profileRepo = profileRepo
+		clientID := uuid.New()
+		payer := testBillingProfile()
+		payer.IsActive = false
+
+		clientRepo.On("GetByID", ctx, clientID).Return(minor(clientID), nil)
+		profileRepo.On("GetDefaultForClient", ctx, clientID).Return(payer, nil)
+		invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).Run(issueAs("F_2025_0001")).Return(nil)
+
+		invoice, err := svc.CreateInvoice(ctx, request(clientID))
+
+		require.NoError(t, err)
+		assert.False(t, invoice.HasPayer())
+		assert.Equal(t, 0.0, invoice.IRPFRate)
+	})
+
+	t.Run("client can be billed despite a default payer", func(t *testing.T) {
+		svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+		profileRepo := new(MockBillingProfileRepository)
+		svc.profileRepo = profileRepo
+		clientID := uuid.New()
+
+		clientRepo.On("GetByID", ctx, clientID).Return(minor(clientID), nil)
+		invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).Run(issueAs("F_2025_0001")).Return(nil)
+
+		req := request(clientID)
+		req.BillClient = true
+		invoice, err := svc.CreateInvoice(ctx, req)
+
+		require.NoError(t, err)
+		assert.False(t, invoice.HasPayer())
+		assert.Empty(t, invoice.PayerName)
+		profileRepo.AssertNotCalled(t, "GetDefaultForClient", mock.Anything, mock.Anything)
+	})
+
+	t.Run("explicit payer must pay for the client", func(t *testing.T) {
+		svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+		profileRepo := new(MockBillingProfileRepository)
+		svc.profileRepo = profileRepo
+		clientID := uuid.New()
+		profileID := uuid.New()
+
+		clientRepo.On("GetByID", ctx, clientID).Return(minor(clientID), nil)
+		profileRepo.On("GetClientLink", ctx, clientID, profileID).Return(nil, errors.NewNotFoundError("billing profile not linked to the client"))
+
+		req := request(clientID)
+		req.BillingProfileID = &profileID
+		_, err := svc.CreateInvoice(ctx, req)
+
+		assert.Equal(t, domain.ErrBillingProfileNotLinked, err)
+		invoiceRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+	})
+}
+
+func TestInvoiceService_CreateRectifyingInvoice_KeepsPayer(t *testing.T) {
+	svc, invoiceRepo, _, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	original := issuedTestInvoice(t)
+	original.SetPayer(testBillingProfile())
+
+	invoiceRepo.On("GetByID", ctx, original.ID).Return(original, nil)
+	invoiceRepo.On("GetRectifyingInvoices", ctx, original.ID).Return([]*domain.Invoice{}, nil)
+	invoiceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+
+	rectifying, err := svc.CreateRectifyingInvoice(ctx, original.ID, &CreateRectifyingInvoiceRequest{
+		Mode:   domain.RectificationModeCancellation,
+		Reason: "Importe erróneo",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, original.BillingProfileID, rectifying.BillingProfileID)
+	assert.Equal(t, original.PayerName, rectifying.PayerName)
+	assert.Equal(t, original.PayerTaxID, rectifying.PayerTaxID)
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/pdf"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/storage"
+)
+
+// MaxInvoiceLogoBytes is the maximum size of the invoice logo
+const MaxInvoiceLogoBytes int64 = 1 << 20
+
+// invoiceLogoKey is the storage key of the invoice logo
+const invoiceLogoKey = "settings/invoice-logo"
+
+// UpdateBillingSettingsRequest represents the request to update the issuer data and invoice template
+type UpdateBillingSettingsRequest struct {
+	LegalName         string `json:"legalName" binding:"required"`
+	TradeName         string `json:"tradeName"`
+	TaxID             string `json:"taxId" binding:"required"`
+	AddressStreet     string `json:"addressStreet" binding:"required"`
+	AddressCity       string `json:"addressCity" binding:"required"`
+	AddressProvince   string `json:"addressProvince"`
+	AddressPostalCode string `json:"addressPostalCode" binding:"required"`
+	AddressCountry    string `json:"addressCountry"` // Defaults to ES
+	Email             string `json:"email" binding:"omitempty,email"`
+	Phone             string `json:"phone"`
+	Website           string `json:"website"`
+	IBAN              string `json:"iban"`
+	SEPACreditorID    string `json:"sepaCreditorId"` // Required to collect invoices by direct debit
+	RegistryInfo      string `json:"registryInfo"`
+	FooterText        string `json:"footerText"`
+	AccentColor       string `json:"accentColor"` // #rrggbb, defaults to the template color
+
+	AppointmentInvoicing domain.AppointmentInvoicingMode `json:"appointmentInvoicing"` // off, draft or issue; defaults to off
+}
+
+// BillingSettingsService manages the clinic's fiscal data and invoice template
+type BillingSettingsService interface {
+	// GetSettings retrieves the billing settings
+	GetSettings(ctx context.Context) (*domain.BillingSettings, error)
+
+	// UpdateSettings updates the issuer data, footer and accent color
+	UpdateSettings(ctx context.Context, req *UpdateBillingSettingsRequest) (*domain.BillingSettings, error)
+
+	// UploadLogo stores the logo printed on invoices (PNG or JPEG)
+	UploadLogo(ctx context.Context, content io.Reader) (*domain.BillingSettings, error)
+
+	// DeleteLogo removes the invoice logo
+	DeleteLogo(ctx context.Context) (*domain.BillingSettings, error)
+
+	// GetLogo opens the invoice logo; the caller must close the reader
+	GetLogo(ctx context.Context) (io.ReadCloser, string, error)
+}
+
+type billingSettingsService struct {
+	settingsRepo repository.BillingSettingsRepository
+	storage      storage.Storage
+}
+
+// NewBillingSettingsService creates a new billing settings service
+func NewBillingSettingsService(settingsRepo repository.BillingSettingsRepository, store storage.Storage) BillingSettingsService {
+	return &billingSettingsService{
+		settingsRepo: settingsRepo,
+		storage:      store,
+	}
+}
+
+// GetSettings retrieves the billing settings
+func (s *billingSettingsService) GetSettings(ctx context.Context) (*domain.BillingSettings, error) {
+	return s.settingsRepo.Get(ctx)
+}
+
+// UpdateSettings updates the issuer data, footer and accent color
+func (s *billingSettingsService) UpdateSettings(ctx context.Context, req *UpdateBillingSettingsRequest) (*domain.BillingSettings, error) {
+	settings, err := s.settingsRepo.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	settings.LegalName = strings.TrimSpace(req.LegalName)
+	settings.TradeName = strings.TrimSpace(req.TradeName)
+	settings.TaxID = strings.ToUpper(strings.TrimSpace(req.TaxID))
+	settings.AddressStreet = strings.TrimSpace(req.AddressStreet)
+	settings.AddressCity = strings.TrimSpace(req.AddressCity)
+	settings.AddressProvince = strings.TrimSpace(req.AddressProvince)
+	settings.AddressPostalCode = strings.TrimSpace(req.AddressPostalCode)
+	settings.AddressCountry = strings.ToUpper(strings.TrimSpace(req.AddressCountry))
+	settings.Email = strings.TrimSpace(req.Email)
+	settings.Phone = strings.TrimSpace(req.Phone)
+	settings.Website = strings.TrimSpace(req.Website)
+	settings.IBAN = strings.ToUpper(strings.ReplaceAll(req.IBAN, " ", ""))
+	settings.SEPACreditorID = strings.ToUpper(strings.ReplaceAll(req.SEPACreditorID, " ", ""))
+	settings.RegistryInfo = strings.TrimSpace(req.RegistryInfo)
+	settings.FooterText = strings.TrimSpace(req.FooterText)
+	settings.AccentColor = strings.TrimSpace(req.AccentColor)
+	settings.AppointmentInvoicing = req.AppointmentInvoicing
+	settings.UpdatedAt = time.Now()
+
+	if settings.AddressCountry == "" {
+		settings.AddressCountry = "ES"
+	}
+	if settings.AccentColor == "" {
+		settings.AccentColor = domain.DefaultInvoiceAccentColor
+	}
+	if settings.AppointmentInvoicing == "" {
+		settings.AppointmentInvoicing = domain.AppointmentInvoicingOff
+	}
+
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.settingsRepo.Update(ctx, settings); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// UploadLogo stores the logo printed on invoices (PNG or JPEG)
+func (s *billingSettingsService) UploadLogo(ctx context.Context, content io.Reader) (*domain.BillingSettings, error) {
+	settings, err := s.settingsRepo.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(content, MaxInvoiceLogoBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logo: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, errors.NewValidationError("file is empty", nil)
+	}
+	if int64(len(data)) > MaxInvoiceLogoBytes {
+		return nil, errors.NewValidationError(fmt.Sprintf("logo exceeds the maximum size of %d MB", MaxInvoiceLogoBytes>>20), nil)
+	}
+
+	contentType := http.DetectContentType(data)
+	if contentType != "image/png" && contentType != "image/jpeg" {
+		return nil, errors.NewValidationError("file type not allowed", map[string][]string{
+			"file": {"allowed types: PNG, JPEG"},
+		})
+	}
+
+	// Make sure the image can be embedded before accepting it
+	if _, err := pdf.NewImage(data); err != nil {
+		return nil, errors.NewValidationError("logo image could not be read", map[string][]string{
+			"file": {err.Error()},
+		})
+	}
+
+	if err := s.storage.Put(ctx, invoiceLogoKey, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return nil, fmt.Errorf("failed to store logo: %w", err)
+	}
+
+	key := invoiceLogoKey
+	settings.LogoKey = &key
+	settings.LogoContentType = &contentType
+	settings.UpdatedAt = time.Now()
+
+	if err := s.settingsRepo.Update(ctx, settings); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// DeleteLogo removes the invoice logo
+func (s *billingSettingsService) DeleteLogo(ctx context.Context) (*domain.BillingSettings, error) {
+	settings, err := s.settingsRepo.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.HasLogo() {
+		return settings, nil
+	}
+
+	key := *settings.LogoKey
+	settings.LogoKey = nil
+	settings.LogoContentType = nil
+	settings.UpdatedAt = time.Now()
+
+	if err := s.settingsRepo.Update(ctx, settings); err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.Delete(ctx, key); err != nil {
+		log.Printf("[WARN] Failed to delete invoice logo %s: %v", key, err)
+	}
+
+	return settings, nil
+}
+
+// GetLogo opens the invoice logo; the caller must close the reader
+func (s *billingSettingsService) GetLogo(ctx context.Context) (io.ReadCloser, string, error) {
+	settings, err := s.settingsRepo.Get(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if !settings.HasLogo() {
+		return nil, "", errors.NewNotFoundError("invoice logo not found")
+	}
+
+	reader, _, err := s.storage.Get(ctx, *settings.LogoKey)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, "", errors.NewNotFoundError("invoice logo not found")
+		}
+		return nil, "", fmt.Errorf("failed to open logo: %w", err)
+	}
+
+	contentType := "application/octet-stream"
+	if settings.LogoContentType != nil {
+		contentType = *settings.LogoContentType
+	}
+
+	return reader, contentType, nil
+}
@@ -2,46 +2,135 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 )
 
 // DashboardStats represents billing dashboard statistics
 type DashboardStats struct {
-	TotalRevenue       float64           `json:"totalRevenue"`
-	TotalExpenses      float64           `json:"totalExpenses"`
-	Balance            float64           `json:"balance"`
-	UnpaidInvoices     int               `json:"unpaidInvoices"`
-	UnpaidAmount       float64           `json:"unpaidAmount"`
-	RevenueByMonth     []MonthlyRevenue  `json:"revenueByMonth"`
+	TotalRevenue       money.Money       `json:"totalRevenue"`
+	TotalExpenses      money.Money       `json:"totalExpenses"`
+	Balance            money.Money       `json:"balance"`
+	UnpaidInvoices     int               `json:"unpaidInvoices"` // Unpaid and partially paid invoices
+	UnpaidAmount       money.Money       `json:"unpaidAmount"`   // Outstanding balance of those invoices
+	RevenueByMonth     []RevenuePeriod   `json:"revenueByMonth"`
 	ExpensesByCategory []CategoryExpense `json:"expensesByCategory"`
 	RecentInvoices     []InvoiceSummary  `json:"recentInvoices"`
 }
 
-// MonthlyRevenue represents revenue for a specific month
-type MonthlyRevenue struct {
-	Month   string  `json:"month"`
-	Revenue float64 `json:"revenue"`
+// RevenueTotals are the billing figures of a period
+type RevenueTotals struct {
+	Issued    money.Money `json:"issued"`    // Total of the invoices issued, net of rectifications
+	Collected money.Money `json:"collected"` // Payments received, whatever the issue date of their invoice
+	Expenses  money.Money `json:"expenses"`
+	Net       money.Money `json:"net"` // Issued minus expenses
 }
 
+// RevenuePeriod represents the revenue and expenses of a week, month or quarter
+type RevenuePeriod struct {
+	Period string    `json:"period"` // 2025-W09, 2025-03 or 2025-Q1
+	Start  time.Time `json:"start"`
+	RevenueTotals
+	PreviousYear *RevenueTotals `json:"previousYear,omitempty"` // Same period a year earlier, when compared
+}
+
+// RevenueQuery selects the periods of a revenue report
+type RevenueQuery struct {
+	GroupBy     repository.PeriodGranularity // week, month or quarter
+	FromDate    time.Time
+	ToDate      time.Time
+	CompareYear bool // Adds the figures of the same period of the previous year
+}
+
+// maxRevenuePeriods bounds the periods of a report, e.g. about eight years of weeks
+const maxRevenuePeriods = 420
+
 // CategoryExpense represents expenses grouped by category
 type CategoryExpense struct {
-	CategoryID   uuid.UUID `json:"categoryId"`
-	CategoryName string    `json:"categoryName"`
-	Total        float64   `json:"total"`
-	Percentage   float64   `json:"percentage"`
+	CategoryID   uuid.UUID   `json:"categoryId"`
+	CategoryName string      `json:"categoryName"`
+	Total        money.Money `json:"total"`
+	Percentage   float64     `json:"percentage"`
+}
+
+// AgingBuckets splits outstanding balances by how many days past their due date they are
+type AgingBuckets struct {
+	Current    money.Money `json:"current"` // Not due yet
+	Days1To30  money.Money `json:"days1To30"`
+	Days31To60 money.Money `json:"days31To60"`
+	Days61To90 money.Money `json:"days61To90"`
+	Over90     money.Money `json:"over90"`
+	Total      money.Money `json:"total"`
+}
+
+// add adds an outstanding balance to the bucket of its days past due
+func (b *AgingBuckets) add(amount money.Money, daysOverdue int) error {
+	bucket := &b.Over90
+	switch {
+	case daysOverdue == 0:
+		bucket = &b.Current
+	case daysOverdue <= 30:
+		bucket = &b.Days1To30
+	case daysOverdue <= 60:
+		bucket = &b.Days31To60
+	case daysOverdue <= 90:
+		bucket = &b.Days61To90
+	}
+
+	var err error
+	if *bucket, err = bucket.Add(amount); err != nil {
+		return err
+	}
+	b.Total, err = b.Total.Add(amount)
+	return err
+}
+
+// AgingInvoice is an invoice with an outstanding balance in the aging report
+type AgingInvoice struct {
+	InvoiceID     uuid.UUID   `json:"invoiceId"`
+	InvoiceNumber string      `json:"invoiceNumber"`
+	IssueDate     time.Time   `json:"issueDate"`
+	DueDate       time.Time   `json:"dueDate"`
+	DaysOverdue   int         `json:"daysOverdue"`
+	Outstanding   money.Money `json:"outstanding"`
+}
+
+// ClientAging is the aging of the outstanding balance of a client
+type ClientAging struct {
+	ClientID   uuid.UUID `json:"clientId"`
+	ClientName string    `json:"clientName"`
+	TaxID      string    `json:"taxId,omitempty"`
+	AgingBuckets
+	Invoices []AgingInvoice `json:"invoices"`
+}
+
+// AgingReport is the accounts receivable aging on a day, clients owing the most first
+type AgingReport struct {
+	AsOf    time.Time     `json:"asOf"`
+	Clients []ClientAging `json:"clients"`
+	Totals  AgingBuckets  `json:"totals"`
+}
+
+// AgingQuery selects the invoices of an aging report
+type AgingQuery struct {
+	AsOf    time.Time // Day the balances and days past due are taken on; today when zero
+	Filters repository.OutstandingInvoiceFilters
 }
 
 // InvoiceSummary represents a summarized invoice
 type InvoiceSummary struct {
-	ID            uuid.UUID `json:"id"`
-	InvoiceNumber string    `json:"invoiceNumber"`
-	ClientID      uuid.UUID `json:"clientId"`
-	TotalAmount   float64   `json:"totalAmount"`
-	Status        string    `json:"status"`
-	IssueDate     time.Time `json:"issueDate"`
+	ID            uuid.UUID   `json:"id"`
+	InvoiceNumber string      `json:"invoiceNumber"`
+	ClientID      uuid.UUID   `json:"clientId"`
+	TotalAmount   money.Money `json:"totalAmount"`
+	Status        string      `json:"status"`
+	IssueDate     time.Time   `json:"issueDate"`
 }
 
 // BillingStatsService handles billing statistics and dashboard data
@@ -49,32 +138,38 @@ type BillingStatsService interface {
 	// GetDashboardStats retrieves comprehensive dashboard statistics
 	GetDashboardStats(ctx context.Context, fromDate, toDate time.Time) (*DashboardStats, error)
 
-	// GetRevenueByMonth calculates revenue grouped by month
-	GetRevenueByMonth(ctx context.Context, fromDate, toDate time.Time) ([]MonthlyRevenue, error)
+	// GetRevenueByPeriod aggregates revenue and expenses by week, month or quarter. Every
+	// period of the range is returned, with zeros when nothing was invoiced or spent.
+	GetRevenueByPeriod(ctx context.Context, query RevenueQuery) ([]RevenuePeriod, error)
 
 	// GetExpensesByCategory calculates expenses grouped by category
 	GetExpensesByCategory(ctx context.Context, fromDate, toDate time.Time) ([]CategoryExpense, error)
 
 	// GetBalance calculates the balance (revenue - expenses) for a period
-	GetBalance(ctx context.Context, fromDate, toDate time.Time) (float64, error)
+	GetBalance(ctx context.Context, fromDate, toDate time.Time) (money.Money, error)
+
+	// GetAgingReport buckets the outstanding balance of each client by days past due
+	GetAgingReport(ctx context.Context, query AgingQuery) (*AgingReport, error)
+
+	// ExportAgingReportCSV exports the aging report as CSV, a row per client
+	ExportAgingReportCSV(ctx context.Context, query AgingQuery) (*CSVExport, error)
 }
 
 type billingStatsService struct {
-	invoiceRepo  repository.InvoiceRepository
-	expenseRepo  repository.ExpenseRepository
-	categoryRepo repository.ExpenseCategoryRepository
+	invoiceRepo repository.InvoiceRepository
+	expenseRepo repository.ExpenseRepository
+	now         func() time.Time
 }
 
 // NewBillingStatsService creates a new billing stats service
 func NewBillingStatsService(
 	invoiceRepo repository.InvoiceRepository,
 	expenseRepo repository.ExpenseRepository,
-	categoryRepo repository.ExpenseCategoryRepository,
 ) BillingStatsService {
 	return &billingStatsService{
-		invoiceRepo:  invoiceRepo,
-		expenseRepo:  expenseRepo,
-		categoryRepo: categoryRepo,
+		invoiceRepo: invoiceRepo,
+		expenseRepo: expenseRepo,
+		now:         time.Now,
 	}
 }
 
@@ -93,22 +188,35 @@ func (s *billingStatsService) GetDashboardStats(ctx context.Context, fromDate, t
 	}
 
 	// Calculate balance
-	balance := totalRevenue - totalExpenses
+	balance, err := totalRevenue.Sub(totalExpenses)
+	if err != nil {
+		return nil, err
+	}
 
-	// Get unpaid invoices
+	// Get unpaid and partially paid invoices; only their outstanding balance is owed
 	unpaidInvoices, err := s.invoiceRepo.GetUnpaidInvoices(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	unpaidCount := len(unpaidInvoices)
-	unpaidAmount := 0.0
+	unpaidAmount := money.Money{}
 	for _, invoice := range unpaidInvoices {
-		unpaidAmount += invoice.TotalAmount
+		outstanding, err := invoice.OutstandingAmount()
+		if err != nil {
+			return nil, err
+		}
+		if unpaidAmount, err = unpaidAmount.Add(outstanding); err != nil {
+			return nil, err
+		}
 	}
 
-	// Get revenue by month (last 6 months)
-	revenueByMonth, err := s.GetRevenueByMonth(ctx, fromDate, toDate)
+	// Get revenue by month
+	revenueByMonth, err := s.GetRevenueByPeriod(ctx, RevenueQuery{
+		GroupBy:  repository.PeriodMonth,
+		FromDate: fromDate,
+		ToDate:   toDate,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -153,48 +261,141 @@ func (s *billingStatsService) GetDashboardStats(ctx context.Context, fromDate, t
 	}, nil
 }
 
-// GetRevenueByMonth calculates revenue grouped by month
-func (s *billingStatsService) GetRevenueByMonth(ctx context.Context, fromDate, toDate time.Time) ([]MonthlyRevenue, error) {
-	// This is a simplified implementation
-	// For production, you would want to query the database with GROUP BY month
+// GetRevenueByPeriod aggregates revenue and expenses by week, month or quarter
+func (s *billingStatsService) GetRevenueByPeriod(ctx context.Context, query RevenueQuery) ([]RevenuePeriod, error) {
+	granularity := query.GroupBy
+	if !granularity.IsValid() {
+		return nil, errors.NewValidationError("invalid grouping", map[string][]string{
+			"groupBy": {"must be week, month or quarter"},
+		})
+	}
+	if query.ToDate.Before(query.FromDate) {
+		return nil, errors.NewValidationError("invalid date range", map[string][]string{
+			"toDate": {"must not be before fromDate"},
+		})
+	}
+
+	var starts []time.Time
+	for start := granularity.Truncate(query.FromDate); !start.After(query.ToDate); start = granularity.Next(start) {
+		if len(starts) == maxRevenuePeriods {
+			return nil, errors.NewValidationError("date range too long", map[string][]string{
+				"fromDate": {fmt.Sprintf("at most %d periods can be returned", maxRevenuePeriods)},
+			})
+		}
+		starts = append(starts, start)
+	}
+
+	current, err := s.revenueTotals(ctx, granularity, query.FromDate, query.ToDate)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous periodTotals
+	if query.CompareYear {
+		previous, err = s.revenueTotals(ctx, granularity, query.FromDate.AddDate(-1, 0, 0), query.ToDate.AddDate(-1, 0, 0))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	periods := make([]RevenuePeriod, 0, len(starts))
+	for _, start := range starts {
+		period := RevenuePeriod{
+			Period:        granularity.Label(start),
+			Start:         start,
+			RevenueTotals: current.get(start),
+		}
+		if previous != nil {
+			totals := previous.get(granularity.Truncate(start.AddDate(-1, 0, 0)))
+			period.PreviousYear = &totals
+		}
+		periods = append(periods, period)
+	}
+
+	return periods, nil
+}
+
+// periodTotals holds the figures of each period by its first day
+type periodTotals map[string]*RevenueTotals
+
+func periodKey(start time.Time) string {
+	return start.Format("2006-01-02")
+}
+
+// get returns the figures of the period starting on start; zero when there were none
+func (p periodTotals) get(start time.Time) RevenueTotals {
+	if totals, ok := p[periodKey(start)]; ok {
+		return *totals
+	}
+	return RevenueTotals{}
+}
+
+// at returns the figures of the period starting on start, adding them when missing
+func (p periodTotals) at(start time.Time) *RevenueTotals {
+	key := periodKey(start)
+	if _, ok := p[key]; !ok {
+		p[key] = &RevenueTotals{}
+	}
+	return p[key]
+}
+
+// revenueTotals aggregates the issued and collected revenue and the expenses by period
+func (s *billingStatsService) revenueTotals(ctx context.Context, granularity repository.PeriodGranularity, fromDate, toDate time.Time) (periodTotals, error) {
+	issued, err := s.invoiceRepo.GetIssuedByPeriod(ctx, granularity, fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+	collected, err := s.invoiceRepo.GetCollectedByPeriod(ctx, granularity, fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+	expenses, err := s.expenseRepo.GetTotalByPeriod(ctx, granularity, fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
 
-	// For now, return a basic structure
-	// TODO: Implement proper monthly aggregation in repository
-	return []MonthlyRevenue{}, nil
+	totals := periodTotals{}
+	for _, row := range issued {
+		totals.at(row.Start).Issued = row.Total
+	}
+	for _, row := range collected {
+		totals.at(row.Start).Collected = row.Total
+	}
+	for _, row := range expenses {
+		totals.at(row.Start).Expenses = row.Total
+	}
+	for _, period := range totals {
+		if period.Net, err = period.Issued.Sub(period.Expenses); err != nil {
+			return nil, err
+		}
+	}
+
+	return totals, nil
 }
 
 // GetExpensesByCategory calculates expenses grouped by category
 func (s *billingStatsService) GetExpensesByCategory(ctx context.Context, fromDate, toDate time.Time) ([]CategoryExpense, error) {
-	// Get total expenses by category
+	// Totals come with their category names, largest first
 	totals, err := s.expenseRepo.GetTotalByCategory(ctx, fromDate, toDate)
 	if err != nil {
 		return nil, err
 	}
 
 	// Calculate grand total
-	grandTotal := 0.0
+	grandTotal := money.Money{}
 	for _, total := range totals {
-		grandTotal += total
+		if grandTotal, err = grandTotal.Add(total.Total); err != nil {
+			return nil, err
+		}
 	}
 
-	// Build result with category names and percentages
 	result := make([]CategoryExpense, 0, len(totals))
-	for categoryID, total := range totals {
-		category, err := s.categoryRepo.GetByID(ctx, categoryID)
-		if err != nil {
-			continue // Skip if category not found
-		}
-
-		percentage := 0.0
-		if grandTotal > 0 {
-			percentage = (total / grandTotal) * 100
-		}
-
+	for _, total := range totals {
 		result = append(result, CategoryExpense{
-			CategoryID:   categoryID,
-			CategoryName: category.Name,
-			Total:        total,
-			Percentage:   percentage,
+			CategoryID:   total.CategoryID,
+			CategoryName: total.CategoryName,
+			Total:        total.Total,
+			Percentage:   total.Total.Ratio(grandTotal) * 100,
 		})
 	}
 
@@ -202,16 +403,117 @@ func (s *billingStatsService) GetExpensesByCategory(ctx context.Context, fromDat
 }
 
 // GetBalance calculates the balance (revenue - expenses) for a period
-func (s *billingStatsService) GetBalance(ctx context.Context, fromDate, toDate time.Time) (float64, error) {
+func (s *billingStatsService) GetBalance(ctx context.Context, fromDate, toDate time.Time) (money.Money, error) {
 	revenue, err := s.invoiceRepo.GetTotalRevenueByDateRange(ctx, fromDate, toDate)
 	if err != nil {
-		return 0, err
+		return money.Money{}, err
 	}
 
 	expenses, err := s.expenseRepo.GetTotalByDateRange(ctx, fromDate, toDate)
 	if err != nil {
-		return 0, err
+		return money.Money{}, err
+	}
+
+	return revenue.Sub(expenses)
+}
+
+// GetAgingReport buckets the outstanding balance of each client by days past due. An invoice
+// counts with its whole outstanding balance when any of its lines matches the filters.
+func (s *billingStatsService) GetAgingReport(ctx context.Context, query AgingQuery) (*AgingReport, error) {
+	asOf := query.AsOf
+	if asOf.IsZero() {
+		asOf = s.now()
+	}
+	asOf = time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Balances on the day: invoices issued later and payments made later are left out
+	filters := query.Filters
+	filters.AsOf = &asOf
+	invoices, err := s.invoiceRepo.GetOutstandingInvoices(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AgingReport{AsOf: asOf, Clients: []ClientAging{}}
+	byClient := map[uuid.UUID]*ClientAging{}
+	var order []uuid.UUID
+	for _, invoice := range invoices {
+		outstanding, err := invoice.OutstandingAmount()
+		if err != nil {
+			return nil, err
+		}
+		if outstanding.IsZero() {
+			continue
+		}
+
+		// Settlement invoices of an insurer have no client and are grouped by their payer
+		key := invoice.ClientID
+		if !invoice.HasClient() && invoice.HasPayer() {
+			key = *invoice.BillingProfileID
+		}
+		client, ok := byClient[key]
+		if !ok {
+			client = &ClientAging{ClientID: invoice.ClientID, Invoices: []AgingInvoice{}}
+			if invoice.Client != nil {
+				client.ClientName = invoice.Client.FullName()
+				client.TaxID = invoice.Client.DNICIF
+			} else if invoice.HasPayer() {
+				payer := invoice.Recipient(nil)
+				client.ClientName = payer.Name
+				client.TaxID = payer.TaxID
+			}
+			byClient[key] = client
+			order = append(order, key)
+		}
+
+		days := invoice.DaysOverdue(asOf)
+		if err := client.add(outstanding, days); err != nil {
+			return nil, err
+		}
+		if err := report.Totals.add(outstanding, days); err != nil {
+			return nil, err
+		}
+		client.Invoices = append(client.Invoices, AgingInvoice{
+			InvoiceID:     invoice.ID,
+			InvoiceNumber: invoice.InvoiceNumber,
+			IssueDate:     invoice.IssueDate,
+			DueDate:       invoice.DueDate,
+			DaysOverdue:   days,
+			Outstanding:   outstanding,
+		})
+	}
+
+	for _, id := range order {
+		report.Clients = append(report.Clients, *byClient[id])
+	}
+	sort.SliceStable(report.Clients, func(i, j int) bool {
+		return report.Clients[i].Total.Cents() > report.Clients[j].Total.Cents()
+	})
+
+	return report, nil
+}
+
+// ExportAgingReportCSV exports the aging report as CSV, a row per client and the totals
+func (s *billingStatsService) ExportAgingReportCSV(ctx context.Context, query AgingQuery) (*CSVExport, error) {
+	report, err := s.GetAgingReport(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	row := func(name, taxID string, buckets AgingBuckets) []string {
+		return []string{
+			name, taxID, buckets.Current.Decimal(), buckets.Days1To30.Decimal(), buckets.Days31To60.Decimal(),
+			buckets.Days61To90.Decimal(), buckets.Over90.Decimal(), buckets.Total.Decimal(),
+		}
+	}
+
+	rows := make([][]string, 0, len(report.Clients)+1)
+	for _, client := range report.Clients {
+		rows = append(rows, row(client.ClientName, client.TaxID, client.AgingBuckets))
 	}
+	rows = append(rows, row("Total", "", report.Totals))
 
-	return revenue - expenses, nil
+	fileName := fmt.Sprintf("antiguedad_saldos_%s.csv", report.AsOf.Format("2006-01-02"))
+	header := []string{"Cliente", "NIF", "No vencido", "1-30 días", "31-60 días", "61-90 días", "Más de 90 días", "Total"}
+	return newCSVExport(fileName, header, rows)
 }
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockExpenseRepository is a mock implementation of ExpenseRepository
+type MockExpenseRepository struct {
+	mock.Mock
+}
+
+func (m *MockExpenseRepository) Create(ctx context.Context, expense *domain.Expense) error {
+	args := m.Called(ctx, expense)
+	return args.Error(0)
+}
+
+func (m *MockExpenseRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Expense, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Expense), args.Error(1)
+}
+
+func (m *MockExpenseRepository) List(ctx context.Context, filters repository.ExpenseFilters) ([]*domain.Expense, int, error) {
+	args := m.Called(ctx, filters)
+	return args.Get(0).([]*domain.Expense), args.Int(1), args.Error(2)
+}
+
+func (m *MockExpenseRepository) Update(ctx context.Context, expense *domain.Expense) error {
+	args := m.Called(ctx, expense)
+	return args.Error(0)
+}
+
+func (m *MockExpenseRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockExpenseRepository) GetByCategory(ctx context.Context, categoryID uuid.UUID) ([]*domain.Expense, error) {
+	args := m.Called(ctx, categoryID)
+	return args.Get(0).([]*domain.Expense), args.Error(1)
+}
+
+func (m *MockExpenseRepository) GetTotalByDateRange(ctx context.Context, fromDate, toDate time.Time) (money.Money, error) {
+	args := m.Called(ctx, fromDate, toDate)
+	return args.Get(0).(money.Money), args.Error(1)
+}
+
+func (m *MockExpenseRepository) GetTotalByCategory(ctx context.Context, fromDate, toDate time.Time) ([]repository.CategoryTotal, error) {
+	args := m.Called(ctx, fromDate, toDate)
+	return args.Get(0).([]repository.CategoryTotal), args.Error(1)
+}
+
+func (m *MockExpenseRepository) GetTotalByPeriod(ctx context.Context, granularity repository.PeriodGranularity, fromDate, toDate time.Time) ([]repository.PeriodTotal, error) {
+	args := m.Called(ctx, granularity, fromDate, toDate)
+	return args.Get(0).([]repository.PeriodTotal), args.Error(1)
+}
+
+func (m *MockExpenseRepository) ListByDateRange(ctx context.Context, fromDate, toDate time.Time) ([]*domain.Expense, error) {
+	args := m.Called(ctx, fromDate, toDate)
+	return args.Get(0).([]*domain.Expense), args.Error(1)
+}
+
+func (m *MockExpenseRepository) GetBySupplier(ctx context.Context, supplier string) ([]*domain.Expense, error) {
+	args := m.Called(ctx, supplier)
+	return args.Get(0).([]*domain.Expense), args.Error(1)
+}
+
+func (m *MockExpenseRepository) MarkReviewed(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockExpenseRepository) UpdateAttachment(ctx context.Context, expense *domain.Expense) error {
+	args := m.Called(ctx, expense)
+	return args.Error(0)
+}
+
+func (m *MockExpenseRepository) ListByAttachmentHash(ctx context.Context, sha256 string) ([]*domain.Expense, error) {
+	args := m.Called(ctx, sha256)
+	return args.Get(0).([]*domain.Expense), args.Error(1)
+}
+
+func newBillingStatsTestService() (BillingStatsService, *MockInvoiceRepository, *MockExpenseRepository) {
+	invoiceRepo := new(MockInvoiceRepository)
+	expenseRepo := new(MockExpenseRepository)
+	return NewBillingStatsService(invoiceRepo, expenseRepo), invoiceRepo, expenseRepo
+}
+
+func utcDay(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func periodTotal(start time.Time, amount string) repository.PeriodTotal {
+	return repository.PeriodTotal{Start: start, Total: money.MustParse(amount)}
+}
+
+func TestBillingStatsService_GetRevenueByPeriod_ZeroFillsMonths(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, expenseRepo := newBillingStatsTestService()
+	from, to := utcDay(2025, 1, 15), utcDay(2025, 4, 10)
+
+	invoiceRepo.On("GetIssuedByPeriod", ctx, repository.PeriodMonth, from, to).Return([]repository.PeriodTotal{
+		periodTotal(utcDay(2025, 1, 1), "1000"),
+		periodTotal(utcDay(2025, 3, 1), "500"),
+	}, nil)
+	invoiceRepo.On("GetCollectedByPeriod", ctx, repository.PeriodMonth, from, to).Return([]repository.PeriodTotal{
+		periodTotal(utcDay(2025, 2, 1), "800"),
+	}, nil)
+	expenseRepo.On("GetTotalByPeriod", ctx, repository.PeriodMonth, from, to).Return([]repository.PeriodTotal{
+		periodTotal(utcDay(2025, 3, 1), "700"),
+	}, nil)
+
+	periods, err := svc.GetRevenueByPeriod(ctx, RevenueQuery{GroupBy: repository.PeriodMonth, FromDate: from, ToDate: to})
+
+	require.NoError(t, err)
+	require.Len(t, periods, 4)
+	assert.Equal(t, []string{"2025-01", "2025-02", "2025-03", "2025-04"},
+		[]string{periods[0].Period, periods[1].Period, periods[2].Period, periods[3].Period})
+
+	assert.Equal(t, "1000.00", periods[0].Issued.Decimal())
+	assert.Equal(t, "1000.00", periods[0].Net.Decimal())
+	assert.Equal(t, "0.00", periods[1].Issued.Decimal())
+	assert.Equal(t, "800.00", periods[1].Collected.Decimal())
+	assert.Equal(t, "-200.00", periods[2].Net.Decimal())
+	assert.True(t, periods[3].Issued.IsZero())
+	assert.Nil(t, periods[0].PreviousYear)
+}
+
+func TestBillingStatsService_GetRevenueByPeriod_WeeksAndQuarters(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, expenseRepo := newBillingStatsTestService()
+	invoiceRepo.On("GetIssuedByPeriod", ctx, mock.Anything, mock.Anything, mock.Anything).Return([]repository.PeriodTotal{}, nil)
+	invoiceRepo.On("GetCollectedByPeriod", ctx, mock.Anything, mock.Anything, mock.Anything).Return([]repository.PeriodTotal{}, nil)
+	expenseRepo.On("GetTotalByPeriod", ctx, mock.Anything, mock.Anything, mock.Anything).Return([]repository.PeriodTotal{}, nil)
+
+	// Wednesday 26 February to Sunday 9 March: weeks start on Monday
+	weeks, err := svc.GetRevenueByPeriod(ctx, RevenueQuery{GroupBy: repository.PeriodWeek, FromDate: utcDay(2025, 2, 26), ToDate: utcDay(2025, 3, 9)})
+	require.NoError(t, err)
+	require.Len(t, weeks, 2)
+	assert.Equal(t, "2025-W09", weeks[0].Period)
+	assert.Equal(t, utcDay(2025, 2, 24), weeks[0].Start)
+	assert.Equal(t, "2025-W10", weeks[1].Period)
+
+	quarters, err := svc.GetRevenueByPeriod(ctx, RevenueQuery{GroupBy: repository.PeriodQuarter, FromDate: utcDay(2024, 11, 5), ToDate: utcDay(2025, 5, 1)})
+	require.NoError(t, err)
+	require.Len(t, quarters, 3)
+	assert.Equal(t, "2024-Q4", quarters[0].Period)
+	assert.Equal(t, utcDay(2024, 10, 1), quarters[0].Start)
+	assert.Equal(t, "2025-Q2", quarters[2].Period)
+}
+
+func TestBillingStatsService_GetRevenueByPeriod_ComparesWithPreviousYear(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, expenseRepo := newBillingStatsTestService()
+	from, to := utcDay(2025, 1, 1), utcDay(2025, 3, 31)
+	lastFrom, lastTo := utcDay(2024, 1, 1), utcDay(2024, 3, 31)
+
+	invoiceRepo.On("GetIssuedByPeriod", ctx, repository.PeriodQuarter, from, to).Return([]repository.PeriodTotal{
+		periodTotal(utcDay(2025, 1, 1), "3000"),
+	}, nil)
+	invoiceRepo.On("GetIssuedByPeriod", ctx, repository.PeriodQuarter, lastFrom, lastTo).Return([]repository.PeriodTotal{
+		periodTotal(utcDay(2024, 1, 1), "2400"),
+	}, nil)
+	invoiceRepo.On("GetCollectedByPeriod", ctx, repository.PeriodQuarter, mock.Anything, mock.Anything).Return([]repository.PeriodTotal{}, nil)
+	expenseRepo.On("GetTotalByPeriod", ctx, repository.PeriodQuarter, lastFrom, lastTo).Return([]repository.PeriodTotal{
+		periodTotal(utcDay(2024, 1, 1), "400"),
+	}, nil)
+	expenseRepo.On("GetTotalByPeriod", ctx, repository.PeriodQuarter, from, to).Return([]repository.PeriodTotal{}, nil)
+
+	periods, err := svc.GetRevenueByPeriod(ctx, RevenueQuery{GroupBy: repository.PeriodQuarter, FromDate: from, ToDate: to, CompareYear: true})
+
+	require.NoError(t, err)
+	require.Len(t, periods, 1)
+	assert.Equal(t, "3000.00", periods[0].Issued.Decimal())
+	require.NotNil(t, periods[0].PreviousYear)
+	assert.Equal(t, "2400.00", periods[0].PreviousYear.Issued.Decimal())
+	assert.Equal(t, "2000.00", periods[0].PreviousYear.Net.Decimal())
+}
+
+func TestBillingStatsService_GetRevenueByPeriod_InvalidQuery(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, _ := newBillingStatsTestService()
+
+	_, err := svc.GetRevenueByPeriod(ctx, RevenueQuery{GroupBy: "day", FromDate: utcDay(2025, 1, 1), ToDate: utcDay(2025, 2, 1)})
+	requireValidationError(t, err)
+
+	_, err = svc.GetRevenueByPeriod(ctx, RevenueQuery{GroupBy: repository.PeriodMonth, FromDate: utcDay(2025, 2, 1), ToDate: utcDay(2025, 1, 1)})
+	requireValidationError(t, err)
+
+	_, err = svc.GetRevenueByPeriod(ctx, RevenueQuery{GroupBy: repository.PeriodWeek, FromDate: utcDay(2000, 1, 1), ToDate: utcDay(2025, 1, 1)})
+	requireValidationError(t, err)
+
+	invoiceRepo.AssertNotCalled(t, "GetIssuedByPeriod", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBillingStatsService_GetExpensesByCategory(t *testing.T) {
+	ctx := context.Background()
+	svc, _, expenseRepo := newBillingStatsTestService()
+	from, to := utcDay(2025, 1, 1), utcDay(2025, 1, 31)
+
+	rent, supplies := uuid.New(), uuid.New()
+	expenseRepo.On("GetTotalByCategory", ctx, from, to).Return([]repository.CategoryTotal{
+		{CategoryID: rent, CategoryName: "Alquiler", Total: money.MustParse("750")},
+		{CategoryID: supplies, CategoryName: "Suministros", Total: money.MustParse("250")},
+	}, nil)
+
+	expenses, err := svc.GetExpensesByCategory(ctx, from, to)
+
+	require.NoError(t, err)
+	require.Len(t, expenses, 2)
+	assert.Equal(t, "Alquiler", expenses[0].CategoryName)
+	assert.InDelta(t, 75.0, expenses[0].Percentage, 0.001)
+	assert.Equal(t, supplies, expenses[1].CategoryID)
+	assert.InDelta(t, 25.0, expenses[1].Percentage, 0.001)
+}
+
+func agingInvoice(client *domain.Client, number string, dueDate time.Time, total, paid string) *domain.Invoice {
+	return &domain.Invoice{
+		ID:            uuid.New(),
+		InvoiceNumber: number,
+		ClientID:      client.ID,
+		IssueDate:     dueDate.AddDate(0, 0, -30),
+		DueDate:       dueDate,
+		TotalAmount:   money.MustParse(total),
+		PaidAmount:    money.MustParse(paid),
+		Status:        domain.InvoiceStatusUnpaid,
+		Client:        client,
+	}
+}
+
+func TestBillingStatsService_GetAgingReport_BucketsByClient(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, _ := newBillingStatsTestService()
+	asOf := utcDay(2025, 6, 30)
+
+	ana := &domain.Client{ID: uuid.New(), FirstName: "Ana", LastName: "López", DNICIF: "12345678Z"}
+	pablo := &domain.Client{ID: uuid.New(), FirstName: "Pablo", LastName: "Ruiz"}
+
+	// The repository returns the balances on that day
+	invoiceRepo.On("GetOutstandingInvoices", ctx, repository.OutstandingInvoiceFilters{AsOf: &asOf}).Return([]*domain.Invoice{
+		agingInvoice(ana, "F_2025_0001", utcDay(2025, 3, 1), "100", "0"),    // 121 days
+		agingInvoice(pablo, "F_2025_0002", utcDay(2025, 4, 15), "300", "0"), // 76 days
+		agingInvoice(ana, "F_2025_0003", utcDay(2025, 5, 31), "80", "30"),   // 30 days, partially paid
+		agingInvoice(pablo, "F_2025_0004", utcDay(2025, 5, 1), "60", "0"),   // 60 days
+		agingInvoice(ana, "F_2025_0005", utcDay(2025, 6, 30), "40", "0"),    // Due today
+		agingInvoice(pablo, "F_2025_0006", utcDay(2025, 7, 15), "25", "0"),  // Not due yet
+	}, nil)
+
+	report, err := svc.GetAgingReport(ctx, AgingQuery{AsOf: asOf})
+
+	require.NoError(t, err)
+	assert.Equal(t, asOf, report.AsOf)
+	require.Len(t, report.Clients, 2)
+
+	// Clients owing the most come first
+	first := report.Clients[0]
+	assert.Equal(t, "Pablo Ruiz", first.ClientName)
+	assert.Equal(t, "25.00", first.Current.Decimal())
+	assert.Equal(t, "60.00", first.Days31To60.Decimal())
+	assert.Equal(t, "300.00", first.Days61To90.Decimal())
+	assert.Equal(t, "385.00", first.Total.Decimal())
+	assert.Len(t, first.Invoices, 3)
+
+	second := report.Clients[1]
+	assert.Equal(t, "12345678Z", second.TaxID)
+	assert.Equal(t, "40.00", second.Current.Decimal())
+	assert.Equal(t, "50.00", second.Days1To30.Decimal())
+	assert.Equal(t, "100.00", second.Over90.Decimal())
+	assert.Equal(t, "190.00", second.Total.Decimal())
+	assert.Equal(t, 121, second.Invoices[0].DaysOverdue)
+
+	assert.Equal(t, "65.00", report.Totals.Current.Decimal())
+	assert.Equal(t, "50.00", report.Totals.Days1To30.Decimal())
+	assert.Equal(t, "60.00", report.Totals.Days31To60.Decimal())
+	assert.Equal(t, "300.00", report.Totals.Days61To90.Decimal())
+	assert.Equal(t, "100.00", report.Totals.Over90.Decimal())
+	assert.Equal(t, "575.00", report.Totals.Total.Decimal())
+}
+
+func TestBillingStatsService_GetAgingReport_PassesFilters(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, _ := newBillingStatsTestService()
+	employeeID, serviceTypeID := uuid.New(), uuid.New()
+	filters := repository.OutstandingInvoiceFilters{EmployeeID: &employeeID, ServiceTypeID: &serviceTypeID}
+	asOf := utcDay(2025, 6, 30)
+
+	withDay := filters
+	withDay.AsOf = &asOf
+	invoiceRepo.On("GetOutstandingInvoices", ctx, withDay).Return([]*domain.Invoice{}, nil)
+
+	// The day is taken from the query, not from the filters
+	report, err := svc.GetAgingReport(ctx, AgingQuery{AsOf: asOf.Add(15 * time.Hour), Filters: filters})
+
+	require.NoError(t, err)
+	assert.NotNil(t, report.Clients)
+	assert.Empty(t, report.Clients)
+	assert.True(t, report.Totals.Total.IsZero())
+	invoiceRepo.AssertExpectations(t)
+}
+
+func TestBillingStatsService_ExportAgingReportCSV(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, _ := newBillingStatsTestService()
+	ana := &domain.Client{ID: uuid.New(), FirstName: "Ana", LastName: "López", DNICIF: "12345678Z"}
+
+	asOf := utcDay(2025, 6, 30)
+	invoiceRepo.On("GetOutstandingInvoices", ctx, repository.OutstandingInvoiceFilters{AsOf: &asOf}).Return([]*domain.Invoice{
+		agingInvoice(ana, "F_2025_0001", utcDay(2025, 6, 10), "121", "0"),
+	}, nil)
+
+	export, err := svc.ExportAgingReportCSV(ctx, AgingQuery{AsOf: asOf})
+
+	require.NoError(t, err)
+	assert.Equal(t, "antiguedad_saldos_2025-06-30.csv", export.FileName)
+	rows := strings.Split(strings.TrimSpace(string(export.Content)), "\n")
+	require.Len(t, rows, 3)
+	assert.Equal(t, "Ana López,12345678Z,0.00,121.00,0.00,0.00,0.00,121.00", rows[1])
+	assert.Equal(t, "Total,,0.00,121.00,0.00,0.00,0.00,121.00", rows[2])
+}
@@ -255,6 +255,17 @@ func (s *clientService) UpdateClient(ctx context.Context, id uuid.UUID, req Upda
 	if req.IsActive != nil {
 		client.IsActive = *req.IsActive
 	}
+	if req.PaymentPlanUntil != nil {
+		if *req.PaymentPlanUntil == "" {
+			client.PaymentPlanUntil = nil
+		} else {
+			until, err := time.Parse("2006-01-02", *req.PaymentPlanUntil)
+			if err != nil {
+				return nil, fmt.Errorf("invalid payment plan date, expected YYYY-MM-DD")
+			}
+			client.PaymentPlanUntil = &until
+		}
+	}
 
 	client.UpdatedAt = time.Now()
 
@@ -57,6 +57,10 @@ type UpdateClientRequest struct {
 	Province   *string `json:"province,omitempty"`
 	IsActive   *bool   `json:"isActive,omitempty"`
 	Notes      *string `json:"notes,omitempty"`
+
+	// Last day of a payment plan (YYYY-MM-DD), during which no payment reminders are sent;
+	// an empty string ends the plan
+	PaymentPlanUntil *string `json:"paymentPlanUntil,omitempty"`
 }
 
 // ClientListResponse represents a paginated list of clients
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+)
+
+// CSVExport is a report exported as a CSV file for spreadsheets and the accountant (gestoría)
+type CSVExport struct {
+	FileName string
+	Content  []byte
+}
+
+// newCSVExport writes a header and its rows as a comma separated file; amounts are written
+// with a decimal point so they can be imported without locale guessing
+func newCSVExport(fileName string, header []string, rows [][]string) (*CSVExport, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return nil, fmt.Errorf("failed to write CSV rows: %w", err)
+	}
+
+	return &CSVExport{FileName: fileName, Content: buf.Bytes()}, nil
+}
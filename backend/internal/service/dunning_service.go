@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/queue"
+	"github.com/google/uuid"
+)
+
+// TaskEnqueuer queues background tasks; the queue worker pool implements it
+type TaskEnqueuer interface {
+	EnqueueTask(taskType queue.TaskType, payload map[string]interface{}) error
+}
+
+// DunningPolicy configures the payment reminders sent for overdue invoices
+type DunningPolicy struct {
+	Schedule domain.DunningSchedule // Days after the due date of each reminder; empty sends none
+}
+
+// DunningRun summarises a run of the dunning job
+type DunningRun struct {
+	MarkedOverdue int       `json:"markedOverdue"` // Invoices found past their due date in this run
+	RemindersSent int       `json:"remindersSent"`
+	Skipped       int       `json:"skipped"` // Clients without an email address, or reminders sent by a concurrent run
+	Failed        int       `json:"failed"`  // Left for the next run
+	RunAt         time.Time `json:"runAt"`
+}
+
+// DunningService detects overdue invoices and chases them with payment reminders
+type DunningService interface {
+	// Run flags the invoices past their due date and queues the reminders due today.
+	// Clients in a payment plan receive no reminders.
+	Run(ctx context.Context) (*DunningRun, error)
+
+	// ListOverdueInvoices retrieves the overdue invoices with a balance outstanding
+	ListOverdueInvoices(ctx context.Context) ([]*domain.Invoice, error)
+
+	// GetInvoiceReminders retrieves the reminders sent for an invoice
+	GetInvoiceReminders(ctx context.Context, invoiceID uuid.UUID) ([]*domain.InvoiceReminder, error)
+}
+
+type dunningService struct {
+	reminderRepo repository.InvoiceReminderRepository
+	invoiceRepo  repository.InvoiceRepository
+	clientRepo   repository.ClientRepository
+	settingsRepo repository.BillingSettingsRepository
+	pdfService   InvoicePDFService
+	tasks        TaskEnqueuer
+	policy       DunningPolicy
+	now          func() time.Time
+}
+
+// NewDunningService creates a new dunning service; reminders are queued as email tasks
+// carrying the invoice PDF
+func NewDunningService(
+	reminderRepo repository.InvoiceReminderRepository,
+	invoiceRepo repository.InvoiceRepository,
+	clientRepo repository.ClientRepository,
+	settingsRepo repository.BillingSettingsRepository,
+	pdfService InvoicePDFService,
+	tasks TaskEnqueuer,
+	policy DunningPolicy,
+) DunningService {
+	return &dunningService{
+		reminderRepo: reminderRepo,
+		invoiceRepo:  invoiceRepo,
+		clientRepo:   clientRepo,
+		settingsRepo: settingsRepo,
+		pdfService:   pdfService,
+		tasks:        tasks,
+		policy:       policy,
+		now:          time.Now,
+	}
+}
+
+// Run flags the invoices past their due date and queues the reminders due today
+func (s *dunningService) Run(ctx context.Context) (*DunningRun, error) {
+	now := s.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	run := &DunningRun{RunAt: now}
+
+	marked, err := s.reminderRepo.MarkOverdue(ctx, today)
+	if err != nil {
+		return nil, err
+	}
+	run.MarkedOverdue = marked
+
+	if len(s.policy.Schedule) == 0 {
+		return run, nil
+	}
+
+	invoices, err := s.reminderRepo.ListDunning(ctx, today)
+	if err != nil {
+		return nil, err
+	}
+
+	// Settings are only read when there is something to send
+	var settings *domain.BillingSettings
+	for _, invoice := range invoices {
+		level, due := s.policy.Schedule.NextLevel(invoice.RemindersSent, invoice.DaysOverdue(today))
+		if !due {
+			continue
+		}
+
+		if settings == nil {
+			if settings, err = s.settingsRepo.Get(ctx); err != nil {
+				return nil, fmt.Errorf("failed to get billing settings: %w", err)
+			}
+		}
+
+		sent, err := s.remind(ctx, &invoice.Invoice, level, today, settings)
+		switch {
+		case err != nil:
+			log.Printf("[ERROR] Failed to send reminder %d of invoice %s: %v", level, invoice.InvoiceNumber, err)
+			run.Failed++
+		case sent:
+			run.RemindersSent++
+		default:
+			run.Skipped++
+		}
+	}
+
+	return run, nil
+}
+
+// remind queues a reminder of an invoice; it returns false when the client cannot be reached
+// or the reminder was already sent
+func (s *dunningService) remind(ctx context.Context, invoice *domain.Invoice, level int, today time.Time, settings *domain.BillingSettings) (bool, error) {
+	client, err := invoiceClient(ctx, s.clientRepo, invoice)
+	if err != nil {
+		return false, fmt.Errorf("failed to get client: %w", err)
+	}
+	// Reminders go to whoever pays the invoice
+	recipient := invoice.Recipient(client)
+	if recipient.Email == "" {
+		log.Printf("[WARN] Payer of invoice %s has no email address, the invoice is not reminded", invoice.InvoiceNumber)
+		return false, nil
+	}
+
+	outstanding, err := invoice.OutstandingAmount()
+	if err != nil {
+		return false, err
+	}
+
+	pdf, err := s.pdfService.GetInvoicePDF(ctx, invoice.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get invoice PDF: %w", err)
+	}
+
+	reminder := &domain.InvoiceReminder{
+		ID:                uuid.New(),
+		InvoiceID:         invoice.ID,
+		Level:             level,
+		DaysOverdue:       invoice.DaysOverdue(today),
+		Recipient:         recipient.Email,
+		OutstandingAmount: outstanding,
+		PaymentReference:  invoice.PaymentReference(),
+		SentAt:            s.now(),
+	}
+
+	stage := s.policy.Schedule.Stage(level)
+	subject, body, err := renderDunningEmail(stage, newDunningEmailData(invoice, client, settings, reminder))
+	if err != nil {
+		return false, err
+	}
+
+	// The level is claimed before the email is queued, so concurrent runs never send it twice
+	if err := s.reminderRepo.Create(ctx, reminder); err != nil {
+		if err == domain.ErrReminderAlreadySent {
+			return false, nil
+		}
+		return false, err
+	}
+
+	err = s.tasks.EnqueueTask(queue.TaskTypeSendEmail, map[string]interface{}{
+		"to":      recipient.Email,
+		"subject": subject,
+		"body":    body,
+		"attachments": []map[string]interface{}{{
+			"filename":    pdf.FileName,
+			"contentType": "application/pdf",
+			"content":     base64.StdEncoding.EncodeToString(pdf.Content),
+		}},
+		"invoiceId":        invoice.ID.String(),
+		"reminderLevel":    level,
+		"dunningStage":     string(stage),
+		"paymentReference": reminder.PaymentReference,
+	})
+	if err != nil {
+		if delErr := s.reminderRepo.Delete(ctx, reminder.ID); delErr != nil {
+			log.Printf("[ERROR] Failed to release reminder %d of invoice %s: %v", level, invoice.InvoiceNumber, delErr)
+		}
+		return false, fmt.Errorf("failed to queue reminder email: %w", err)
+	}
+
+	return true, nil
+}
+
+// ListOverdueInvoices retrieves the overdue invoices with a balance outstanding
+func (s *dunningService) ListOverdueInvoices(ctx context.Context) ([]*domain.Invoice, error) {
+	return s.reminderRepo.ListOverdue(ctx)
+}
+
+// GetInvoiceReminders retrieves the reminders sent for an invoice
+func (s *dunningService) GetInvoiceReminders(ctx context.Context, invoiceID uuid.UUID) ([]*domain.InvoiceReminder, error) {
+	if _, err := s.invoiceRepo.GetByID(ctx, invoiceID); err != nil {
+		return nil, err
+	}
+
+	return s.reminderRepo.ListByInvoice(ctx, invoiceID)
+}
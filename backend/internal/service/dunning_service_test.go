@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/queue"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockInvoiceReminderRepository is a mock implementation of InvoiceReminderRepository
+type MockInvoiceReminderRepository struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceReminderRepository) MarkOverdue(ctx context.Context, today time.Time) (int, error) {
+	args := m.Called(ctx, today)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockInvoiceReminderRepository) ListOverdue(ctx context.Context) ([]*domain.Invoice, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Invoice), args.Error(1)
+}
+
+func (m *MockInvoiceReminderRepository) ListDunning(ctx context.Context, today time.Time) ([]*repository.DunningInvoice, error) {
+	args := m.Called(ctx, today)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*repository.DunningInvoice), args.Error(1)
+}
+
+func (m *MockInvoiceReminderRepository) Create(ctx context.Context, reminder *domain.InvoiceReminder) error {
+	args := m.Called(ctx, reminder)
+	return args.Error(0)
+}
+
+func (m *MockInvoiceReminderRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockInvoiceReminderRepository) ListByInvoice(ctx context.Context, invoiceID uuid.UUID) ([]*domain.InvoiceReminder, error) {
+	args := m.Called(ctx, invoiceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.InvoiceReminder), args.Error(1)
+}
+
+// fakeInvoicePDFService renders a fixed document for every invoice
+type fakeInvoicePDFService struct{}
+
+func (fakeInvoicePDFService) GetInvoicePDF(ctx context.Context, invoiceID uuid.UUID) (*InvoicePDF, error) {
+	return &InvoicePDF{FileName: "factura.pdf", Content: []byte("%PDF-1.4 " + invoiceID.String())}, nil
+}
+
+// fakeTaskEnqueuer keeps the queued tasks in memory
+type fakeTaskEnqueuer struct {
+	tasks []map[string]interface{}
+	err   error
+}
+
+func (f *fakeTaskEnqueuer) EnqueueTask(taskType queue.TaskType, payload map[string]interface{}) error {
+	if f.err != nil {
+		return f.err
+	}
+	if taskType != queue.TaskTypeSendEmail {
+		return fmt.Errorf("unexpected task type %s", taskType)
+	}
+	f.tasks = append(f.tasks, payload)
+	return nil
+}
+
+// dunningToday is the day the tests run the job
+var dunningToday = time.Date(2025, 4, 20, 0, 0, 0, 0, time.UTC)
+
+type dunningTestService struct {
+	*dunningService
+	reminderRepo *MockInvoiceReminderRepository
+	clientRepo   *MockClientRepository
+	tasks        *fakeTaskEnqueuer
+}
+
+func newDunningTestService() *dunningTestService {
+	reminderRepo := new(MockInvoiceReminderRepository)
+	clientRepo := new(MockClientRepository)
+	settingsRepo := new(MockBillingSettingsRepository)
+	settingsRepo.On("Get", mock.Anything).Return(pdfTestSettings(), nil)
+	tasks := &fakeTaskEnqueuer{}
+
+	svc := NewDunningService(reminderRepo, new(MockInvoiceRepository), clientRepo, settingsRepo, fakeInvoicePDFService{}, tasks, DunningPolicy{
+		Schedule: domain.DunningSchedule{3, 15, 30},
+	}).(*dunningService)
+	svc.now = func() time.Time { return dunningToday.Add(9 * time.Hour) }
+
+	return &dunningTestService{dunningService: svc, reminderRepo: reminderRepo, clientRepo: clientRepo, tasks: tasks}
+}
+
+// overdueTestInvoice returns an issued invoice of 361.00 due daysOverdue days before dunningToday
+func overdueTestInvoice(t *testing.T, daysOverdue, remindersSent int) *repository.DunningInvoice {
+	t.Helper()
+
+	invoice := issuedTestInvoice(t)
+	invoice.DueDate = dunningToday.AddDate(0, 0, -daysOverdue)
+	overdueAt := invoice.DueDate.AddDate(0, 0, 1)
+	invoice.OverdueAt = &overdueAt
+	return &repository.DunningInvoice{Invoice: *invoice, RemindersSent: remindersSent}
+}
+
+func dunningTestClient(id uuid.UUID) *domain.Client {
+	return &domain.Client{ID: id, FirstName: "Lucía", LastName: "Martín", Email: "lucia@example.com"}
+}
+
+func TestDunningService_Run(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("queues the reminder due with the PDF and the payment reference", func(t *testing.T) {
+		s := newDunningTestService()
+		invoice := overdueTestInvoice(t, 4, 0)
+		require.NoError(t, invoice.ApplyPaidAmount(money.MustParse("61")))
+
+		s.reminderRepo.On("MarkOverdue", ctx, dunningToday).Return(1, nil)
+		s.reminderRepo.On("ListDunning", ctx, dunningToday).Return([]*repository.DunningInvoice{invoice}, nil)
+		s.clientRepo.On("GetByID", ctx, invoice.ClientID).Return(dunningTestClient(invoice.ClientID), nil)
+		s.reminderRepo.On("Create", ctx, mock.MatchedBy(func(r *domain.InvoiceReminder) bool {
+			return r.InvoiceID == invoice.ID && r.Level == 1 && r.DaysOverdue == 4 &&
+				r.OutstandingAmount.Equal(money.MustParse("300")) && r.Recipient == "lucia@example.com"
+		})).Return(nil)
+
+		run, err := s.Run(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.MarkedOverdue)
+		assert.Equal(t, 1, run.RemindersSent)
+		assert.Zero(t, run.Failed)
+
+		require.Len(t, s.tasks.tasks, 1)
+		email := s.tasks.tasks[0]
+		assert.Equal(t, "lucia@example.com", email["to"])
+		assert.Equal(t, "Recordatorio de pago: factura F_2025_0010", email["subject"])
+		assert.Equal(t, "friendly", email["dunningStage"])
+		assert.Equal(t, 1, email["reminderLevel"])
+
+		reference := invoice.PaymentReference()
+		assert.Equal(t, reference, email["paymentReference"])
+		body := email["body"].(string)
+		assert.Contains(t, body, "Hola, Lucía Martín:")
+		assert.Contains(t, body, "Importe pendiente: 300,00 €")
+		assert.Contains(t, body, "Referencia de pago: "+reference)
+		assert.Contains(t, body, "ES91 2100 0418 4502 0005 1332")
+
+		attachments := email["attachments"].([]map[string]interface{})
+		require.Len(t, attachments, 1)
+		assert.Equal(t, "application/pdf", attachments[0]["contentType"])
+		content, err := base64.StdEncoding.DecodeString(attachments[0]["content"].(string))
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(content), "%PDF"))
+	})
+
+	t.Run("levels are sent in order once their day is reached", func(t *testing.T) {
+		cases := []struct {
+			name          string
+			daysOverdue   int
+			remindersSent int
+			level         int // 0 when nothing is due
+			subject       string
+		}{
+			{"not reached", 2, 0, 0, ""},
+			{"friendly first even when late", 40, 0, 1, "Recordatorio de pago"},
+			{"second notice", 15, 1, 2, "Segundo aviso"},
+			{"second notice not reached", 14, 1, 0, ""},
+			{"final notice", 31, 2, 3, "Último aviso"},
+			{"sequence finished", 90, 3, 0, ""},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				s := newDunningTestService()
+				invoice := overdueTestInvoice(t, tc.daysOverdue, tc.remindersSent)
+
+				s.reminderRepo.On("MarkOverdue", ctx, dunningToday).Return(0, nil)
+				s.reminderRepo.On("ListDunning", ctx, dunningToday).Return([]*repository.DunningInvoice{invoice}, nil)
+				s.clientRepo.On("GetByID", ctx, invoice.ClientID).Return(dunningTestClient(invoice.ClientID), nil)
+				s.reminderRepo.On("Create", ctx, mock.AnythingOfType("*domain.InvoiceReminder")).Return(nil)
+
+				run, err := s.Run(ctx)
+				require.NoError(t, err)
+
+				if tc.level == 0 {
+					assert.Zero(t, run.RemindersSent)
+					assert.Empty(t, s.tasks.tasks)
+					s.reminderRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+					return
+				}
+				require.Len(t, s.tasks.tasks, 1)
+				assert.Equal(t, tc.level, s.tasks.tasks[0]["reminderLevel"])
+				assert.True(t, strings.HasPrefix(s.tasks.tasks[0]["subject"].(string), tc.subject))
+			})
+		}
+	})
+
+	t.Run("clients without email are skipped", func(t *testing.T) {
+		s := newDunningTestService()
+		invoice := overdueTestInvoice(t, 5, 0)
+		client := dunningTestClient(invoice.ClientID)
+		client.Email = ""
+
+		s.reminderRepo.On("MarkOverdue", ctx, dunningToday).Return(0, nil)
+		s.reminderRepo.On("ListDunning", ctx, dunningToday).Return([]*repository.DunningInvoice{invoice}, nil)
+		s.clientRepo.On("GetByID", ctx, invoice.ClientID).Return(client, nil)
+
+		run, err := s.Run(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.Skipped)
+		assert.Empty(t, s.tasks.tasks)
+		s.reminderRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("a reminder claimed by a concurrent run is not queued again", func(t *testing.T) {
+		s := newDunningTestService()
+		invoice := overdueTestInvoice(t, 5, 0)
+
+		s.reminderRepo.On("MarkOverdue", ctx, dunningToday).Return(0, nil)
+		s.reminderRepo.On("ListDunning", ctx, dunningToday).Return([]*repository.DunningInvoice{invoice}, nil)
+		s.clientRepo.On("GetByID", ctx, invoice.ClientID).Return(dunningTestClient(invoice.ClientID), nil)
+		s.reminderRepo.On("Create", ctx, mock.AnythingOfType("*domain.InvoiceReminder")).Return(domain.ErrReminderAlreadySent)
+
+		run, err := s.Run(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.Skipped)
+		assert.Empty(t, s.tasks.tasks)
+	})
+
+	t.Run("a reminder that cannot be queued is released for the next run", func(t *testing.T) {
+		s := newDunningTestService()
+		s.tasks.err = fmt.Errorf("redis unavailable")
+		invoice := overdueTestInvoice(t, 5, 0)
+
+		var claimed *domain.InvoiceReminder
+		s.reminderRepo.On("MarkOverdue", ctx, dunningToday).Return(0, nil)
+		s.reminderRepo.On("ListDunning", ctx, dunningToday).Return([]*repository.DunningInvoice{invoice}, nil)
+		s.clientRepo.On("GetByID", ctx, invoice.ClientID).Return(dunningTestClient(invoice.ClientID), nil)
+		s.reminderRepo.On("Create", ctx, mock.AnythingOfType("*domain.InvoiceReminder")).
+			Run(func(args mock.Arguments) { claimed = args.Get(1).(*domain.InvoiceReminder) }).
+			Return(nil)
+		s.reminderRepo.On("Delete", ctx, mock.AnythingOfType("uuid.UUID")).Return(nil)
+
+		run, err := s.Run(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.Failed)
+		require.NotNil(t, claimed)
+		s.reminderRepo.AssertCalled(t, "Delete", ctx, claimed.ID)
+	})
+
+	t.Run("without a schedule invoices are only flagged", func(t *testing.T) {
+		s := newDunningTestService()
+		s.policy.Schedule = nil
+		s.reminderRepo.On("MarkOverdue", ctx, dunningToday).Return(3, nil)
+
+		run, err := s.Run(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, run.MarkedOverdue)
+		s.reminderRepo.AssertNotCalled(t, "ListDunning", mock.Anything, mock.Anything)
+	})
+}
+
+func TestDunningSchedule(t *testing.T) {
+	schedule := domain.DunningSchedule{3, 15, 30}
+	require.NoError(t, schedule.Validate())
+
+	assert.Equal(t, domain.DunningStageFriendly, schedule.Stage(1))
+	assert.Equal(t, domain.DunningStageSecondNotice, schedule.Stage(2))
+	assert.Equal(t, domain.DunningStageFinalNotice, schedule.Stage(3))
+	assert.Equal(t, domain.DunningStageFriendly, domain.DunningSchedule{7}.Stage(1), "a single reminder stays friendly")
+
+	for _, invalid := range []domain.DunningSchedule{{0, 10}, {10, 5}, {3, 3}} {
+		requireValidationError(t, invalid.Validate())
+	}
+}
+
+func TestInvoice_PaymentReference(t *testing.T) {
+	// Example of ISO 11649
+	assert.Equal(t, "RF18539007547034", (&domain.Invoice{InvoiceNumber: "539007547034"}).PaymentReference())
+
+	// The check digits validate the reference: moving "RFnn" to the end leaves 1 modulo 97
+	reference := (&domain.Invoice{InvoiceNumber: "F_2025_0010"}).PaymentReference()
+	require.True(t, strings.HasPrefix(reference, "RF"))
+	assert.True(t, strings.HasSuffix(reference, "F20250010"))
+
+	var digits strings.Builder
+	for _, r := range reference[4:] + reference[:4] {
+		if r >= 'A' && r <= 'Z' {
+			digits.WriteString(fmt.Sprint(r - 'A' + 10))
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	require.True(t, ok)
+	assert.Equal(t, int64(1), new(big.Int).Mod(n, big.NewInt(97)).Int64())
+
+	// Numbers longer than a creditor reference allows are quoted as they are
+	long := "CLINICAMAD_2025_0000000001"
+	assert.Equal(t, long, (&domain.Invoice{InvoiceNumber: long}).PaymentReference())
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+)
+
+// dunningEmailData is the data available to the payment reminder templates
+type dunningEmailData struct {
+	ClientName       string
+	InvoiceNumber    string
+	IssueDate        string
+	DueDate          string
+	DaysOverdue      int
+	Outstanding      string
+	PaymentReference string
+	IBAN             string // Empty when the clinic has not configured one
+	ClinicName       string
+	ClinicEmail      string
+	ClinicPhone      string
+}
+
+// dunningEmailTemplate is the subject and body of a reminder stage
+type dunningEmailTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// paymentInstructions is shared by every stage
+const paymentInstructions = `{{define "payment"}}Importe pendiente: {{.Outstanding}}
+Referencia de pago: {{.PaymentReference}}
+{{- if .IBAN}}
+Puede abonarlo por transferencia a la cuenta {{.IBAN}} indicando la referencia de pago en el concepto.
+{{- end}}
+
+Adjuntamos una copia de la factura.{{end}}` +
+	`{{define "signature"}}Un saludo,
+{{.ClinicName}}
+{{- if .ClinicEmail}}
+{{.ClinicEmail}}{{end}}
+{{- if .ClinicPhone}}
+{{.ClinicPhone}}{{end}}{{end}}`
+
+var dunningEmailTemplates = map[domain.DunningStage]dunningEmailTemplate{
+	domain.DunningStageFriendly: newDunningEmailTemplate(
+		`Recordatorio de pago: factura {{.InvoiceNumber}}`,
+		`Hola, {{.ClientName}}:
+
+Le recordamos que la factura {{.InvoiceNumber}}, emitida el {{.IssueDate}}, venció el {{.DueDate}} y todavía figura como pendiente de pago. Es posible que se trate de un descuido; si ya la ha abonado, puede ignorar este mensaje.
+
+{{template "payment" .}}
+
+{{template "signature" .}}
+`),
+	domain.DunningStageSecondNotice: newDunningEmailTemplate(
+		`Segundo aviso: factura {{.InvoiceNumber}} pendiente de pago`,
+		`Hola, {{.ClientName}}:
+
+No nos consta el pago de la factura {{.InvoiceNumber}}, que venció el {{.DueDate}} (hace {{.DaysOverdue}} días). Le rogamos que regularice el importe pendiente a la mayor brevedad o que se ponga en contacto con nosotros si necesita acordar un plan de pago.
+
+{{template "payment" .}}
+
+{{template "signature" .}}
+`),
+	domain.DunningStageFinalNotice: newDunningEmailTemplate(
+		`Último aviso: factura {{.InvoiceNumber}} pendiente de pago`,
+		`Hola, {{.ClientName}}:
+
+A pesar de nuestros avisos anteriores, la factura {{.InvoiceNumber}}, vencida el {{.DueDate}}, sigue pendiente de pago después de {{.DaysOverdue}} días. Este es el último recordatorio que le enviamos; si no recibimos el pago ni noticias suyas, nos veremos obligados a iniciar otras gestiones de cobro.
+
+{{template "payment" .}}
+
+{{template "signature" .}}
+`),
+}
+
+func newDunningEmailTemplate(subject, body string) dunningEmailTemplate {
+	return dunningEmailTemplate{
+		subject: template.Must(template.New("subject").Parse(subject)),
+		body:    template.Must(template.Must(template.New("body").Parse(paymentInstructions)).Parse(body)),
+	}
+}
+
+// newDunningEmailData gathers the template data of a reminder
+func newDunningEmailData(invoice *domain.Invoice, client *domain.Client, settings *domain.BillingSettings, reminder *domain.InvoiceReminder) dunningEmailData {
+	data := dunningEmailData{
+		ClientName:       invoice.Recipient(client).Name,
+		InvoiceNumber:    invoice.InvoiceNumber,
+		IssueDate:        formatPDFDate(invoice.IssueDate),
+		DueDate:          formatPDFDate(invoice.DueDate),
+		DaysOverdue:      reminder.DaysOverdue,
+		Outstanding:      formatPDFAmount(reminder.OutstandingAmount),
+		PaymentReference: reminder.PaymentReference,
+		ClinicName:       settings.DisplayName(),
+		ClinicEmail:      settings.Email,
+		ClinicPhone:      settings.Phone,
+	}
+	if settings.IBAN != "" {
+		data.IBAN = formatIBAN(strings.ReplaceAll(settings.IBAN, " ", ""))
+	}
+	return data
+}
+
+// renderDunningEmail renders the subject and body of a reminder stage
+func renderDunningEmail(stage domain.DunningStage, data dunningEmailData) (string, string, error) {
+	tmpl, ok := dunningEmailTemplates[stage]
+	if !ok {
+		return "", "", fmt.Errorf("no email template for dunning stage %s", stage)
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return "", "", fmt.Errorf("failed to render reminder subject: %w", err)
+	}
+	if err := tmpl.body.Execute(&body, data); err != nil {
+		return "", "", fmt.Errorf("failed to render reminder body: %w", err)
+	}
+
+	return subject.String(), body.String(), nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -40,30 +41,32 @@ func NewEmployeeService(repo repository.EmployeeRepository, userRepo repository.
 
 // CreateEmployeeRequest represents the request to create a new employee
 type CreateEmployeeRequest struct {
-	UserID      *uuid.UUID `json:"userId"`
-	FirstName   string     `json:"firstName" binding:"required"`
-	LastName    string     `json:"lastName" binding:"required"`
-	Email       string     `json:"email" binding:"required,email"`
-	Phone       string     `json:"phone" binding:"required"`
-	DNI         string     `json:"dni" binding:"required"`
-	Specialty   string     `json:"specialty" binding:"required"` // Single specialty, converted to array internally
-	HireDate    string     `json:"hireDate" binding:"required"`  // ISO 8601 date string
-	Notes       string     `json:"notes"`                        // Optional notes
-	AvatarColor string     `json:"avatarColor"`
+	UserID      *uuid.UUID   `json:"userId"`
+	FirstName   string       `json:"firstName" binding:"required"`
+	LastName    string       `json:"lastName" binding:"required"`
+	Email       string       `json:"email" binding:"required,email"`
+	Phone       string       `json:"phone" binding:"required"`
+	DNI         string       `json:"dni" binding:"required"`
+	Specialty   string       `json:"specialty" binding:"required"` // Single specialty, converted to array internally
+	HireDate    string       `json:"hireDate" binding:"required"`  // ISO 8601 date string
+	Notes       string       `json:"notes"`                        // Optional notes
+	AvatarColor string       `json:"avatarColor"`
+	SessionRate *money.Money `json:"sessionRate,omitempty"` // Optional price per session before VAT
 }
 
 // UpdateEmployeeRequest represents the request to update an employee
 type UpdateEmployeeRequest struct {
-	FirstName   string `json:"firstName"`
-	LastName    string `json:"lastName"`
-	Email       string `json:"email"`
-	Phone       string `json:"phone"`
-	DNI         string `json:"dni"`
-	Specialty   string `json:"specialty"` // Single specialty
-	HireDate    string `json:"hireDate"`  // ISO 8601 date string
-	Notes       string `json:"notes"`     // Optional notes
-	IsActive    *bool  `json:"isActive"`
-	AvatarColor string `json:"avatarColor"`
+	FirstName   string       `json:"firstName"`
+	LastName    string       `json:"lastName"`
+	Email       string       `json:"email"`
+	Phone       string       `json:"phone"`
+	DNI         string       `json:"dni"`
+	Specialty   string       `json:"specialty"` // Single specialty
+	HireDate    string       `json:"hireDate"`  // ISO 8601 date string
+	Notes       string       `json:"notes"`     // Optional notes
+	IsActive    *bool        `json:"isActive"`
+	AvatarColor string       `json:"avatarColor"`
+	SessionRate *money.Money `json:"sessionRate,omitempty"` // Zero clears the rate
 }
 
 var (
@@ -73,6 +76,7 @@ var (
 	ErrEmailInUse       = errors.New("email is already in use")
 	ErrDNIInUse         = errors.New("DNI is already in use")
 	ErrEmployeeNotFound = errors.New("employee not found")
+	ErrInvalidRate      = errors.New("session rate cannot be negative")
 )
 
 func (s *employeeService) CreateEmployee(ctx context.Context, req CreateEmployeeRequest) (*domain.Employee, error) {
@@ -89,6 +93,10 @@ func (s *employeeService) CreateEmployee(ctx context.Context, req CreateEmployee
 		return nil, err
 	}
 
+	if req.SessionRate != nil && req.SessionRate.IsNegative() {
+		return nil, ErrInvalidRate
+	}
+
 	// Check for duplicates
 	emailExists, err := s.repo.EmailExists(ctx, req.Email)
 	if err != nil {
@@ -161,6 +169,7 @@ func (s *employeeService) CreateEmployee(ctx context.Context, req CreateEmployee
 		Notes:       &notes,
 		IsActive:    true,
 		AvatarColor: req.AvatarColor,
+		SessionRate: req.SessionRate,
 	}
 
 	if employee.AvatarColor == "" {
@@ -284,6 +293,16 @@ func (s *employeeService) UpdateEmployee(ctx context.Context, id uuid.UUID, req
 	if req.AvatarColor != "" {
 		employee.AvatarColor = req.AvatarColor
 	}
+	if req.SessionRate != nil {
+		switch {
+		case req.SessionRate.IsNegative():
+			return nil, ErrInvalidRate
+		case req.SessionRate.IsZero():
+			employee.SessionRate = nil
+		default:
+			employee.SessionRate = req.SessionRate
+		}
+	}
 
 	// Save updates
 	if err := s.repo.Update(ctx, employee); err != nil {
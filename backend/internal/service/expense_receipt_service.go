@@ -0,0 +1,322 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// DefaultReceiptURLTTL is used when no lifetime is configured for receipt download URLs
+const DefaultReceiptURLTTL = 5 * time.Minute
+
+// ExpenseReceiptDownloadPath is the public route serving receipts through signed URLs
+// when the storage backend cannot presign them
+const ExpenseReceiptDownloadPath = "/api/v1/files/expense-receipts/"
+
+// allowedReceiptTypes maps the sniffed MIME types accepted for expense receipts
+var allowedReceiptTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+}
+
+// UploadReceiptRequest represents a receipt uploaded to an expense
+type UploadReceiptRequest struct {
+	FileName string
+	Content  io.Reader
+}
+
+// ReceiptURL is a short-lived URL to download the receipt of an expense
+type ReceiptURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ExpenseReceiptService manages the receipts (supplier invoices, tickets) of expenses
+type ExpenseReceiptService interface {
+	// Upload stores a receipt and attaches it to the expense, replacing the previous one.
+	// Receipts are stored once per content; uploading one already attached to another
+	// expense is rejected as a likely duplicate expense.
+	Upload(ctx context.Context, expenseID uuid.UUID, req *UploadReceiptRequest) (*domain.Expense, error)
+
+	// DownloadURL returns a short-lived URL to download the receipt of an expense
+	DownloadURL(ctx context.Context, expenseID uuid.UUID) (*ReceiptURL, error)
+
+	// Open checks a signed URL issued by DownloadURL and opens the receipt; the caller
+	// must close the reader
+	Open(ctx context.Context, expenseID uuid.UUID, expires time.Time, signature string) (io.ReadCloser, *domain.Expense, error)
+
+	// Delete removes the receipt of an expense
+	Delete(ctx context.Context, expenseID uuid.UUID) error
+}
+
+type expenseReceiptService struct {
+	expenseRepo repository.ExpenseRepository
+	storage     storage.Storage
+	signer      *storage.URLSigner
+	maxBytes    int64
+	urlTTL      time.Duration
+	now         func() time.Time
+}
+
+// NewExpenseReceiptService creates a new expense receipt service
+func NewExpenseReceiptService(
+	expenseRepo repository.ExpenseRepository,
+	store storage.Storage,
+	signer *storage.URLSigner,
+	maxBytes int64,
+	urlTTL time.Duration,
+) ExpenseReceiptService {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxAttachmentBytes
+	}
+	if urlTTL <= 0 {
+		urlTTL = DefaultReceiptURLTTL
+	}
+
+	return &expenseReceiptService{
+		expenseRepo: expenseRepo,
+		storage:     store,
+		signer:      signer,
+		maxBytes:    maxBytes,
+		urlTTL:      urlTTL,
+		now:         time.Now,
+	}
+}
+
+// Upload stores a receipt and attaches it to the expense, replacing the previous one
+func (s *expenseReceiptService) Upload(ctx context.Context, expenseID uuid.UUID, req *UploadReceiptRequest) (*domain.Expense, error) {
+	expense, err := s.expenseRepo.GetByID(ctx, expenseID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := filepath.Base(strings.TrimSpace(req.FileName))
+	if fileName == "" || fileName == "." || fileName == "/" {
+		return nil, errors.NewValidationError("file name is required", nil)
+	}
+
+	// Read one byte past the limit to detect oversized files without trusting headers
+	content, err := io.ReadAll(io.LimitReader(req.Content, s.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read receipt: %w", err)
+	}
+	if len(content) == 0 {
+		return nil, errors.NewValidationError("file is empty", nil)
+	}
+	if int64(len(content)) > s.maxBytes {
+		return nil, errors.NewValidationError(fmt.Sprintf("file exceeds the maximum size of %d MB", s.maxBytes>>20), nil)
+	}
+
+	contentType, ok := sniffReceiptType(content)
+	if !ok {
+		return nil, errors.NewValidationError("file type not allowed", map[string][]string{
+			"file": {"allowed types: PDF, JPEG, PNG"},
+		})
+	}
+
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	// Uploading the same receipt again changes nothing
+	if expense.AttachmentSHA256 != nil && *expense.AttachmentSHA256 == hash {
+		return expense, nil
+	}
+
+	owners, err := s.expenseRepo.ListByAttachmentHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	for _, owner := range owners {
+		if owner.ID != expense.ID && owner.DeletedAt == nil {
+			return nil, errors.NewConflictError(fmt.Sprintf(
+				"this receipt is already attached to the expense of %s on %s (%s)",
+				owner.Supplier, owner.ExpenseDate.Format("02/01/2006"), owner.ID,
+			), errors.CodeConflict)
+		}
+	}
+
+	// Receipts are addressed by content, so an object kept for a deleted expense is reused
+	key := receiptStorageKey(hash)
+	stored := len(owners) == 0
+	if stored {
+		if err := s.storage.Put(ctx, key, bytes.NewReader(content), int64(len(content)), contentType); err != nil {
+			return nil, fmt.Errorf("failed to store receipt: %w", err)
+		}
+	}
+
+	var previousHash *string
+	if expense.HasUploadedReceipt() {
+		previousHash = expense.AttachmentSHA256
+	}
+
+	now := s.now()
+	size := int64(len(content))
+	expense.AttachmentPath = &key
+	expense.AttachmentFileName = &fileName
+	expense.AttachmentContentType = &contentType
+	expense.AttachmentSizeBytes = &size
+	expense.AttachmentSHA256 = &hash
+	expense.AttachmentUploadedAt = &now
+	expense.UpdatedAt = now
+
+	if err := s.expenseRepo.UpdateAttachment(ctx, expense); err != nil {
+		// Do not leave orphaned objects behind
+		if stored {
+			if delErr := s.storage.Delete(ctx, key); delErr != nil {
+				log.Printf("[WARN] Failed to remove orphaned receipt %s: %v", key, delErr)
+			}
+		}
+		return nil, err
+	}
+
+	if previousHash != nil {
+		s.release(ctx, *previousHash)
+	}
+
+	return expense, nil
+}
+
+// DownloadURL returns a short-lived URL to download the receipt of an expense
+func (s *expenseReceiptService) DownloadURL(ctx context.Context, expenseID uuid.UUID) (*ReceiptURL, error) {
+	expense, err := s.expenseRepo.GetByID(ctx, expenseID)
+	if err != nil {
+		return nil, err
+	}
+	if !expense.HasUploadedReceipt() {
+		return nil, domain.ErrExpenseHasNoReceipt
+	}
+
+	// Backends that can presign serve the file themselves
+	if presigner, ok := s.storage.(storage.Presigner); ok {
+		expiresAt := s.now().Add(s.urlTTL)
+		url, err := presigner.PresignGet(ctx, *expense.AttachmentPath, s.urlTTL, *expense.AttachmentFileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to presign receipt url: %w", err)
+		}
+		return &ReceiptURL{URL: url, ExpiresAt: expiresAt}, nil
+	}
+
+	// Signatures have a one-second resolution
+	expiresAt := s.now().Add(s.urlTTL).Truncate(time.Second)
+	signature := s.signer.Sign(receiptURLResource(expense), expiresAt)
+
+	return &ReceiptURL{
+		URL:       fmt.Sprintf("%s%s?expires=%d&signature=%s", ExpenseReceiptDownloadPath, expense.ID, expiresAt.Unix(), signature),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Open checks a signed URL issued by DownloadURL and opens the receipt
+func (s *expenseReceiptService) Open(ctx context.Context, expenseID uuid.UUID, expires time.Time, signature string) (io.ReadCloser, *domain.Expense, error) {
+	// Missing expenses are reported as invalid links so their existence is not leaked
+	expense, err := s.expenseRepo.GetByID(ctx, expenseID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil, errors.NewForbiddenError("invalid download link")
+		}
+		return nil, nil, err
+	}
+	if !expense.HasUploadedReceipt() {
+		return nil, nil, errors.NewForbiddenError("invalid download link")
+	}
+
+	if err := s.signer.Verify(receiptURLResource(expense), expires, signature, s.now()); err != nil {
+		if err == storage.ErrURLExpired {
+			return nil, nil, errors.NewForbiddenError("download link has expired")
+		}
+		return nil, nil, errors.NewForbiddenError("invalid download link")
+	}
+
+	reader, _, err := s.storage.Get(ctx, *expense.AttachmentPath)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, nil, errors.NewNotFoundError("receipt file not found")
+		}
+		return nil, nil, fmt.Errorf("failed to open receipt: %w", err)
+	}
+
+	return reader, expense, nil
+}
+
+// Delete removes the receipt of an expense
+func (s *expenseReceiptService) Delete(ctx context.Context, expenseID uuid.UUID) error {
+	expense, err := s.expenseRepo.GetByID(ctx, expenseID)
+	if err != nil {
+		return err
+	}
+	if !expense.HasAttachment() {
+		return domain.ErrExpenseHasNoReceipt
+	}
+
+	// Paths recorded before uploads were supported do not point to our objects
+	var previousHash *string
+	if expense.HasUploadedReceipt() {
+		previousHash = expense.AttachmentSHA256
+	}
+
+	expense.ClearAttachment()
+	expense.UpdatedAt = s.now()
+	if err := s.expenseRepo.UpdateAttachment(ctx, expense); err != nil {
+		return err
+	}
+
+	if previousHash != nil {
+		s.release(ctx, *previousHash)
+	}
+
+	return nil
+}
+
+// release deletes the object of a receipt once no expense references it, deleted ones
+// included so their receipts are kept
+func (s *expenseReceiptService) release(ctx context.Context, hash string) {
+	owners, err := s.expenseRepo.ListByAttachmentHash(ctx, hash)
+	if err != nil {
+		log.Printf("[WARN] Failed to check references of receipt %s: %v", hash, err)
+		return
+	}
+	if len(owners) > 0 {
+		return
+	}
+
+	key := receiptStorageKey(hash)
+	if err := s.storage.Delete(ctx, key); err != nil {
+		log.Printf("[WARN] Failed to remove receipt object %s: %v", key, err)
+	}
+}
+
+// receiptStorageKey returns the storage key of a receipt from the SHA-256 of its content
+func receiptStorageKey(hash string) string {
+	return "expenses/receipts/" + hash
+}
+
+// receiptURLResource identifies the receipt signed in a download URL; replacing the
+// receipt invalidates the URLs issued for the previous one
+func receiptURLResource(expense *domain.Expense) string {
+	return "expense-receipts/" + expense.ID.String() + "/" + *expense.AttachmentSHA256
+}
+
+// sniffReceiptType detects the MIME type from the content and checks it is allowed
+func sniffReceiptType(content []byte) (string, bool) {
+	contentType := http.DetectContentType(content)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+
+	return contentType, allowedReceiptTypes[contentType]
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var pngContent = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+
+func newReceiptTestService(t *testing.T, maxBytes int64) (*expenseReceiptService, *MockExpenseRepository, storage.Storage) {
+	store, err := storage.NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+
+	expenseRepo := new(MockExpenseRepository)
+	svc := NewExpenseReceiptService(expenseRepo, store, storage.NewURLSigner("test-key"), maxBytes, 5*time.Minute)
+
+	return svc.(*expenseReceiptService), expenseRepo, store
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestExpenseReceiptService_Upload_StoresByContentHash(t *testing.T) {
+	svc, expenseRepo, store := newReceiptTestService(t, 0)
+	ctx := context.Background()
+
+	expense := &domain.Expense{ID: uuid.New(), Supplier: "Papelería Sol"}
+	hash := contentHash(pdfContent)
+
+	expenseRepo.On("GetByID", ctx, expense.ID).Return(expense, nil)
+	expenseRepo.On("ListByAttachmentHash", ctx, hash).Return([]*domain.Expense{}, nil)
+	expenseRepo.On("UpdateAttachment", ctx, expense).Return(nil)
+
+	updated, err := svc.Upload(ctx, expense.ID, &UploadReceiptRequest{
+		FileName: "../factura 12.pdf",
+		Content:  bytes.NewReader(pdfContent),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "expenses/receipts/"+hash, *updated.AttachmentPath)
+	assert.Equal(t, "factura 12.pdf", *updated.AttachmentFileName)
+	assert.Equal(t, "application/pdf", *updated.AttachmentContentType)
+	assert.Equal(t, int64(len(pdfContent)), *updated.AttachmentSizeBytes)
+	assert.Equal(t, hash, *updated.AttachmentSHA256)
+	assert.True(t, updated.HasUploadedReceipt())
+
+	reader, _, err := store.Get(ctx, *updated.AttachmentPath)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, pdfContent, data)
+}
+
+func TestExpenseReceiptService_Upload_RejectsInvalidFiles(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{"empty", nil},
+		{"plain text", []byte("just some notes")},
+		{"too large", append(append([]byte{}, pdfContent...), bytes.Repeat([]byte("x"), 1024)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, expenseRepo, _ := newReceiptTestService(t, 1024)
+			expense := &domain.Expense{ID: uuid.New()}
+			expenseRepo.On("GetByID", ctx, expense.ID).Return(expense, nil)
+
+			_, err := svc.Upload(ctx, expense.ID, &UploadReceiptRequest{
+				FileName: "ticket.pdf",
+				Content:  bytes.NewReader(tt.content),
+			})
+
+			require.Error(t, err)
+			appErr, ok := err.(*errors.AppError)
+			require.True(t, ok)
+			assert.Equal(t, errors.CodeValidationFailed, appErr.Code)
+			expenseRepo.AssertNotCalled(t, "UpdateAttachment", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestExpenseReceiptService_Upload_RejectsReceiptOfAnotherExpense(t *testing.T) {
+	svc, expenseRepo, _ := newReceiptTestService(t, 0)
+	ctx := context.Background()
+
+	expense := &domain.Expense{ID: uuid.New()}
+	other := &domain.Expense{ID: uuid.New(), Supplier: "Limpiezas Norte", ExpenseDate: time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)}
+
+	expenseRepo.On("GetByID", ctx, expense.ID).Return(expense, nil)
+	expenseRepo.On("ListByAttachmentHash", ctx, contentHash(pdfContent)).Return([]*domain.Expense{other}, nil)
+
+	_, err := svc.Upload(ctx, expense.ID, &UploadReceiptRequest{FileName: "ticket.pdf", Content: bytes.NewReader(pdfContent)})
+
+	require.Error(t, err)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, errors.CodeConflict, appErr.Code)
+	assert.Contains(t, appErr.Message, "Limpiezas Norte on 03/02/2026")
+	expenseRepo.AssertNotCalled(t, "UpdateAttachment", mock.Anything, mock.Anything)
+}
+
+func TestExpenseReceiptService_Upload_ReplacingReleasesUnreferencedObject(t *testing.T) {
+	svc, expenseRepo, store := newReceiptTestService(t, 0)
+	ctx := context.Background()
+
+	oldHash := contentHash(pdfContent)
+	oldKey := "expenses/receipts/" + oldHash
+	require.NoError(t, store.Put(ctx, oldKey, bytes.NewReader(pdfContent), int64(len(pdfContent)), "application/pdf"))
+
+	expense := &domain.Expense{ID: uuid.New(), AttachmentPath: &oldKey, AttachmentSHA256: &oldHash}
+
+	expenseRepo.On("GetByID", ctx, expense.ID).Return(expense, nil)
+	expenseRepo.On("ListByAttachmentHash", ctx, contentHash(pngContent)).Return([]*domain.Expense{}, nil)
+	expenseRepo.On("UpdateAttachment", ctx, expense).Return(nil)
+	expenseRepo.On("ListByAttachmentHash", ctx, oldHash).Return([]*domain.Expense{}, nil)
+
+	updated, err := svc.Upload(ctx, expense.ID, &UploadReceiptRequest{FileName: "ticket.png", Content: bytes.NewReader(pngContent)})
+
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", *updated.AttachmentContentType)
+
+	_, _, err = store.Get(ctx, oldKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestExpenseReceiptService_Delete_KeepsObjectStillReferenced(t *testing.T) {
+	svc, expenseRepo, store := newReceiptTestService(t, 0)
+	ctx := context.Background()
+
+	hash := contentHash(pdfContent)
+	key := "expenses/receipts/" + hash
+	require.NoError(t, store.Put(ctx, key, bytes.NewReader(pdfContent), int64(len(pdfContent)), "application/pdf"))
+
+	expense := &domain.Expense{ID: uuid.New(), AttachmentPath: &key, AttachmentSHA256: &hash}
+	deletedAt := time.Now()
+	deleted := &domain.Expense{ID: uuid.New(), AttachmentSHA256: &hash, DeletedAt: &deletedAt}
+
+	expenseRepo.On("GetByID", ctx, expense.ID).Return(expense, nil)
+	expenseRepo.On("UpdateAttachment", ctx, expense).Return(nil)
+	expenseRepo.On("ListByAttachmentHash", ctx, hash).Return([]*domain.Expense{deleted}, nil)
+
+	require.NoError(t, svc.Delete(ctx, expense.ID))
+	assert.False(t, expense.HasAttachment())
+
+	// A deleted expense still references the receipt
+	_, _, err := store.Get(ctx, key)
+	assert.NoError(t, err)
+}
+
+func TestExpenseReceiptService_SignedDownloadURL(t *testing.T) {
+	svc, expenseRepo, store := newReceiptTestService(t, 0)
+	ctx := context.Background()
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	hash := contentHash(pdfContent)
+	key := "expenses/receipts/" + hash
+	fileName, contentType, size := "ticket.pdf", "application/pdf", int64(len(pdfContent))
+	require.NoError(t, store.Put(ctx, key, bytes.NewReader(pdfContent), size, contentType))
+
+	expense := &domain.Expense{
+		ID:                    uuid.New(),
+		AttachmentPath:        &key,
+		AttachmentFileName:    &fileName,
+		AttachmentContentType: &contentType,
+		AttachmentSizeBytes:   &size,
+		AttachmentSHA256:      &hash,
+	}
+	expenseRepo.On("GetByID", mock.Anything, expense.ID).Return(expense, nil)
+
+	receiptURL, err := svc.DownloadURL(ctx, expense.ID)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(5*time.Minute), receiptURL.ExpiresAt)
+	require.True(t, strings.HasPrefix(receiptURL.URL, ExpenseReceiptDownloadPath+expense.ID.String()+"?"))
+
+	parsed, err := url.Parse(receiptURL.URL)
+	require.NoError(t, err)
+	unix, err := strconv.ParseInt(parsed.Query().Get("expires"), 10, 64)
+	require.NoError(t, err)
+	expires := time.Unix(unix, 0)
+	signature := parsed.Query().Get("signature")
+
+	reader, opened, err := svc.Open(ctx, expense.ID, expires, signature)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, pdfContent, data)
+	assert.Equal(t, expense.ID, opened.ID)
+
+	// Tampered and expired links are refused
+	_, _, err = svc.Open(ctx, expense.ID, expires.Add(time.Hour), signature)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, errors.CodeForbidden, appErr.Code)
+
+	now = expires
+	_, _, err = svc.Open(ctx, expense.ID, expires, signature)
+	appErr, ok = err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, "download link has expired", appErr.Message)
+}
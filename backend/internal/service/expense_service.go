@@ -8,37 +8,42 @@ import (
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
 	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 )
 
 // CreateExpenseRequest represents the request to create an expense
 type CreateExpenseRequest struct {
-	ExpenseDate     time.Time  `json:"expenseDate" binding:"required"`
-	SupplierInvoice *string    `json:"supplierInvoice,omitempty"`
-	Supplier        string     `json:"supplier" binding:"required"`
-	Amount          float64    `json:"amount" binding:"required,gt=0"`
-	CategoryID      uuid.UUID  `json:"categoryId" binding:"required"`
-	SubcategoryID   *uuid.UUID `json:"subcategoryId,omitempty"`
-	HasInvoice      bool       `json:"hasInvoice"`
-	AttachmentPath  *string    `json:"attachmentPath,omitempty"`
-	Description     *string    `json:"description,omitempty"`
-	PaymentMethod   *string    `json:"paymentMethod,omitempty"`
-	Notes           *string    `json:"notes,omitempty"`
+	ExpenseDate     time.Time   `json:"expenseDate" binding:"required"`
+	SupplierInvoice *string     `json:"supplierInvoice,omitempty"`
+	Supplier        string      `json:"supplier" binding:"required"`
+	Amount          money.Money `json:"amount"`    // Must be greater than 0
+	VATAmount       money.Money `json:"vatAmount"` // VAT included in the amount
+	VATDeductible   bool        `json:"vatDeductible"`
+	IRPFDeductible  *bool       `json:"irpfDeductible,omitempty"` // Defaults to true
+	CategoryID      uuid.UUID   `json:"categoryId" binding:"required"`
+	SubcategoryID   *uuid.UUID  `json:"subcategoryId,omitempty"`
+	HasInvoice      bool        `json:"hasInvoice"`
+	Description     *string     `json:"description,omitempty"`
+	PaymentMethod   *string     `json:"paymentMethod,omitempty"`
+	Notes           *string     `json:"notes,omitempty"`
 }
 
 // UpdateExpenseRequest represents the request to update an expense
 type UpdateExpenseRequest struct {
-	ExpenseDate     time.Time  `json:"expenseDate" binding:"required"`
-	SupplierInvoice *string    `json:"supplierInvoice,omitempty"`
-	Supplier        string     `json:"supplier" binding:"required"`
-	Amount          float64    `json:"amount" binding:"required,gt=0"`
-	CategoryID      uuid.UUID  `json:"categoryId" binding:"required"`
-	SubcategoryID   *uuid.UUID `json:"subcategoryId,omitempty"`
-	HasInvoice      bool       `json:"hasInvoice"`
-	AttachmentPath  *string    `json:"attachmentPath,omitempty"`
-	Description     *string    `json:"description,omitempty"`
-	PaymentMethod   *string    `json:"paymentMethod,omitempty"`
-	Notes           *string    `json:"notes,omitempty"`
+	ExpenseDate     time.Time   `json:"expenseDate" binding:"required"`
+	SupplierInvoice *string     `json:"supplierInvoice,omitempty"`
+	Supplier        string      `json:"supplier" binding:"required"`
+	Amount          money.Money `json:"amount"` // Must be greater than 0
+	VATAmount       money.Money `json:"vatAmount"`
+	VATDeductible   bool        `json:"vatDeductible"`
+	IRPFDeductible  *bool       `json:"irpfDeductible,omitempty"` // Unchanged when not given
+	CategoryID      uuid.UUID   `json:"categoryId" binding:"required"`
+	SubcategoryID   *uuid.UUID  `json:"subcategoryId,omitempty"`
+	HasInvoice      bool        `json:"hasInvoice"`
+	Description     *string     `json:"description,omitempty"`
+	PaymentMethod   *string     `json:"paymentMethod,omitempty"`
+	Notes           *string     `json:"notes,omitempty"`
 }
 
 // ExpenseService handles expense business logic
@@ -58,6 +63,9 @@ type ExpenseService interface {
 	// DeleteExpense soft deletes an expense
 	DeleteExpense(ctx context.Context, id uuid.UUID) error
 
+	// MarkExpenseReviewed clears the review flag of an expense generated from a recurring expense
+	MarkExpenseReviewed(ctx context.Context, id uuid.UUID) (*domain.Expense, error)
+
 	// GetExpensesByCategory retrieves expenses for a category
 	GetExpensesByCategory(ctx context.Context, categoryID uuid.UUID) ([]*domain.Expense, error)
 
@@ -65,7 +73,7 @@ type ExpenseService interface {
 	GetExpensesBySupplier(ctx context.Context, supplier string) ([]*domain.Expense, error)
 
 	// GetTotalExpenses calculates total expenses in a date range
-	GetTotalExpenses(ctx context.Context, fromDate, toDate time.Time) (float64, error)
+	GetTotalExpenses(ctx context.Context, fromDate, toDate time.Time) (money.Money, error)
 }
 
 type expenseService struct {
@@ -83,36 +91,8 @@ func NewExpenseService(expenseRepo repository.ExpenseRepository, categoryRepo re
 
 // CreateExpense creates a new expense
 func (s *expenseService) CreateExpense(ctx context.Context, req *CreateExpenseRequest) (*domain.Expense, error) {
-	// Validate category exists
-	category, err := s.categoryRepo.GetByID(ctx, req.CategoryID)
-	if err != nil {
-		return nil, errors.NewValidationError("category not found", map[string][]string{
-			"categoryId": {"category does not exist"},
-		})
-	}
-
-	// Validate subcategory if provided
-	if req.SubcategoryID != nil {
-		subcategory, err := s.categoryRepo.GetByID(ctx, *req.SubcategoryID)
-		if err != nil {
-			return nil, errors.NewValidationError("subcategory not found", map[string][]string{
-				"subcategoryId": {"subcategory does not exist"},
-			})
-		}
-
-		// Validate subcategory belongs to the category
-		if subcategory.ParentID == nil || *subcategory.ParentID != req.CategoryID {
-			return nil, errors.NewValidationError("subcategory does not belong to the selected category", map[string][]string{
-				"subcategoryId": {"invalid subcategory for this category"},
-			})
-		}
-	}
-
-	// Validate category is a parent (not a subcategory)
-	if category.IsSubcategory() {
-		return nil, errors.NewValidationError("cannot use a subcategory as the main category", map[string][]string{
-			"categoryId": {"must be a parent category, not a subcategory"},
-		})
+	if err := validateExpenseCategories(ctx, s.categoryRepo, req.CategoryID, req.SubcategoryID); err != nil {
+		return nil, err
 	}
 
 	// Create expense
@@ -122,10 +102,12 @@ func (s *expenseService) CreateExpense(ctx context.Context, req *CreateExpenseRe
 		SupplierInvoice: req.SupplierInvoice,
 		Supplier:        req.Supplier,
 		Amount:          req.Amount,
+		VATAmount:       req.VATAmount,
+		VATDeductible:   req.VATDeductible,
+		IRPFDeductible:  req.IRPFDeductible == nil || *req.IRPFDeductible,
 		CategoryID:      req.CategoryID,
 		SubcategoryID:   req.SubcategoryID,
 		HasInvoice:      req.HasInvoice,
-		AttachmentPath:  req.AttachmentPath,
 		Description:     req.Description,
 		PaymentMethod:   req.PaymentMethod,
 		Notes:           req.Notes,
@@ -164,36 +146,8 @@ func (s *expenseService) UpdateExpense(ctx context.Context, id uuid.UUID, req *U
 		return nil, err
 	}
 
-	// Validate category exists
-	category, err := s.categoryRepo.GetByID(ctx, req.CategoryID)
-	if err != nil {
-		return nil, errors.NewValidationError("category not found", map[string][]string{
-			"categoryId": {"category does not exist"},
-		})
-	}
-
-	// Validate subcategory if provided
-	if req.SubcategoryID != nil {
-		subcategory, err := s.categoryRepo.GetByID(ctx, *req.SubcategoryID)
-		if err != nil {
-			return nil, errors.NewValidationError("subcategory not found", map[string][]string{
-				"subcategoryId": {"subcategory does not exist"},
-			})
-		}
-
-		// Validate subcategory belongs to the category
-		if subcategory.ParentID == nil || *subcategory.ParentID != req.CategoryID {
-			return nil, errors.NewValidationError("subcategory does not belong to the selected category", map[string][]string{
-				"subcategoryId": {"invalid subcategory for this category"},
-			})
-		}
-	}
-
-	// Validate category is a parent
-	if category.IsSubcategory() {
-		return nil, errors.NewValidationError("cannot use a subcategory as the main category", map[string][]string{
-			"categoryId": {"must be a parent category, not a subcategory"},
-		})
+	if err := validateExpenseCategories(ctx, s.categoryRepo, req.CategoryID, req.SubcategoryID); err != nil {
+		return nil, err
 	}
 
 	// Update fields
@@ -201,10 +155,14 @@ func (s *expenseService) UpdateExpense(ctx context.Context, id uuid.UUID, req *U
 	expense.SupplierInvoice = req.SupplierInvoice
 	expense.Supplier = req.Supplier
 	expense.Amount = req.Amount
+	expense.VATAmount = req.VATAmount
+	expense.VATDeductible = req.VATDeductible
+	if req.IRPFDeductible != nil {
+		expense.IRPFDeductible = *req.IRPFDeductible
+	}
 	expense.CategoryID = req.CategoryID
 	expense.SubcategoryID = req.SubcategoryID
 	expense.HasInvoice = req.HasInvoice
-	expense.AttachmentPath = req.AttachmentPath
 	expense.Description = req.Description
 	expense.PaymentMethod = req.PaymentMethod
 	expense.Notes = req.Notes
@@ -234,6 +192,15 @@ func (s *expenseService) DeleteExpense(ctx context.Context, id uuid.UUID) error
 	return s.expenseRepo.Delete(ctx, id)
 }
 
+// MarkExpenseReviewed clears the review flag of an expense generated from a recurring expense
+func (s *expenseService) MarkExpenseReviewed(ctx context.Context, id uuid.UUID) (*domain.Expense, error) {
+	if err := s.expenseRepo.MarkReviewed(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.expenseRepo.GetByID(ctx, id)
+}
+
 // GetExpensesByCategory retrieves expenses for a category
 func (s *expenseService) GetExpensesByCategory(ctx context.Context, categoryID uuid.UUID) ([]*domain.Expense, error) {
 	// Validate category exists
@@ -255,10 +222,48 @@ func (s *expenseService) GetExpensesBySupplier(ctx context.Context, supplier str
 }
 
 // GetTotalExpenses calculates total expenses in a date range
-func (s *expenseService) GetTotalExpenses(ctx context.Context, fromDate, toDate time.Time) (float64, error) {
+func (s *expenseService) GetTotalExpenses(ctx context.Context, fromDate, toDate time.Time) (money.Money, error) {
 	if toDate.Before(fromDate) {
-		return 0, errors.NewValidationError("end date must be after start date", nil)
+		return money.Money{}, errors.NewValidationError("end date must be after start date", nil)
 	}
 
 	return s.expenseRepo.GetTotalByDateRange(ctx, fromDate, toDate)
 }
+
+// validateExpenseCategories checks the category of an expense is a parent category and the
+// subcategory, if any, belongs to it
+func validateExpenseCategories(ctx context.Context, categoryRepo repository.ExpenseCategoryRepository, categoryID uuid.UUID, subcategoryID *uuid.UUID) error {
+	// Validate category exists
+	category, err := categoryRepo.GetByID(ctx, categoryID)
+	if err != nil {
+		return errors.NewValidationError("category not found", map[string][]string{
+			"categoryId": {"category does not exist"},
+		})
+	}
+
+	// Validate subcategory if provided
+	if subcategoryID != nil {
+		subcategory, err := categoryRepo.GetByID(ctx, *subcategoryID)
+		if err != nil {
+			return errors.NewValidationError("subcategory not found", map[string][]string{
+				"subcategoryId": {"subcategory does not exist"},
+			})
+		}
+
+		// Validate subcategory belongs to the category
+		if subcategory.ParentID == nil || *subcategory.ParentID != categoryID {
+			return errors.NewValidationError("subcategory does not belong to the selected category", map[string][]string{
+				"subcategoryId": {"invalid subcategory for this category"},
+			})
+		}
+	}
+
+	// Validate category is a parent (not a subcategory)
+	if category.IsSubcategory() {
+		return errors.NewValidationError("cannot use a subcategory as the main category", map[string][]string{
+			"categoryId": {"must be a parent category, not a subcategory"},
+		})
+	}
+
+	return nil
+}
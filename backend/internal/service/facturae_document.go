@@ -0,0 +1,700 @@
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+)
+
+const (
+	facturaeNamespace     = "http://www.facturae.gob.es/formato/Versiones/Facturaev3_2_2.xml"
+	facturaeSchemaVersion = "3.2.2"
+	facturaeDateLayout    = "2006-01-02"
+
+	facturaeTaxVAT  = "01" // IVA
+	facturaeTaxIRPF = "04" // IRPF
+
+	facturaeUnits        = "01" // Unidades
+	facturaePaymentTrans = "04" // Transferencia
+
+	// Rectifying invoices always correct the taxable base; the reason is detailed in free text
+	facturaeReasonCode        = "16"
+	facturaeReasonDescription = "Base imponible"
+)
+
+var spanishPostCodePattern = regexp.MustCompile(`^[0-9]{5}$`)
+
+// facturaeDocument is a Facturae 3.2.2 file with a single invoice. Only the root element is
+// qualified; the ds:Signature element is appended by the signer.
+type facturaeDocument struct {
+	XMLName    xml.Name           `xml:"fe:Facturae"`
+	Namespace  string             `xml:"xmlns:fe,attr"`
+	FileHeader facturaeFileHeader `xml:"FileHeader"`
+	Parties    facturaeParties    `xml:"Parties"`
+	Invoices   []facturaeInvoice  `xml:"Invoices>Invoice"`
+}
+
+type facturaeFileHeader struct {
+	SchemaVersion     string        `xml:"SchemaVersion"`
+	Modality          string        `xml:"Modality"`          // I: individual invoice
+	InvoiceIssuerType string        `xml:"InvoiceIssuerType"` // EM: issued by the seller
+	Batch             facturaeBatch `xml:"Batch"`
+}
+
+type facturaeBatch struct {
+	BatchIdentifier        string         `xml:"BatchIdentifier"`
+	InvoicesCount          int            `xml:"InvoicesCount"`
+	TotalInvoicesAmount    facturaeAmount `xml:"TotalInvoicesAmount"`
+	TotalOutstandingAmount facturaeAmount `xml:"TotalOutstandingAmount"`
+	TotalExecutableAmount  facturaeAmount `xml:"TotalExecutableAmount"`
+	InvoiceCurrencyCode    string         `xml:"InvoiceCurrencyCode"`
+}
+
+type facturaeAmount struct {
+	TotalAmount string `xml:"TotalAmount"`
+}
+
+type facturaeParties struct {
+	SellerParty facturaeParty `xml:"SellerParty"`
+	BuyerParty  facturaeParty `xml:"BuyerParty"`
+}
+
+type facturaeParty struct {
+	TaxIdentification facturaeTaxIdentification `xml:"TaxIdentification"`
+	LegalEntity       *facturaeLegalEntity      `xml:"LegalEntity"`
+	Individual        *facturaeIndividual       `xml:"Individual"`
+}
+
+type facturaeTaxIdentification struct {
+	PersonTypeCode          string `xml:"PersonTypeCode"`    // F: individual, J: legal entity
+	ResidenceTypeCode       string `xml:"ResidenceTypeCode"` // R: Spain, U: European Union, E: elsewhere
+	TaxIdentificationNumber string `xml:"TaxIdentificationNumber"`
+}
+
+type facturaeLegalEntity struct {
+	CorporateName string `xml:"CorporateName"`
+	TradeName     string `xml:"TradeName,omitempty"`
+	facturaeLocation
+}
+
+type facturaeIndividual struct {
+	Name          string `xml:"Name"`
+	FirstSurname  string `xml:"FirstSurname"`
+	SecondSurname string `xml:"SecondSurname,omitempty"`
+	facturaeLocation
+}
+
+// facturaeLocation holds the address and contact details shared by both kinds of party
+type facturaeLocation struct {
+	AddressInSpain  *facturaeAddress         `xml:"AddressInSpain"`
+	OverseasAddress *facturaeOverseasAddress `xml:"OverseasAddress"`
+	ContactDetails  *facturaeContactDetails  `xml:"ContactDetails"`
+}
+
+type facturaeAddress struct {
+	Address     string `xml:"Address"`
+	PostCode    string `xml:"PostCode"`
+	Town        string `xml:"Town"`
+	Province    string `xml:"Province"`
+	CountryCode string `xml:"CountryCode"`
+}
+
+type facturaeOverseasAddress struct {
+	Address         string `xml:"Address"`
+	PostCodeAndTown string `xml:"PostCodeAndTown"`
+	Province        string `xml:"Province"`
+	CountryCode     string `xml:"CountryCode"`
+}
+
+type facturaeContactDetails struct {
+	Telephone      string `xml:"Telephone,omitempty"`
+	WebAddress     string `xml:"WebAddress,omitempty"`
+	ElectronicMail string `xml:"ElectronicMail,omitempty"`
+}
+
+type facturaeInvoice struct {
+	InvoiceHeader    facturaeInvoiceHeader `xml:"InvoiceHeader"`
+	InvoiceIssueData facturaeIssueData     `xml:"InvoiceIssueData"`
+	TaxesOutputs     []facturaeTax         `xml:"TaxesOutputs>Tax"`
+	TaxesWithheld    *facturaeTaxes        `xml:"TaxesWithheld"`
+	InvoiceTotals    facturaeTotals        `xml:"InvoiceTotals"`
+	Items            []facturaeLine        `xml:"Items>InvoiceLine"`
+	PaymentDetails   *facturaePayment      `xml:"PaymentDetails"`
+	LegalLiterals    *facturaeLiterals     `xml:"LegalLiterals"`
+	AdditionalData   *facturaeAdditional   `xml:"AdditionalData"`
+}
+
+type facturaeInvoiceHeader struct {
+	InvoiceNumber       string              `xml:"InvoiceNumber"`
+	InvoiceDocumentType string              `xml:"InvoiceDocumentType"` // FC: full invoice, FA: simplified
+	InvoiceClass        string              `xml:"InvoiceClass"`        // OO: original, OR: rectifying
+	Corrective          *facturaeCorrective `xml:"Corrective"`
+}
+
+type facturaeCorrective struct {
+	InvoiceNumber               string         `xml:"InvoiceNumber"`
+	ReasonCode                  string         `xml:"ReasonCode"`
+	ReasonDescription           string         `xml:"ReasonDescription"`
+	TaxPeriod                   facturaePeriod `xml:"TaxPeriod"`
+	CorrectionMethod            string         `xml:"CorrectionMethod"`
+	CorrectionMethodDescription string         `xml:"CorrectionMethodDescription"`
+	AdditionalReasonDescription string         `xml:"AdditionalReasonDescription,omitempty"`
+	InvoiceIssueDate            string         `xml:"InvoiceIssueDate"`
+}
+
+type facturaePeriod struct {
+	StartDate string `xml:"StartDate"`
+	EndDate   string `xml:"EndDate"`
+}
+
+type facturaeIssueData struct {
+	IssueDate           string `xml:"IssueDate"`
+	InvoiceCurrencyCode string `xml:"InvoiceCurrencyCode"`
+	TaxCurrencyCode     string `xml:"TaxCurrencyCode"`
+	LanguageName        string `xml:"LanguageName"`
+	InvoiceDescription  string `xml:"InvoiceDescription,omitempty"`
+}
+
+// Optional lists are wrapped in pointers: encoding/xml writes the parent of an a>b
+// path even for an empty slice, and the schema rejects empty containers
+
+type facturaeTaxes struct {
+	Tax []facturaeTax `xml:"Tax"`
+}
+
+type facturaeDiscounts struct {
+	Discount []facturaeDiscount `xml:"Discount"`
+}
+
+type facturaePayment struct {
+	Installment []facturaeInstallment `xml:"Installment"`
+}
+
+type facturaeLiterals struct {
+	LegalReference []string `xml:"LegalReference"`
+}
+
+type facturaeTax struct {
+	TaxTypeCode string         `xml:"TaxTypeCode"`
+	TaxRate     string         `xml:"TaxRate"`
+	TaxableBase facturaeAmount `xml:"TaxableBase"`
+	TaxAmount   facturaeAmount `xml:"TaxAmount"`
+}
+
+type facturaeTotals struct {
+	TotalGrossAmount            string `xml:"TotalGrossAmount"`
+	TotalGrossAmountBeforeTaxes string `xml:"TotalGrossAmountBeforeTaxes"`
+	TotalTaxOutputs             string `xml:"TotalTaxOutputs"`
+	TotalTaxesWithheld          string `xml:"TotalTaxesWithheld"`
+	InvoiceTotal                string `xml:"InvoiceTotal"`
+	TotalOutstandingAmount      string `xml:"TotalOutstandingAmount"`
+	TotalExecutableAmount       string `xml:"TotalExecutableAmount"`
+}
+
+type facturaeLine struct {
+	SequenceNumber                int                `xml:"SequenceNumber"`
+	ItemDescription               string             `xml:"ItemDescription"`
+	Quantity                      string             `xml:"Quantity"`
+	UnitOfMeasure                 string             `xml:"UnitOfMeasure"`
+	UnitPriceWithoutTax           string             `xml:"UnitPriceWithoutTax"`
+	TotalCost                     string             `xml:"TotalCost"`
+	DiscountsAndRebates           *facturaeDiscounts `xml:"DiscountsAndRebates"`
+	GrossAmount                   string             `xml:"GrossAmount"`
+	TaxesOutputs                  []facturaeTax      `xml:"TaxesOutputs>Tax"`
+	AdditionalLineItemInformation string             `xml:"AdditionalLineItemInformation,omitempty"`
+}
+
+type facturaeDiscount struct {
+	DiscountReason string `xml:"DiscountReason"`
+	DiscountRate   string `xml:"DiscountRate"`
+	DiscountAmount string `xml:"DiscountAmount"`
+}
+
+type facturaeInstallment struct {
+	InstallmentDueDate  string          `xml:"InstallmentDueDate"`
+	InstallmentAmount   string          `xml:"InstallmentAmount"`
+	PaymentMeans        string          `xml:"PaymentMeans"`
+	AccountToBeCredited facturaeAccount `xml:"AccountToBeCredited"`
+}
+
+type facturaeAccount struct {
+	IBAN string `xml:"IBAN"`
+}
+
+type facturaeAdditional struct {
+	InvoiceAdditionalInformation string `xml:"InvoiceAdditionalInformation"`
+}
+
+// facturaeBuilder collects the problems found while mapping an invoice, so that they
+// are all reported at once
+type facturaeBuilder struct {
+	problems map[string][]string
+}
+
+// buildFacturae maps an issued invoice, the invoice it rectifies (if any), its client and
+// the clinic's fiscal data to a Facturae document
+func buildFacturae(invoice, rectified *domain.Invoice, client *domain.Client, settings *domain.BillingSettings) (*facturaeDocument, error) {
+	b := &facturaeBuilder{problems: map[string][]string{}}
+
+	seller := b.sellerParty(settings)
+	buyer := b.buyerParty(invoice.Recipient(client), invoice.HasPayer())
+	document := b.invoice(invoice, rectified, settings)
+	batchID := b.text("invoiceNumber", strings.ToUpper(settings.TaxID)+invoice.InvoiceNumber, 70)
+
+	if len(b.problems) > 0 {
+		return nil, errors.NewValidationError("invoice cannot be exported to Facturae", b.problems)
+	}
+
+	total := facturaeAmount{TotalAmount: document.InvoiceTotals.InvoiceTotal}
+	return &facturaeDocument{
+		Namespace: facturaeNamespace,
+		FileHeader: facturaeFileHeader{
+			SchemaVersion:     facturaeSchemaVersion,
+			Modality:          "I",
+			InvoiceIssuerType: "EM",
+			Batch: facturaeBatch{
+				BatchIdentifier:        batchID,
+				InvoicesCount:          1,
+				TotalInvoicesAmount:    total,
+				TotalOutstandingAmount: total,
+				TotalExecutableAmount:  total,
+				InvoiceCurrencyCode:    document.InvoiceIssueData.InvoiceCurrencyCode,
+			},
+		},
+		Parties:  facturaeParties{SellerParty: seller, BuyerParty: buyer},
+		Invoices: []facturaeInvoice{document},
+	}, nil
+}
+
+// marshalFacturae serializes the document with its XML declaration
+func marshalFacturae(document *facturaeDocument) ([]byte, error) {
+	content, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Facturae document: %w", err)
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+// sellerParty maps the clinic as issuer of the invoice
+func (b *facturaeBuilder) sellerParty(settings *domain.BillingSettings) facturaeParty {
+	if settings.TaxID == "" || settings.LegalName == "" {
+		b.problem("settings", "legal name and tax ID of the clinic are required")
+	}
+
+	party := b.taxIdentification("settings.taxId", settings.TaxID, settings.AddressCountry)
+	location := b.location("settings", settings.AddressStreet, settings.AddressPostalCode, settings.AddressCity,
+		settings.AddressProvince, settings.AddressCountry)
+	location.ContactDetails = contactDetails(settings.Phone, settings.Website, settings.Email)
+
+	if party.TaxIdentification.PersonTypeCode == "J" {
+		entity := &facturaeLegalEntity{
+			CorporateName:    b.text("settings.legalName", settings.LegalName, 80),
+			facturaeLocation: location,
+		}
+		if settings.TradeName != settings.LegalName && utf8.RuneCountInString(settings.TradeName) <= 40 {
+			entity.TradeName = settings.TradeName
+		}
+		party.LegalEntity = entity
+		return party
+	}
+
+	// Self-employed professionals sign as individuals: "Name Surname [Surname]"
+	individual, ok := splitIndividualName(settings.LegalName)
+	if !ok {
+		b.problem("settings.legalName", "legal name of a self-employed clinic must include name and surname")
+		return party
+	}
+	individual.facturaeLocation = location
+	b.individualNames("settings.legalName", individual)
+	party.Individual = individual
+	return party
+}
+
+// splitIndividualName splits a full name written as "Name Surname [Surname]"
+func splitIndividualName(fullName string) (*facturaeIndividual, bool) {
+	words := strings.Fields(fullName)
+	if len(words) < 2 {
+		return nil, false
+	}
+	individual := &facturaeIndividual{}
+	if len(words) == 2 {
+		individual.Name, individual.FirstSurname = words[0], words[1]
+	} else {
+		n := len(words)
+		individual.Name = strings.Join(words[:n-2], " ")
+		individual.FirstSurname, individual.SecondSurname = words[n-2], words[n-1]
+	}
+	return individual, true
+}
+
+// buyerParty maps the recipient of the invoice: the client or, when someone else pays, the
+// payer, whose name is not known split in name and surnames
+func (b *facturaeBuilder) buyerParty(recipient domain.InvoiceParty, payer bool) facturaeParty {
+	field, taxField := "client", "client.dniCif"
+	if payer {
+		field, taxField = "payer", "payer.taxId"
+	}
+
+	if strings.TrimSpace(recipient.TaxID) == "" {
+		b.problem(taxField, "the recipient's tax ID is required on e-invoices")
+	}
+
+	party := b.taxIdentification(taxField, recipient.TaxID, recipient.Country)
+	location := b.location(field, recipient.Street, recipient.PostalCode, recipient.City,
+		recipient.Province, recipient.Country)
+	location.ContactDetails = contactDetails(recipient.Phone, "", recipient.Email)
+
+	if party.TaxIdentification.PersonTypeCode == "J" {
+		party.LegalEntity = &facturaeLegalEntity{
+			CorporateName:    b.text(field+".name", recipient.Name, 80),
+			facturaeLocation: location,
+		}
+		return party
+	}
+
+	var individual *facturaeIndividual
+	if surnames := strings.Fields(recipient.LastName); recipient.FirstName != "" && len(surnames) > 0 {
+		individual = &facturaeIndividual{
+			Name:          recipient.FirstName,
+			FirstSurname:  surnames[0],
+			SecondSurname: strings.Join(surnames[1:], " "),
+		}
+	} else if split, ok := splitIndividualName(recipient.Name); ok && payer {
+		individual = split
+	} else {
+		b.problem(field+".name", "first and last name of the recipient are required")
+		return party
+	}
+	individual.facturaeLocation = location
+	b.individualNames(field+".name", individual)
+	party.Individual = individual
+	return party
+}
+
+// taxIdentification classifies a tax ID by person type and residence
+func (b *facturaeBuilder) taxIdentification(field, taxID, country string) facturaeParty {
+	taxID = strings.ToUpper(strings.NewReplacer(" ", "", "-", "", ".", "").Replace(taxID))
+
+	personType := "F"
+	if domain.IsCompanyTaxID(taxID) {
+		personType = "J"
+	}
+
+	residence := "E"
+	if code, ok := facturaeCountry(country); ok {
+		switch {
+		case code == "ESP":
+			residence = "R"
+		case euCountries[code]:
+			residence = "U"
+		}
+	}
+
+	return facturaeParty{TaxIdentification: facturaeTaxIdentification{
+		PersonTypeCode:          personType,
+		ResidenceTypeCode:       residence,
+		TaxIdentificationNumber: b.text(field, taxID, 30),
+	}}
+}
+
+// location maps a postal address: Spanish addresses need a valid postal code, from
+// which the province is taken when it is missing or too long
+func (b *facturaeBuilder) location(field, street, postalCode, city, province, country string) facturaeLocation {
+	street, postalCode, city, province = strings.TrimSpace(street), strings.TrimSpace(postalCode),
+		strings.TrimSpace(city), strings.TrimSpace(province)
+	if street == "" || city == "" {
+		b.problem(field+".address", "street and city are required")
+	}
+
+	code, ok := facturaeCountry(country)
+	if !ok {
+		b.problem(field+".address", fmt.Sprintf("unknown country %q: use its ISO code", country))
+		return facturaeLocation{}
+	}
+
+	if province == "" || utf8.RuneCountInString(province) > 20 {
+		if fromPostCode, ok := spanishProvinces[prefix(postalCode, 2)]; ok && code == "ESP" {
+			province = fromPostCode
+		} else if province == "" {
+			province = city
+		}
+	}
+
+	if code != "ESP" {
+		return facturaeLocation{OverseasAddress: &facturaeOverseasAddress{
+			Address:         b.text(field+".address", street, 80),
+			PostCodeAndTown: b.text(field+".address", strings.TrimSpace(postalCode+" "+city), 50),
+			Province:        b.text(field+".address", province, 20),
+			CountryCode:     code,
+		}}
+	}
+
+	if !spanishPostCodePattern.MatchString(postalCode) {
+		b.problem(field+".address", "a Spanish address needs a 5-digit postal code")
+	}
+	return facturaeLocation{AddressInSpain: &facturaeAddress{
+		Address:     b.text(field+".address", street, 80),
+		PostCode:    postalCode,
+		Town:        b.text(field+".address", city, 50),
+		Province:    b.text(field+".address", province, 20),
+		CountryCode: code,
+	}}
+}
+
+// individualNames checks the length of the names of an individual
+func (b *facturaeBuilder) individualNames(field string, individual *facturaeIndividual) {
+	individual.Name = b.text(field, individual.Name, 40)
+	individual.FirstSurname = b.text(field, individual.FirstSurname, 40)
+	if individual.SecondSurname != "" {
+		individual.SecondSurname = b.text(field, individual.SecondSurname, 40)
+	}
+}
+
+// invoice maps the invoice header, taxes, totals, lines and payment details
+func (b *facturaeBuilder) invoice(invoice, rectified *domain.Invoice, settings *domain.BillingSettings) facturaeInvoice {
+	if invoice.IsDraft() {
+		b.problem("status", "only issued invoices can be exported")
+	}
+	if len(invoice.Lines) == 0 {
+		b.problem("lines", "invoice must have at least one line")
+	}
+
+	header := facturaeInvoiceHeader{
+		InvoiceNumber:       b.text("invoiceNumber", invoice.InvoiceNumber, 20),
+		InvoiceDocumentType: "FC",
+		InvoiceClass:        "OO",
+	}
+	if invoice.IsSimplified() {
+		header.InvoiceDocumentType = "FA"
+	}
+	if invoice.IsRectifying() {
+		header.InvoiceClass = "OR"
+		header.Corrective = b.corrective(invoice, rectified)
+	}
+
+	currency := string(invoice.TotalAmount.Currency())
+	result := facturaeInvoice{
+		InvoiceHeader: header,
+		InvoiceIssueData: facturaeIssueData{
+			IssueDate:           invoice.IssueDate.Format(facturaeDateLayout),
+			InvoiceCurrencyCode: currency,
+			TaxCurrencyCode:     currency,
+			LanguageName:        "es",
+			InvoiceDescription:  truncateRunes(invoice.Description, 2500),
+		},
+		InvoiceTotals: facturaeTotals{
+			TotalGrossAmount:            invoice.BaseAmount.Decimal(),
+			TotalGrossAmountBeforeTaxes: invoice.BaseAmount.Decimal(),
+			TotalTaxOutputs:             invoice.VATAmount.Decimal(),
+			TotalTaxesWithheld:          invoice.IRPFAmount.Decimal(),
+			InvoiceTotal:                invoice.TotalAmount.Decimal(),
+			TotalOutstandingAmount:      invoice.TotalAmount.Decimal(),
+			TotalExecutableAmount:       invoice.TotalAmount.Decimal(),
+		},
+	}
+
+	breakdown, err := invoice.TaxBreakdown()
+	if err != nil {
+		b.problem("lines", err.Error())
+	}
+	var literals []string
+	seen := map[string]bool{}
+	for _, group := range breakdown {
+		result.TaxesOutputs = append(result.TaxesOutputs, vatTax(group.VATRate, group.BaseAmount, group.VATAmount))
+		if group.IsExempt() {
+			mention := group.VATExemption.LegalMention()
+			if !seen[mention] {
+				seen[mention] = true
+				literals = append(literals, truncateRunes(mention, 250))
+			}
+		}
+	}
+	if len(literals) > 0 {
+		result.LegalLiterals = &facturaeLiterals{LegalReference: literals}
+	}
+
+	if invoice.HasWithholding() {
+		result.TaxesWithheld = &facturaeTaxes{Tax: []facturaeTax{{
+			TaxTypeCode: facturaeTaxIRPF,
+			TaxRate:     facturaeRate(invoice.IRPFRate),
+			TaxableBase: facturaeAmount{TotalAmount: invoice.BaseAmount.Decimal()},
+			TaxAmount:   facturaeAmount{TotalAmount: invoice.IRPFAmount.Decimal()},
+		}}}
+	}
+
+	for _, line := range invoice.Lines {
+		result.Items = append(result.Items, b.line(line))
+	}
+
+	// Payment by bank transfer to the clinic's account, unless nothing is owed
+	if iban := strings.ReplaceAll(settings.IBAN, " ", ""); iban != "" && invoice.TotalAmount.IsPositive() {
+		result.PaymentDetails = &facturaePayment{Installment: []facturaeInstallment{{
+			InstallmentDueDate:  invoice.DueDate.Format(facturaeDateLayout),
+			InstallmentAmount:   invoice.TotalAmount.Decimal(),
+			PaymentMeans:        facturaePaymentTrans,
+			AccountToBeCredited: facturaeAccount{IBAN: strings.ToUpper(iban)},
+		}}}
+	}
+
+	if notes := strings.TrimSpace(invoice.Notes); notes != "" {
+		result.AdditionalData = &facturaeAdditional{InvoiceAdditionalInformation: truncateRunes(notes, 2500)}
+	}
+
+	return result
+}
+
+// corrective describes the invoice corrected by a rectifying invoice
+func (b *facturaeBuilder) corrective(invoice, rectified *domain.Invoice) *facturaeCorrective {
+	if rectified == nil {
+		b.problem("rectifiedInvoiceId", "the rectified invoice is required")
+		return nil
+	}
+
+	method, description := "02", "Rectificación por diferencias"
+	if invoice.RectificationMode != nil && *invoice.RectificationMode == domain.RectificationModeCancellation {
+		method, description = "01", "Rectificación íntegra"
+	}
+
+	// The tax period is the month the corrected invoice was issued in
+	issued := rectified.IssueDate
+	start := time.Date(issued.Year(), issued.Month(), 1, 0, 0, 0, 0, issued.Location())
+	return &facturaeCorrective{
+		InvoiceNumber:     b.text("rectifiedInvoiceId", rectified.InvoiceNumber, 20),
+		ReasonCode:        facturaeReasonCode,
+		ReasonDescription: facturaeReasonDescription,
+		TaxPeriod: facturaePeriod{
+			StartDate: start.Format(facturaeDateLayout),
+			EndDate:   start.AddDate(0, 1, -1).Format(facturaeDateLayout),
+		},
+		CorrectionMethod:            method,
+		CorrectionMethodDescription: description,
+		AdditionalReasonDescription: truncateRunes(invoice.RectificationReason, 2500),
+		InvoiceIssueDate:            issued.Format(facturaeDateLayout),
+	}
+}
+
+// line maps an invoice line; the discount is the difference between the cost and the base
+func (b *facturaeBuilder) line(line *domain.InvoiceLine) facturaeLine {
+	totalCost := line.UnitPrice.Mul(line.Quantity)
+	result := facturaeLine{
+		SequenceNumber:      line.Position,
+		ItemDescription:     truncateRunes(line.Description, 2500),
+		Quantity:            strconv.FormatFloat(line.Quantity, 'f', -1, 64),
+		UnitOfMeasure:       facturaeUnits,
+		UnitPriceWithoutTax: line.UnitPrice.Decimal(),
+		TotalCost:           totalCost.Decimal(),
+		GrossAmount:         line.BaseAmount.Decimal(),
+		TaxesOutputs:        []facturaeTax{vatTax(line.VATRate, line.BaseAmount, line.VATAmount)},
+	}
+
+	if line.DiscountPercent > 0 {
+		discount, err := totalCost.Sub(line.BaseAmount)
+		if err != nil {
+			b.problem("lines", err.Error())
+		}
+		result.DiscountsAndRebates = &facturaeDiscounts{Discount: []facturaeDiscount{{
+			DiscountReason: "Descuento",
+			DiscountRate:   strconv.FormatFloat(line.DiscountPercent, 'f', 4, 64),
+			DiscountAmount: discount.Decimal(),
+		}}}
+	}
+
+	if line.IsExempt() {
+		result.AdditionalLineItemInformation = line.VATExemption.LegalMention()
+	}
+
+	return result
+}
+
+// text checks that a required text fits in max characters
+func (b *facturaeBuilder) text(field, value string, max int) string {
+	value = strings.TrimSpace(value)
+	if n := utf8.RuneCountInString(value); n > max {
+		b.problem(field, fmt.Sprintf("%q exceeds the %d characters allowed", value, max))
+	}
+	return value
+}
+
+func (b *facturaeBuilder) problem(field, message string) {
+	b.problems[field] = append(b.problems[field], message)
+}
+
+// vatTax returns the VAT of a base at a rate
+func vatTax(rate float64, base, quota money.Money) facturaeTax {
+	return facturaeTax{
+		TaxTypeCode: facturaeTaxVAT,
+		TaxRate:     facturaeRate(rate),
+		TaxableBase: facturaeAmount{TotalAmount: base.Decimal()},
+		TaxAmount:   facturaeAmount{TotalAmount: quota.Decimal()},
+	}
+}
+
+// contactDetails returns the contact details that fit in the format, or nil when there are none
+func contactDetails(phone, website, email string) *facturaeContactDetails {
+	details := &facturaeContactDetails{}
+	if phone = strings.TrimSpace(phone); utf8.RuneCountInString(phone) <= 15 {
+		details.Telephone = phone
+	}
+	if website = strings.TrimSpace(website); utf8.RuneCountInString(website) <= 60 {
+		details.WebAddress = website
+	}
+	if email = strings.TrimSpace(email); len(email) >= 3 && utf8.RuneCountInString(email) <= 60 {
+		details.ElectronicMail = email
+	}
+
+	if *details == (facturaeContactDetails{}) {
+		return nil
+	}
+	return details
+}
+
+// facturaeCountry returns the alpha-3 code of a country given by its ISO code or, for Spain,
+// by its name; an empty country is Spain
+func facturaeCountry(country string) (string, bool) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	switch country {
+	case "", "ESPAÑA", "ESPANA", "SPAIN":
+		return "ESP", true
+	}
+
+	if len(country) == 2 {
+		code, ok := countryAlpha3[country]
+		return code, ok
+	}
+	for _, code := range countryAlpha3 {
+		if code == country {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// facturaeRate formats a percentage with two decimals
+func facturaeRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', 2, 64)
+}
+
+// prefix returns the first n bytes of s, or s when it is shorter
+func prefix(s string, n int) string {
+	if len(s) < n {
+		return s
+	}
+	return s[:n]
+}
+
+// truncateRunes shortens s to at most max characters
+func truncateRunes(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
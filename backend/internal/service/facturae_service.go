@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// InvoiceFacturae is an invoice exported as a Facturae e-invoice
+type InvoiceFacturae struct {
+	FileName string
+	Content  []byte
+	Signed   bool
+}
+
+// FacturaeService exports issued invoices in the Facturae format required by public bodies
+// and some corporate clients
+type FacturaeService interface {
+	// GetInvoiceFacturae builds the Facturae 3.2.2 XML of an issued invoice, signed when
+	// a certificate is configured
+	GetInvoiceFacturae(ctx context.Context, invoiceID uuid.UUID) (*InvoiceFacturae, error)
+}
+
+type facturaeService struct {
+	invoiceRepo  repository.InvoiceRepository
+	clientRepo   repository.ClientRepository
+	settingsRepo repository.BillingSettingsRepository
+	signer       *FacturaeSigner
+	now          func() time.Time
+}
+
+// NewFacturaeService creates a new Facturae service; documents are left unsigned when signer is nil
+func NewFacturaeService(
+	invoiceRepo repository.InvoiceRepository,
+	clientRepo repository.ClientRepository,
+	settingsRepo repository.BillingSettingsRepository,
+	signer *FacturaeSigner,
+) FacturaeService {
+	return &facturaeService{
+		invoiceRepo:  invoiceRepo,
+		clientRepo:   clientRepo,
+		settingsRepo: settingsRepo,
+		signer:       signer,
+		now:          time.Now,
+	}
+}
+
+// GetInvoiceFacturae builds the Facturae XML of an issued invoice
+func (s *facturaeService) GetInvoiceFacturae(ctx context.Context, invoiceID uuid.UUID) (*InvoiceFacturae, error) {
+	invoice, err := s.invoiceRepo.GetByID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := invoiceClient(ctx, s.clientRepo, invoice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice client: %w", err)
+	}
+
+	settings, err := s.settingsRepo.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing settings: %w", err)
+	}
+
+	var rectified *domain.Invoice
+	if invoice.IsRectifying() && invoice.RectifiedInvoiceID != nil {
+		if rectified, err = s.invoiceRepo.GetByID(ctx, *invoice.RectifiedInvoiceID); err != nil {
+			return nil, fmt.Errorf("failed to get rectified invoice: %w", err)
+		}
+	}
+
+	document, err := buildFacturae(invoice, rectified, client, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := marshalFacturae(document)
+	if err != nil {
+		return nil, err
+	}
+
+	name := "Factura_" + strings.NewReplacer("/", "-", " ", "_").Replace(invoice.InvoiceNumber)
+	if s.signer == nil {
+		return &InvoiceFacturae{FileName: name + ".xml", Content: content}, nil
+	}
+
+	signed, err := s.signer.Sign(content, s.now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign Facturae document: %w", err)
+	}
+
+	// Signed Facturae files use the .xsig extension
+	return &InvoiceFacturae{FileName: name + ".xsig", Content: signed, Signed: true}, nil
+}
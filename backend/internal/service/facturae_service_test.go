@@ -0,0 +1,471 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/c14n"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	facturaeTestSchema       = "testdata/facturae/Facturaev3_2_2.xsd"
+	facturaeTestCertificate  = "testdata/facturae/test-cert.p12"
+	facturaeTestCertPassword = "facturae"
+)
+
+func newFacturaeTestService(signer *FacturaeSigner) (*facturaeService, *MockInvoiceRepository, *MockClientRepository, *MockBillingSettingsRepository) {
+	invoiceRepo := new(MockInvoiceRepository)
+	clientRepo := new(MockClientRepository)
+	settingsRepo := new(MockBillingSettingsRepository)
+
+	svc := NewFacturaeService(invoiceRepo, clientRepo, settingsRepo, signer).(*facturaeService)
+	svc.now = func() time.Time { return time.Date(2025, 3, 2, 10, 0, 0, 0, time.UTC) }
+	return svc, invoiceRepo, clientRepo, settingsRepo
+}
+
+func facturaeTestSigner(t *testing.T) *FacturaeSigner {
+	t.Helper()
+
+	p12, err := os.ReadFile(facturaeTestCertificate)
+	require.NoError(t, err)
+	signer, err := NewFacturaeSigner(p12, facturaeTestCertPassword)
+	require.NoError(t, err)
+	return signer
+}
+
+func facturaeTestBusinessClient(id uuid.UUID) *domain.Client {
+	return &domain.Client{
+		ID:                id,
+		FirstName:         "Recursos Humanos",
+		LastName:          "Ejemplo S.A.",
+		Email:             "rrhh@ejemplo.es",
+		DNICIF:            "A87654321",
+		AddressStreet:     "Calle de Alcalá 10",
+		AddressCity:       "Madrid",
+		AddressPostalCode: "28014",
+	}
+}
+
+func facturaeTestIndividualClient(id uuid.UUID) *domain.Client {
+	return &domain.Client{
+		ID:                id,
+		FirstName:         "María José",
+		LastName:          "García López",
+		DNICIF:            "12345678Z",
+		AddressStreet:     "Avenida del Puerto 3",
+		AddressCity:       "Valencia",
+		AddressProvince:   "Valencia",
+		AddressPostalCode: "46021",
+		AddressCountry:    "España",
+	}
+}
+
+// requireValidFacturae validates a document against the Facturae schema with xmllint
+func requireValidFacturae(t *testing.T, content []byte) {
+	t.Helper()
+
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Log("xmllint not found, skipping schema validation")
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "factura.xml")
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+
+	out, err := exec.Command(xmllint, "--noout", "--nonet", "--schema", facturaeTestSchema, path).CombinedOutput()
+	require.NoError(t, err, "document does not match the Facturae schema:\n%s\n%s", out, content)
+}
+
+func TestBuildFacturae_OrdinaryInvoice(t *testing.T) {
+	client := facturaeTestBusinessClient(uuid.New())
+	invoice := pdfTestInvoice(client.ID)
+	invoice.Lines[0].DiscountPercent = 10
+	require.NoError(t, invoice.CalculateAmounts())
+
+	document, err := buildFacturae(invoice, nil, client, pdfTestSettings())
+	require.NoError(t, err)
+
+	header := document.FileHeader
+	assert.Equal(t, "3.2.2", header.SchemaVersion)
+	assert.Equal(t, "B12345678F2025-0042", header.Batch.BatchIdentifier)
+	assert.Equal(t, invoice.TotalAmount.Decimal(), header.Batch.TotalExecutableAmount.TotalAmount)
+	assert.Equal(t, "EUR", header.Batch.InvoiceCurrencyCode)
+
+	seller := document.Parties.SellerParty
+	assert.Equal(t, facturaeTaxIdentification{PersonTypeCode: "J", ResidenceTypeCode: "R", TaxIdentificationNumber: "B12345678"}, seller.TaxIdentification)
+	require.NotNil(t, seller.LegalEntity)
+	assert.Equal(t, "Arnela Psicología S.L.", seller.LegalEntity.CorporateName)
+	assert.Equal(t, &facturaeAddress{Address: "Calle Mayor 1", PostCode: "28013", Town: "Madrid", Province: "Madrid", CountryCode: "ESP"},
+		seller.LegalEntity.AddressInSpain)
+
+	buyer := document.Parties.BuyerParty
+	assert.Equal(t, "J", buyer.TaxIdentification.PersonTypeCode)
+	require.NotNil(t, buyer.LegalEntity)
+	assert.Equal(t, "Recursos Humanos Ejemplo S.A.", buyer.LegalEntity.CorporateName)
+	assert.Equal(t, "Madrid", buyer.LegalEntity.AddressInSpain.Province, "province is taken from the postal code")
+	assert.Equal(t, "rrhh@ejemplo.es", buyer.LegalEntity.ContactDetails.ElectronicMail)
+
+	require.Len(t, document.Invoices, 1)
+	doc := document.Invoices[0]
+	assert.Equal(t, facturaeInvoiceHeader{InvoiceNumber: "F2025-0042", InvoiceDocumentType: "FC", InvoiceClass: "OO"}, doc.InvoiceHeader)
+	assert.Equal(t, "2025-03-01", doc.InvoiceIssueData.IssueDate)
+
+	// One VAT entry per rate, the exempt one with its legal mention
+	require.Len(t, doc.TaxesOutputs, 2)
+	assert.Equal(t, vatTax(21, money.MustParse("120"), money.MustParse("25.20")), doc.TaxesOutputs[0])
+	assert.Equal(t, vatTax(0, money.MustParse("216"), money.MustParse("0")), doc.TaxesOutputs[1])
+	require.NotNil(t, doc.LegalLiterals)
+	assert.Equal(t, []string{domain.VATExemptArticle20.LegalMention()}, doc.LegalLiterals.LegalReference)
+
+	require.NotNil(t, doc.TaxesWithheld)
+	require.Len(t, doc.TaxesWithheld.Tax, 1)
+	withheld := doc.TaxesWithheld.Tax[0]
+	assert.Equal(t, "04", withheld.TaxTypeCode)
+	assert.Equal(t, "15.00", withheld.TaxRate)
+	assert.Equal(t, "50.40", withheld.TaxAmount.TotalAmount)
+
+	// 336 base + 25.20 VAT - 50.40 IRPF
+	assert.Equal(t, facturaeTotals{
+		TotalGrossAmount:            "336.00",
+		TotalGrossAmountBeforeTaxes: "336.00",
+		TotalTaxOutputs:             "25.20",
+		TotalTaxesWithheld:          "50.40",
+		InvoiceTotal:                "310.80",
+		TotalOutstandingAmount:      "310.80",
+		TotalExecutableAmount:       "310.80",
+	}, doc.InvoiceTotals)
+
+	require.Len(t, doc.Items, 2)
+	line := doc.Items[0]
+	assert.Equal(t, "4", line.Quantity)
+	assert.Equal(t, "60.00", line.UnitPriceWithoutTax)
+	assert.Equal(t, "240.00", line.TotalCost)
+	require.NotNil(t, line.DiscountsAndRebates)
+	assert.Equal(t, []facturaeDiscount{{DiscountReason: "Descuento", DiscountRate: "10.0000", DiscountAmount: "24.00"}}, line.DiscountsAndRebates.Discount)
+	assert.Nil(t, doc.Items[1].DiscountsAndRebates)
+	assert.Equal(t, "216.00", line.GrossAmount)
+
+	require.NotNil(t, doc.PaymentDetails)
+	require.Len(t, doc.PaymentDetails.Installment, 1)
+	assert.Equal(t, facturaeInstallment{
+		InstallmentDueDate:  "2025-03-31",
+		InstallmentAmount:   "310.80",
+		PaymentMeans:        "04",
+		AccountToBeCredited: facturaeAccount{IBAN: "ES9121000418450200051332"},
+	}, doc.PaymentDetails.Installment[0])
+}
+
+func TestBuildFacturae_RectifyingInvoice(t *testing.T) {
+	client := facturaeTestIndividualClient(uuid.New())
+	original := issuedTestInvoice(t)
+	original.ClientID = client.ID
+
+	exempt := domain.VATExemptArticle20
+	mode := domain.RectificationModeDifference
+	rectifying := &domain.Invoice{
+		ID:                  uuid.New(),
+		InvoiceNumber:       "R_2025_0001",
+		ClientID:            client.ID,
+		IssueDate:           time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC),
+		DueDate:             time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC),
+		Status:              domain.InvoiceStatusUnpaid,
+		InvoiceType:         domain.InvoiceTypeRectifying,
+		RectifiedInvoiceID:  &original.ID,
+		RectificationMode:   &mode,
+		RectificationReason: "Una sesión no se realizó",
+		Lines: []*domain.InvoiceLine{
+			{Description: "Sesión individual", Quantity: -1, UnitPrice: money.MustParse("60"), VATExemption: &exempt},
+		},
+	}
+	require.NoError(t, rectifying.CalculateAmounts())
+
+	document, err := buildFacturae(rectifying, original, client, pdfTestSettings())
+	require.NoError(t, err)
+
+	buyer := document.Parties.BuyerParty
+	assert.Equal(t, "F", buyer.TaxIdentification.PersonTypeCode)
+	require.NotNil(t, buyer.Individual)
+	assert.Equal(t, "María José", buyer.Individual.Name)
+	assert.Equal(t, "García", buyer.Individual.FirstSurname)
+	assert.Equal(t, "López", buyer.Individual.SecondSurname)
+
+	doc := document.Invoices[0]
+	assert.Equal(t, "OR", doc.InvoiceHeader.InvoiceClass)
+	assert.Equal(t, &facturaeCorrective{
+		InvoiceNumber:               "F_2025_0010",
+		ReasonCode:                  "16",
+		ReasonDescription:           "Base imponible",
+		TaxPeriod:                   facturaePeriod{StartDate: "2025-03-01", EndDate: "2025-03-31"},
+		CorrectionMethod:            "02",
+		CorrectionMethodDescription: "Rectificación por diferencias",
+		AdditionalReasonDescription: "Una sesión no se realizó",
+		InvoiceIssueDate:            "2025-03-01",
+	}, doc.InvoiceHeader.Corrective)
+	assert.Equal(t, "-60.00", doc.InvoiceTotals.InvoiceTotal)
+	assert.Nil(t, doc.TaxesWithheld)
+	assert.Nil(t, doc.PaymentDetails, "nothing is owed on a negative invoice")
+}
+
+func TestBuildFacturae_ReportsMissingData(t *testing.T) {
+	client := &domain.Client{ID: uuid.New(), FirstName: "Ana"}
+	invoice := pdfTestInvoice(client.ID)
+	settings := pdfTestSettings()
+	settings.AddressPostalCode = "280"
+	settings.AddressCountry = "Narnia"
+
+	_, err := buildFacturae(invoice, nil, client, settings)
+	requireValidationError(t, err)
+	details := err.(*errors.AppError).Details
+	assert.Contains(t, details, "client.dniCif")
+	assert.Contains(t, details, "client.name")
+	assert.Contains(t, details, "client.address")
+	assert.Contains(t, details, "settings.address")
+}
+
+func TestBuildFacturae_OverseasClient(t *testing.T) {
+	client := facturaeTestIndividualClient(uuid.New())
+	client.DNICIF = "FR40303265045"
+	client.AddressStreet = "12 rue de Rivoli"
+	client.AddressPostalCode = "75001"
+	client.AddressCity = "Paris"
+	client.AddressProvince = ""
+	client.AddressCountry = "fr"
+
+	document, err := buildFacturae(pdfTestInvoice(client.ID), nil, client, pdfTestSettings())
+	require.NoError(t, err)
+
+	buyer := document.Parties.BuyerParty
+	assert.Equal(t, "U", buyer.TaxIdentification.ResidenceTypeCode)
+	assert.Nil(t, buyer.Individual.AddressInSpain)
+	assert.Equal(t, &facturaeOverseasAddress{Address: "12 rue de Rivoli", PostCodeAndTown: "75001 Paris", Province: "Paris", CountryCode: "FRA"},
+		buyer.Individual.OverseasAddress)
+}
+
+func TestFacturaeService_GetInvoiceFacturae_Unsigned(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, clientRepo, settingsRepo := newFacturaeTestService(nil)
+
+	client := facturaeTestBusinessClient(uuid.New())
+	invoice := pdfTestInvoice(client.ID)
+	invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+	clientRepo.On("GetByID", ctx, client.ID).Return(client, nil)
+	settingsRepo.On("Get", ctx).Return(pdfTestSettings(), nil)
+
+	result, err := svc.GetInvoiceFacturae(ctx, invoice.ID)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Factura_F2025-0042.xml", result.FileName)
+	assert.False(t, result.Signed)
+	assert.True(t, bytes.HasPrefix(result.Content, []byte(xml.Header)))
+	assert.NotContains(t, string(result.Content), "ds:Signature")
+	requireValidFacturae(t, result.Content)
+}
+
+func TestFacturaeService_GetInvoiceFacturae_RectifyingIsValid(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, clientRepo, settingsRepo := newFacturaeTestService(nil)
+
+	client := facturaeTestIndividualClient(uuid.New())
+	original := issuedTestInvoice(t)
+	mode := domain.RectificationModeCancellation
+	rectifying := &domain.Invoice{
+		ID:                  uuid.New(),
+		InvoiceNumber:       "R_2025_0002",
+		ClientID:            client.ID,
+		IssueDate:           time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC),
+		DueDate:             time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC),
+		Status:              domain.InvoiceStatusUnpaid,
+		InvoiceType:         domain.InvoiceTypeRectifying,
+		RectifiedInvoiceID:  &original.ID,
+		RectificationMode:   &mode,
+		RectificationReason: "Factura emitida por error",
+	}
+	for _, line := range original.Lines {
+		rectifying.Lines = append(rectifying.Lines, line.Negated())
+	}
+	require.NoError(t, rectifying.CalculateAmounts())
+
+	invoiceRepo.On("GetByID", ctx, rectifying.ID).Return(rectifying, nil)
+	invoiceRepo.On("GetByID", ctx, original.ID).Return(original, nil)
+	clientRepo.On("GetByID", ctx, client.ID).Return(client, nil)
+	settingsRepo.On("Get", ctx).Return(pdfTestSettings(), nil)
+
+	result, err := svc.GetInvoiceFacturae(ctx, rectifying.ID)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(result.Content), "<CorrectionMethodDescription>Rectificación íntegra</CorrectionMethodDescription>")
+	requireValidFacturae(t, result.Content)
+}
+
+func TestFacturaeService_GetInvoiceFacturae_RefusesDrafts(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, clientRepo, settingsRepo := newFacturaeTestService(nil)
+
+	client := facturaeTestBusinessClient(uuid.New())
+	invoice := pdfTestInvoice(client.ID)
+	invoice.Status = domain.InvoiceStatusDraft
+	invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+	clientRepo.On("GetByID", ctx, client.ID).Return(client, nil)
+	settingsRepo.On("Get", ctx).Return(pdfTestSettings(), nil)
+
+	_, err := svc.GetInvoiceFacturae(ctx, invoice.ID)
+	requireValidationError(t, err)
+}
+
+func TestFacturaeService_GetInvoiceFacturae_Signed(t *testing.T) {
+	ctx := context.Background()
+	signer := facturaeTestSigner(t)
+	svc, invoiceRepo, clientRepo, settingsRepo := newFacturaeTestService(signer)
+
+	client := facturaeTestBusinessClient(uuid.New())
+	invoice := pdfTestInvoice(client.ID)
+	invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+	clientRepo.On("GetByID", ctx, client.ID).Return(client, nil)
+	settingsRepo.On("Get", ctx).Return(pdfTestSettings(), nil)
+
+	result, err := svc.GetInvoiceFacturae(ctx, invoice.ID)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Factura_F2025-0042.xsig", result.FileName)
+	assert.True(t, result.Signed)
+	assert.Contains(t, string(result.Content), "<xades:SigningTime>2025-03-02T10:00:00Z</xades:SigningTime>")
+	assert.Contains(t, string(result.Content), facturaePolicyDigest)
+	requireValidFacturae(t, result.Content)
+
+	require.NoError(t, verifyFacturaeSignature(result.Content, signer.Certificate().PublicKey.(*rsa.PublicKey)))
+
+	t.Run("tampering breaks the signature", func(t *testing.T) {
+		total := "<InvoiceTotal>" + invoice.TotalAmount.Decimal() + "</InvoiceTotal>"
+		tampered := bytes.Replace(result.Content, []byte(total), []byte("<InvoiceTotal>1.00</InvoiceTotal>"), 1)
+		require.NotEqual(t, result.Content, tampered)
+		assert.Error(t, verifyFacturaeSignature(tampered, signer.Certificate().PublicKey.(*rsa.PublicKey)))
+	})
+
+	t.Run("canonical form matches xmllint", func(t *testing.T) {
+		xmllint, err := exec.LookPath("xmllint")
+		if err != nil {
+			t.Skip("xmllint not found")
+		}
+		path := filepath.Join(t.TempDir(), "factura.xsig")
+		require.NoError(t, os.WriteFile(path, result.Content, 0o600))
+
+		expected, err := exec.Command(xmllint, "--c14n", path).Output()
+		require.NoError(t, err)
+		canonical, err := c14n.Canonicalize(result.Content, "")
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(canonical))
+	})
+}
+
+func TestNewFacturaeSigner_RejectsInvalidCertificates(t *testing.T) {
+	p12, err := os.ReadFile(facturaeTestCertificate)
+	require.NoError(t, err)
+
+	_, err = NewFacturaeSigner(p12, "wrong password")
+	assert.Error(t, err)
+
+	_, err = NewFacturaeSigner([]byte("not a certificate"), facturaeTestCertPassword)
+	assert.Error(t, err)
+}
+
+var facturaeSignaturePattern = regexp.MustCompile(`(?s)<ds:Signature .*</ds:Signature>`)
+
+// verifyFacturaeSignature checks the signature value and the digest of every reference
+func verifyFacturaeSignature(content []byte, key *rsa.PublicKey) error {
+	var document struct {
+		Signature struct {
+			SignedInfo struct {
+				ID         string `xml:"Id,attr"`
+				References []struct {
+					URI         string `xml:"URI,attr"`
+					DigestValue string `xml:"DigestValue"`
+				} `xml:"Reference"`
+			} `xml:"SignedInfo"`
+			SignatureValue string `xml:"SignatureValue"`
+		} `xml:"Signature"`
+	}
+	if err := xml.Unmarshal(content, &document); err != nil {
+		return err
+	}
+	signature := document.Signature
+
+	for _, reference := range signature.SignedInfo.References {
+		var canonical []byte
+		var err error
+		if reference.URI == "" {
+			// Enveloped signature transform
+			canonical, err = c14n.Canonicalize(facturaeSignaturePattern.ReplaceAll(content, nil), "")
+		} else {
+			canonical, err = c14n.Canonicalize(content, reference.URI[1:])
+		}
+		if err != nil {
+			return err
+		}
+		if digest(canonical) != reference.DigestValue {
+			return assert.AnError
+		}
+	}
+
+	signedInfo, err := c14n.Canonicalize(content, signature.SignedInfo.ID)
+	if err != nil {
+		return err
+	}
+	value, err := base64.StdEncoding.DecodeString(signature.SignatureValue)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(signedInfo)
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], value)
+}
+
+func TestBuildFacturae_PayerIsTheBuyer(t *testing.T) {
+	client := facturaeTestIndividualClient(uuid.New())
+	invoice := pdfTestInvoice(client.ID)
+
+	t.Run("company", func(t *testing.T) {
+		invoice.SetPayer(testBillingProfile())
+
+		document, err := buildFacturae(invoice, nil, client, pdfTestSettings())
+		require.NoError(t, err)
+
+		buyer := document.Parties.BuyerParty
+		assert.Equal(t, "J", buyer.TaxIdentification.PersonTypeCode)
+		assert.Equal(t, "B12345678", buyer.TaxIdentification.TaxIdentificationNumber)
+		require.NotNil(t, buyer.LegalEntity)
+		assert.Equal(t, "Construcciones Norte SL", buyer.LegalEntity.CorporateName)
+		assert.Nil(t, buyer.Individual)
+	})
+
+	t.Run("parent", func(t *testing.T) {
+		parent := testBillingProfile()
+		parent.Name, parent.TaxID = "Ana Belén Ruiz Soto", "12345678Z"
+		invoice.SetPayer(parent)
+
+		document, err := buildFacturae(invoice, nil, client, pdfTestSettings())
+		require.NoError(t, err)
+
+		buyer := document.Parties.BuyerParty
+		require.NotNil(t, buyer.Individual)
+		assert.Equal(t, "Ana Belén", buyer.Individual.Name)
+		assert.Equal(t, "Ruiz", buyer.Individual.FirstSurname)
+		assert.Equal(t, "Soto", buyer.Individual.SecondSurname)
+	})
+}
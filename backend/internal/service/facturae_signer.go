@@ -0,0 +1,345 @@
+package service
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/c14n"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/pkcs12"
+)
+
+// Algorithms and policy of Facturae signatures (XAdES-EPES, enveloped)
+const (
+	xmldsigNamespace = "http://www.w3.org/2000/09/xmldsig#"
+	xadesNamespace   = "http://uri.etsi.org/01903/v1.3.2#"
+
+	signatureMethodRSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	digestMethodSHA256       = "http://www.w3.org/2001/04/xmlenc#sha256"
+	digestMethodSHA1         = "http://www.w3.org/2000/09/xmldsig#sha1"
+	transformEnveloped       = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	signedPropertiesType     = "http://uri.etsi.org/01903#SignedProperties"
+
+	facturaePolicyURL         = "http://www.facturae.es/politica_de_firma_formato_facturae/politica_de_firma_formato_facturae_v3_1.pdf"
+	facturaePolicyDescription = "Política de Firma FacturaE v3.1"
+	facturaePolicyDigest      = "Ohixl6upD6av8N7pEvDABhEL6hM=" // SHA-1 of the policy document
+)
+
+// FacturaeSigner signs Facturae documents on behalf of the issuer with a qualified certificate
+type FacturaeSigner struct {
+	key   *rsa.PrivateKey
+	chain []*x509.Certificate // Signing certificate first
+}
+
+// NewFacturaeSigner loads the RSA key and certificates of a PKCS#12 (.p12/.pfx) file. Files must
+// use the legacy 3DES or RC2 ciphers; newer ones can be converted with openssl pkcs12 -legacy.
+func NewFacturaeSigner(p12 []byte, password string) (*FacturaeSigner, error) {
+	blocks, err := pkcs12.ToPEM(p12, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PKCS#12 certificate: %w", err)
+	}
+
+	signer := &FacturaeSigner{}
+	var others []*x509.Certificate
+	for _, block := range blocks {
+		switch block.Type {
+		case "PRIVATE KEY":
+			if signer.key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				return nil, fmt.Errorf("only RSA keys can sign Facturae documents: %w", err)
+			}
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate: %w", err)
+			}
+			others = append(others, cert)
+		}
+	}
+	if signer.key == nil {
+		return nil, fmt.Errorf("PKCS#12 file has no private key")
+	}
+
+	for i, cert := range others {
+		if signer.key.PublicKey.Equal(cert.PublicKey) {
+			signer.chain = append([]*x509.Certificate{cert}, append(others[:i:i], others[i+1:]...)...)
+			break
+		}
+	}
+	if signer.chain == nil {
+		return nil, fmt.Errorf("PKCS#12 file has no certificate for its private key")
+	}
+
+	if now := time.Now(); now.After(signer.chain[0].NotAfter) {
+		return nil, fmt.Errorf("certificate expired on %s", signer.chain[0].NotAfter.Format("2006-01-02"))
+	}
+
+	return signer, nil
+}
+
+// Certificate returns the signing certificate
+func (s *FacturaeSigner) Certificate() *x509.Certificate {
+	return s.chain[0]
+}
+
+// Sign appends an enveloped XAdES-EPES signature, under the Facturae signature policy, as
+// the last child of the document element. Every reference is canonicalized with C14N 1.0.
+func (s *FacturaeSigner) Sign(document []byte, signingTime time.Time) ([]byte, error) {
+	end := bytes.LastIndex(document, []byte("</fe:Facturae>"))
+	if end < 0 {
+		return nil, fmt.Errorf("not a Facturae document")
+	}
+
+	// The document digest excludes the signature, which is inserted with no surrounding text
+	canonical, err := c14n.Canonicalize(document, "")
+	if err != nil {
+		return nil, err
+	}
+
+	signature := s.newSignature("Signature-"+uuid.New().String(), signingTime, digest(canonical))
+	insert := func() ([]byte, error) {
+		content, err := xml.Marshal(signature)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal signature: %w", err)
+		}
+		signed := make([]byte, 0, len(document)+len(content))
+		signed = append(signed, document[:end]...)
+		signed = append(signed, content...)
+		return append(signed, document[end:]...), nil
+	}
+
+	// Digest the key info and the signed properties where they sit in the document
+	signed, err := insert()
+	if err != nil {
+		return nil, err
+	}
+	references := signature.SignedInfo.References
+	for i := 1; i < len(references); i++ {
+		canonical, err := c14n.Canonicalize(signed, references[i].URI[1:])
+		if err != nil {
+			return nil, err
+		}
+		references[i].DigestValue = digest(canonical)
+	}
+
+	if signed, err = insert(); err != nil {
+		return nil, err
+	}
+	canonical, err = c14n.Canonicalize(signed, signature.SignedInfo.ID)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(canonical)
+	value, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign document: %w", err)
+	}
+	signature.SignatureValue.Value = base64.StdEncoding.EncodeToString(value)
+
+	return insert()
+}
+
+// newSignature builds the signature with the document digest; the other digests and the
+// signature value are filled in once it is placed in the document
+func (s *FacturaeSigner) newSignature(id string, signingTime time.Time, documentDigest string) *xmlSignature {
+	cert := s.chain[0]
+	certDigest := sha256.Sum256(cert.Raw)
+
+	certificates := make([]string, len(s.chain))
+	for i, c := range s.chain {
+		certificates[i] = base64.StdEncoding.EncodeToString(c.Raw)
+	}
+
+	return &xmlSignature{
+		Namespace: xmldsigNamespace,
+		ID:        id,
+		SignedInfo: xmlSignedInfo{
+			ID:                     id + "-SignedInfo",
+			CanonicalizationMethod: xmlAlgorithm{Algorithm: c14n.Algorithm},
+			SignatureMethod:        xmlAlgorithm{Algorithm: signatureMethodRSASHA256},
+			References: []xmlReference{
+				{
+					ID:           id + "-Ref-Document",
+					URI:          "",
+					Transforms:   &xmlTransforms{Transforms: []xmlAlgorithm{{Algorithm: transformEnveloped}}},
+					DigestMethod: xmlAlgorithm{Algorithm: digestMethodSHA256},
+					DigestValue:  documentDigest,
+				},
+				{
+					URI:          "#" + id + "-KeyInfo",
+					DigestMethod: xmlAlgorithm{Algorithm: digestMethodSHA256},
+				},
+				{
+					Type:         signedPropertiesType,
+					URI:          "#" + id + "-SignedProperties",
+					DigestMethod: xmlAlgorithm{Algorithm: digestMethodSHA256},
+				},
+			},
+		},
+		SignatureValue: xmlSignatureValue{ID: id + "-SignatureValue"},
+		KeyInfo: xmlKeyInfo{
+			ID:       id + "-KeyInfo",
+			X509Data: xmlX509Data{Certificates: certificates},
+			KeyValue: xmlKeyValue{RSAKeyValue: xmlRSAKeyValue{
+				Modulus:  base64.StdEncoding.EncodeToString(s.key.N.Bytes()),
+				Exponent: base64.StdEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+			}},
+		},
+		Object: xmlSignatureObject{QualifyingProperties: xadesQualifyingProperties{
+			Namespace: xadesNamespace,
+			Target:    "#" + id,
+			SignedProperties: xadesSignedProperties{
+				ID: id + "-SignedProperties",
+				SignatureProperties: xadesSignedSignatureProperties{
+					SigningTime: signingTime.Format(time.RFC3339),
+					SigningCertificate: xadesCert{
+						CertDigest: xadesDigest{
+							DigestMethod: xmlAlgorithm{Algorithm: digestMethodSHA256},
+							DigestValue:  base64.StdEncoding.EncodeToString(certDigest[:]),
+						},
+						IssuerSerial: xadesIssuerSerial{
+							IssuerName:   cert.Issuer.String(),
+							SerialNumber: cert.SerialNumber.String(),
+						},
+					},
+					Policy: xadesPolicy{
+						Identifier:  facturaePolicyURL,
+						Description: facturaePolicyDescription,
+						Hash: xadesDigest{
+							DigestMethod: xmlAlgorithm{Algorithm: digestMethodSHA1},
+							DigestValue:  facturaePolicyDigest,
+						},
+					},
+					ClaimedRole: "emisor",
+				},
+				DataObjectFormat: xadesDataObjectFormat{
+					ObjectReference: "#" + id + "-Ref-Document",
+					Description:     "Factura electrónica",
+					MimeType:        "text/xml",
+				},
+			},
+		}},
+	}
+}
+
+// digest returns the base64 SHA-256 digest of canonical XML
+func digest(canonical []byte) string {
+	sum := sha256.Sum256(canonical)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+type xmlSignature struct {
+	XMLName        xml.Name           `xml:"ds:Signature"`
+	Namespace      string             `xml:"xmlns:ds,attr"`
+	ID             string             `xml:"Id,attr"`
+	SignedInfo     xmlSignedInfo      `xml:"ds:SignedInfo"`
+	SignatureValue xmlSignatureValue  `xml:"ds:SignatureValue"`
+	KeyInfo        xmlKeyInfo         `xml:"ds:KeyInfo"`
+	Object         xmlSignatureObject `xml:"ds:Object"`
+}
+
+type xmlSignedInfo struct {
+	ID                     string         `xml:"Id,attr"`
+	CanonicalizationMethod xmlAlgorithm   `xml:"ds:CanonicalizationMethod"`
+	SignatureMethod        xmlAlgorithm   `xml:"ds:SignatureMethod"`
+	References             []xmlReference `xml:"ds:Reference"`
+}
+
+type xmlAlgorithm struct {
+	Algorithm string `xml:"Algorithm,attr"`
+}
+
+type xmlReference struct {
+	ID           string         `xml:"Id,attr,omitempty"`
+	Type         string         `xml:"Type,attr,omitempty"`
+	URI          string         `xml:"URI,attr"`
+	Transforms   *xmlTransforms `xml:"ds:Transforms"`
+	DigestMethod xmlAlgorithm   `xml:"ds:DigestMethod"`
+	DigestValue  string         `xml:"ds:DigestValue"`
+}
+
+type xmlTransforms struct {
+	Transforms []xmlAlgorithm `xml:"ds:Transform"`
+}
+
+type xmlSignatureValue struct {
+	ID    string `xml:"Id,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlKeyInfo struct {
+	ID       string      `xml:"Id,attr"`
+	X509Data xmlX509Data `xml:"ds:X509Data"`
+	KeyValue xmlKeyValue `xml:"ds:KeyValue"`
+}
+
+type xmlX509Data struct {
+	Certificates []string `xml:"ds:X509Certificate"`
+}
+
+type xmlKeyValue struct {
+	RSAKeyValue xmlRSAKeyValue `xml:"ds:RSAKeyValue"`
+}
+
+type xmlRSAKeyValue struct {
+	Modulus  string `xml:"ds:Modulus"`
+	Exponent string `xml:"ds:Exponent"`
+}
+
+type xmlSignatureObject struct {
+	QualifyingProperties xadesQualifyingProperties `xml:"xades:QualifyingProperties"`
+}
+
+type xadesQualifyingProperties struct {
+	Namespace        string                `xml:"xmlns:xades,attr"`
+	Target           string                `xml:"Target,attr"`
+	SignedProperties xadesSignedProperties `xml:"xades:SignedProperties"`
+}
+
+type xadesSignedProperties struct {
+	ID                  string                         `xml:"Id,attr"`
+	SignatureProperties xadesSignedSignatureProperties `xml:"xades:SignedSignatureProperties"`
+	DataObjectFormat    xadesDataObjectFormat          `xml:"xades:SignedDataObjectProperties>xades:DataObjectFormat"`
+}
+
+type xadesSignedSignatureProperties struct {
+	SigningTime        string      `xml:"xades:SigningTime"`
+	SigningCertificate xadesCert   `xml:"xades:SigningCertificate>xades:Cert"`
+	Policy             xadesPolicy `xml:"xades:SignaturePolicyIdentifier>xades:SignaturePolicyId"`
+	ClaimedRole        string      `xml:"xades:SignerRole>xades:ClaimedRoles>xades:ClaimedRole"`
+}
+
+type xadesCert struct {
+	CertDigest   xadesDigest       `xml:"xades:CertDigest"`
+	IssuerSerial xadesIssuerSerial `xml:"xades:IssuerSerial"`
+}
+
+type xadesDigest struct {
+	DigestMethod xmlAlgorithm `xml:"ds:DigestMethod"`
+	DigestValue  string       `xml:"ds:DigestValue"`
+}
+
+type xadesIssuerSerial struct {
+	IssuerName   string `xml:"ds:X509IssuerName"`
+	SerialNumber string `xml:"ds:X509SerialNumber"`
+}
+
+type xadesPolicy struct {
+	Identifier  string      `xml:"xades:SigPolicyId>xades:Identifier"`
+	Description string      `xml:"xades:SigPolicyId>xades:Description"`
+	Hash        xadesDigest `xml:"xades:SigPolicyHash"`
+}
+
+type xadesDataObjectFormat struct {
+	ObjectReference string `xml:"ObjectReference,attr"`
+	Description     string `xml:"xades:Description"`
+	MimeType        string `xml:"xades:MimeType"`
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+)
+
+// insuranceCoverageConsumer charges completed appointments to the authorisations of the
+// insurers of their clients
+type insuranceCoverageConsumer struct {
+	insurerService InsurerService
+}
+
+// NewInsuranceCoverageConsumer creates the transition hook that takes a session of an
+// insurance authorisation when an appointment is completed. It must run before the session
+// pack consumer and the appointment invoicer: covered appointments do not consume a pack
+// and only their co-payment is invoiced to the client.
+func NewInsuranceCoverageConsumer(insurerService InsurerService) AppointmentTransitionHook {
+	return &insuranceCoverageConsumer{insurerService: insurerService}
+}
+
+// OnAppointmentTransition covers an appointment that has just been completed
+func (h *insuranceCoverageConsumer) OnAppointmentTransition(ctx context.Context, appointment *domain.Appointment, from domain.AppointmentStatus) error {
+	if appointment.Status != domain.AppointmentStatusCompleted || from == domain.AppointmentStatusCompleted {
+		return nil
+	}
+
+	session, err := h.insurerService.CoverSession(ctx, appointment)
+	switch err {
+	case nil:
+		log.Printf("[INFO] Appointment %s covered by authorisation %s (co-payment %s)", appointment.ID, session.AuthorisationID, session.CopayAmount)
+		return nil
+	case domain.ErrNoUsableAuthorisation, domain.ErrSessionAlreadyCovered:
+		// Not insured, or already covered
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,590 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// CreateInsurerRequest represents the request to create an insurer
+type CreateInsurerRequest struct {
+	Name             string      `json:"name" binding:"required"`
+	BillingProfileID uuid.UUID   `json:"billingProfileId" binding:"required"`                 // Fiscal data of the settlement invoices
+	SessionRate      money.Money `json:"sessionRate"`                                         // Agreed price per session before VAT
+	PaymentTermDays  *int        `json:"paymentTermDays,omitempty" binding:"omitempty,gte=0"` // Defaults to 30 days
+	Notes            *string     `json:"notes,omitempty"`
+}
+
+// UpdateInsurerRequest represents the request to update an insurer; sessions already
+// covered keep the amounts they were covered with
+type UpdateInsurerRequest struct {
+	Name             string      `json:"name" binding:"required"`
+	BillingProfileID uuid.UUID   `json:"billingProfileId" binding:"required"`
+	SessionRate      money.Money `json:"sessionRate"`
+	PaymentTermDays  int         `json:"paymentTermDays" binding:"gte=0"`
+	Notes            *string     `json:"notes,omitempty"`
+	IsActive         bool        `json:"isActive"`
+}
+
+// InsurancePolicyRequest represents the request to create or update the policy of a client
+type InsurancePolicyRequest struct {
+	InsurerID    uuid.UUID   `json:"insurerId" binding:"required"`
+	PolicyNumber string      `json:"policyNumber" binding:"required"`
+	Copay        money.Money `json:"copay"` // Paid by the client for each covered session
+	ValidFrom    time.Time   `json:"validFrom" binding:"required"`
+	ValidUntil   *time.Time  `json:"validUntil,omitempty"` // Open-ended when not given
+	Notes        *string     `json:"notes,omitempty"`
+	IsActive     *bool       `json:"isActive,omitempty"` // Defaults to true
+}
+
+// InsuranceAuthorisationRequest represents the request to create or update an authorisation
+type InsuranceAuthorisationRequest struct {
+	AuthorisationNumber string     `json:"authorisationNumber" binding:"required"`
+	ServiceTypeID       *uuid.UUID `json:"serviceTypeId,omitempty"` // Sessions it covers; any when not given
+	SessionsAuthorised  int        `json:"sessionsAuthorised" binding:"required,gt=0"`
+	ValidFrom           time.Time  `json:"validFrom" binding:"required"`
+	ValidUntil          time.Time  `json:"validUntil" binding:"required"`
+	Notes               *string    `json:"notes,omitempty"`
+}
+
+// SettleInsurerRequest represents the request to invoice an insurer the sessions of a month
+type SettleInsurerRequest struct {
+	Period    string     `json:"period" binding:"required"` // YYYY-MM
+	IssueDate *time.Time `json:"issueDate,omitempty"`       // Defaults to today
+	Preview   bool       `json:"preview"`                   // Lists the sessions without invoicing them
+	Draft     bool       `json:"draft"`                     // Leaves the invoice as a draft
+}
+
+// InsurerSettlement lists the sessions invoiced, or to be invoiced, to an insurer
+type InsurerSettlement struct {
+	InsurerID uuid.UUID                `json:"insurerId"`
+	Period    string                   `json:"period"`
+	Preview   bool                     `json:"preview"`
+	Sessions  []*domain.CoveredSession `json:"sessions"`
+	Amount    money.Money              `json:"amount"`            // Sum of the insurer amounts, before VAT
+	Invoice   *domain.Invoice          `json:"invoice,omitempty"` // Created unless previewing
+}
+
+// InsurerService handles insurers (mutuas), the policies of the clients, the sessions they
+// authorise and the monthly invoices settling the covered sessions
+type InsurerService interface {
+	BookingAdvisor
+
+	// CreateInsurer creates a new insurer
+	CreateInsurer(ctx context.Context, req *CreateInsurerRequest) (*domain.Insurer, error)
+
+	// GetInsurer retrieves an insurer by ID
+	GetInsurer(ctx context.Context, id uuid.UUID) (*domain.Insurer, error)
+
+	// ListInsurers retrieves the insurers, optionally only active ones
+	ListInsurers(ctx context.Context, activeOnly bool) ([]*domain.Insurer, error)
+
+	// UpdateInsurer updates an existing insurer
+	UpdateInsurer(ctx context.Context, id uuid.UUID, req *UpdateInsurerRequest) (*domain.Insurer, error)
+
+	// DeleteInsurer soft deletes an insurer
+	DeleteInsurer(ctx context.Context, id uuid.UUID) error
+
+	// CreatePolicy records the policy of a client with an insurer
+	CreatePolicy(ctx context.Context, clientID uuid.UUID, req *InsurancePolicyRequest) (*domain.InsurancePolicy, error)
+
+	// ListClientPolicies retrieves the policies of a client with their authorisations
+	ListClientPolicies(ctx context.Context, clientID uuid.UUID) ([]*domain.InsurancePolicy, error)
+
+	// UpdatePolicy updates the policy of a client
+	UpdatePolicy(ctx context.Context, id uuid.UUID, req *InsurancePolicyRequest) (*domain.InsurancePolicy, error)
+
+	// DeletePolicy soft deletes the policy of a client
+	DeletePolicy(ctx context.Context, id uuid.UUID) error
+
+	// CreateAuthorisation records an authorisation of the insurer for a policy
+	CreateAuthorisation(ctx context.Context, policyID uuid.UUID, req *InsuranceAuthorisationRequest) (*domain.InsuranceAuthorisation, error)
+
+	// UpdateAuthorisation updates an authorisation; the sessions used are kept
+	UpdateAuthorisation(ctx context.Context, id uuid.UUID, req *InsuranceAuthorisationRequest) (*domain.InsuranceAuthorisation, error)
+
+	// DeleteAuthorisation deletes an authorisation that has not covered any session
+	DeleteAuthorisation(ctx context.Context, id uuid.UUID) error
+
+	// CoverSession takes a session of an authorisation of its client for a completed appointment
+	CoverSession(ctx context.Context, appointment *domain.Appointment) (*domain.CoveredSession, error)
+
+	// SettleInsurer invoices an insurer the covered sessions up to the end of a month that
+	// were not settled yet, one line per session
+	SettleInsurer(ctx context.Context, insurerID uuid.UUID, req *SettleInsurerRequest) (*InsurerSettlement, error)
+}
+
+type insurerService struct {
+	insurerRepo     repository.InsurerRepository
+	profileRepo     repository.BillingProfileRepository
+	clientRepo      repository.ClientRepository
+	serviceTypeRepo repository.ServiceTypeRepository
+	invoiceService  InvoiceService
+	now             func() time.Time
+}
+
+// NewInsurerService creates a new insurer service
+func NewInsurerService(
+	insurerRepo repository.InsurerRepository,
+	profileRepo repository.BillingProfileRepository,
+	clientRepo repository.ClientRepository,
+	serviceTypeRepo repository.ServiceTypeRepository,
+	invoiceService InvoiceService,
+) InsurerService {
+	return &insurerService{
+		insurerRepo:     insurerRepo,
+		profileRepo:     profileRepo,
+		clientRepo:      clientRepo,
+		serviceTypeRepo: serviceTypeRepo,
+		invoiceService:  invoiceService,
+		now:             time.Now,
+	}
+}
+
+// defaultInsurerPaymentTermDays is the payment term of the settlement invoices when not given
+const defaultInsurerPaymentTermDays = 30
+
+// CreateInsurer creates a new insurer
+func (s *insurerService) CreateInsurer(ctx context.Context, req *CreateInsurerRequest) (*domain.Insurer, error) {
+	insurer := &domain.Insurer{
+		ID:               uuid.New(),
+		Name:             strings.TrimSpace(req.Name),
+		BillingProfileID: req.BillingProfileID,
+		SessionRate:      req.SessionRate,
+		PaymentTermDays:  defaultInsurerPaymentTermDays,
+		Notes:            req.Notes,
+		IsActive:         true,
+		CreatedAt:        s.now(),
+		UpdatedAt:        s.now(),
+	}
+	if req.PaymentTermDays != nil {
+		insurer.PaymentTermDays = *req.PaymentTermDays
+	}
+
+	if err := s.validateInsurer(ctx, insurer); err != nil {
+		return nil, err
+	}
+
+	if err := s.insurerRepo.Create(ctx, insurer); err != nil {
+		return nil, err
+	}
+
+	return insurer, nil
+}
+
+// GetInsurer retrieves an insurer by ID
+func (s *insurerService) GetInsurer(ctx context.Context, id uuid.UUID) (*domain.Insurer, error) {
+	return s.insurerRepo.GetByID(ctx, id)
+}
+
+// ListInsurers retrieves the insurers, optionally only active ones
+func (s *insurerService) ListInsurers(ctx context.Context, activeOnly bool) ([]*domain.Insurer, error) {
+	return s.insurerRepo.List(ctx, activeOnly)
+}
+
+// UpdateInsurer updates an existing insurer
+func (s *insurerService) UpdateInsurer(ctx context.Context, id uuid.UUID, req *UpdateInsurerRequest) (*domain.Insurer, error) {
+	insurer, err := s.insurerRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	insurer.Name = strings.TrimSpace(req.Name)
+	insurer.BillingProfileID = req.BillingProfileID
+	insurer.SessionRate = req.SessionRate
+	insurer.PaymentTermDays = req.PaymentTermDays
+	insurer.Notes = req.Notes
+	insurer.IsActive = req.IsActive
+	insurer.UpdatedAt = s.now()
+
+	if err := s.validateInsurer(ctx, insurer); err != nil {
+		return nil, err
+	}
+
+	if err := s.insurerRepo.Update(ctx, insurer); err != nil {
+		return nil, fmt.Errorf("failed to update insurer: %w", err)
+	}
+
+	return insurer, nil
+}
+
+// validateInsurer checks the insurer and that its billing profile exists
+func (s *insurerService) validateInsurer(ctx context.Context, insurer *domain.Insurer) error {
+	if err := insurer.Validate(); err != nil {
+		return err
+	}
+	if _, err := s.profileRepo.GetByID(ctx, insurer.BillingProfileID); err != nil {
+		if isNotFound(err) {
+			return errors.NewValidationError("billing profile not found", map[string][]string{
+				"billingProfileId": {"billing profile does not exist"},
+			})
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteInsurer soft deletes an insurer; its settlement invoices and the policies of its
+// clients are kept
+func (s *insurerService) DeleteInsurer(ctx context.Context, id uuid.UUID) error {
+	return s.insurerRepo.Delete(ctx, id)
+}
+
+// CreatePolicy records the policy of a client with an insurer
+func (s *insurerService) CreatePolicy(ctx context.Context, clientID uuid.UUID, req *InsurancePolicyRequest) (*domain.InsurancePolicy, error) {
+	if _, err := s.clientRepo.GetByID(ctx, clientID); err != nil {
+		return nil, err
+	}
+
+	policy := &domain.InsurancePolicy{
+		ID:        uuid.New(),
+		ClientID:  clientID,
+		IsActive:  true,
+		CreatedAt: s.now(),
+	}
+	applyInsurancePolicyRequest(policy, req)
+	policy.UpdatedAt = s.now()
+
+	insurer, err := s.validatePolicy(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.insurerRepo.CreatePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+
+	policy.Insurer = insurer
+	policy.Authorisations = []*domain.InsuranceAuthorisation{}
+	return policy, nil
+}
+
+// applyInsurancePolicyRequest copies the fields of a request to a policy
+func applyInsurancePolicyRequest(policy *domain.InsurancePolicy, req *InsurancePolicyRequest) {
+	policy.InsurerID = req.InsurerID
+	policy.PolicyNumber = strings.TrimSpace(req.PolicyNumber)
+	policy.Copay = req.Copay
+	policy.ValidFrom = req.ValidFrom
+	policy.ValidUntil = req.ValidUntil
+	policy.Notes = req.Notes
+	if req.IsActive != nil {
+		policy.IsActive = *req.IsActive
+	}
+}
+
+// validatePolicy checks the policy and returns its insurer, which must be active
+func (s *insurerService) validatePolicy(ctx context.Context, policy *domain.InsurancePolicy) (*domain.Insurer, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	insurer, err := s.insurerRepo.GetByID(ctx, policy.InsurerID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.NewValidationError("insurer not found", map[string][]string{
+				"insurerId": {"insurer does not exist"},
+			})
+		}
+		return nil, err
+	}
+	if !insurer.IsActive {
+		return nil, domain.ErrInsurerInactive
+	}
+
+	return insurer, nil
+}
+
+// ListClientPolicies retrieves the policies of a client with their authorisations
+func (s *insurerService) ListClientPolicies(ctx context.Context, clientID uuid.UUID) ([]*domain.InsurancePolicy, error) {
+	if _, err := s.clientRepo.GetByID(ctx, clientID); err != nil {
+		return nil, err
+	}
+	return s.insurerRepo.ListClientPolicies(ctx, clientID)
+}
+
+// UpdatePolicy updates the policy of a client; sessions already covered keep their co-payment
+func (s *insurerService) UpdatePolicy(ctx context.Context, id uuid.UUID, req *InsurancePolicyRequest) (*domain.InsurancePolicy, error) {
+	policy, err := s.insurerRepo.GetPolicy(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyInsurancePolicyRequest(policy, req)
+	policy.UpdatedAt = s.now()
+
+	insurer, err := s.validatePolicy(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.insurerRepo.UpdatePolicy(ctx, policy); err != nil {
+		return nil, fmt.Errorf("failed to update insurance policy: %w", err)
+	}
+
+	policy.Insurer = insurer
+	return policy, nil
+}
+
+// DeletePolicy soft deletes the policy of a client
+func (s *insurerService) DeletePolicy(ctx context.Context, id uuid.UUID) error {
+	return s.insurerRepo.DeletePolicy(ctx, id)
+}
+
+// CreateAuthorisation records an authorisation of the insurer for a policy
+func (s *insurerService) CreateAuthorisation(ctx context.Context, policyID uuid.UUID, req *InsuranceAuthorisationRequest) (*domain.InsuranceAuthorisation, error) {
+	if _, err := s.insurerRepo.GetPolicy(ctx, policyID); err != nil {
+		return nil, err
+	}
+
+	authorisation := &domain.InsuranceAuthorisation{
+		ID:        uuid.New(),
+		PolicyID:  policyID,
+		CreatedAt: s.now(),
+	}
+	applyInsuranceAuthorisationRequest(authorisation, req)
+	authorisation.UpdatedAt = s.now()
+
+	if err := s.validateAuthorisation(ctx, authorisation); err != nil {
+		return nil, err
+	}
+
+	if err := s.insurerRepo.CreateAuthorisation(ctx, authorisation); err != nil {
+		return nil, err
+	}
+
+	return authorisation, nil
+}
+
+// UpdateAuthorisation updates an authorisation, e.g. when the insurer extends it; the
+// sessions used are kept
+func (s *insurerService) UpdateAuthorisation(ctx context.Context, id uuid.UUID, req *InsuranceAuthorisationRequest) (*domain.InsuranceAuthorisation, error) {
+	authorisation, err := s.insurerRepo.GetAuthorisation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyInsuranceAuthorisationRequest(authorisation, req)
+	authorisation.UpdatedAt = s.now()
+
+	if err := s.validateAuthorisation(ctx, authorisation); err != nil {
+		return nil, err
+	}
+
+	if err := s.insurerRepo.UpdateAuthorisation(ctx, authorisation); err != nil {
+		return nil, err
+	}
+
+	return authorisation, nil
+}
+
+// applyInsuranceAuthorisationRequest copies the fields of a request to an authorisation
+func applyInsuranceAuthorisationRequest(authorisation *domain.InsuranceAuthorisation, req *InsuranceAuthorisationRequest) {
+	authorisation.AuthorisationNumber = strings.TrimSpace(req.AuthorisationNumber)
+	authorisation.ServiceTypeID = req.ServiceTypeID
+	authorisation.SessionsAuthorised = req.SessionsAuthorised
+	authorisation.ValidFrom = req.ValidFrom
+	authorisation.ValidUntil = req.ValidUntil
+	authorisation.Notes = req.Notes
+}
+
+// validateAuthorisation checks the authorisation and that the service type it covers exists
+func (s *insurerService) validateAuthorisation(ctx context.Context, authorisation *domain.InsuranceAuthorisation) error {
+	if err := authorisation.Validate(); err != nil {
+		return err
+	}
+	if authorisation.ServiceTypeID != nil {
+		if _, err := s.serviceTypeRepo.GetByID(ctx, *authorisation.ServiceTypeID); err != nil {
+			return errors.NewValidationError("service type not found", map[string][]string{
+				"serviceTypeId": {"service type does not exist"},
+			})
+		}
+	}
+	return nil
+}
+
+// DeleteAuthorisation deletes an authorisation that has not covered any session
+func (s *insurerService) DeleteAuthorisation(ctx context.Context, id uuid.UUID) error {
+	return s.insurerRepo.DeleteAuthorisation(ctx, id)
+}
+
+// CoverSession takes a session of an authorisation of its client for a completed appointment.
+// The insurer pays its session rate and the client the co-payment of the policy.
+func (s *insurerService) CoverSession(ctx context.Context, appointment *domain.Appointment) (*domain.CoveredSession, error) {
+	if appointment.Status != domain.AppointmentStatusCompleted {
+		return nil, errors.NewValidationError("only completed appointments are covered", map[string][]string{
+			"status": {fmt.Sprintf("appointment is %s", appointment.Status)},
+		})
+	}
+
+	start := appointment.StartTime
+	session := &domain.CoveredSession{
+		ID:          uuid.New(),
+		SessionDate: time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC),
+		CreatedAt:   s.now(),
+	}
+
+	if _, err := s.insurerRepo.CoverSession(ctx, appointment, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// BookingWarnings warns when the client of an appointment has a policy in force on its date
+// but no authorisation to cover it: the latest one covering the service is used up or
+// expired, or there is none. The booking is allowed; the session would not be covered.
+func (s *insurerService) BookingWarnings(ctx context.Context, appointment *domain.Appointment) ([]domain.BookingWarning, error) {
+	policies, err := s.insurerRepo.ListClientPolicies(ctx, appointment.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := []domain.BookingWarning{}
+	for _, policy := range policies {
+		if !policy.IsValidOn(appointment.StartTime) || policy.Insurer == nil || !policy.Insurer.IsActive || policy.Insurer.DeletedAt != nil {
+			continue
+		}
+
+		var latest *domain.InsuranceAuthorisation
+		usable := false
+		for _, authorisation := range policy.Authorisations {
+			if !authorisation.Covers(appointment.ServiceTypeID) {
+				continue
+			}
+			if authorisation.IsValidOn(appointment.StartTime) {
+				usable = true
+				break
+			}
+			// Authorisations starting after the appointment do not apply
+			if authorisation.ValidFrom.After(appointment.StartTime) {
+				continue
+			}
+			if latest == nil || authorisation.ValidUntil.After(latest.ValidUntil) {
+				latest = authorisation
+			}
+		}
+		if usable {
+			continue
+		}
+
+		switch {
+		case latest == nil:
+			warnings = append(warnings, domain.BookingWarning{
+				Code: domain.BookingWarningNoAuthorisation,
+				Message: fmt.Sprintf("El cliente tiene póliza con %s pero ninguna autorización para esta sesión",
+					policy.Insurer.Name),
+			})
+		case latest.IsExpired(appointment.StartTime):
+			warnings = append(warnings, domain.BookingWarning{
+				Code: domain.BookingWarningAuthorisationExpiry,
+				Message: fmt.Sprintf("La autorización %s de %s caducó el %s",
+					latest.AuthorisationNumber, policy.Insurer.Name, latest.ValidUntil.Format("02/01/2006")),
+			})
+		default:
+			warnings = append(warnings, domain.BookingWarning{
+				Code: domain.BookingWarningAuthorisationUsedUp,
+				Message: fmt.Sprintf("La autorización %s de %s ha agotado sus %d sesiones",
+					latest.AuthorisationNumber, policy.Insurer.Name, latest.SessionsAuthorised),
+			})
+		}
+	}
+
+	return warnings, nil
+}
+
+// SettleInsurer invoices an insurer the covered sessions up to the end of a month that were
+// not settled yet, so sessions missed by an earlier settlement are included in the next one.
+// The invoice is addressed to the billing profile of the insurer and due after its payment
+// term; the sessions are marked once it is created.
+func (s *insurerService) SettleInsurer(ctx context.Context, insurerID uuid.UUID, req *SettleInsurerRequest) (*InsurerSettlement, error) {
+	from, err := time.ParseInLocation(InvoiceBatchPeriodLayout, req.Period, time.UTC)
+	if err != nil {
+		return nil, errors.NewValidationError("invalid period", map[string][]string{
+			"period": {"must be a month as YYYY-MM"},
+		})
+	}
+
+	insurer, err := s.insurerRepo.GetByID(ctx, insurerID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.insurerRepo.ListUnsettled(ctx, insurerID, from.AddDate(0, 1, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	settlement := &InsurerSettlement{
+		InsurerID: insurerID,
+		Period:    req.Period,
+		Preview:   req.Preview,
+		Sessions:  sessions,
+	}
+	for _, session := range sessions {
+		if settlement.Amount, err = settlement.Amount.Add(session.InsurerAmount); err != nil {
+			return nil, err
+		}
+	}
+	if req.Preview {
+		return settlement, nil
+	}
+	if len(sessions) == 0 {
+		return nil, domain.ErrNothingToSettle
+	}
+
+	now := s.now()
+	issueDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if req.IssueDate != nil {
+		issueDate = *req.IssueDate
+	}
+
+	lines := make([]InvoiceLineRequest, 0, len(sessions))
+	sessionIDs := make([]uuid.UUID, 0, len(sessions))
+	for _, session := range sessions {
+		amount := session.InsurerAmount
+		lines = append(lines, InvoiceLineRequest{
+			Description: fmt.Sprintf("Sesión del %s - %s (póliza %s, aut. %s)",
+				session.SessionDate.Format("02/01/2006"), session.ClientName, session.PolicyNumber, session.AuthorisationNumber),
+			UnitPrice:     &amount,
+			ServiceTypeID: session.ServiceTypeID,
+		})
+		sessionIDs = append(sessionIDs, session.ID)
+	}
+
+	profileID := insurer.BillingProfileID
+	invoiceReq := &CreateInvoiceRequest{
+		ClientID:         uuid.Nil,
+		BillingProfileID: &profileID,
+		IssueDate:        issueDate,
+		DueDate:          issueDate.AddDate(0, 0, insurer.PaymentTermDays),
+		Lines:            lines,
+		Description: fmt.Sprintf("Liquidación %s de %s de %d",
+			insurer.Name, spanishMonths[from.Month()-1], from.Year()),
+	}
+
+	createInvoice := s.invoiceService.CreateInvoice
+	if req.Draft {
+		createInvoice = s.invoiceService.CreateDraftInvoice
+	}
+	invoice, err := createInvoice(ctx, invoiceReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.insurerRepo.SetSettlementInvoice(ctx, sessionIDs, invoice.ID); err != nil {
+		return nil, fmt.Errorf("invoice %s was created but the sessions were not marked as settled: %w", invoice.ID, err)
+	}
+	for _, session := range sessions {
+		session.SettlementInvoiceID = &invoice.ID
+	}
+
+	settlement.Invoice = invoice
+	return settlement, nil
+}
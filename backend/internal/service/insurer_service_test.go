@@ -0,0 +1,471 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockInsurerRepository is a mock implementation of repository.InsurerRepository
+type MockInsurerRepository struct {
+	mock.Mock
+}
+
+func (m *MockInsurerRepository) Create(ctx context.Context, insurer *domain.Insurer) error {
+	args := m.Called(ctx, insurer)
+	return args.Error(0)
+}
+
+func (m *MockInsurerRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Insurer, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Insurer), args.Error(1)
+}
+
+func (m *MockInsurerRepository) List(ctx context.Context, activeOnly bool) ([]*domain.Insurer, error) {
+	args := m.Called(ctx, activeOnly)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Insurer), args.Error(1)
+}
+
+func (m *MockInsurerRepository) Update(ctx context.Context, insurer *domain.Insurer) error {
+	args := m.Called(ctx, insurer)
+	return args.Error(0)
+}
+
+func (m *MockInsurerRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockInsurerRepository) CreatePolicy(ctx context.Context, policy *domain.InsurancePolicy) error {
+	args := m.Called(ctx, policy)
+	return args.Error(0)
+}
+
+func (m *MockInsurerRepository) GetPolicy(ctx context.Context, id uuid.UUID) (*domain.InsurancePolicy, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.InsurancePolicy), args.Error(1)
+}
+
+func (m *MockInsurerRepository) ListClientPolicies(ctx context.Context, clientID uuid.UUID) ([]*domain.InsurancePolicy, error) {
+	args := m.Called(ctx, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.InsurancePolicy), args.Error(1)
+}
+
+func (m *MockInsurerRepository) UpdatePolicy(ctx context.Context, policy *domain.InsurancePolicy) error {
+	args := m.Called(ctx, policy)
+	return args.Error(0)
+}
+
+func (m *MockInsurerRepository) DeletePolicy(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockInsurerRepository) CreateAuthorisation(ctx context.Context, authorisation *domain.InsuranceAuthorisation) error {
+	args := m.Called(ctx, authorisation)
+	return args.Error(0)
+}
+
+func (m *MockInsurerRepository) GetAuthorisation(ctx context.Context, id uuid.UUID) (*domain.InsuranceAuthorisation, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.InsuranceAuthorisation), args.Error(1)
+}
+
+func (m *MockInsurerRepository) UpdateAuthorisation(ctx context.Context, authorisation *domain.InsuranceAuthorisation) error {
+	args := m.Called(ctx, authorisation)
+	return args.Error(0)
+}
+
+func (m *MockInsurerRepository) DeleteAuthorisation(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockInsurerRepository) CoverSession(ctx context.Context, appointment *domain.Appointment, session *domain.CoveredSession) (*domain.InsuranceAuthorisation, error) {
+	args := m.Called(ctx, appointment, session)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.InsuranceAuthorisation), args.Error(1)
+}
+
+func (m *MockInsurerRepository) GetCoveredSession(ctx context.Context, appointmentID uuid.UUID) (*domain.CoveredSession, error) {
+	args := m.Called(ctx, appointmentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.CoveredSession), args.Error(1)
+}
+
+func (m *MockInsurerRepository) ListUnsettled(ctx context.Context, insurerID uuid.UUID, until time.Time) ([]*domain.CoveredSession, error) {
+	args := m.Called(ctx, insurerID, until)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.CoveredSession), args.Error(1)
+}
+
+func (m *MockInsurerRepository) SetSettlementInvoice(ctx context.Context, sessionIDs []uuid.UUID, invoiceID uuid.UUID) error {
+	args := m.Called(ctx, sessionIDs, invoiceID)
+	return args.Error(0)
+}
+
+// newNotCoveredRepository returns an insurer repository where no appointment is covered
+func newNotCoveredRepository() *MockInsurerRepository {
+	insurerRepo := new(MockInsurerRepository)
+	insurerRepo.On("GetCoveredSession", mock.Anything, mock.Anything).
+		Return(nil, errors.NewNotFoundError("covered session not found")).Maybe()
+	return insurerRepo
+}
+
+func newInsurerTestService(now time.Time) (*insurerService, *MockInsurerRepository, *MockBillingProfileRepository, *MockInvoiceService) {
+	insurerRepo := new(MockInsurerRepository)
+	profileRepo := new(MockBillingProfileRepository)
+	invoiceService := new(MockInvoiceService)
+
+	svc := NewInsurerService(insurerRepo, profileRepo, new(MockClientRepository), new(MockServiceTypeRepository), invoiceService).(*insurerService)
+	svc.now = func() time.Time { return now }
+	return svc, insurerRepo, profileRepo, invoiceService
+}
+
+func testInsurer() *domain.Insurer {
+	return &domain.Insurer{
+		ID:               uuid.New(),
+		Name:             "Sanitas",
+		BillingProfileID: uuid.New(),
+		SessionRate:      money.MustParse("35"),
+		PaymentTermDays:  30,
+		IsActive:         true,
+	}
+}
+
+func TestInsurerService_CreateInsurer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("payment term defaults to 30 days", func(t *testing.T) {
+		svc, insurerRepo, profileRepo, _ := newInsurerTestService(time.Now())
+		profile := testBillingProfile()
+		profileRepo.On("GetByID", ctx, profile.ID).Return(profile, nil)
+		insurerRepo.On("Create", ctx, mock.AnythingOfType("*domain.Insurer")).Return(nil)
+
+		insurer, err := svc.CreateInsurer(ctx, &CreateInsurerRequest{
+			Name:             " Sanitas ",
+			BillingProfileID: profile.ID,
+			SessionRate:      money.MustParse("35"),
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "Sanitas", insurer.Name)
+		assert.Equal(t, 30, insurer.PaymentTermDays)
+		assert.True(t, insurer.IsActive)
+	})
+
+	t.Run("billing profile must exist", func(t *testing.T) {
+		svc, insurerRepo, profileRepo, _ := newInsurerTestService(time.Now())
+		profileRepo.On("GetByID", ctx, mock.Anything).Return(nil, errors.NewNotFoundError("billing profile not found"))
+
+		_, err := svc.CreateInsurer(ctx, &CreateInsurerRequest{
+			Name:             "Sanitas",
+			BillingProfileID: uuid.New(),
+			SessionRate:      money.MustParse("35"),
+		})
+
+		requireValidationError(t, err)
+		insurerRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("session rate must be positive", func(t *testing.T) {
+		svc, _, _, _ := newInsurerTestService(time.Now())
+
+		_, err := svc.CreateInsurer(ctx, &CreateInsurerRequest{Name: "Sanitas", BillingProfileID: uuid.New()})
+
+		assert.Equal(t, domain.ErrInvalidInsurerSessionRate, err)
+	})
+}
+
+func TestInsurerService_CoverSession(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 10, 19, 0, 0, 0, time.UTC)
+	svc, insurerRepo, _, _ := newInsurerTestService(now)
+
+	appointment := &domain.Appointment{
+		ID:        uuid.New(),
+		ClientID:  uuid.New(),
+		Status:    domain.AppointmentStatusCompleted,
+		StartTime: time.Date(2025, 3, 10, 17, 0, 0, 0, time.Local),
+	}
+	insurerRepo.On("CoverSession", ctx, appointment, mock.AnythingOfType("*domain.CoveredSession")).
+		Run(func(args mock.Arguments) {
+			session := args.Get(2).(*domain.CoveredSession)
+			session.InsurerAmount = money.MustParse("35")
+			session.CopayAmount = money.MustParse("10")
+		}).
+		Return(&domain.InsuranceAuthorisation{ID: uuid.New(), SessionsAuthorised: 10, SessionsUsed: 1}, nil)
+
+	session, err := svc.CoverSession(ctx, appointment)
+
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), session.SessionDate)
+	assert.Equal(t, "10.00", session.CopayAmount.Decimal())
+
+	pending := &domain.Appointment{ID: uuid.New(), Status: domain.AppointmentStatusPending}
+	_, err = svc.CoverSession(ctx, pending)
+	requireValidationError(t, err)
+}
+
+func TestInsurerService_BookingWarnings(t *testing.T) {
+	ctx := context.Background()
+	therapy := uuid.New()
+	bookedOn := time.Date(2025, 3, 20, 10, 0, 0, 0, time.UTC)
+	authorisation := func(used int, from, until time.Time) *domain.InsuranceAuthorisation {
+		return &domain.InsuranceAuthorisation{
+			ID:                  uuid.New(),
+			AuthorisationNumber: "AUT-001",
+			ServiceTypeID:       &therapy,
+			SessionsAuthorised:  10,
+			SessionsUsed:        used,
+			ValidFrom:           from,
+			ValidUntil:          until,
+		}
+	}
+	january := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+	february := time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		authorisations []*domain.InsuranceAuthorisation
+		want           []domain.BookingWarningCode
+	}{
+		{"usable authorisation", []*domain.InsuranceAuthorisation{authorisation(3, january, june)}, nil},
+		{"used up", []*domain.InsuranceAuthorisation{authorisation(10, january, june)}, []domain.BookingWarningCode{domain.BookingWarningAuthorisationUsedUp}},
+		{"expired", []*domain.InsuranceAuthorisation{authorisation(3, january, february)}, []domain.BookingWarningCode{domain.BookingWarningAuthorisationExpiry}},
+		{"renewed after expiring", []*domain.InsuranceAuthorisation{authorisation(0, february.AddDate(0, 0, 1), june), authorisation(10, january, february)}, nil},
+		{"not authorised yet", []*domain.InsuranceAuthorisation{authorisation(0, june, june)}, []domain.BookingWarningCode{domain.BookingWarningNoAuthorisation}},
+		{"none", []*domain.InsuranceAuthorisation{}, []domain.BookingWarningCode{domain.BookingWarningNoAuthorisation}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, insurerRepo, _, _ := newInsurerTestService(bookedOn)
+			appointment := &domain.Appointment{ID: uuid.New(), ClientID: uuid.New(), StartTime: bookedOn, ServiceTypeID: &therapy}
+			insurerRepo.On("ListClientPolicies", ctx, appointment.ClientID).Return([]*domain.InsurancePolicy{{
+				ID:             uuid.New(),
+				PolicyNumber:   "POL-1",
+				ValidFrom:      january,
+				IsActive:       true,
+				Insurer:        testInsurer(),
+				Authorisations: tt.authorisations,
+			}}, nil)
+
+			warnings, err := svc.BookingWarnings(ctx, appointment)
+
+			require.NoError(t, err)
+			codes := []domain.BookingWarningCode(nil)
+			for _, warning := range warnings {
+				codes = append(codes, warning.Code)
+				assert.Contains(t, warning.Message, "Sanitas")
+			}
+			assert.Equal(t, tt.want, codes)
+		})
+	}
+
+	t.Run("clients without a policy in force are not warned", func(t *testing.T) {
+		svc, insurerRepo, _, _ := newInsurerTestService(bookedOn)
+		appointment := &domain.Appointment{ID: uuid.New(), ClientID: uuid.New(), StartTime: bookedOn}
+		insurerRepo.On("ListClientPolicies", ctx, appointment.ClientID).Return([]*domain.InsurancePolicy{{
+			ValidFrom:  january,
+			ValidUntil: &february,
+			IsActive:   true,
+			Insurer:    testInsurer(),
+		}}, nil)
+
+		warnings, err := svc.BookingWarnings(ctx, appointment)
+
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+	})
+}
+
+func TestInsurerService_SettleInsurer(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 4, 2, 9, 0, 0, 0, time.UTC)
+	endOfMarch := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	therapy := uuid.New()
+	sessions := func() []*domain.CoveredSession {
+		return []*domain.CoveredSession{
+			{ID: uuid.New(), SessionDate: time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC), InsurerAmount: money.MustParse("35"), ServiceTypeID: &therapy,
+				ClientName: "Lucía Pérez", PolicyNumber: "POL-1", AuthorisationNumber: "AUT-001"},
+			{ID: uuid.New(), SessionDate: time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC), InsurerAmount: money.MustParse("30"), ServiceTypeID: &therapy,
+				ClientName: "Mario Ruiz", PolicyNumber: "POL-2", AuthorisationNumber: "AUT-007"},
+		}
+	}
+
+	t.Run("one line per session addressed to the insurer", func(t *testing.T) {
+		svc, insurerRepo, _, invoiceService := newInsurerTestService(now)
+		insurer := testInsurer()
+		pending := sessions()
+		invoice := &domain.Invoice{ID: uuid.New()}
+
+		insurerRepo.On("GetByID", ctx, insurer.ID).Return(insurer, nil)
+		insurerRepo.On("ListUnsettled", ctx, insurer.ID, endOfMarch).Return(pending, nil)
+		invoiceService.On("CreateInvoice", ctx, mock.MatchedBy(func(req *CreateInvoiceRequest) bool {
+			return req.ClientID == uuid.Nil &&
+				*req.BillingProfileID == insurer.BillingProfileID &&
+				req.IssueDate.Equal(time.Date(2025, 4, 2, 0, 0, 0, 0, time.UTC)) &&
+				req.DueDate.Equal(time.Date(2025, 5, 2, 0, 0, 0, 0, time.UTC)) &&
+				len(req.Lines) == 2 &&
+				req.Lines[0].Description == "Sesión del 04/03/2025 - Lucía Pérez (póliza POL-1, aut. AUT-001)" &&
+				req.Lines[1].UnitPrice.Decimal() == "30.00" &&
+				*req.Lines[1].ServiceTypeID == therapy &&
+				req.Description == "Liquidación Sanitas de marzo de 2025"
+		})).Return(invoice, nil)
+		insurerRepo.On("SetSettlementInvoice", ctx, []uuid.UUID{pending[0].ID, pending[1].ID}, invoice.ID).Return(nil)
+
+		settlement, err := svc.SettleInsurer(ctx, insurer.ID, &SettleInsurerRequest{Period: "2025-03"})
+
+		require.NoError(t, err)
+		assert.Equal(t, invoice, settlement.Invoice)
+		assert.Equal(t, "65.00", settlement.Amount.Decimal())
+		assert.Equal(t, &invoice.ID, settlement.Sessions[1].SettlementInvoiceID)
+		invoiceService.AssertExpectations(t)
+		insurerRepo.AssertExpectations(t)
+	})
+
+	t.Run("preview invoices nothing", func(t *testing.T) {
+		svc, insurerRepo, _, invoiceService := newInsurerTestService(now)
+		insurer := testInsurer()
+		insurerRepo.On("GetByID", ctx, insurer.ID).Return(insurer, nil)
+		insurerRepo.On("ListUnsettled", ctx, insurer.ID, endOfMarch).Return(sessions(), nil)
+
+		settlement, err := svc.SettleInsurer(ctx, insurer.ID, &SettleInsurerRequest{Period: "2025-03", Preview: true})
+
+		require.NoError(t, err)
+		assert.Len(t, settlement.Sessions, 2)
+		assert.Nil(t, settlement.Invoice)
+		invoiceService.AssertNotCalled(t, "CreateInvoice", mock.Anything, mock.Anything)
+	})
+
+	t.Run("nothing to settle", func(t *testing.T) {
+		svc, insurerRepo, _, _ := newInsurerTestService(now)
+		insurer := testInsurer()
+		insurerRepo.On("GetByID", ctx, insurer.ID).Return(insurer, nil)
+		insurerRepo.On("ListUnsettled", ctx, insurer.ID, endOfMarch).Return([]*domain.CoveredSession{}, nil)
+
+		_, err := svc.SettleInsurer(ctx, insurer.ID, &SettleInsurerRequest{Period: "2025-03"})
+
+		assert.Equal(t, domain.ErrNothingToSettle, err)
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		svc, _, _, _ := newInsurerTestService(now)
+
+		_, err := svc.SettleInsurer(ctx, uuid.New(), &SettleInsurerRequest{Period: "marzo"})
+
+		requireValidationError(t, err)
+	})
+}
+
+func TestInvoiceService_CreateInvoice_SettlementWithoutClient(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	profileRepo := new(MockBillingProfileRepository)
+	svc.profileRepo = profileRepo
+	payer := testBillingProfile()
+	issueDate := time.Date(2025, 4, 2, 0, 0, 0, 0, time.UTC)
+
+	profileRepo.On("GetByID", ctx, payer.ID).Return(payer, nil)
+	invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).Run(issueAs("F_2025_0001")).Return(nil)
+
+	invoice, err := svc.CreateInvoice(ctx, &CreateInvoiceRequest{
+		BillingProfileID: &payer.ID,
+		IssueDate:        issueDate,
+		DueDate:          issueDate.AddDate(0, 0, 30),
+		Lines:            []InvoiceLineRequest{{Description: "Sesión del 04/03/2025", UnitPrice: pricePtr("35"), VATRate: floatPtr(0)}},
+	})
+
+	require.NoError(t, err)
+	assert.False(t, invoice.HasClient())
+	assert.Equal(t, "Construcciones Norte SL", invoice.Recipient(nil).Name)
+	clientRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
+
+func TestInsuranceCoverageConsumer_CoversCompletedAppointments(t *testing.T) {
+	ctx := context.Background()
+	svc, insurerRepo, _, _ := newInsurerTestService(time.Now())
+	hook := NewInsuranceCoverageConsumer(svc)
+
+	appointment := &domain.Appointment{ID: uuid.New(), ClientID: uuid.New(), Status: domain.AppointmentStatusCompleted}
+	insurerRepo.On("CoverSession", ctx, appointment, mock.Anything).Return(&domain.InsuranceAuthorisation{ID: uuid.New()}, nil).Once()
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusConfirmed))
+
+	// Clients without an authorisation are invoiced as usual
+	insurerRepo.On("CoverSession", ctx, appointment, mock.Anything).Return(nil, domain.ErrNoUsableAuthorisation).Once()
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusConfirmed))
+
+	insurerRepo.AssertExpectations(t)
+}
+
+func TestSessionPackConsumer_SkipsCoveredAppointments(t *testing.T) {
+	ctx := context.Background()
+	svc, sessionPackRepo, _, _ := newSessionPackTestService(time.Now())
+	insurerRepo := new(MockInsurerRepository)
+	hook := NewSessionPackConsumer(svc, insurerRepo)
+
+	appointment := &domain.Appointment{ID: uuid.New(), ClientID: uuid.New(), Status: domain.AppointmentStatusCompleted}
+	insurerRepo.On("GetCoveredSession", ctx, appointment.ID).Return(&domain.CoveredSession{ID: uuid.New()}, nil)
+
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusConfirmed))
+	sessionPackRepo.AssertNotCalled(t, "ConsumeSession", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAppointmentInvoicer_InvoicesTheCopayOfCoveredAppointments(t *testing.T) {
+	ctx := context.Background()
+	settingsRepo := new(MockBillingSettingsRepository)
+	settingsRepo.On("Get", mock.Anything).Return(&domain.BillingSettings{AppointmentInvoicing: domain.AppointmentInvoicingIssue}, nil)
+	sessionPackRepo := new(MockSessionPackRepository)
+	sessionPackRepo.On("GetUsageByAppointment", mock.Anything, mock.Anything).
+		Return(nil, errors.NewNotFoundError("session pack usage not found"))
+	insurerRepo := new(MockInsurerRepository)
+	invoiceService := new(MockInvoiceService)
+	hook := NewAppointmentInvoicer(settingsRepo, sessionPackRepo, insurerRepo, invoiceService)
+
+	withCopay := &domain.Appointment{ID: uuid.New(), Status: domain.AppointmentStatusCompleted}
+	insurerRepo.On("GetCoveredSession", ctx, withCopay.ID).Return(&domain.CoveredSession{CopayAmount: money.MustParse("10")}, nil)
+	invoiceService.On("CreateInvoiceFromAppointment", ctx, withCopay.ID, mock.MatchedBy(func(req *CreateInvoiceFromAppointmentRequest) bool {
+		return req.UnitPrice != nil && req.UnitPrice.Decimal() == "10.00" && req.Notes != ""
+	})).Return(&domain.Invoice{ID: uuid.New()}, nil).Once()
+
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, withCopay, domain.AppointmentStatusConfirmed))
+	invoiceService.AssertExpectations(t)
+
+	// Fully paid by the insurer
+	withoutCopay := &domain.Appointment{ID: uuid.New(), Status: domain.AppointmentStatusCompleted}
+	insurerRepo.On("GetCoveredSession", ctx, withoutCopay.ID).Return(&domain.CoveredSession{}, nil)
+
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, withoutCopay, domain.AppointmentStatusConfirmed))
+	invoiceService.AssertNumberOfCalls(t, "CreateInvoiceFromAppointment", 1)
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// InvoiceBatchPeriodLayout is the format of a batch period (a calendar month)
+const InvoiceBatchPeriodLayout = "2006-01"
+
+var spanishMonths = [...]string{
+	"enero", "febrero", "marzo", "abril", "mayo", "junio",
+	"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre",
+}
+
+// InvoiceBatchRequest represents the request to invoice the sessions of a month, one invoice per client
+type InvoiceBatchRequest struct {
+	Period    string      `json:"period" binding:"required"` // YYYY-MM
+	ClientIDs []uuid.UUID `json:"clientIds,omitempty"`       // Defaults to every client with sessions to invoice
+	Preview   bool        `json:"preview"`                   // Calculates the invoices without storing them
+	Draft     bool        `json:"draft"`                     // Creates drafts instead of issuing the invoices
+}
+
+// InvoiceBatchItem is the invoice of a client in a batch run
+type InvoiceBatchItem struct {
+	ClientID       uuid.UUID       `json:"clientId"`
+	AppointmentIDs []uuid.UUID     `json:"appointmentIds"`
+	Invoice        *domain.Invoice `json:"invoice,omitempty"` // Calculated in a preview, created otherwise
+	Error          string          `json:"error,omitempty"`   // Why the client was not invoiced
+}
+
+// InvoiceBatchRun summarises a batch run
+type InvoiceBatchRun struct {
+	Period   string              `json:"period"`
+	Preview  bool                `json:"preview"`
+	Items    []*InvoiceBatchItem `json:"items"`
+	Invoiced int                 `json:"invoiced"` // Invoices created, or to be created in a preview
+	Failed   int                 `json:"failed"`   // Clients left for the next run
+	Total    money.Money         `json:"total"`    // Sum of the invoiced totals
+	RunAt    time.Time           `json:"runAt"`
+}
+
+// InvoiceBatchPolicy configures the scheduled monthly invoicing
+type InvoiceBatchPolicy struct {
+	Day   int  // Day of the month from which the previous month is invoiced; 0 disables it
+	Draft bool // Leaves the invoices as drafts to be reviewed
+}
+
+// InvoiceBatchService invoices the sessions of a month with one invoice per client
+type InvoiceBatchService interface {
+	// Run invoices the completed appointments of a period that no invoice references yet.
+	// Appointments invoiced by an earlier run are skipped, so running a period again is
+	// harmless and resumes a run that was interrupted.
+	Run(ctx context.Context, req *InvoiceBatchRequest) (*InvoiceBatchRun, error)
+
+	// RunScheduled invoices the previous month once the policy day is reached; it returns
+	// nil when the run is not due
+	RunScheduled(ctx context.Context) (*InvoiceBatchRun, error)
+}
+
+type invoiceBatchService struct {
+	appointmentRepo repository.AppointmentRepository
+	invoiceService  InvoiceService
+	policy          InvoiceBatchPolicy
+	now             func() time.Time
+}
+
+// NewInvoiceBatchService creates a new batch invoicing service
+func NewInvoiceBatchService(
+	appointmentRepo repository.AppointmentRepository,
+	invoiceService InvoiceService,
+	policy InvoiceBatchPolicy,
+) InvoiceBatchService {
+	return &invoiceBatchService{
+		appointmentRepo: appointmentRepo,
+		invoiceService:  invoiceService,
+		policy:          policy,
+		now:             time.Now,
+	}
+}
+
+// Run invoices the completed appointments of a period that no invoice references yet
+func (s *invoiceBatchService) Run(ctx context.Context, req *InvoiceBatchRequest) (*InvoiceBatchRun, error) {
+	from, err := time.ParseInLocation(InvoiceBatchPeriodLayout, req.Period, time.Local)
+	if err != nil {
+		return nil, errors.NewValidationError("invalid period", map[string][]string{
+			"period": {"must be a month as YYYY-MM"},
+		})
+	}
+	if from.After(s.now()) {
+		return nil, errors.NewValidationError("period has not started", map[string][]string{
+			"period": {"future months cannot be invoiced"},
+		})
+	}
+
+	appointments, err := s.appointmentRepo.ListUninvoiced(ctx, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	run := &InvoiceBatchRun{
+		Period:  req.Period,
+		Preview: req.Preview,
+		Items:   []*InvoiceBatchItem{},
+		RunAt:   s.now(),
+	}
+
+	clients := make(map[uuid.UUID]bool, len(req.ClientIDs))
+	for _, clientID := range req.ClientIDs {
+		clients[clientID] = true
+	}
+
+	description := fmt.Sprintf("Sesiones de %s de %d", spanishMonths[from.Month()-1], from.Year())
+
+	// Appointments come ordered by client, so each group is one invoice
+	for start := 0; start < len(appointments); {
+		end := start + 1
+		for end < len(appointments) && appointments[end].ClientID == appointments[start].ClientID {
+			end++
+		}
+		group := appointments[start:end]
+		start = end
+
+		clientID := group[0].ClientID
+		if len(clients) > 0 && !clients[clientID] {
+			continue
+		}
+
+		invoiceReq := &InvoiceAppointmentsRequest{
+			Description: description,
+			Draft:       req.Draft,
+			Preview:     req.Preview,
+		}
+
+		item := &InvoiceBatchItem{ClientID: clientID, AppointmentIDs: make([]uuid.UUID, 0, len(group))}
+		for _, appointment := range group {
+			item.AppointmentIDs = append(item.AppointmentIDs, appointment.ID)
+
+			// The insurer pays the session but the co-payment, settled monthly
+			if appointment.CopayAmount != nil {
+				if invoiceReq.UnitPrices == nil {
+					invoiceReq.UnitPrices = make(map[uuid.UUID]money.Money)
+				}
+				invoiceReq.UnitPrices[appointment.ID] = *appointment.CopayAmount
+				invoiceReq.Notes = "Las sesiones cubiertas por su aseguradora se facturan por el copago del paciente"
+			}
+		}
+		run.Items = append(run.Items, item)
+
+		// A client that cannot be invoiced does not stop the others
+		invoice, err := s.invoiceService.InvoiceAppointments(ctx, clientID, group, invoiceReq)
+		if err != nil {
+			if !req.Preview {
+				log.Printf("[ERROR] Failed to invoice %s sessions of client %s: %v", req.Period, clientID, err)
+			}
+			item.Error = err.Error()
+			run.Failed++
+			continue
+		}
+
+		item.Invoice = invoice
+		run.Invoiced++
+		if run.Total, err = run.Total.Add(invoice.TotalAmount); err != nil {
+			return nil, err
+		}
+	}
+
+	return run, nil
+}
+
+// RunScheduled invoices the previous month once the policy day is reached
+func (s *invoiceBatchService) RunScheduled(ctx context.Context) (*InvoiceBatchRun, error) {
+	now := s.now()
+	if s.policy.Day <= 0 || now.Day() < s.policy.Day {
+		return nil, nil
+	}
+
+	previous := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -1, 0)
+	return s.Run(ctx, &InvoiceBatchRequest{
+		Period: previous.Format(InvoiceBatchPeriodLayout),
+		Draft:  s.policy.Draft,
+	})
+}
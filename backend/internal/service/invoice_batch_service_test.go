@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newInvoiceBatchTestService(now time.Time, policy InvoiceBatchPolicy) (*invoiceBatchService, *MockAppointmentRepository, *MockInvoiceService) {
+	appointmentRepo := new(MockAppointmentRepository)
+	invoiceService := new(MockInvoiceService)
+
+	svc := NewInvoiceBatchService(appointmentRepo, invoiceService, policy).(*invoiceBatchService)
+	svc.now = func() time.Time { return now }
+	return svc, appointmentRepo, invoiceService
+}
+
+func batchAppointment(clientID uuid.UUID, day int) *domain.Appointment {
+	return &domain.Appointment{
+		ID:        uuid.New(),
+		ClientID:  clientID,
+		StartTime: time.Date(2025, 3, day, 10, 0, 0, 0, time.Local),
+		Status:    domain.AppointmentStatusCompleted,
+	}
+}
+
+func TestInvoiceBatchService_Run_OneInvoicePerClient(t *testing.T) {
+	ctx := context.Background()
+	svc, appointmentRepo, invoiceService := newInvoiceBatchTestService(time.Date(2025, 4, 2, 9, 0, 0, 0, time.Local), InvoiceBatchPolicy{})
+
+	ana, luis := uuid.New(), uuid.New()
+	anaSessions := []*domain.Appointment{batchAppointment(ana, 3), batchAppointment(ana, 10), batchAppointment(ana, 17)}
+	luisSessions := []*domain.Appointment{batchAppointment(luis, 5)}
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.Local)
+	appointmentRepo.On("ListUninvoiced", ctx, from, from.AddDate(0, 1, 0)).Return(append(append([]*domain.Appointment{}, anaSessions...), luisSessions...), nil)
+
+	expectedReq := &InvoiceAppointmentsRequest{Description: "Sesiones de marzo de 2025", Preview: true}
+	invoiceService.On("InvoiceAppointments", ctx, ana, anaSessions, expectedReq).Return(&domain.Invoice{ClientID: ana, TotalAmount: money.MustParse("180")}, nil)
+	invoiceService.On("InvoiceAppointments", ctx, luis, luisSessions, expectedReq).Return(&domain.Invoice{ClientID: luis, TotalAmount: money.MustParse("60")}, nil)
+
+	run, err := svc.Run(ctx, &InvoiceBatchRequest{Period: "2025-03", Preview: true})
+
+	require.NoError(t, err)
+	assert.True(t, run.Preview)
+	assert.Equal(t, 2, run.Invoiced)
+	assert.Equal(t, 0, run.Failed)
+	assert.Equal(t, "240.00", run.Total.Decimal())
+	require.Len(t, run.Items, 2)
+	assert.Equal(t, ana, run.Items[0].ClientID)
+	assert.Equal(t, []uuid.UUID{anaSessions[0].ID, anaSessions[1].ID, anaSessions[2].ID}, run.Items[0].AppointmentIDs)
+	assert.Equal(t, luis, run.Items[1].ClientID)
+	invoiceService.AssertExpectations(t)
+}
+
+func TestInvoiceBatchService_Run_FailedClientDoesNotStopTheOthers(t *testing.T) {
+	ctx := context.Background()
+	svc, appointmentRepo, invoiceService := newInvoiceBatchTestService(time.Date(2025, 4, 2, 9, 0, 0, 0, time.Local), InvoiceBatchPolicy{})
+
+	unpriced, priced := uuid.New(), uuid.New()
+	appointments := []*domain.Appointment{batchAppointment(unpriced, 3), batchAppointment(priced, 4)}
+	appointmentRepo.On("ListUninvoiced", ctx, mock.Anything, mock.Anything).Return(appointments, nil)
+
+	invoiceService.On("InvoiceAppointments", ctx, unpriced, mock.Anything, mock.Anything).
+		Return(nil, errors.NewValidationError("appointment has no price", nil))
+	invoiceService.On("InvoiceAppointments", ctx, priced, mock.Anything, mock.MatchedBy(func(req *InvoiceAppointmentsRequest) bool {
+		return req.Draft && !req.Preview
+	})).Return(&domain.Invoice{ClientID: priced, TotalAmount: money.MustParse("60")}, nil)
+
+	run, err := svc.Run(ctx, &InvoiceBatchRequest{Period: "2025-03", Draft: true})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, run.Invoiced)
+	assert.Equal(t, 1, run.Failed)
+	assert.Equal(t, "appointment has no price", run.Items[0].Error)
+	assert.Nil(t, run.Items[0].Invoice)
+	assert.NotNil(t, run.Items[1].Invoice)
+	assert.Equal(t, "60.00", run.Total.Decimal())
+}
+
+func TestInvoiceBatchService_Run_CoveredSessionsBillTheCopay(t *testing.T) {
+	ctx := context.Background()
+	svc, appointmentRepo, invoiceService := newInvoiceBatchTestService(time.Date(2025, 4, 2, 9, 0, 0, 0, time.Local), InvoiceBatchPolicy{})
+
+	clientID := uuid.New()
+	covered, uncovered := batchAppointment(clientID, 3), batchAppointment(clientID, 10)
+	copay := money.MustParse("15")
+	covered.CopayAmount = &copay
+	appointmentRepo.On("ListUninvoiced", ctx, mock.Anything, mock.Anything).Return([]*domain.Appointment{covered, uncovered}, nil)
+
+	invoiceService.On("InvoiceAppointments", ctx, clientID, mock.Anything, mock.MatchedBy(func(req *InvoiceAppointmentsRequest) bool {
+		_, priced := req.UnitPrices[uncovered.ID]
+		return len(req.UnitPrices) == 1 && req.UnitPrices[covered.ID] == copay && !priced && req.Notes != ""
+	})).Return(&domain.Invoice{ClientID: clientID, TotalAmount: money.MustParse("75")}, nil)
+
+	run, err := svc.Run(ctx, &InvoiceBatchRequest{Period: "2025-03"})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, run.Invoiced)
+	invoiceService.AssertExpectations(t)
+}
+
+func TestInvoiceBatchService_Run_SelectedClients(t *testing.T) {
+	ctx := context.Background()
+	svc, appointmentRepo, invoiceService := newInvoiceBatchTestService(time.Date(2025, 4, 2, 9, 0, 0, 0, time.Local), InvoiceBatchPolicy{})
+
+	selected, other := uuid.New(), uuid.New()
+	appointments := []*domain.Appointment{batchAppointment(other, 3), batchAppointment(selected, 4)}
+	appointmentRepo.On("ListUninvoiced", ctx, mock.Anything, mock.Anything).Return(appointments, nil)
+	invoiceService.On("InvoiceAppointments", ctx, selected, mock.Anything, mock.Anything).Return(&domain.Invoice{ClientID: selected}, nil)
+
+	run, err := svc.Run(ctx, &InvoiceBatchRequest{Period: "2025-03", ClientIDs: []uuid.UUID{selected}})
+
+	require.NoError(t, err)
+	require.Len(t, run.Items, 1)
+	assert.Equal(t, selected, run.Items[0].ClientID)
+	invoiceService.AssertNotCalled(t, "InvoiceAppointments", ctx, other, mock.Anything, mock.Anything)
+}
+
+func TestInvoiceBatchService_Run_NothingLeftToInvoice(t *testing.T) {
+	ctx := context.Background()
+	svc, appointmentRepo, invoiceService := newInvoiceBatchTestService(time.Date(2025, 4, 2, 9, 0, 0, 0, time.Local), InvoiceBatchPolicy{})
+
+	// A repeated run finds every session already invoiced
+	appointmentRepo.On("ListUninvoiced", ctx, mock.Anything, mock.Anything).Return([]*domain.Appointment{}, nil)
+
+	run, err := svc.Run(ctx, &InvoiceBatchRequest{Period: "2025-03"})
+
+	require.NoError(t, err)
+	assert.Empty(t, run.Items)
+	assert.Equal(t, 0, run.Invoiced)
+	invoiceService.AssertNotCalled(t, "InvoiceAppointments", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestInvoiceBatchService_Run_InvalidPeriod(t *testing.T) {
+	ctx := context.Background()
+	svc, appointmentRepo, _ := newInvoiceBatchTestService(time.Date(2025, 4, 2, 9, 0, 0, 0, time.Local), InvoiceBatchPolicy{})
+
+	for _, period := range []string{"", "2025-13", "03/2025", "2025-05"} {
+		_, err := svc.Run(ctx, &InvoiceBatchRequest{Period: period})
+		requireValidationError(t, err)
+	}
+	appointmentRepo.AssertNotCalled(t, "ListUninvoiced", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestInvoiceBatchService_RunScheduled(t *testing.T) {
+	ctx := context.Background()
+
+	// Not due before the policy day, nor when disabled
+	svc, appointmentRepo, _ := newInvoiceBatchTestService(time.Date(2025, 4, 2, 9, 0, 0, 0, time.Local), InvoiceBatchPolicy{Day: 3})
+	run, err := svc.RunScheduled(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, run)
+
+	svc, appointmentRepo, _ = newInvoiceBatchTestService(time.Date(2025, 4, 20, 9, 0, 0, 0, time.Local), InvoiceBatchPolicy{})
+	run, err = svc.RunScheduled(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, run)
+	appointmentRepo.AssertNotCalled(t, "ListUninvoiced", mock.Anything, mock.Anything, mock.Anything)
+
+	// From the policy day on, the previous month is invoiced
+	svc, appointmentRepo, _ = newInvoiceBatchTestService(time.Date(2025, 1, 5, 9, 0, 0, 0, time.Local), InvoiceBatchPolicy{Day: 3, Draft: true})
+	from := time.Date(2024, 12, 1, 0, 0, 0, 0, time.Local)
+	appointmentRepo.On("ListUninvoiced", ctx, from, from.AddDate(0, 1, 0)).Return([]*domain.Appointment{}, nil)
+
+	run, err = svc.RunScheduled(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, run)
+	assert.Equal(t, "2024-12", run.Period)
+	assert.False(t, run.Preview)
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// invoicePDFLayoutVersion must be bumped whenever the template layout changes,
+// so that cached PDFs are regenerated
+const invoicePDFLayoutVersion = 2
+
+// InvoicePDF is a rendered invoice document
+type InvoicePDF struct {
+	FileName string
+	Content  []byte
+}
+
+// InvoicePDFService renders invoices as PDF documents
+type InvoicePDFService interface {
+	// GetInvoicePDF returns the PDF of an invoice. The document is cached in the file storage
+	// and only rendered again when the invoice, its client or the template change.
+	GetInvoicePDF(ctx context.Context, invoiceID uuid.UUID) (*InvoicePDF, error)
+}
+
+type invoicePDFService struct {
+	invoiceRepo  repository.InvoiceRepository
+	clientRepo   repository.ClientRepository
+	settingsRepo repository.BillingSettingsRepository
+	storage      storage.Storage
+	qrBaseURL    string
+}
+
+// NewInvoicePDFService creates a new invoice PDF service; issued invoices carry a QR code
+// pointing to qrBaseURL, the tax agency verification service by default
+func NewInvoicePDFService(
+	invoiceRepo repository.InvoiceRepository,
+	clientRepo repository.ClientRepository,
+	settingsRepo repository.BillingSettingsRepository,
+	store storage.Storage,
+	qrBaseURL string,
+) InvoicePDFService {
+	return &invoicePDFService{
+		invoiceRepo:  invoiceRepo,
+		clientRepo:   clientRepo,
+		settingsRepo: settingsRepo,
+		storage:      store,
+		qrBaseURL:    qrBaseURL,
+	}
+}
+
+// GetInvoicePDF returns the PDF of an invoice, from the cache when it is up to date
+func (s *invoicePDFService) GetInvoicePDF(ctx context.Context, invoiceID uuid.UUID) (*InvoicePDF, error) {
+	invoice, err := s.invoiceRepo.GetByID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := invoiceClient(ctx, s.clientRepo, invoice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice client: %w", err)
+	}
+
+	settings, err := s.settingsRepo.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing settings: %w", err)
+	}
+
+	// Issued invoices must carry the issuer's fiscal data; drafts can be previewed without it
+	if !invoice.IsDraft() && !settings.IsComplete() {
+		return nil, errors.NewValidationError("billing settings are incomplete", map[string][]string{
+			"settings": {"legal name, tax ID and address of the clinic are required to issue invoice PDFs"},
+		})
+	}
+
+	// Rectifying invoices print the number and date of the invoice they correct
+	var rectified *domain.Invoice
+	if invoice.IsRectifying() && invoice.RectifiedInvoiceID != nil {
+		if rectified, err = s.invoiceRepo.GetByID(ctx, *invoice.RectifiedInvoiceID); err != nil {
+			return nil, fmt.Errorf("failed to get rectified invoice: %w", err)
+		}
+	}
+
+	// Issued invoices carry the QR code to verify them with the tax agency
+	var qrURL string
+	if invoice.IsIssued() {
+		if qrURL, err = domain.InvoiceQRURL(s.qrBaseURL, settings.TaxID, invoice); err != nil {
+			return nil, fmt.Errorf("failed to build invoice QR code: %w", err)
+		}
+	}
+
+	result := &InvoicePDF{FileName: invoicePDFFileName(invoice)}
+	key, err := invoicePDFKey(invoice, rectified, client, settings, qrURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if invoice.PDFPath != nil && *invoice.PDFPath == key {
+		content, err := s.readCached(ctx, key)
+		if err == nil {
+			result.Content = content
+			return result, nil
+		}
+		if err != storage.ErrNotFound {
+			log.Printf("[WARN] Failed to read cached PDF of invoice %s, regenerating: %v", invoice.ID, err)
+		}
+	}
+
+	var logo []byte
+	if settings.HasLogo() {
+		if logo, err = s.readCached(ctx, *settings.LogoKey); err != nil {
+			log.Printf("[WARN] Failed to read invoice logo, rendering without it: %v", err)
+			logo = nil
+		}
+	}
+
+	content, err := renderInvoicePDF(invoice, rectified, client, settings, logo, qrURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render invoice PDF: %w", err)
+	}
+
+	if err := s.storage.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "application/pdf"); err != nil {
+		return nil, fmt.Errorf("failed to store invoice PDF: %w", err)
+	}
+
+	previous := invoice.PDFPath
+	if err := s.invoiceRepo.UpdatePDFPath(ctx, invoice.ID, &key); err != nil {
+		return nil, err
+	}
+	invoice.PDFPath = &key
+
+	// Drop the outdated copy
+	if previous != nil && *previous != "" && *previous != key {
+		if err := s.storage.Delete(ctx, *previous); err != nil {
+			log.Printf("[WARN] Failed to delete outdated PDF %s: %v", *previous, err)
+		}
+	}
+
+	result.Content = content
+	return result, nil
+}
+
+// readCached reads a whole object from the storage
+func (s *invoicePDFService) readCached(ctx context.Context, key string) ([]byte, error) {
+	reader, _, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// invoicePDFKey returns the storage key of the PDF for the current data of the invoice.
+// The key embeds a fingerprint of everything printed, so any change yields a new key.
+func invoicePDFKey(invoice, rectified *domain.Invoice, client *domain.Client, settings *domain.BillingSettings, qrURL string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintln(h, invoicePDFLayoutVersion, qrURL)
+	fmt.Fprintln(h, invoice.InvoiceNumber, invoice.IsDraft(), invoice.IssueDate.Format("2006-01-02"), invoice.DueDate.Format("2006-01-02"))
+	fmt.Fprintln(h, invoice.Description, invoice.Notes)
+	if rectified != nil {
+		fmt.Fprintln(h, rectified.InvoiceNumber, rectified.IssueDate.Format("2006-01-02"), invoice.RectificationReason)
+	}
+	fmt.Fprintln(h, invoice.BaseAmount, invoice.VATAmount, invoice.IRPFRate, invoice.IRPFAmount, invoice.TotalAmount)
+	for _, line := range invoice.Lines {
+		exemption := ""
+		if line.VATExemption != nil {
+			exemption = string(*line.VATExemption)
+		}
+		fmt.Fprintln(h, line.Position, line.Description, line.Quantity, line.UnitPrice, line.DiscountPercent,
+			line.VATRate, exemption, line.BaseAmount, line.VATAmount, line.TotalAmount)
+	}
+	recipient := invoice.Recipient(client)
+	fmt.Fprintln(h, recipient.Name, recipient.TaxID, recipient.Email, strings.Join(recipient.AddressLines(), "|"))
+	if invoice.HasPayer() && client != nil {
+		fmt.Fprintln(h, client.FullName())
+	}
+
+	// The settings' updated_at also changes when the logo is replaced
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return "", fmt.Errorf("failed to fingerprint billing settings: %w", err)
+	}
+	h.Write(settingsJSON)
+
+	return fmt.Sprintf("invoices/%s/%s.pdf", invoice.ID, hex.EncodeToString(h.Sum(nil))[:16]), nil
+}
+
+// invoicePDFFileName returns the download name of the invoice PDF
+func invoicePDFFileName(invoice *domain.Invoice) string {
+	if invoice.IsDraft() {
+		return fmt.Sprintf("Borrador_%s.pdf", invoice.ID.String()[:8])
+	}
+	return fmt.Sprintf("Factura_%s.pdf", strings.NewReplacer("/", "-", " ", "_").Replace(invoice.InvoiceNumber))
+}
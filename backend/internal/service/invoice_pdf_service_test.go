@@ -0,0 +1,223 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockBillingSettingsRepository is a mock implementation of BillingSettingsRepository
+type MockBillingSettingsRepository struct {
+	mock.Mock
+}
+
+func (m *MockBillingSettingsRepository) Get(ctx context.Context) (*domain.BillingSettings, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BillingSettings), args.Error(1)
+}
+
+func (m *MockBillingSettingsRepository) Update(ctx context.Context, settings *domain.BillingSettings) error {
+	args := m.Called(ctx, settings)
+	return args.Error(0)
+}
+
+func newInvoicePDFTestService(t *testing.T) (InvoicePDFService, *MockInvoiceRepository, *MockClientRepository, *MockBillingSettingsRepository, storage.Storage) {
+	store, err := storage.NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+
+	invoiceRepo := new(MockInvoiceRepository)
+	clientRepo := new(MockClientRepository)
+	settingsRepo := new(MockBillingSettingsRepository)
+
+	return NewInvoicePDFService(invoiceRepo, clientRepo, settingsRepo, store, ""), invoiceRepo, clientRepo, settingsRepo, store
+}
+
+func pdfTestSettings() *domain.BillingSettings {
+	return &domain.BillingSettings{
+		LegalName:         "Arnela Psicología S.L.",
+		TaxID:             "B12345678",
+		AddressStreet:     "Calle Mayor 1",
+		AddressCity:       "Madrid",
+		AddressPostalCode: "28013",
+		AddressCountry:    "ES",
+		IBAN:              "ES9121000418450200051332",
+		FooterText:        "Gracias por su confianza",
+		AccentColor:       domain.DefaultInvoiceAccentColor,
+	}
+}
+
+func pdfTestInvoice(clientID uuid.UUID) *domain.Invoice {
+	exempt := domain.VATExemptArticle20
+	invoice := &domain.Invoice{
+		ID:            uuid.New(),
+		InvoiceNumber: "F2025-0042",
+		ClientID:      clientID,
+		IssueDate:     time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		DueDate:       time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+		Status:        domain.InvoiceStatusUnpaid,
+		IRPFRate:      15,
+		Lines: []*domain.InvoiceLine{
+			{Position: 1, Description: "Sesión de psicoterapia individual", Quantity: 4, UnitPrice: money.MustParse("60"), VATExemption: &exempt},
+			{Position: 2, Description: "Informe psicológico", Quantity: 1, UnitPrice: money.MustParse("120"), VATRate: 21},
+		},
+	}
+	if err := invoice.CalculateAmounts(); err != nil {
+		panic(err)
+	}
+	return invoice
+}
+
+// pdfContentText inflates the page content streams of a PDF
+func pdfContentText(t *testing.T, content []byte) string {
+	t.Helper()
+
+	var text bytes.Buffer
+	re := regexp.MustCompile(`/FlateDecode /Length (\d+) >>\nstream\n`)
+	for _, loc := range re.FindAllSubmatchIndex(content, -1) {
+		length, err := strconv.Atoi(string(content[loc[2]:loc[3]]))
+		require.NoError(t, err)
+
+		zr, err := zlib.NewReader(bytes.NewReader(content[loc[1] : loc[1]+length]))
+		require.NoError(t, err)
+		data, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		text.Write(data)
+	}
+	return text.String()
+}
+
+func TestInvoicePDFService_GetInvoicePDF_RendersAndCaches(t *testing.T) {
+	svc, invoiceRepo, clientRepo, settingsRepo, store := newInvoicePDFTestService(t)
+	ctx := context.Background()
+
+	client := &domain.Client{ID: uuid.New(), FirstName: "Lucía", LastName: "Pérez", DNICIF: "12345678Z", AddressStreet: "Gran Vía 10", AddressCity: "Madrid", AddressPostalCode: "28013"}
+	invoice := pdfTestInvoice(client.ID)
+
+	invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+	clientRepo.On("GetByID", ctx, client.ID).Return(client, nil)
+	settingsRepo.On("Get", ctx).Return(pdfTestSettings(), nil)
+	invoiceRepo.On("UpdatePDFPath", ctx, invoice.ID, mock.AnythingOfType("*string")).
+		Run(func(args mock.Arguments) { invoice.PDFPath = args.Get(2).(*string) }).
+		Return(nil).Once()
+
+	result, err := svc.GetInvoicePDF(ctx, invoice.ID)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Factura_F2025-0042.pdf", result.FileName)
+	assert.True(t, bytes.HasPrefix(result.Content, []byte("%PDF-")))
+	require.NotNil(t, invoice.PDFPath)
+
+	text := pdfContentText(t, result.Content)
+	assert.Contains(t, text, "(F2025-0042)")
+	assert.Contains(t, text, "(NIF: 12345678Z)")
+	assert.Contains(t, text, "(NIF: B12345678)")
+	assert.Contains(t, text, "(Exento \\(E1\\))")
+	assert.Contains(t, text, "(Gracias por su confianza)")
+	assert.Contains(t, text, "(VERI*FACTU)")
+
+	stored, _, err := store.Get(ctx, *invoice.PDFPath)
+	require.NoError(t, err)
+	stored.Close()
+
+	// A second request is served from the cache without touching the invoice
+	cached, err := svc.GetInvoicePDF(ctx, invoice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, result.Content, cached.Content)
+	invoiceRepo.AssertNumberOfCalls(t, "UpdatePDFPath", 1)
+}
+
+func TestInvoicePDFService_GetInvoicePDF_RegeneratesWhenInvoiceChanges(t *testing.T) {
+	svc, invoiceRepo, clientRepo, settingsRepo, store := newInvoicePDFTestService(t)
+	ctx := context.Background()
+
+	client := &domain.Client{ID: uuid.New(), FirstName: "Lucía", LastName: "Pérez", DNICIF: "12345678Z"}
+	invoice := pdfTestInvoice(client.ID)
+
+	invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+	clientRepo.On("GetByID", ctx, client.ID).Return(client, nil)
+	settingsRepo.On("Get", ctx).Return(pdfTestSettings(), nil)
+	invoiceRepo.On("UpdatePDFPath", ctx, invoice.ID, mock.AnythingOfType("*string")).
+		Run(func(args mock.Arguments) { invoice.PDFPath = args.Get(2).(*string) }).
+		Return(nil)
+
+	_, err := svc.GetInvoicePDF(ctx, invoice.ID)
+	require.NoError(t, err)
+	firstKey := *invoice.PDFPath
+
+	invoice.Notes = "Sesiones de marzo"
+	_, err = svc.GetInvoicePDF(ctx, invoice.ID)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, firstKey, *invoice.PDFPath)
+	invoiceRepo.AssertNumberOfCalls(t, "UpdatePDFPath", 2)
+
+	_, _, err = store.Get(ctx, firstKey)
+	assert.Equal(t, storage.ErrNotFound, err, "outdated PDF must be removed")
+}
+
+func TestInvoicePDFService_GetInvoicePDF_RequiresFiscalData(t *testing.T) {
+	svc, invoiceRepo, clientRepo, settingsRepo, _ := newInvoicePDFTestService(t)
+	ctx := context.Background()
+
+	client := &domain.Client{ID: uuid.New(), FirstName: "Lucía", LastName: "Pérez"}
+	invoice := pdfTestInvoice(client.ID)
+
+	invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+	clientRepo.On("GetByID", ctx, client.ID).Return(client, nil)
+	settingsRepo.On("Get", ctx).Return(&domain.BillingSettings{}, nil)
+
+	_, err := svc.GetInvoicePDF(ctx, invoice.ID)
+
+	require.Error(t, err)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, errors.CodeValidationFailed, appErr.Code)
+	invoiceRepo.AssertNotCalled(t, "UpdatePDFPath", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestInvoicePDFService_GetInvoicePDF_DraftPreviewWithoutSettings(t *testing.T) {
+	svc, invoiceRepo, clientRepo, settingsRepo, _ := newInvoicePDFTestService(t)
+	ctx := context.Background()
+
+	client := &domain.Client{ID: uuid.New(), FirstName: "Lucía", LastName: "Pérez"}
+	invoice := pdfTestInvoice(client.ID)
+	invoice.Status = domain.InvoiceStatusDraft
+
+	invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+	clientRepo.On("GetByID", ctx, client.ID).Return(client, nil)
+	settingsRepo.On("Get", ctx).Return(&domain.BillingSettings{}, nil)
+	invoiceRepo.On("UpdatePDFPath", ctx, invoice.ID, mock.AnythingOfType("*string")).Return(nil)
+
+	result, err := svc.GetInvoicePDF(ctx, invoice.ID)
+
+	require.NoError(t, err)
+	assert.Contains(t, result.FileName, "Borrador_")
+	text := pdfContentText(t, result.Content)
+	assert.Contains(t, text, "(BORRADOR - Documento sin validez fiscal)")
+	assert.NotContains(t, text, "VERI*FACTU", "drafts carry no verification QR code")
+}
+
+func TestFormatPDFAmount(t *testing.T) {
+	assert.Equal(t, "1.234,56 €", formatPDFAmount(money.MustParse("1234.56")))
+	assert.Equal(t, "0,05 €", formatPDFAmount(money.MustParse("0.05")))
+	assert.Equal(t, "-36,00 €", formatPDFAmount(money.MustParse("-36")))
+	assert.Equal(t, "1.000.000,00 €", formatPDFAmount(money.MustParse("1000000")))
+	assert.Equal(t, "10,5%", formatPDFPercent(10.5))
+}
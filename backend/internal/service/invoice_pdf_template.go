@@ -0,0 +1,582 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/pdf"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/qrcode"
+)
+
+// Layout of the invoice template, in points on an A4 page
+const (
+	pdfMargin       = 40.0
+	pdfContentRight = 555.0
+	pdfFooterTop    = 770.0
+	pdfRowPadding   = 5.0
+	pdfLineHeight   = 11.0
+)
+
+var (
+	pdfTextColor  = pdf.Color{R: 33, G: 37, B: 41}
+	pdfMutedColor = pdf.Color{R: 108, G: 117, B: 125}
+	pdfRuleColor  = pdf.Color{R: 206, G: 212, B: 218}
+	pdfDraftColor = pdf.Color{R: 200, G: 35, B: 51}
+)
+
+// invoiceTableColumns are the right edges of the numeric columns of the lines table
+var invoiceTableColumns = struct {
+	concept, quantity, price, discount, vat, amount float64
+}{concept: 290, quantity: 330, price: 400, discount: 445, vat: 490, amount: pdfContentRight}
+
+// invoiceRenderer lays out an invoice on as many pages as needed
+type invoiceRenderer struct {
+	doc       *pdf.Document
+	page      *pdf.Page
+	pages     []*pdf.Page
+	y         float64
+	accent    pdf.Color
+	invoice   *domain.Invoice
+	rectified *domain.Invoice
+	client    *domain.Client
+	settings  *domain.BillingSettings
+	logo      *pdf.Image
+	qr        *qrcode.Code
+}
+
+// renderInvoicePDF renders an invoice with the clinic's template; rectified is the invoice
+// corrected by a rectifying invoice, nil otherwise, and qrURL the content of the QR code, if any
+func renderInvoicePDF(invoice, rectified *domain.Invoice, client *domain.Client, settings *domain.BillingSettings, logo []byte, qrURL string) ([]byte, error) {
+	accent, err := pdf.ParseHexColor(settings.AccentColor)
+	if err != nil {
+		accent, _ = pdf.ParseHexColor(domain.DefaultInvoiceAccentColor)
+	}
+
+	r := &invoiceRenderer{
+		doc:       pdf.New(pdf.A4),
+		accent:    accent,
+		invoice:   invoice,
+		rectified: rectified,
+		client:    client,
+		settings:  settings,
+	}
+
+	if len(logo) > 0 {
+		if r.logo, err = pdf.NewImage(logo); err != nil {
+			return nil, err
+		}
+	}
+
+	if qrURL != "" {
+		if r.qr, err = qrcode.Encode([]byte(qrURL), qrcode.Medium); err != nil {
+			return nil, err
+		}
+	}
+
+	r.doc.SetInfo(invoiceTitle(invoice)+" "+invoice.InvoiceNumber, settings.LegalName)
+	r.doc.SetCreationDate(invoice.IssueDate)
+
+	r.newPage()
+	r.header()
+	r.rectification()
+	r.parties()
+	r.linesTable()
+	r.totals()
+	r.verification()
+	r.mentions()
+	r.footers()
+
+	return r.doc.Bytes()
+}
+
+// newPage starts a page; continuation pages only repeat a short heading
+func (r *invoiceRenderer) newPage() {
+	r.page = r.doc.AddPage()
+	r.pages = append(r.pages, r.page)
+	r.y = pdfMargin
+
+	if len(r.pages) > 1 {
+		r.page.SetFont(pdf.HelveticaBold, 9)
+		r.page.SetTextColor(pdfMutedColor)
+		r.page.Text(pdfMargin, r.y+10, fmt.Sprintf("%s %s (continuación)", invoiceTitle(r.invoice), r.invoice.InvoiceNumber))
+		r.y += 30
+	}
+}
+
+// ensureSpace moves to a new page when height does not fit above the footer
+func (r *invoiceRenderer) ensureSpace(height float64) bool {
+	if r.y+height <= pdfFooterTop-10 {
+		return false
+	}
+	r.newPage()
+	return true
+}
+
+// header draws the logo, the issuer's fiscal data and the invoice identification
+func (r *invoiceRenderer) header() {
+	p := r.page
+	s := r.settings
+
+	if r.logo != nil {
+		w, h := r.logo.Size()
+		scale := minFloat(140/float64(w), 60/float64(h))
+		p.Image(r.logo, pdfMargin, r.y, float64(w)*scale, float64(h)*scale)
+	}
+
+	// Issuer block, right aligned
+	y := r.y + 12
+	p.SetTextColor(pdfTextColor)
+	p.SetFont(pdf.HelveticaBold, 12)
+	p.TextRight(pdfContentRight, y, s.DisplayName())
+	p.SetFont(pdf.Helvetica, 8.5)
+	issuer := []string{}
+	if s.TradeName != "" && s.LegalName != "" && s.TradeName != s.LegalName {
+		issuer = append(issuer, s.LegalName)
+	}
+	if s.TaxID != "" {
+		issuer = append(issuer, "NIF: "+s.TaxID)
+	}
+	issuer = append(issuer, s.AddressLines()...)
+	if contact := joinNonEmpty(" · ", s.Phone, s.Email); contact != "" {
+		issuer = append(issuer, contact)
+	}
+	if s.Website != "" {
+		issuer = append(issuer, s.Website)
+	}
+	for _, line := range issuer {
+		y += pdfLineHeight
+		p.TextRight(pdfContentRight, y, line)
+	}
+
+	r.y = maxFloat(y, r.y+60) + 30
+
+	// Title and identification
+	title := strings.ToUpper(invoiceTitle(r.invoice))
+	titleSize := 20.0
+	for titleSize > 12 && pdf.TextWidth(pdf.HelveticaBold, titleSize, title) > 250 {
+		titleSize--
+	}
+	p.SetFont(pdf.HelveticaBold, titleSize)
+	p.SetTextColor(r.accent)
+	p.Text(pdfMargin, r.y, title)
+
+	if r.invoice.IsDraft() {
+		p.SetFont(pdf.HelveticaBold, 9)
+		p.SetTextColor(pdfDraftColor)
+		p.Text(pdfMargin, r.y+14, "BORRADOR - Documento sin validez fiscal")
+	}
+
+	labels := []string{"Nº de factura", "Fecha de emisión", "Fecha de vencimiento"}
+	values := []string{r.invoice.InvoiceNumber, formatPDFDate(r.invoice.IssueDate), formatPDFDate(r.invoice.DueDate)}
+	if r.invoice.IsDraft() {
+		values[0] = "Pendiente de emisión"
+	}
+
+	x := 300.0
+	for i := range labels {
+		p.SetFont(pdf.Helvetica, 7.5)
+		p.SetTextColor(pdfMutedColor)
+		p.Text(x, r.y-10, labels[i])
+		p.SetFont(pdf.HelveticaBold, 9)
+		p.SetTextColor(pdfTextColor)
+		p.Text(x, r.y+2, values[i])
+		x += 88
+	}
+
+	r.y += 28
+}
+
+// rectification identifies the invoice corrected by a rectifying invoice and the reason
+func (r *invoiceRenderer) rectification() {
+	if !r.invoice.IsRectifying() || r.rectified == nil {
+		return
+	}
+
+	mode := "Rectificación por diferencias"
+	if r.invoice.RectificationMode != nil && *r.invoice.RectificationMode == domain.RectificationModeCancellation {
+		mode = "Anulación total"
+	}
+
+	lines := []string{
+		fmt.Sprintf("%s de la factura nº %s de fecha %s.", mode, r.rectified.InvoiceNumber, formatPDFDate(r.rectified.IssueDate)),
+	}
+	if reason := strings.TrimSpace(r.invoice.RectificationReason); reason != "" {
+		lines = append(lines, "Motivo: "+reason)
+	}
+
+	p := r.page
+	p.SetFont(pdf.Helvetica, 8.5)
+	p.SetTextColor(pdfTextColor)
+	for _, line := range lines {
+		for _, wrapped := range pdf.WrapText(pdf.Helvetica, 8.5, line, pdfContentRight-pdfMargin) {
+			p.Text(pdfMargin, r.y, wrapped)
+			r.y += pdfLineHeight
+		}
+	}
+	r.y += 10
+}
+
+// parties draws the fiscal data of the recipient: the payer, followed by the client as
+// beneficiary, or the client
+func (r *invoiceRenderer) parties() {
+	p := r.page
+	c := r.invoice.Recipient(r.client)
+
+	lines := []string{}
+	if c.TaxID != "" {
+		lines = append(lines, "NIF: "+c.TaxID)
+	}
+	lines = append(lines, c.AddressLines()...)
+	if c.Email != "" {
+		lines = append(lines, c.Email)
+	}
+	if r.invoice.HasPayer() && r.client != nil {
+		lines = append(lines, "Paciente: "+r.client.FullName())
+	}
+
+	height := 30 + float64(len(lines))*pdfLineHeight
+	p.FillRect(pdfMargin, r.y, pdfContentRight-pdfMargin, height, pdf.Color{R: 248, G: 249, B: 250})
+	p.FillRect(pdfMargin, r.y, 3, height, r.accent)
+
+	y := r.y + 13
+	p.SetFont(pdf.Helvetica, 7.5)
+	p.SetTextColor(pdfMutedColor)
+	p.Text(pdfMargin+12, y, "FACTURAR A")
+	y += 12
+	p.SetFont(pdf.HelveticaBold, 10)
+	p.SetTextColor(pdfTextColor)
+	p.Text(pdfMargin+12, y, c.Name)
+	p.SetFont(pdf.Helvetica, 8.5)
+	for _, line := range lines {
+		y += pdfLineHeight
+		p.Text(pdfMargin+12, y, line)
+	}
+
+	r.y += height + 20
+}
+
+// linesTable draws the invoice lines, breaking pages when needed
+func (r *invoiceRenderer) linesTable() {
+	r.tableHeader()
+
+	conceptWidth := invoiceTableColumns.concept - pdfMargin - 8
+	for _, line := range r.invoice.Lines {
+		description := pdf.WrapText(pdf.Helvetica, 8.5, line.Description, conceptWidth)
+		height := float64(len(description))*pdfLineHeight + 2*pdfRowPadding
+
+		if r.ensureSpace(height) {
+			r.tableHeader()
+		}
+
+		p := r.page
+		y := r.y + pdfRowPadding + 8
+		p.SetFont(pdf.Helvetica, 8.5)
+		p.SetTextColor(pdfTextColor)
+		for i, text := range description {
+			p.Text(pdfMargin+4, y+float64(i)*pdfLineHeight, text)
+		}
+
+		p.TextRight(invoiceTableColumns.quantity, y, formatPDFNumber(line.Quantity))
+		p.TextRight(invoiceTableColumns.price, y, formatPDFAmount(line.UnitPrice))
+		if line.DiscountPercent > 0 {
+			p.TextRight(invoiceTableColumns.discount, y, formatPDFPercent(line.DiscountPercent))
+		}
+		if line.IsExempt() {
+			p.TextRight(invoiceTableColumns.vat, y, "Exento")
+		} else {
+			p.TextRight(invoiceTableColumns.vat, y, formatPDFPercent(line.VATRate))
+		}
+		p.TextRight(invoiceTableColumns.amount-4, y, formatPDFAmount(line.BaseAmount))
+
+		r.y += height
+		p.Line(pdfMargin, r.y, pdfContentRight, r.y, 0.5, pdfRuleColor)
+	}
+
+	r.y += 15
+}
+
+func (r *invoiceRenderer) tableHeader() {
+	p := r.page
+	p.FillRect(pdfMargin, r.y, pdfContentRight-pdfMargin, 18, r.accent)
+	p.SetFont(pdf.HelveticaBold, 8)
+	p.SetTextColor(pdf.White)
+
+	y := r.y + 12
+	p.Text(pdfMargin+4, y, "Concepto")
+	p.TextRight(invoiceTableColumns.quantity, y, "Cant.")
+	p.TextRight(invoiceTableColumns.price, y, "Precio")
+	p.TextRight(invoiceTableColumns.discount, y, "Dto.")
+	p.TextRight(invoiceTableColumns.vat, y, "IVA")
+	p.TextRight(invoiceTableColumns.amount-4, y, "Importe")
+
+	r.y += 18
+}
+
+// totals draws the tax breakdown by rate, the IRPF withholding and the total
+func (r *invoiceRenderer) totals() {
+	breakdown, _ := r.invoice.TaxBreakdown()
+
+	rows := len(breakdown) + 4
+	if r.invoice.HasWithholding() {
+		rows++
+	}
+	r.ensureSpace(float64(rows)*14 + 30)
+
+	p := r.page
+	left := 300.0
+
+	// Breakdown by VAT rate
+	p.SetFont(pdf.HelveticaBold, 7.5)
+	p.SetTextColor(pdfMutedColor)
+	p.Text(left, r.y, "Base imponible")
+	p.TextRight(480, r.y, "Tipo IVA")
+	p.TextRight(pdfContentRight, r.y, "Cuota")
+	r.y += 4
+	p.Line(left, r.y, pdfContentRight, r.y, 0.5, pdfRuleColor)
+
+	p.SetFont(pdf.Helvetica, 8.5)
+	p.SetTextColor(pdfTextColor)
+	for _, group := range breakdown {
+		r.y += 13
+		p.Text(left, r.y, formatPDFAmount(group.BaseAmount))
+		if group.IsExempt() {
+			p.TextRight(480, r.y, "Exento ("+string(*group.VATExemption)+")")
+		} else {
+			p.TextRight(480, r.y, formatPDFPercent(group.VATRate))
+		}
+		p.TextRight(pdfContentRight, r.y, formatPDFAmount(group.VATAmount))
+	}
+	r.y += 20
+
+	// Totals
+	r.totalRow(left, "Base imponible", formatPDFAmount(r.invoice.BaseAmount))
+	r.totalRow(left, "IVA", formatPDFAmount(r.invoice.VATAmount))
+	if r.invoice.HasWithholding() {
+		r.totalRow(left, fmt.Sprintf("Retención IRPF (%s)", formatPDFPercent(r.invoice.IRPFRate)),
+			formatPDFAmount(r.invoice.IRPFAmount.Neg()))
+	}
+
+	r.y += 4
+	p.FillRect(left, r.y, pdfContentRight-left, 22, r.accent)
+	p.SetFont(pdf.HelveticaBold, 11)
+	p.SetTextColor(pdf.White)
+	p.Text(left+6, r.y+15, "TOTAL")
+	p.TextRight(pdfContentRight-6, r.y+15, formatPDFAmount(r.invoice.TotalAmount))
+	r.y += 40
+}
+
+// verification draws the VeriFactu QR code with its legend
+func (r *invoiceRenderer) verification() {
+	if r.qr == nil {
+		return
+	}
+
+	// At least 30 mm wide plus the quiet zone, as required for the tax agency QR code
+	const size = 90.0
+	r.ensureSpace(size + 20)
+
+	p := r.page
+	module := size / float64(r.qr.Size)
+	for y := 0; y < r.qr.Size; y++ {
+		// Merge the dark modules of a row into runs to keep the content stream small
+		for x := 0; x < r.qr.Size; {
+			if !r.qr.Black(x, y) {
+				x++
+				continue
+			}
+			run := 1
+			for r.qr.Black(x+run, y) {
+				run++
+			}
+			p.FillRect(pdfMargin+float64(x)*module, r.y+float64(y)*module, float64(run)*module, module, pdf.Black)
+			x += run
+		}
+	}
+
+	x := pdfMargin + size + 15
+	p.SetFont(pdf.HelveticaBold, 8.5)
+	p.SetTextColor(pdfTextColor)
+	p.Text(x, r.y+12, "QR tributario:")
+	p.SetFont(pdf.Helvetica, 8)
+	p.Text(x, r.y+25, "Factura verificable en la sede electrónica de la AEAT")
+	p.SetFont(pdf.HelveticaBold, 8)
+	p.Text(x, r.y+38, "VERI*FACTU")
+
+	r.y += size + 20
+}
+
+func (r *invoiceRenderer) totalRow(left float64, label, value string) {
+	p := r.page
+	p.SetFont(pdf.Helvetica, 9)
+	p.SetTextColor(pdfTextColor)
+	p.Text(left+6, r.y, label)
+	p.TextRight(pdfContentRight-6, r.y, value)
+	r.y += 14
+}
+
+// mentions draws the payment terms, exemption notices and notes
+func (r *invoiceRenderer) mentions() {
+	var mentions []string
+
+	payment := "Vencimiento: " + formatPDFDate(r.invoice.DueDate)
+	if r.settings.IBAN != "" {
+		payment += ". Forma de pago: transferencia bancaria a la cuenta " + formatIBAN(r.settings.IBAN) +
+			", indicando el número de factura como concepto"
+	}
+	mentions = append(mentions, payment+".")
+
+	seen := map[domain.VATExemptionCause]bool{}
+	for _, line := range r.invoice.Lines {
+		if line.VATExemption != nil && !seen[*line.VATExemption] {
+			seen[*line.VATExemption] = true
+			mentions = append(mentions, line.VATExemption.LegalMention()+".")
+		}
+	}
+
+	if r.invoice.HasWithholding() {
+		mentions = append(mentions, fmt.Sprintf(
+			"Factura sujeta a retención del %s a cuenta del IRPF.", formatPDFPercent(r.invoice.IRPFRate)))
+	}
+
+	width := pdfContentRight - pdfMargin
+	r.section("Condiciones y menciones legales", mentions, width)
+
+	if notes := strings.TrimSpace(r.invoice.Notes); notes != "" {
+		r.section("Observaciones", []string{notes}, width)
+	}
+}
+
+func (r *invoiceRenderer) section(title string, paragraphs []string, width float64) {
+	r.ensureSpace(30)
+	r.page.SetFont(pdf.HelveticaBold, 8.5)
+	r.page.SetTextColor(r.accent)
+	r.page.Text(pdfMargin, r.y, title)
+	r.y += 13
+
+	for _, paragraph := range paragraphs {
+		for _, line := range pdf.WrapText(pdf.Helvetica, 8, paragraph, width) {
+			r.ensureSpace(pdfLineHeight)
+			r.page.SetFont(pdf.Helvetica, 8)
+			r.page.SetTextColor(pdfTextColor)
+			r.page.Text(pdfMargin, r.y, line)
+			r.y += 10
+		}
+		r.y += 3
+	}
+	r.y += 8
+}
+
+// footers draws the custom footer, registry mention and page numbers on every page
+func (r *invoiceRenderer) footers() {
+	footer := joinNonEmpty("\n", r.settings.FooterText, r.settings.RegistryInfo)
+	lines := pdf.WrapText(pdf.Helvetica, 7, footer, pdfContentRight-pdfMargin-70)
+
+	for i, p := range r.pages {
+		p.Line(pdfMargin, pdfFooterTop, pdfContentRight, pdfFooterTop, 0.5, pdfRuleColor)
+		p.SetFont(pdf.Helvetica, 7)
+		p.SetTextColor(pdfMutedColor)
+
+		y := pdfFooterTop + 12
+		for _, line := range lines {
+			if y > 830 {
+				break
+			}
+			p.Text(pdfMargin, y, line)
+			y += 9
+		}
+		p.TextRight(pdfContentRight, pdfFooterTop+12, fmt.Sprintf("Página %d de %d", i+1, len(r.pages)))
+	}
+}
+
+// invoiceTitle returns the document title printed on the invoice
+func invoiceTitle(invoice *domain.Invoice) string {
+	title := "Factura"
+	switch invoice.InvoiceType {
+	case domain.InvoiceTypeRectifying:
+		title = "Factura rectificativa"
+	case domain.InvoiceTypeSimplified:
+		title = "Factura simplificada"
+	}
+	if invoice.IsDraft() {
+		return "Borrador de " + strings.ToLower(title)
+	}
+	return title
+}
+
+// formatPDFAmount formats an amount the Spanish way, e.g. "1.234,56 €"
+func formatPDFAmount(m money.Money) string {
+	decimal := m.Abs().Decimal()
+	integer, cents, _ := strings.Cut(decimal, ".")
+
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteByte('.')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	sign := ""
+	if m.IsNegative() {
+		sign = "-"
+	}
+
+	symbol := string(m.Currency())
+	if m.Currency() == money.EUR {
+		symbol = "€"
+	}
+	return fmt.Sprintf("%s%s,%s %s", sign, grouped.String(), cents, symbol)
+}
+
+// formatPDFNumber formats a quantity with a decimal comma, without trailing zeros
+func formatPDFNumber(v float64) string {
+	return strings.Replace(strconv.FormatFloat(v, 'f', -1, 64), ".", ",", 1)
+}
+
+// formatPDFPercent formats a rate such as 21 or 10.5 as "21%" or "10,5%"
+func formatPDFPercent(v float64) string {
+	return formatPDFNumber(v) + "%"
+}
+
+func formatPDFDate(t interface{ Format(string) string }) string {
+	return t.Format("02/01/2006")
+}
+
+// formatIBAN groups an IBAN in blocks of four characters
+func formatIBAN(iban string) string {
+	var b strings.Builder
+	for i, c := range iban {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			nonEmpty = append(nonEmpty, strings.TrimSpace(part))
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+)
+
+// InvoiceRecordReceipt is the answer of the tax agency to a submitted record
+type InvoiceRecordReceipt struct {
+	Accepted  bool
+	Reference string // Identifier of the submission (CSV) when accepted
+	Error     string // Reason of the rejection
+}
+
+// InvoiceRecordSender transmits invoice records to the tax agency. Records are sent one at a
+// time in chain order; an error means the record could not be delivered and must be retried.
+type InvoiceRecordSender interface {
+	Send(ctx context.Context, record *domain.InvoiceRecord) (*InvoiceRecordReceipt, error)
+}
+
+// NewInvoiceRecordSender creates the sender selected in the configuration
+func NewInvoiceRecordSender(driver string) (InvoiceRecordSender, error) {
+	switch driver {
+	case "", "fake":
+		return NewFakeInvoiceRecordSender(), nil
+	default:
+		return nil, fmt.Errorf("unknown invoice record sender %q", driver)
+	}
+}
+
+// FakeInvoiceRecordSender is a local stand-in for the tax agency, for development and tests.
+// Like the agency, it rejects records that do not chain to the last one it received.
+type FakeInvoiceRecordSender struct {
+	mu       sync.Mutex
+	sent     []*domain.InvoiceRecord
+	lastHash string
+
+	// Reject, when set, returns the rejection reason of a record, or "" to accept it
+	Reject func(record *domain.InvoiceRecord) string
+}
+
+// NewFakeInvoiceRecordSender creates a fake sender that accepts every well-chained record
+func NewFakeInvoiceRecordSender() *FakeInvoiceRecordSender {
+	return &FakeInvoiceRecordSender{}
+}
+
+// Send accepts the record unless it breaks the chain or Reject refuses it
+func (f *FakeInvoiceRecordSender) Send(ctx context.Context, record *domain.InvoiceRecord) (*InvoiceRecordReceipt, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = append(f.sent, record)
+
+	if f.lastHash != "" && (record.PreviousHash == nil || *record.PreviousHash != f.lastHash) {
+		return &InvoiceRecordReceipt{Error: "la huella anterior no coincide con el último registro recibido"}, nil
+	}
+	f.lastHash = record.Hash
+
+	if f.Reject != nil {
+		if reason := f.Reject(record); reason != "" {
+			return &InvoiceRecordReceipt{Error: reason}, nil
+		}
+	}
+
+	return &InvoiceRecordReceipt{
+		Accepted:  true,
+		Reference: fmt.Sprintf("FAKE-%08d", record.Sequence),
+	}, nil
+}
+
+// Sent returns the records received so far, accepted or not
+func (f *FakeInvoiceRecordSender) Sent() []*domain.InvoiceRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]*domain.InvoiceRecord(nil), f.sent...)
+}
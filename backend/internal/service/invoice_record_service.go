@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// invoiceRecordBatchSize is the number of records read or submitted at a time
+const invoiceRecordBatchSize = 500
+
+// InvoiceChainProblem describes a record that breaks the chain
+type InvoiceChainProblem struct {
+	Sequence      int64     `json:"sequence"`
+	RecordID      uuid.UUID `json:"recordId"`
+	InvoiceNumber string    `json:"invoiceNumber"`
+	Problem       string    `json:"problem"`
+}
+
+// InvoiceChainVerification is the result of checking the whole invoice record chain
+type InvoiceChainVerification struct {
+	Valid              bool                  `json:"valid"`
+	Records            int                   `json:"records"`
+	LastHash           string                `json:"lastHash,omitempty"`
+	Problems           []InvoiceChainProblem `json:"problems"`
+	UnrecordedInvoices []uuid.UUID           `json:"unrecordedInvoices"` // Issued since the chain started without a record
+	VerifiedAt         time.Time             `json:"verifiedAt"`
+}
+
+// InvoiceRecordSubmission summarises a transmission run to the tax agency
+type InvoiceRecordSubmission struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+	Pending  int `json:"pending"` // Left for the next run after a delivery failure
+}
+
+// InvoiceRecordService keeps the VeriFactu chain of invoice records and sends it to the tax agency
+type InvoiceRecordService interface {
+	InvoiceIssueRecording
+
+	// GetInvoiceRecords retrieves the records of an invoice
+	GetInvoiceRecords(ctx context.Context, invoiceID uuid.UUID) ([]*domain.InvoiceRecord, error)
+
+	// VerifyChain recomputes every hash, link and signature of the chain
+	VerifyChain(ctx context.Context) (*InvoiceChainVerification, error)
+
+	// SubmitPending sends the pending records to the tax agency in chain order
+	SubmitPending(ctx context.Context) (*InvoiceRecordSubmission, error)
+}
+
+type invoiceRecordService struct {
+	recordRepo   repository.InvoiceRecordRepository
+	invoiceRepo  repository.InvoiceRepository
+	settingsRepo repository.BillingSettingsRepository
+	sender       InvoiceRecordSender
+	signingKey   []byte
+	now          func() time.Time
+}
+
+// NewInvoiceRecordService creates a new invoice record service; records are signed with signingKey
+func NewInvoiceRecordService(
+	recordRepo repository.InvoiceRecordRepository,
+	invoiceRepo repository.InvoiceRepository,
+	settingsRepo repository.BillingSettingsRepository,
+	sender InvoiceRecordSender,
+	signingKey []byte,
+) InvoiceRecordService {
+	return &invoiceRecordService{
+		recordRepo:   recordRepo,
+		invoiceRepo:  invoiceRepo,
+		settingsRepo: settingsRepo,
+		sender:       sender,
+		signingKey:   signingKey,
+		now:          time.Now,
+	}
+}
+
+// IssueRecorder prepares the records of the issue of an invoice. Issuing a cancelling
+// rectifying invoice also records the cancellation of the invoice it cancels.
+func (s *invoiceRecordService) IssueRecorder(ctx context.Context, invoice *domain.Invoice) (repository.InvoiceIssueRecorder, error) {
+	settings, err := s.settingsRepo.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing settings: %w", err)
+	}
+	if settings.TaxID == "" {
+		return nil, errors.NewValidationError("billing settings are incomplete", map[string][]string{
+			"taxId": {"the clinic's tax ID is required to record issued invoices"},
+		})
+	}
+
+	recorder := &issueRecorder{issuerTaxID: settings.TaxID, signingKey: s.signingKey, generatedAt: s.now()}
+
+	if !invoice.IsRectifying() || invoice.RectificationMode == nil ||
+		*invoice.RectificationMode != domain.RectificationModeCancellation || invoice.RectifiedInvoiceID == nil {
+		return recorder, nil
+	}
+
+	recorder.cancelled, err = s.invoiceRepo.GetByID(ctx, *invoice.RectifiedInvoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cancelled invoice: %w", err)
+	}
+
+	return recorder, nil
+}
+
+// issueRecorder builds and links the records of an issue inside the issuing transaction
+type issueRecorder struct {
+	issuerTaxID string
+	signingKey  []byte
+	generatedAt time.Time
+	cancelled   *domain.Invoice
+}
+
+// IssueRecords builds the registration of the numbered invoice and, for a cancelling
+// rectifying invoice, the cancellation of the invoice it cancels
+func (r *issueRecorder) IssueRecords(invoice *domain.Invoice) ([]*domain.InvoiceRecord, error) {
+	registration, err := domain.NewInvoiceRecord(domain.InvoiceRecordTypeRegistration, invoice, r.issuerTaxID, r.generatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build invoice record: %w", err)
+	}
+	if r.cancelled == nil {
+		return []*domain.InvoiceRecord{registration}, nil
+	}
+
+	cancellation, err := domain.NewInvoiceRecord(domain.InvoiceRecordTypeCancellation, r.cancelled, r.issuerTaxID, r.generatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build invoice record: %w", err)
+	}
+
+	return []*domain.InvoiceRecord{registration, cancellation}, nil
+}
+
+// SealRecord links a record to the last record of the chain and signs it
+func (r *issueRecorder) SealRecord(record, previous *domain.InvoiceRecord) {
+	record.Chain(previous, r.signingKey)
+}
+
+// GetInvoiceRecords retrieves the records of an invoice
+func (s *invoiceRecordService) GetInvoiceRecords(ctx context.Context, invoiceID uuid.UUID) ([]*domain.InvoiceRecord, error) {
+	if _, err := s.invoiceRepo.GetByID(ctx, invoiceID); err != nil {
+		return nil, err
+	}
+
+	return s.recordRepo.GetByInvoiceID(ctx, invoiceID)
+}
+
+// VerifyChain walks the chain from the first record, checking that sequences have no gaps,
+// that each record points to the hash of the previous one, that each hash matches the
+// fingerprinted data and that each signature is valid
+func (s *invoiceRecordService) VerifyChain(ctx context.Context) (*InvoiceChainVerification, error) {
+	result := &InvoiceChainVerification{
+		Problems:           []InvoiceChainProblem{},
+		UnrecordedInvoices: []uuid.UUID{},
+		VerifiedAt:         s.now(),
+	}
+
+	var first, previous *domain.InvoiceRecord
+	for {
+		after := int64(0)
+		if previous != nil {
+			after = previous.Sequence
+		}
+
+		records, err := s.recordRepo.ListChain(ctx, after, invoiceRecordBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			for _, problem := range s.chainProblems(record, previous) {
+				result.Problems = append(result.Problems, InvoiceChainProblem{
+					Sequence:      record.Sequence,
+					RecordID:      record.ID,
+					InvoiceNumber: record.InvoiceNumber,
+					Problem:       problem,
+				})
+			}
+
+			if first == nil {
+				first = record
+			}
+			previous = record
+			result.Records++
+		}
+
+		if len(records) < invoiceRecordBatchSize {
+			break
+		}
+	}
+
+	// Every invoice issued since the chain started must have been recorded
+	if first != nil {
+		result.LastHash = previous.Hash
+
+		unrecorded, err := s.recordRepo.GetUnrecordedInvoiceIDs(ctx, first.IssueDate)
+		if err != nil {
+			return nil, err
+		}
+		result.UnrecordedInvoices = unrecorded
+	}
+
+	result.Valid = len(result.Problems) == 0 && len(result.UnrecordedInvoices) == 0
+	return result, nil
+}
+
+// chainProblems returns what is wrong with a record following previous (nil for the first one)
+func (s *invoiceRecordService) chainProblems(record, previous *domain.InvoiceRecord) []string {
+	var problems []string
+
+	expectedSequence := int64(1)
+	if previous != nil {
+		expectedSequence = previous.Sequence + 1
+	}
+	if record.Sequence != expectedSequence {
+		problems = append(problems, fmt.Sprintf("expected sequence %d: records are missing", expectedSequence))
+	}
+
+	switch {
+	case previous == nil && record.PreviousHash != nil:
+		problems = append(problems, "first record points to a previous record")
+	case previous != nil && (record.PreviousHash == nil || *record.PreviousHash != previous.Hash):
+		problems = append(problems, "previous hash does not match the previous record")
+	}
+
+	if record.ComputeHash() != record.Hash {
+		problems = append(problems, "hash does not match the record data")
+	}
+	if !record.HasValidSignature(s.signingKey) {
+		problems = append(problems, "signature is not valid")
+	}
+
+	return problems
+}
+
+// SubmitPending sends the pending records in chain order, stopping at the first delivery
+// failure so that the agency always receives the chain without gaps
+func (s *invoiceRecordService) SubmitPending(ctx context.Context) (*InvoiceRecordSubmission, error) {
+	result := &InvoiceRecordSubmission{}
+
+	records, err := s.recordRepo.GetPendingSubmission(ctx, invoiceRecordBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, record := range records {
+		receipt, err := s.sender.Send(ctx, record)
+		if err != nil {
+			log.Printf("[WARN] Failed to submit invoice record %d, retrying later: %v", record.Sequence, err)
+			result.Pending = len(records) - i
+			return result, nil
+		}
+
+		submittedAt := s.now()
+		record.SubmittedAt = &submittedAt
+		if receipt.Accepted {
+			reference := receipt.Reference
+			record.SubmissionStatus = domain.InvoiceRecordSubmissionAccepted
+			record.SubmissionReference = &reference
+			record.SubmissionError = nil
+			result.Accepted++
+		} else {
+			reason := receipt.Error
+			record.SubmissionStatus = domain.InvoiceRecordSubmissionRejected
+			record.SubmissionError = &reason
+			result.Rejected++
+		}
+
+		if err := s.recordRepo.UpdateSubmission(ctx, record); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
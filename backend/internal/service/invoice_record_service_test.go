@@ -0,0 +1,533 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testSigningKey = []byte("test-signing-key")
+
+// memoryInvoiceRecordRepository keeps the chain in memory, serialising appends like the database
+type memoryInvoiceRecordRepository struct {
+	mu         sync.Mutex
+	records    []*domain.InvoiceRecord
+	unrecorded []uuid.UUID
+}
+
+func (r *memoryInvoiceRecordRepository) Append(ctx context.Context, record *domain.InvoiceRecord, seal func(previous *domain.InvoiceRecord)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var previous *domain.InvoiceRecord
+	if len(r.records) > 0 {
+		previous = r.records[len(r.records)-1]
+	}
+	seal(previous)
+
+	for _, existing := range r.records {
+		if existing.InvoiceID == record.InvoiceID && existing.RecordType == record.RecordType {
+			return stderrors.New("duplicate invoice record")
+		}
+	}
+	r.records = append(r.records, record)
+	return nil
+}
+
+func (r *memoryInvoiceRecordRepository) GetByInvoiceID(ctx context.Context, invoiceID uuid.UUID) ([]*domain.InvoiceRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := []*domain.InvoiceRecord{}
+	for _, record := range r.records {
+		if record.InvoiceID == invoiceID {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (r *memoryInvoiceRecordRepository) ListChain(ctx context.Context, afterSequence int64, limit int) ([]*domain.InvoiceRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := []*domain.InvoiceRecord{}
+	for _, record := range r.records {
+		if record.Sequence > afterSequence && len(records) < limit {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (r *memoryInvoiceRecordRepository) GetPendingSubmission(ctx context.Context, limit int) ([]*domain.InvoiceRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := []*domain.InvoiceRecord{}
+	for _, record := range r.records {
+		if record.IsPendingSubmission() && len(records) < limit {
+			copied := *record
+			records = append(records, &copied)
+		}
+	}
+	return records, nil
+}
+
+func (r *memoryInvoiceRecordRepository) UpdateSubmission(ctx context.Context, record *domain.InvoiceRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.records {
+		if existing.ID == record.ID {
+			existing.SubmissionStatus = record.SubmissionStatus
+			existing.SubmissionReference = record.SubmissionReference
+			existing.SubmissionError = record.SubmissionError
+			existing.SubmittedAt = record.SubmittedAt
+			return nil
+		}
+	}
+	return stderrors.New("invoice record not found")
+}
+
+func (r *memoryInvoiceRecordRepository) GetUnrecordedInvoiceIDs(ctx context.Context, since time.Time) ([]uuid.UUID, error) {
+	return append([]uuid.UUID{}, r.unrecorded...), nil
+}
+
+// failingInvoiceRecordSender fails to deliver from the given sequence on
+type failingInvoiceRecordSender struct {
+	*FakeInvoiceRecordSender
+	failFrom int64
+}
+
+func (f *failingInvoiceRecordSender) Send(ctx context.Context, record *domain.InvoiceRecord) (*InvoiceRecordReceipt, error) {
+	if record.Sequence >= f.failFrom {
+		return nil, stderrors.New("connection refused")
+	}
+	return f.FakeInvoiceRecordSender.Send(ctx, record)
+}
+
+func newInvoiceRecordTestService(sender InvoiceRecordSender) (*invoiceRecordService, *memoryInvoiceRecordRepository, *MockInvoiceRepository) {
+	recordRepo := &memoryInvoiceRecordRepository{}
+	invoiceRepo := new(MockInvoiceRepository)
+	settingsRepo := new(MockBillingSettingsRepository)
+	settingsRepo.On("Get", mock.Anything).Return(pdfTestSettings(), nil)
+
+	svc := NewInvoiceRecordService(recordRepo, invoiceRepo, settingsRepo, sender, testSigningKey).(*invoiceRecordService)
+	return svc, recordRepo, invoiceRepo
+}
+
+// recordIssue appends the records of an issue like the invoice repository does when issuing
+func recordIssue(ctx context.Context, svc *invoiceRecordService, invoice *domain.Invoice) error {
+	recorder, err := svc.IssueRecorder(ctx, invoice)
+	if err != nil {
+		return err
+	}
+
+	records, err := recorder.IssueRecords(invoice)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		err := svc.recordRepo.Append(ctx, record, func(previous *domain.InvoiceRecord) {
+			recorder.SealRecord(record, previous)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordIssuedInvoices records n issued invoices
+func recordIssuedInvoices(t *testing.T, svc *invoiceRecordService, n int) []*domain.Invoice {
+	t.Helper()
+
+	invoices := make([]*domain.Invoice, 0, n)
+	for i := 0; i < n; i++ {
+		invoice := issuedTestInvoice(t)
+		invoice.InvoiceNumber = testOrdinarySeries.FormatNumber(2025, i+1)
+		require.NoError(t, recordIssue(context.Background(), svc, invoice))
+		invoices = append(invoices, invoice)
+	}
+	return invoices
+}
+
+func TestInvoiceRecord_FingerprintMatchesSpecification(t *testing.T) {
+	// Worked example of the VeriFactu specification for the first registration record
+	record := &domain.InvoiceRecord{
+		RecordType:      domain.InvoiceRecordTypeRegistration,
+		IssuerTaxID:     "89890001K",
+		InvoiceNumber:   "12345678/G33",
+		IssueDate:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		InvoiceTypeCode: domain.InvoiceTypeCodeOrdinary,
+		VATAmount:       money.MustParse("12.35"),
+		TotalAmount:     money.MustParse("123.45"),
+		GeneratedAt:     time.Date(2024, 1, 1, 18, 20, 30, 0, time.UTC),
+	}
+
+	assert.Equal(t, "IDEmisorFactura=89890001K&NumSerieFactura=12345678/G33&FechaExpedicionFactura=01-01-2024"+
+		"&TipoFactura=F1&CuotaTotal=12.35&ImporteTotal=123.45&Huella=&FechaHoraHusoGenRegistro=2024-01-01T19:20:30+01:00",
+		record.Fingerprint())
+	assert.Equal(t, "3C464DAF61ACB827C65FDA19F352A4E3BDC2C640E9E9FC4CC058073F38F12F60", record.ComputeHash())
+
+	previous := record.ComputeHash()
+	cancellation := &domain.InvoiceRecord{
+		RecordType:    domain.InvoiceRecordTypeCancellation,
+		IssuerTaxID:   "89890001K",
+		InvoiceNumber: "12345678/G33",
+		IssueDate:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		PreviousHash:  &previous,
+		GeneratedAt:   time.Date(2024, 7, 1, 17, 20, 30, 0, time.UTC),
+	}
+	assert.Equal(t, "IDEmisorFacturaAnulada=89890001K&NumSerieFacturaAnulada=12345678/G33&FechaExpedicionFacturaAnulada=01-01-2024"+
+		"&Huella="+previous+"&FechaHoraHusoGenRegistro=2024-07-01T19:20:30+02:00", cancellation.Fingerprint())
+}
+
+func TestInvoiceQRURL(t *testing.T) {
+	invoice := issuedTestInvoice(t)
+
+	url, err := domain.InvoiceQRURL("", "b12345678", invoice)
+	require.NoError(t, err)
+
+	// The total excludes the IRPF withholding: 340 + 21 VAT
+	assert.Equal(t, domain.DefaultInvoiceQRBaseURL+"?nif=B12345678&numserie=F_2025_0010&fecha=01-03-2025&importe=361.00", url)
+}
+
+func TestInvoiceRecordService_IssueRecorder_ChainsSignedRecords(t *testing.T) {
+	svc, recordRepo, _ := newInvoiceRecordTestService(NewFakeInvoiceRecordSender())
+
+	invoices := recordIssuedInvoices(t, svc, 3)
+
+	require.Len(t, recordRepo.records, 3)
+	for i, record := range recordRepo.records {
+		assert.Equal(t, int64(i+1), record.Sequence)
+		assert.Equal(t, invoices[i].ID, record.InvoiceID)
+		assert.Equal(t, "B12345678", record.IssuerTaxID)
+		assert.Equal(t, domain.InvoiceTypeCodeOrdinary, record.InvoiceTypeCode)
+		assert.Equal(t, "361.00", record.TotalAmount.Decimal())
+		assert.Equal(t, record.ComputeHash(), record.Hash)
+		assert.True(t, record.HasValidSignature(testSigningKey))
+		assert.False(t, record.HasValidSignature([]byte("another key")))
+		assert.Equal(t, domain.InvoiceRecordSubmissionPending, record.SubmissionStatus)
+
+		if i == 0 {
+			assert.Nil(t, record.PreviousHash)
+		} else {
+			require.NotNil(t, record.PreviousHash)
+			assert.Equal(t, recordRepo.records[i-1].Hash, *record.PreviousHash)
+		}
+	}
+}
+
+func TestInvoiceRecordService_IssueRecorder_RecordsCancellation(t *testing.T) {
+	svc, recordRepo, invoiceRepo := newInvoiceRecordTestService(NewFakeInvoiceRecordSender())
+	ctx := context.Background()
+
+	original := recordIssuedInvoices(t, svc, 1)[0]
+	invoiceRepo.On("GetByID", ctx, original.ID).Return(original, nil)
+
+	mode := domain.RectificationModeCancellation
+	cancelling := issuedTestInvoice(t)
+	cancelling.InvoiceNumber = "R_2025_0001"
+	cancelling.InvoiceType = domain.InvoiceTypeRectifying
+	cancelling.RectifiedInvoiceID = &original.ID
+	cancelling.RectificationMode = &mode
+	for _, line := range cancelling.Lines {
+		line.Quantity = -line.Quantity
+	}
+	require.NoError(t, cancelling.CalculateAmounts())
+
+	require.NoError(t, recordIssue(ctx, svc, cancelling))
+
+	require.Len(t, recordRepo.records, 3)
+	registration, cancellation := recordRepo.records[1], recordRepo.records[2]
+
+	assert.Equal(t, cancelling.ID, registration.InvoiceID)
+	assert.Equal(t, domain.InvoiceTypeCodeRectifying, registration.InvoiceTypeCode)
+	assert.Equal(t, "-361.00", registration.TotalAmount.Decimal())
+
+	assert.Equal(t, domain.InvoiceRecordTypeCancellation, cancellation.RecordType)
+	assert.Equal(t, original.ID, cancellation.InvoiceID)
+	assert.Equal(t, original.InvoiceNumber, cancellation.InvoiceNumber)
+	assert.Contains(t, cancellation.Fingerprint(), "NumSerieFacturaAnulada=F_2025_0001")
+	assert.Equal(t, registration.Hash, *cancellation.PreviousHash)
+}
+
+func TestInvoiceRecordService_IssueRecorder_RequiresTaxID(t *testing.T) {
+	recordRepo := &memoryInvoiceRecordRepository{}
+	settingsRepo := new(MockBillingSettingsRepository)
+	settingsRepo.On("Get", mock.Anything).Return(&domain.BillingSettings{}, nil)
+	svc := NewInvoiceRecordService(recordRepo, new(MockInvoiceRepository), settingsRepo, NewFakeInvoiceRecordSender(), testSigningKey)
+
+	_, err := svc.IssueRecorder(context.Background(), issuedTestInvoice(t))
+
+	requireValidationError(t, err)
+	assert.Empty(t, recordRepo.records)
+}
+
+func TestInvoiceRecordService_IssueRecorder_ConcurrentIssuesKeepOneChain(t *testing.T) {
+	svc, recordRepo, _ := newInvoiceRecordTestService(NewFakeInvoiceRecordSender())
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, recordIssue(ctx, svc, issuedTestInvoice(t)))
+		}()
+	}
+	wg.Wait()
+
+	result, err := svc.VerifyChain(ctx)
+	require.NoError(t, err)
+	assert.True(t, result.Valid, "%+v", result.Problems)
+	assert.Equal(t, 50, result.Records)
+	assert.Equal(t, recordRepo.records[49].Hash, result.LastHash)
+}
+
+func TestInvoiceRecordService_VerifyChain_DetectsTampering(t *testing.T) {
+	cases := map[string]struct {
+		tamper   func(records []*domain.InvoiceRecord) []*domain.InvoiceRecord
+		sequence int64
+		problem  string
+	}{
+		"altered amount": {
+			tamper: func(records []*domain.InvoiceRecord) []*domain.InvoiceRecord {
+				records[1].TotalAmount = money.MustParse("1.00")
+				return records
+			},
+			sequence: 2,
+			problem:  "hash does not match the record data",
+		},
+		"altered and rehashed record": {
+			tamper: func(records []*domain.InvoiceRecord) []*domain.InvoiceRecord {
+				records[1].TotalAmount = money.MustParse("1.00")
+				records[1].Hash = records[1].ComputeHash()
+				records[1].Signature = records[1].ComputeSignature(testSigningKey)
+				return records
+			},
+			sequence: 3,
+			problem:  "previous hash does not match the previous record",
+		},
+		"forged signature": {
+			tamper: func(records []*domain.InvoiceRecord) []*domain.InvoiceRecord {
+				records[2].Signature = records[2].ComputeSignature([]byte("stolen key"))
+				return records
+			},
+			sequence: 3,
+			problem:  "signature is not valid",
+		},
+		"removed record": {
+			tamper: func(records []*domain.InvoiceRecord) []*domain.InvoiceRecord {
+				return append(records[:1], records[2:]...)
+			},
+			sequence: 3,
+			problem:  "expected sequence 2: records are missing",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			svc, recordRepo, _ := newInvoiceRecordTestService(NewFakeInvoiceRecordSender())
+			recordIssuedInvoices(t, svc, 4)
+
+			recordRepo.records = tc.tamper(recordRepo.records)
+
+			result, err := svc.VerifyChain(context.Background())
+			require.NoError(t, err)
+			assert.False(t, result.Valid)
+			require.NotEmpty(t, result.Problems)
+			assert.Equal(t, tc.sequence, result.Problems[0].Sequence)
+			assert.Equal(t, tc.problem, result.Problems[0].Problem)
+		})
+	}
+}
+
+func TestInvoiceRecordService_VerifyChain_ReportsUnrecordedInvoices(t *testing.T) {
+	svc, recordRepo, _ := newInvoiceRecordTestService(NewFakeInvoiceRecordSender())
+	ctx := context.Background()
+
+	empty, err := svc.VerifyChain(ctx)
+	require.NoError(t, err)
+	assert.True(t, empty.Valid)
+	assert.Zero(t, empty.Records)
+
+	recordIssuedInvoices(t, svc, 2)
+	missing := uuid.New()
+	recordRepo.unrecorded = []uuid.UUID{missing}
+
+	result, err := svc.VerifyChain(ctx)
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Empty(t, result.Problems)
+	assert.Equal(t, []uuid.UUID{missing}, result.UnrecordedInvoices)
+}
+
+func TestInvoiceRecordService_SubmitPending(t *testing.T) {
+	sender := NewFakeInvoiceRecordSender()
+	svc, recordRepo, _ := newInvoiceRecordTestService(sender)
+	ctx := context.Background()
+
+	invoices := recordIssuedInvoices(t, svc, 3)
+	sender.Reject = func(record *domain.InvoiceRecord) string {
+		if record.InvoiceID == invoices[1].ID {
+			return "NIF del destinatario no identificado"
+		}
+		return ""
+	}
+
+	result, err := svc.SubmitPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &InvoiceRecordSubmission{Accepted: 2, Rejected: 1}, result)
+
+	assert.Equal(t, domain.InvoiceRecordSubmissionAccepted, recordRepo.records[0].SubmissionStatus)
+	assert.Equal(t, "FAKE-00000001", *recordRepo.records[0].SubmissionReference)
+	assert.Equal(t, domain.InvoiceRecordSubmissionRejected, recordRepo.records[1].SubmissionStatus)
+	assert.Equal(t, "NIF del destinatario no identificado", *recordRepo.records[1].SubmissionError)
+
+	assert.Equal(t, domain.InvoiceRecordSubmissionAccepted, recordRepo.records[2].SubmissionStatus)
+	assert.NotNil(t, recordRepo.records[2].SubmittedAt)
+
+	// Nothing is left to send
+	again, err := svc.SubmitPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &InvoiceRecordSubmission{}, again)
+	assert.Len(t, sender.Sent(), 3)
+}
+
+func TestFakeInvoiceRecordSender_RejectsBrokenChain(t *testing.T) {
+	sender := NewFakeInvoiceRecordSender()
+	svc, recordRepo, _ := newInvoiceRecordTestService(sender)
+	ctx := context.Background()
+
+	recordIssuedInvoices(t, svc, 3)
+
+	receipt, err := sender.Send(ctx, recordRepo.records[0])
+	require.NoError(t, err)
+	assert.True(t, receipt.Accepted)
+
+	// Skipping a record breaks the chain seen by the agency
+	receipt, err = sender.Send(ctx, recordRepo.records[2])
+	require.NoError(t, err)
+	assert.False(t, receipt.Accepted)
+	assert.NotEmpty(t, receipt.Error)
+}
+
+func TestInvoiceRecordService_SubmitPending_StopsOnDeliveryFailure(t *testing.T) {
+	sender := &failingInvoiceRecordSender{FakeInvoiceRecordSender: NewFakeInvoiceRecordSender(), failFrom: 2}
+	svc, recordRepo, _ := newInvoiceRecordTestService(sender)
+	ctx := context.Background()
+
+	recordIssuedInvoices(t, svc, 4)
+
+	result, err := svc.SubmitPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &InvoiceRecordSubmission{Accepted: 1, Pending: 3}, result)
+	for _, record := range recordRepo.records[1:] {
+		assert.True(t, record.IsPendingSubmission())
+	}
+
+	// Once the agency is reachable the rest of the chain is sent in order
+	sender.failFrom = 100
+	result, err = svc.SubmitPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &InvoiceRecordSubmission{Accepted: 3}, result)
+}
+
+// recordingIssueHook remembers the invoices it is notified of
+type recordingIssueHook struct {
+	issued []*domain.Invoice
+	err    error
+}
+
+func (h *recordingIssueHook) OnInvoiceIssued(ctx context.Context, invoice *domain.Invoice) error {
+	h.issued = append(h.issued, invoice)
+	return h.err
+}
+
+func TestInvoiceService_NotifiesIssueHooks(t *testing.T) {
+	_, invoiceRepo, clientRepo, serviceTypeRepo := newInvoiceTestService()
+	seriesRepo := new(MockInvoiceSeriesRepository)
+	seriesRepo.On("GetDefault", mock.Anything, domain.InvoiceTypeOrdinary, mock.Anything).Return(testOrdinarySeries, nil)
+
+	// A failing hook is logged and does not undo the issue
+	hook := &recordingIssueHook{err: stderrors.New("chain unavailable")}
+	svc := NewInvoiceService(invoiceRepo, clientRepo, newNoPayerRepository(), serviceTypeRepo, seriesRepo, new(MockAppointmentRepository), new(MockEmployeeRepository), newNoUsageRepository(), newNotCoveredRepository(), InvoiceTaxPolicy{}, nil, hook)
+	ctx := context.Background()
+
+	clientID := uuid.New()
+	clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+	invoiceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+	invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).Run(issueAs("F_2026_0001")).Return(nil)
+
+	req := &CreateInvoiceRequest{
+		ClientID:    clientID,
+		IssueDate:   time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		DueDate:     time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+		BaseAmount:  money.MustParse("60"),
+		Description: "Sesión individual",
+	}
+
+	// Drafts are not notified
+	draft, err := svc.CreateDraftInvoice(ctx, req)
+	require.NoError(t, err)
+	assert.Empty(t, hook.issued)
+
+	invoiceRepo.On("GetByID", ctx, draft.ID).Return(draft, nil)
+	issued, err := svc.IssueInvoice(ctx, draft.ID)
+	require.NoError(t, err)
+	require.Len(t, hook.issued, 1)
+	assert.Equal(t, "F_2026_0001", hook.issued[0].InvoiceNumber)
+
+	direct, err := svc.CreateInvoice(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, hook.issued, 2)
+	assert.Same(t, direct, hook.issued[1])
+	assert.Equal(t, domain.InvoiceStatusUnpaid, issued.Status)
+}
+
+func TestInvoiceService_IssueFailsWithoutItsRecord(t *testing.T) {
+	_, invoiceRepo, clientRepo, serviceTypeRepo := newInvoiceTestService()
+	seriesRepo := new(MockInvoiceSeriesRepository)
+	seriesRepo.On("GetDefault", mock.Anything, domain.InvoiceTypeOrdinary, mock.Anything).Return(testOrdinarySeries, nil)
+
+	// Without the clinic's tax ID the issue cannot be recorded
+	settingsRepo := new(MockBillingSettingsRepository)
+	settingsRepo.On("Get", mock.Anything).Return(&domain.BillingSettings{}, nil)
+	recording := NewInvoiceRecordService(&memoryInvoiceRecordRepository{}, invoiceRepo, settingsRepo, NewFakeInvoiceRecordSender(), testSigningKey)
+
+	hook := &recordingIssueHook{}
+	svc := NewInvoiceService(invoiceRepo, clientRepo, newNoPayerRepository(), serviceTypeRepo, seriesRepo, new(MockAppointmentRepository), new(MockEmployeeRepository), newNoUsageRepository(), newNotCoveredRepository(), InvoiceTaxPolicy{}, recording, hook)
+	ctx := context.Background()
+
+	clientID := uuid.New()
+	clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+	invoiceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+
+	draft, err := svc.CreateDraftInvoice(ctx, &CreateInvoiceRequest{
+		ClientID:    clientID,
+		IssueDate:   time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		DueDate:     time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+		BaseAmount:  money.MustParse("60"),
+		Description: "Sesión individual",
+	})
+	require.NoError(t, err)
+	invoiceRepo.On("GetByID", ctx, draft.ID).Return(draft, nil)
+
+	_, err = svc.IssueInvoice(ctx, draft.ID)
+
+	requireValidationError(t, err)
+	invoiceRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+	assert.Empty(t, hook.issued)
+}
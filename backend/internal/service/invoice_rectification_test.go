@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// issuedTestInvoice returns an issued invoice of 4 exempt sessions and a report with VAT
+func issuedTestInvoice(t *testing.T) *domain.Invoice {
+	t.Helper()
+
+	exempt := domain.VATExemptArticle20
+	issueDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	invoice := &domain.Invoice{
+		ID:            uuid.New(),
+		InvoiceNumber: "F_2025_0010",
+		ClientID:      uuid.New(),
+		IssueDate:     issueDate,
+		DueDate:       issueDate.AddDate(0, 0, 30),
+		Description:   "Sesiones de marzo",
+		Status:        domain.InvoiceStatusUnpaid,
+		InvoiceType:   domain.InvoiceTypeOrdinary,
+		Lines: []*domain.InvoiceLine{
+			{ID: uuid.New(), Description: "Sesión individual", Quantity: 4, UnitPrice: money.MustParse("60"), VATExemption: &exempt},
+			{ID: uuid.New(), Description: "Informe", Quantity: 1, UnitPrice: money.MustParse("100"), VATRate: 21},
+		},
+	}
+	require.NoError(t, invoice.CalculateAmounts())
+	return invoice
+}
+
+func requireValidationError(t *testing.T, err error) {
+	t.Helper()
+
+	require.Error(t, err)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok, "expected an AppError, got %v", err)
+	assert.Equal(t, errors.CodeValidationFailed, appErr.Code)
+}
+
+func TestInvoiceService_IssuedInvoicesAreImmutable(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("update is refused", func(t *testing.T) {
+		svc, invoiceRepo, _, _ := newInvoiceTestService()
+		invoice := issuedTestInvoice(t)
+		invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+
+		_, err := svc.UpdateInvoice(ctx, invoice.ID, &UpdateInvoiceRequest{
+			IssueDate: invoice.IssueDate,
+			DueDate:   invoice.DueDate,
+			Notes:     "Cambio",
+		})
+
+		requireValidationError(t, err)
+		invoiceRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("delete is refused", func(t *testing.T) {
+		svc, invoiceRepo, _, _ := newInvoiceTestService()
+		invoice := issuedTestInvoice(t)
+		invoice.Status = domain.InvoiceStatusPaid
+		invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+
+		err := svc.DeleteInvoice(ctx, invoice.ID)
+
+		requireValidationError(t, err)
+		invoiceRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("drafts can be deleted", func(t *testing.T) {
+		svc, invoiceRepo, _, _ := newInvoiceTestService()
+		invoice := issuedTestInvoice(t)
+		invoice.Status = domain.InvoiceStatusDraft
+		invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+		invoiceRepo.On("Delete", ctx, invoice.ID).Return(nil)
+
+		require.NoError(t, svc.DeleteInvoice(ctx, invoice.ID))
+	})
+}
+
+func TestInvoiceService_CreateRectifyingInvoice_Cancellation(t *testing.T) {
+	svc, invoiceRepo, _, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	original := issuedTestInvoice(t)
+	original.IRPFRate = 15
+	require.NoError(t, original.CalculateAmounts())
+
+	invoiceRepo.On("GetByID", ctx, original.ID).Return(original, nil)
+	invoiceRepo.On("GetRectifyingInvoices", ctx, original.ID).Return([]*domain.Invoice{}, nil)
+	invoiceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+
+	rectifying, err := svc.CreateRectifyingInvoice(ctx, original.ID, &CreateRectifyingInvoiceRequest{
+		Mode:   domain.RectificationModeCancellation,
+		Reason: "Factura emitida a un cliente equivocado",
+	})
+
+	require.NoError(t, err)
+	assert.True(t, rectifying.IsDraft())
+	assert.True(t, rectifying.IsRectifying())
+	assert.Equal(t, original.ID, *rectifying.RectifiedInvoiceID)
+	assert.Equal(t, domain.RectificationModeCancellation, *rectifying.RectificationMode)
+	assert.Equal(t, original.ClientID, rectifying.ClientID)
+	assert.Equal(t, "Anulación de la factura F_2025_0010", rectifying.Description)
+
+	require.Len(t, rectifying.Lines, 2)
+	assert.Equal(t, -4.0, rectifying.Lines[0].Quantity)
+	assert.Equal(t, original.BaseAmount.Neg(), rectifying.BaseAmount)
+	assert.Equal(t, original.VATAmount.Neg(), rectifying.VATAmount)
+	assert.Equal(t, original.IRPFAmount.Neg(), rectifying.IRPFAmount)
+	assert.Equal(t, original.TotalAmount.Neg(), rectifying.TotalAmount)
+
+	// The original is left untouched
+	assert.Equal(t, "-340.00", rectifying.BaseAmount.Decimal())
+	assert.Equal(t, 4.0, original.Lines[0].Quantity)
+	invoiceRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_CreateRectifyingInvoice_CancellationAfterDifference(t *testing.T) {
+	svc, invoiceRepo, _, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	original := issuedTestInvoice(t)
+	mode := domain.RectificationModeDifference
+	discount := &domain.Invoice{
+		ID:                 uuid.New(),
+		Status:             domain.InvoiceStatusUnpaid,
+		InvoiceType:        domain.InvoiceTypeRectifying,
+		RectifiedInvoiceID: &original.ID,
+		RectificationMode:  &mode,
+		Lines: []*domain.InvoiceLine{
+			{ID: uuid.New(), Description: "Sesión no realizada", Quantity: -1, UnitPrice: money.MustParse("60"), VATExemption: original.Lines[0].VATExemption},
+		},
+	}
+	require.NoError(t, discount.CalculateAmounts())
+
+	invoiceRepo.On("GetByID", ctx, original.ID).Return(original, nil)
+	invoiceRepo.On("GetRectifyingInvoices", ctx, original.ID).Return([]*domain.Invoice{discount}, nil)
+	invoiceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+
+	rectifying, err := svc.CreateRectifyingInvoice(ctx, original.ID, &CreateRectifyingInvoiceRequest{
+		Mode:   domain.RectificationModeCancellation,
+		Reason: "Servicio no prestado",
+	})
+
+	require.NoError(t, err)
+	require.Len(t, rectifying.Lines, 3)
+
+	// Original 340 - 60 already rectified = 280 left to cancel
+	assert.Equal(t, "-280.00", rectifying.BaseAmount.Decimal())
+	balance, err := money.Sum(original.TotalAmount, discount.TotalAmount, rectifying.TotalAmount)
+	require.NoError(t, err)
+	assert.True(t, balance.IsZero())
+}
+
+func TestInvoiceService_CreateRectifyingInvoice_Difference(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("adds the correcting lines", func(t *testing.T) {
+		svc, invoiceRepo, _, _ := newInvoiceTestService()
+		original := issuedTestInvoice(t)
+
+		invoiceRepo.On("GetByID", ctx, original.ID).Return(original, nil)
+		invoiceRepo.On("GetRectifyingInvoices", ctx, original.ID).Return([]*domain.Invoice{}, nil)
+		invoiceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+
+		rectifying, err := svc.CreateRectifyingInvoice(ctx, original.ID, &CreateRectifyingInvoiceRequest{
+			Mode:   domain.RectificationModeDifference,
+			Reason: "Descuento no aplicado",
+			Lines: []InvoiceLineRequest{
+				{Description: "Descuento informe", UnitPrice: pricePtr("-20"), VATRate: floatPtr(21)},
+			},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "Rectificación de la factura F_2025_0010", rectifying.Description)
+		assert.Equal(t, "-20.00", rectifying.BaseAmount.Decimal())
+		assert.Equal(t, "-4.20", rectifying.VATAmount.Decimal())
+		assert.Equal(t, "-24.20", rectifying.TotalAmount.Decimal())
+	})
+
+	t.Run("cannot go below zero", func(t *testing.T) {
+		svc, invoiceRepo, _, _ := newInvoiceTestService()
+		original := issuedTestInvoice(t)
+
+		invoiceRepo.On("GetByID", ctx, original.ID).Return(original, nil)
+		invoiceRepo.On("GetRectifyingInvoices", ctx, original.ID).Return([]*domain.Invoice{}, nil)
+
+		_, err := svc.CreateRectifyingInvoice(ctx, original.ID, &CreateRectifyingInvoiceRequest{
+			Mode:   domain.RectificationModeDifference,
+			Reason: "Devolución",
+			Lines: []InvoiceLineRequest{
+				{Description: "Devolución", Quantity: -7, UnitPrice: pricePtr("60"), VATRate: floatPtr(0), VATExemption: original.Lines[0].VATExemption},
+			},
+		})
+
+		requireValidationError(t, err)
+		invoiceRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("requires lines", func(t *testing.T) {
+		svc, invoiceRepo, _, _ := newInvoiceTestService()
+		original := issuedTestInvoice(t)
+		invoiceRepo.On("GetByID", ctx, original.ID).Return(original, nil)
+
+		_, err := svc.CreateRectifyingInvoice(ctx, original.ID, &CreateRectifyingInvoiceRequest{
+			Mode:   domain.RectificationModeDifference,
+			Reason: "Error",
+		})
+
+		requireValidationError(t, err)
+	})
+}
+
+func TestInvoiceService_CreateRectifyingInvoice_RefusesDrafts(t *testing.T) {
+	svc, invoiceRepo, _, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	draft := issuedTestInvoice(t)
+	draft.Status = domain.InvoiceStatusDraft
+	invoiceRepo.On("GetByID", ctx, draft.ID).Return(draft, nil)
+
+	_, err := svc.CreateRectifyingInvoice(ctx, draft.ID, &CreateRectifyingInvoiceRequest{
+		Mode:   domain.RectificationModeCancellation,
+		Reason: "Error",
+	})
+
+	requireValidationError(t, err)
+}
+
+func TestInvoiceService_IssueInvoice_RectifyingSeries(t *testing.T) {
+	svc, invoiceRepo, _, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	original := issuedTestInvoice(t)
+	mode := domain.RectificationModeCancellation
+	rectifying := &domain.Invoice{
+		ID:                  uuid.New(),
+		InvoiceNumber:       "BORRADOR_x",
+		ClientID:            original.ClientID,
+		IssueDate:           time.Now(),
+		DueDate:             time.Now().AddDate(0, 0, 30),
+		Description:         "Anulación de la factura F_2025_0010",
+		Status:              domain.InvoiceStatusDraft,
+		InvoiceType:         domain.InvoiceTypeRectifying,
+		RectifiedInvoiceID:  &original.ID,
+		RectificationMode:   &mode,
+		RectificationReason: "Error",
+	}
+	for _, line := range original.Lines {
+		rectifying.Lines = append(rectifying.Lines, line.Negated())
+	}
+	require.NoError(t, rectifying.CalculateAmounts())
+
+	invoiceRepo.On("GetByID", ctx, rectifying.ID).Return(rectifying, nil)
+	invoiceRepo.On("GetByID", ctx, original.ID).Return(original, nil)
+	invoiceRepo.On("GetRectifyingInvoices", ctx, original.ID).Return([]*domain.Invoice{rectifying}, nil)
+	invoiceRepo.On("Issue", ctx, rectifying).Run(issueAs("R_2026_0001")).Return(nil)
+
+	issued, err := svc.IssueInvoice(ctx, rectifying.ID)
+
+	require.NoError(t, err)
+	assert.Equal(t, "R_2026_0001", issued.InvoiceNumber)
+	assert.Equal(t, &testRectifyingSeries.ID, issued.SeriesID) // Drafted before series existed
+	assert.True(t, issued.IsIssued())
+}
+
+func TestInvoiceLine_Negated(t *testing.T) {
+	line := &domain.InvoiceLine{ID: uuid.New(), Description: "Sesión", Quantity: 3, UnitPrice: money.MustParse("33.33"), DiscountPercent: 10, VATRate: 21}
+	require.NoError(t, line.CalculateAmounts())
+
+	negated := line.Negated()
+	require.NoError(t, negated.CalculateAmounts())
+
+	assert.NotEqual(t, line.ID, negated.ID)
+	assert.Equal(t, line.BaseAmount.Neg(), negated.BaseAmount)
+	assert.Equal(t, line.VATAmount.Neg(), negated.VATAmount)
+	assert.Equal(t, line.TotalAmount.Neg(), negated.TotalAmount)
+	assert.NoError(t, negated.ValidateCorrection())
+	assert.Error(t, negated.Validate())
+}
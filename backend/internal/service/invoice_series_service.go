@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// CreateInvoiceSeriesRequest represents the request to create a numbering series
+type CreateInvoiceSeriesRequest struct {
+	Name        string             `json:"name" binding:"required"`
+	Prefix      string             `json:"prefix" binding:"required"` // Uppercase letters or digits, e.g. FMAD
+	InvoiceType domain.InvoiceType `json:"invoiceType" binding:"required,oneof=ordinary rectifying simplified"`
+	Location    *string            `json:"location,omitempty"`    // Clinic location, empty for all of them
+	IsDefault   bool               `json:"isDefault"`             // Replaces the default of the type and location
+	YearlyReset *bool              `json:"yearlyReset,omitempty"` // Defaults to true
+	Padding     int                `json:"padding,omitempty" binding:"omitempty,gte=1,lte=10"`
+}
+
+// UpdateInvoiceSeriesRequest represents the request to update a numbering series.
+// The prefix, type, yearly reset and padding cannot change once the series exists.
+type UpdateInvoiceSeriesRequest struct {
+	Name      string  `json:"name" binding:"required"`
+	Location  *string `json:"location,omitempty"`
+	IsDefault bool    `json:"isDefault"`
+	IsActive  bool    `json:"isActive"`
+}
+
+// InvoiceSeriesService handles the invoice numbering series
+type InvoiceSeriesService interface {
+	// CreateSeries creates a new numbering series
+	CreateSeries(ctx context.Context, req *CreateInvoiceSeriesRequest) (*domain.InvoiceSeries, error)
+
+	// GetSeries retrieves a series by ID
+	GetSeries(ctx context.Context, id uuid.UUID) (*domain.InvoiceSeries, error)
+
+	// ListSeries retrieves the series, optionally only active ones
+	ListSeries(ctx context.Context, activeOnly bool) ([]*domain.InvoiceSeries, error)
+
+	// UpdateSeries updates the name, location, default and active flags of a series
+	UpdateSeries(ctx context.Context, id uuid.UUID, req *UpdateInvoiceSeriesRequest) (*domain.InvoiceSeries, error)
+}
+
+type invoiceSeriesService struct {
+	seriesRepo repository.InvoiceSeriesRepository
+}
+
+// NewInvoiceSeriesService creates a new invoice series service
+func NewInvoiceSeriesService(seriesRepo repository.InvoiceSeriesRepository) InvoiceSeriesService {
+	return &invoiceSeriesService{
+		seriesRepo: seriesRepo,
+	}
+}
+
+// CreateSeries creates a new numbering series
+func (s *invoiceSeriesService) CreateSeries(ctx context.Context, req *CreateInvoiceSeriesRequest) (*domain.InvoiceSeries, error) {
+	series := &domain.InvoiceSeries{
+		ID:          uuid.New(),
+		Name:        strings.TrimSpace(req.Name),
+		Prefix:      strings.ToUpper(strings.TrimSpace(req.Prefix)),
+		InvoiceType: req.InvoiceType,
+		Location:    normalizeLocation(req.Location),
+		IsDefault:   req.IsDefault,
+		YearlyReset: true,
+		Padding:     domain.DefaultSeriesPadding,
+		IsActive:    true,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	if req.YearlyReset != nil {
+		series.YearlyReset = *req.YearlyReset
+	}
+	if req.Padding > 0 {
+		series.Padding = req.Padding
+	}
+
+	if err := series.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.seriesRepo.Create(ctx, series); err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+// GetSeries retrieves a series by ID
+func (s *invoiceSeriesService) GetSeries(ctx context.Context, id uuid.UUID) (*domain.InvoiceSeries, error) {
+	return s.seriesRepo.GetByID(ctx, id)
+}
+
+// ListSeries retrieves the series, optionally only active ones
+func (s *invoiceSeriesService) ListSeries(ctx context.Context, activeOnly bool) ([]*domain.InvoiceSeries, error) {
+	return s.seriesRepo.List(ctx, activeOnly)
+}
+
+// UpdateSeries updates the name, location, default and active flags of a series
+func (s *invoiceSeriesService) UpdateSeries(ctx context.Context, id uuid.UUID, req *UpdateInvoiceSeriesRequest) (*domain.InvoiceSeries, error) {
+	series, err := s.seriesRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	series.Name = strings.TrimSpace(req.Name)
+	series.Location = normalizeLocation(req.Location)
+	series.IsDefault = req.IsDefault
+	series.IsActive = req.IsActive
+	series.UpdatedAt = time.Now()
+
+	if err := series.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.seriesRepo.Update(ctx, series); err != nil {
+		return nil, fmt.Errorf("failed to update invoice series: %w", err)
+	}
+
+	return series, nil
+}
+
+// normalizeLocation trims a location, treating an empty one as all locations
+func normalizeLocation(location *string) *string {
+	if location == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*location)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
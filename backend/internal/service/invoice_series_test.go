@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockInvoiceSeriesRepository is a mock implementation of InvoiceSeriesRepository
+type MockInvoiceSeriesRepository struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceSeriesRepository) seriesResult(args mock.Arguments) (*domain.InvoiceSeries, error) {
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.InvoiceSeries), args.Error(1)
+}
+
+func (m *MockInvoiceSeriesRepository) Create(ctx context.Context, series *domain.InvoiceSeries) error {
+	args := m.Called(ctx, series)
+	return args.Error(0)
+}
+
+func (m *MockInvoiceSeriesRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.InvoiceSeries, error) {
+	return m.seriesResult(m.Called(ctx, id))
+}
+
+func (m *MockInvoiceSeriesRepository) List(ctx context.Context, activeOnly bool) ([]*domain.InvoiceSeries, error) {
+	args := m.Called(ctx, activeOnly)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.InvoiceSeries), args.Error(1)
+}
+
+func (m *MockInvoiceSeriesRepository) GetDefault(ctx context.Context, invoiceType domain.InvoiceType, location *string) (*domain.InvoiceSeries, error) {
+	return m.seriesResult(m.Called(ctx, invoiceType, location))
+}
+
+func (m *MockInvoiceSeriesRepository) Update(ctx context.Context, series *domain.InvoiceSeries) error {
+	args := m.Called(ctx, series)
+	return args.Error(0)
+}
+
+// countingInvoiceRepository numbers issued invoices like the database does: the counter
+// of the series is held locked until the invoice is stored
+type countingInvoiceRepository struct {
+	*MockInvoiceRepository
+
+	series   map[uuid.UUID]*domain.InvoiceSeries
+	mu       sync.Mutex
+	counters map[string]int
+	issued   []*domain.Invoice
+}
+
+func (r *countingInvoiceRepository) Issue(ctx context.Context, invoice *domain.Invoice, recorder repository.InvoiceIssueRecorder) error {
+	series := r.series[*invoice.SeriesID]
+	year := series.CounterYear(invoice.IssueDate)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := fmt.Sprintf("%s/%d", series.ID, year)
+	r.counters[key]++
+	sequence := r.counters[key]
+
+	invoice.InvoiceNumber = series.FormatNumber(year, sequence)
+	invoice.NumberYear = &year
+	invoice.SequenceNumber = &sequence
+	r.issued = append(r.issued, invoice)
+	return nil
+}
+
+func TestInvoiceSeries_FormatNumber(t *testing.T) {
+	yearly := &domain.InvoiceSeries{Prefix: "F", YearlyReset: true, Padding: 4}
+	continuous := &domain.InvoiceSeries{Prefix: "SMAD", YearlyReset: false, Padding: 6}
+	issueDate := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 2026, yearly.CounterYear(issueDate))
+	assert.Equal(t, "F_2026_0042", yearly.FormatNumber(2026, 42))
+	assert.Equal(t, "F_2026_12345", yearly.FormatNumber(2026, 12345))
+
+	assert.Equal(t, 0, continuous.CounterYear(issueDate))
+	assert.Equal(t, "SMAD_000042", continuous.FormatNumber(0, 42))
+}
+
+func TestInvoiceSeries_Validate(t *testing.T) {
+	valid := func() *domain.InvoiceSeries {
+		return &domain.InvoiceSeries{Name: "Madrid", Prefix: "FMAD", InvoiceType: domain.InvoiceTypeOrdinary, Padding: 4, IsActive: true}
+	}
+
+	require.NoError(t, valid().Validate())
+
+	for name, change := range map[string]func(*domain.InvoiceSeries){
+		"lowercase prefix": func(s *domain.InvoiceSeries) { s.Prefix = "fmad" },
+		"prefix separator": func(s *domain.InvoiceSeries) { s.Prefix = "F_MAD" },
+		"draft prefix":     func(s *domain.InvoiceSeries) { s.Prefix = "BORRADOR" },
+		"unknown type":     func(s *domain.InvoiceSeries) { s.InvoiceType = "proforma" },
+		"no padding":       func(s *domain.InvoiceSeries) { s.Padding = 0 },
+		"inactive default": func(s *domain.InvoiceSeries) { s.IsDefault, s.IsActive = true, false },
+	} {
+		t.Run(name, func(t *testing.T) {
+			series := valid()
+			change(series)
+			requireValidationError(t, series.Validate())
+		})
+	}
+}
+
+func TestInvoiceService_CreateInvoice_SimplifiedSeries(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	seriesRepo := svc.seriesRepo.(*MockInvoiceSeriesRepository)
+	ctx := context.Background()
+
+	clientID := uuid.New()
+	simplified := &domain.InvoiceSeries{ID: uuid.New(), Name: "Tickets", Prefix: "S", InvoiceType: domain.InvoiceTypeSimplified, YearlyReset: true, Padding: 4, IsActive: true}
+	issueDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+	seriesRepo.On("GetByID", ctx, simplified.ID).Return(simplified, nil)
+	invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).Run(issueAs("S_2025_0001")).Return(nil)
+
+	invoice, err := svc.CreateInvoice(ctx, &CreateInvoiceRequest{
+		ClientID:    clientID,
+		IssueDate:   issueDate,
+		DueDate:     issueDate,
+		BaseAmount:  money.MustParse("40"),
+		Description: "Sesión",
+		SeriesID:    &simplified.ID,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.InvoiceTypeSimplified, invoice.InvoiceType)
+	assert.Equal(t, &simplified.ID, invoice.SeriesID)
+	assert.Equal(t, "S_2025_0001", invoice.InvoiceNumber)
+}
+
+func TestInvoiceService_CreateInvoice_IssuedTodayKeepingTheTerm(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	seriesRepo := svc.seriesRepo.(*MockInvoiceSeriesRepository)
+	ctx := context.Background()
+
+	clientID := uuid.New()
+	series := &domain.InvoiceSeries{ID: uuid.New(), Prefix: "F", InvoiceType: domain.InvoiceTypeOrdinary, IsDefault: true, Padding: 4, IsActive: true}
+	clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+	seriesRepo.On("GetDefault", ctx, domain.InvoiceTypeOrdinary, (*string)(nil)).Return(series, nil)
+	invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+	invoiceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+
+	backdated := time.Date(2024, 11, 4, 0, 0, 0, 0, time.UTC)
+	req := &CreateInvoiceRequest{
+		ClientID:    clientID,
+		IssueDate:   backdated,
+		DueDate:     backdated.AddDate(0, 0, 30),
+		BaseAmount:  money.MustParse("60"),
+		Description: "Sesión",
+	}
+
+	// An issued invoice cannot be dated before the ones already numbered
+	issued, err := svc.CreateInvoice(ctx, req)
+	require.NoError(t, err)
+	now := time.Now()
+	assert.Equal(t, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), issued.IssueDate)
+	assert.Equal(t, 30*24*time.Hour, issued.DueDate.Sub(issued.IssueDate))
+
+	// Drafts keep the requested date until they are issued
+	draft, err := svc.CreateDraftInvoice(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, backdated, draft.IssueDate)
+}
+
+func TestInvoiceService_CreateInvoice_RejectsUnusableSeries(t *testing.T) {
+	for name, series := range map[string]*domain.InvoiceSeries{
+		"rectifying": {ID: uuid.New(), Prefix: "R", InvoiceType: domain.InvoiceTypeRectifying, IsActive: true},
+		"inactive":   {ID: uuid.New(), Prefix: "F24", InvoiceType: domain.InvoiceTypeOrdinary, IsActive: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+			seriesRepo := svc.seriesRepo.(*MockInvoiceSeriesRepository)
+			ctx := context.Background()
+
+			clientID := uuid.New()
+			issueDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+			clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+			seriesRepo.On("GetByID", ctx, series.ID).Return(series, nil)
+
+			_, err := svc.CreateInvoice(ctx, &CreateInvoiceRequest{
+				ClientID:    clientID,
+				IssueDate:   issueDate,
+				DueDate:     issueDate,
+				BaseAmount:  money.MustParse("40"),
+				Description: "Sesión",
+				SeriesID:    &series.ID,
+			})
+
+			requireValidationError(t, err)
+			invoiceRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestInvoiceService_CreateRectifyingInvoice_UsesSeriesOfTheLocation(t *testing.T) {
+	invoiceRepo := new(MockInvoiceRepository)
+	seriesRepo := new(MockInvoiceSeriesRepository)
+	svc := NewInvoiceService(invoiceRepo, new(MockClientRepository), newNoPayerRepository(), new(MockServiceTypeRepository), seriesRepo, new(MockAppointmentRepository), new(MockEmployeeRepository), newNoUsageRepository(), newNotCoveredRepository(), InvoiceTaxPolicy{}, nil).(*invoiceService)
+	ctx := context.Background()
+
+	madrid := "Madrid"
+	ordinaryMadrid := &domain.InvoiceSeries{ID: uuid.New(), Prefix: "FMAD", InvoiceType: domain.InvoiceTypeOrdinary, Location: &madrid, IsActive: true}
+	rectifyingMadrid := &domain.InvoiceSeries{ID: uuid.New(), Prefix: "RMAD", InvoiceType: domain.InvoiceTypeRectifying, Location: &madrid, IsActive: true}
+
+	original := issuedTestInvoice(t)
+	original.SeriesID = &ordinaryMadrid.ID
+
+	invoiceRepo.On("GetByID", ctx, original.ID).Return(original, nil)
+	invoiceRepo.On("GetRectifyingInvoices", ctx, original.ID).Return([]*domain.Invoice{}, nil)
+	invoiceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+	seriesRepo.On("GetByID", ctx, ordinaryMadrid.ID).Return(ordinaryMadrid, nil)
+	seriesRepo.On("GetDefault", ctx, domain.InvoiceTypeRectifying, &madrid).Return(rectifyingMadrid, nil)
+
+	rectifying, err := svc.CreateRectifyingInvoice(ctx, original.ID, &CreateRectifyingInvoiceRequest{
+		Mode:   domain.RectificationModeCancellation,
+		Reason: "Duplicada",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, &rectifyingMadrid.ID, rectifying.SeriesID)
+	assert.True(t, rectifying.IsDraft())
+}
+
+func TestInvoiceService_ConcurrentIssuesLeaveNoGaps(t *testing.T) {
+	const invoices = 200
+
+	yearly := &domain.InvoiceSeries{ID: uuid.New(), Prefix: "F", InvoiceType: domain.InvoiceTypeOrdinary, IsDefault: true, YearlyReset: true, Padding: 4, IsActive: true}
+	invoiceRepo := &countingInvoiceRepository{
+		MockInvoiceRepository: new(MockInvoiceRepository),
+		series:                map[uuid.UUID]*domain.InvoiceSeries{yearly.ID: yearly},
+		counters:              map[string]int{},
+	}
+	clientRepo := new(MockClientRepository)
+	seriesRepo := new(MockInvoiceSeriesRepository)
+	svc := NewInvoiceService(invoiceRepo, clientRepo, newNoPayerRepository(), new(MockServiceTypeRepository), seriesRepo, new(MockAppointmentRepository), new(MockEmployeeRepository), newNoUsageRepository(), newNotCoveredRepository(), InvoiceTaxPolicy{}, nil)
+	ctx := context.Background()
+
+	clientID := uuid.New()
+	clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+	seriesRepo.On("GetDefault", ctx, domain.InvoiceTypeOrdinary, (*string)(nil)).Return(yearly, nil)
+
+	// Invoices are issued today whatever date is requested
+	var wg sync.WaitGroup
+	errs := make(chan error, invoices)
+	for i := 0; i < invoices; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			issueDate := time.Date(2025-i%2, 6, 1, 0, 0, 0, 0, time.UTC)
+			_, err := svc.CreateInvoice(ctx, &CreateInvoiceRequest{
+				ClientID:    clientID,
+				IssueDate:   issueDate,
+				DueDate:     issueDate.AddDate(0, 0, 30),
+				BaseAmount:  money.MustParse("60"),
+				Description: "Sesión",
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	numbers := map[string]bool{}
+	for _, invoice := range invoiceRepo.issued {
+		assert.False(t, numbers[invoice.InvoiceNumber], "duplicated number %s", invoice.InvoiceNumber)
+		numbers[invoice.InvoiceNumber] = true
+	}
+
+	year := time.Now().Year()
+	for sequence := 1; sequence <= invoices; sequence++ {
+		number := yearly.FormatNumber(year, sequence)
+		assert.True(t, numbers[number], "missing number %s", number)
+	}
+	assert.Len(t, numbers, invoices)
+}
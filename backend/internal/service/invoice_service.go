@@ -3,32 +3,96 @@ package service
 import (
 	"context"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
 	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 )
 
-// CreateInvoiceRequest represents the request to create an invoice
+// InvoiceLineRequest represents a line of an invoice in create and update requests
+type InvoiceLineRequest struct {
+	Description     string                    `json:"description"`                       // Defaults to the service type name
+	Quantity        float64                   `json:"quantity" binding:"omitempty,gt=0"` // Defaults to 1
+	UnitPrice       *money.Money              `json:"unitPrice,omitempty"`               // Defaults to the service type price
+	DiscountPercent float64                   `json:"discountPercent" binding:"gte=0,lte=100"`
+	VATRate         *float64                  `json:"vatRate,omitempty" binding:"omitempty,gte=0"` // Defaults to the service type rate
+	VATExemption    *domain.VATExemptionCause `json:"vatExemption,omitempty"`                      // E1-E6; implies a 0% rate
+	AppointmentID   *uuid.UUID                `json:"appointmentId,omitempty"`
+	ServiceTypeID   *uuid.UUID                `json:"serviceTypeId,omitempty"`
+}
+
+// CreateInvoiceRequest represents the request to create an invoice.
+// Either Lines or BaseAmount (a single line with Description) must be given.
 type CreateInvoiceRequest struct {
-	ClientID      uuid.UUID  `json:"clientId" binding:"required"`
-	AppointmentID *uuid.UUID `json:"appointmentId,omitempty"`
-	IssueDate     time.Time  `json:"issueDate" binding:"required"`
-	DueDate       time.Time  `json:"dueDate" binding:"required"`
-	BaseAmount    float64    `json:"baseAmount" binding:"required,gt=0"`
-	Description   string     `json:"description" binding:"required"`
-	Notes         string     `json:"notes,omitempty"`
+	ClientID      uuid.UUID            `json:"clientId" binding:"required"`
+	AppointmentID *uuid.UUID           `json:"appointmentId,omitempty"`
+	IssueDate     time.Time            `json:"issueDate" binding:"required"` // Kept by drafts; issued invoices are dated today
+	DueDate       time.Time            `json:"dueDate" binding:"required"`   // Issued invoices keep the payment term
+	Lines         []InvoiceLineRequest `json:"lines,omitempty" binding:"omitempty,dive"`
+	BaseAmount    money.Money          `json:"baseAmount,omitempty"`
+	Description   string               `json:"description,omitempty"`                                // Defaults to a summary of the lines
+	IRPFRate      *float64             `json:"irpfRate,omitempty" binding:"omitempty,gte=0,lte=100"` // Defaults to the policy rate for business clients
+	SeriesID      *uuid.UUID           `json:"seriesId,omitempty"`                                   // Ordinary or simplified series; defaults to the ordinary one
+	Notes         string               `json:"notes,omitempty"`
+
+	// Payer the invoice is addressed to, one of the billing profiles of the client; defaults
+	// to the client's default payer. BillClient addresses it to the client regardless.
+	// Internally, a payer without a client (uuid.Nil) bills sessions of several clients,
+	// as the monthly settlement of an insurer does.
+	BillingProfileID *uuid.UUID `json:"billingProfileId,omitempty"`
+	BillClient       bool       `json:"billClient,omitempty"`
 }
 
-// UpdateInvoiceRequest represents the request to update an invoice
+// UpdateInvoiceRequest represents the request to update an invoice.
+// Lines (or BaseAmount for a single line) replace the current lines; when both are empty the lines are kept.
 type UpdateInvoiceRequest struct {
-	IssueDate   time.Time `json:"issueDate" binding:"required"`
-	DueDate     time.Time `json:"dueDate" binding:"required"`
-	BaseAmount  float64   `json:"baseAmount" binding:"required,gt=0"`
-	Description string    `json:"description" binding:"required"`
-	Notes       string    `json:"notes,omitempty"`
+	IssueDate   time.Time            `json:"issueDate" binding:"required"`
+	DueDate     time.Time            `json:"dueDate" binding:"required"`
+	Lines       []InvoiceLineRequest `json:"lines,omitempty" binding:"omitempty,dive"`
+	BaseAmount  money.Money          `json:"baseAmount,omitempty"`
+	Description string               `json:"description,omitempty"`
+	IRPFRate    *float64             `json:"irpfRate,omitempty" binding:"omitempty,gte=0,lte=100"` // Kept when not given
+	Notes       string               `json:"notes,omitempty"`
+}
+
+// CreateRectifyingInvoiceRequest represents the request to correct an issued invoice.
+// A cancellation reverses the whole invoice; a difference adds the given lines, where
+// negative quantities or unit prices subtract from the original.
+type CreateRectifyingInvoiceRequest struct {
+	Mode    domain.RectificationMode `json:"mode" binding:"required,oneof=cancellation difference"`
+	Reason  string                   `json:"reason" binding:"required"` // Printed on the rectifying invoice
+	Lines   []InvoiceLineRequest     `json:"lines,omitempty"`           // Required for a difference
+	DueDate *time.Time               `json:"dueDate,omitempty"`         // Defaults to the payment term of the original
+	Notes   string                   `json:"notes,omitempty"`
+}
+
+// CreateInvoiceFromAppointmentRequest represents the request to invoice an appointment.
+// The price defaults to the service type of the appointment or the session rate of its employee;
+// a session covered by an insurer is priced at its co-payment.
+type CreateInvoiceFromAppointmentRequest struct {
+	UnitPrice *money.Money `json:"unitPrice,omitempty"` // Overrides the default price
+	Draft     bool         `json:"-"`                   // Creates a draft instead of issuing the invoice
+	Notes     string       `json:"notes,omitempty"`
+}
+
+// InvoiceAppointmentsRequest represents the options of an invoice grouping several appointments
+type InvoiceAppointmentsRequest struct {
+	Description string                    // Defaults to a summary of the lines
+	Notes       string                    // Printed on the invoice
+	UnitPrices  map[uuid.UUID]money.Money // Overrides the price of some appointments, e.g. a co-payment
+	Draft       bool                      // Creates a draft instead of issuing the invoice
+	Preview     bool                      // Calculates the invoice without storing it
+}
+
+// InvoiceTaxPolicy holds the clinic's tax and payment defaults for new invoices
+type InvoiceTaxPolicy struct {
+	IRPFRate        float64 // Withholding applied by default to invoices addressed to businesses
+	PaymentTermDays int     // Days until an invoice created from an appointment is due (default 15)
 }
 
 // InvoiceService handles invoice business logic
@@ -36,8 +100,21 @@ type InvoiceService interface {
 	// CreateInvoice creates a new invoice with automatic VAT calculation
 	CreateInvoice(ctx context.Context, req *CreateInvoiceRequest) (*domain.Invoice, error)
 
-	// CreateInvoiceFromAppointment creates an invoice automatically from an appointment
-	CreateInvoiceFromAppointment(ctx context.Context, appointmentID uuid.UUID, baseAmount float64) (*domain.Invoice, error)
+	// CreateDraftInvoice creates an invoice in draft status, without an official number
+	CreateDraftInvoice(ctx context.Context, req *CreateInvoiceRequest) (*domain.Invoice, error)
+
+	// IssueInvoice assigns the next number of its series to a draft and issues it as unpaid
+	IssueInvoice(ctx context.Context, id uuid.UUID) (*domain.Invoice, error)
+
+	// CreateInvoiceFromAppointment invoices an appointment; each appointment is invoiced once
+	CreateInvoiceFromAppointment(ctx context.Context, appointmentID uuid.UUID, req *CreateInvoiceFromAppointmentRequest) (*domain.Invoice, error)
+
+	// InvoiceAppointments invoices several appointments of a client together, one line per session.
+	// With Preview the invoice is calculated but not stored.
+	InvoiceAppointments(ctx context.Context, clientID uuid.UUID, appointments []*domain.Appointment, req *InvoiceAppointmentsRequest) (*domain.Invoice, error)
+
+	// GetAppointmentInvoice retrieves the invoice of an appointment
+	GetAppointmentInvoice(ctx context.Context, appointmentID uuid.UUID) (*domain.Invoice, error)
 
 	// GetInvoice retrieves an invoice by ID
 	GetInvoice(ctx context.Context, id uuid.UUID) (*domain.Invoice, error)
@@ -48,43 +125,162 @@ type InvoiceService interface {
 	// ListInvoices retrieves a paginated list of invoices with filters
 	ListInvoices(ctx context.Context, filters repository.InvoiceFilters) ([]*domain.Invoice, int, error)
 
-	// UpdateInvoice updates an existing invoice
+	// UpdateInvoice updates a draft invoice; issued invoices are immutable
 	UpdateInvoice(ctx context.Context, id uuid.UUID, req *UpdateInvoiceRequest) (*domain.Invoice, error)
 
-	// DeleteInvoice soft deletes an invoice
+	// DeleteInvoice soft deletes a draft invoice; issued invoices cannot be deleted
 	DeleteInvoice(ctx context.Context, id uuid.UUID) error
 
-	// MarkAsPaid marks an invoice as paid
-	MarkAsPaid(ctx context.Context, id uuid.UUID) (*domain.Invoice, error)
+	// CreateRectifyingInvoice creates a draft rectifying invoice correcting an issued invoice
+	CreateRectifyingInvoice(ctx context.Context, id uuid.UUID, req *CreateRectifyingInvoiceRequest) (*domain.Invoice, error)
+
+	// GetRectifyingInvoices retrieves the rectifying invoices of an invoice
+	GetRectifyingInvoices(ctx context.Context, id uuid.UUID) ([]*domain.Invoice, error)
 
 	// GetClientInvoices retrieves all invoices for a client
 	GetClientInvoices(ctx context.Context, clientID uuid.UUID) ([]*domain.Invoice, error)
 
-	// GetUnpaidInvoices retrieves all unpaid invoices
+	// GetUnpaidInvoices retrieves the issued invoices with an outstanding balance
 	GetUnpaidInvoices(ctx context.Context) ([]*domain.Invoice, error)
 }
 
+// InvoiceIssueHook is notified after an invoice has been issued
+type InvoiceIssueHook interface {
+	OnInvoiceIssued(ctx context.Context, invoice *domain.Invoice) error
+}
+
+// InvoiceIssueRecording prepares the records stored in the same transaction that issues an
+// invoice; an invoice whose records cannot be prepared or stored is not issued
+type InvoiceIssueRecording interface {
+	IssueRecorder(ctx context.Context, invoice *domain.Invoice) (repository.InvoiceIssueRecorder, error)
+}
+
 type invoiceService struct {
-	invoiceRepo repository.InvoiceRepository
-	clientRepo  repository.ClientRepository
+	invoiceRepo     repository.InvoiceRepository
+	clientRepo      repository.ClientRepository
+	profileRepo     repository.BillingProfileRepository
+	serviceTypeRepo repository.ServiceTypeRepository
+	seriesRepo      repository.InvoiceSeriesRepository
+	appointmentRepo repository.AppointmentRepository
+	employeeRepo    repository.EmployeeRepository
+	sessionPackRepo repository.SessionPackRepository
+	insurerRepo     repository.InsurerRepository
+	taxPolicy       InvoiceTaxPolicy
+	recording       InvoiceIssueRecording
+	hooks           []InvoiceIssueHook
 }
 
-// NewInvoiceService creates a new invoice service
-func NewInvoiceService(invoiceRepo repository.InvoiceRepository, clientRepo repository.ClientRepository) InvoiceService {
+// NewInvoiceService creates a new invoice service; issued invoices are recorded by recording,
+// when given, and hooks are notified of them
+func NewInvoiceService(
+	invoiceRepo repository.InvoiceRepository,
+	clientRepo repository.ClientRepository,
+	profileRepo repository.BillingProfileRepository,
+	serviceTypeRepo repository.ServiceTypeRepository,
+	seriesRepo repository.InvoiceSeriesRepository,
+	appointmentRepo repository.AppointmentRepository,
+	employeeRepo repository.EmployeeRepository,
+	sessionPackRepo repository.SessionPackRepository,
+	insurerRepo repository.InsurerRepository,
+	taxPolicy InvoiceTaxPolicy,
+	recording InvoiceIssueRecording,
+	hooks ...InvoiceIssueHook,
+) InvoiceService {
+	if taxPolicy.PaymentTermDays <= 0 {
+		taxPolicy.PaymentTermDays = 15
+	}
+
 	return &invoiceService{
-		invoiceRepo: invoiceRepo,
-		clientRepo:  clientRepo,
+		invoiceRepo:     invoiceRepo,
+		clientRepo:      clientRepo,
+		profileRepo:     profileRepo,
+		serviceTypeRepo: serviceTypeRepo,
+		seriesRepo:      seriesRepo,
+		appointmentRepo: appointmentRepo,
+		employeeRepo:    employeeRepo,
+		sessionPackRepo: sessionPackRepo,
+		insurerRepo:     insurerRepo,
+		taxPolicy:       taxPolicy,
+		recording:       recording,
+		hooks:           hooks,
+	}
+}
+
+// issue stores the invoice as issued together with its records
+func (s *invoiceService) issue(ctx context.Context, invoice *domain.Invoice) error {
+	var recorder repository.InvoiceIssueRecorder
+	if s.recording != nil {
+		var err error
+		if recorder, err = s.recording.IssueRecorder(ctx, invoice); err != nil {
+			return err
+		}
+	}
+
+	return s.invoiceRepo.Issue(ctx, invoice, recorder)
+}
+
+// notifyIssued runs the issue hooks; failures are logged and never undo the issue
+func (s *invoiceService) notifyIssued(ctx context.Context, invoice *domain.Invoice) {
+	for _, hook := range s.hooks {
+		if err := hook.OnInvoiceIssued(ctx, invoice); err != nil {
+			log.Printf("[WARN] Invoice %s issue hook failed: %v", invoice.InvoiceNumber, err)
+		}
 	}
 }
 
 // CreateInvoice creates a new invoice with automatic VAT calculation
 func (s *invoiceService) CreateInvoice(ctx context.Context, req *CreateInvoiceRequest) (*domain.Invoice, error) {
-	// Validate client exists
-	_, err := s.clientRepo.GetByID(ctx, req.ClientID)
+	return s.createInvoice(ctx, req, domain.InvoiceStatusUnpaid)
+}
+
+// CreateDraftInvoice creates an invoice in draft status, without an official number
+func (s *invoiceService) CreateDraftInvoice(ctx context.Context, req *CreateInvoiceRequest) (*domain.Invoice, error) {
+	return s.createInvoice(ctx, req, domain.InvoiceStatusDraft)
+}
+
+// createInvoice validates the request and stores a new invoice with the given status
+func (s *invoiceService) createInvoice(ctx context.Context, req *CreateInvoiceRequest, status domain.InvoiceStatus) (*domain.Invoice, error) {
+	invoice, err := s.prepareInvoice(ctx, req, status)
 	if err != nil {
-		return nil, errors.NewValidationError("client not found", map[string][]string{
-			"clientId": {"client does not exist"},
-		})
+		return nil, err
+	}
+
+	// Save to database
+	if invoice.IsDraft() {
+		err = s.invoiceRepo.Create(ctx, invoice)
+	} else {
+		err = s.issue(ctx, invoice)
+	}
+	if err != nil {
+		return nil, wrapInvoiceRepoError(err, "failed to create invoice")
+	}
+
+	if invoice.IsIssued() {
+		s.notifyIssued(ctx, invoice)
+	}
+
+	return invoice, nil
+}
+
+// prepareInvoice validates the request and builds a new invoice with the given status,
+// without storing it
+func (s *invoiceService) prepareInvoice(ctx context.Context, req *CreateInvoiceRequest, status domain.InvoiceStatus) (*domain.Invoice, error) {
+	// Validate client exists; the settlement invoices of an insurer have none and are only
+	// addressed to their payer
+	var client *domain.Client
+	var err error
+	if req.ClientID != uuid.Nil || req.BillingProfileID == nil {
+		client, err = s.clientRepo.GetByID(ctx, req.ClientID)
+		if err != nil {
+			return nil, errors.NewValidationError("client not found", map[string][]string{
+				"clientId": {"client does not exist"},
+			})
+		}
+	}
+
+	// An appointment is invoiced once
+	if err := s.checkAppointmentsNotInvoiced(ctx, req); err != nil {
+		return nil, err
 	}
 
 	// Validate dates
@@ -94,51 +290,426 @@ func (s *invoiceService) CreateInvoice(ctx context.Context, req *CreateInvoiceRe
 		})
 	}
 
-	// Generate invoice number
-	year := req.IssueDate.Year()
-	invoiceNumber, err := s.invoiceRepo.GetNextInvoiceNumber(ctx, year)
+	payer, err := s.invoicePayer(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate invoice number: %w", err)
+		return nil, err
+	}
+	if payer == nil && client == nil {
+		return nil, domain.ErrInvalidClientID
+	}
+
+	// Businesses withhold IRPF from professional services; individuals do not
+	var recipient domain.InvoiceParty
+	if payer != nil {
+		recipient = payer.InvoiceParty()
+	} else {
+		recipient = client.InvoiceParty()
+	}
+	irpfRate := 0.0
+	if recipient.IsBusiness() {
+		irpfRate = s.taxPolicy.IRPFRate
+	}
+	if req.IRPFRate != nil {
+		irpfRate = *req.IRPFRate
+	}
+
+	series, err := s.invoiceSeries(ctx, req.SeriesID)
+	if err != nil {
+		return nil, err
+	}
+
+	invoiceID := uuid.New()
+
+	lines, err := s.buildLines(ctx, req.Lines, req.BaseAmount, req.Description, req.AppointmentID, false)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create invoice
+	// Invoices issued directly are dated today, as drafts are when issued, so the numbers of
+	// a series stay in chronological order; the requested dates only give the payment term
+	issueDate, dueDate := req.IssueDate, req.DueDate
+	if status != domain.InvoiceStatusDraft {
+		now := time.Now()
+		issueDate = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		dueDate = issueDate.Add(req.DueDate.Sub(req.IssueDate))
+	}
+
+	// Create invoice; the official number is assigned by the series when it is issued
 	invoice := &domain.Invoice{
-		ID:            uuid.New(),
-		InvoiceNumber: invoiceNumber,
+		ID:            invoiceID,
+		InvoiceNumber: domain.DraftInvoiceNumber(invoiceID),
 		ClientID:      req.ClientID,
 		AppointmentID: req.AppointmentID,
-		IssueDate:     req.IssueDate,
-		DueDate:       req.DueDate,
+		IssueDate:     issueDate,
+		DueDate:       dueDate,
 		Description:   req.Description,
-		BaseAmount:    req.BaseAmount,
-		VATRate:       domain.FixedVATRate,
-		Status:        domain.InvoiceStatusUnpaid,
+		Lines:         lines,
+		IRPFRate:      irpfRate,
+		Status:        status,
+		InvoiceType:   series.InvoiceType,
+		SeriesID:      &series.ID,
 		Notes:         req.Notes,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
+	invoice.SetPayer(payer)
 
-	// Calculate VAT and total
-	invoice.CalculateAmounts()
+	// Calculate line amounts, VAT and totals
+	if err := invoice.CalculateAmounts(); err != nil {
+		return nil, fmt.Errorf("failed to calculate invoice amounts: %w", err)
+	}
+	if invoice.Description == "" {
+		invoice.Description = invoice.SummarizeLines()
+	}
 
 	// Validate invoice
 	if err := invoice.Validate(); err != nil {
 		return nil, err
 	}
 
-	// Save to database
-	if err := s.invoiceRepo.Create(ctx, invoice); err != nil {
-		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	return invoice, nil
+}
+
+// invoicePayer returns the billing profile a new invoice is addressed to: the one requested,
+// which must pay for the client (if any), or the client's default payer; nil when the
+// client pays
+func (s *invoiceService) invoicePayer(ctx context.Context, req *CreateInvoiceRequest) (*domain.BillingProfile, error) {
+	if req.BillClient {
+		return nil, nil
+	}
+
+	if req.BillingProfileID == nil {
+		// An inactive default payer no longer pays for the client
+		payer, err := s.profileRepo.GetDefaultForClient(ctx, req.ClientID)
+		if isNotFound(err) || (err == nil && !payer.IsActive) {
+			return nil, nil
+		}
+		return payer, err
+	}
+
+	if req.ClientID != uuid.Nil {
+		if _, err := s.profileRepo.GetClientLink(ctx, req.ClientID, *req.BillingProfileID); err != nil {
+			if isNotFound(err) {
+				return nil, domain.ErrBillingProfileNotLinked
+			}
+			return nil, err
+		}
+	}
+	payer, err := s.profileRepo.GetByID(ctx, *req.BillingProfileID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, domain.ErrBillingProfileNotLinked
+		}
+		return nil, err
+	}
+	if !payer.IsActive {
+		return nil, domain.ErrBillingProfileInactive
+	}
+
+	return payer, nil
+}
+
+// invoiceSeries returns the series chosen for a new invoice, or the default ordinary series
+func (s *invoiceService) invoiceSeries(ctx context.Context, seriesID *uuid.UUID) (*domain.InvoiceSeries, error) {
+	if seriesID == nil {
+		return s.seriesRepo.GetDefault(ctx, domain.InvoiceTypeOrdinary, nil)
+	}
+
+	series, err := s.seriesRepo.GetByID(ctx, *seriesID)
+	if err != nil {
+		return nil, errors.NewValidationError("invoice series not found", map[string][]string{
+			"seriesId": {"series does not exist"},
+		})
+	}
+	if series.InvoiceType == domain.InvoiceTypeRectifying {
+		return nil, errors.NewValidationError("rectifying series cannot number new invoices", map[string][]string{
+			"seriesId": {"rectifying invoices are created from the invoice they correct"},
+		})
+	}
+	if !series.IsActive {
+		return nil, errors.NewValidationError("invoice series is inactive", map[string][]string{
+			"seriesId": {"series is no longer used"},
+		})
+	}
+
+	return series, nil
+}
+
+// rectifyingSeries returns the rectifying series of the location of the original invoice
+func (s *invoiceService) rectifyingSeries(ctx context.Context, original *domain.Invoice) (*domain.InvoiceSeries, error) {
+	var location *string
+	if original.SeriesID != nil {
+		series, err := s.seriesRepo.GetByID(ctx, *original.SeriesID)
+		if err != nil {
+			return nil, err
+		}
+		location = series.Location
+	}
+
+	return s.seriesRepo.GetDefault(ctx, domain.InvoiceTypeRectifying, location)
+}
+
+// IssueInvoice assigns the next number of its series to a draft and issues it as unpaid
+func (s *invoiceService) IssueInvoice(ctx context.Context, id uuid.UUID) (*domain.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !invoice.IsDraft() {
+		return nil, errors.NewValidationError("only draft invoices can be issued", map[string][]string{
+			"status": {"invoice has already been issued"},
+		})
 	}
 
+	// Other rectifications may have been issued since this one was drafted
+	if invoice.IsRectifying() {
+		if err := s.checkRectificationBalance(ctx, invoice); err != nil {
+			return nil, err
+		}
+	}
+
+	// Drafts are issued with the current data of their payer; a deleted payer keeps the copy
+	if invoice.HasPayer() && !invoice.IsRectifying() {
+		payer, err := s.profileRepo.GetByID(ctx, *invoice.BillingProfileID)
+		if err != nil && !isNotFound(err) {
+			return nil, err
+		}
+		if payer != nil {
+			invoice.SetPayer(payer)
+		}
+	}
+
+	// The invoice is issued today, keeping the original payment term
+	paymentTerm := invoice.DueDate.Sub(invoice.IssueDate)
+	now := time.Now()
+	issueDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	// Drafts created before numbering series existed use the default series of their type
+	if invoice.SeriesID == nil {
+		series, err := s.seriesRepo.GetDefault(ctx, invoice.InvoiceType, nil)
+		if err != nil {
+			return nil, err
+		}
+		invoice.SeriesID = &series.ID
+	}
+
+	invoice.IssueDate = issueDate
+	invoice.DueDate = issueDate.Add(paymentTerm)
+	invoice.Status = domain.InvoiceStatusUnpaid
+	invoice.UpdatedAt = now
+
+	// The number is assigned by the series in the same transaction that issues the invoice
+	if err := s.issue(ctx, invoice); err != nil {
+		return nil, wrapInvoiceRepoError(err, "failed to issue invoice")
+	}
+
+	s.notifyIssued(ctx, invoice)
+
 	return invoice, nil
 }
 
-// CreateInvoiceFromAppointment creates an invoice automatically from an appointment
-func (s *invoiceService) CreateInvoiceFromAppointment(ctx context.Context, appointmentID uuid.UUID, baseAmount float64) (*domain.Invoice, error) {
-	// TODO: Implement once AppointmentRepository is created
-	// For now, return not implemented error
-	return nil, errors.NewValidationError("appointment invoice creation not yet implemented", nil)
+// CreateInvoiceFromAppointment invoices an appointment; each appointment is invoiced once and
+// never when it was paid with a session pack. A session covered by an insurer is billed its
+// co-payment only, and not at all when it has none.
+func (s *invoiceService) CreateInvoiceFromAppointment(ctx context.Context, appointmentID uuid.UUID, req *CreateInvoiceFromAppointmentRequest) (*domain.Invoice, error) {
+	appointment, err := s.appointmentRepo.GetByID(ctx, appointmentID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("appointment not found")
+	}
+
+	// Cancellations and no-shows are charged through their fees
+	if appointment.Status == domain.AppointmentStatusCancelled || appointment.Status == domain.AppointmentStatusNoShow {
+		return nil, errors.NewValidationError("cancelled and missed appointments cannot be invoiced", map[string][]string{
+			"status": {fmt.Sprintf("appointment is %s", appointment.Status)},
+		})
+	}
+
+	// The insurer pays the session but the co-payment, settled monthly
+	unitPrice, notes := req.UnitPrice, req.Notes
+	covered, err := s.insurerRepo.GetCoveredSession(ctx, appointment.ID)
+	if err == nil {
+		if covered.CopayAmount.IsZero() {
+			return nil, errors.NewConflictError("appointment is covered by an insurer without co-payment", errors.CodeConflict)
+		}
+		copay := covered.CopayAmount
+		unitPrice = &copay
+		if notes == "" {
+			notes = "Copago del paciente; el resto de la sesión lo abona su aseguradora"
+		}
+	} else if !isNotFound(err) {
+		return nil, fmt.Errorf("failed to get covered session: %w", err)
+	}
+
+	line, err := s.appointmentLine(ctx, appointment, unitPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	issueDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	status := domain.InvoiceStatusUnpaid
+	if req.Draft {
+		status = domain.InvoiceStatusDraft
+	}
+
+	return s.createInvoice(ctx, &CreateInvoiceRequest{
+		ClientID:      appointment.ClientID,
+		AppointmentID: &appointment.ID,
+		IssueDate:     issueDate,
+		DueDate:       issueDate.AddDate(0, 0, s.taxPolicy.PaymentTermDays),
+		Lines:         []InvoiceLineRequest{line},
+		Notes:         notes,
+	}, status)
+}
+
+// appointmentLine prices an appointment at the price of its service type or, when it has
+// none, at the session rate of its employee; the service type still sets the VAT treatment
+func (s *invoiceService) appointmentLine(ctx context.Context, appointment *domain.Appointment, unitPrice *money.Money) (InvoiceLineRequest, error) {
+	line := InvoiceLineRequest{
+		UnitPrice:     unitPrice,
+		AppointmentID: &appointment.ID,
+		ServiceTypeID: appointment.ServiceTypeID,
+	}
+
+	name := appointment.Title
+	priced := unitPrice != nil
+	if appointment.ServiceTypeID != nil {
+		serviceType, err := s.serviceTypeRepo.GetByID(ctx, *appointment.ServiceTypeID)
+		if err != nil {
+			return line, errors.NewValidationError("service type not found", map[string][]string{
+				"serviceTypeId": {"service type of the appointment does not exist"},
+			})
+		}
+		name = serviceType.Name
+		priced = priced || serviceType.DefaultPrice.IsPositive()
+	}
+	line.Description = fmt.Sprintf("%s - cita del %s", name, appointment.StartTime.Format("02/01/2006 15:04"))
+
+	if priced {
+		return line, nil
+	}
+
+	employee, err := s.employeeRepo.GetByID(ctx, appointment.EmployeeID)
+	if err != nil {
+		return line, fmt.Errorf("failed to get employee: %w", err)
+	}
+	if employee.SessionRate == nil || !employee.SessionRate.IsPositive() {
+		return line, errors.NewValidationError("appointment has no price", map[string][]string{
+			"unitPrice": {"the appointment has no priced service type and its employee has no session rate"},
+		})
+	}
+	line.UnitPrice = employee.SessionRate
+
+	return line, nil
+}
+
+// wrapInvoiceRepoError adds context to unexpected repository errors; application errors,
+// such as an appointment invoiced concurrently, are returned as they are so callers can
+// tell a conflict apart from a failure
+func wrapInvoiceRepoError(err error, msg string) error {
+	if _, ok := err.(*errors.AppError); ok {
+		return err
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// checkAppointmentsNotInvoiced refuses an invoice for appointments already billed by another one
+// or paid with a session pack. It reports the usual case early; the repository repeats the
+// check with the appointments locked, which is what stops concurrent batch runs, invoicers
+// and pack consumers from billing them twice.
+func (s *invoiceService) checkAppointmentsNotInvoiced(ctx context.Context, req *CreateInvoiceRequest) error {
+	var appointmentIDs []uuid.UUID
+	if req.AppointmentID != nil {
+		appointmentIDs = append(appointmentIDs, *req.AppointmentID)
+	}
+	for _, line := range req.Lines {
+		if line.AppointmentID != nil {
+			appointmentIDs = append(appointmentIDs, *line.AppointmentID)
+		}
+	}
+
+	checked := make(map[uuid.UUID]bool, len(appointmentIDs))
+	for _, appointmentID := range appointmentIDs {
+		if checked[appointmentID] {
+			continue
+		}
+		checked[appointmentID] = true
+
+		existing, err := s.invoiceRepo.GetByAppointmentID(ctx, appointmentID)
+		if err == nil {
+			return errors.NewConflictError(fmt.Sprintf("appointment already invoiced in %s", existing.InvoiceNumber), errors.CodeConflict)
+		}
+		if !isNotFound(err) {
+			return err
+		}
+
+		_, err = s.sessionPackRepo.GetUsageByAppointment(ctx, appointmentID)
+		if err == nil {
+			return errors.NewConflictError("appointment was paid with a session pack", errors.CodeConflict)
+		}
+		if !isNotFound(err) {
+			return fmt.Errorf("failed to get session pack usage: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// InvoiceAppointments invoices several appointments of a client together, one line per session
+func (s *invoiceService) InvoiceAppointments(ctx context.Context, clientID uuid.UUID, appointments []*domain.Appointment, req *InvoiceAppointmentsRequest) (*domain.Invoice, error) {
+	if len(appointments) == 0 {
+		return nil, errors.NewValidationError("no appointments to invoice", map[string][]string{
+			"appointments": {"provide at least one appointment"},
+		})
+	}
+
+	lines := make([]InvoiceLineRequest, 0, len(appointments))
+	for _, appointment := range appointments {
+		if appointment.ClientID != clientID {
+			return nil, errors.NewValidationError("appointment of another client", map[string][]string{
+				"appointments": {fmt.Sprintf("appointment %s does not belong to the client", appointment.ID)},
+			})
+		}
+
+		var unitPrice *money.Money
+		if price, ok := req.UnitPrices[appointment.ID]; ok {
+			unitPrice = &price
+		}
+
+		line, err := s.appointmentLine(ctx, appointment, unitPrice)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	now := time.Now()
+	issueDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	invoiceReq := &CreateInvoiceRequest{
+		ClientID:    clientID,
+		IssueDate:   issueDate,
+		DueDate:     issueDate.AddDate(0, 0, s.taxPolicy.PaymentTermDays),
+		Lines:       lines,
+		Description: req.Description,
+		Notes:       req.Notes,
+	}
+
+	status := domain.InvoiceStatusUnpaid
+	if req.Draft {
+		status = domain.InvoiceStatusDraft
+	}
+
+	if req.Preview {
+		return s.prepareInvoice(ctx, invoiceReq, status)
+	}
+	return s.createInvoice(ctx, invoiceReq, status)
+}
+
+// GetAppointmentInvoice retrieves the invoice of an appointment
+func (s *invoiceService) GetAppointmentInvoice(ctx context.Context, appointmentID uuid.UUID) (*domain.Invoice, error) {
+	return s.invoiceRepo.GetByAppointmentID(ctx, appointmentID)
 }
 
 // GetInvoice retrieves an invoice by ID
@@ -156,7 +727,7 @@ func (s *invoiceService) ListInvoices(ctx context.Context, filters repository.In
 	return s.invoiceRepo.List(ctx, filters)
 }
 
-// UpdateInvoice updates an existing invoice
+// UpdateInvoice updates a draft invoice; issued invoices are immutable
 func (s *invoiceService) UpdateInvoice(ctx context.Context, id uuid.UUID, req *UpdateInvoiceRequest) (*domain.Invoice, error) {
 	// Get existing invoice
 	invoice, err := s.invoiceRepo.GetByID(ctx, id)
@@ -164,10 +735,10 @@ func (s *invoiceService) UpdateInvoice(ctx context.Context, id uuid.UUID, req *U
 		return nil, err
 	}
 
-	// Cannot update paid invoices
-	if invoice.IsPaid() {
-		return nil, errors.NewValidationError("cannot update a paid invoice", map[string][]string{
-			"status": {"invoice is already paid"},
+	// Issued invoices are corrected with a rectifying invoice
+	if invoice.IsIssued() {
+		return nil, errors.NewValidationError("cannot update an issued invoice", map[string][]string{
+			"status": {"issued invoices are immutable; create a rectifying invoice instead"},
 		})
 	}
 
@@ -178,71 +749,260 @@ func (s *invoiceService) UpdateInvoice(ctx context.Context, id uuid.UUID, req *U
 		})
 	}
 
+	// Replace the lines only when new ones are given
+	if len(req.Lines) > 0 || !req.BaseAmount.IsZero() {
+		description := req.Description
+		if description == "" {
+			description = invoice.Description
+		}
+
+		lines, err := s.buildLines(ctx, req.Lines, req.BaseAmount, description, invoice.AppointmentID, invoice.IsRectifying())
+		if err != nil {
+			return nil, err
+		}
+		invoice.Lines = lines
+		invoice.Description = ""
+	}
+
 	// Update fields
 	invoice.IssueDate = req.IssueDate
 	invoice.DueDate = req.DueDate
-	invoice.Description = req.Description
-	invoice.BaseAmount = req.BaseAmount
+	if req.Description != "" {
+		invoice.Description = req.Description
+	}
+	if req.IRPFRate != nil {
+		invoice.IRPFRate = *req.IRPFRate
+	}
 	invoice.Notes = req.Notes
 	invoice.UpdatedAt = time.Now()
 
-	// Recalculate VAT and total
-	invoice.CalculateAmounts()
+	// Recalculate line amounts, VAT and totals
+	if err := invoice.CalculateAmounts(); err != nil {
+		return nil, fmt.Errorf("failed to calculate invoice amounts: %w", err)
+	}
+	if invoice.Description == "" {
+		invoice.Description = invoice.SummarizeLines()
+	}
 
 	// Validate
 	if err := invoice.Validate(); err != nil {
 		return nil, err
 	}
+	if invoice.IsRectifying() {
+		if err := s.checkRectificationBalance(ctx, invoice); err != nil {
+			return nil, err
+		}
+	}
 
 	// Save changes
 	if err := s.invoiceRepo.Update(ctx, invoice); err != nil {
-		return nil, fmt.Errorf("failed to update invoice: %w", err)
+		return nil, wrapInvoiceRepoError(err, "failed to update invoice")
 	}
 
 	return invoice, nil
 }
 
-// DeleteInvoice soft deletes an invoice
+// DeleteInvoice soft deletes a draft invoice; issued invoices cannot be deleted
 func (s *invoiceService) DeleteInvoice(ctx context.Context, id uuid.UUID) error {
-	// Get invoice to check if it exists and is paid
+	// Get invoice to check if it exists and is still a draft
 	invoice, err := s.invoiceRepo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	// Cannot delete paid invoices
-	if invoice.IsPaid() {
-		return errors.NewValidationError("cannot delete a paid invoice", map[string][]string{
-			"status": {"invoice is already paid"},
+	// Issued invoices are cancelled with a rectifying invoice
+	if invoice.IsIssued() {
+		return errors.NewValidationError("cannot delete an issued invoice", map[string][]string{
+			"status": {"issued invoices cannot be deleted; create a cancelling rectifying invoice instead"},
 		})
 	}
 
 	return s.invoiceRepo.Delete(ctx, id)
 }
 
-// MarkAsPaid marks an invoice as paid
-func (s *invoiceService) MarkAsPaid(ctx context.Context, id uuid.UUID) (*domain.Invoice, error) {
-	invoice, err := s.invoiceRepo.GetByID(ctx, id)
+// CreateRectifyingInvoice creates a draft rectifying invoice correcting an issued invoice
+func (s *invoiceService) CreateRectifyingInvoice(ctx context.Context, id uuid.UUID, req *CreateRectifyingInvoiceRequest) (*domain.Invoice, error) {
+	original, err := s.invoiceRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	if invoice.IsPaid() {
-		return nil, errors.NewValidationError("invoice is already paid", map[string][]string{
-			"status": {"invoice is already marked as paid"},
+	if original.IsDraft() {
+		return nil, errors.NewValidationError("only issued invoices can be rectified", map[string][]string{
+			"status": {"draft invoices can be edited directly"},
+		})
+	}
+	if original.IsRectifying() {
+		return nil, errors.NewValidationError("cannot rectify a rectifying invoice", map[string][]string{
+			"invoiceId": {"rectify the original invoice instead"},
 		})
 	}
+	if !req.Mode.IsValid() {
+		return nil, domain.ErrInvalidRectificationMode
+	}
 
-	invoice.MarkAsPaid()
-	invoice.UpdatedAt = time.Now()
+	var lines []*domain.InvoiceLine
+	description := fmt.Sprintf("Rectificación de la factura %s", original.InvoiceNumber)
 
-	if err := s.invoiceRepo.Update(ctx, invoice); err != nil {
-		return nil, fmt.Errorf("failed to mark invoice as paid: %w", err)
+	switch req.Mode {
+	case domain.RectificationModeCancellation:
+		// Reverse the original and every correction already issued, leaving nothing to pay
+		description = fmt.Sprintf("Anulación de la factura %s", original.InvoiceNumber)
+		lines, err = s.cancellationLines(ctx, original)
+		if err != nil {
+			return nil, err
+		}
+	case domain.RectificationModeDifference:
+		if len(req.Lines) == 0 {
+			return nil, errors.NewValidationError("rectification by difference requires lines", map[string][]string{
+				"lines": {"provide the lines correcting the original invoice"},
+			})
+		}
+		lines, err = s.buildLines(ctx, req.Lines, money.Money{}, "", nil, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	issueDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	dueDate := issueDate.Add(original.DueDate.Sub(original.IssueDate))
+	if req.DueDate != nil {
+		dueDate = *req.DueDate
+	}
+	if dueDate.Before(issueDate) {
+		return nil, errors.NewValidationError("due date must be after issue date", map[string][]string{
+			"dueDate": {"must be after issue date"},
+		})
+	}
+
+	// Rectifying invoices are numbered in their own series, at the location of the original
+	series, err := s.rectifyingSeries(ctx, original)
+	if err != nil {
+		return nil, err
+	}
+
+	invoiceID := uuid.New()
+	mode := req.Mode
+	originalID := original.ID
+
+	invoice := &domain.Invoice{
+		ID:                  invoiceID,
+		InvoiceNumber:       domain.DraftInvoiceNumber(invoiceID),
+		ClientID:            original.ClientID,
+		IssueDate:           issueDate,
+		DueDate:             dueDate,
+		Description:         description,
+		Lines:               lines,
+		IRPFRate:            original.IRPFRate,
+		Status:              domain.InvoiceStatusDraft,
+		InvoiceType:         domain.InvoiceTypeRectifying,
+		SeriesID:            &series.ID,
+		RectifiedInvoiceID:  &originalID,
+		RectificationMode:   &mode,
+		RectificationReason: strings.TrimSpace(req.Reason),
+		Notes:               req.Notes,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+	// The correction is addressed to whoever the original invoice was addressed to
+	invoice.CopyPayer(original)
+
+	if err := invoice.CalculateAmounts(); err != nil {
+		return nil, fmt.Errorf("failed to calculate invoice amounts: %w", err)
+	}
+
+	if err := invoice.Validate(); err != nil {
+		if err == domain.ErrEmptyRectification && req.Mode == domain.RectificationModeCancellation {
+			return nil, errors.NewValidationError("invoice is already fully cancelled", map[string][]string{
+				"mode": {"the corrections already issued leave nothing to cancel"},
+			})
+		}
+		return nil, err
+	}
+
+	if err := s.checkRectificationBalance(ctx, invoice); err != nil {
+		return nil, err
+	}
+
+	if err := s.invoiceRepo.Create(ctx, invoice); err != nil {
+		return nil, fmt.Errorf("failed to create rectifying invoice: %w", err)
 	}
 
 	return invoice, nil
 }
 
+// GetRectifyingInvoices retrieves the rectifying invoices of an invoice
+func (s *invoiceService) GetRectifyingInvoices(ctx context.Context, id uuid.UUID) ([]*domain.Invoice, error) {
+	if _, err := s.invoiceRepo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.invoiceRepo.GetRectifyingInvoices(ctx, id)
+}
+
+// cancellationLines returns the lines reversing an invoice and the rectifications already issued
+func (s *invoiceService) cancellationLines(ctx context.Context, original *domain.Invoice) ([]*domain.InvoiceLine, error) {
+	rectifications, err := s.invoiceRepo.GetRectifyingInvoices(ctx, original.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	lines := make([]*domain.InvoiceLine, 0, len(original.Lines))
+	reverse := func(invoiceLines []*domain.InvoiceLine) {
+		for _, line := range invoiceLines {
+			negated := line.Negated()
+			negated.CreatedAt = now
+			negated.UpdatedAt = now
+			lines = append(lines, negated)
+		}
+	}
+
+	reverse(original.Lines)
+	for _, rectification := range rectifications {
+		if rectification.IsIssued() {
+			reverse(rectification.Lines)
+		}
+	}
+
+	return lines, nil
+}
+
+// checkRectificationBalance makes sure the original invoice and all its corrections,
+// including pending drafts, never add up to less than zero
+func (s *invoiceService) checkRectificationBalance(ctx context.Context, invoice *domain.Invoice) error {
+	original, err := s.invoiceRepo.GetByID(ctx, *invoice.RectifiedInvoiceID)
+	if err != nil {
+		return err
+	}
+
+	rectifications, err := s.invoiceRepo.GetRectifyingInvoices(ctx, original.ID)
+	if err != nil {
+		return err
+	}
+
+	totals := []money.Money{original.TotalAmount, invoice.TotalAmount}
+	for _, rectification := range rectifications {
+		if rectification.ID != invoice.ID {
+			totals = append(totals, rectification.TotalAmount)
+		}
+	}
+
+	balance, err := money.Sum(totals...)
+	if err != nil {
+		return fmt.Errorf("failed to add up rectifications: %w", err)
+	}
+
+	if balance.IsNegative() {
+		return errors.NewValidationError("rectification exceeds the amount of the original invoice", map[string][]string{
+			"lines": {fmt.Sprintf("invoice %s would be rectified below zero (%s)", original.InvoiceNumber, balance.Decimal())},
+		})
+	}
+
+	return nil
+}
+
 // GetClientInvoices retrieves all invoices for a client
 func (s *invoiceService) GetClientInvoices(ctx context.Context, clientID uuid.UUID) ([]*domain.Invoice, error) {
 	// Validate client exists
@@ -254,7 +1014,98 @@ func (s *invoiceService) GetClientInvoices(ctx context.Context, clientID uuid.UU
 	return s.invoiceRepo.GetByClientID(ctx, clientID)
 }
 
-// GetUnpaidInvoices retrieves all unpaid invoices
+// GetUnpaidInvoices retrieves the issued invoices with an outstanding balance
 func (s *invoiceService) GetUnpaidInvoices(ctx context.Context) ([]*domain.Invoice, error) {
 	return s.invoiceRepo.GetUnpaidInvoices(ctx)
 }
+
+// buildLines builds the invoice lines of a request, filling defaults from the service types.
+// Without lines, a single line is built from baseAmount and description (legacy requests).
+// Correction lines of rectifying invoices may carry negative quantities or prices.
+func (s *invoiceService) buildLines(ctx context.Context, reqs []InvoiceLineRequest, baseAmount money.Money, description string, appointmentID *uuid.UUID, correction bool) ([]*domain.InvoiceLine, error) {
+	if len(reqs) == 0 {
+		if !baseAmount.IsPositive() {
+			return nil, errors.NewValidationError("invoice must have at least one line", map[string][]string{
+				"lines": {"provide lines or a base amount"},
+			})
+		}
+		unitPrice := baseAmount
+		reqs = []InvoiceLineRequest{{
+			Description:   description,
+			UnitPrice:     &unitPrice,
+			AppointmentID: appointmentID,
+		}}
+	}
+
+	now := time.Now()
+	lines := make([]*domain.InvoiceLine, 0, len(reqs))
+
+	for idx, req := range reqs {
+		line := &domain.InvoiceLine{
+			ID:              uuid.New(),
+			Position:        idx + 1,
+			Description:     req.Description,
+			Quantity:        req.Quantity,
+			DiscountPercent: req.DiscountPercent,
+			VATRate:         domain.DefaultVATRate,
+			AppointmentID:   req.AppointmentID,
+			ServiceTypeID:   req.ServiceTypeID,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+
+		if line.Quantity == 0 {
+			line.Quantity = 1
+		}
+
+		if req.ServiceTypeID != nil {
+			serviceType, err := s.serviceTypeRepo.GetByID(ctx, *req.ServiceTypeID)
+			if err != nil {
+				return nil, errors.NewValidationError("service type not found", map[string][]string{
+					fmt.Sprintf("lines[%d].serviceTypeId", idx): {"service type does not exist"},
+				})
+			}
+			if line.Description == "" {
+				line.Description = serviceType.Name
+			}
+			line.UnitPrice = serviceType.DefaultPrice
+			line.VATRate = serviceType.VATRate
+			line.VATExemption = serviceType.VATExemption
+		}
+
+		if req.UnitPrice != nil {
+			line.UnitPrice = *req.UnitPrice
+		}
+		if req.VATRate != nil {
+			line.VATRate = *req.VATRate
+			line.VATExemption = nil
+		}
+		if req.VATExemption != nil {
+			line.VATExemption = req.VATExemption
+			if req.VATRate == nil {
+				line.VATRate = domain.VATRateZero
+			}
+		}
+
+		validate := line.Validate
+		if correction {
+			validate = line.ValidateCorrection
+		}
+		if err := validate(); err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+// invoiceClient retrieves the client of an invoice; nil for the settlement invoices of an
+// insurer, which are addressed only to their payer
+func invoiceClient(ctx context.Context, clientRepo repository.ClientRepository, invoice *domain.Invoice) (*domain.Client, error) {
+	if !invoice.HasClient() {
+		return nil, nil
+	}
+	return clientRepo.GetByID(ctx, invoice.ClientID)
+}
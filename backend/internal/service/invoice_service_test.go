@@ -0,0 +1,899 @@
+package service
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockInvoiceRepository is a mock implementation of InvoiceRepository
+type MockInvoiceRepository struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceRepository) invoiceResult(args mock.Arguments) (*domain.Invoice, error) {
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Invoice), args.Error(1)
+}
+
+func (m *MockInvoiceRepository) invoicesResult(args mock.Arguments) ([]*domain.Invoice, error) {
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Invoice), args.Error(1)
+}
+
+func (m *MockInvoiceRepository) Create(ctx context.Context, invoice *domain.Invoice) error {
+	args := m.Called(ctx, invoice)
+	return args.Error(0)
+}
+
+func (m *MockInvoiceRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, id))
+}
+
+func (m *MockInvoiceRepository) GetByInvoiceNumber(ctx context.Context, invoiceNumber string) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, invoiceNumber))
+}
+
+func (m *MockInvoiceRepository) List(ctx context.Context, filters repository.InvoiceFilters) ([]*domain.Invoice, int, error) {
+	args := m.Called(ctx, filters)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]*domain.Invoice), args.Int(1), args.Error(2)
+}
+
+func (m *MockInvoiceRepository) Update(ctx context.Context, invoice *domain.Invoice) error {
+	args := m.Called(ctx, invoice)
+	return args.Error(0)
+}
+
+func (m *MockInvoiceRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockInvoiceRepository) Issue(ctx context.Context, invoice *domain.Invoice, recorder repository.InvoiceIssueRecorder) error {
+	args := m.Called(ctx, invoice)
+	if err := args.Error(0); err != nil || recorder == nil {
+		return err
+	}
+
+	// Like the database, the issue fails when its records cannot be built
+	_, err := recorder.IssueRecords(invoice)
+	return err
+}
+
+func (m *MockInvoiceRepository) GetRectifyingInvoices(ctx context.Context, invoiceID uuid.UUID) ([]*domain.Invoice, error) {
+	return m.invoicesResult(m.Called(ctx, invoiceID))
+}
+
+func (m *MockInvoiceRepository) GetByClientID(ctx context.Context, clientID uuid.UUID) ([]*domain.Invoice, error) {
+	return m.invoicesResult(m.Called(ctx, clientID))
+}
+
+func (m *MockInvoiceRepository) GetByAppointmentID(ctx context.Context, appointmentID uuid.UUID) (*domain.Invoice, error) {
+	return m.invoiceResult(m.Called(ctx, appointmentID))
+}
+
+func (m *MockInvoiceRepository) UpdatePDFPath(ctx context.Context, id uuid.UUID, pdfPath *string) error {
+	args := m.Called(ctx, id, pdfPath)
+	return args.Error(0)
+}
+
+func (m *MockInvoiceRepository) GetTotalRevenueByDateRange(ctx context.Context, fromDate, toDate time.Time) (money.Money, error) {
+	args := m.Called(ctx, fromDate, toDate)
+	return args.Get(0).(money.Money), args.Error(1)
+}
+
+func (m *MockInvoiceRepository) GetIssuedByPeriod(ctx context.Context, granularity repository.PeriodGranularity, fromDate, toDate time.Time) ([]repository.PeriodTotal, error) {
+	args := m.Called(ctx, granularity, fromDate, toDate)
+	return args.Get(0).([]repository.PeriodTotal), args.Error(1)
+}
+
+func (m *MockInvoiceRepository) GetCollectedByPeriod(ctx context.Context, granularity repository.PeriodGranularity, fromDate, toDate time.Time) ([]repository.PeriodTotal, error) {
+	args := m.Called(ctx, granularity, fromDate, toDate)
+	return args.Get(0).([]repository.PeriodTotal), args.Error(1)
+}
+
+func (m *MockInvoiceRepository) GetIssuedByDateRange(ctx context.Context, fromDate, toDate time.Time) ([]*domain.Invoice, error) {
+	return m.invoicesResult(m.Called(ctx, fromDate, toDate))
+}
+
+func (m *MockInvoiceRepository) GetUnpaidInvoices(ctx context.Context) ([]*domain.Invoice, error) {
+	return m.invoicesResult(m.Called(ctx))
+}
+
+func (m *MockInvoiceRepository) GetOutstandingInvoices(ctx context.Context, filters repository.OutstandingInvoiceFilters) ([]*domain.Invoice, error) {
+	return m.invoicesResult(m.Called(ctx, filters))
+}
+
+// MockServiceTypeRepository is a mock implementation of ServiceTypeRepository
+type MockServiceTypeRepository struct {
+	mock.Mock
+}
+
+func (m *MockServiceTypeRepository) Create(ctx context.Context, serviceType *domain.ServiceType) error {
+	args := m.Called(ctx, serviceType)
+	return args.Error(0)
+}
+
+func (m *MockServiceTypeRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.ServiceType, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ServiceType), args.Error(1)
+}
+
+func (m *MockServiceTypeRepository) List(ctx context.Context, activeOnly bool) ([]*domain.ServiceType, error) {
+	args := m.Called(ctx, activeOnly)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.ServiceType), args.Error(1)
+}
+
+func (m *MockServiceTypeRepository) Update(ctx context.Context, serviceType *domain.ServiceType) error {
+	args := m.Called(ctx, serviceType)
+	return args.Error(0)
+}
+
+func (m *MockServiceTypeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// Default series returned by the series repository of newInvoiceTestService
+var (
+	testOrdinarySeries   = &domain.InvoiceSeries{ID: uuid.New(), Name: "Ordinarias", Prefix: "F", InvoiceType: domain.InvoiceTypeOrdinary, IsDefault: true, YearlyReset: true, Padding: 4, IsActive: true}
+	testRectifyingSeries = &domain.InvoiceSeries{ID: uuid.New(), Name: "Rectificativas", Prefix: "R", InvoiceType: domain.InvoiceTypeRectifying, IsDefault: true, YearlyReset: true, Padding: 4, IsActive: true}
+)
+
+func newInvoiceTestService() (*invoiceService, *MockInvoiceRepository, *MockClientRepository, *MockServiceTypeRepository) {
+	invoiceRepo := new(MockInvoiceRepository)
+	clientRepo := new(MockClientRepository)
+	serviceTypeRepo := new(MockServiceTypeRepository)
+	seriesRepo := new(MockInvoiceSeriesRepository)
+	seriesRepo.On("GetDefault", mock.Anything, domain.InvoiceTypeOrdinary, mock.Anything).Return(testOrdinarySeries, nil).Maybe()
+	seriesRepo.On("GetDefault", mock.Anything, domain.InvoiceTypeRectifying, mock.Anything).Return(testRectifyingSeries, nil).Maybe()
+
+	svc := NewInvoiceService(invoiceRepo, clientRepo, newNoPayerRepository(), serviceTypeRepo, seriesRepo, new(MockAppointmentRepository), new(MockEmployeeRepository), newNoUsageRepository(), newNotCoveredRepository(), InvoiceTaxPolicy{IRPFRate: 15}, nil).(*invoiceService)
+	return svc, invoiceRepo, clientRepo, serviceTypeRepo
+}
+
+// issueAs makes a mocked Issue assign the given number, as the series counter would
+func issueAs(number string) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		args.Get(1).(*domain.Invoice).InvoiceNumber = number
+	}
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func pricePtr(amount string) *money.Money {
+	price := money.MustParse(amount)
+	return &price
+}
+
+func TestInvoiceService_CreateInvoice_TotalsFromLines(t *testing.T) {
+	svc, invoiceRepo, clientRepo, serviceTypeRepo := newInvoiceTestService()
+	ctx := context.Background()
+
+	clientID := uuid.New()
+	session := &domain.ServiceType{ID: uuid.New(), Name: "Sesión de psicoterapia", DefaultPrice: money.MustParse("60"), VATRate: 21}
+	issueDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+	serviceTypeRepo.On("GetByID", ctx, session.ID).Return(session, nil)
+	invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).Run(issueAs("F_2025_0007")).Return(nil)
+
+	invoice, err := svc.CreateInvoice(ctx, &CreateInvoiceRequest{
+		ClientID:  clientID,
+		IssueDate: issueDate,
+		DueDate:   issueDate.AddDate(0, 0, 15),
+		Lines: []InvoiceLineRequest{
+			{ServiceTypeID: &session.ID, Quantity: 4, DiscountPercent: 10},
+			{Description: "Informe psicológico", UnitPrice: pricePtr("80"), VATRate: floatPtr(21)},
+		},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, invoice.Lines, 2)
+
+	first := invoice.Lines[0]
+	assert.Equal(t, "Sesión de psicoterapia", first.Description)
+	assert.Equal(t, 1, first.Position)
+	assert.Equal(t, invoice.ID, first.InvoiceID)
+	assert.Equal(t, "216.00", first.BaseAmount.Decimal()) // 4 x 60 - 10%
+	assert.Equal(t, 2, invoice.Lines[1].Position)
+
+	assert.Equal(t, "296.00", invoice.BaseAmount.Decimal())
+	assert.Equal(t, "62.16", invoice.VATAmount.Decimal())
+	assert.Equal(t, "358.16", invoice.TotalAmount.Decimal())
+	assert.Equal(t, 21.0, invoice.VATRate)
+	assert.Equal(t, "Sesión de psicoterapia y 1 conceptos más", invoice.Description)
+	assert.Equal(t, "F_2025_0007", invoice.InvoiceNumber)
+	assert.Equal(t, &testOrdinarySeries.ID, invoice.SeriesID)
+	invoiceRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_CreateInvoice_LegacyBaseAmountBuildsSingleLine(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	clientID := uuid.New()
+	appointmentID := uuid.New()
+	issueDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+	invoiceRepo.On("GetByAppointmentID", ctx, appointmentID).Return(nil, errors.NewNotFoundError("invoice not found"))
+	invoiceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+
+	invoice, err := svc.CreateDraftInvoice(ctx, &CreateInvoiceRequest{
+		ClientID:      clientID,
+		AppointmentID: &appointmentID,
+		IssueDate:     issueDate,
+		DueDate:       issueDate.AddDate(0, 0, 15),
+		BaseAmount:    money.MustParse("50"),
+		Description:   "Sesión individual",
+	})
+
+	require.NoError(t, err)
+	require.Len(t, invoice.Lines, 1)
+	assert.Equal(t, "Sesión individual", invoice.Lines[0].Description)
+	assert.Equal(t, 1.0, invoice.Lines[0].Quantity)
+	assert.Equal(t, "50.00", invoice.Lines[0].UnitPrice.Decimal())
+	assert.Equal(t, &appointmentID, invoice.Lines[0].AppointmentID)
+	assert.Equal(t, "50.00", invoice.BaseAmount.Decimal())
+	assert.Equal(t, &testOrdinarySeries.ID, invoice.SeriesID)
+	invoiceRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_CreateInvoice_RequiresLines(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	clientID := uuid.New()
+	issueDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+
+	_, err := svc.CreateInvoice(ctx, &CreateInvoiceRequest{
+		ClientID:  clientID,
+		IssueDate: issueDate,
+		DueDate:   issueDate,
+	})
+
+	require.Error(t, err)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, errors.CodeValidationFailed, appErr.Code)
+	invoiceRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_UpdateInvoice_Lines(t *testing.T) {
+	ctx := context.Background()
+	issueDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	existing := func() *domain.Invoice {
+		invoice := &domain.Invoice{
+			ID:            uuid.New(),
+			InvoiceNumber: "F_2025_0001",
+			ClientID:      uuid.New(),
+			IssueDate:     issueDate,
+			DueDate:       issueDate.AddDate(0, 0, 15),
+			Description:   "Sesión",
+			Status:        domain.InvoiceStatusDraft,
+			InvoiceType:   domain.InvoiceTypeOrdinary,
+			Lines: []*domain.InvoiceLine{
+				{ID: uuid.New(), Description: "Sesión", Quantity: 1, UnitPrice: money.MustParse("60"), VATRate: 21},
+			},
+		}
+		require.NoError(t, invoice.CalculateAmounts())
+		return invoice
+	}
+
+	t.Run("replaces lines when given", func(t *testing.T) {
+		svc, invoiceRepo, _, _ := newInvoiceTestService()
+		invoice := existing()
+
+		invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+		invoiceRepo.On("Update", ctx, invoice).Return(nil)
+
+		updated, err := svc.UpdateInvoice(ctx, invoice.ID, &UpdateInvoiceRequest{
+			IssueDate: issueDate,
+			DueDate:   issueDate.AddDate(0, 0, 30),
+			Lines: []InvoiceLineRequest{
+				{Description: "Sesión de pareja", Quantity: 2, UnitPrice: pricePtr("75"), VATRate: floatPtr(21)},
+			},
+		})
+
+		require.NoError(t, err)
+		require.Len(t, updated.Lines, 1)
+		assert.Equal(t, "Sesión de pareja", updated.Description)
+		assert.Equal(t, "150.00", updated.BaseAmount.Decimal())
+		assert.Equal(t, "181.50", updated.TotalAmount.Decimal())
+	})
+
+	t.Run("keeps lines when none are given", func(t *testing.T) {
+		svc, invoiceRepo, _, _ := newInvoiceTestService()
+		invoice := existing()
+		lineID := invoice.Lines[0].ID
+
+		invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+		invoiceRepo.On("Update", ctx, invoice).Return(nil)
+
+		updated, err := svc.UpdateInvoice(ctx, invoice.ID, &UpdateInvoiceRequest{
+			IssueDate: issueDate,
+			DueDate:   issueDate.AddDate(0, 0, 30),
+			Notes:     "Pago por transferencia",
+		})
+
+		require.NoError(t, err)
+		require.Len(t, updated.Lines, 1)
+		assert.Equal(t, lineID, updated.Lines[0].ID)
+		assert.Equal(t, "Sesión", updated.Description)
+		assert.Equal(t, "60.00", updated.BaseAmount.Decimal())
+	})
+}
+
+func TestInvoiceService_CreateInvoice_TaxModel(t *testing.T) {
+	ctx := context.Background()
+	issueDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	exempt := domain.VATExemptArticle20
+
+	tests := []struct {
+		name          string
+		taxID         string
+		lines         []InvoiceLineRequest
+		irpfRate      *float64
+		expectedBase  string
+		expectedVAT   string
+		expectedIRPF  string
+		expectedTotal string
+		breakdown     int
+	}{
+		{
+			name:          "default rate is 21 percent",
+			taxID:         "12345678Z",
+			lines:         []InvoiceLineRequest{{Description: "Taller", UnitPrice: pricePtr("100")}},
+			expectedBase:  "100",
+			expectedVAT:   "21",
+			expectedIRPF:  "0",
+			expectedTotal: "121",
+			breakdown:     1,
+		},
+		{
+			name:          "exempt psychology session carries no VAT",
+			taxID:         "12345678Z",
+			lines:         []InvoiceLineRequest{{Description: "Sesión", UnitPrice: pricePtr("60"), VATExemption: &exempt}},
+			expectedBase:  "60",
+			expectedVAT:   "0",
+			expectedIRPF:  "0",
+			expectedTotal: "60",
+			breakdown:     1,
+		},
+		{
+			name:  "business client gets IRPF withheld",
+			taxID: "B12345678",
+			lines: []InvoiceLineRequest{
+				{Description: "Formación in company", UnitPrice: pricePtr("500"), VATRate: floatPtr(21)},
+				{Description: "Sesión", UnitPrice: pricePtr("60"), VATExemption: &exempt},
+			},
+			expectedBase:  "560",
+			expectedVAT:   "105",
+			expectedIRPF:  "84",
+			expectedTotal: "581",
+			breakdown:     2,
+		},
+		{
+			name:          "explicit IRPF rate overrides the default",
+			taxID:         "12345678Z",
+			lines:         []InvoiceLineRequest{{Description: "Supervisión", UnitPrice: pricePtr("200"), VATRate: floatPtr(21)}},
+			irpfRate:      floatPtr(7),
+			expectedBase:  "200",
+			expectedVAT:   "42",
+			expectedIRPF:  "14",
+			expectedTotal: "228",
+			breakdown:     1,
+		},
+		{
+			name: "VAT is rounded once per rate group",
+			// Three lines of 0.05 at 10%: per line 0.005 -> 0.01 each, per group 0.15 -> 0.02
+			taxID: "12345678Z",
+			lines: []InvoiceLineRequest{
+				{Description: "Fotocopia", UnitPrice: pricePtr("0.05"), VATRate: floatPtr(10)},
+				{Description: "Fotocopia", UnitPrice: pricePtr("0.05"), VATRate: floatPtr(10)},
+				{Description: "Fotocopia", UnitPrice: pricePtr("0.05"), VATRate: floatPtr(10)},
+			},
+			expectedBase:  "0.15",
+			expectedVAT:   "0.02",
+			expectedIRPF:  "0",
+			expectedTotal: "0.17",
+			breakdown:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+			clientID := uuid.New()
+
+			clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID, DNICIF: tt.taxID}, nil)
+			invoiceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+
+			invoice, err := svc.CreateDraftInvoice(ctx, &CreateInvoiceRequest{
+				ClientID:  clientID,
+				IssueDate: issueDate,
+				DueDate:   issueDate.AddDate(0, 0, 15),
+				Lines:     tt.lines,
+				IRPFRate:  tt.irpfRate,
+			})
+
+			require.NoError(t, err)
+			assert.True(t, money.MustParse(tt.expectedBase).Equal(invoice.BaseAmount), "base %s", invoice.BaseAmount)
+			assert.True(t, money.MustParse(tt.expectedVAT).Equal(invoice.VATAmount), "VAT %s", invoice.VATAmount)
+			assert.True(t, money.MustParse(tt.expectedIRPF).Equal(invoice.IRPFAmount), "IRPF %s", invoice.IRPFAmount)
+			assert.True(t, money.MustParse(tt.expectedTotal).Equal(invoice.TotalAmount), "total %s", invoice.TotalAmount)
+
+			breakdown, err := invoice.TaxBreakdown()
+			require.NoError(t, err)
+			assert.Len(t, breakdown, tt.breakdown)
+		})
+	}
+}
+
+func TestInvoiceService_CreateInvoice_RejectsInvalidVAT(t *testing.T) {
+	ctx := context.Background()
+	issueDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	exempt := domain.VATExemptArticle20
+	unknown := domain.VATExemptionCause("E9")
+
+	tests := []struct {
+		name string
+		line InvoiceLineRequest
+	}{
+		{name: "non Spanish rate", line: InvoiceLineRequest{Description: "Sesión", UnitPrice: pricePtr("60"), VATRate: floatPtr(0.21)}},
+		{name: "exempt line with VAT", line: InvoiceLineRequest{Description: "Sesión", UnitPrice: pricePtr("60"), VATRate: floatPtr(21), VATExemption: &exempt}},
+		{name: "unknown exemption cause", line: InvoiceLineRequest{Description: "Sesión", UnitPrice: pricePtr("60"), VATExemption: &unknown}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+			clientID := uuid.New()
+			clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+
+			_, err := svc.CreateDraftInvoice(ctx, &CreateInvoiceRequest{
+				ClientID:  clientID,
+				IssueDate: issueDate,
+				DueDate:   issueDate,
+				Lines:     []InvoiceLineRequest{tt.line},
+			})
+
+			require.Error(t, err)
+			appErr, ok := err.(*errors.AppError)
+			require.True(t, ok)
+			assert.Equal(t, errors.CodeValidationFailed, appErr.Code)
+			invoiceRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestInvoiceService_CreateInvoice_TotalsProperties(t *testing.T) {
+	ctx := context.Background()
+	issueDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	rates := []float64{domain.VATRateGeneral, domain.VATRateReduced, domain.VATRateSuperReduced, domain.VATRateZero}
+	exempt := domain.VATExemptArticle20
+
+	// Random invoices of up to 8 lines with prices, quantities, discounts, rates and IRPF
+	property := func(seed int64) bool {
+		rnd := rand.New(rand.NewSource(seed))
+
+		lines := make([]InvoiceLineRequest, 1+rnd.Intn(8))
+		for i := range lines {
+			lines[i] = InvoiceLineRequest{
+				Description:     "Concepto",
+				Quantity:        float64(1+rnd.Intn(400)) / 4,
+				UnitPrice:       pricePtr(money.FromCents(rnd.Int63n(100000)).Decimal()),
+				DiscountPercent: float64(rnd.Intn(101)),
+				VATRate:         floatPtr(rates[rnd.Intn(len(rates))]),
+			}
+			if *lines[i].VATRate == domain.VATRateZero && rnd.Intn(2) == 0 {
+				lines[i].VATExemption = &exempt
+			}
+		}
+		// Guarantee a positive base
+		lines[0].UnitPrice = pricePtr("1.00")
+		lines[0].DiscountPercent = 0
+
+		svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+		clientID := uuid.New()
+		clientRepo.On("GetByID", ctx, clientID).Return(&domain.Client{ID: clientID}, nil)
+		invoiceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+
+		invoice, err := svc.CreateDraftInvoice(ctx, &CreateInvoiceRequest{
+			ClientID:  clientID,
+			IssueDate: issueDate,
+			DueDate:   issueDate,
+			Lines:     lines,
+			IRPFRate:  floatPtr(float64(rnd.Intn(20))),
+		})
+		if err != nil {
+			t.Logf("seed %d: %v", seed, err)
+			return false
+		}
+
+		// The invoice base is the sum of the line bases
+		var lineBases []money.Money
+		for _, line := range invoice.Lines {
+			total, err := line.BaseAmount.Add(line.VATAmount)
+			if err != nil || !total.Equal(line.TotalAmount) {
+				return false
+			}
+			lineBases = append(lineBases, line.BaseAmount)
+		}
+		base, err := money.Sum(lineBases...)
+		if err != nil || !base.Equal(invoice.BaseAmount) {
+			return false
+		}
+
+		// The invoice VAT is the sum of the quotas of the rate groups
+		breakdown, err := invoice.TaxBreakdown()
+		if err != nil {
+			return false
+		}
+		var quotas []money.Money
+		for _, group := range breakdown {
+			if group.IsExempt() && !group.VATAmount.IsZero() {
+				return false
+			}
+			quotas = append(quotas, group.VATAmount)
+		}
+		vat, err := money.Sum(quotas...)
+		if err != nil || !vat.Equal(invoice.VATAmount) {
+			return false
+		}
+
+		// Total = base + VAT - IRPF, to the cent
+		return invoice.BaseAmount.Cents()+invoice.VATAmount.Cents()-invoice.IRPFAmount.Cents() == invoice.TotalAmount.Cents()
+	}
+
+	assert.NoError(t, quick.Check(property, &quick.Config{MaxCount: 300}))
+}
+
+func TestInvoiceService_CreateInvoice_RefusesMixedCurrencies(t *testing.T) {
+	invoice := &domain.Invoice{
+		ID: uuid.New(),
+		Lines: []*domain.InvoiceLine{
+			{Description: "Sesión", Quantity: 1, UnitPrice: money.New(6000, money.EUR), VATRate: 21},
+			{Description: "Session", Quantity: 1, UnitPrice: money.New(6000, "USD"), VATRate: 21},
+		},
+	}
+
+	assert.ErrorIs(t, invoice.CalculateAmounts(), money.ErrCurrencyMismatch)
+}
+
+// newAppointmentInvoiceTest prepares a completed appointment of a client and an employee
+func newAppointmentInvoiceTest(svc *invoiceService, clientRepo *MockClientRepository, serviceTypeID *uuid.UUID, sessionRate *money.Money) (*domain.Appointment, *MockAppointmentRepository, *MockEmployeeRepository) {
+	appointmentRepo := new(MockAppointmentRepository)
+	employeeRepo := new(MockEmployeeRepository)
+	svc.appointmentRepo = appointmentRepo
+	svc.employeeRepo = employeeRepo
+
+	appointment := &domain.Appointment{
+		ID:            uuid.New(),
+		ClientID:      uuid.New(),
+		EmployeeID:    uuid.New(),
+		Title:         "Sesión individual",
+		StartTime:     time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC),
+		Status:        domain.AppointmentStatusCompleted,
+		ServiceTypeID: serviceTypeID,
+	}
+
+	appointmentRepo.On("GetByID", mock.Anything, appointment.ID).Return(appointment, nil)
+	employeeRepo.On("GetByID", mock.Anything, appointment.EmployeeID).Return(&domain.Employee{ID: appointment.EmployeeID, SessionRate: sessionRate}, nil)
+	clientRepo.On("GetByID", mock.Anything, appointment.ClientID).Return(&domain.Client{ID: appointment.ClientID}, nil)
+
+	return appointment, appointmentRepo, employeeRepo
+}
+
+func TestInvoiceService_CreateInvoiceFromAppointment_ServiceTypePrice(t *testing.T) {
+	svc, invoiceRepo, clientRepo, serviceTypeRepo := newInvoiceTestService()
+	ctx := context.Background()
+
+	exempt := domain.VATExemptArticle20
+	session := &domain.ServiceType{ID: uuid.New(), Name: "Sesión de psicoterapia", DefaultPrice: money.MustParse("60"), VATRate: 0, VATExemption: &exempt}
+	appointment, _, employeeRepo := newAppointmentInvoiceTest(svc, clientRepo, &session.ID, pricePtr("45"))
+
+	serviceTypeRepo.On("GetByID", ctx, session.ID).Return(session, nil)
+	invoiceRepo.On("GetByAppointmentID", ctx, appointment.ID).Return(nil, errors.NewNotFoundError("invoice not found"))
+	invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).Run(issueAs("F_2025_0011")).Return(nil)
+
+	invoice, err := svc.CreateInvoiceFromAppointment(ctx, appointment.ID, &CreateInvoiceFromAppointmentRequest{})
+
+	require.NoError(t, err)
+	assert.Equal(t, "F_2025_0011", invoice.InvoiceNumber)
+	assert.Equal(t, appointment.ClientID, invoice.ClientID)
+	assert.Equal(t, &appointment.ID, invoice.AppointmentID)
+	assert.Equal(t, 15*24*time.Hour, invoice.DueDate.Sub(invoice.IssueDate))
+	require.Len(t, invoice.Lines, 1)
+	assert.Equal(t, "Sesión de psicoterapia - cita del 03/03/2025 10:00", invoice.Lines[0].Description)
+	assert.Equal(t, "60.00", invoice.Lines[0].UnitPrice.Decimal())
+	assert.Equal(t, &exempt, invoice.Lines[0].VATExemption)
+	assert.Equal(t, &appointment.ID, invoice.Lines[0].AppointmentID)
+	assert.Equal(t, "60.00", invoice.TotalAmount.Decimal())
+	employeeRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_CreateInvoiceFromAppointment_EmployeeRateDraft(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	appointment, _, _ := newAppointmentInvoiceTest(svc, clientRepo, nil, pricePtr("50"))
+
+	invoiceRepo.On("GetByAppointmentID", ctx, appointment.ID).Return(nil, errors.NewNotFoundError("invoice not found"))
+	invoiceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+
+	invoice, err := svc.CreateInvoiceFromAppointment(ctx, appointment.ID, &CreateInvoiceFromAppointmentRequest{Draft: true})
+
+	require.NoError(t, err)
+	assert.True(t, invoice.IsDraft())
+	require.Len(t, invoice.Lines, 1)
+	assert.Equal(t, "Sesión individual - cita del 03/03/2025 10:00", invoice.Lines[0].Description)
+	assert.Equal(t, "50.00", invoice.Lines[0].UnitPrice.Decimal())
+	assert.Equal(t, domain.DefaultVATRate, invoice.Lines[0].VATRate)
+	invoiceRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_CreateInvoiceFromAppointment_UnpricedServiceTypeUsesEmployeeRate(t *testing.T) {
+	svc, invoiceRepo, clientRepo, serviceTypeRepo := newInvoiceTestService()
+	ctx := context.Background()
+
+	exempt := domain.VATExemptArticle20
+	assessment := &domain.ServiceType{ID: uuid.New(), Name: "Valoración inicial", VATExemption: &exempt}
+	appointment, _, _ := newAppointmentInvoiceTest(svc, clientRepo, &assessment.ID, pricePtr("70"))
+
+	serviceTypeRepo.On("GetByID", ctx, assessment.ID).Return(assessment, nil)
+	invoiceRepo.On("GetByAppointmentID", ctx, appointment.ID).Return(nil, errors.NewNotFoundError("invoice not found"))
+	invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+
+	invoice, err := svc.CreateInvoiceFromAppointment(ctx, appointment.ID, &CreateInvoiceFromAppointmentRequest{})
+
+	require.NoError(t, err)
+	assert.Equal(t, "70.00", invoice.Lines[0].UnitPrice.Decimal())
+	assert.Equal(t, &exempt, invoice.Lines[0].VATExemption, "the service type keeps its tax treatment")
+	assert.Equal(t, "70.00", invoice.TotalAmount.Decimal())
+}
+
+func TestInvoiceService_CreateInvoiceFromAppointment_PriceOverride(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	appointment, _, employeeRepo := newAppointmentInvoiceTest(svc, clientRepo, nil, nil)
+
+	invoiceRepo.On("GetByAppointmentID", ctx, appointment.ID).Return(nil, errors.NewNotFoundError("invoice not found"))
+	invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+
+	invoice, err := svc.CreateInvoiceFromAppointment(ctx, appointment.ID, &CreateInvoiceFromAppointmentRequest{UnitPrice: pricePtr("40"), Notes: "Tarifa reducida"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "40.00", invoice.Lines[0].UnitPrice.Decimal())
+	assert.Equal(t, "Tarifa reducida", invoice.Notes)
+	employeeRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_CreateInvoiceFromAppointment_RequiresPrice(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	appointment, _, _ := newAppointmentInvoiceTest(svc, clientRepo, nil, nil)
+
+	_, err := svc.CreateInvoiceFromAppointment(ctx, appointment.ID, &CreateInvoiceFromAppointmentRequest{})
+
+	requireValidationError(t, err)
+	assert.Contains(t, err.(*errors.AppError).Details, "unitPrice")
+	invoiceRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_CreateInvoiceFromAppointment_RefusesDuplicates(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	appointment, _, _ := newAppointmentInvoiceTest(svc, clientRepo, nil, pricePtr("50"))
+	invoiceRepo.On("GetByAppointmentID", ctx, appointment.ID).Return(&domain.Invoice{ID: uuid.New(), InvoiceNumber: "F_2025_0004"}, nil)
+
+	_, err := svc.CreateInvoiceFromAppointment(ctx, appointment.ID, &CreateInvoiceFromAppointmentRequest{})
+
+	require.Error(t, err)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, errors.CodeConflict, appErr.Code)
+	assert.Contains(t, appErr.Message, "F_2025_0004")
+	invoiceRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+	invoiceRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_CreateInvoiceFromAppointment_RefusesPackSessions(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	appointment, _, _ := newAppointmentInvoiceTest(svc, clientRepo, nil, pricePtr("50"))
+	invoiceRepo.On("GetByAppointmentID", ctx, appointment.ID).Return(nil, errors.NewNotFoundError("invoice not found"))
+	sessionPackRepo := new(MockSessionPackRepository)
+	sessionPackRepo.On("GetUsageByAppointment", ctx, appointment.ID).
+		Return(&domain.SessionPackUsage{ID: uuid.New(), AppointmentID: appointment.ID}, nil)
+	svc.sessionPackRepo = sessionPackRepo
+
+	_, err := svc.CreateInvoiceFromAppointment(ctx, appointment.ID, &CreateInvoiceFromAppointmentRequest{})
+
+	require.Error(t, err)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, errors.CodeConflict, appErr.Code)
+	invoiceRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_CreateInvoiceFromAppointment_CoveredSessionBillsTheCopay(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	appointment, _, _ := newAppointmentInvoiceTest(svc, clientRepo, nil, pricePtr("60"))
+	invoiceRepo.On("GetByAppointmentID", ctx, appointment.ID).Return(nil, errors.NewNotFoundError("invoice not found"))
+	invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).Return(nil)
+	insurerRepo := new(MockInsurerRepository)
+	insurerRepo.On("GetCoveredSession", ctx, appointment.ID).
+		Return(&domain.CoveredSession{AppointmentID: appointment.ID, InsurerAmount: money.MustParse("45"), CopayAmount: money.MustParse("15")}, nil)
+	svc.insurerRepo = insurerRepo
+
+	invoice, err := svc.CreateInvoiceFromAppointment(ctx, appointment.ID, &CreateInvoiceFromAppointmentRequest{})
+
+	require.NoError(t, err)
+	assert.Equal(t, "15.00", invoice.Lines[0].UnitPrice.Decimal(), "the insurer is billed the rest")
+	assert.Contains(t, invoice.Notes, "Copago")
+}
+
+func TestInvoiceService_CreateInvoiceFromAppointment_RefusesCoveredSessionsWithoutCopay(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	appointment, _, _ := newAppointmentInvoiceTest(svc, clientRepo, nil, pricePtr("60"))
+	insurerRepo := new(MockInsurerRepository)
+	insurerRepo.On("GetCoveredSession", ctx, appointment.ID).
+		Return(&domain.CoveredSession{AppointmentID: appointment.ID, InsurerAmount: money.MustParse("60")}, nil)
+	svc.insurerRepo = insurerRepo
+
+	_, err := svc.CreateInvoiceFromAppointment(ctx, appointment.ID, &CreateInvoiceFromAppointmentRequest{UnitPrice: pricePtr("60")})
+
+	require.Error(t, err)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, errors.CodeConflict, appErr.Code)
+	invoiceRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_CreateInvoiceFromAppointment_ConcurrentInvoiceIsAConflict(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	// The early check passes, but another invoice billed the session before the insert
+	appointment, _, _ := newAppointmentInvoiceTest(svc, clientRepo, nil, pricePtr("50"))
+	invoiceRepo.On("GetByAppointmentID", ctx, appointment.ID).Return(nil, errors.NewNotFoundError("invoice not found"))
+	invoiceRepo.On("Issue", ctx, mock.AnythingOfType("*domain.Invoice")).
+		Return(errors.NewConflictError("appointment already invoiced in F_2025_0005", errors.CodeConflict))
+
+	_, err := svc.CreateInvoiceFromAppointment(ctx, appointment.ID, &CreateInvoiceFromAppointmentRequest{})
+
+	require.Error(t, err)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok, "the conflict reaches callers unwrapped")
+	assert.Equal(t, errors.CodeConflict, appErr.Code)
+	assert.Contains(t, appErr.Message, "F_2025_0005")
+}
+
+func TestInvoiceService_CreateInvoiceFromAppointment_RefusesCancelled(t *testing.T) {
+	svc, _, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	appointment, _, _ := newAppointmentInvoiceTest(svc, clientRepo, nil, pricePtr("50"))
+	appointment.Status = domain.AppointmentStatusCancelled
+
+	_, err := svc.CreateInvoiceFromAppointment(ctx, appointment.ID, &CreateInvoiceFromAppointmentRequest{})
+
+	requireValidationError(t, err)
+}
+
+func TestInvoiceService_InvoiceAppointments_PreviewIsNotStored(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	first, _, _ := newAppointmentInvoiceTest(svc, clientRepo, nil, pricePtr("50"))
+	second := *first
+	second.ID = uuid.New()
+	second.StartTime = first.StartTime.AddDate(0, 0, 7)
+
+	invoiceRepo.On("GetByAppointmentID", ctx, mock.Anything).Return(nil, errors.NewNotFoundError("invoice not found"))
+
+	invoice, err := svc.InvoiceAppointments(ctx, first.ClientID, []*domain.Appointment{first, &second}, &InvoiceAppointmentsRequest{
+		Description: "Sesiones de marzo de 2025",
+		Preview:     true,
+	})
+
+	require.NoError(t, err)
+	assert.Nil(t, invoice.AppointmentID)
+	require.Len(t, invoice.Lines, 2)
+	assert.Equal(t, &first.ID, invoice.Lines[0].AppointmentID)
+	assert.Equal(t, "Sesión individual - cita del 10/03/2025 10:00", invoice.Lines[1].Description)
+	assert.Equal(t, "Sesiones de marzo de 2025", invoice.Description)
+	assert.Equal(t, "121.00", invoice.TotalAmount.Decimal()) // 2 x 50 + 21% VAT
+	invoiceRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+	invoiceRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_InvoiceAppointments_PriceOverrides(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	covered, _, _ := newAppointmentInvoiceTest(svc, clientRepo, nil, pricePtr("50"))
+	uncovered := *covered
+	uncovered.ID = uuid.New()
+
+	invoiceRepo.On("GetByAppointmentID", ctx, mock.Anything).Return(nil, errors.NewNotFoundError("invoice not found"))
+
+	invoice, err := svc.InvoiceAppointments(ctx, covered.ClientID, []*domain.Appointment{covered, &uncovered}, &InvoiceAppointmentsRequest{
+		Notes:      "Copagos",
+		UnitPrices: map[uuid.UUID]money.Money{covered.ID: money.MustParse("15")},
+		Preview:    true,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, invoice.Lines, 2)
+	assert.Equal(t, "15.00", invoice.Lines[0].UnitPrice.Decimal())
+	assert.Equal(t, "50.00", invoice.Lines[1].UnitPrice.Decimal())
+	assert.Equal(t, "Copagos", invoice.Notes)
+}
+
+func TestInvoiceService_InvoiceAppointments_RefusesSessionsAlreadyInvoiced(t *testing.T) {
+	svc, invoiceRepo, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	first, _, _ := newAppointmentInvoiceTest(svc, clientRepo, nil, pricePtr("50"))
+	second := *first
+	second.ID = uuid.New()
+
+	invoiceRepo.On("GetByAppointmentID", ctx, first.ID).Return(nil, errors.NewNotFoundError("invoice not found"))
+	invoiceRepo.On("GetByAppointmentID", ctx, second.ID).Return(&domain.Invoice{InvoiceNumber: "F_2025_0009"}, nil)
+
+	_, err := svc.InvoiceAppointments(ctx, first.ClientID, []*domain.Appointment{first, &second}, &InvoiceAppointmentsRequest{})
+
+	require.Error(t, err)
+	assert.Equal(t, errors.CodeConflict, err.(*errors.AppError).Code)
+	invoiceRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+}
+
+func TestInvoiceService_InvoiceAppointments_RefusesOtherClients(t *testing.T) {
+	svc, _, clientRepo, _ := newInvoiceTestService()
+	ctx := context.Background()
+
+	appointment, _, _ := newAppointmentInvoiceTest(svc, clientRepo, nil, pricePtr("50"))
+
+	_, err := svc.InvoiceAppointments(ctx, uuid.New(), []*domain.Appointment{appointment}, &InvoiceAppointmentsRequest{})
+
+	requireValidationError(t, err)
+}
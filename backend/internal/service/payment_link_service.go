@@ -0,0 +1,322 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/queue"
+	"github.com/google/uuid"
+)
+
+// MaxPaymentWebhookBytes is the maximum size of a webhook callback
+const MaxPaymentWebhookBytes = 256 << 10
+
+// PaymentLinkSettings holds where the client goes after paying and how long a link lasts
+type PaymentLinkSettings struct {
+	SuccessURL string
+	CancelURL  string
+	TTL        time.Duration
+}
+
+// CreatePaymentLinkRequest represents the request to create a "pay now" link for an invoice
+type CreatePaymentLinkRequest struct {
+	Send bool `json:"send"` // Email the link to the client
+}
+
+// paymentLinkEmail is the email sending a link; the signature is the one of the reminders
+var paymentLinkEmail = newDunningEmailTemplate(
+	`Pago online de la factura {{.InvoiceNumber}}`,
+	`Hola, {{.ClientName}}:
+
+Puede pagar con tarjeta la factura {{.InvoiceNumber}} desde el siguiente enlace, válido hasta el {{.ExpiresAt}}:
+
+{{.URL}}
+
+Importe pendiente: {{.Outstanding}}
+
+{{template "signature" .}}
+`)
+
+// paymentLinkEmailData is the data available to the payment link email
+type paymentLinkEmailData struct {
+	ClientName    string
+	InvoiceNumber string
+	Outstanding   string
+	URL           string
+	ExpiresAt     string
+	ClinicName    string
+	ClinicEmail   string
+	ClinicPhone   string
+}
+
+// PaymentLinkService handles the online "pay now" links of invoices
+type PaymentLinkService interface {
+	// CreatePaymentLink creates a checkout at the payment provider for the outstanding
+	// balance of an issued invoice, reusing a link still open for the same amount, and
+	// optionally emails it to the client
+	CreatePaymentLink(ctx context.Context, invoiceID uuid.UUID, req *CreatePaymentLinkRequest, createdBy uuid.UUID) (*domain.PaymentLink, error)
+
+	// ListInvoicePaymentLinks retrieves the links of an invoice, newest first
+	ListInvoicePaymentLinks(ctx context.Context, invoiceID uuid.UUID) ([]*domain.PaymentLink, error)
+
+	// HandleWebhook verifies and applies a provider callback. A paid checkout records a card
+	// payment on the invoice; callbacks are applied once, however often they are retried.
+	HandleWebhook(ctx context.Context, provider string, payload []byte, header http.Header) error
+}
+
+type paymentLinkService struct {
+	linkRepo     repository.PaymentLinkRepository
+	invoiceRepo  repository.InvoiceRepository
+	clientRepo   repository.ClientRepository
+	settingsRepo repository.BillingSettingsRepository
+	provider     PaymentProvider // Nil when online payments are disabled
+	tasks        TaskEnqueuer
+	settings     PaymentLinkSettings
+	now          func() time.Time
+}
+
+// NewPaymentLinkService creates a new payment link service
+func NewPaymentLinkService(
+	linkRepo repository.PaymentLinkRepository,
+	invoiceRepo repository.InvoiceRepository,
+	clientRepo repository.ClientRepository,
+	settingsRepo repository.BillingSettingsRepository,
+	provider PaymentProvider,
+	tasks TaskEnqueuer,
+	settings PaymentLinkSettings,
+) PaymentLinkService {
+	if settings.TTL <= 0 {
+		settings.TTL = 24 * time.Hour
+	}
+	return &paymentLinkService{
+		linkRepo:     linkRepo,
+		invoiceRepo:  invoiceRepo,
+		clientRepo:   clientRepo,
+		settingsRepo: settingsRepo,
+		provider:     provider,
+		tasks:        tasks,
+		settings:     settings,
+		now:          time.Now,
+	}
+}
+
+// CreatePaymentLink creates or reuses the link of the outstanding balance of an invoice
+func (s *paymentLinkService) CreatePaymentLink(ctx context.Context, invoiceID uuid.UUID, req *CreatePaymentLinkRequest, createdBy uuid.UUID) (*domain.PaymentLink, error) {
+	if s.provider == nil {
+		return nil, domain.ErrOnlinePaymentsNotConfigured
+	}
+
+	invoice, err := s.invoiceRepo.GetByID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	outstanding, err := invoice.OutstandingAmount()
+	if err != nil {
+		return nil, err
+	}
+	if err := invoice.CheckPayment(outstanding); err != nil {
+		return nil, err
+	}
+
+	client, err := invoiceClient(ctx, s.clientRepo, invoice)
+	if err != nil {
+		return nil, err
+	}
+	recipient := invoice.Recipient(client)
+	if req.Send && recipient.Email == "" {
+		return nil, errors.NewValidationError("payer has no email address", map[string][]string{
+			"send": {"the link cannot be emailed to the payer of the invoice"},
+		})
+	}
+
+	now := s.now()
+	link, err := s.openLink(ctx, invoice.ID, outstanding, now)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		link = &domain.PaymentLink{
+			ID:        uuid.New(),
+			InvoiceID: invoice.ID,
+			Provider:  s.provider.Name(),
+			Amount:    outstanding,
+			Status:    domain.PaymentLinkOpen,
+			CreatedBy: &createdBy,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+
+		session, err := s.provider.CreateCheckout(ctx, &CheckoutRequest{
+			Reference:     link.ID.String(),
+			Amount:        outstanding,
+			Description:   fmt.Sprintf("Factura %s", invoice.InvoiceNumber),
+			CustomerEmail: recipient.Email,
+			SuccessURL:    s.settings.SuccessURL,
+			CancelURL:     s.settings.CancelURL,
+			ExpiresAt:     now.Add(s.settings.TTL),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create checkout: %w", err)
+		}
+		link.SessionID = session.ID
+		link.URL = session.URL
+		link.ExpiresAt = session.ExpiresAt
+
+		if err := s.linkRepo.Create(ctx, link); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Send {
+		if err := s.sendLink(ctx, link, invoice, recipient); err != nil {
+			return nil, err
+		}
+	}
+
+	return link, nil
+}
+
+// openLink returns a link of the invoice the client can still pay for amount
+func (s *paymentLinkService) openLink(ctx context.Context, invoiceID uuid.UUID, amount money.Money, now time.Time) (*domain.PaymentLink, error) {
+	links, err := s.linkRepo.ListByInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		// Links expiring within the hour are not handed out again
+		if link.Provider == s.provider.Name() && link.IsUsable(now.Add(time.Hour)) && link.Amount.Equal(amount) {
+			return link, nil
+		}
+	}
+	return nil, nil
+}
+
+// sendLink queues the email with the link to the payer of the invoice
+func (s *paymentLinkService) sendLink(ctx context.Context, link *domain.PaymentLink, invoice *domain.Invoice, recipient domain.InvoiceParty) error {
+	settings, err := s.settingsRepo.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	data := paymentLinkEmailData{
+		ClientName:    recipient.Name,
+		InvoiceNumber: invoice.InvoiceNumber,
+		Outstanding:   formatPDFAmount(link.Amount),
+		URL:           link.URL,
+		ExpiresAt:     link.ExpiresAt.Format("02/01/2006 15:04"),
+		ClinicName:    settings.DisplayName(),
+		ClinicEmail:   settings.Email,
+		ClinicPhone:   settings.Phone,
+	}
+	var subject, body bytes.Buffer
+	if err := paymentLinkEmail.subject.Execute(&subject, data); err != nil {
+		return fmt.Errorf("failed to render payment link email: %w", err)
+	}
+	if err := paymentLinkEmail.body.Execute(&body, data); err != nil {
+		return fmt.Errorf("failed to render payment link email: %w", err)
+	}
+
+	err = s.tasks.EnqueueTask(queue.TaskTypeSendEmail, map[string]interface{}{
+		"to":            recipient.Email,
+		"subject":       subject.String(),
+		"body":          body.String(),
+		"invoiceId":     invoice.ID.String(),
+		"paymentLinkId": link.ID.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue payment link email: %w", err)
+	}
+	return nil
+}
+
+// ListInvoicePaymentLinks retrieves the links of an invoice
+func (s *paymentLinkService) ListInvoicePaymentLinks(ctx context.Context, invoiceID uuid.UUID) ([]*domain.PaymentLink, error) {
+	if _, err := s.invoiceRepo.GetByID(ctx, invoiceID); err != nil {
+		return nil, err
+	}
+	return s.linkRepo.ListByInvoice(ctx, invoiceID)
+}
+
+// HandleWebhook applies a verified callback. Events already applied, about links no longer
+// open or about checkouts created elsewhere are acknowledged without changes, so the
+// provider stops retrying them.
+func (s *paymentLinkService) HandleWebhook(ctx context.Context, provider string, payload []byte, header http.Header) error {
+	if s.provider == nil || s.provider.Name() != provider {
+		return errors.NewNotFoundError("payment provider not found")
+	}
+
+	event, err := s.provider.ParseWebhook(payload, header)
+	if err != nil {
+		if err == domain.ErrInvalidWebhookSignature {
+			return err
+		}
+		return errors.NewValidationError("invalid webhook event", map[string][]string{
+			"event": {err.Error()},
+		})
+	}
+	if event == nil {
+		return nil
+	}
+
+	link, err := s.linkRepo.GetBySession(ctx, provider, event.SessionID)
+	if err != nil {
+		if isNotFound(err) {
+			log.Printf("[WARN] Ignoring %s event %s for unknown checkout %s", provider, event.ID, event.SessionID)
+			return nil
+		}
+		return err
+	}
+
+	switch event.Type {
+	case PaymentEventCompleted:
+		err = s.complete(ctx, link, event)
+	case PaymentEventExpired:
+		link.Status = domain.PaymentLinkExpired
+		link.UpdatedAt = s.now()
+		err = s.linkRepo.Expire(ctx, link, event.ID)
+	}
+
+	if err == domain.ErrWebhookEventProcessed || err == domain.ErrPaymentLinkNotOpen {
+		return nil
+	}
+	return err
+}
+
+// complete records the card payment of a paid link. When the invoice no longer has the
+// balance, e.g. it was paid in the clinic meanwhile, the link is closed without a payment
+// and the charge is left to be refunded.
+func (s *paymentLinkService) complete(ctx context.Context, link *domain.PaymentLink, event *PaymentEvent) error {
+	now := s.now()
+	payment := &domain.Payment{
+		ID:          uuid.New(),
+		InvoiceID:   link.InvoiceID,
+		Amount:      event.Amount,
+		PaymentDate: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()),
+		Method:      domain.PaymentMethodCard,
+		Reference:   truncateRunes(event.PaymentReference, 100),
+		Notes:       fmt.Sprintf("Pago online (%s)", link.Provider),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	link.Status = domain.PaymentLinkPaid
+	link.PaymentID = &payment.ID
+	link.PaidAt = &now
+	link.UpdatedAt = now
+
+	_, err := s.linkRepo.Complete(ctx, link, payment, event.ID)
+	if appErr, ok := err.(*errors.AppError); ok && appErr.Code == errors.CodeValidationFailed {
+		log.Printf("[WARN] Payment link %s paid but not applicable to invoice %s, refund it: %v", link.ID, link.InvoiceID, err)
+		link.PaymentID = nil
+		_, err = s.linkRepo.Complete(ctx, link, nil, event.ID)
+	}
+	return err
+}
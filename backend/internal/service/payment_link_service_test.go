@@ -0,0 +1,423 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockPaymentLinkRepository is a mock implementation of repository.PaymentLinkRepository
+type MockPaymentLinkRepository struct {
+	mock.Mock
+}
+
+func (m *MockPaymentLinkRepository) Create(ctx context.Context, link *domain.PaymentLink) error {
+	args := m.Called(ctx, link)
+	return args.Error(0)
+}
+
+func (m *MockPaymentLinkRepository) GetBySession(ctx context.Context, provider, sessionID string) (*domain.PaymentLink, error) {
+	args := m.Called(ctx, provider, sessionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PaymentLink), args.Error(1)
+}
+
+func (m *MockPaymentLinkRepository) ListByInvoice(ctx context.Context, invoiceID uuid.UUID) ([]*domain.PaymentLink, error) {
+	args := m.Called(ctx, invoiceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.PaymentLink), args.Error(1)
+}
+
+func (m *MockPaymentLinkRepository) Complete(ctx context.Context, link *domain.PaymentLink, payment *domain.Payment, eventID string) (*domain.Invoice, error) {
+	args := m.Called(ctx, link, payment, eventID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Invoice), args.Error(1)
+}
+
+func (m *MockPaymentLinkRepository) Expire(ctx context.Context, link *domain.PaymentLink, eventID string) error {
+	args := m.Called(ctx, link, eventID)
+	return args.Error(0)
+}
+
+const (
+	fakeStripeSecretKey     = "sk_test_arnela"
+	fakeStripeWebhookSecret = "whsec_arnela"
+)
+
+// paymentTestNow is the time the tests run at
+var paymentTestNow = time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+
+// fakeStripe is a local stand-in for the Checkout Sessions API
+type fakeStripe struct {
+	mu       sync.Mutex
+	requests []url.Values
+	keys     []string // Idempotency keys
+}
+
+func (f *fakeStripe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/checkout/sessions" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+fakeStripeSecretKey {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":{"message":"Invalid API Key provided"}}`))
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, r.PostForm)
+	f.keys = append(f.keys, r.Header.Get("Idempotency-Key"))
+	n := len(f.requests)
+	f.mu.Unlock()
+
+	expiresAt, _ := strconv.ParseInt(r.PostForm.Get("expires_at"), 10, 64)
+	amount, _ := strconv.ParseInt(r.PostForm.Get("line_items[0][price_data][unit_amount]"), 10, 64)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":             fmt.Sprintf("cs_test_%d", n),
+		"url":            fmt.Sprintf("https://checkout.stripe.test/c/pay/cs_test_%d", n),
+		"expires_at":     expiresAt,
+		"amount_total":   amount,
+		"currency":       "eur",
+		"payment_status": "unpaid",
+	})
+}
+
+func (f *fakeStripe) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+func newTestStripeProvider(t *testing.T, apiURL, secretKey string) *StripeProvider {
+	t.Helper()
+
+	provider, err := NewStripeProvider(StripeConfig{APIURL: apiURL, SecretKey: secretKey, WebhookSecret: fakeStripeWebhookSecret})
+	require.NoError(t, err)
+	provider.now = func() time.Time { return paymentTestNow }
+	return provider
+}
+
+// stripeWebhook builds a checkout event signed as Stripe does at time at
+func stripeWebhook(eventID, eventType, sessionID, paymentStatus string, at time.Time) ([]byte, http.Header) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"id":   eventID,
+		"type": eventType,
+		"data": map[string]interface{}{
+			"object": map[string]interface{}{
+				"id":             sessionID,
+				"amount_total":   36100,
+				"currency":       "eur",
+				"payment_status": paymentStatus,
+				"payment_intent": "pi_3PaidByCard",
+			},
+		},
+	})
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	header := http.Header{}
+	header.Set("Stripe-Signature", fmt.Sprintf("t=%s,v1=%s", timestamp, stripeSignature(fakeStripeWebhookSecret, timestamp, payload)))
+	return payload, header
+}
+
+func TestStripeProvider_CreateCheckout(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeStripe{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	req := &CheckoutRequest{
+		Reference:     "link-1",
+		Amount:        money.MustParse("361.00"),
+		Description:   "Factura F_2025_0010",
+		CustomerEmail: "lucia@example.com",
+		SuccessURL:    "https://clinica.test/pago/completado",
+		CancelURL:     "https://clinica.test/pago/cancelado",
+		ExpiresAt:     paymentTestNow.Add(24 * time.Hour),
+	}
+
+	session, err := newTestStripeProvider(t, server.URL, fakeStripeSecretKey).CreateCheckout(ctx, req)
+
+	require.NoError(t, err)
+	assert.Equal(t, "cs_test_1", session.ID)
+	assert.Equal(t, "https://checkout.stripe.test/c/pay/cs_test_1", session.URL)
+	assert.Equal(t, paymentTestNow.Add(24*time.Hour), session.ExpiresAt)
+
+	form := fake.requests[0]
+	assert.Equal(t, "payment", form.Get("mode"))
+	assert.Equal(t, "36100", form.Get("line_items[0][price_data][unit_amount]"))
+	assert.Equal(t, "eur", form.Get("line_items[0][price_data][currency]"))
+	assert.Equal(t, "Factura F_2025_0010", form.Get("line_items[0][price_data][product_data][name]"))
+	assert.Equal(t, "link-1", form.Get("client_reference_id"))
+	assert.Equal(t, "lucia@example.com", form.Get("customer_email"))
+	assert.Equal(t, []string{"link-1"}, fake.keys)
+
+	_, err = newTestStripeProvider(t, server.URL, "sk_test_wrong").CreateCheckout(ctx, req)
+	assert.ErrorContains(t, err, "Invalid API Key provided")
+}
+
+func TestStripeProvider_ParseWebhook(t *testing.T) {
+	provider := newTestStripeProvider(t, "http://localhost", fakeStripeSecretKey)
+
+	t.Run("Paid checkout", func(t *testing.T) {
+		payload, header := stripeWebhook("evt_1", "checkout.session.completed", "cs_test_1", "paid", paymentTestNow)
+
+		event, err := provider.ParseWebhook(payload, header)
+
+		require.NoError(t, err)
+		assert.Equal(t, &PaymentEvent{
+			ID:               "evt_1",
+			Type:             PaymentEventCompleted,
+			SessionID:        "cs_test_1",
+			Amount:           money.MustParse("361.00"),
+			PaymentReference: "pi_3PaidByCard",
+		}, event)
+	})
+
+	t.Run("Expired checkout", func(t *testing.T) {
+		payload, header := stripeWebhook("evt_2", "checkout.session.expired", "cs_test_1", "unpaid", paymentTestNow)
+
+		event, err := provider.ParseWebhook(payload, header)
+
+		require.NoError(t, err)
+		assert.Equal(t, PaymentEventExpired, event.Type)
+	})
+
+	t.Run("Events not handled", func(t *testing.T) {
+		for _, tc := range []struct{ eventType, status string }{
+			{"checkout.session.completed", "unpaid"}, // Delayed payment method, paid later
+			{"customer.created", ""},
+		} {
+			payload, header := stripeWebhook("evt_3", tc.eventType, "cs_test_1", tc.status, paymentTestNow)
+
+			event, err := provider.ParseWebhook(payload, header)
+
+			require.NoError(t, err)
+			assert.Nil(t, event, tc.eventType)
+		}
+	})
+
+	t.Run("Invalid signatures", func(t *testing.T) {
+		payload, header := stripeWebhook("evt_1", "checkout.session.completed", "cs_test_1", "paid", paymentTestNow)
+		_, oldHeader := stripeWebhook("evt_1", "checkout.session.completed", "cs_test_1", "paid", paymentTestNow.Add(-10*time.Minute))
+		tampered := append([]byte(nil), payload...)
+		tampered[len(tampered)-2] = ' '
+
+		for name, tc := range map[string]struct {
+			payload []byte
+			header  http.Header
+		}{
+			"tampered payload": {tampered, header},
+			"missing header":   {payload, http.Header{}},
+			"replayed":         {payload, oldHeader},
+		} {
+			_, err := provider.ParseWebhook(tc.payload, tc.header)
+			assert.Equal(t, domain.ErrInvalidWebhookSignature, err, name)
+		}
+	})
+}
+
+type paymentLinkTestMocks struct {
+	linkRepo    *MockPaymentLinkRepository
+	invoiceRepo *MockInvoiceRepository
+	clientRepo  *MockClientRepository
+	tasks       *fakeTaskEnqueuer
+	stripe      *fakeStripe
+}
+
+func newPaymentLinkTestService(t *testing.T) (*paymentLinkService, *paymentLinkTestMocks) {
+	fake := &fakeStripe{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	settingsRepo := new(MockBillingSettingsRepository)
+	settingsRepo.On("Get", mock.Anything).Return(pdfTestSettings(), nil)
+	m := &paymentLinkTestMocks{
+		linkRepo:    new(MockPaymentLinkRepository),
+		invoiceRepo: new(MockInvoiceRepository),
+		clientRepo:  new(MockClientRepository),
+		tasks:       &fakeTaskEnqueuer{},
+		stripe:      fake,
+	}
+	svc := NewPaymentLinkService(m.linkRepo, m.invoiceRepo, m.clientRepo, settingsRepo,
+		newTestStripeProvider(t, server.URL, fakeStripeSecretKey), m.tasks, PaymentLinkSettings{
+			SuccessURL: "https://clinica.test/pago/completado",
+			CancelURL:  "https://clinica.test/pago/cancelado",
+			TTL:        24 * time.Hour,
+		}).(*paymentLinkService)
+	svc.now = func() time.Time { return paymentTestNow }
+	return svc, m
+}
+
+func TestPaymentLinkService_CreatePaymentLink(t *testing.T) {
+	ctx := context.Background()
+	createdBy := uuid.New()
+
+	t.Run("Creates a checkout for the outstanding balance and emails it", func(t *testing.T) {
+		svc, m := newPaymentLinkTestService(t)
+		invoice := issuedTestInvoice(t)
+		invoice.PaidAmount = money.MustParse("61.00")
+		client := dunningTestClient(invoice.ClientID)
+		m.invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+		m.clientRepo.On("GetByID", ctx, invoice.ClientID).Return(client, nil)
+		m.linkRepo.On("ListByInvoice", ctx, invoice.ID).Return([]*domain.PaymentLink{}, nil)
+		m.linkRepo.On("Create", ctx, mock.AnythingOfType("*domain.PaymentLink")).Return(nil)
+
+		link, err := svc.CreatePaymentLink(ctx, invoice.ID, &CreatePaymentLinkRequest{Send: true}, createdBy)
+
+		require.NoError(t, err)
+		assert.Equal(t, "stripe", link.Provider)
+		assert.Equal(t, "cs_test_1", link.SessionID)
+		assert.Equal(t, money.MustParse("300.00"), link.Amount)
+		assert.Equal(t, domain.PaymentLinkOpen, link.Status)
+		assert.Equal(t, paymentTestNow.Add(24*time.Hour), link.ExpiresAt)
+		assert.Equal(t, "30000", m.stripe.requests[0].Get("line_items[0][price_data][unit_amount]"))
+		assert.Equal(t, link.ID.String(), m.stripe.requests[0].Get("client_reference_id"))
+
+		require.Len(t, m.tasks.tasks, 1)
+		email := m.tasks.tasks[0]
+		assert.Equal(t, "lucia@example.com", email["to"])
+		assert.Equal(t, "Pago online de la factura F_2025_0010", email["subject"])
+		assert.Contains(t, email["body"], link.URL)
+	})
+
+	t.Run("Reuses the open link of the same amount", func(t *testing.T) {
+		svc, m := newPaymentLinkTestService(t)
+		invoice := issuedTestInvoice(t)
+		open := &domain.PaymentLink{ID: uuid.New(), Provider: "stripe", Amount: invoice.TotalAmount, Status: domain.PaymentLinkOpen, ExpiresAt: paymentTestNow.Add(20 * time.Hour)}
+		stale := &domain.PaymentLink{ID: uuid.New(), Provider: "stripe", Amount: money.MustParse("400.00"), Status: domain.PaymentLinkOpen, ExpiresAt: paymentTestNow.Add(20 * time.Hour)}
+		m.invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+		m.clientRepo.On("GetByID", ctx, invoice.ClientID).Return(dunningTestClient(invoice.ClientID), nil)
+		m.linkRepo.On("ListByInvoice", ctx, invoice.ID).Return([]*domain.PaymentLink{stale, open}, nil)
+
+		link, err := svc.CreatePaymentLink(ctx, invoice.ID, &CreatePaymentLinkRequest{}, createdBy)
+
+		require.NoError(t, err)
+		assert.Equal(t, open, link)
+		assert.Zero(t, m.stripe.count())
+		assert.Empty(t, m.tasks.tasks)
+	})
+
+	t.Run("Invoices that cannot be paid online", func(t *testing.T) {
+		svc, m := newPaymentLinkTestService(t)
+		draft := issuedTestInvoice(t)
+		draft.Status = domain.InvoiceStatusDraft
+		paid := issuedTestInvoice(t)
+		paid.PaidAmount = paid.TotalAmount
+		noEmail := issuedTestInvoice(t)
+		m.invoiceRepo.On("GetByID", ctx, draft.ID).Return(draft, nil)
+		m.invoiceRepo.On("GetByID", ctx, paid.ID).Return(paid, nil)
+		m.invoiceRepo.On("GetByID", ctx, noEmail.ID).Return(noEmail, nil)
+		m.clientRepo.On("GetByID", ctx, noEmail.ClientID).Return(&domain.Client{ID: noEmail.ClientID, FirstName: "Pedro"}, nil)
+
+		for _, invoice := range []*domain.Invoice{draft, paid, noEmail} {
+			_, err := svc.CreatePaymentLink(ctx, invoice.ID, &CreatePaymentLinkRequest{Send: true}, createdBy)
+			requireValidationError(t, err)
+		}
+		assert.Zero(t, m.stripe.count())
+
+		svc.provider = nil
+		_, err := svc.CreatePaymentLink(ctx, draft.ID, &CreatePaymentLinkRequest{}, createdBy)
+		assert.Equal(t, domain.ErrOnlinePaymentsNotConfigured, err)
+	})
+}
+
+func TestPaymentLinkService_HandleWebhook(t *testing.T) {
+	ctx := context.Background()
+	openLink := func() *domain.PaymentLink {
+		return &domain.PaymentLink{ID: uuid.New(), InvoiceID: uuid.New(), Provider: "stripe", SessionID: "cs_test_1", Amount: money.MustParse("361.00"), Status: domain.PaymentLinkOpen}
+	}
+	paid, paidHeader := stripeWebhook("evt_paid", "checkout.session.completed", "cs_test_1", "paid", paymentTestNow)
+
+	t.Run("Records the card payment once", func(t *testing.T) {
+		svc, m := newPaymentLinkTestService(t)
+		link := openLink()
+		var payment *domain.Payment
+		m.linkRepo.On("GetBySession", ctx, "stripe", "cs_test_1").Return(link, nil)
+		m.linkRepo.On("Complete", ctx, link, mock.AnythingOfType("*domain.Payment"), "evt_paid").
+			Run(func(args mock.Arguments) { payment = args.Get(2).(*domain.Payment) }).
+			Return(issuedTestInvoice(t), nil).Once()
+		m.linkRepo.On("Complete", ctx, link, mock.Anything, "evt_paid").Return(nil, domain.ErrWebhookEventProcessed)
+
+		require.NoError(t, svc.HandleWebhook(ctx, "stripe", paid, paidHeader))
+
+		require.NotNil(t, payment)
+		assert.Equal(t, link.InvoiceID, payment.InvoiceID)
+		assert.Equal(t, money.MustParse("361.00"), payment.Amount)
+		assert.Equal(t, domain.PaymentMethodCard, payment.Method)
+		assert.Equal(t, "pi_3PaidByCard", payment.Reference)
+		assert.Equal(t, utcDay(2025, 3, 20), payment.PaymentDate)
+		assert.Equal(t, domain.PaymentLinkPaid, link.Status)
+		assert.Equal(t, &payment.ID, link.PaymentID)
+
+		// The provider retries the callback
+		require.NoError(t, svc.HandleWebhook(ctx, "stripe", paid, paidHeader))
+		m.linkRepo.AssertNumberOfCalls(t, "Complete", 2)
+	})
+
+	t.Run("Closes the link without a payment when the invoice was settled", func(t *testing.T) {
+		svc, m := newPaymentLinkTestService(t)
+		link := openLink()
+		m.linkRepo.On("GetBySession", ctx, "stripe", "cs_test_1").Return(link, nil)
+		m.linkRepo.On("Complete", ctx, link, mock.AnythingOfType("*domain.Payment"), "evt_paid").
+			Return(nil, errors.NewValidationError("invoice has no outstanding balance", nil)).Once()
+		m.linkRepo.On("Complete", ctx, link, (*domain.Payment)(nil), "evt_paid").Return(nil, nil).Once()
+
+		require.NoError(t, svc.HandleWebhook(ctx, "stripe", paid, paidHeader))
+
+		assert.Equal(t, domain.PaymentLinkPaid, link.Status)
+		assert.Nil(t, link.PaymentID)
+		m.linkRepo.AssertExpectations(t)
+	})
+
+	t.Run("Expires the link", func(t *testing.T) {
+		svc, m := newPaymentLinkTestService(t)
+		link := openLink()
+		payload, header := stripeWebhook("evt_expired", "checkout.session.expired", "cs_test_1", "unpaid", paymentTestNow)
+		m.linkRepo.On("GetBySession", ctx, "stripe", "cs_test_1").Return(link, nil)
+		m.linkRepo.On("Expire", ctx, link, "evt_expired").Return(nil)
+
+		require.NoError(t, svc.HandleWebhook(ctx, "stripe", payload, header))
+
+		assert.Equal(t, domain.PaymentLinkExpired, link.Status)
+	})
+
+	t.Run("Rejected and ignored callbacks", func(t *testing.T) {
+		svc, m := newPaymentLinkTestService(t)
+		m.linkRepo.On("GetBySession", ctx, "stripe", "cs_test_1").Return(nil, errors.NewNotFoundError("payment link not found"))
+
+		err := svc.HandleWebhook(ctx, "stripe", paid, http.Header{})
+		assert.Equal(t, domain.ErrInvalidWebhookSignature, err)
+
+		err = svc.HandleWebhook(ctx, "redsys", paid, paidHeader)
+		assert.True(t, isNotFound(err))
+
+		// Checkouts created outside the application are acknowledged
+		require.NoError(t, svc.HandleWebhook(ctx, "stripe", paid, paidHeader))
+		m.linkRepo.AssertNotCalled(t, "Complete", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+)
+
+// CheckoutRequest is a hosted checkout for an amount, paid by card at the provider
+type CheckoutRequest struct {
+	Reference     string // Our identifier of the checkout, used as idempotency key
+	Amount        money.Money
+	Description   string
+	CustomerEmail string
+	SuccessURL    string
+	CancelURL     string
+	ExpiresAt     time.Time
+}
+
+// CheckoutSession is a checkout created at the provider
+type CheckoutSession struct {
+	ID        string
+	URL       string
+	ExpiresAt time.Time
+}
+
+// PaymentEventType is the outcome of a checkout reported by the provider
+type PaymentEventType string
+
+const (
+	PaymentEventCompleted PaymentEventType = "completed" // Paid
+	PaymentEventExpired   PaymentEventType = "expired"   // Expired without being paid
+)
+
+// PaymentEvent is a verified webhook callback about a checkout
+type PaymentEvent struct {
+	ID               string // Unique per event; a retried callback repeats it
+	Type             PaymentEventType
+	SessionID        string
+	Amount           money.Money // Amount paid, for completed checkouts
+	PaymentReference string      // Charge at the provider, for completed checkouts
+}
+
+// PaymentProvider creates hosted card checkouts and verifies the webhook callbacks that
+// report their outcome
+type PaymentProvider interface {
+	// Name identifies the provider in the stored links and the webhook URL
+	Name() string
+
+	// CreateCheckout creates a checkout the client pays at the returned URL
+	CreateCheckout(ctx context.Context, req *CheckoutRequest) (*CheckoutSession, error)
+
+	// ParseWebhook verifies the signature of a callback, failing with
+	// domain.ErrInvalidWebhookSignature, and decodes it. Events about other matters are
+	// returned as nil.
+	ParseWebhook(payload []byte, header http.Header) (*PaymentEvent, error)
+}
+
+// NewPaymentProvider creates the provider selected in the configuration; none when the
+// driver is empty, which disables online payments
+func NewPaymentProvider(driver string, stripe StripeConfig) (PaymentProvider, error) {
+	switch driver {
+	case "":
+		return nil, nil
+	case "stripe":
+		return NewStripeProvider(stripe)
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", driver)
+	}
+}
+
+// StripeConfig holds the keys of a Stripe account
+type StripeConfig struct {
+	APIURL        string // https://api.stripe.com, or a local stand-in
+	SecretKey     string
+	WebhookSecret string
+}
+
+// stripeSignatureTolerance is the maximum age of a signed webhook, against replays
+const stripeSignatureTolerance = 5 * time.Minute
+
+// StripeProvider creates Stripe Checkout sessions through its REST API and verifies the
+// Stripe-Signature header of the webhooks
+type StripeProvider struct {
+	cfg    StripeConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewStripeProvider creates a Stripe provider
+func NewStripeProvider(cfg StripeConfig) (*StripeProvider, error) {
+	if cfg.SecretKey == "" || cfg.WebhookSecret == "" {
+		return nil, fmt.Errorf("stripe requires a secret key and a webhook secret")
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = "https://api.stripe.com"
+	}
+	cfg.APIURL = strings.TrimSuffix(cfg.APIURL, "/")
+
+	return &StripeProvider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+		now:    time.Now,
+	}, nil
+}
+
+// Name identifies the provider
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+// stripeSession is the part of a Checkout Session object used here
+type stripeSession struct {
+	ID                string `json:"id"`
+	URL               string `json:"url"`
+	ExpiresAt         int64  `json:"expires_at"`
+	AmountTotal       int64  `json:"amount_total"`
+	Currency          string `json:"currency"`
+	PaymentStatus     string `json:"payment_status"`
+	PaymentIntent     string `json:"payment_intent"`
+	ClientReferenceID string `json:"client_reference_id"`
+}
+
+// CreateCheckout creates a Checkout Session in payment mode with a single line
+func (p *StripeProvider) CreateCheckout(ctx context.Context, req *CheckoutRequest) (*CheckoutSession, error) {
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("payment_method_types[0]", "card")
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", "eur")
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(req.Amount.Cents(), 10))
+	form.Set("line_items[0][price_data][product_data][name]", req.Description)
+	form.Set("client_reference_id", req.Reference)
+	form.Set("success_url", req.SuccessURL)
+	form.Set("cancel_url", req.CancelURL)
+	form.Set("expires_at", strconv.FormatInt(req.ExpiresAt.Unix(), 10))
+	if req.CustomerEmail != "" {
+		form.Set("customer_email", req.CustomerEmail)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.APIURL+"/v1/checkout/sessions", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkout request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.cfg.SecretKey)
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Idempotency-Key", req.Reference)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach stripe: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stripe response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(body, &failure)
+		return nil, fmt.Errorf("stripe rejected the checkout (%d): %s", resp.StatusCode, failure.Error.Message)
+	}
+
+	var session stripeSession
+	if err := json.Unmarshal(body, &session); err != nil || session.ID == "" || session.URL == "" {
+		return nil, fmt.Errorf("invalid stripe checkout session")
+	}
+
+	return &CheckoutSession{
+		ID:        session.ID,
+		URL:       session.URL,
+		ExpiresAt: time.Unix(session.ExpiresAt, 0).UTC(),
+	}, nil
+}
+
+// ParseWebhook verifies the Stripe-Signature header and decodes the checkout events
+func (p *StripeProvider) ParseWebhook(payload []byte, header http.Header) (*PaymentEvent, error) {
+	if !p.validSignature(payload, header.Get("Stripe-Signature")) {
+		return nil, domain.ErrInvalidWebhookSignature
+	}
+
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object stripeSession `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil || event.ID == "" {
+		return nil, fmt.Errorf("invalid stripe event")
+	}
+
+	session := event.Data.Object
+	result := &PaymentEvent{ID: event.ID, SessionID: session.ID}
+	switch event.Type {
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded":
+		// Delayed payment methods complete the checkout before the money arrives
+		if session.PaymentStatus != "paid" {
+			return nil, nil
+		}
+		if !strings.EqualFold(session.Currency, string(money.EUR)) {
+			return nil, fmt.Errorf("unsupported currency %q", session.Currency)
+		}
+		result.Type = PaymentEventCompleted
+		result.Amount = money.FromCents(session.AmountTotal)
+		result.PaymentReference = session.PaymentIntent
+	case "checkout.session.expired":
+		result.Type = PaymentEventExpired
+	default:
+		return nil, nil
+	}
+
+	return result, nil
+}
+
+// validSignature checks the "t=<timestamp>,v1=<hex HMAC-SHA256 of timestamp.payload>"
+// header against the webhook secret and rejects signatures older than the tolerance
+func (p *StripeProvider) validSignature(payload []byte, header string) bool {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return false
+	}
+	if age := p.now().Sub(time.Unix(seconds, 0)); age > stripeSignatureTolerance || age < -stripeSignatureTolerance {
+		return false
+	}
+
+	expected := stripeSignature(p.cfg.WebhookSecret, timestamp, payload)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripeSignature signs a webhook payload sent at timestamp
+func stripeSignature(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
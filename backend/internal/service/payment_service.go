@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// RecordPaymentRequest represents the request to record a payment of an invoice
+type RecordPaymentRequest struct {
+	Amount      money.Money          `json:"amount"`
+	PaymentDate *time.Time           `json:"paymentDate,omitempty"` // Defaults to today
+	Method      domain.PaymentMethod `json:"method" binding:"required"`
+	Reference   string               `json:"reference,omitempty" binding:"max=100"`
+	Notes       string               `json:"notes,omitempty"`
+}
+
+// SettleInvoiceRequest represents the request to collect the whole outstanding balance of an invoice
+type SettleInvoiceRequest struct {
+	PaymentDate *time.Time           `json:"paymentDate,omitempty"` // Defaults to today
+	Method      domain.PaymentMethod `json:"method,omitempty"`      // Defaults to other
+	Reference   string               `json:"reference,omitempty" binding:"max=100"`
+}
+
+// ReversePaymentRequest represents the request to reverse a payment
+type ReversePaymentRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// InvoicePayment is a payment together with the updated balance of its invoice
+type InvoicePayment struct {
+	Payment *domain.Payment `json:"payment"`
+	Invoice *domain.Invoice `json:"invoice"`
+}
+
+// PaymentService handles the payments collected for invoices
+type PaymentService interface {
+	// RecordPayment records a full or partial payment of an issued invoice
+	RecordPayment(ctx context.Context, invoiceID uuid.UUID, req *RecordPaymentRequest, recordedBy uuid.UUID) (*InvoicePayment, error)
+
+	// SettleInvoice records a payment of the whole outstanding balance of an invoice
+	SettleInvoice(ctx context.Context, invoiceID uuid.UUID, req *SettleInvoiceRequest, recordedBy uuid.UUID) (*domain.Invoice, error)
+
+	// ReversePayment reverses a payment, which stops counting towards the invoice balance
+	ReversePayment(ctx context.Context, paymentID uuid.UUID, req *ReversePaymentRequest, reversedBy uuid.UUID) (*InvoicePayment, error)
+
+	// ListInvoicePayments retrieves the payments of an invoice, including reversed ones
+	ListInvoicePayments(ctx context.Context, invoiceID uuid.UUID) ([]*domain.Payment, error)
+}
+
+type paymentService struct {
+	paymentRepo repository.PaymentRepository
+	invoiceRepo repository.InvoiceRepository
+	now         func() time.Time
+}
+
+// NewPaymentService creates a new payment service
+func NewPaymentService(paymentRepo repository.PaymentRepository, invoiceRepo repository.InvoiceRepository) PaymentService {
+	return &paymentService{
+		paymentRepo: paymentRepo,
+		invoiceRepo: invoiceRepo,
+		now:         time.Now,
+	}
+}
+
+// RecordPayment records a full or partial payment of an issued invoice
+func (s *paymentService) RecordPayment(ctx context.Context, invoiceID uuid.UUID, req *RecordPaymentRequest, recordedBy uuid.UUID) (*InvoicePayment, error) {
+	invoice, err := s.invoiceRepo.GetByID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	payment := &domain.Payment{
+		ID:          uuid.New(),
+		InvoiceID:   invoice.ID,
+		Amount:      req.Amount,
+		PaymentDate: s.today(),
+		Method:      req.Method,
+		Reference:   strings.TrimSpace(req.Reference),
+		Notes:       strings.TrimSpace(req.Notes),
+		RecordedBy:  &recordedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if req.PaymentDate != nil {
+		payment.PaymentDate = *req.PaymentDate
+	}
+
+	return s.record(ctx, invoice, payment)
+}
+
+// SettleInvoice records a payment of the whole outstanding balance of an invoice
+func (s *paymentService) SettleInvoice(ctx context.Context, invoiceID uuid.UUID, req *SettleInvoiceRequest, recordedBy uuid.UUID) (*domain.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetByID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	outstanding, err := invoice.OutstandingAmount()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	payment := &domain.Payment{
+		ID:          uuid.New(),
+		InvoiceID:   invoice.ID,
+		Amount:      outstanding,
+		PaymentDate: s.today(),
+		Method:      req.Method,
+		Reference:   strings.TrimSpace(req.Reference),
+		RecordedBy:  &recordedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if payment.Method == "" {
+		payment.Method = domain.PaymentMethodOther
+	}
+	if req.PaymentDate != nil {
+		payment.PaymentDate = *req.PaymentDate
+	}
+
+	result, err := s.record(ctx, invoice, payment)
+	if err != nil {
+		return nil, err
+	}
+	return result.Invoice, nil
+}
+
+// record validates a payment against its invoice and stores it
+func (s *paymentService) record(ctx context.Context, invoice *domain.Invoice, payment *domain.Payment) (*InvoicePayment, error) {
+	if err := invoice.CheckPayment(payment.Amount); err != nil {
+		return nil, err
+	}
+	if err := payment.Validate(); err != nil {
+		return nil, err
+	}
+
+	if payment.PaymentDate.After(s.now()) {
+		return nil, errors.NewValidationError("payment date cannot be in the future", map[string][]string{
+			"paymentDate": {"payment date must be today or earlier"},
+		})
+	}
+	if payment.PaymentDate.Before(invoice.IssueDate) {
+		return nil, errors.NewValidationError("payment date is before the invoice was issued", map[string][]string{
+			"paymentDate": {fmt.Sprintf("invoice %s was issued on %s", invoice.InvoiceNumber, invoice.IssueDate.Format("02/01/2006"))},
+		})
+	}
+
+	// The repository checks the balance again while the invoice is locked
+	updated, err := s.paymentRepo.Create(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+	updated.Lines = invoice.Lines
+
+	return &InvoicePayment{Payment: payment, Invoice: updated}, nil
+}
+
+// ReversePayment reverses a payment, which stops counting towards the invoice balance
+func (s *paymentService) ReversePayment(ctx context.Context, paymentID uuid.UUID, req *ReversePaymentRequest, reversedBy uuid.UUID) (*InvoicePayment, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.IsReversed() {
+		return nil, domain.ErrPaymentAlreadyReversed
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errors.NewValidationError("reversal reason is required", map[string][]string{
+			"reason": {"explain why the payment is reversed"},
+		})
+	}
+
+	now := s.now()
+	payment.ReversedAt = &now
+	payment.ReversedBy = &reversedBy
+	payment.ReversalReason = reason
+	payment.UpdatedAt = now
+
+	invoice, err := s.paymentRepo.Reverse(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InvoicePayment{Payment: payment, Invoice: invoice}, nil
+}
+
+// ListInvoicePayments retrieves the payments of an invoice, including reversed ones
+func (s *paymentService) ListInvoicePayments(ctx context.Context, invoiceID uuid.UUID) ([]*domain.Payment, error) {
+	if _, err := s.invoiceRepo.GetByID(ctx, invoiceID); err != nil {
+		return nil, err
+	}
+
+	return s.paymentRepo.ListByInvoice(ctx, invoiceID)
+}
+
+// today returns the current date at midnight
+func (s *paymentService) today() time.Time {
+	now := s.now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
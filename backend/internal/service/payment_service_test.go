@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockPaymentRepository is a mock implementation of PaymentRepository
+type MockPaymentRepository struct {
+	mock.Mock
+}
+
+func (m *MockPaymentRepository) Create(ctx context.Context, payment *domain.Payment) (*domain.Invoice, error) {
+	args := m.Called(ctx, payment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Invoice), args.Error(1)
+}
+
+func (m *MockPaymentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Payment, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepository) ListByInvoice(ctx context.Context, invoiceID uuid.UUID) ([]*domain.Payment, error) {
+	args := m.Called(ctx, invoiceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepository) Reverse(ctx context.Context, payment *domain.Payment) (*domain.Invoice, error) {
+	args := m.Called(ctx, payment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Invoice), args.Error(1)
+}
+
+func newPaymentTestService() (*paymentService, *MockPaymentRepository, *MockInvoiceRepository) {
+	paymentRepo := new(MockPaymentRepository)
+	invoiceRepo := new(MockInvoiceRepository)
+
+	svc := NewPaymentService(paymentRepo, invoiceRepo).(*paymentService)
+	svc.now = func() time.Time { return time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC) }
+	return svc, paymentRepo, invoiceRepo
+}
+
+// applyPayment mimics the repository: the invoice balance and status follow the payment
+func applyPayment(invoice *domain.Invoice) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		payment := args.Get(1).(*domain.Payment)
+		paid, err := invoice.PaidAmount.Add(payment.Amount)
+		if err != nil {
+			panic(err)
+		}
+		if err := invoice.ApplyPaidAmount(paid); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func TestPaymentService_RecordPayment(t *testing.T) {
+	ctx := context.Background()
+	recordedBy := uuid.New()
+
+	t.Run("partial payment leaves the invoice partially paid", func(t *testing.T) {
+		svc, paymentRepo, invoiceRepo := newPaymentTestService()
+		invoice := issuedTestInvoice(t) // 361.00
+		invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+		paymentRepo.On("Create", ctx, mock.AnythingOfType("*domain.Payment")).Run(applyPayment(invoice)).Return(invoice, nil)
+
+		result, err := svc.RecordPayment(ctx, invoice.ID, &RecordPaymentRequest{
+			Amount:    money.MustParse("30"),
+			Method:    domain.PaymentMethodCard,
+			Reference: "  TPV 0042 ",
+		}, recordedBy)
+
+		require.NoError(t, err)
+		assert.Equal(t, money.MustParse("30"), result.Payment.Amount)
+		assert.Equal(t, "TPV 0042", result.Payment.Reference)
+		assert.Equal(t, &recordedBy, result.Payment.RecordedBy)
+		assert.Equal(t, time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC), result.Payment.PaymentDate, "defaults to today")
+		assert.Equal(t, domain.InvoiceStatusPartiallyPaid, result.Invoice.Status)
+
+		outstanding, err := result.Invoice.OutstandingAmount()
+		require.NoError(t, err)
+		assert.Equal(t, money.MustParse("331"), outstanding)
+	})
+
+	t.Run("paying the balance settles the invoice", func(t *testing.T) {
+		svc, paymentRepo, invoiceRepo := newPaymentTestService()
+		invoice := issuedTestInvoice(t)
+		require.NoError(t, invoice.ApplyPaidAmount(money.MustParse("30")))
+		invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+		paymentRepo.On("Create", ctx, mock.AnythingOfType("*domain.Payment")).Run(applyPayment(invoice)).Return(invoice, nil)
+
+		result, err := svc.RecordPayment(ctx, invoice.ID, &RecordPaymentRequest{
+			Amount: money.MustParse("331"),
+			Method: domain.PaymentMethodTransfer,
+		}, recordedBy)
+
+		require.NoError(t, err)
+		assert.True(t, result.Invoice.IsPaid())
+	})
+
+	t.Run("refuses amounts above the outstanding balance", func(t *testing.T) {
+		svc, paymentRepo, invoiceRepo := newPaymentTestService()
+		invoice := issuedTestInvoice(t)
+		require.NoError(t, invoice.ApplyPaidAmount(money.MustParse("30")))
+		invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+
+		_, err := svc.RecordPayment(ctx, invoice.ID, &RecordPaymentRequest{
+			Amount: money.MustParse("331.01"),
+			Method: domain.PaymentMethodCash,
+		}, recordedBy)
+
+		requireValidationError(t, err)
+		assert.Contains(t, err.Error(), "outstanding balance")
+		paymentRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("refuses drafts, settled invoices and invalid payments", func(t *testing.T) {
+		draft := issuedTestInvoice(t)
+		draft.Status = domain.InvoiceStatusDraft
+
+		settled := issuedTestInvoice(t)
+		require.NoError(t, settled.ApplyPaidAmount(settled.TotalAmount))
+
+		future := time.Date(2025, 3, 21, 0, 0, 0, 0, time.UTC)
+		beforeIssue := time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)
+
+		cases := []struct {
+			name    string
+			invoice *domain.Invoice
+			req     RecordPaymentRequest
+		}{
+			{"draft", draft, RecordPaymentRequest{Amount: money.MustParse("10"), Method: domain.PaymentMethodCash}},
+			{"settled", settled, RecordPaymentRequest{Amount: money.MustParse("10"), Method: domain.PaymentMethodCash}},
+			{"zero amount", issuedTestInvoice(t), RecordPaymentRequest{Method: domain.PaymentMethodCash}},
+			{"negative amount", issuedTestInvoice(t), RecordPaymentRequest{Amount: money.MustParse("-10"), Method: domain.PaymentMethodCash}},
+			{"unknown method", issuedTestInvoice(t), RecordPaymentRequest{Amount: money.MustParse("10"), Method: "cheque"}},
+			{"future date", issuedTestInvoice(t), RecordPaymentRequest{Amount: money.MustParse("10"), Method: domain.PaymentMethodCash, PaymentDate: &future}},
+			{"before issue", issuedTestInvoice(t), RecordPaymentRequest{Amount: money.MustParse("10"), Method: domain.PaymentMethodCash, PaymentDate: &beforeIssue}},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				svc, paymentRepo, invoiceRepo := newPaymentTestService()
+				invoiceRepo.On("GetByID", ctx, tc.invoice.ID).Return(tc.invoice, nil)
+
+				_, err := svc.RecordPayment(ctx, tc.invoice.ID, &tc.req, recordedBy)
+
+				requireValidationError(t, err)
+				paymentRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+			})
+		}
+	})
+}
+
+func TestPaymentService_SettleInvoice(t *testing.T) {
+	ctx := context.Background()
+	svc, paymentRepo, invoiceRepo := newPaymentTestService()
+
+	invoice := issuedTestInvoice(t)
+	require.NoError(t, invoice.ApplyPaidAmount(money.MustParse("50")))
+	invoiceRepo.On("GetByID", ctx, invoice.ID).Return(invoice, nil)
+	paymentRepo.On("Create", ctx, mock.MatchedBy(func(payment *domain.Payment) bool {
+		return payment.Amount.Equal(money.MustParse("311")) && payment.Method == domain.PaymentMethodOther
+	})).Run(applyPayment(invoice)).Return(invoice, nil)
+
+	paid, err := svc.SettleInvoice(ctx, invoice.ID, &SettleInvoiceRequest{}, uuid.New())
+
+	require.NoError(t, err)
+	assert.True(t, paid.IsPaid())
+	assert.Equal(t, invoice.TotalAmount, paid.PaidAmount)
+
+	t.Run("an invoice cannot be settled twice", func(t *testing.T) {
+		_, err := svc.SettleInvoice(ctx, invoice.ID, &SettleInvoiceRequest{}, uuid.New())
+		requireValidationError(t, err)
+	})
+}
+
+func TestPaymentService_ReversePayment(t *testing.T) {
+	ctx := context.Background()
+	reversedBy := uuid.New()
+
+	t.Run("reversal reopens the balance", func(t *testing.T) {
+		svc, paymentRepo, _ := newPaymentTestService()
+		invoice := issuedTestInvoice(t)
+		payment := &domain.Payment{ID: uuid.New(), InvoiceID: invoice.ID, Amount: invoice.TotalAmount, Method: domain.PaymentMethodCard}
+		paymentRepo.On("GetByID", ctx, payment.ID).Return(payment, nil)
+		paymentRepo.On("Reverse", ctx, payment).Return(invoice, nil)
+
+		result, err := svc.ReversePayment(ctx, payment.ID, &ReversePaymentRequest{Reason: " Cobro duplicado "}, reversedBy)
+
+		require.NoError(t, err)
+		assert.True(t, result.Payment.IsReversed())
+		assert.Equal(t, &reversedBy, result.Payment.ReversedBy)
+		assert.Equal(t, "Cobro duplicado", result.Payment.ReversalReason)
+		assert.Equal(t, invoice, result.Invoice)
+	})
+
+	t.Run("a payment cannot be reversed twice", func(t *testing.T) {
+		svc, paymentRepo, _ := newPaymentTestService()
+		reversedAt := time.Now()
+		payment := &domain.Payment{ID: uuid.New(), InvoiceID: uuid.New(), ReversedAt: &reversedAt}
+		paymentRepo.On("GetByID", ctx, payment.ID).Return(payment, nil)
+
+		_, err := svc.ReversePayment(ctx, payment.ID, &ReversePaymentRequest{Reason: "Otra vez"}, reversedBy)
+
+		assert.Equal(t, domain.ErrPaymentAlreadyReversed, err)
+		paymentRepo.AssertNotCalled(t, "Reverse", mock.Anything, mock.Anything)
+	})
+
+	t.Run("a reason is required", func(t *testing.T) {
+		svc, paymentRepo, _ := newPaymentTestService()
+		payment := &domain.Payment{ID: uuid.New(), InvoiceID: uuid.New()}
+		paymentRepo.On("GetByID", ctx, payment.ID).Return(payment, nil)
+
+		_, err := svc.ReversePayment(ctx, payment.ID, &ReversePaymentRequest{Reason: "   "}, reversedBy)
+
+		requireValidationError(t, err)
+	})
+}
+
+func TestInvoice_ApplyPaidAmount(t *testing.T) {
+	cases := []struct {
+		paid   string
+		status domain.InvoiceStatus
+	}{
+		{"0", domain.InvoiceStatusUnpaid},
+		{"0.01", domain.InvoiceStatusPartiallyPaid},
+		{"360.99", domain.InvoiceStatusPartiallyPaid},
+		{"361", domain.InvoiceStatusPaid},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.paid, func(t *testing.T) {
+			invoice := issuedTestInvoice(t)
+			require.NoError(t, invoice.ApplyPaidAmount(money.MustParse(tc.paid)))
+			assert.Equal(t, tc.status, invoice.Status)
+		})
+	}
+
+	t.Run("drafts keep their status", func(t *testing.T) {
+		invoice := issuedTestInvoice(t)
+		invoice.Status = domain.InvoiceStatusDraft
+		require.NoError(t, invoice.ApplyPaidAmount(money.Money{}))
+		assert.True(t, invoice.IsDraft())
+	})
+}
@@ -0,0 +1,434 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// maxOccurrenceMonths limits the months listed in one request
+const maxOccurrenceMonths = 24
+
+// CreateRecurringExpenseRequest represents the request to create a recurring expense
+type CreateRecurringExpenseRequest struct {
+	Supplier       string      `json:"supplier" binding:"required"`
+	Amount         money.Money `json:"amount"`    // Must be greater than 0
+	VATAmount      money.Money `json:"vatAmount"` // VAT included in the amount
+	VATDeductible  bool        `json:"vatDeductible"`
+	IRPFDeductible *bool       `json:"irpfDeductible,omitempty"` // Defaults to true
+	CategoryID     uuid.UUID   `json:"categoryId" binding:"required"`
+	SubcategoryID  *uuid.UUID  `json:"subcategoryId,omitempty"`
+	DayOfMonth     int         `json:"dayOfMonth" binding:"required"` // 1-31; the last day of shorter months
+	StartDate      time.Time   `json:"startDate" binding:"required"`
+	EndDate        *time.Time  `json:"endDate,omitempty"`
+	Description    *string     `json:"description,omitempty"`
+	PaymentMethod  *string     `json:"paymentMethod,omitempty"`
+	Notes          *string     `json:"notes,omitempty"`
+}
+
+// UpdateRecurringExpenseRequest represents the request to update a recurring expense; the
+// expenses already generated are not changed
+type UpdateRecurringExpenseRequest struct {
+	Supplier       string      `json:"supplier" binding:"required"`
+	Amount         money.Money `json:"amount"`
+	VATAmount      money.Money `json:"vatAmount"`
+	VATDeductible  bool        `json:"vatDeductible"`
+	IRPFDeductible *bool       `json:"irpfDeductible,omitempty"` // Unchanged when not given
+	CategoryID     uuid.UUID   `json:"categoryId" binding:"required"`
+	SubcategoryID  *uuid.UUID  `json:"subcategoryId,omitempty"`
+	DayOfMonth     int         `json:"dayOfMonth" binding:"required"`
+	StartDate      time.Time   `json:"startDate" binding:"required"`
+	EndDate        *time.Time  `json:"endDate,omitempty"`
+	Description    *string     `json:"description,omitempty"`
+	PaymentMethod  *string     `json:"paymentMethod,omitempty"`
+	Notes          *string     `json:"notes,omitempty"`
+	IsActive       *bool       `json:"isActive,omitempty"` // Unchanged when not given
+}
+
+// AdjustOccurrenceRequest skips one month of a recurring expense or changes what is
+// generated for it, without changing the template
+type AdjustOccurrenceRequest struct {
+	Skip        bool         `json:"skip"`
+	ExpenseDate *time.Time   `json:"expenseDate,omitempty"` // Within the month
+	Amount      *money.Money `json:"amount,omitempty"`
+	VATAmount   *money.Money `json:"vatAmount,omitempty"`
+	Notes       *string      `json:"notes,omitempty"`
+}
+
+// RecurringExpenseRun summarises a run of the recurring expense job
+type RecurringExpenseRun struct {
+	Generated int       `json:"generated"`
+	Failed    int       `json:"failed"` // Left for the next run
+	RunAt     time.Time `json:"runAt"`
+}
+
+// RecurringExpenseService manages recurring expenses and generates their expenses when due
+type RecurringExpenseService interface {
+	// CreateRecurringExpense creates a new recurring expense
+	CreateRecurringExpense(ctx context.Context, req *CreateRecurringExpenseRequest) (*domain.RecurringExpense, error)
+
+	// GetRecurringExpense retrieves a recurring expense by ID
+	GetRecurringExpense(ctx context.Context, id uuid.UUID) (*domain.RecurringExpense, error)
+
+	// ListRecurringExpenses retrieves recurring expenses, optionally only active ones
+	ListRecurringExpenses(ctx context.Context, activeOnly bool) ([]*domain.RecurringExpense, error)
+
+	// UpdateRecurringExpense updates a recurring expense; later months follow the changes
+	UpdateRecurringExpense(ctx context.Context, id uuid.UUID, req *UpdateRecurringExpenseRequest) (*domain.RecurringExpense, error)
+
+	// DeleteRecurringExpense soft deletes a recurring expense; its expenses are kept
+	DeleteRecurringExpense(ctx context.Context, id uuid.UUID) error
+
+	// ListOccurrences retrieves the months of a recurring expense between two periods
+	// (YYYY-MM, inclusive); months following the template are listed as due. Defaults to
+	// the current month and the five following.
+	ListOccurrences(ctx context.Context, id uuid.UUID, from, to string) ([]*domain.RecurringExpenseOccurrence, error)
+
+	// AdjustOccurrence skips or adjusts the month of period (YYYY-MM) before it is generated
+	AdjustOccurrence(ctx context.Context, id uuid.UUID, period string, req *AdjustOccurrenceRequest) (*domain.RecurringExpenseOccurrence, error)
+
+	// ResetOccurrence undoes the skip or adjustment of the month of period (YYYY-MM)
+	ResetOccurrence(ctx context.Context, id uuid.UUID, period string) error
+
+	// GenerateDue creates the expenses of the active recurring expenses due up to today,
+	// flagged for review. Months already generated or skipped are left alone, so running
+	// it again is harmless and catches up on missed runs.
+	GenerateDue(ctx context.Context) (*RecurringExpenseRun, error)
+}
+
+type recurringExpenseService struct {
+	recurringRepo repository.RecurringExpenseRepository
+	categoryRepo  repository.ExpenseCategoryRepository
+	now           func() time.Time
+}
+
+// NewRecurringExpenseService creates a new recurring expense service
+func NewRecurringExpenseService(recurringRepo repository.RecurringExpenseRepository, categoryRepo repository.ExpenseCategoryRepository) RecurringExpenseService {
+	return &recurringExpenseService{
+		recurringRepo: recurringRepo,
+		categoryRepo:  categoryRepo,
+		now:           time.Now,
+	}
+}
+
+// CreateRecurringExpense creates a new recurring expense
+func (s *recurringExpenseService) CreateRecurringExpense(ctx context.Context, req *CreateRecurringExpenseRequest) (*domain.RecurringExpense, error) {
+	if err := validateExpenseCategories(ctx, s.categoryRepo, req.CategoryID, req.SubcategoryID); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	recurring := &domain.RecurringExpense{
+		ID:             uuid.New(),
+		Supplier:       strings.TrimSpace(req.Supplier),
+		Amount:         req.Amount,
+		VATAmount:      req.VATAmount,
+		VATDeductible:  req.VATDeductible,
+		IRPFDeductible: req.IRPFDeductible == nil || *req.IRPFDeductible,
+		CategoryID:     req.CategoryID,
+		SubcategoryID:  req.SubcategoryID,
+		DayOfMonth:     req.DayOfMonth,
+		StartDate:      req.StartDate,
+		EndDate:        req.EndDate,
+		Description:    req.Description,
+		PaymentMethod:  req.PaymentMethod,
+		Notes:          req.Notes,
+		IsActive:       true,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	if err := recurring.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.recurringRepo.Create(ctx, recurring); err != nil {
+		return nil, err
+	}
+
+	return recurring, nil
+}
+
+// GetRecurringExpense retrieves a recurring expense by ID
+func (s *recurringExpenseService) GetRecurringExpense(ctx context.Context, id uuid.UUID) (*domain.RecurringExpense, error) {
+	return s.recurringRepo.GetByID(ctx, id)
+}
+
+// ListRecurringExpenses retrieves recurring expenses, optionally only active ones
+func (s *recurringExpenseService) ListRecurringExpenses(ctx context.Context, activeOnly bool) ([]*domain.RecurringExpense, error) {
+	return s.recurringRepo.List(ctx, activeOnly)
+}
+
+// UpdateRecurringExpense updates a recurring expense
+func (s *recurringExpenseService) UpdateRecurringExpense(ctx context.Context, id uuid.UUID, req *UpdateRecurringExpenseRequest) (*domain.RecurringExpense, error) {
+	recurring, err := s.recurringRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateExpenseCategories(ctx, s.categoryRepo, req.CategoryID, req.SubcategoryID); err != nil {
+		return nil, err
+	}
+
+	recurring.Supplier = strings.TrimSpace(req.Supplier)
+	recurring.Amount = req.Amount
+	recurring.VATAmount = req.VATAmount
+	recurring.VATDeductible = req.VATDeductible
+	if req.IRPFDeductible != nil {
+		recurring.IRPFDeductible = *req.IRPFDeductible
+	}
+	recurring.CategoryID = req.CategoryID
+	recurring.SubcategoryID = req.SubcategoryID
+	recurring.DayOfMonth = req.DayOfMonth
+	recurring.StartDate = req.StartDate
+	recurring.EndDate = req.EndDate
+	recurring.Description = req.Description
+	recurring.PaymentMethod = req.PaymentMethod
+	recurring.Notes = req.Notes
+	if req.IsActive != nil {
+		recurring.IsActive = *req.IsActive
+	}
+	recurring.UpdatedAt = s.now()
+
+	if err := recurring.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.recurringRepo.Update(ctx, recurring); err != nil {
+		return nil, err
+	}
+
+	return recurring, nil
+}
+
+// DeleteRecurringExpense soft deletes a recurring expense
+func (s *recurringExpenseService) DeleteRecurringExpense(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.recurringRepo.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	return s.recurringRepo.Delete(ctx, id)
+}
+
+// ListOccurrences retrieves the months of a recurring expense between two periods
+func (s *recurringExpenseService) ListOccurrences(ctx context.Context, id uuid.UUID, from, to string) ([]*domain.RecurringExpenseOccurrence, error) {
+	recurring, err := s.recurringRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	fromPeriod := domain.PeriodOf(s.now())
+	if from != "" {
+		if fromPeriod, err = parseOccurrencePeriod(from); err != nil {
+			return nil, err
+		}
+	}
+	toPeriod := fromPeriod.AddDate(0, 5, 0)
+	if to != "" {
+		if toPeriod, err = parseOccurrencePeriod(to); err != nil {
+			return nil, err
+		}
+	}
+	if toPeriod.Before(fromPeriod) || toPeriod.After(fromPeriod.AddDate(0, maxOccurrenceMonths-1, 0)) {
+		return nil, errors.NewValidationError("invalid period range", map[string][]string{
+			"to": {"must be after from and within 24 months"},
+		})
+	}
+
+	stored, err := s.recurringRepo.ListOccurrences(ctx, recurring.ID, fromPeriod, toPeriod)
+	if err != nil {
+		return nil, err
+	}
+	byPeriod := make(map[string]*domain.RecurringExpenseOccurrence, len(stored))
+	for _, occurrence := range stored {
+		byPeriod[occurrence.Period.Format(InvoiceBatchPeriodLayout)] = occurrence
+	}
+
+	occurrences := []*domain.RecurringExpenseOccurrence{}
+	for period := fromPeriod; !period.After(toPeriod); period = period.AddDate(0, 1, 0) {
+		if occurrence, ok := byPeriod[period.Format(InvoiceBatchPeriodLayout)]; ok {
+			occurrences = append(occurrences, occurrence)
+			continue
+		}
+		if recurring.OccursIn(period) {
+			due := recurring.DueDate(period)
+			occurrences = append(occurrences, &domain.RecurringExpenseOccurrence{
+				RecurringExpenseID: recurring.ID,
+				Period:             period,
+				Status:             domain.OccurrenceStatusDue,
+				ExpenseDate:        &due,
+			})
+		}
+	}
+
+	return occurrences, nil
+}
+
+// AdjustOccurrence skips or adjusts the month of period before it is generated
+func (s *recurringExpenseService) AdjustOccurrence(ctx context.Context, id uuid.UUID, period string, req *AdjustOccurrenceRequest) (*domain.RecurringExpenseOccurrence, error) {
+	recurring, err := s.recurringRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	month, err := parseOccurrencePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	if !recurring.OccursIn(month) {
+		return nil, domain.ErrNoOccurrenceInPeriod
+	}
+
+	now := s.now()
+	occurrence := &domain.RecurringExpenseOccurrence{
+		ID:                 uuid.New(),
+		RecurringExpenseID: recurring.ID,
+		Period:             month,
+		Status:             domain.OccurrenceStatusSkipped,
+		Notes:              req.Notes,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+
+	if !req.Skip {
+		if req.ExpenseDate == nil && req.Amount == nil && req.VATAmount == nil && req.Notes == nil {
+			return nil, errors.NewValidationError("nothing to adjust", map[string][]string{
+				"skip": {"skip the month or give the date, amounts or notes to use"},
+			})
+		}
+		occurrence.Status = domain.OccurrenceStatusAdjusted
+		occurrence.ExpenseDate = req.ExpenseDate
+		occurrence.Amount = req.Amount
+		occurrence.VATAmount = req.VATAmount
+
+		if err := occurrence.Validate(recurring); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.recurringRepo.SaveOccurrence(ctx, occurrence); err != nil {
+		return nil, err
+	}
+
+	return occurrence, nil
+}
+
+// ResetOccurrence undoes the skip or adjustment of the month of period
+func (s *recurringExpenseService) ResetOccurrence(ctx context.Context, id uuid.UUID, period string) error {
+	if _, err := s.recurringRepo.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	month, err := parseOccurrencePeriod(period)
+	if err != nil {
+		return err
+	}
+
+	return s.recurringRepo.DeleteOccurrence(ctx, id, month)
+}
+
+// GenerateDue creates the expenses of the active recurring expenses due up to today
+func (s *recurringExpenseService) GenerateDue(ctx context.Context) (*RecurringExpenseRun, error) {
+	now := s.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	run := &RecurringExpenseRun{RunAt: now}
+
+	templates, err := s.recurringRepo.List(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, recurring := range templates {
+		if recurring.StartDate.After(today) {
+			continue
+		}
+
+		generated, failed, err := s.generate(ctx, recurring, today, now)
+		if err != nil {
+			log.Printf("[ERROR] Failed to generate recurring expense %s (%s): %v", recurring.ID, recurring.Supplier, err)
+			run.Failed++
+			continue
+		}
+		run.Generated += generated
+		run.Failed += failed
+	}
+
+	return run, nil
+}
+
+// generate creates the expenses of a recurring expense due up to today that were neither
+// generated nor skipped
+func (s *recurringExpenseService) generate(ctx context.Context, recurring *domain.RecurringExpense, today, now time.Time) (int, int, error) {
+	from := domain.PeriodOf(recurring.StartDate)
+	to := domain.PeriodOf(today)
+	if recurring.EndDate != nil && domain.PeriodOf(*recurring.EndDate).Before(to) {
+		to = domain.PeriodOf(*recurring.EndDate)
+	}
+
+	stored, err := s.recurringRepo.ListOccurrences(ctx, recurring.ID, from, to)
+	if err != nil {
+		return 0, 0, err
+	}
+	byPeriod := make(map[string]*domain.RecurringExpenseOccurrence, len(stored))
+	for _, occurrence := range stored {
+		byPeriod[occurrence.Period.Format(InvoiceBatchPeriodLayout)] = occurrence
+	}
+
+	generated, failed := 0, 0
+	for period := from; !period.After(to); period = period.AddDate(0, 1, 0) {
+		if !recurring.OccursIn(period) {
+			continue
+		}
+
+		occurrence := byPeriod[period.Format(InvoiceBatchPeriodLayout)]
+		if occurrence != nil && occurrence.Status != domain.OccurrenceStatusAdjusted {
+			continue
+		}
+
+		// Adjusted dates later in the month wait for their day
+		expense := recurring.Materialise(period, occurrence, now)
+		if expense.ExpenseDate.After(today) {
+			continue
+		}
+
+		if occurrence == nil {
+			occurrence = &domain.RecurringExpenseOccurrence{
+				ID:                 uuid.New(),
+				RecurringExpenseID: recurring.ID,
+				Period:             period,
+				CreatedAt:          now,
+			}
+		}
+		occurrence.UpdatedAt = now
+
+		switch err := s.recurringRepo.Generate(ctx, occurrence, expense); {
+		case err == domain.ErrOccurrenceGenerated:
+			// Generated or skipped concurrently
+		case err != nil:
+			log.Printf("[ERROR] Failed to generate %s of recurring expense %s: %v", period.Format(InvoiceBatchPeriodLayout), recurring.ID, err)
+			failed++
+		default:
+			generated++
+		}
+	}
+
+	return generated, failed, nil
+}
+
+// parseOccurrencePeriod parses a month as YYYY-MM
+func parseOccurrencePeriod(period string) (time.Time, error) {
+	month, err := time.Parse(InvoiceBatchPeriodLayout, period)
+	if err != nil {
+		return time.Time{}, errors.NewValidationError("invalid period", map[string][]string{
+			"period": {"must be a month as YYYY-MM"},
+		})
+	}
+	return month, nil
+}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockRecurringExpenseRepository is a mock implementation of RecurringExpenseRepository
+type MockRecurringExpenseRepository struct {
+	mock.Mock
+}
+
+func (m *MockRecurringExpenseRepository) Create(ctx context.Context, recurring *domain.RecurringExpense) error {
+	args := m.Called(ctx, recurring)
+	return args.Error(0)
+}
+
+func (m *MockRecurringExpenseRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.RecurringExpense, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.RecurringExpense), args.Error(1)
+}
+
+func (m *MockRecurringExpenseRepository) List(ctx context.Context, activeOnly bool) ([]*domain.RecurringExpense, error) {
+	args := m.Called(ctx, activeOnly)
+	return args.Get(0).([]*domain.RecurringExpense), args.Error(1)
+}
+
+func (m *MockRecurringExpenseRepository) Update(ctx context.Context, recurring *domain.RecurringExpense) error {
+	args := m.Called(ctx, recurring)
+	return args.Error(0)
+}
+
+func (m *MockRecurringExpenseRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRecurringExpenseRepository) ListOccurrences(ctx context.Context, recurringID uuid.UUID, from, to time.Time) ([]*domain.RecurringExpenseOccurrence, error) {
+	args := m.Called(ctx, recurringID, from, to)
+	return args.Get(0).([]*domain.RecurringExpenseOccurrence), args.Error(1)
+}
+
+func (m *MockRecurringExpenseRepository) SaveOccurrence(ctx context.Context, occurrence *domain.RecurringExpenseOccurrence) error {
+	args := m.Called(ctx, occurrence)
+	return args.Error(0)
+}
+
+func (m *MockRecurringExpenseRepository) DeleteOccurrence(ctx context.Context, recurringID uuid.UUID, period time.Time) error {
+	args := m.Called(ctx, recurringID, period)
+	return args.Error(0)
+}
+
+func (m *MockRecurringExpenseRepository) Generate(ctx context.Context, occurrence *domain.RecurringExpenseOccurrence, expense *domain.Expense) error {
+	args := m.Called(ctx, occurrence, expense)
+	return args.Error(0)
+}
+
+// MockExpenseCategoryRepository is a mock implementation of ExpenseCategoryRepository
+type MockExpenseCategoryRepository struct {
+	mock.Mock
+}
+
+func (m *MockExpenseCategoryRepository) Create(ctx context.Context, category *domain.ExpenseCategory) error {
+	args := m.Called(ctx, category)
+	return args.Error(0)
+}
+
+func (m *MockExpenseCategoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.ExpenseCategory, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ExpenseCategory), args.Error(1)
+}
+
+func (m *MockExpenseCategoryRepository) GetByName(ctx context.Context, name string) (*domain.ExpenseCategory, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ExpenseCategory), args.Error(1)
+}
+
+func (m *MockExpenseCategoryRepository) List(ctx context.Context) ([]*domain.ExpenseCategory, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*domain.ExpenseCategory), args.Error(1)
+}
+
+func (m *MockExpenseCategoryRepository) GetCategories(ctx context.Context) ([]*domain.ExpenseCategory, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*domain.ExpenseCategory), args.Error(1)
+}
+
+func (m *MockExpenseCategoryRepository) GetSubcategories(ctx context.Context, parentID uuid.UUID) ([]*domain.ExpenseCategory, error) {
+	args := m.Called(ctx, parentID)
+	return args.Get(0).([]*domain.ExpenseCategory), args.Error(1)
+}
+
+func (m *MockExpenseCategoryRepository) GetCategoryTree(ctx context.Context) ([]*domain.ExpenseCategoryWithChildren, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*domain.ExpenseCategoryWithChildren), args.Error(1)
+}
+
+func (m *MockExpenseCategoryRepository) Update(ctx context.Context, category *domain.ExpenseCategory) error {
+	args := m.Called(ctx, category)
+	return args.Error(0)
+}
+
+func (m *MockExpenseCategoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockExpenseCategoryRepository) NameExists(ctx context.Context, name string, excludeID uuid.UUID) (bool, error) {
+	args := m.Called(ctx, name, excludeID)
+	return args.Bool(0), args.Error(1)
+}
+
+func newRecurringExpenseTestService(now time.Time) (*recurringExpenseService, *MockRecurringExpenseRepository, *MockExpenseCategoryRepository) {
+	recurringRepo := new(MockRecurringExpenseRepository)
+	categoryRepo := new(MockExpenseCategoryRepository)
+	svc := NewRecurringExpenseService(recurringRepo, categoryRepo).(*recurringExpenseService)
+	svc.now = func() time.Time { return now }
+	return svc, recurringRepo, categoryRepo
+}
+
+func rentTemplate(day int, start time.Time) *domain.RecurringExpense {
+	return &domain.RecurringExpense{
+		ID:             uuid.New(),
+		Supplier:       "Inmobiliaria Centro",
+		Amount:         money.MustParse("605.00"),
+		VATAmount:      money.MustParse("105.00"),
+		VATDeductible:  true,
+		IRPFDeductible: true,
+		CategoryID:     uuid.New(),
+		DayOfMonth:     day,
+		StartDate:      start,
+		IsActive:       true,
+	}
+}
+
+func TestRecurringExpenseService_Create_RejectsSubcategoryAsCategory(t *testing.T) {
+	svc, recurringRepo, categoryRepo := newRecurringExpenseTestService(utcDay(2026, 3, 10))
+	ctx := context.Background()
+
+	parentID := uuid.New()
+	subcategory := &domain.ExpenseCategory{ID: uuid.New(), ParentID: &parentID}
+	categoryRepo.On("GetByID", ctx, subcategory.ID).Return(subcategory, nil)
+
+	_, err := svc.CreateRecurringExpense(ctx, &CreateRecurringExpenseRequest{
+		Supplier:   "Telefónica",
+		Amount:     money.MustParse("48.40"),
+		CategoryID: subcategory.ID,
+		DayOfMonth: 1,
+		StartDate:  utcDay(2026, 1, 1),
+	})
+
+	require.Error(t, err)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, errors.CodeValidationFailed, appErr.Code)
+	recurringRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestRecurringExpenseService_Create_ValidatesSchedule(t *testing.T) {
+	svc, recurringRepo, categoryRepo := newRecurringExpenseTestService(utcDay(2026, 3, 10))
+	ctx := context.Background()
+
+	category := &domain.ExpenseCategory{ID: uuid.New()}
+	categoryRepo.On("GetByID", ctx, category.ID).Return(category, nil)
+	recurringRepo.On("Create", ctx, mock.AnythingOfType("*domain.RecurringExpense")).Return(nil)
+
+	endBeforeStart := utcDay(2025, 12, 31)
+	tests := []struct {
+		name string
+		req  CreateRecurringExpenseRequest
+		want error
+	}{
+		{"day out of range", CreateRecurringExpenseRequest{DayOfMonth: 32, StartDate: utcDay(2026, 1, 1)}, domain.ErrInvalidRecurringDay},
+		{"ends before start", CreateRecurringExpenseRequest{DayOfMonth: 1, StartDate: utcDay(2026, 1, 1), EndDate: &endBeforeStart}, domain.ErrInvalidRecurringDates},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Supplier = "Adobe"
+			tt.req.Amount = money.MustParse("24.19")
+			tt.req.CategoryID = category.ID
+
+			_, err := svc.CreateRecurringExpense(ctx, &tt.req)
+			assert.Equal(t, tt.want, err)
+		})
+	}
+
+	recurring, err := svc.CreateRecurringExpense(ctx, &CreateRecurringExpenseRequest{
+		Supplier:   " Adobe ",
+		Amount:     money.MustParse("24.19"),
+		VATAmount:  money.MustParse("4.20"),
+		CategoryID: category.ID,
+		DayOfMonth: 3,
+		StartDate:  utcDay(2026, 1, 1),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Adobe", recurring.Supplier)
+	assert.True(t, recurring.IsActive)
+	assert.True(t, recurring.IRPFDeductible)
+}
+
+func TestRecurringExpense_DueDateUsesLastDayOfShortMonths(t *testing.T) {
+	recurring := rentTemplate(31, utcDay(2026, 1, 15))
+
+	assert.Equal(t, utcDay(2026, 1, 31), recurring.DueDate(utcDay(2026, 1, 1)))
+	assert.Equal(t, utcDay(2026, 2, 28), recurring.DueDate(utcDay(2026, 2, 1)))
+	assert.Equal(t, utcDay(2028, 2, 29), recurring.DueDate(utcDay(2028, 2, 1)))
+	assert.Equal(t, utcDay(2026, 4, 30), recurring.DueDate(utcDay(2026, 4, 1)))
+
+	// The month of the start date counts only when its due day is not earlier
+	late := rentTemplate(10, utcDay(2026, 1, 15))
+	assert.False(t, late.OccursIn(utcDay(2026, 1, 1)))
+	assert.True(t, late.OccursIn(utcDay(2026, 2, 1)))
+
+	end := utcDay(2026, 3, 5)
+	late.EndDate = &end
+	assert.False(t, late.OccursIn(utcDay(2026, 3, 1)))
+}
+
+func TestRecurringExpenseService_GenerateDue(t *testing.T) {
+	now := time.Date(2026, 3, 10, 6, 0, 0, 0, time.UTC)
+	svc, recurringRepo, _ := newRecurringExpenseTestService(now)
+	ctx := context.Background()
+
+	// Rent on the last day: January adjusted, February skipped, March not due yet
+	rent := rentTemplate(31, utcDay(2026, 1, 1))
+	adjustedAmount := money.MustParse("550.00")
+	adjustedVAT := money.MustParse("95.45")
+	january := &domain.RecurringExpenseOccurrence{
+		ID:                 uuid.New(),
+		RecurringExpenseID: rent.ID,
+		Period:             utcDay(2026, 1, 1),
+		Status:             domain.OccurrenceStatusAdjusted,
+		Amount:             &adjustedAmount,
+		VATAmount:          &adjustedVAT,
+	}
+	february := &domain.RecurringExpenseOccurrence{
+		RecurringExpenseID: rent.ID,
+		Period:             utcDay(2026, 2, 1),
+		Status:             domain.OccurrenceStatusSkipped,
+	}
+
+	// Subscription on the 5th starting this month
+	subscription := rentTemplate(5, utcDay(2026, 3, 1))
+	subscription.Supplier = "Google Workspace"
+
+	// Not started yet
+	future := rentTemplate(1, utcDay(2026, 4, 1))
+
+	recurringRepo.On("List", ctx, true).Return([]*domain.RecurringExpense{rent, subscription, future}, nil)
+	recurringRepo.On("ListOccurrences", ctx, rent.ID, utcDay(2026, 1, 1), utcDay(2026, 3, 1)).
+		Return([]*domain.RecurringExpenseOccurrence{january, february}, nil)
+	recurringRepo.On("ListOccurrences", ctx, subscription.ID, utcDay(2026, 3, 1), utcDay(2026, 3, 1)).
+		Return([]*domain.RecurringExpenseOccurrence{}, nil)
+
+	var generated []*domain.Expense
+	recurringRepo.On("Generate", ctx, mock.AnythingOfType("*domain.RecurringExpenseOccurrence"), mock.AnythingOfType("*domain.Expense")).
+		Run(func(args mock.Arguments) {
+			generated = append(generated, args.Get(2).(*domain.Expense))
+		}).Return(nil)
+
+	run, err := svc.GenerateDue(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, run.Generated)
+	assert.Equal(t, 0, run.Failed)
+	require.Len(t, generated, 2)
+
+	// The adjustment applies to January only
+	assert.Equal(t, utcDay(2026, 1, 31), generated[0].ExpenseDate)
+	assert.Equal(t, "550.00", generated[0].Amount.Decimal())
+	assert.Equal(t, "95.45", generated[0].VATAmount.Decimal())
+	assert.True(t, generated[0].NeedsReview)
+	assert.Equal(t, rent.ID, *generated[0].RecurringExpenseID)
+
+	assert.Equal(t, utcDay(2026, 3, 5), generated[1].ExpenseDate)
+	assert.Equal(t, "Google Workspace", generated[1].Supplier)
+	assert.Equal(t, "605.00", generated[1].Amount.Decimal())
+	assert.True(t, generated[1].NeedsReview)
+
+	recurringRepo.AssertNotCalled(t, "ListOccurrences", mock.Anything, future.ID, mock.Anything, mock.Anything)
+}
+
+func TestRecurringExpenseService_GenerateDue_ConcurrentRunIsNotAFailure(t *testing.T) {
+	svc, recurringRepo, _ := newRecurringExpenseTestService(utcDay(2026, 3, 10))
+	ctx := context.Background()
+
+	subscription := rentTemplate(1, utcDay(2026, 3, 1))
+	recurringRepo.On("List", ctx, true).Return([]*domain.RecurringExpense{subscription}, nil)
+	recurringRepo.On("ListOccurrences", ctx, subscription.ID, mock.Anything, mock.Anything).Return([]*domain.RecurringExpenseOccurrence{}, nil)
+	recurringRepo.On("Generate", ctx, mock.Anything, mock.Anything).Return(domain.ErrOccurrenceGenerated)
+
+	run, err := svc.GenerateDue(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, run.Generated)
+	assert.Equal(t, 0, run.Failed)
+}
+
+func TestRecurringExpenseService_AdjustOccurrence(t *testing.T) {
+	svc, recurringRepo, _ := newRecurringExpenseTestService(utcDay(2026, 3, 10))
+	ctx := context.Background()
+
+	rent := rentTemplate(1, utcDay(2026, 1, 1))
+	recurringRepo.On("GetByID", ctx, rent.ID).Return(rent, nil)
+	recurringRepo.On("SaveOccurrence", ctx, mock.AnythingOfType("*domain.RecurringExpenseOccurrence")).Return(nil)
+
+	// Skipping a month
+	notes := "Rent waived during the works"
+	skipped, err := svc.AdjustOccurrence(ctx, rent.ID, "2026-04", &AdjustOccurrenceRequest{Skip: true, Notes: &notes})
+	require.NoError(t, err)
+	assert.Equal(t, domain.OccurrenceStatusSkipped, skipped.Status)
+	assert.Equal(t, utcDay(2026, 4, 1), skipped.Period)
+
+	// Adjusting a month
+	date := utcDay(2026, 5, 12)
+	amount := money.MustParse("700.00")
+	adjusted, err := svc.AdjustOccurrence(ctx, rent.ID, "2026-05", &AdjustOccurrenceRequest{ExpenseDate: &date, Amount: &amount})
+	require.NoError(t, err)
+	assert.Equal(t, domain.OccurrenceStatusAdjusted, adjusted.Status)
+	assert.Equal(t, "700.00", adjusted.Amount.Decimal())
+
+	// Invalid adjustments
+	otherMonth := utcDay(2026, 7, 1)
+	tooMuchVAT := money.MustParse("605.00")
+	tests := []struct {
+		name   string
+		period string
+		req    AdjustOccurrenceRequest
+	}{
+		{"invalid period", "May 2026", AdjustOccurrenceRequest{Skip: true}},
+		{"before the start", "2025-12", AdjustOccurrenceRequest{Skip: true}},
+		{"nothing to adjust", "2026-06", AdjustOccurrenceRequest{}},
+		{"date in another month", "2026-06", AdjustOccurrenceRequest{ExpenseDate: &otherMonth}},
+		{"VAT not lower than the amount", "2026-06", AdjustOccurrenceRequest{VATAmount: &tooMuchVAT}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.AdjustOccurrence(ctx, rent.ID, tt.period, &tt.req)
+			require.Error(t, err)
+			appErr, ok := err.(*errors.AppError)
+			require.True(t, ok)
+			assert.Equal(t, errors.CodeValidationFailed, appErr.Code)
+		})
+	}
+
+	recurringRepo.AssertNumberOfCalls(t, "SaveOccurrence", 2)
+}
+
+func TestRecurringExpenseService_ListOccurrences(t *testing.T) {
+	svc, recurringRepo, _ := newRecurringExpenseTestService(utcDay(2026, 3, 10))
+	ctx := context.Background()
+
+	end := utcDay(2026, 5, 31)
+	rent := rentTemplate(31, utcDay(2026, 1, 1))
+	rent.EndDate = &end
+	expenseID := uuid.New()
+	march := &domain.RecurringExpenseOccurrence{
+		RecurringExpenseID: rent.ID,
+		Period:             utcDay(2026, 3, 1),
+		Status:             domain.OccurrenceStatusGenerated,
+		ExpenseID:          &expenseID,
+	}
+
+	recurringRepo.On("GetByID", ctx, rent.ID).Return(rent, nil)
+	recurringRepo.On("ListOccurrences", ctx, rent.ID, utcDay(2026, 3, 1), utcDay(2026, 8, 1)).
+		Return([]*domain.RecurringExpenseOccurrence{march}, nil)
+
+	occurrences, err := svc.ListOccurrences(ctx, rent.ID, "", "")
+
+	require.NoError(t, err)
+	require.Len(t, occurrences, 3) // March to May; the template ends in May
+	assert.Equal(t, domain.OccurrenceStatusGenerated, occurrences[0].Status)
+	assert.Equal(t, domain.OccurrenceStatusDue, occurrences[1].Status)
+	assert.Equal(t, utcDay(2026, 4, 30), *occurrences[1].ExpenseDate)
+	assert.Equal(t, utcDay(2026, 5, 31), *occurrences[2].ExpenseDate)
+
+	_, err = svc.ListOccurrences(ctx, rent.ID, "2026-01", "2028-01")
+	assert.Error(t, err)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -95,7 +96,7 @@ func TestSearchService_GlobalSearch_Success(t *testing.T) {
 			ID:            invoiceID,
 			InvoiceNumber: "TEST-001",
 			ClientName:    "Test Client",
-			TotalAmount:   100.00,
+			TotalAmount:   money.MustParse("100.00"),
 			Status:        "paid",
 		},
 	}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+)
+
+const (
+	pain008Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.008.001.02"
+	sepaDateLayout   = "2006-01-02"
+	sepaCurrency     = "EUR"
+
+	// Agents are identified by the IBAN alone when the BIC is not known
+	sepaAgentNotProvided = "NOTPROVIDED"
+)
+
+// pain008Document is a SEPA Core direct debit initiation (pain.008.001.02) with one payment
+// information block per sequence type
+type pain008Document struct {
+	XMLName    xml.Name          `xml:"Document"`
+	Namespace  string            `xml:"xmlns,attr"`
+	Initiation pain008Initiation `xml:"CstmrDrctDbtInitn"`
+}
+
+type pain008Initiation struct {
+	GroupHeader pain008GroupHeader `xml:"GrpHdr"`
+	Payments    []pain008Payment   `xml:"PmtInf"`
+}
+
+type pain008GroupHeader struct {
+	MessageID        string       `xml:"MsgId"`
+	CreationDateTime string       `xml:"CreDtTm"`
+	NumberOfTxs      int          `xml:"NbOfTxs"`
+	ControlSum       string       `xml:"CtrlSum"`
+	InitiatingParty  pain008Party `xml:"InitgPty"`
+}
+
+type pain008Party struct {
+	Name string `xml:"Nm"`
+}
+
+type pain008Payment struct {
+	PaymentInfoID    string               `xml:"PmtInfId"`
+	PaymentMethod    string               `xml:"PmtMtd"` // DD: direct debit
+	NumberOfTxs      int                  `xml:"NbOfTxs"`
+	ControlSum       string               `xml:"CtrlSum"`
+	PaymentType      pain008PaymentType   `xml:"PmtTpInf"`
+	CollectionDate   string               `xml:"ReqdColltnDt"`
+	Creditor         pain008Party         `xml:"Cdtr"`
+	CreditorAccount  pain008Account       `xml:"CdtrAcct"`
+	CreditorAgent    pain008Agent         `xml:"CdtrAgt"`
+	ChargeBearer     string               `xml:"ChrgBr"` // SLEV: following the scheme rules
+	CreditorSchemeID pain008SchemeID      `xml:"CdtrSchmeId"`
+	Transactions     []pain008Transaction `xml:"DrctDbtTxInf"`
+}
+
+type pain008PaymentType struct {
+	ServiceLevel    string                  `xml:"SvcLvl>Cd"`    // SEPA
+	LocalInstrument string                  `xml:"LclInstrm>Cd"` // CORE
+	SequenceType    domain.SEPASequenceType `xml:"SeqTp"`
+}
+
+type pain008Account struct {
+	IBAN string `xml:"Id>IBAN"`
+}
+
+// pain008Agent identifies a bank by its BIC or, without it, as NOTPROVIDED
+type pain008Agent struct {
+	BIC   string          `xml:"FinInstnId>BIC,omitempty"`
+	Other *pain008OtherID `xml:"FinInstnId>Othr,omitempty"`
+}
+
+type pain008OtherID struct {
+	ID string `xml:"Id"`
+}
+
+type pain008SchemeID struct {
+	ID         string `xml:"Id>PrvtId>Othr>Id"`
+	SchemeName string `xml:"Id>PrvtId>Othr>SchmeNm>Prtry"` // SEPA
+}
+
+type pain008Transaction struct {
+	EndToEndID     string         `xml:"PmtId>EndToEndId"`
+	Amount         pain008Amount  `xml:"InstdAmt"`
+	MandateID      string         `xml:"DrctDbtTx>MndtRltdInf>MndtId"`
+	SignatureDate  string         `xml:"DrctDbtTx>MndtRltdInf>DtOfSgntr"`
+	DebtorAgent    pain008Agent   `xml:"DbtrAgt"`
+	Debtor         pain008Party   `xml:"Dbtr"`
+	DebtorAccount  pain008Account `xml:"DbtrAcct"`
+	RemittanceInfo string         `xml:"RmtInf>Ustrd"`
+}
+
+type pain008Amount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// buildPain008 maps a batch and its debits, with their invoices and mandates, to a pain.008
+// document; the debits are grouped by sequence type, FRST first, in their original order
+func buildPain008(batch *domain.SEPABatch, settings *domain.BillingSettings, createdAt time.Time) (*pain008Document, error) {
+	creditorName := sepaText(settings.LegalName, 70)
+	creditorIBAN := domain.NormalizeIBAN(settings.IBAN)
+
+	var payments []pain008Payment
+	for _, sequence := range []domain.SEPASequenceType{domain.SEPASequenceFirst, domain.SEPASequenceRecurrent} {
+		var transactions []pain008Transaction
+		var amounts []money.Money
+		for _, debit := range batch.Debits {
+			if debit.Sequence != sequence {
+				continue
+			}
+			transactions = append(transactions, pain008Transaction{
+				EndToEndID:     debit.EndToEndID,
+				Amount:         pain008Amount{Currency: sepaCurrency, Value: debit.Amount.Decimal()},
+				MandateID:      debit.Mandate.Reference,
+				SignatureDate:  debit.Mandate.SignedAt.Format(sepaDateLayout),
+				DebtorAgent:    sepaAgent(debit.Mandate.BIC),
+				Debtor:         pain008Party{Name: sepaText(debit.Mandate.DebtorName, 70)},
+				DebtorAccount:  pain008Account{IBAN: debit.Mandate.IBAN},
+				RemittanceInfo: sepaText("Factura "+debit.Invoice.InvoiceNumber, 140),
+			})
+			amounts = append(amounts, debit.Amount)
+		}
+		if len(transactions) == 0 {
+			continue
+		}
+
+		sum, err := money.Sum(amounts...)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, pain008Payment{
+			PaymentInfoID: fmt.Sprintf("%s-%s", prefix(batch.MessageID, 30), sequence),
+			PaymentMethod: "DD",
+			NumberOfTxs:   len(transactions),
+			ControlSum:    sum.Decimal(),
+			PaymentType: pain008PaymentType{
+				ServiceLevel:    "SEPA",
+				LocalInstrument: "CORE",
+				SequenceType:    sequence,
+			},
+			CollectionDate:   batch.CollectionDate.Format(sepaDateLayout),
+			Creditor:         pain008Party{Name: creditorName},
+			CreditorAccount:  pain008Account{IBAN: creditorIBAN},
+			CreditorAgent:    sepaAgent(""),
+			ChargeBearer:     "SLEV",
+			CreditorSchemeID: pain008SchemeID{ID: settings.SEPACreditorID, SchemeName: "SEPA"},
+			Transactions:     transactions,
+		})
+	}
+
+	return &pain008Document{
+		Namespace: pain008Namespace,
+		Initiation: pain008Initiation{
+			GroupHeader: pain008GroupHeader{
+				MessageID:        batch.MessageID,
+				CreationDateTime: createdAt.Format("2006-01-02T15:04:05"),
+				NumberOfTxs:      batch.TransactionCount,
+				ControlSum:       batch.TotalAmount.Decimal(),
+				InitiatingParty:  pain008Party{Name: creditorName},
+			},
+			Payments: payments,
+		},
+	}, nil
+}
+
+// marshalPain008 serializes the document with its XML declaration
+func marshalPain008(document *pain008Document) ([]byte, error) {
+	content, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pain.008 document: %w", err)
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+// pain002Document is the payment status report the bank sends back with the debits it
+// rejected or the debtors returned (fichero de devoluciones)
+type pain002Document struct {
+	XMLName  xml.Name            `xml:"Document"`
+	Payments []pain002PaymentInf `xml:"CstmrPmtStsRpt>OrgnlPmtInfAndSts"`
+}
+
+type pain002PaymentInf struct {
+	Transactions []pain002Transaction `xml:"TxInfAndSts"`
+}
+
+type pain002Transaction struct {
+	EndToEndID string   `xml:"OrgnlEndToEndId"`
+	Status     string   `xml:"TxSts"`            // RJCT for returned debits
+	Reasons    []string `xml:"StsRsnInf>Rsn>Cd"` // ISO reason codes, e.g. AM04
+}
+
+// parsePain002 reads the transactions of a payment status report
+func parsePain002(content []byte) ([]pain002Transaction, error) {
+	var document pain002Document
+	if err := xml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("invalid pain.002 file: %w", err)
+	}
+
+	var transactions []pain002Transaction
+	for _, payment := range document.Payments {
+		transactions = append(transactions, payment.Transactions...)
+	}
+	return transactions, nil
+}
+
+// sepaAgent identifies a bank by its BIC, or as not provided
+func sepaAgent(bic string) pain008Agent {
+	if bic == "" {
+		return pain008Agent{Other: &pain008OtherID{ID: sepaAgentNotProvided}}
+	}
+	return pain008Agent{BIC: bic}
+}
+
+// sepaAccents maps the Spanish letters outside the basic Latin set of SEPA messages, and the
+// underscore of invoice numbers to a hyphen
+var sepaAccents = strings.NewReplacer(
+	"_", "-",
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n", "ç", "c",
+	"Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U", "Ü", "U", "Ñ", "N", "Ç", "C",
+	"à", "a", "è", "e", "ò", "o", "À", "A", "È", "E", "Ò", "O", "ª", "a", "º", "o",
+)
+
+// sepaText converts a name or description to the character set accepted by every bank of
+// the scheme, replacing the other characters with spaces, and shortens it to max characters
+func sepaText(s string, max int) string {
+	s = sepaAccents.Replace(s)
+	converted := []rune(s)
+	for i, r := range converted {
+		if !strings.ContainsRune(sepaTextCharacters, r) {
+			converted[i] = ' '
+		}
+	}
+	return truncateRunes(strings.Join(strings.Fields(string(converted)), " "), max)
+}
+
+const sepaTextCharacters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789/-?:().,'+ "
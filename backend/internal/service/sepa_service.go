@@ -0,0 +1,450 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// MaxSEPAReturnFileBytes is the maximum size of an imported return file
+const MaxSEPAReturnFileBytes = 5 << 20
+
+// sepaRejectedStatus is the transaction status of the debits returned or rejected by the bank
+const sepaRejectedStatus = "RJCT"
+
+// CreateSEPAMandateRequest represents the request to register a mandate signed by a client
+type CreateSEPAMandateRequest struct {
+	Reference  string                  `json:"reference,omitempty" binding:"max=35"` // Generated when empty
+	DebtorName string                  `json:"debtorName,omitempty"`                 // Defaults to the client's name
+	IBAN       string                  `json:"iban" binding:"required"`
+	BIC        string                  `json:"bic,omitempty"`
+	SignedAt   time.Time               `json:"signedAt" binding:"required"`
+	Sequence   domain.SEPASequenceType `json:"sequence,omitempty"` // FRST by default; RCUR for mandates already collected elsewhere
+}
+
+// CreateSEPABatchRequest represents the request to collect a set of invoices by direct debit
+type CreateSEPABatchRequest struct {
+	InvoiceIDs     []uuid.UUID `json:"invoiceIds" binding:"required,min=1"`
+	CollectionDate time.Time   `json:"collectionDate" binding:"required"`
+}
+
+// SEPABatchFile is the pain.008 file of a batch, ready to upload to the bank
+type SEPABatchFile struct {
+	FileName string
+	Content  []byte
+}
+
+// SEPAReturnedDebit is a debit returned by the bank together with its reopened invoice
+type SEPAReturnedDebit struct {
+	Debit   *domain.SEPADebit `json:"debit"`
+	Invoice *domain.Invoice   `json:"invoice"`
+}
+
+// SEPAReturnImport summarizes the import of a return file
+type SEPAReturnImport struct {
+	Returned        []*SEPAReturnedDebit `json:"returned"`
+	AlreadyReturned []string             `json:"alreadyReturned"` // End-to-end IDs imported before
+	Unmatched       []string             `json:"unmatched"`       // End-to-end IDs of no debit
+}
+
+// SEPAService handles SEPA direct debit mandates, batches and returns
+type SEPAService interface {
+	// CreateMandate registers the direct debit mandate signed by a client
+	CreateMandate(ctx context.Context, clientID uuid.UUID, req *CreateSEPAMandateRequest) (*domain.SEPAMandate, error)
+
+	// ListClientMandates retrieves the mandates of a client, including revoked ones
+	ListClientMandates(ctx context.Context, clientID uuid.UUID) ([]*domain.SEPAMandate, error)
+
+	// RevokeMandate revokes a mandate, which can no longer be collected
+	RevokeMandate(ctx context.Context, id uuid.UUID) (*domain.SEPAMandate, error)
+
+	// CreateBatch collects the outstanding balance of a set of issued invoices by direct
+	// debit. A payment is recorded for each invoice on the collection date, and the batch
+	// keeps the pain.008 file to upload to the bank.
+	CreateBatch(ctx context.Context, req *CreateSEPABatchRequest, createdBy uuid.UUID) (*domain.SEPABatch, error)
+
+	// GetBatch retrieves a batch with its debits
+	GetBatch(ctx context.Context, id uuid.UUID) (*domain.SEPABatch, error)
+
+	// ListBatches retrieves the batches, newest first
+	ListBatches(ctx context.Context) ([]*domain.SEPABatch, error)
+
+	// GetBatchFile retrieves the pain.008 file of a batch
+	GetBatchFile(ctx context.Context, id uuid.UUID) (*SEPABatchFile, error)
+
+	// ImportReturns reads a pain.002 return file from the bank and reverses the payment of
+	// every returned debit, reopening its invoice
+	ImportReturns(ctx context.Context, file io.Reader, importedBy uuid.UUID) (*SEPAReturnImport, error)
+}
+
+type sepaService struct {
+	sepaRepo     repository.SEPARepository
+	invoiceRepo  repository.InvoiceRepository
+	clientRepo   repository.ClientRepository
+	paymentRepo  repository.PaymentRepository
+	settingsRepo repository.BillingSettingsRepository
+	now          func() time.Time
+}
+
+// NewSEPAService creates a new SEPA direct debit service
+func NewSEPAService(
+	sepaRepo repository.SEPARepository,
+	invoiceRepo repository.InvoiceRepository,
+	clientRepo repository.ClientRepository,
+	paymentRepo repository.PaymentRepository,
+	settingsRepo repository.BillingSettingsRepository,
+) SEPAService {
+	return &sepaService{
+		sepaRepo:     sepaRepo,
+		invoiceRepo:  invoiceRepo,
+		clientRepo:   clientRepo,
+		paymentRepo:  paymentRepo,
+		settingsRepo: settingsRepo,
+		now:          time.Now,
+	}
+}
+
+// CreateMandate registers the direct debit mandate signed by a client
+func (s *sepaService) CreateMandate(ctx context.Context, clientID uuid.UUID, req *CreateSEPAMandateRequest) (*domain.SEPAMandate, error) {
+	client, err := s.clientRepo.GetByID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	mandate := &domain.SEPAMandate{
+		ID:         uuid.New(),
+		ClientID:   client.ID,
+		Reference:  strings.TrimSpace(req.Reference),
+		DebtorName: strings.TrimSpace(req.DebtorName),
+		IBAN:       domain.NormalizeIBAN(req.IBAN),
+		BIC:        strings.ToUpper(strings.TrimSpace(req.BIC)),
+		SignedAt:   req.SignedAt,
+		Sequence:   req.Sequence,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if mandate.Reference == "" {
+		mandate.Reference = sepaIdentifier(mandate.ID)
+	}
+	if mandate.DebtorName == "" {
+		mandate.DebtorName = client.FullName()
+	}
+	if mandate.Sequence == "" {
+		mandate.Sequence = domain.SEPASequenceFirst
+	}
+
+	if err := mandate.Validate(); err != nil {
+		return nil, err
+	}
+	if mandate.SignedAt.After(now) {
+		return nil, errors.NewValidationError("mandate signature date cannot be in the future", map[string][]string{
+			"signedAt": {"the mandate must be signed before it is registered"},
+		})
+	}
+
+	if err := s.sepaRepo.CreateMandate(ctx, mandate); err != nil {
+		return nil, err
+	}
+
+	return mandate, nil
+}
+
+// ListClientMandates retrieves the mandates of a client, including revoked ones
+func (s *sepaService) ListClientMandates(ctx context.Context, clientID uuid.UUID) ([]*domain.SEPAMandate, error) {
+	if _, err := s.clientRepo.GetByID(ctx, clientID); err != nil {
+		return nil, err
+	}
+
+	return s.sepaRepo.ListMandatesByClient(ctx, clientID)
+}
+
+// RevokeMandate revokes a mandate, which can no longer be collected
+func (s *sepaService) RevokeMandate(ctx context.Context, id uuid.UUID) (*domain.SEPAMandate, error) {
+	mandate, err := s.sepaRepo.GetMandateByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !mandate.IsActive() {
+		return nil, domain.ErrMandateRevoked
+	}
+
+	now := s.now()
+	mandate.RevokedAt = &now
+	mandate.UpdatedAt = now
+
+	if err := s.sepaRepo.RevokeMandate(ctx, mandate); err != nil {
+		return nil, err
+	}
+
+	return mandate, nil
+}
+
+// CreateBatch collects the outstanding balance of a set of issued invoices by direct
+// debit. A payment is recorded for each invoice on the collection date, and the batch
+// keeps the pain.008 file to upload to the bank.
+func (s *sepaService) CreateBatch(ctx context.Context, req *CreateSEPABatchRequest, createdBy uuid.UUID) (*domain.SEPABatch, error) {
+	settings, err := s.settingsRepo.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing settings: %w", err)
+	}
+	if !settings.CanCollectDirectDebits() {
+		return nil, domain.ErrDirectDebitNotConfigured
+	}
+
+	// SEPA Core debits are presented at least one business day in advance
+	now := s.now()
+	collectionDate := time.Date(req.CollectionDate.Year(), req.CollectionDate.Month(), req.CollectionDate.Day(), 0, 0, 0, 0, time.UTC)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if !collectionDate.After(today) {
+		return nil, errors.NewValidationError("collection date must be after today", map[string][]string{
+			"collectionDate": {"the bank needs the batch at least one day before the collection"},
+		})
+	}
+
+	batch := &domain.SEPABatch{
+		ID:             uuid.New(),
+		CollectionDate: collectionDate,
+		CreatedBy:      &createdBy,
+		CreatedAt:      now,
+	}
+	batch.MessageID = sepaIdentifier(batch.ID)
+
+	debits, problems, err := s.batchDebits(ctx, batch, req.InvoiceIDs, &createdBy)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 {
+		return nil, errors.NewValidationError("invoices cannot be collected by direct debit", map[string][]string{
+			"invoiceIds": problems,
+		})
+	}
+
+	amounts := make([]money.Money, 0, len(debits))
+	for _, debit := range debits {
+		amounts = append(amounts, debit.Amount)
+	}
+	if batch.TotalAmount, err = money.Sum(amounts...); err != nil {
+		return nil, err
+	}
+	batch.TransactionCount = len(debits)
+	batch.Debits = debits
+
+	document, err := buildPain008(batch, settings, now)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Content, err = marshalPain008(document); err != nil {
+		return nil, err
+	}
+
+	if err := s.sepaRepo.CreateBatch(ctx, batch); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// batchDebits prepares the debit and payment of each invoice, collecting the reasons why
+// an invoice cannot be collected so that they are all reported at once
+func (s *sepaService) batchDebits(ctx context.Context, batch *domain.SEPABatch, invoiceIDs []uuid.UUID, createdBy *uuid.UUID) ([]*domain.SEPADebit, []string, error) {
+	var debits []*domain.SEPADebit
+	var problems []string
+	mandates := map[uuid.UUID]*domain.SEPAMandate{}
+	seen := map[uuid.UUID]bool{}
+
+	for _, invoiceID := range invoiceIDs {
+		if seen[invoiceID] {
+			continue
+		}
+		seen[invoiceID] = true
+
+		invoice, err := s.invoiceRepo.GetByID(ctx, invoiceID)
+		if err != nil {
+			if isNotFound(err) {
+				problems = append(problems, fmt.Sprintf("invoice %s not found", invoiceID))
+				continue
+			}
+			return nil, nil, err
+		}
+		if !invoice.IsIssued() {
+			problems = append(problems, fmt.Sprintf("invoice %s is a draft", invoiceID))
+			continue
+		}
+
+		outstanding, err := invoice.OutstandingAmount()
+		if err != nil {
+			return nil, nil, err
+		}
+		if !outstanding.IsPositive() {
+			problems = append(problems, fmt.Sprintf("invoice %s has no outstanding balance", invoice.InvoiceNumber))
+			continue
+		}
+
+		mandate, ok := mandates[invoice.ClientID]
+		if !ok {
+			mandate, err = s.sepaRepo.GetActiveMandate(ctx, invoice.ClientID)
+			if err != nil && !isNotFound(err) {
+				return nil, nil, err
+			}
+			mandates[invoice.ClientID] = mandate
+		}
+		if mandate == nil {
+			problems = append(problems, fmt.Sprintf("client of invoice %s has no active mandate", invoice.InvoiceNumber))
+			continue
+		}
+
+		debitID := uuid.New()
+		endToEndID := sepaIdentifier(debitID)
+		payment := &domain.Payment{
+			ID:          uuid.New(),
+			InvoiceID:   invoice.ID,
+			Amount:      outstanding,
+			PaymentDate: batch.CollectionDate,
+			Method:      domain.PaymentMethodDirectDebit,
+			Reference:   endToEndID,
+			Notes:       fmt.Sprintf("Remesa %s", batch.MessageID),
+			RecordedBy:  createdBy,
+			CreatedAt:   batch.CreatedAt,
+			UpdatedAt:   batch.CreatedAt,
+		}
+		debits = append(debits, &domain.SEPADebit{
+			ID:         debitID,
+			BatchID:    batch.ID,
+			InvoiceID:  invoice.ID,
+			MandateID:  mandate.ID,
+			PaymentID:  payment.ID,
+			EndToEndID: endToEndID,
+			Amount:     outstanding,
+			Sequence:   mandate.Sequence,
+			Status:     domain.SEPADebitPresented,
+			CreatedAt:  batch.CreatedAt,
+			UpdatedAt:  batch.CreatedAt,
+			Invoice:    invoice,
+			Mandate:    mandate,
+			Payment:    payment,
+		})
+	}
+
+	return debits, problems, nil
+}
+
+// GetBatch retrieves a batch with its debits
+func (s *sepaService) GetBatch(ctx context.Context, id uuid.UUID) (*domain.SEPABatch, error) {
+	return s.sepaRepo.GetBatch(ctx, id)
+}
+
+// ListBatches retrieves the batches, newest first
+func (s *sepaService) ListBatches(ctx context.Context) ([]*domain.SEPABatch, error) {
+	return s.sepaRepo.ListBatches(ctx)
+}
+
+// GetBatchFile retrieves the pain.008 file of a batch
+func (s *sepaService) GetBatchFile(ctx context.Context, id uuid.UUID) (*SEPABatchFile, error) {
+	batch, err := s.sepaRepo.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SEPABatchFile{
+		FileName: fmt.Sprintf("remesa_%s_%s.xml", batch.CollectionDate.Format(sepaDateLayout), prefix(batch.MessageID, 8)),
+		Content:  batch.Content,
+	}, nil
+}
+
+// ImportReturns reads a pain.002 return file from the bank and reverses the payment of
+// every returned debit, reopening its invoice
+func (s *sepaService) ImportReturns(ctx context.Context, file io.Reader, importedBy uuid.UUID) (*SEPAReturnImport, error) {
+	content, err := io.ReadAll(io.LimitReader(file, MaxSEPAReturnFileBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read return file: %w", err)
+	}
+	if len(content) > MaxSEPAReturnFileBytes {
+		return nil, errors.NewValidationError(fmt.Sprintf("return file exceeds the maximum size of %d MB", MaxSEPAReturnFileBytes>>20), nil)
+	}
+
+	transactions, err := parsePain002(content)
+	if err != nil || len(transactions) == 0 {
+		return nil, errors.NewValidationError("file is not a pain.002 payment status report", map[string][]string{
+			"file": {"upload the return file (devoluciones) downloaded from the bank"},
+		})
+	}
+
+	result := &SEPAReturnImport{
+		Returned:        []*SEPAReturnedDebit{},
+		AlreadyReturned: []string{},
+		Unmatched:       []string{},
+	}
+	for _, transaction := range transactions {
+		if transaction.Status != sepaRejectedStatus {
+			continue
+		}
+		endToEndID := strings.TrimSpace(transaction.EndToEndID)
+
+		debit, err := s.sepaRepo.GetDebitByEndToEndID(ctx, endToEndID)
+		if err != nil {
+			if isNotFound(err) {
+				result.Unmatched = append(result.Unmatched, endToEndID)
+				continue
+			}
+			return nil, err
+		}
+		if debit.IsReturned() {
+			result.AlreadyReturned = append(result.AlreadyReturned, endToEndID)
+			continue
+		}
+
+		invoice, err := s.returnDebit(ctx, debit, transaction.Reasons, importedBy)
+		if err == domain.ErrDebitAlreadyReturned {
+			result.AlreadyReturned = append(result.AlreadyReturned, endToEndID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Returned = append(result.Returned, &SEPAReturnedDebit{Debit: debit, Invoice: invoice})
+	}
+
+	return result, nil
+}
+
+// returnDebit marks a debit as returned with the first reason given by the bank and
+// reverses its payment
+func (s *sepaService) returnDebit(ctx context.Context, debit *domain.SEPADebit, reasons []string, importedBy uuid.UUID) (*domain.Invoice, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, debit.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	reversal := "Devolución de adeudo SEPA"
+	if len(reasons) > 0 && reasons[0] != "" {
+		reason := prefix(strings.TrimSpace(reasons[0]), 4)
+		debit.ReturnReason = &reason
+		reversal = fmt.Sprintf("%s (%s)", reversal, reason)
+	}
+	debit.Status = domain.SEPADebitReturned
+	debit.ReturnedAt = &now
+	debit.UpdatedAt = now
+
+	payment.ReversedAt = &now
+	payment.ReversedBy = &importedBy
+	payment.ReversalReason = reversal
+	payment.UpdatedAt = now
+
+	return s.sepaRepo.ReturnDebit(ctx, debit, payment)
+}
+
+// sepaIdentifier returns the 32 hexadecimal digits of a UUID, an identifier unique across
+// batches that fits the 35 characters of SEPA identifiers
+func sepaIdentifier(id uuid.UUID) string {
+	return strings.ToUpper(strings.ReplaceAll(id.String(), "-", ""))
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockSEPARepository is a mock implementation of repository.SEPARepository
+type MockSEPARepository struct {
+	mock.Mock
+}
+
+func (m *MockSEPARepository) CreateMandate(ctx context.Context, mandate *domain.SEPAMandate) error {
+	args := m.Called(ctx, mandate)
+	return args.Error(0)
+}
+
+func (m *MockSEPARepository) GetMandateByID(ctx context.Context, id uuid.UUID) (*domain.SEPAMandate, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SEPAMandate), args.Error(1)
+}
+
+func (m *MockSEPARepository) GetActiveMandate(ctx context.Context, clientID uuid.UUID) (*domain.SEPAMandate, error) {
+	args := m.Called(ctx, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SEPAMandate), args.Error(1)
+}
+
+func (m *MockSEPARepository) ListMandatesByClient(ctx context.Context, clientID uuid.UUID) ([]*domain.SEPAMandate, error) {
+	args := m.Called(ctx, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.SEPAMandate), args.Error(1)
+}
+
+func (m *MockSEPARepository) RevokeMandate(ctx context.Context, mandate *domain.SEPAMandate) error {
+	args := m.Called(ctx, mandate)
+	return args.Error(0)
+}
+
+func (m *MockSEPARepository) CreateBatch(ctx context.Context, batch *domain.SEPABatch) error {
+	args := m.Called(ctx, batch)
+	return args.Error(0)
+}
+
+func (m *MockSEPARepository) GetBatch(ctx context.Context, id uuid.UUID) (*domain.SEPABatch, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SEPABatch), args.Error(1)
+}
+
+func (m *MockSEPARepository) ListBatches(ctx context.Context) ([]*domain.SEPABatch, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.SEPABatch), args.Error(1)
+}
+
+func (m *MockSEPARepository) GetDebitByEndToEndID(ctx context.Context, endToEndID string) (*domain.SEPADebit, error) {
+	args := m.Called(ctx, endToEndID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SEPADebit), args.Error(1)
+}
+
+func (m *MockSEPARepository) ReturnDebit(ctx context.Context, debit *domain.SEPADebit, payment *domain.Payment) (*domain.Invoice, error) {
+	args := m.Called(ctx, debit, payment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Invoice), args.Error(1)
+}
+
+type sepaTestMocks struct {
+	sepaRepo     *MockSEPARepository
+	invoiceRepo  *MockInvoiceRepository
+	clientRepo   *MockClientRepository
+	paymentRepo  *MockPaymentRepository
+	settingsRepo *MockBillingSettingsRepository
+}
+
+func newSEPATestService() (*sepaService, *sepaTestMocks) {
+	m := &sepaTestMocks{
+		sepaRepo:     new(MockSEPARepository),
+		invoiceRepo:  new(MockInvoiceRepository),
+		clientRepo:   new(MockClientRepository),
+		paymentRepo:  new(MockPaymentRepository),
+		settingsRepo: new(MockBillingSettingsRepository),
+	}
+	svc := NewSEPAService(m.sepaRepo, m.invoiceRepo, m.clientRepo, m.paymentRepo, m.settingsRepo).(*sepaService)
+	svc.now = func() time.Time { return time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC) }
+	return svc, m
+}
+
+func sepaTestSettings() *domain.BillingSettings {
+	return &domain.BillingSettings{
+		LegalName:      "Clínica Arnela S.L.",
+		TaxID:          "B12345678",
+		IBAN:           "ES79 2100 0813 6101 2345 6789",
+		SEPACreditorID: "ES97ZZZB12345678",
+	}
+}
+
+func sepaTestMandate(clientID uuid.UUID, sequence domain.SEPASequenceType) *domain.SEPAMandate {
+	return &domain.SEPAMandate{
+		ID:         uuid.New(),
+		ClientID:   clientID,
+		Reference:  "MND-0001",
+		DebtorName: "Lucía Martín Núñez",
+		IBAN:       "ES9121000418450200051332",
+		SignedAt:   utcDay(2025, 1, 15),
+		Sequence:   sequence,
+	}
+}
+
+func TestSEPAService_CreateMandate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("normalizes the IBAN and fills the defaults", func(t *testing.T) {
+		svc, m := newSEPATestService()
+		client := &domain.Client{ID: uuid.New(), FirstName: "Lucía", LastName: "Martín"}
+		m.clientRepo.On("GetByID", ctx, client.ID).Return(client, nil)
+		m.sepaRepo.On("CreateMandate", ctx, mock.AnythingOfType("*domain.SEPAMandate")).Return(nil)
+
+		mandate, err := svc.CreateMandate(ctx, client.ID, &CreateSEPAMandateRequest{
+			IBAN:     "es91 2100 0418 4502 0005 1332",
+			SignedAt: utcDay(2025, 3, 1),
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "ES9121000418450200051332", mandate.IBAN)
+		assert.Equal(t, "Lucía Martín", mandate.DebtorName)
+		assert.Equal(t, domain.SEPASequenceFirst, mandate.Sequence)
+		assert.Len(t, mandate.Reference, 32)
+		assert.True(t, mandate.IsActive())
+	})
+
+	t.Run("rejects invalid IBANs and future signatures", func(t *testing.T) {
+		svc, m := newSEPATestService()
+		client := &domain.Client{ID: uuid.New(), FirstName: "Lucía", LastName: "Martín"}
+		m.clientRepo.On("GetByID", ctx, client.ID).Return(client, nil)
+
+		_, err := svc.CreateMandate(ctx, client.ID, &CreateSEPAMandateRequest{
+			IBAN:     "ES9121000418450200051333", // Wrong check digits
+			SignedAt: utcDay(2025, 3, 1),
+		})
+		requireValidationError(t, err)
+
+		_, err = svc.CreateMandate(ctx, client.ID, &CreateSEPAMandateRequest{
+			IBAN:     "ES91210004184502000513", // Too short for Spain
+			SignedAt: utcDay(2025, 3, 1),
+		})
+		requireValidationError(t, err)
+
+		_, err = svc.CreateMandate(ctx, client.ID, &CreateSEPAMandateRequest{
+			IBAN:     "DE89370400440532013000",
+			SignedAt: utcDay(2025, 3, 21),
+		})
+		requireValidationError(t, err)
+
+		m.sepaRepo.AssertNotCalled(t, "CreateMandate", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSEPAService_CreateBatch(t *testing.T) {
+	ctx := context.Background()
+	createdBy := uuid.New()
+
+	t.Run("generates the pain.008 file grouped by sequence type", func(t *testing.T) {
+		svc, m := newSEPATestService()
+		first := issuedTestInvoice(t) // 361.00
+		second := issuedTestInvoice(t)
+		second.InvoiceNumber = "F_2025_0011"
+		require.NoError(t, second.ApplyPaidAmount(money.MustParse("61")))
+		firstMandate := sepaTestMandate(first.ClientID, domain.SEPASequenceFirst)
+		secondMandate := sepaTestMandate(second.ClientID, domain.SEPASequenceRecurrent)
+		secondMandate.BIC = "CAIXESBBXXX"
+
+		m.settingsRepo.On("Get", ctx).Return(sepaTestSettings(), nil)
+		m.invoiceRepo.On("GetByID", ctx, first.ID).Return(first, nil)
+		m.invoiceRepo.On("GetByID", ctx, second.ID).Return(second, nil)
+		m.sepaRepo.On("GetActiveMandate", ctx, first.ClientID).Return(firstMandate, nil)
+		m.sepaRepo.On("GetActiveMandate", ctx, second.ClientID).Return(secondMandate, nil)
+		m.sepaRepo.On("CreateBatch", ctx, mock.AnythingOfType("*domain.SEPABatch")).Return(nil)
+
+		batch, err := svc.CreateBatch(ctx, &CreateSEPABatchRequest{
+			InvoiceIDs:     []uuid.UUID{first.ID, second.ID, first.ID},
+			CollectionDate: utcDay(2025, 3, 25),
+		}, createdBy)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, batch.TransactionCount)
+		assert.Equal(t, "661.00", batch.TotalAmount.Decimal())
+		require.Len(t, batch.Debits, 2)
+
+		debit := batch.Debits[1]
+		assert.Equal(t, domain.SEPASequenceRecurrent, debit.Sequence)
+		assert.Equal(t, "300.00", debit.Amount.Decimal())
+		assert.Equal(t, domain.PaymentMethodDirectDebit, debit.Payment.Method)
+		assert.Equal(t, debit.EndToEndID, debit.Payment.Reference)
+		assert.Equal(t, utcDay(2025, 3, 25), debit.Payment.PaymentDate)
+
+		xml := string(batch.Content)
+		assert.True(t, strings.HasPrefix(xml, "<?xml"))
+		assert.Contains(t, xml, `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.008.001.02">`)
+		assert.Contains(t, xml, "<NbOfTxs>2</NbOfTxs>")
+		assert.Contains(t, xml, "<CtrlSum>661.00</CtrlSum>")
+		assert.Contains(t, xml, "<SeqTp>FRST</SeqTp>")
+		assert.Contains(t, xml, "<SeqTp>RCUR</SeqTp>")
+		assert.Contains(t, xml, "<ReqdColltnDt>2025-03-25</ReqdColltnDt>")
+		assert.Contains(t, xml, "<Nm>Clinica Arnela S.L.</Nm>")
+		assert.Contains(t, xml, "<IBAN>ES7921000813610123456789</IBAN>")
+		assert.Contains(t, xml, "<Id>ES97ZZZB12345678</Id>")
+		assert.Contains(t, xml, `<InstdAmt Ccy="EUR">361.00</InstdAmt>`)
+		assert.Contains(t, xml, "<MndtId>MND-0001</MndtId>")
+		assert.Contains(t, xml, "<DtOfSgntr>2025-01-15</DtOfSgntr>")
+		assert.Contains(t, xml, "<BIC>CAIXESBBXXX</BIC>")
+		assert.NotContains(t, xml, "<Othr></Othr>")
+		assert.Contains(t, xml, "<Id>NOTPROVIDED</Id>")
+		assert.Contains(t, xml, "<Nm>Lucia Martin Nunez</Nm>")
+		assert.Contains(t, xml, "<Ustrd>Factura F-2025-0011</Ustrd>")
+		assert.Less(t, strings.Index(xml, "<SeqTp>FRST</SeqTp>"), strings.Index(xml, "<SeqTp>RCUR</SeqTp>"))
+	})
+
+	t.Run("reports every invoice that cannot be collected", func(t *testing.T) {
+		svc, m := newSEPATestService()
+		draft := issuedTestInvoice(t)
+		draft.Status = domain.InvoiceStatusDraft
+		paid := issuedTestInvoice(t)
+		require.NoError(t, paid.ApplyPaidAmount(paid.TotalAmount))
+		noMandate := issuedTestInvoice(t)
+		missing := uuid.New()
+
+		m.settingsRepo.On("Get", ctx).Return(sepaTestSettings(), nil)
+		m.invoiceRepo.On("GetByID", ctx, draft.ID).Return(draft, nil)
+		m.invoiceRepo.On("GetByID", ctx, paid.ID).Return(paid, nil)
+		m.invoiceRepo.On("GetByID", ctx, noMandate.ID).Return(noMandate, nil)
+		m.invoiceRepo.On("GetByID", ctx, missing).Return(nil, errors.NewNotFoundError("invoice not found"))
+		m.sepaRepo.On("GetActiveMandate", ctx, noMandate.ClientID).Return(nil, errors.NewNotFoundError("SEPA mandate not found"))
+
+		_, err := svc.CreateBatch(ctx, &CreateSEPABatchRequest{
+			InvoiceIDs:     []uuid.UUID{draft.ID, paid.ID, noMandate.ID, missing},
+			CollectionDate: utcDay(2025, 3, 25),
+		}, createdBy)
+
+		requireValidationError(t, err)
+		assert.Len(t, err.(*errors.AppError).Details["invoiceIds"], 4)
+		m.sepaRepo.AssertNotCalled(t, "CreateBatch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("requires the creditor data and a future collection date", func(t *testing.T) {
+		svc, m := newSEPATestService()
+		settings := sepaTestSettings()
+		settings.SEPACreditorID = ""
+		m.settingsRepo.On("Get", ctx).Return(settings, nil).Once()
+
+		_, err := svc.CreateBatch(ctx, &CreateSEPABatchRequest{InvoiceIDs: []uuid.UUID{uuid.New()}, CollectionDate: utcDay(2025, 3, 25)}, createdBy)
+		assert.Equal(t, domain.ErrDirectDebitNotConfigured, err)
+
+		m.settingsRepo.On("Get", ctx).Return(sepaTestSettings(), nil)
+		_, err = svc.CreateBatch(ctx, &CreateSEPABatchRequest{InvoiceIDs: []uuid.UUID{uuid.New()}, CollectionDate: utcDay(2025, 3, 20)}, createdBy)
+		requireValidationError(t, err)
+
+		m.invoiceRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	})
+}
+
+const sepaTestReturnFile = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.002.001.03">
+  <CstmrPmtStsRpt>
+    <GrpHdr><MsgId>RET-0001</MsgId></GrpHdr>
+    <OrgnlPmtInfAndSts>
+      <OrgnlPmtInfId>BATCH-FRST</OrgnlPmtInfId>
+      <TxInfAndSts>
+        <OrgnlEndToEndId>E2E-RETURNED</OrgnlEndToEndId>
+        <TxSts>RJCT</TxSts>
+        <StsRsnInf><Rsn><Cd>AM04</Cd></Rsn></StsRsnInf>
+      </TxInfAndSts>
+      <TxInfAndSts>
+        <OrgnlEndToEndId>E2E-TWICE</OrgnlEndToEndId>
+        <TxSts>RJCT</TxSts>
+        <StsRsnInf><Rsn><Cd>MD06</Cd></Rsn></StsRsnInf>
+      </TxInfAndSts>
+      <TxInfAndSts>
+        <OrgnlEndToEndId>E2E-UNKNOWN</OrgnlEndToEndId>
+        <TxSts>RJCT</TxSts>
+      </TxInfAndSts>
+      <TxInfAndSts>
+        <OrgnlEndToEndId>E2E-ACCEPTED</OrgnlEndToEndId>
+        <TxSts>ACCP</TxSts>
+      </TxInfAndSts>
+    </OrgnlPmtInfAndSts>
+  </CstmrPmtStsRpt>
+</Document>`
+
+func TestSEPAService_ImportReturns(t *testing.T) {
+	ctx := context.Background()
+	importedBy := uuid.New()
+	svc, m := newSEPATestService()
+
+	invoice := issuedTestInvoice(t)
+	payment := &domain.Payment{ID: uuid.New(), InvoiceID: invoice.ID, Amount: invoice.TotalAmount, Method: domain.PaymentMethodDirectDebit}
+	debit := &domain.SEPADebit{ID: uuid.New(), InvoiceID: invoice.ID, PaymentID: payment.ID, EndToEndID: "E2E-RETURNED", Sequence: domain.SEPASequenceFirst, Status: domain.SEPADebitPresented}
+	returned := &domain.SEPADebit{ID: uuid.New(), EndToEndID: "E2E-TWICE", Status: domain.SEPADebitReturned}
+
+	m.sepaRepo.On("GetDebitByEndToEndID", ctx, "E2E-RETURNED").Return(debit, nil)
+	m.sepaRepo.On("GetDebitByEndToEndID", ctx, "E2E-TWICE").Return(returned, nil)
+	m.sepaRepo.On("GetDebitByEndToEndID", ctx, "E2E-UNKNOWN").Return(nil, errors.NewNotFoundError("SEPA debit not found"))
+	m.paymentRepo.On("GetByID", ctx, payment.ID).Return(payment, nil)
+	m.sepaRepo.On("ReturnDebit", ctx, debit, payment).Return(invoice, nil)
+
+	result, err := svc.ImportReturns(ctx, strings.NewReader(sepaTestReturnFile), importedBy)
+
+	require.NoError(t, err)
+	require.Len(t, result.Returned, 1)
+	assert.Equal(t, invoice, result.Returned[0].Invoice)
+	assert.Equal(t, []string{"E2E-TWICE"}, result.AlreadyReturned)
+	assert.Equal(t, []string{"E2E-UNKNOWN"}, result.Unmatched)
+
+	assert.True(t, debit.IsReturned())
+	require.NotNil(t, debit.ReturnReason)
+	assert.Equal(t, "AM04", *debit.ReturnReason)
+	assert.True(t, payment.IsReversed())
+	assert.Equal(t, &importedBy, payment.ReversedBy)
+	assert.Equal(t, "Devolución de adeudo SEPA (AM04)", payment.ReversalReason)
+	m.sepaRepo.AssertNotCalled(t, "GetDebitByEndToEndID", mock.Anything, "E2E-ACCEPTED")
+
+	_, err = svc.ImportReturns(ctx, strings.NewReader("not a return file"), importedBy)
+	requireValidationError(t, err)
+}
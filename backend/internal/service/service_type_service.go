@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// CreateServiceTypeRequest represents the request to create a service type
+type CreateServiceTypeRequest struct {
+	Name         string                    `json:"name" binding:"required"`
+	Description  *string                   `json:"description,omitempty"`
+	DefaultPrice money.Money               `json:"defaultPrice"`                                // Cannot be negative
+	VATRate      *float64                  `json:"vatRate,omitempty" binding:"omitempty,gte=0"` // Defaults to the general rate (21%)
+	VATExemption *domain.VATExemptionCause `json:"vatExemption,omitempty"`                      // E.g. E1 for exempt health services
+}
+
+// UpdateServiceTypeRequest represents the request to update a service type
+type UpdateServiceTypeRequest struct {
+	Name         string                    `json:"name" binding:"required"`
+	Description  *string                   `json:"description,omitempty"`
+	DefaultPrice money.Money               `json:"defaultPrice"` // Cannot be negative
+	VATRate      float64                   `json:"vatRate" binding:"gte=0"`
+	VATExemption *domain.VATExemptionCause `json:"vatExemption,omitempty"`
+	IsActive     bool                      `json:"isActive"`
+}
+
+// ServiceTypeService handles the catalogue of billable services
+type ServiceTypeService interface {
+	// CreateServiceType creates a new service type
+	CreateServiceType(ctx context.Context, req *CreateServiceTypeRequest) (*domain.ServiceType, error)
+
+	// GetServiceType retrieves a service type by ID
+	GetServiceType(ctx context.Context, id uuid.UUID) (*domain.ServiceType, error)
+
+	// ListServiceTypes retrieves the service types, optionally only active ones
+	ListServiceTypes(ctx context.Context, activeOnly bool) ([]*domain.ServiceType, error)
+
+	// UpdateServiceType updates an existing service type
+	UpdateServiceType(ctx context.Context, id uuid.UUID, req *UpdateServiceTypeRequest) (*domain.ServiceType, error)
+
+	// DeleteServiceType soft deletes a service type
+	DeleteServiceType(ctx context.Context, id uuid.UUID) error
+}
+
+type serviceTypeService struct {
+	serviceTypeRepo repository.ServiceTypeRepository
+}
+
+// NewServiceTypeService creates a new service type service
+func NewServiceTypeService(serviceTypeRepo repository.ServiceTypeRepository) ServiceTypeService {
+	return &serviceTypeService{
+		serviceTypeRepo: serviceTypeRepo,
+	}
+}
+
+// CreateServiceType creates a new service type
+func (s *serviceTypeService) CreateServiceType(ctx context.Context, req *CreateServiceTypeRequest) (*domain.ServiceType, error) {
+	serviceType := &domain.ServiceType{
+		ID:           uuid.New(),
+		Name:         req.Name,
+		Description:  req.Description,
+		DefaultPrice: req.DefaultPrice,
+		VATRate:      domain.DefaultVATRate,
+		VATExemption: req.VATExemption,
+		IsActive:     true,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	if req.VATRate != nil {
+		serviceType.VATRate = *req.VATRate
+	} else if req.VATExemption != nil {
+		serviceType.VATRate = domain.VATRateZero
+	}
+
+	if err := serviceType.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.serviceTypeRepo.Create(ctx, serviceType); err != nil {
+		return nil, err
+	}
+
+	return serviceType, nil
+}
+
+// GetServiceType retrieves a service type by ID
+func (s *serviceTypeService) GetServiceType(ctx context.Context, id uuid.UUID) (*domain.ServiceType, error) {
+	return s.serviceTypeRepo.GetByID(ctx, id)
+}
+
+// ListServiceTypes retrieves the service types, optionally only active ones
+func (s *serviceTypeService) ListServiceTypes(ctx context.Context, activeOnly bool) ([]*domain.ServiceType, error) {
+	return s.serviceTypeRepo.List(ctx, activeOnly)
+}
+
+// UpdateServiceType updates an existing service type
+func (s *serviceTypeService) UpdateServiceType(ctx context.Context, id uuid.UUID, req *UpdateServiceTypeRequest) (*domain.ServiceType, error) {
+	serviceType, err := s.serviceTypeRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceType.Name = req.Name
+	serviceType.Description = req.Description
+	serviceType.DefaultPrice = req.DefaultPrice
+	serviceType.VATRate = req.VATRate
+	serviceType.VATExemption = req.VATExemption
+	serviceType.IsActive = req.IsActive
+	serviceType.UpdatedAt = time.Now()
+
+	if err := serviceType.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.serviceTypeRepo.Update(ctx, serviceType); err != nil {
+		return nil, fmt.Errorf("failed to update service type: %w", err)
+	}
+
+	return serviceType, nil
+}
+
+// DeleteServiceType soft deletes a service type; existing invoice lines keep their values
+func (s *serviceTypeService) DeleteServiceType(ctx context.Context, id uuid.UUID) error {
+	return s.serviceTypeRepo.Delete(ctx, id)
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+)
+
+// sessionPackConsumer pays completed appointments with the session packs of their clients
+type sessionPackConsumer struct {
+	sessionPackService SessionPackService
+	insurerRepo        repository.InsurerRepository
+}
+
+// NewSessionPackConsumer creates the transition hook that consumes a session of a pack when
+// an appointment is completed. It must run before the appointment invoicer, which skips the
+// appointments paid with a pack, and after the insurance coverage consumer, as appointments
+// covered by an insurer do not consume a pack.
+func NewSessionPackConsumer(sessionPackService SessionPackService, insurerRepo repository.InsurerRepository) AppointmentTransitionHook {
+	return &sessionPackConsumer{
+		sessionPackService: sessionPackService,
+		insurerRepo:        insurerRepo,
+	}
+}
+
+// OnAppointmentTransition consumes a session for an appointment that has just been completed
+func (h *sessionPackConsumer) OnAppointmentTransition(ctx context.Context, appointment *domain.Appointment, from domain.AppointmentStatus) error {
+	if appointment.Status != domain.AppointmentStatusCompleted || from == domain.AppointmentStatusCompleted {
+		return nil
+	}
+
+	_, err := h.insurerRepo.GetCoveredSession(ctx, appointment.ID)
+	if err == nil {
+		return nil
+	}
+	if !isNotFound(err) {
+		return fmt.Errorf("failed to get covered session: %w", err)
+	}
+
+	pack, err := h.sessionPackService.ConsumeSession(ctx, appointment)
+	switch err {
+	case nil:
+		log.Printf("[INFO] Appointment %s paid with pack %s (%d sessions left)", appointment.ID, pack.ID, pack.SessionsRemaining)
+		return nil
+	case domain.ErrNoUsableSessionPack, domain.ErrSessionAlreadyConsumed, domain.ErrSessionAlreadyInvoiced:
+		// Invoiced as usual, already paid with a pack or already invoiced, e.g. in advance
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// CreateSessionPackRequest represents the request to create a session pack
+type CreateSessionPackRequest struct {
+	Name          string                    `json:"name" binding:"required"`
+	Description   *string                   `json:"description,omitempty"`
+	ServiceTypeID *uuid.UUID                `json:"serviceTypeId,omitempty"` // Sessions it covers; any when not given
+	Sessions      int                       `json:"sessions" binding:"required,gt=0"`
+	Price         money.Money               `json:"price"`                                       // Whole pack, without VAT
+	VATRate       *float64                  `json:"vatRate,omitempty" binding:"omitempty,gte=0"` // Defaults to the general rate (21%)
+	VATExemption  *domain.VATExemptionCause `json:"vatExemption,omitempty"`
+	ValidityDays  int                       `json:"validityDays" binding:"required,gt=0"`
+}
+
+// UpdateSessionPackRequest represents the request to update a session pack; packs already
+// sold keep the sessions and expiry they were sold with
+type UpdateSessionPackRequest struct {
+	Name          string                    `json:"name" binding:"required"`
+	Description   *string                   `json:"description,omitempty"`
+	ServiceTypeID *uuid.UUID                `json:"serviceTypeId,omitempty"`
+	Sessions      int                       `json:"sessions" binding:"required,gt=0"`
+	Price         money.Money               `json:"price"`
+	VATRate       float64                   `json:"vatRate" binding:"gte=0"`
+	VATExemption  *domain.VATExemptionCause `json:"vatExemption,omitempty"`
+	ValidityDays  int                       `json:"validityDays" binding:"required,gt=0"`
+	IsActive      bool                      `json:"isActive"`
+}
+
+// SellSessionPackRequest represents the sale of a session pack to a client
+type SellSessionPackRequest struct {
+	SessionPackID uuid.UUID `json:"sessionPackId" binding:"required"`
+	Draft         bool      `json:"draft"` // Leaves the invoice of the sale as a draft
+}
+
+// SessionPackService handles prepaid session packs (bonos): the catalogue, their sale and
+// the sessions consumed by completed appointments
+type SessionPackService interface {
+	// CreateSessionPack creates a new session pack of the catalogue
+	CreateSessionPack(ctx context.Context, req *CreateSessionPackRequest) (*domain.SessionPack, error)
+
+	// GetSessionPack retrieves a session pack by ID
+	GetSessionPack(ctx context.Context, id uuid.UUID) (*domain.SessionPack, error)
+
+	// ListSessionPacks retrieves the session packs, optionally only active ones
+	ListSessionPacks(ctx context.Context, activeOnly bool) ([]*domain.SessionPack, error)
+
+	// UpdateSessionPack updates an existing session pack
+	UpdateSessionPack(ctx context.Context, id uuid.UUID, req *UpdateSessionPackRequest) (*domain.SessionPack, error)
+
+	// DeleteSessionPack soft deletes a session pack
+	DeleteSessionPack(ctx context.Context, id uuid.UUID) error
+
+	// SellSessionPack invoices a session pack to a client and records the sessions bought
+	SellSessionPack(ctx context.Context, clientID uuid.UUID, req *SellSessionPackRequest) (*domain.ClientSessionPack, error)
+
+	// GetClientBalance retrieves the packs of a client and the sessions left
+	GetClientBalance(ctx context.Context, clientID uuid.UUID) (*domain.SessionPackBalance, error)
+
+	// ConsumeSession pays a completed appointment with a session of a pack of its client
+	ConsumeSession(ctx context.Context, appointment *domain.Appointment) (*domain.ClientSessionPack, error)
+}
+
+type sessionPackService struct {
+	sessionPackRepo repository.SessionPackRepository
+	serviceTypeRepo repository.ServiceTypeRepository
+	invoiceService  InvoiceService
+	now             func() time.Time
+}
+
+// NewSessionPackService creates a new session pack service
+func NewSessionPackService(
+	sessionPackRepo repository.SessionPackRepository,
+	serviceTypeRepo repository.ServiceTypeRepository,
+	invoiceService InvoiceService,
+) SessionPackService {
+	return &sessionPackService{
+		sessionPackRepo: sessionPackRepo,
+		serviceTypeRepo: serviceTypeRepo,
+		invoiceService:  invoiceService,
+		now:             time.Now,
+	}
+}
+
+// CreateSessionPack creates a new session pack of the catalogue
+func (s *sessionPackService) CreateSessionPack(ctx context.Context, req *CreateSessionPackRequest) (*domain.SessionPack, error) {
+	pack := &domain.SessionPack{
+		ID:            uuid.New(),
+		Name:          req.Name,
+		Description:   req.Description,
+		ServiceTypeID: req.ServiceTypeID,
+		Sessions:      req.Sessions,
+		Price:         req.Price,
+		VATRate:       domain.DefaultVATRate,
+		VATExemption:  req.VATExemption,
+		ValidityDays:  req.ValidityDays,
+		IsActive:      true,
+		CreatedAt:     s.now(),
+		UpdatedAt:     s.now(),
+	}
+
+	if req.VATRate != nil {
+		pack.VATRate = *req.VATRate
+	} else if req.VATExemption != nil {
+		pack.VATRate = domain.VATRateZero
+	}
+
+	if err := s.validate(ctx, pack); err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionPackRepo.Create(ctx, pack); err != nil {
+		return nil, err
+	}
+
+	return pack, nil
+}
+
+// GetSessionPack retrieves a session pack by ID
+func (s *sessionPackService) GetSessionPack(ctx context.Context, id uuid.UUID) (*domain.SessionPack, error) {
+	return s.sessionPackRepo.GetByID(ctx, id)
+}
+
+// ListSessionPacks retrieves the session packs, optionally only active ones
+func (s *sessionPackService) ListSessionPacks(ctx context.Context, activeOnly bool) ([]*domain.SessionPack, error) {
+	return s.sessionPackRepo.List(ctx, activeOnly)
+}
+
+// UpdateSessionPack updates an existing session pack
+func (s *sessionPackService) UpdateSessionPack(ctx context.Context, id uuid.UUID, req *UpdateSessionPackRequest) (*domain.SessionPack, error) {
+	pack, err := s.sessionPackRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	pack.Name = req.Name
+	pack.Description = req.Description
+	pack.ServiceTypeID = req.ServiceTypeID
+	pack.Sessions = req.Sessions
+	pack.Price = req.Price
+	pack.VATRate = req.VATRate
+	pack.VATExemption = req.VATExemption
+	pack.ValidityDays = req.ValidityDays
+	pack.IsActive = req.IsActive
+	pack.UpdatedAt = s.now()
+
+	if err := s.validate(ctx, pack); err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionPackRepo.Update(ctx, pack); err != nil {
+		return nil, fmt.Errorf("failed to update session pack: %w", err)
+	}
+
+	return pack, nil
+}
+
+// validate checks the pack and that the service type it covers exists
+func (s *sessionPackService) validate(ctx context.Context, pack *domain.SessionPack) error {
+	if err := pack.Validate(); err != nil {
+		return err
+	}
+	if pack.ServiceTypeID != nil {
+		if _, err := s.serviceTypeRepo.GetByID(ctx, *pack.ServiceTypeID); err != nil {
+			return errors.NewValidationError("service type not found", map[string][]string{
+				"serviceTypeId": {"service type does not exist"},
+			})
+		}
+	}
+	return nil
+}
+
+// DeleteSessionPack soft deletes a session pack; clients keep the packs they bought
+func (s *sessionPackService) DeleteSessionPack(ctx context.Context, id uuid.UUID) error {
+	return s.sessionPackRepo.Delete(ctx, id)
+}
+
+// SellSessionPack invoices a session pack to a client and records the sessions bought.
+// Packs are paid when sold, so the invoice is due on its issue date.
+func (s *sessionPackService) SellSessionPack(ctx context.Context, clientID uuid.UUID, req *SellSessionPackRequest) (*domain.ClientSessionPack, error) {
+	pack, err := s.sessionPackRepo.GetByID(ctx, req.SessionPackID)
+	if err != nil {
+		return nil, err
+	}
+	if !pack.IsActive {
+		return nil, domain.ErrSessionPackInactive
+	}
+
+	now := s.now()
+	purchasedAt := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	expiresAt := purchasedAt.AddDate(0, 0, pack.ValidityDays)
+
+	price := pack.Price
+	vatRate := pack.VATRate
+	invoiceReq := &CreateInvoiceRequest{
+		ClientID:  clientID,
+		IssueDate: purchasedAt,
+		DueDate:   purchasedAt,
+		Lines: []InvoiceLineRequest{{
+			Description:  fmt.Sprintf("%s (%d sesiones)", pack.Name, pack.Sessions),
+			UnitPrice:    &price,
+			VATRate:      &vatRate,
+			VATExemption: pack.VATExemption,
+		}},
+		Notes: fmt.Sprintf("Bono válido hasta el %s", expiresAt.Format("02/01/2006")),
+	}
+
+	// The invoice is issued only once the pack is recorded, so a failure never leaves an
+	// issued invoice without the sessions it sold
+	invoice, err := s.invoiceService.CreateDraftInvoice(ctx, invoiceReq)
+	if err != nil {
+		return nil, err
+	}
+
+	clientPack := &domain.ClientSessionPack{
+		ID:                uuid.New(),
+		ClientID:          clientID,
+		SessionPackID:     pack.ID,
+		InvoiceID:         &invoice.ID,
+		Name:              pack.Name,
+		ServiceTypeID:     pack.ServiceTypeID,
+		SessionsTotal:     pack.Sessions,
+		SessionsRemaining: pack.Sessions,
+		PurchasedAt:       purchasedAt,
+		ExpiresAt:         expiresAt,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	if err := s.sessionPackRepo.CreateClientPack(ctx, clientPack); err != nil {
+		if deleteErr := s.invoiceService.DeleteInvoice(ctx, invoice.ID); deleteErr != nil {
+			log.Printf("[WARN] Failed to delete draft invoice %s of an unrecorded pack: %v", invoice.ID, deleteErr)
+		}
+		return nil, fmt.Errorf("failed to record client session pack: %w", err)
+	}
+
+	if !req.Draft {
+		// The pack keeps its draft invoice when it cannot be issued, to be issued later
+		if _, err := s.invoiceService.IssueInvoice(ctx, invoice.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return clientPack, nil
+}
+
+// GetClientBalance retrieves the packs of a client and the sessions left
+func (s *sessionPackService) GetClientBalance(ctx context.Context, clientID uuid.UUID) (*domain.SessionPackBalance, error) {
+	packs, err := s.sessionPackRepo.ListClientPacks(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.NewSessionPackBalance(clientID, packs, s.now()), nil
+}
+
+// ConsumeSession pays a completed appointment with a session of a pack of its client
+func (s *sessionPackService) ConsumeSession(ctx context.Context, appointment *domain.Appointment) (*domain.ClientSessionPack, error) {
+	if appointment.Status != domain.AppointmentStatusCompleted {
+		return nil, errors.NewValidationError("only completed appointments consume sessions", map[string][]string{
+			"status": {fmt.Sprintf("appointment is %s", appointment.Status)},
+		})
+	}
+
+	usage := &domain.SessionPackUsage{
+		ID:        uuid.New(),
+		CreatedAt: s.now(),
+	}
+
+	return s.sessionPackRepo.ConsumeSession(ctx, appointment, usage)
+}
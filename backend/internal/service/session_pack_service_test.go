@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockSessionPackRepository is a mock implementation of SessionPackRepository
+type MockSessionPackRepository struct {
+	mock.Mock
+}
+
+func (m *MockSessionPackRepository) Create(ctx context.Context, pack *domain.SessionPack) error {
+	args := m.Called(ctx, pack)
+	return args.Error(0)
+}
+
+func (m *MockSessionPackRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.SessionPack, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SessionPack), args.Error(1)
+}
+
+func (m *MockSessionPackRepository) List(ctx context.Context, activeOnly bool) ([]*domain.SessionPack, error) {
+	args := m.Called(ctx, activeOnly)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.SessionPack), args.Error(1)
+}
+
+func (m *MockSessionPackRepository) Update(ctx context.Context, pack *domain.SessionPack) error {
+	args := m.Called(ctx, pack)
+	return args.Error(0)
+}
+
+func (m *MockSessionPackRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockSessionPackRepository) CreateClientPack(ctx context.Context, clientPack *domain.ClientSessionPack) error {
+	args := m.Called(ctx, clientPack)
+	return args.Error(0)
+}
+
+func (m *MockSessionPackRepository) ListClientPacks(ctx context.Context, clientID uuid.UUID) ([]*domain.ClientSessionPack, error) {
+	args := m.Called(ctx, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.ClientSessionPack), args.Error(1)
+}
+
+func (m *MockSessionPackRepository) ConsumeSession(ctx context.Context, appointment *domain.Appointment, usage *domain.SessionPackUsage) (*domain.ClientSessionPack, error) {
+	args := m.Called(ctx, appointment, usage)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ClientSessionPack), args.Error(1)
+}
+
+func (m *MockSessionPackRepository) GetUsageByAppointment(ctx context.Context, appointmentID uuid.UUID) (*domain.SessionPackUsage, error) {
+	args := m.Called(ctx, appointmentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SessionPackUsage), args.Error(1)
+}
+
+// newNoUsageRepository is a session pack repository where no appointment consumed a session
+func newNoUsageRepository() *MockSessionPackRepository {
+	sessionPackRepo := new(MockSessionPackRepository)
+	sessionPackRepo.On("GetUsageByAppointment", mock.Anything, mock.Anything).
+		Return(nil, errors.NewNotFoundError("session pack usage not found")).Maybe()
+	return sessionPackRepo
+}
+
+func newSessionPackTestService(now time.Time) (*sessionPackService, *MockSessionPackRepository, *MockServiceTypeRepository, *MockInvoiceService) {
+	sessionPackRepo := new(MockSessionPackRepository)
+	serviceTypeRepo := new(MockServiceTypeRepository)
+	invoiceService := new(MockInvoiceService)
+
+	svc := NewSessionPackService(sessionPackRepo, serviceTypeRepo, invoiceService).(*sessionPackService)
+	svc.now = func() time.Time { return now }
+	return svc, sessionPackRepo, serviceTypeRepo, invoiceService
+}
+
+func testSessionPack() *domain.SessionPack {
+	exempt := domain.VATExemptArticle20
+	return &domain.SessionPack{
+		ID:           uuid.New(),
+		Name:         "Bono 10 sesiones",
+		Sessions:     10,
+		Price:        money.MustParse("500"),
+		VATRate:      domain.VATRateZero,
+		VATExemption: &exempt,
+		ValidityDays: 180,
+		IsActive:     true,
+	}
+}
+
+func TestSessionPackService_CreateSessionPack(t *testing.T) {
+	ctx := context.Background()
+	svc, sessionPackRepo, serviceTypeRepo, _ := newSessionPackTestService(time.Now())
+	sessionPackRepo.On("Create", ctx, mock.Anything).Return(nil)
+
+	pack, err := svc.CreateSessionPack(ctx, &CreateSessionPackRequest{
+		Name:         "Bono 5 sesiones",
+		Sessions:     5,
+		Price:        money.MustParse("275"),
+		ValidityDays: 90,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DefaultVATRate, pack.VATRate)
+	assert.True(t, pack.IsActive)
+
+	// Exempt packs default to a 0% rate
+	exempt := domain.VATExemptArticle20
+	pack, err = svc.CreateSessionPack(ctx, &CreateSessionPackRequest{
+		Name: "Bono 10 sesiones", Sessions: 10, Price: money.MustParse("500"), ValidityDays: 180, VATExemption: &exempt,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, domain.VATRateZero, pack.VATRate)
+
+	// The service type covered must exist
+	unknown := uuid.New()
+	serviceTypeRepo.On("GetByID", ctx, unknown).Return(nil, errors.NewNotFoundError("service type not found"))
+	_, err = svc.CreateSessionPack(ctx, &CreateSessionPackRequest{
+		Name: "Bono evaluación", Sessions: 3, ValidityDays: 30, ServiceTypeID: &unknown,
+	})
+	requireValidationError(t, err)
+	sessionPackRepo.AssertNumberOfCalls(t, "Create", 2)
+}
+
+func TestSessionPackService_SellSessionPack(t *testing.T) {
+	ctx := context.Background()
+	svc, sessionPackRepo, _, invoiceService := newSessionPackTestService(time.Date(2025, 3, 14, 17, 30, 0, 0, time.Local))
+
+	pack := testSessionPack()
+	clientID := uuid.New()
+	invoice := &domain.Invoice{ID: uuid.New(), InvoiceNumber: "F_2025_0012"}
+
+	sessionPackRepo.On("GetByID", ctx, pack.ID).Return(pack, nil)
+	invoiceService.On("CreateDraftInvoice", ctx, mock.MatchedBy(func(req *CreateInvoiceRequest) bool {
+		line := req.Lines[0]
+		return req.ClientID == clientID &&
+			req.IssueDate.Equal(time.Date(2025, 3, 14, 0, 0, 0, 0, time.Local)) &&
+			req.DueDate.Equal(req.IssueDate) &&
+			len(req.Lines) == 1 &&
+			line.Description == "Bono 10 sesiones (10 sesiones)" &&
+			line.UnitPrice.Decimal() == "500.00" &&
+			*line.VATExemption == domain.VATExemptArticle20 &&
+			req.Notes == "Bono válido hasta el 10/09/2025"
+	})).Return(invoice, nil)
+	// The invoice is issued once the pack is recorded
+	sessionPackRepo.On("CreateClientPack", ctx, mock.Anything).Return(nil).Once()
+	invoiceService.On("IssueInvoice", ctx, invoice.ID).Return(invoice, nil).Once().
+		Run(func(mock.Arguments) { sessionPackRepo.AssertNumberOfCalls(t, "CreateClientPack", 1) })
+
+	clientPack, err := svc.SellSessionPack(ctx, clientID, &SellSessionPackRequest{SessionPackID: pack.ID})
+
+	require.NoError(t, err)
+	assert.Equal(t, clientID, clientPack.ClientID)
+	assert.Equal(t, &invoice.ID, clientPack.InvoiceID)
+	assert.Equal(t, 10, clientPack.SessionsTotal)
+	assert.Equal(t, 10, clientPack.SessionsRemaining)
+	assert.Equal(t, time.Date(2025, 9, 10, 0, 0, 0, 0, time.Local), clientPack.ExpiresAt)
+	invoiceService.AssertExpectations(t)
+	invoiceService.AssertNotCalled(t, "CreateInvoice", mock.Anything, mock.Anything)
+}
+
+func TestSessionPackService_SellSessionPack_PackNotRecorded(t *testing.T) {
+	ctx := context.Background()
+	svc, sessionPackRepo, _, invoiceService := newSessionPackTestService(time.Now())
+
+	pack := testSessionPack()
+	invoice := &domain.Invoice{ID: uuid.New()}
+	sessionPackRepo.On("GetByID", ctx, pack.ID).Return(pack, nil)
+	invoiceService.On("CreateDraftInvoice", ctx, mock.Anything).Return(invoice, nil)
+	sessionPackRepo.On("CreateClientPack", ctx, mock.Anything).Return(assert.AnError)
+	invoiceService.On("DeleteInvoice", ctx, invoice.ID).Return(nil)
+
+	_, err := svc.SellSessionPack(ctx, uuid.New(), &SellSessionPackRequest{SessionPackID: pack.ID})
+
+	// Nothing is issued and the draft is discarded
+	assert.ErrorIs(t, err, assert.AnError)
+	invoiceService.AssertExpectations(t)
+	invoiceService.AssertNotCalled(t, "IssueInvoice", mock.Anything, mock.Anything)
+}
+
+func TestSessionPackService_SellSessionPack_Draft(t *testing.T) {
+	ctx := context.Background()
+	svc, sessionPackRepo, _, invoiceService := newSessionPackTestService(time.Now())
+
+	pack := testSessionPack()
+	sessionPackRepo.On("GetByID", ctx, pack.ID).Return(pack, nil)
+	invoiceService.On("CreateDraftInvoice", ctx, mock.Anything).Return(&domain.Invoice{ID: uuid.New()}, nil)
+	sessionPackRepo.On("CreateClientPack", ctx, mock.Anything).Return(nil)
+
+	_, err := svc.SellSessionPack(ctx, uuid.New(), &SellSessionPackRequest{SessionPackID: pack.ID, Draft: true})
+
+	require.NoError(t, err)
+	invoiceService.AssertNotCalled(t, "IssueInvoice", mock.Anything, mock.Anything)
+}
+
+func TestSessionPackService_SellSessionPack_Inactive(t *testing.T) {
+	ctx := context.Background()
+	svc, sessionPackRepo, _, invoiceService := newSessionPackTestService(time.Now())
+
+	pack := testSessionPack()
+	pack.IsActive = false
+	sessionPackRepo.On("GetByID", ctx, pack.ID).Return(pack, nil)
+
+	_, err := svc.SellSessionPack(ctx, uuid.New(), &SellSessionPackRequest{SessionPackID: pack.ID})
+
+	assert.Equal(t, domain.ErrSessionPackInactive, err)
+	invoiceService.AssertNotCalled(t, "CreateDraftInvoice", mock.Anything, mock.Anything)
+	sessionPackRepo.AssertNotCalled(t, "CreateClientPack", mock.Anything, mock.Anything)
+}
+
+func TestSessionPackService_GetClientBalance(t *testing.T) {
+	ctx := context.Background()
+	svc, sessionPackRepo, _, _ := newSessionPackTestService(time.Date(2025, 6, 1, 12, 0, 0, 0, time.Local))
+
+	clientID := uuid.New()
+	packs := []*domain.ClientSessionPack{
+		{ID: uuid.New(), SessionsTotal: 5, SessionsRemaining: 4, ExpiresAt: time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: uuid.New(), SessionsTotal: 10, SessionsRemaining: 2, ExpiresAt: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}, // Last day
+		{ID: uuid.New(), SessionsTotal: 5, SessionsRemaining: 3, ExpiresAt: time.Date(2025, 5, 31, 0, 0, 0, 0, time.UTC)}, // Expired
+		{ID: uuid.New(), SessionsTotal: 5, SessionsRemaining: 0, ExpiresAt: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)}, // Used up
+	}
+	sessionPackRepo.On("ListClientPacks", ctx, clientID).Return(packs, nil)
+
+	balance, err := svc.GetClientBalance(ctx, clientID)
+
+	require.NoError(t, err)
+	assert.Equal(t, 6, balance.SessionsRemaining)
+	require.NotNil(t, balance.NextExpiry)
+	assert.Equal(t, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), *balance.NextExpiry)
+	assert.Len(t, balance.Packs, 4)
+}
+
+func TestSessionPackConsumer_ConsumesCompletedAppointments(t *testing.T) {
+	ctx := context.Background()
+	svc, sessionPackRepo, _, _ := newSessionPackTestService(time.Now())
+	hook := NewSessionPackConsumer(svc, newNotCoveredRepository())
+
+	appointment := &domain.Appointment{ID: uuid.New(), ClientID: uuid.New(), Status: domain.AppointmentStatusCompleted}
+	sessionPackRepo.On("ConsumeSession", ctx, appointment, mock.Anything).
+		Return(&domain.ClientSessionPack{ID: uuid.New(), SessionsRemaining: 3}, nil).Once()
+
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusConfirmed))
+	sessionPackRepo.AssertExpectations(t)
+}
+
+func TestSessionPackConsumer_IgnoresClientsWithoutSessions(t *testing.T) {
+	ctx := context.Background()
+	svc, sessionPackRepo, _, _ := newSessionPackTestService(time.Now())
+	hook := NewSessionPackConsumer(svc, newNotCoveredRepository())
+
+	appointment := &domain.Appointment{ID: uuid.New(), ClientID: uuid.New(), Status: domain.AppointmentStatusCompleted}
+	sessionPackRepo.On("ConsumeSession", ctx, appointment, mock.Anything).Return(nil, domain.ErrNoUsableSessionPack).Once()
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusConfirmed))
+
+	sessionPackRepo.On("ConsumeSession", ctx, appointment, mock.Anything).Return(nil, domain.ErrSessionAlreadyConsumed).Once()
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusConfirmed))
+
+	// Invoiced before it was completed, e.g. paid in advance
+	sessionPackRepo.On("ConsumeSession", ctx, appointment, mock.Anything).Return(nil, domain.ErrSessionAlreadyInvoiced).Once()
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, appointment, domain.AppointmentStatusConfirmed))
+
+	// Other transitions consume nothing
+	noShow := &domain.Appointment{ID: uuid.New(), Status: domain.AppointmentStatusNoShow}
+	assert.NoError(t, hook.OnAppointmentTransition(ctx, noShow, domain.AppointmentStatusConfirmed))
+	sessionPackRepo.AssertNumberOfCalls(t, "ConsumeSession", 3)
+}
@@ -0,0 +1,539 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/repository"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+// TaxModel identifies a quarterly tax return
+type TaxModel string
+
+const (
+	TaxModel303 TaxModel = "303" // IVA: autoliquidación trimestral
+	TaxModel130 TaxModel = "130" // IRPF: pago fraccionado de actividades económicas
+)
+
+// IsValid returns true if the model is supported
+func (m TaxModel) IsValid() bool {
+	return m == TaxModel303 || m == TaxModel130
+}
+
+// Kinds of documents behind a box
+const (
+	TaxDocumentInvoice = "invoice"
+	TaxDocumentExpense = "expense"
+)
+
+// irpfInstallmentRate is the percentage of the net income paid on account in Modelo 130
+const irpfInstallmentRate float64 = 20
+
+// TaxReportQuery selects the return and quarter of a tax report
+type TaxReportQuery struct {
+	Model   TaxModel
+	Year    int
+	Quarter int // 1 to 4
+}
+
+// TaxReport holds the boxes (casillas) of a return as working papers for filing it
+type TaxReport struct {
+	Model    TaxModel       `json:"model"`
+	Year     int            `json:"year"`
+	Quarter  int            `json:"quarter"`
+	FromDate time.Time      `json:"fromDate"` // Modelo 130 is cumulative from the 1st of January
+	ToDate   time.Time      `json:"toDate"`
+	Boxes    []TaxReportBox `json:"boxes"`
+}
+
+// TaxReportBox is a box of the return and the documents its amount comes from
+type TaxReportBox struct {
+	Box           string              `json:"box"` // Number of the box on the form
+	Description   string              `json:"description"`
+	Amount        money.Money         `json:"amount"`
+	DocumentCount int                 `json:"documentCount"`
+	Documents     []TaxReportDocument `json:"documents,omitempty"` // Only when drilling down into the box
+}
+
+// TaxReportDocument is an invoice or expense counted in a box. Invoices appear once per
+// VAT rate they include.
+type TaxReportDocument struct {
+	Kind   string      `json:"kind"` // invoice or expense
+	ID     uuid.UUID   `json:"id"`
+	Number string      `json:"number,omitempty"`
+	Date   time.Time   `json:"date"`
+	Party  string      `json:"party"` // Client or supplier
+	TaxID  string      `json:"taxId,omitempty"`
+	Base   money.Money `json:"base"`
+	VAT    money.Money `json:"vat"`
+	Amount money.Money `json:"amount"` // What the document adds to the box
+}
+
+// TaxReportService prepares the working papers of the quarterly returns (Modelo 303 for VAT
+// and Modelo 130 for IRPF) from the issued invoices and the recorded expenses
+type TaxReportService interface {
+	// GetTaxReport computes the boxes of a return for a quarter
+	GetTaxReport(ctx context.Context, query TaxReportQuery) (*TaxReport, error)
+
+	// GetTaxReportBox computes a box of a return with the documents behind its amount
+	GetTaxReportBox(ctx context.Context, query TaxReportQuery, box string) (*TaxReportBox, error)
+
+	// ExportTaxReportCSV exports the boxes of a return as CSV
+	ExportTaxReportCSV(ctx context.Context, query TaxReportQuery) (*CSVExport, error)
+
+	// ExportTaxReportBoxCSV exports the documents behind a box as CSV
+	ExportTaxReportBoxCSV(ctx context.Context, query TaxReportQuery, box string) (*CSVExport, error)
+}
+
+type taxReportService struct {
+	invoiceRepo repository.InvoiceRepository
+	expenseRepo repository.ExpenseRepository
+}
+
+// NewTaxReportService creates a new tax report service
+func NewTaxReportService(invoiceRepo repository.InvoiceRepository, expenseRepo repository.ExpenseRepository) TaxReportService {
+	return &taxReportService{
+		invoiceRepo: invoiceRepo,
+		expenseRepo: expenseRepo,
+	}
+}
+
+// GetTaxReport computes the boxes of a return for a quarter
+func (s *taxReportService) GetTaxReport(ctx context.Context, query TaxReportQuery) (*TaxReport, error) {
+	report, err := s.buildReport(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range report.Boxes {
+		report.Boxes[i].Documents = nil
+	}
+
+	return report, nil
+}
+
+// GetTaxReportBox computes a box of a return with the documents behind its amount
+func (s *taxReportService) GetTaxReportBox(ctx context.Context, query TaxReportQuery, box string) (*TaxReportBox, error) {
+	report, err := s.buildReport(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range report.Boxes {
+		if report.Boxes[i].Box == box {
+			found := report.Boxes[i]
+			if found.Documents == nil {
+				found.Documents = []TaxReportDocument{}
+			}
+			return &found, nil
+		}
+	}
+
+	return nil, errors.NewNotFoundError(fmt.Sprintf("box %s is not part of Modelo %s", box, query.Model))
+}
+
+// ExportTaxReportCSV exports the boxes of a return as CSV
+func (s *taxReportService) ExportTaxReportCSV(ctx context.Context, query TaxReportQuery) (*CSVExport, error) {
+	report, err := s.GetTaxReport(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(report.Boxes))
+	for _, box := range report.Boxes {
+		rows = append(rows, []string{box.Box, box.Description, box.Amount.Decimal(), fmt.Sprint(box.DocumentCount)})
+	}
+
+	fileName := fmt.Sprintf("modelo%s_%d_%dT.csv", query.Model, query.Year, query.Quarter)
+	return newCSVExport(fileName, []string{"Casilla", "Concepto", "Importe", "Documentos"}, rows)
+}
+
+// ExportTaxReportBoxCSV exports the documents behind a box as CSV
+func (s *taxReportService) ExportTaxReportBoxCSV(ctx context.Context, query TaxReportQuery, box string) (*CSVExport, error) {
+	reportBox, err := s.GetTaxReportBox(ctx, query, box)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(reportBox.Documents))
+	for _, doc := range reportBox.Documents {
+		rows = append(rows, []string{
+			documentKindLabel(doc.Kind), doc.Number, doc.Date.Format("2006-01-02"), doc.Party, doc.TaxID,
+			doc.Base.Decimal(), doc.VAT.Decimal(), doc.Amount.Decimal(),
+		})
+	}
+
+	fileName := fmt.Sprintf("modelo%s_%d_%dT_casilla_%s.csv", query.Model, query.Year, query.Quarter, box)
+	header := []string{"Tipo", "Número", "Fecha", "Tercero", "NIF", "Base imponible", "Cuota IVA", "Importe casilla"}
+	return newCSVExport(fileName, header, rows)
+}
+
+func documentKindLabel(kind string) string {
+	if kind == TaxDocumentExpense {
+		return "Gasto"
+	}
+	return "Factura"
+}
+
+// buildReport validates the query and computes every box with its documents
+func (s *taxReportService) buildReport(ctx context.Context, query TaxReportQuery) (*TaxReport, error) {
+	if err := validateTaxReportQuery(query); err != nil {
+		return nil, err
+	}
+
+	from, to := quarterRange(query.Year, query.Quarter)
+	report := &TaxReport{Model: query.Model, Year: query.Year, Quarter: query.Quarter, FromDate: from, ToDate: to}
+
+	var boxes *taxBoxSet
+	var err error
+	if query.Model == TaxModel303 {
+		boxes, err = s.modelo303(ctx, from, to)
+	} else {
+		report.FromDate = time.Date(query.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		boxes, err = s.modelo130(ctx, query.Year, query.Quarter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report.Boxes = boxes.list()
+	return report, nil
+}
+
+func validateTaxReportQuery(query TaxReportQuery) error {
+	details := map[string][]string{}
+	if !query.Model.IsValid() {
+		details["model"] = []string{"must be 303 or 130"}
+	}
+	if query.Year < 2000 || query.Year > 2100 {
+		details["year"] = []string{"must be between 2000 and 2100"}
+	}
+	if query.Quarter < 1 || query.Quarter > 4 {
+		details["quarter"] = []string{"must be between 1 and 4"}
+	}
+	if len(details) > 0 {
+		return errors.NewValidationError("invalid tax report query", details)
+	}
+	return nil
+}
+
+// quarterRange returns the first and last day of a quarter
+func quarterRange(year, quarter int) (time.Time, time.Time) {
+	from := time.Date(year, time.Month(3*(quarter-1)+1), 1, 0, 0, 0, 0, time.UTC)
+	return from, from.AddDate(0, 3, -1)
+}
+
+// VAT boxes of Modelo 303 (régimen general) by rate: base and quota
+var modelo303RateBoxes = map[float64][2]string{
+	domain.VATRateSuperReduced: {"01", "03"},
+	domain.VATRateReduced:      {"04", "06"},
+	domain.VATRateGeneral:      {"07", "09"},
+	domain.VATRateZero:         {"150", "152"},
+}
+
+// Box of the working papers for exempt operations; it is not filed as such but shows the
+// base left out of the VAT boxes
+const modelo303ExemptBox = "exempt"
+
+// modelo303 computes the output VAT of the invoices issued in the quarter by rate, the
+// input VAT of the deductible expenses and the result. Rectifying invoices subtract.
+func (s *taxReportService) modelo303(ctx context.Context, from, to time.Time) (*taxBoxSet, error) {
+	invoices, err := s.invoiceRepo.GetIssuedByDateRange(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	expenses, err := s.expenseRepo.ListByDateRange(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	boxes := newTaxBoxSet(
+		taxBoxSpec{"01", "Régimen general 4%: base imponible"},
+		taxBoxSpec{"03", "Régimen general 4%: cuota"},
+		taxBoxSpec{"04", "Régimen general 10%: base imponible"},
+		taxBoxSpec{"06", "Régimen general 10%: cuota"},
+		taxBoxSpec{"07", "Régimen general 21%: base imponible"},
+		taxBoxSpec{"09", "Régimen general 21%: cuota"},
+		taxBoxSpec{"150", "Régimen general 0%: base imponible"},
+		taxBoxSpec{"152", "Régimen general 0%: cuota"},
+		taxBoxSpec{"27", "Total cuota devengada"},
+		taxBoxSpec{"28", "Cuotas soportadas en operaciones interiores corrientes: base"},
+		taxBoxSpec{"29", "Cuotas soportadas en operaciones interiores corrientes: cuota"},
+		taxBoxSpec{"45", "Total a deducir"},
+		taxBoxSpec{"46", "Resultado régimen general"},
+		taxBoxSpec{modelo303ExemptBox, "Operaciones exentas: base imponible (informativa)"},
+	)
+
+	for _, invoice := range invoices {
+		breakdown, err := invoice.TaxBreakdown()
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range breakdown {
+			doc := invoiceTaxDocument(invoice)
+			doc.Base, doc.VAT = group.BaseAmount, group.VATAmount
+
+			if group.IsExempt() {
+				if err := boxes.add(modelo303ExemptBox, doc, doc.Base); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			rateBoxes, ok := modelo303RateBoxes[group.VATRate]
+			if !ok {
+				return nil, fmt.Errorf("invoice %s has an unsupported VAT rate %v", invoice.InvoiceNumber, group.VATRate)
+			}
+			if err := boxes.add(rateBoxes[0], doc, doc.Base); err != nil {
+				return nil, err
+			}
+			if err := boxes.add(rateBoxes[1], doc, doc.VAT); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, expense := range expenses {
+		if !expense.VATDeductible || expense.VATAmount.IsZero() {
+			continue
+		}
+		doc, err := expenseTaxDocument(expense)
+		if err != nil {
+			return nil, err
+		}
+		if err := boxes.add("28", doc, doc.Base); err != nil {
+			return nil, err
+		}
+		if err := boxes.add("29", doc, doc.VAT); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := boxes.sum("27", "03", "06", "09", "152"); err != nil {
+		return nil, err
+	}
+	if err := boxes.sum("45", "29"); err != nil {
+		return nil, err
+	}
+	if err := boxes.difference("46", "27", "45"); err != nil {
+		return nil, err
+	}
+
+	return boxes, nil
+}
+
+// modelo130 computes the installment of a quarter. The figures are cumulative from the
+// start of the year, so the installments of the previous quarters are computed as well to
+// fill box 05.
+func (s *taxReportService) modelo130(ctx context.Context, year, quarter int) (*taxBoxSet, error) {
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	_, to := quarterRange(year, quarter)
+
+	invoices, err := s.invoiceRepo.GetIssuedByDateRange(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	expenses, err := s.expenseRepo.ListByDateRange(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var boxes *taxBoxSet
+	previous := money.Money{}
+	for q := 1; q <= quarter; q++ {
+		_, until := quarterRange(year, q)
+		if boxes, err = modelo130Boxes(invoices, expenses, until, previous); err != nil {
+			return nil, err
+		}
+
+		if result := boxes.amount("07"); result.IsPositive() {
+			if previous, err = previous.Add(result); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return boxes, nil
+}
+
+// modelo130Boxes computes the boxes with the documents dated up to a quarter end, given
+// what was paid in the previous quarters
+func modelo130Boxes(invoices []*domain.Invoice, expenses []*domain.Expense, until time.Time, previous money.Money) (*taxBoxSet, error) {
+	boxes := newTaxBoxSet(
+		taxBoxSpec{"01", "Ingresos computables"},
+		taxBoxSpec{"02", "Gastos fiscalmente deducibles"},
+		taxBoxSpec{"03", "Rendimiento neto"},
+		taxBoxSpec{"04", "20% del rendimiento neto positivo"},
+		taxBoxSpec{"05", "Pagos fraccionados de trimestres anteriores"},
+		taxBoxSpec{"06", "Retenciones e ingresos a cuenta soportados"},
+		taxBoxSpec{"07", "Resultado del pago fraccionado"},
+	)
+
+	for _, invoice := range invoices {
+		if invoice.IssueDate.After(until) {
+			continue
+		}
+		doc := invoiceTaxDocument(invoice)
+		doc.Base, doc.VAT = invoice.BaseAmount, invoice.VATAmount
+
+		if err := boxes.add("01", doc, doc.Base); err != nil {
+			return nil, err
+		}
+		if !invoice.IRPFAmount.IsZero() {
+			if err := boxes.add("06", doc, invoice.IRPFAmount); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, expense := range expenses {
+		if expense.ExpenseDate.After(until) || !expense.IRPFDeductible {
+			continue
+		}
+		doc, err := expenseTaxDocument(expense)
+		if err != nil {
+			return nil, err
+		}
+		deductible, err := expense.IRPFDeductibleAmount()
+		if err != nil {
+			return nil, fmt.Errorf("expense %s: %w", expense.ID, err)
+		}
+		if err := boxes.add("02", doc, deductible); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := boxes.difference("03", "01", "02"); err != nil {
+		return nil, err
+	}
+	if net := boxes.amount("03"); net.IsPositive() {
+		boxes.set("04", net.Percent(irpfInstallmentRate))
+	}
+	boxes.set("05", previous)
+
+	result, err := boxes.amount("04").Sub(previous)
+	if err != nil {
+		return nil, err
+	}
+	if result, err = result.Sub(boxes.amount("06")); err != nil {
+		return nil, err
+	}
+	boxes.set("07", result)
+
+	return boxes, nil
+}
+
+func invoiceTaxDocument(invoice *domain.Invoice) TaxReportDocument {
+	doc := TaxReportDocument{
+		Kind:   TaxDocumentInvoice,
+		ID:     invoice.ID,
+		Number: invoice.InvoiceNumber,
+		Date:   invoice.IssueDate,
+	}
+	if invoice.Client != nil || invoice.HasPayer() {
+		recipient := invoice.Recipient(invoice.Client)
+		doc.Party = recipient.Name
+		doc.TaxID = recipient.TaxID
+	}
+	return doc
+}
+
+func expenseTaxDocument(expense *domain.Expense) (TaxReportDocument, error) {
+	base, err := expense.BaseAmount()
+	if err != nil {
+		return TaxReportDocument{}, fmt.Errorf("expense %s: %w", expense.ID, err)
+	}
+
+	doc := TaxReportDocument{
+		Kind:  TaxDocumentExpense,
+		ID:    expense.ID,
+		Date:  expense.ExpenseDate,
+		Party: expense.Supplier,
+		Base:  base,
+		VAT:   expense.VATAmount,
+	}
+	if expense.SupplierInvoice != nil {
+		doc.Number = *expense.SupplierInvoice
+	}
+	return doc, nil
+}
+
+type taxBoxSpec struct {
+	box         string
+	description string
+}
+
+// taxBoxSet keeps the boxes of a return in the order of the form
+type taxBoxSet struct {
+	boxes []*TaxReportBox
+	byBox map[string]*TaxReportBox
+}
+
+func newTaxBoxSet(specs ...taxBoxSpec) *taxBoxSet {
+	set := &taxBoxSet{byBox: make(map[string]*TaxReportBox, len(specs))}
+	for _, spec := range specs {
+		box := &TaxReportBox{Box: spec.box, Description: spec.description}
+		set.boxes = append(set.boxes, box)
+		set.byBox[spec.box] = box
+	}
+	return set
+}
+
+// add counts a document in a box with the amount it contributes
+func (s *taxBoxSet) add(box string, doc TaxReportDocument, amount money.Money) error {
+	b := s.byBox[box]
+	total, err := b.Amount.Add(amount)
+	if err != nil {
+		return err
+	}
+	b.Amount = total
+	doc.Amount = amount
+	b.Documents = append(b.Documents, doc)
+	b.DocumentCount++
+	return nil
+}
+
+func (s *taxBoxSet) set(box string, amount money.Money) {
+	s.byBox[box].Amount = amount
+}
+
+func (s *taxBoxSet) amount(box string) money.Money {
+	return s.byBox[box].Amount
+}
+
+// sum sets a box to the sum of other boxes
+func (s *taxBoxSet) sum(box string, terms ...string) error {
+	amounts := make([]money.Money, 0, len(terms))
+	for _, term := range terms {
+		amounts = append(amounts, s.amount(term))
+	}
+	total, err := money.Sum(amounts...)
+	if err != nil {
+		return err
+	}
+	s.set(box, total)
+	return nil
+}
+
+// difference sets a box to the amount of a box minus another
+func (s *taxBoxSet) difference(box, minuend, subtrahend string) error {
+	result, err := s.amount(minuend).Sub(s.amount(subtrahend))
+	if err != nil {
+		return err
+	}
+	s.set(box, result)
+	return nil
+}
+
+func (s *taxBoxSet) list() []TaxReportBox {
+	boxes := make([]TaxReportBox, 0, len(s.boxes))
+	for _, box := range s.boxes {
+		boxes = append(boxes, *box)
+	}
+	return boxes
+}
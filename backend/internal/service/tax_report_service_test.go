@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/internal/domain"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/errors"
+	"github.com/gaston-garcia-cegid/arnela/backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTaxReportTestService() (TaxReportService, *MockInvoiceRepository, *MockExpenseRepository) {
+	invoiceRepo := new(MockInvoiceRepository)
+	expenseRepo := new(MockExpenseRepository)
+	return NewTaxReportService(invoiceRepo, expenseRepo), invoiceRepo, expenseRepo
+}
+
+func taxLine(unitPrice string, vatRate float64, exemption *domain.VATExemptionCause) *domain.InvoiceLine {
+	return &domain.InvoiceLine{
+		Description:  "Sesión",
+		Quantity:     1,
+		UnitPrice:    money.MustParse(unitPrice),
+		VATRate:      vatRate,
+		VATExemption: exemption,
+	}
+}
+
+func taxInvoice(t *testing.T, number string, issueDate time.Time, irpfRate float64, lines ...*domain.InvoiceLine) *domain.Invoice {
+	t.Helper()
+
+	invoice := &domain.Invoice{
+		ID:            uuid.New(),
+		InvoiceNumber: number,
+		ClientID:      uuid.New(),
+		IssueDate:     issueDate,
+		IRPFRate:      irpfRate,
+		Status:        domain.InvoiceStatusUnpaid,
+		InvoiceType:   domain.InvoiceTypeOrdinary,
+		Lines:         lines,
+		Client:        &domain.Client{FirstName: "Lucía", LastName: "Martín", DNICIF: "12345678Z"},
+	}
+	require.NoError(t, invoice.CalculateAmounts())
+	return invoice
+}
+
+func taxExpense(date time.Time, amount, vatAmount string, vatDeductible, irpfDeductible bool) *domain.Expense {
+	return &domain.Expense{
+		ID:             uuid.New(),
+		ExpenseDate:    date,
+		Supplier:       "Inmobiliaria Sol",
+		Amount:         money.MustParse(amount),
+		VATAmount:      money.MustParse(vatAmount),
+		VATDeductible:  vatDeductible,
+		IRPFDeductible: irpfDeductible,
+	}
+}
+
+func boxAmounts(report *TaxReport) map[string]string {
+	amounts := make(map[string]string, len(report.Boxes))
+	for _, box := range report.Boxes {
+		amounts[box.Box] = box.Amount.Decimal()
+	}
+	return amounts
+}
+
+func TestTaxReportService_Modelo303_BoxesByRate(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, expenseRepo := newTaxReportTestService()
+	from, to := utcDay(2025, 4, 1), utcDay(2025, 6, 30)
+	exempt := domain.VATExemptArticle20
+
+	rectifying := taxInvoice(t, "R_2025_0001", utcDay(2025, 6, 2), 0, taxLine("-20", 21, nil))
+	rectifying.InvoiceType = domain.InvoiceTypeRectifying
+
+	invoiceRepo.On("GetIssuedByDateRange", ctx, from, to).Return([]*domain.Invoice{
+		taxInvoice(t, "F_2025_0010", utcDay(2025, 4, 7), 0, taxLine("100", 21, nil), taxLine("200", 0, &exempt)),
+		taxInvoice(t, "F_2025_0011", utcDay(2025, 5, 12), 0, taxLine("50", 10, nil)),
+		rectifying,
+	}, nil)
+	expenseRepo.On("ListByDateRange", ctx, from, to).Return([]*domain.Expense{
+		taxExpense(utcDay(2025, 4, 1), "121", "21", true, true),
+		taxExpense(utcDay(2025, 5, 3), "55", "5", false, true),
+	}, nil)
+
+	report, err := svc.GetTaxReport(ctx, TaxReportQuery{Model: TaxModel303, Year: 2025, Quarter: 2})
+
+	require.NoError(t, err)
+	assert.Equal(t, from, report.FromDate)
+	assert.Equal(t, to, report.ToDate)
+
+	amounts := boxAmounts(report)
+	assert.Equal(t, "80.00", amounts["07"])
+	assert.Equal(t, "16.80", amounts["09"])
+	assert.Equal(t, "50.00", amounts["04"])
+	assert.Equal(t, "5.00", amounts["06"])
+	assert.Equal(t, "0.00", amounts["01"])
+	assert.Equal(t, "200.00", amounts[modelo303ExemptBox])
+	assert.Equal(t, "21.80", amounts["27"])
+	assert.Equal(t, "100.00", amounts["28"])
+	assert.Equal(t, "21.00", amounts["29"])
+	assert.Equal(t, "21.00", amounts["45"])
+	assert.Equal(t, "0.80", amounts["46"])
+
+	for _, box := range report.Boxes {
+		assert.Nil(t, box.Documents, "box %s should not list documents", box.Box)
+	}
+	assert.Equal(t, 2, report.Boxes[5].DocumentCount) // box 09
+}
+
+func TestTaxReportService_Modelo130_CumulativeWithPreviousInstallments(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, expenseRepo := newTaxReportTestService()
+	from, to := utcDay(2025, 1, 1), utcDay(2025, 6, 30)
+
+	invoiceRepo.On("GetIssuedByDateRange", ctx, from, to).Return([]*domain.Invoice{
+		taxInvoice(t, "F_2025_0001", utcDay(2025, 2, 10), 15, taxLine("1000", 21, nil)),
+		taxInvoice(t, "F_2025_0002", utcDay(2025, 5, 20), 0, taxLine("2000", 21, nil)),
+	}, nil)
+	expenseRepo.On("ListByDateRange", ctx, from, to).Return([]*domain.Expense{
+		taxExpense(utcDay(2025, 3, 1), "242", "42", true, true),   // 200 once its VAT is deducted
+		taxExpense(utcDay(2025, 4, 1), "242", "42", false, true),  // 242: the VAT is a cost
+		taxExpense(utcDay(2025, 4, 15), "100", "0", false, false), // Not deductible for IRPF
+	}, nil)
+
+	report, err := svc.GetTaxReport(ctx, TaxReportQuery{Model: TaxModel130, Year: 2025, Quarter: 2})
+
+	require.NoError(t, err)
+	assert.Equal(t, from, report.FromDate)
+
+	// The first quarter paid 20% of 800 minus the 150 withheld: 10
+	amounts := boxAmounts(report)
+	assert.Equal(t, "3000.00", amounts["01"])
+	assert.Equal(t, "442.00", amounts["02"])
+	assert.Equal(t, "2558.00", amounts["03"])
+	assert.Equal(t, "511.60", amounts["04"])
+	assert.Equal(t, "10.00", amounts["05"])
+	assert.Equal(t, "150.00", amounts["06"])
+	assert.Equal(t, "351.60", amounts["07"])
+}
+
+func TestTaxReportService_Modelo130_NegativeNetIncome(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, expenseRepo := newTaxReportTestService()
+
+	invoiceRepo.On("GetIssuedByDateRange", ctx, mock.Anything, mock.Anything).Return([]*domain.Invoice{
+		taxInvoice(t, "F_2025_0001", utcDay(2025, 1, 10), 0, taxLine("300", 21, nil)),
+	}, nil)
+	expenseRepo.On("ListByDateRange", ctx, mock.Anything, mock.Anything).Return([]*domain.Expense{
+		taxExpense(utcDay(2025, 1, 2), "500", "0", false, true),
+	}, nil)
+
+	report, err := svc.GetTaxReport(ctx, TaxReportQuery{Model: TaxModel130, Year: 2025, Quarter: 1})
+
+	require.NoError(t, err)
+	amounts := boxAmounts(report)
+	assert.Equal(t, "-200.00", amounts["03"])
+	assert.Equal(t, "0.00", amounts["04"])
+	assert.Equal(t, "0.00", amounts["07"])
+}
+
+func TestTaxReportService_ExpenseInAnotherCurrencyFails(t *testing.T) {
+	ctx := context.Background()
+
+	// The VAT cannot be taken from the amount, so the deductions are not silently zero
+	expense := taxExpense(utcDay(2025, 2, 3), "121", "0", true, true)
+	expense.VATAmount = money.New(2100, "USD")
+
+	for _, model := range []TaxModel{TaxModel303, TaxModel130} {
+		svc, invoiceRepo, expenseRepo := newTaxReportTestService()
+		invoiceRepo.On("GetIssuedByDateRange", ctx, mock.Anything, mock.Anything).Return([]*domain.Invoice{}, nil)
+		expenseRepo.On("ListByDateRange", ctx, mock.Anything, mock.Anything).Return([]*domain.Expense{expense}, nil)
+
+		_, err := svc.GetTaxReport(ctx, TaxReportQuery{Model: model, Year: 2025, Quarter: 1})
+
+		assert.ErrorIs(t, err, money.ErrCurrencyMismatch, model)
+	}
+}
+
+func TestTaxReportService_GetTaxReportBox_DrillDown(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, expenseRepo := newTaxReportTestService()
+	query := TaxReportQuery{Model: TaxModel303, Year: 2025, Quarter: 1}
+
+	invoice := taxInvoice(t, "F_2025_0001", utcDay(2025, 1, 10), 0, taxLine("100", 21, nil), taxLine("40", 21, nil))
+	invoiceRepo.On("GetIssuedByDateRange", ctx, utcDay(2025, 1, 1), utcDay(2025, 3, 31)).Return([]*domain.Invoice{invoice}, nil)
+	expenseRepo.On("ListByDateRange", ctx, mock.Anything, mock.Anything).Return([]*domain.Expense{}, nil)
+
+	box, err := svc.GetTaxReportBox(ctx, query, "09")
+	require.NoError(t, err)
+	assert.Equal(t, "29.40", box.Amount.Decimal())
+	require.Len(t, box.Documents, 1)
+	doc := box.Documents[0]
+	assert.Equal(t, TaxDocumentInvoice, doc.Kind)
+	assert.Equal(t, invoice.ID, doc.ID)
+	assert.Equal(t, "Lucía Martín", doc.Party)
+	assert.Equal(t, "12345678Z", doc.TaxID)
+	assert.Equal(t, "140.00", doc.Base.Decimal())
+	assert.Equal(t, "29.40", doc.Amount.Decimal())
+
+	empty, err := svc.GetTaxReportBox(ctx, query, "28")
+	require.NoError(t, err)
+	assert.NotNil(t, empty.Documents)
+	assert.Empty(t, empty.Documents)
+
+	_, err = svc.GetTaxReportBox(ctx, query, "99")
+	require.Error(t, err)
+	appErr, ok := err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, errors.CodeNotFound, appErr.Code)
+}
+
+func TestTaxReportService_ExportCSV(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, expenseRepo := newTaxReportTestService()
+	query := TaxReportQuery{Model: TaxModel303, Year: 2025, Quarter: 1}
+
+	invoiceRepo.On("GetIssuedByDateRange", ctx, mock.Anything, mock.Anything).Return([]*domain.Invoice{
+		taxInvoice(t, "F_2025_0001", utcDay(2025, 1, 10), 0, taxLine("100", 21, nil)),
+	}, nil)
+	expenseRepo.On("ListByDateRange", ctx, mock.Anything, mock.Anything).Return([]*domain.Expense{}, nil)
+
+	export, err := svc.ExportTaxReportCSV(ctx, query)
+	require.NoError(t, err)
+	assert.Equal(t, "modelo303_2025_1T.csv", export.FileName)
+	rows := strings.Split(strings.TrimSpace(string(export.Content)), "\n")
+	assert.Equal(t, "Casilla,Concepto,Importe,Documentos", rows[0])
+	assert.Contains(t, rows, "09,Régimen general 21%: cuota,21.00,1")
+
+	boxExport, err := svc.ExportTaxReportBoxCSV(ctx, query, "07")
+	require.NoError(t, err)
+	assert.Equal(t, "modelo303_2025_1T_casilla_07.csv", boxExport.FileName)
+	rows = strings.Split(strings.TrimSpace(string(boxExport.Content)), "\n")
+	require.Len(t, rows, 2)
+	assert.Equal(t, "Factura,F_2025_0001,2025-01-10,Lucía Martín,12345678Z,100.00,21.00,100.00", rows[1])
+}
+
+func TestTaxReportService_InvalidQuery(t *testing.T) {
+	ctx := context.Background()
+	svc, invoiceRepo, _ := newTaxReportTestService()
+
+	_, err := svc.GetTaxReport(ctx, TaxReportQuery{Model: "111", Year: 2025, Quarter: 1})
+	requireValidationError(t, err)
+
+	_, err = svc.GetTaxReport(ctx, TaxReportQuery{Model: TaxModel303, Year: 2025, Quarter: 5})
+	requireValidationError(t, err)
+
+	_, err = svc.GetTaxReportBox(ctx, TaxReportQuery{Model: TaxModel130, Year: 1999, Quarter: 1}, "01")
+	requireValidationError(t, err)
+
+	invoiceRepo.AssertNotCalled(t, "GetIssuedByDateRange", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS update_appointment_charges_updated_at ON appointment_charges;
+DROP TABLE IF EXISTS appointment_charges;
+
+-- Draft invoices cannot be represented without the new status
+DELETE FROM invoices WHERE status = 'draft';
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_status_check;
+ALTER TABLE invoices ADD CONSTRAINT invoices_status_check CHECK (status IN ('paid', 'unpaid'));
+
+-- Note: PostgreSQL cannot drop an enum value; 'no_show' remains in appointment_status
+UPDATE appointments SET status = 'cancelled' WHERE status = 'no_show';
@@ -0,0 +1,41 @@
+-- Add no_show to the appointment status enum
+ALTER TYPE appointment_status ADD VALUE IF NOT EXISTS 'no_show';
+
+-- Allow draft invoices (created automatically, pending admin review)
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_status_check;
+ALTER TABLE invoices ADD CONSTRAINT invoices_status_check CHECK (status IN ('draft', 'paid', 'unpaid'));
+
+-- Create appointment_charges table for late-cancellation and no-show fees
+CREATE TABLE IF NOT EXISTS appointment_charges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    appointment_id UUID NOT NULL REFERENCES appointments(id) ON DELETE RESTRICT,
+    client_id UUID NOT NULL REFERENCES clients(id) ON DELETE RESTRICT,
+    kind VARCHAR(30) NOT NULL CHECK (kind IN ('late_cancellation', 'no_show')),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'waived', 'issued')),
+    invoice_id UUID REFERENCES invoices(id) ON DELETE SET NULL,
+    waived_by UUID REFERENCES users(id),
+    waive_reason TEXT,
+    waived_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_appointment_charges_appointment_kind UNIQUE (appointment_id, kind)
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_appointment_charges_client_id ON appointment_charges(client_id);
+CREATE INDEX IF NOT EXISTS idx_appointment_charges_status ON appointment_charges(status);
+CREATE INDEX IF NOT EXISTS idx_appointment_charges_invoice_id ON appointment_charges(invoice_id);
+
+-- Trigger for updated_at
+DROP TRIGGER IF EXISTS update_appointment_charges_updated_at ON appointment_charges;
+CREATE TRIGGER update_appointment_charges_updated_at
+BEFORE UPDATE ON appointment_charges
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Comments for documentation
+COMMENT ON TABLE appointment_charges IS 'Fees raised automatically for late cancellations and no-shows';
+COMMENT ON COLUMN appointment_charges.kind IS 'Charge type: late_cancellation or no_show';
+COMMENT ON COLUMN appointment_charges.status IS 'pending (draft invoice awaiting review), waived or issued';
+COMMENT ON COLUMN appointment_charges.invoice_id IS 'Draft invoice created for the charge';
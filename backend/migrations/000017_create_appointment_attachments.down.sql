@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_appointment_attachments_updated_at ON appointment_attachments;
+DROP TABLE IF EXISTS appointment_attachments;
@@ -0,0 +1,30 @@
+-- Create appointment_attachments table for files shared around a session
+CREATE TABLE IF NOT EXISTS appointment_attachments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    appointment_id UUID NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL, -- Original file name shown to users
+    content_type VARCHAR(100) NOT NULL, -- Sniffed MIME type
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    storage_key VARCHAR(500) NOT NULL UNIQUE, -- Key in the storage backend
+    visibility VARCHAR(20) NOT NULL DEFAULT 'staff_only' CHECK (visibility IN ('staff_only', 'client')),
+    uploaded_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_appointment_attachments_appointment_id ON appointment_attachments(appointment_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_appointment_attachments_uploaded_by ON appointment_attachments(uploaded_by);
+
+-- Trigger for updated_at
+DROP TRIGGER IF EXISTS update_appointment_attachments_updated_at ON appointment_attachments;
+CREATE TRIGGER update_appointment_attachments_updated_at
+BEFORE UPDATE ON appointment_attachments
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Comments for documentation
+COMMENT ON TABLE appointment_attachments IS 'Files attached to appointments (worksheets, reports, referral letters)';
+COMMENT ON COLUMN appointment_attachments.visibility IS 'staff_only (internal) or client (shared with the appointment client)';
+COMMENT ON COLUMN appointment_attachments.storage_key IS 'Object key in the configured storage backend (local or S3)';
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_service_types_updated_at ON service_types;
+DROP TABLE IF EXISTS service_types;
@@ -0,0 +1,27 @@
+-- Create service_types table: catalogue of billable services (sessions, reports...)
+CREATE TABLE IF NOT EXISTS service_types (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(150) NOT NULL,
+    description TEXT,
+    default_price DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (default_price >= 0), -- Unit price without VAT
+    vat_rate DECIMAL(5,2) NOT NULL DEFAULT 21.00 CHECK (vat_rate >= 0),
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+
+-- Indexes
+CREATE UNIQUE INDEX IF NOT EXISTS idx_service_types_name ON service_types(LOWER(name)) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_service_types_is_active ON service_types(is_active);
+
+-- Trigger for updated_at
+DROP TRIGGER IF EXISTS update_service_types_updated_at ON service_types;
+CREATE TRIGGER update_service_types_updated_at
+BEFORE UPDATE ON service_types
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Comments for documentation
+COMMENT ON TABLE service_types IS 'Catalogue of billable services used to prefill invoice lines';
+COMMENT ON COLUMN service_types.default_price IS 'Default unit price before VAT';
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_invoice_lines_updated_at ON invoice_lines;
+DROP TABLE IF EXISTS invoice_lines;
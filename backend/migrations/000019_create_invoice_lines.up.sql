@@ -0,0 +1,47 @@
+-- Create invoice_lines table: the concepts billed in an invoice
+CREATE TABLE IF NOT EXISTS invoice_lines (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL, -- Order of the line in the invoice (1-based)
+    description TEXT NOT NULL,
+    quantity DECIMAL(10,2) NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    unit_price DECIMAL(10,2) NOT NULL CHECK (unit_price >= 0), -- Without VAT
+    discount_percent DECIMAL(5,2) NOT NULL DEFAULT 0 CHECK (discount_percent >= 0 AND discount_percent <= 100),
+    vat_rate DECIMAL(5,2) NOT NULL DEFAULT 21.00,
+    base_amount DECIMAL(10,2) NOT NULL, -- quantity * unit_price - discount
+    vat_amount DECIMAL(10,2) NOT NULL,
+    total_amount DECIMAL(10,2) NOT NULL,
+    appointment_id UUID REFERENCES appointments(id) ON DELETE SET NULL,
+    service_type_id UUID REFERENCES service_types(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (invoice_id, position)
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_invoice_lines_invoice_id ON invoice_lines(invoice_id);
+CREATE INDEX IF NOT EXISTS idx_invoice_lines_appointment_id ON invoice_lines(appointment_id);
+CREATE INDEX IF NOT EXISTS idx_invoice_lines_service_type_id ON invoice_lines(service_type_id);
+
+-- Trigger for updated_at
+DROP TRIGGER IF EXISTS update_invoice_lines_updated_at ON invoice_lines;
+CREATE TRIGGER update_invoice_lines_updated_at
+BEFORE UPDATE ON invoice_lines
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Existing invoices become single-line invoices
+INSERT INTO invoice_lines (
+    invoice_id, position, description, quantity, unit_price, discount_percent,
+    vat_rate, base_amount, vat_amount, total_amount, appointment_id, created_at, updated_at
+)
+SELECT
+    id, 1, description, 1, base_amount, 0,
+    vat_rate, base_amount, vat_amount, total_amount, appointment_id, created_at, updated_at
+FROM invoices
+WHERE NOT EXISTS (SELECT 1 FROM invoice_lines l WHERE l.invoice_id = invoices.id);
+
+-- Comments for documentation
+COMMENT ON TABLE invoice_lines IS 'Invoice lines; invoice totals are the sum of their lines';
+COMMENT ON COLUMN invoice_lines.discount_percent IS 'Discount applied to quantity * unit_price (0-100)';
+COMMENT ON COLUMN invoice_lines.vat_rate IS 'VAT rate percentage applied to the line';
@@ -0,0 +1,11 @@
+-- Corrected VAT rates and amounts of drafts and service types are kept: the previous values were wrong
+ALTER TABLE service_types DROP CONSTRAINT IF EXISTS service_types_vat_rate_check;
+ALTER TABLE invoice_lines DROP CONSTRAINT IF EXISTS invoice_lines_vat_exemption_check;
+ALTER TABLE invoice_lines DROP CONSTRAINT IF EXISTS invoice_lines_vat_rate_check;
+
+UPDATE invoices SET total_amount = total_amount + irpf_amount WHERE irpf_amount <> 0;
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS irpf_amount;
+ALTER TABLE invoices DROP COLUMN IF EXISTS irpf_rate;
+ALTER TABLE service_types DROP COLUMN IF EXISTS vat_exemption_cause;
+ALTER TABLE invoice_lines DROP COLUMN IF EXISTS vat_exemption_cause;
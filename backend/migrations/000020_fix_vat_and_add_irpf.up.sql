@@ -0,0 +1,69 @@
+-- Tax model: per-line VAT rates (21/10/4/0), VAT exemptions and IRPF withholding
+
+-- VAT exemption cause (AEAT codes E1-E6) on lines and service types
+ALTER TABLE invoice_lines ADD COLUMN IF NOT EXISTS vat_exemption_cause VARCHAR(2)
+    CHECK (vat_exemption_cause IN ('E1', 'E2', 'E3', 'E4', 'E5', 'E6'));
+ALTER TABLE service_types ADD COLUMN IF NOT EXISTS vat_exemption_cause VARCHAR(2)
+    CHECK (vat_exemption_cause IN ('E1', 'E2', 'E3', 'E4', 'E5', 'E6'));
+
+-- IRPF withholding on invoices addressed to businesses
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS irpf_rate DECIMAL(5,2) NOT NULL DEFAULT 0
+    CHECK (irpf_rate >= 0 AND irpf_rate <= 100);
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS irpf_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+
+-- The application stored the VAT rate as a fraction (0.21) in columns holding a
+-- percentage, so invoices were computed with 0.21% VAT. Store it as 21 in the service
+-- catalogue and recompute draft invoices: per line, then per rate group for each invoice.
+-- Issued invoices keep the amounts they were sent with, so paid invoices stay settled;
+-- those charged with the wrong VAT are corrected with rectifying invoices.
+UPDATE service_types
+SET vat_rate = vat_rate * 100
+WHERE vat_rate > 0 AND vat_rate < 1;
+
+UPDATE invoice_lines l
+SET vat_rate = l.vat_rate * 100
+FROM invoices i
+WHERE i.id = l.invoice_id AND i.status = 'draft'
+  AND l.vat_rate > 0 AND l.vat_rate < 1;
+
+UPDATE invoice_lines l
+SET vat_amount = ROUND(l.base_amount * l.vat_rate / 100, 2),
+    total_amount = l.base_amount + ROUND(l.base_amount * l.vat_rate / 100, 2)
+FROM invoices i
+WHERE i.id = l.invoice_id AND i.status = 'draft';
+
+UPDATE invoices
+SET vat_rate = vat_rate * 100
+WHERE status = 'draft' AND vat_rate > 0 AND vat_rate < 1;
+
+UPDATE invoices i
+SET base_amount = totals.base_amount,
+    vat_amount = totals.vat_amount,
+    total_amount = totals.base_amount + totals.vat_amount - i.irpf_amount
+FROM (
+    SELECT invoice_id,
+           SUM(group_base) AS base_amount,
+           SUM(CASE WHEN vat_exemption_cause IS NULL THEN ROUND(group_base * vat_rate / 100, 2) ELSE 0 END) AS vat_amount
+    FROM (
+        SELECT invoice_id, vat_rate, vat_exemption_cause, SUM(base_amount) AS group_base
+        FROM invoice_lines
+        GROUP BY invoice_id, vat_rate, vat_exemption_cause
+    ) groups
+    GROUP BY invoice_id
+) totals
+WHERE totals.invoice_id = i.id AND i.status = 'draft';
+
+-- Only Spanish VAT rates are accepted from now on (issued invoices keep their rate);
+-- exempt lines carry no VAT
+ALTER TABLE invoice_lines ADD CONSTRAINT invoice_lines_vat_rate_check
+    CHECK (vat_rate IN (0, 4, 10, 21)) NOT VALID;
+ALTER TABLE invoice_lines ADD CONSTRAINT invoice_lines_vat_exemption_check
+    CHECK (vat_exemption_cause IS NULL OR vat_rate = 0);
+ALTER TABLE service_types ADD CONSTRAINT service_types_vat_rate_check
+    CHECK (vat_rate IN (0, 4, 10, 21)) NOT VALID;
+
+-- Comments for documentation
+COMMENT ON COLUMN invoice_lines.vat_exemption_cause IS 'VAT exemption cause (E1 = art. 20 LIVA, e.g. psychology services)';
+COMMENT ON COLUMN invoices.irpf_rate IS 'IRPF withholding percentage (invoices to businesses)';
+COMMENT ON COLUMN invoices.irpf_amount IS 'IRPF withheld, deducted from total_amount';
+COMMENT ON COLUMN invoices.vat_rate IS 'VAT rate percentage shared by all lines (0 when lines use different rates)';
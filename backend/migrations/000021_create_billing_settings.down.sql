@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_billing_settings_updated_at ON billing_settings;
+DROP TABLE IF EXISTS billing_settings;
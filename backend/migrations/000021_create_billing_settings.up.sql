@@ -0,0 +1,36 @@
+-- Create billing_settings table: the clinic's fiscal data and invoice template (single row)
+CREATE TABLE IF NOT EXISTS billing_settings (
+    id SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    legal_name VARCHAR(200) NOT NULL DEFAULT '', -- Razón social
+    trade_name VARCHAR(200) NOT NULL DEFAULT '', -- Nombre comercial
+    tax_id VARCHAR(20) NOT NULL DEFAULT '', -- NIF/CIF of the issuer
+    address_street VARCHAR(255) NOT NULL DEFAULT '',
+    address_city VARCHAR(100) NOT NULL DEFAULT '',
+    address_province VARCHAR(100) NOT NULL DEFAULT '',
+    address_postal_code VARCHAR(10) NOT NULL DEFAULT '',
+    address_country VARCHAR(2) NOT NULL DEFAULT 'ES',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    phone VARCHAR(30) NOT NULL DEFAULT '',
+    website VARCHAR(255) NOT NULL DEFAULT '',
+    iban VARCHAR(34) NOT NULL DEFAULT '', -- Account shown for bank transfers
+    registry_info TEXT NOT NULL DEFAULT '', -- Mercantile registry or professional association number
+    logo_key VARCHAR(500), -- Logo object key in the storage backend
+    logo_content_type VARCHAR(100),
+    footer_text TEXT NOT NULL DEFAULT '',
+    accent_color VARCHAR(7) NOT NULL DEFAULT '#1f4e79' CHECK (accent_color ~ '^#[0-9a-fA-F]{6}$'),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO billing_settings (id) VALUES (1) ON CONFLICT (id) DO NOTHING;
+
+-- Trigger for updated_at
+DROP TRIGGER IF EXISTS update_billing_settings_updated_at ON billing_settings;
+CREATE TRIGGER update_billing_settings_updated_at
+BEFORE UPDATE ON billing_settings
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Comments for documentation
+COMMENT ON TABLE billing_settings IS 'Issuer fiscal data and customisable invoice template (logo, footer, accent color)';
+COMMENT ON COLUMN billing_settings.registry_info IS 'Legal mention such as the mercantile registry entry or the professional association number';
+COMMENT ON COLUMN invoices.pdf_path IS 'Storage key of the cached PDF; it embeds a fingerprint of the rendered data';
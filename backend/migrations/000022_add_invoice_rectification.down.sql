@@ -0,0 +1,18 @@
+DROP TRIGGER IF EXISTS prevent_issued_invoice_line_changes ON invoice_lines;
+DROP FUNCTION IF EXISTS prevent_issued_invoice_line_changes();
+DROP TRIGGER IF EXISTS prevent_issued_invoice_changes ON invoices;
+DROP FUNCTION IF EXISTS prevent_issued_invoice_changes();
+
+-- Rectifying invoices cannot be represented without the new columns
+DELETE FROM invoices WHERE invoice_type = 'rectifying';
+
+ALTER TABLE invoice_lines DROP CONSTRAINT IF EXISTS invoice_lines_quantity_check;
+ALTER TABLE invoice_lines ADD CONSTRAINT invoice_lines_quantity_check CHECK (quantity > 0);
+ALTER TABLE invoice_lines ADD CONSTRAINT invoice_lines_unit_price_check CHECK (unit_price >= 0);
+
+DROP INDEX IF EXISTS idx_invoices_rectified_invoice_id;
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_rectification_check;
+ALTER TABLE invoices DROP COLUMN IF EXISTS rectification_reason;
+ALTER TABLE invoices DROP COLUMN IF EXISTS rectification_mode;
+ALTER TABLE invoices DROP COLUMN IF EXISTS rectified_invoice_id;
+ALTER TABLE invoices DROP COLUMN IF EXISTS invoice_type;
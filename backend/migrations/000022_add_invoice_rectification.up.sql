@@ -0,0 +1,124 @@
+-- Draft -> issued lifecycle: issued invoices are immutable and are corrected
+-- with rectifying invoices (facturas rectificativas) in their own series
+
+-- Invoice type and reference to the rectified invoice
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS invoice_type VARCHAR(20) NOT NULL DEFAULT 'ordinary'
+    CHECK (invoice_type IN ('ordinary', 'rectifying'));
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS rectified_invoice_id UUID REFERENCES invoices(id) ON DELETE RESTRICT;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS rectification_mode VARCHAR(20)
+    CHECK (rectification_mode IN ('cancellation', 'difference'));
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS rectification_reason TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE invoices ADD CONSTRAINT invoices_rectification_check CHECK (
+    (invoice_type = 'rectifying') = (rectified_invoice_id IS NOT NULL AND rectification_mode IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_rectified_invoice_id ON invoices(rectified_invoice_id)
+    WHERE rectified_invoice_id IS NOT NULL;
+
+-- Rectifying invoices subtract from the original with negative quantities or prices
+ALTER TABLE invoice_lines DROP CONSTRAINT IF EXISTS invoice_lines_quantity_check;
+ALTER TABLE invoice_lines ADD CONSTRAINT invoice_lines_quantity_check CHECK (quantity <> 0);
+ALTER TABLE invoice_lines DROP CONSTRAINT IF EXISTS invoice_lines_unit_price_check;
+
+-- Once issued, only the payment status, payment method and cached PDF of an invoice may change
+CREATE OR REPLACE FUNCTION prevent_issued_invoice_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' OR NEW.deleted_at IS DISTINCT FROM OLD.deleted_at THEN
+        RAISE EXCEPTION 'issued invoices are immutable: invoice % cannot be deleted', OLD.invoice_number;
+    END IF;
+
+    IF NEW.status = 'draft'
+        OR NEW.invoice_number IS DISTINCT FROM OLD.invoice_number
+        OR NEW.client_id IS DISTINCT FROM OLD.client_id
+        OR NEW.issue_date IS DISTINCT FROM OLD.issue_date
+        OR NEW.due_date IS DISTINCT FROM OLD.due_date
+        OR NEW.description IS DISTINCT FROM OLD.description
+        OR NEW.base_amount IS DISTINCT FROM OLD.base_amount
+        OR NEW.vat_rate IS DISTINCT FROM OLD.vat_rate
+        OR NEW.vat_amount IS DISTINCT FROM OLD.vat_amount
+        OR NEW.irpf_rate IS DISTINCT FROM OLD.irpf_rate
+        OR NEW.irpf_amount IS DISTINCT FROM OLD.irpf_amount
+        OR NEW.total_amount IS DISTINCT FROM OLD.total_amount
+        OR NEW.notes IS DISTINCT FROM OLD.notes
+        OR NEW.invoice_type IS DISTINCT FROM OLD.invoice_type
+        OR NEW.rectified_invoice_id IS DISTINCT FROM OLD.rectified_invoice_id
+        OR NEW.rectification_mode IS DISTINCT FROM OLD.rectification_mode
+        OR NEW.rectification_reason IS DISTINCT FROM OLD.rectification_reason
+    THEN
+        RAISE EXCEPTION 'issued invoices are immutable: invoice % cannot be modified', OLD.invoice_number;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS prevent_issued_invoice_changes ON invoices;
+CREATE TRIGGER prevent_issued_invoice_changes
+BEFORE UPDATE OR DELETE ON invoices
+FOR EACH ROW
+EXECUTE FUNCTION prevent_issued_invoice_changes();
+
+-- The lines of an issued invoice cannot be added, removed or changed
+CREATE OR REPLACE FUNCTION prevent_issued_invoice_line_changes()
+RETURNS TRIGGER AS $$
+DECLARE
+    line_invoice_id UUID;
+    invoice_status VARCHAR(20);
+    number VARCHAR(50);
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        line_invoice_id := NEW.invoice_id;
+    ELSE
+        line_invoice_id := OLD.invoice_id;
+    END IF;
+
+    -- Allow ON DELETE SET NULL of appointments and service types on issued lines
+    IF TG_OP = 'UPDATE'
+        AND NEW.invoice_id = OLD.invoice_id
+        AND NEW.position = OLD.position
+        AND NEW.description = OLD.description
+        AND NEW.quantity = OLD.quantity
+        AND NEW.unit_price = OLD.unit_price
+        AND NEW.discount_percent = OLD.discount_percent
+        AND NEW.vat_rate = OLD.vat_rate
+        AND NEW.vat_exemption_cause IS NOT DISTINCT FROM OLD.vat_exemption_cause
+        AND NEW.base_amount = OLD.base_amount
+        AND NEW.vat_amount = OLD.vat_amount
+        AND NEW.total_amount = OLD.total_amount
+    THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT status, invoice_number INTO invoice_status, number FROM invoices WHERE id = line_invoice_id;
+    IF FOUND AND invoice_status <> 'draft' THEN
+        RAISE EXCEPTION 'issued invoices are immutable: lines of invoice % cannot be modified', number;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS prevent_issued_invoice_line_changes ON invoice_lines;
+CREATE TRIGGER prevent_issued_invoice_line_changes
+BEFORE INSERT OR UPDATE OR DELETE ON invoice_lines
+FOR EACH ROW
+EXECUTE FUNCTION prevent_issued_invoice_line_changes();
+
+-- Comments for documentation
+COMMENT ON COLUMN invoices.invoice_type IS 'ordinary or rectifying (factura rectificativa, numbered in the R series)';
+COMMENT ON COLUMN invoices.rectified_invoice_id IS 'Invoice corrected by this rectifying invoice';
+COMMENT ON COLUMN invoices.rectification_mode IS 'cancellation (anulación total) or difference (rectificación por diferencias)';
+COMMENT ON COLUMN invoices.rectification_reason IS 'Reason for the rectification printed on the invoice';
+COMMENT ON COLUMN invoices.status IS 'draft (editable, no number), unpaid or paid; issued invoices are immutable';
@@ -0,0 +1,58 @@
+-- Restore the trigger of 000022, without the series columns
+CREATE OR REPLACE FUNCTION prevent_issued_invoice_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' OR NEW.deleted_at IS DISTINCT FROM OLD.deleted_at THEN
+        RAISE EXCEPTION 'issued invoices are immutable: invoice % cannot be deleted', OLD.invoice_number;
+    END IF;
+
+    IF NEW.status = 'draft'
+        OR NEW.invoice_number IS DISTINCT FROM OLD.invoice_number
+        OR NEW.client_id IS DISTINCT FROM OLD.client_id
+        OR NEW.issue_date IS DISTINCT FROM OLD.issue_date
+        OR NEW.due_date IS DISTINCT FROM OLD.due_date
+        OR NEW.description IS DISTINCT FROM OLD.description
+        OR NEW.base_amount IS DISTINCT FROM OLD.base_amount
+        OR NEW.vat_rate IS DISTINCT FROM OLD.vat_rate
+        OR NEW.vat_amount IS DISTINCT FROM OLD.vat_amount
+        OR NEW.irpf_rate IS DISTINCT FROM OLD.irpf_rate
+        OR NEW.irpf_amount IS DISTINCT FROM OLD.irpf_amount
+        OR NEW.total_amount IS DISTINCT FROM OLD.total_amount
+        OR NEW.notes IS DISTINCT FROM OLD.notes
+        OR NEW.invoice_type IS DISTINCT FROM OLD.invoice_type
+        OR NEW.rectified_invoice_id IS DISTINCT FROM OLD.rectified_invoice_id
+        OR NEW.rectification_mode IS DISTINCT FROM OLD.rectification_mode
+        OR NEW.rectification_reason IS DISTINCT FROM OLD.rectification_reason
+    THEN
+        RAISE EXCEPTION 'issued invoices are immutable: invoice % cannot be modified', OLD.invoice_number;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Simplified invoices cannot be represented without their type
+ALTER TABLE invoices DISABLE TRIGGER prevent_issued_invoice_changes;
+UPDATE invoices SET invoice_type = 'ordinary' WHERE invoice_type = 'simplified';
+ALTER TABLE invoices ENABLE TRIGGER prevent_issued_invoice_changes;
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_invoice_type_check;
+ALTER TABLE invoices ADD CONSTRAINT invoices_invoice_type_check
+    CHECK (invoice_type IN ('ordinary', 'rectifying'));
+
+DROP INDEX IF EXISTS idx_invoices_series_sequence;
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_sequence_check;
+ALTER TABLE invoices DROP COLUMN IF EXISTS sequence_number;
+ALTER TABLE invoices DROP COLUMN IF EXISTS number_year;
+ALTER TABLE invoices DROP COLUMN IF EXISTS series_id;
+
+DROP TABLE IF EXISTS invoice_series_counters;
+DROP TRIGGER IF EXISTS update_invoice_series_updated_at ON invoice_series;
+DROP TABLE IF EXISTS invoice_series;
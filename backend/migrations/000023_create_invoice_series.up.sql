@@ -0,0 +1,144 @@
+-- Invoice numbering series: each series has its own prefix and gap-free counter.
+-- Numbers are assigned when an invoice is issued, inside the transaction that
+-- stores it, so two invoices can never share a number nor leave a hole.
+CREATE TABLE IF NOT EXISTS invoice_series (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(10) NOT NULL CHECK (prefix ~ '^[A-Z0-9]+$'),
+    invoice_type VARCHAR(20) NOT NULL CHECK (invoice_type IN ('ordinary', 'rectifying', 'simplified')),
+    location VARCHAR(100), -- Clinic location using the series, NULL for all of them
+    is_default BOOLEAN NOT NULL DEFAULT false,
+    yearly_reset BOOLEAN NOT NULL DEFAULT true,
+    padding SMALLINT NOT NULL DEFAULT 4 CHECK (padding BETWEEN 1 AND 10),
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoice_series_prefix ON invoice_series(prefix);
+
+-- At most one default series per invoice type and location
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoice_series_default
+    ON invoice_series(invoice_type, COALESCE(location, ''))
+    WHERE is_default;
+
+DROP TRIGGER IF EXISTS update_invoice_series_updated_at ON invoice_series;
+CREATE TRIGGER update_invoice_series_updated_at
+BEFORE UPDATE ON invoice_series
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Last number issued in each series and year (year 0 for series without yearly reset).
+-- The row is locked while an invoice is being issued.
+CREATE TABLE IF NOT EXISTS invoice_series_counters (
+    series_id UUID NOT NULL REFERENCES invoice_series(id) ON DELETE CASCADE,
+    year INTEGER NOT NULL,
+    last_number INTEGER NOT NULL DEFAULT 0 CHECK (last_number >= 0),
+    PRIMARY KEY (series_id, year)
+);
+
+-- Default series, matching the numbers issued so far
+INSERT INTO invoice_series (name, prefix, invoice_type, is_default) VALUES
+    ('Facturas ordinarias', 'F', 'ordinary', true),
+    ('Facturas rectificativas', 'R', 'rectifying', true),
+    ('Facturas simplificadas', 'S', 'simplified', true)
+ON CONFLICT DO NOTHING;
+
+-- Simplified invoices (tickets) are a third invoice type
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_invoice_type_check;
+ALTER TABLE invoices ADD CONSTRAINT invoices_invoice_type_check
+    CHECK (invoice_type IN ('ordinary', 'rectifying', 'simplified'));
+
+-- Series and position of each invoice in it
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS series_id UUID REFERENCES invoice_series(id) ON DELETE RESTRICT;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS number_year INTEGER;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS sequence_number INTEGER CHECK (sequence_number > 0);
+
+ALTER TABLE invoices ADD CONSTRAINT invoices_sequence_check CHECK (
+    (sequence_number IS NULL) = (number_year IS NULL)
+    AND (sequence_number IS NULL OR series_id IS NOT NULL)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_series_sequence
+    ON invoices(series_id, number_year, sequence_number)
+    WHERE sequence_number IS NOT NULL;
+
+-- Backfill: F_YYYY_NNNN and R_YYYY_NNNN belong to the default series of their type.
+-- The trigger protecting issued invoices is disabled while the history is annotated.
+ALTER TABLE invoices DISABLE TRIGGER prevent_issued_invoice_changes;
+
+UPDATE invoices i SET
+    series_id = s.id,
+    number_year = CAST(SPLIT_PART(i.invoice_number, '_', 2) AS INTEGER),
+    sequence_number = CAST(SPLIT_PART(i.invoice_number, '_', 3) AS INTEGER)
+FROM invoice_series s
+WHERE s.is_default
+    AND s.invoice_type = i.invoice_type
+    AND i.invoice_number ~ ('^' || s.prefix || '_[0-9]{4}_[0-9]+$');
+
+UPDATE invoices i SET series_id = s.id
+FROM invoice_series s
+WHERE i.series_id IS NULL AND i.status = 'draft'
+    AND s.is_default AND s.invoice_type = i.invoice_type;
+
+ALTER TABLE invoices ENABLE TRIGGER prevent_issued_invoice_changes;
+
+INSERT INTO invoice_series_counters (series_id, year, last_number)
+SELECT series_id, number_year, MAX(sequence_number)
+FROM invoices
+WHERE sequence_number IS NOT NULL
+GROUP BY series_id, number_year
+ON CONFLICT (series_id, year) DO UPDATE SET last_number = EXCLUDED.last_number;
+
+-- The series and number of an issued invoice cannot change either
+CREATE OR REPLACE FUNCTION prevent_issued_invoice_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' OR NEW.deleted_at IS DISTINCT FROM OLD.deleted_at THEN
+        RAISE EXCEPTION 'issued invoices are immutable: invoice % cannot be deleted', OLD.invoice_number;
+    END IF;
+
+    IF NEW.status = 'draft'
+        OR NEW.invoice_number IS DISTINCT FROM OLD.invoice_number
+        OR NEW.client_id IS DISTINCT FROM OLD.client_id
+        OR NEW.issue_date IS DISTINCT FROM OLD.issue_date
+        OR NEW.due_date IS DISTINCT FROM OLD.due_date
+        OR NEW.description IS DISTINCT FROM OLD.description
+        OR NEW.base_amount IS DISTINCT FROM OLD.base_amount
+        OR NEW.vat_rate IS DISTINCT FROM OLD.vat_rate
+        OR NEW.vat_amount IS DISTINCT FROM OLD.vat_amount
+        OR NEW.irpf_rate IS DISTINCT FROM OLD.irpf_rate
+        OR NEW.irpf_amount IS DISTINCT FROM OLD.irpf_amount
+        OR NEW.total_amount IS DISTINCT FROM OLD.total_amount
+        OR NEW.notes IS DISTINCT FROM OLD.notes
+        OR NEW.invoice_type IS DISTINCT FROM OLD.invoice_type
+        OR NEW.rectified_invoice_id IS DISTINCT FROM OLD.rectified_invoice_id
+        OR NEW.rectification_mode IS DISTINCT FROM OLD.rectification_mode
+        OR NEW.rectification_reason IS DISTINCT FROM OLD.rectification_reason
+        OR NEW.series_id IS DISTINCT FROM OLD.series_id
+        OR NEW.number_year IS DISTINCT FROM OLD.number_year
+        OR NEW.sequence_number IS DISTINCT FROM OLD.sequence_number
+    THEN
+        RAISE EXCEPTION 'issued invoices are immutable: invoice % cannot be modified', OLD.invoice_number;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Comments for documentation
+COMMENT ON TABLE invoice_series IS 'Invoice numbering series (ordinary, rectifying, simplified, per location)';
+COMMENT ON COLUMN invoice_series.prefix IS 'Prefix of the invoice numbers: PREFIX_YYYY_NNNN, or PREFIX_NNNN without yearly reset';
+COMMENT ON COLUMN invoice_series.yearly_reset IS 'Numbering restarts at 1 every calendar year';
+COMMENT ON TABLE invoice_series_counters IS 'Last number issued per series and year; locked while issuing to keep numbering gap-free';
+COMMENT ON COLUMN invoices.series_id IS 'Numbering series of the invoice, chosen when drafted';
+COMMENT ON COLUMN invoices.number_year IS 'Counter year of the number (0 for series without yearly reset)';
+COMMENT ON COLUMN invoices.sequence_number IS 'Position of the invoice in its series and year, assigned when issued';
+COMMENT ON COLUMN invoices.invoice_type IS 'ordinary, rectifying (factura rectificativa) or simplified (factura simplificada)';
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS prevent_invoice_record_truncate ON invoice_records;
+DROP TRIGGER IF EXISTS prevent_invoice_record_changes ON invoice_records;
+DROP FUNCTION IF EXISTS prevent_invoice_record_truncate();
+DROP FUNCTION IF EXISTS prevent_invoice_record_changes();
+DROP TABLE IF EXISTS invoice_records;
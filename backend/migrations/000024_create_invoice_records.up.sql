@@ -0,0 +1,88 @@
+-- VeriFactu invoice records: every issue (alta) and cancellation (anulación) of an
+-- invoice appends a signed record chained to the previous one by its hash
+CREATE TABLE IF NOT EXISTS invoice_records (
+    id UUID PRIMARY KEY,
+    sequence BIGINT NOT NULL UNIQUE CHECK (sequence > 0),
+    record_type VARCHAR(20) NOT NULL CHECK (record_type IN ('registration', 'cancellation')),
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE RESTRICT,
+
+    -- Fingerprinted invoice data
+    issuer_tax_id VARCHAR(20) NOT NULL,
+    invoice_number VARCHAR(50) NOT NULL,
+    issue_date DATE NOT NULL,
+    invoice_type_code VARCHAR(2) NOT NULL DEFAULT '' CHECK (invoice_type_code IN ('', 'F1', 'F2', 'R1')),
+    vat_amount NUMERIC(12,2) NOT NULL DEFAULT 0,
+    total_amount NUMERIC(12,2) NOT NULL DEFAULT 0,
+    previous_hash CHAR(64),
+    hash CHAR(64) NOT NULL UNIQUE,
+    generated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    signature CHAR(64) NOT NULL,
+
+    -- Transmission to the tax agency
+    submission_status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (submission_status IN ('pending', 'accepted', 'rejected')),
+    submission_reference VARCHAR(100),
+    submission_error TEXT,
+    submitted_at TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Only the first record of the chain has no previous hash
+    CONSTRAINT invoice_records_chain_check CHECK ((sequence = 1) = (previous_hash IS NULL)),
+    CONSTRAINT invoice_records_invoice_record_type_key UNIQUE (invoice_id, record_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoice_records_invoice_id ON invoice_records(invoice_id);
+CREATE INDEX IF NOT EXISTS idx_invoice_records_pending ON invoice_records(sequence)
+    WHERE submission_status = 'pending';
+
+-- The chain is append-only: records are never deleted and only their submission status changes
+CREATE OR REPLACE FUNCTION prevent_invoice_record_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        RAISE EXCEPTION 'invoice records are append-only: record % cannot be deleted', OLD.sequence;
+    END IF;
+
+    IF NEW.id IS DISTINCT FROM OLD.id
+        OR NEW.sequence IS DISTINCT FROM OLD.sequence
+        OR NEW.record_type IS DISTINCT FROM OLD.record_type
+        OR NEW.invoice_id IS DISTINCT FROM OLD.invoice_id
+        OR NEW.issuer_tax_id IS DISTINCT FROM OLD.issuer_tax_id
+        OR NEW.invoice_number IS DISTINCT FROM OLD.invoice_number
+        OR NEW.issue_date IS DISTINCT FROM OLD.issue_date
+        OR NEW.invoice_type_code IS DISTINCT FROM OLD.invoice_type_code
+        OR NEW.vat_amount IS DISTINCT FROM OLD.vat_amount
+        OR NEW.total_amount IS DISTINCT FROM OLD.total_amount
+        OR NEW.previous_hash IS DISTINCT FROM OLD.previous_hash
+        OR NEW.hash IS DISTINCT FROM OLD.hash
+        OR NEW.generated_at IS DISTINCT FROM OLD.generated_at
+        OR NEW.signature IS DISTINCT FROM OLD.signature
+        OR NEW.created_at IS DISTINCT FROM OLD.created_at
+    THEN
+        RAISE EXCEPTION 'invoice records are append-only: record % cannot be modified', OLD.sequence;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS prevent_invoice_record_changes ON invoice_records;
+CREATE TRIGGER prevent_invoice_record_changes
+BEFORE UPDATE OR DELETE ON invoice_records
+FOR EACH ROW
+EXECUTE FUNCTION prevent_invoice_record_changes();
+
+-- TRUNCATE bypasses row triggers
+CREATE OR REPLACE FUNCTION prevent_invoice_record_truncate()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'invoice records are append-only and cannot be truncated';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS prevent_invoice_record_truncate ON invoice_records;
+CREATE TRIGGER prevent_invoice_record_truncate
+BEFORE TRUNCATE ON invoice_records
+FOR EACH STATEMENT
+EXECUTE FUNCTION prevent_invoice_record_truncate();
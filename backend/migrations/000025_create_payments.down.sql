@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS update_payments_updated_at ON payments;
+DROP TABLE IF EXISTS payments;
+
+-- Partially paid invoices cannot be represented without the ledger
+UPDATE invoices SET status = 'unpaid' WHERE status = 'partially_paid';
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_status_check;
+ALTER TABLE invoices ADD CONSTRAINT invoices_status_check CHECK (status IN ('draft', 'paid', 'unpaid'));
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS paid_amount;
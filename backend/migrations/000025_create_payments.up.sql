@@ -0,0 +1,54 @@
+-- Payment ledger: invoices are collected through one or more payments, and their
+-- status is derived from the balance (unpaid, partially_paid or paid)
+
+CREATE TABLE IF NOT EXISTS payments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE RESTRICT,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    payment_date DATE NOT NULL,
+    method VARCHAR(20) NOT NULL
+        CHECK (method IN ('cash', 'card', 'transfer', 'bizum', 'direct_debit', 'other')),
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    recorded_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reversed_at TIMESTAMP,
+    reversed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reversal_reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_invoice_id ON payments(invoice_id);
+CREATE INDEX IF NOT EXISTS idx_payments_payment_date ON payments(payment_date);
+
+DROP TRIGGER IF EXISTS update_payments_updated_at ON payments;
+CREATE TRIGGER update_payments_updated_at
+BEFORE UPDATE ON payments
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Amount collected by the payments not reversed; the immutability trigger of issued
+-- invoices does not cover it, so it can change together with the status
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS paid_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_status_check;
+ALTER TABLE invoices ADD CONSTRAINT invoices_status_check
+    CHECK (status IN ('draft', 'unpaid', 'partially_paid', 'paid'));
+
+-- Invoices already marked as paid are settled by a single payment on their last update
+INSERT INTO payments (invoice_id, amount, payment_date, method, reference, created_at, updated_at)
+SELECT id, total_amount, COALESCE(updated_at, NOW())::date,
+       CASE WHEN payment_method IN ('cash', 'card', 'transfer', 'bizum', 'direct_debit') THEN payment_method ELSE 'other' END,
+       'Migrated from paid status', NOW(), NOW()
+FROM invoices
+WHERE status = 'paid' AND total_amount > 0 AND deleted_at IS NULL;
+
+UPDATE invoices SET paid_amount = total_amount WHERE status = 'paid' AND total_amount > 0;
+
+-- Comments for documentation
+COMMENT ON TABLE payments IS 'Payments collected for invoices; reversed payments are kept and no longer count';
+COMMENT ON COLUMN payments.method IS 'cash, card, transfer, bizum, direct_debit or other';
+COMMENT ON COLUMN payments.reference IS 'Card receipt, transfer reference or similar';
+COMMENT ON COLUMN payments.reversed_at IS 'Set when the payment is reversed (e.g. recorded by mistake or returned)';
+COMMENT ON COLUMN invoices.paid_amount IS 'Sum of the payments not reversed';
+COMMENT ON COLUMN invoices.status IS 'draft (editable, no number), unpaid, partially_paid or paid; derived from the payments once issued';
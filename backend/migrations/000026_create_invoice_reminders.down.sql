@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS invoice_reminders;
+
+ALTER TABLE clients DROP COLUMN IF EXISTS payment_plan_until;
+
+DROP INDEX IF EXISTS idx_invoices_overdue_at;
+ALTER TABLE invoices DROP COLUMN IF EXISTS overdue_at;
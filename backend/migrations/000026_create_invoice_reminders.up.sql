@@ -0,0 +1,32 @@
+-- Dunning: issued invoices past their due date are flagged as overdue, and their
+-- clients receive a sequence of payment reminders (one row per level sent)
+
+-- Set by the dunning job when the due date passes with a balance outstanding and
+-- cleared once the invoice is paid; not covered by the immutability trigger
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS overdue_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_invoices_overdue_at ON invoices(overdue_at) WHERE overdue_at IS NOT NULL;
+
+-- Clients with an agreed payment plan receive no reminders until the plan ends
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS payment_plan_until DATE;
+
+CREATE TABLE IF NOT EXISTS invoice_reminders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    level SMALLINT NOT NULL CHECK (level > 0),
+    days_overdue INTEGER NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    outstanding_amount DECIMAL(10,2) NOT NULL,
+    payment_reference VARCHAR(35) NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Each level is sent once per invoice, even with several instances running the job
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoice_reminders_level ON invoice_reminders(invoice_id, level);
+
+-- Comments for documentation
+COMMENT ON TABLE invoice_reminders IS 'Payment reminders sent for overdue invoices';
+COMMENT ON COLUMN invoice_reminders.level IS '1 for the first reminder, up to the number of steps of the dunning schedule';
+COMMENT ON COLUMN invoice_reminders.payment_reference IS 'Structured creditor reference (RF) quoted on the payment';
+COMMENT ON COLUMN invoices.overdue_at IS 'When the invoice was found past its due date with a balance outstanding';
+COMMENT ON COLUMN clients.payment_plan_until IS 'Last day of an agreed payment plan; no reminders are sent until then';
@@ -0,0 +1,5 @@
+ALTER TABLE billing_settings DROP COLUMN IF EXISTS appointment_invoicing;
+
+ALTER TABLE employees DROP COLUMN IF EXISTS session_rate;
+
+ALTER TABLE appointments DROP COLUMN IF EXISTS service_type_id;
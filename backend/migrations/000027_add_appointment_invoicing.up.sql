@@ -0,0 +1,16 @@
+-- Appointment invoicing: an attended appointment is invoiced at the price of its
+-- service type or, when it has none, at the session rate of its employee
+
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS service_type_id UUID REFERENCES service_types(id);
+
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS session_rate DECIMAL(10,2) CHECK (session_rate >= 0);
+
+-- off: appointments are invoiced by hand; draft/issue: the invoice is created when
+-- the appointment is marked completed, as a draft or already issued
+ALTER TABLE billing_settings ADD COLUMN IF NOT EXISTS appointment_invoicing VARCHAR(10) NOT NULL DEFAULT 'off'
+    CHECK (appointment_invoicing IN ('off', 'draft', 'issue'));
+
+-- Comments for documentation
+COMMENT ON COLUMN appointments.service_type_id IS 'Service provided, priced from its default price when the appointment is invoiced';
+COMMENT ON COLUMN employees.session_rate IS 'Price per session before VAT of appointments without a priced service type';
+COMMENT ON COLUMN billing_settings.appointment_invoicing IS 'What happens when an appointment is completed: off, draft or issue its invoice';
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS session_pack_usages;
+DROP TRIGGER IF EXISTS update_client_session_packs_updated_at ON client_session_packs;
+DROP TABLE IF EXISTS client_session_packs;
+DROP TRIGGER IF EXISTS update_session_packs_updated_at ON session_packs;
+DROP TABLE IF EXISTS session_packs;
@@ -0,0 +1,71 @@
+-- Session packs (bonos): prepaid sessions sold at a discount. The sale is invoiced and
+-- each completed appointment consumes one session instead of being invoiced
+
+CREATE TABLE IF NOT EXISTS session_packs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(150) NOT NULL,
+    description TEXT,
+    service_type_id UUID REFERENCES service_types(id), -- Sessions it covers; any when NULL
+    sessions INTEGER NOT NULL CHECK (sessions > 0),
+    price DECIMAL(10,2) NOT NULL CHECK (price >= 0), -- Price of the whole pack without VAT
+    vat_rate DECIMAL(5,2) NOT NULL DEFAULT 21.00 CHECK (vat_rate >= 0),
+    vat_exemption_cause VARCHAR(2)
+        CHECK (vat_exemption_cause IN ('E1', 'E2', 'E3', 'E4', 'E5', 'E6')),
+    validity_days INTEGER NOT NULL CHECK (validity_days > 0),
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_session_packs_name ON session_packs(LOWER(name)) WHERE deleted_at IS NULL;
+
+DROP TRIGGER IF EXISTS update_session_packs_updated_at ON session_packs;
+CREATE TRIGGER update_session_packs_updated_at
+BEFORE UPDATE ON session_packs
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Packs bought by clients; name, sessions and coverage are copied from the pack when sold
+CREATE TABLE IF NOT EXISTS client_session_packs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    client_id UUID NOT NULL REFERENCES clients(id) ON DELETE RESTRICT,
+    session_pack_id UUID NOT NULL REFERENCES session_packs(id) ON DELETE RESTRICT,
+    invoice_id UUID REFERENCES invoices(id) ON DELETE SET NULL,
+    name VARCHAR(150) NOT NULL,
+    service_type_id UUID REFERENCES service_types(id),
+    sessions_total INTEGER NOT NULL CHECK (sessions_total > 0),
+    sessions_remaining INTEGER NOT NULL
+        CHECK (sessions_remaining >= 0 AND sessions_remaining <= sessions_total),
+    purchased_at DATE NOT NULL,
+    expires_at DATE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (expires_at >= purchased_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_session_packs_client_id ON client_session_packs(client_id, expires_at);
+
+DROP TRIGGER IF EXISTS update_client_session_packs_updated_at ON client_session_packs;
+CREATE TRIGGER update_client_session_packs_updated_at
+BEFORE UPDATE ON client_session_packs
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Sessions consumed from a pack; an appointment consumes at most one
+CREATE TABLE IF NOT EXISTS session_pack_usages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    client_session_pack_id UUID NOT NULL REFERENCES client_session_packs(id) ON DELETE RESTRICT,
+    appointment_id UUID NOT NULL UNIQUE REFERENCES appointments(id) ON DELETE RESTRICT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_pack_usages_pack_id ON session_pack_usages(client_session_pack_id);
+
+-- Comments for documentation
+COMMENT ON TABLE session_packs IS 'Catalogue of prepaid session packs (bonos)';
+COMMENT ON COLUMN session_packs.price IS 'Price of the whole pack before VAT';
+COMMENT ON COLUMN session_packs.validity_days IS 'Days the sessions can be used from the sale';
+COMMENT ON TABLE client_session_packs IS 'Session packs sold to clients, with the sessions left';
+COMMENT ON COLUMN client_session_packs.invoice_id IS 'Invoice of the sale';
+COMMENT ON TABLE session_pack_usages IS 'Completed appointments paid with a session of a pack instead of invoiced';
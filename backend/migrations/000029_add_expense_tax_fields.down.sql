@@ -0,0 +1,5 @@
+ALTER TABLE expenses DROP COLUMN IF EXISTS irpf_deductible;
+
+ALTER TABLE expenses DROP COLUMN IF EXISTS vat_deductible;
+
+ALTER TABLE expenses DROP COLUMN IF EXISTS vat_amount;
@@ -0,0 +1,14 @@
+-- Tax treatment of expenses for the quarterly returns: the VAT included in the amount
+-- and whether it is deductible (Modelo 303) and whether the expense counts for IRPF (Modelo 130)
+
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS vat_amount DECIMAL(10,2) NOT NULL DEFAULT 0
+    CHECK (vat_amount >= 0);
+
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS vat_deductible BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS irpf_deductible BOOLEAN NOT NULL DEFAULT true;
+
+-- Comments for documentation
+COMMENT ON COLUMN expenses.vat_amount IS 'VAT included in the amount (cuota soportada); the base is amount - vat_amount';
+COMMENT ON COLUMN expenses.vat_deductible IS 'Whether the input VAT is deducted in Modelo 303';
+COMMENT ON COLUMN expenses.irpf_deductible IS 'Whether the expense is deductible for IRPF (Modelo 130)';
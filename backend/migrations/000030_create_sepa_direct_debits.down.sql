@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS update_sepa_debits_updated_at ON sepa_debits;
+DROP TABLE IF EXISTS sepa_debits;
+DROP TABLE IF EXISTS sepa_batches;
+DROP TRIGGER IF EXISTS update_sepa_mandates_updated_at ON sepa_mandates;
+DROP TABLE IF EXISTS sepa_mandates;
+
+ALTER TABLE billing_settings DROP COLUMN IF EXISTS sepa_creditor_id;
@@ -0,0 +1,77 @@
+-- SEPA Core direct debits (domiciliaciones): clients sign a mandate and their unpaid
+-- invoices are collected in batches (remesas) sent to the bank as pain.008 files
+
+-- Creditor identifier assigned by the bank (e.g. ES00ZZZ12345678Z)
+ALTER TABLE billing_settings ADD COLUMN IF NOT EXISTS sepa_creditor_id VARCHAR(35) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS sepa_mandates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    client_id UUID NOT NULL REFERENCES clients(id) ON DELETE RESTRICT,
+    reference VARCHAR(35) NOT NULL UNIQUE,
+    debtor_name VARCHAR(70) NOT NULL,
+    iban VARCHAR(34) NOT NULL,
+    bic VARCHAR(11) NOT NULL DEFAULT '',
+    signed_at DATE NOT NULL,
+    sequence VARCHAR(4) NOT NULL DEFAULT 'FRST' CHECK (sequence IN ('FRST', 'RCUR')),
+    last_collected_at DATE,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- A client has at most one active mandate
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sepa_mandates_active_client ON sepa_mandates(client_id) WHERE revoked_at IS NULL;
+
+DROP TRIGGER IF EXISTS update_sepa_mandates_updated_at ON sepa_mandates;
+CREATE TRIGGER update_sepa_mandates_updated_at
+BEFORE UPDATE ON sepa_mandates
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS sepa_batches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    message_id VARCHAR(35) NOT NULL UNIQUE,
+    collection_date DATE NOT NULL,
+    transaction_count INTEGER NOT NULL CHECK (transaction_count > 0),
+    total_amount DECIMAL(10,2) NOT NULL CHECK (total_amount > 0),
+    content BYTEA NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sepa_batches_created_at ON sepa_batches(created_at);
+
+CREATE TABLE IF NOT EXISTS sepa_debits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    batch_id UUID NOT NULL REFERENCES sepa_batches(id) ON DELETE RESTRICT,
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE RESTRICT,
+    mandate_id UUID NOT NULL REFERENCES sepa_mandates(id) ON DELETE RESTRICT,
+    payment_id UUID NOT NULL UNIQUE REFERENCES payments(id) ON DELETE RESTRICT,
+    end_to_end_id VARCHAR(35) NOT NULL UNIQUE,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    sequence VARCHAR(4) NOT NULL CHECK (sequence IN ('FRST', 'RCUR')),
+    status VARCHAR(20) NOT NULL DEFAULT 'presented' CHECK (status IN ('presented', 'returned')),
+    return_reason VARCHAR(4),
+    returned_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sepa_debits_batch_id ON sepa_debits(batch_id);
+CREATE INDEX IF NOT EXISTS idx_sepa_debits_invoice_id ON sepa_debits(invoice_id);
+
+DROP TRIGGER IF EXISTS update_sepa_debits_updated_at ON sepa_debits;
+CREATE TRIGGER update_sepa_debits_updated_at
+BEFORE UPDATE ON sepa_debits
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Comments for documentation
+COMMENT ON COLUMN billing_settings.sepa_creditor_id IS 'SEPA creditor identifier, required to collect by direct debit';
+COMMENT ON TABLE sepa_mandates IS 'Direct debit mandates signed by clients; revoked mandates are kept';
+COMMENT ON COLUMN sepa_mandates.reference IS 'Mandate ID (MndtId) quoted in every debit';
+COMMENT ON COLUMN sepa_mandates.sequence IS 'Sequence type of the next debit: FRST until the first one is collected, then RCUR';
+COMMENT ON TABLE sepa_batches IS 'Direct debit batches (remesas) with the pain.008.001.02 file sent to the bank';
+COMMENT ON TABLE sepa_debits IS 'Invoices collected in a batch, with the payment recorded for each one';
+COMMENT ON COLUMN sepa_debits.end_to_end_id IS 'Identifier quoted by the bank when the debit is returned';
+COMMENT ON COLUMN sepa_debits.return_reason IS 'ISO 20022 reason code of a returned debit, e.g. AM04 or MD06';
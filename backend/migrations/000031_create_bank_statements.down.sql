@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS update_bank_movements_updated_at ON bank_movements;
+DROP TABLE IF EXISTS bank_movements;
+DROP TABLE IF EXISTS bank_statements;
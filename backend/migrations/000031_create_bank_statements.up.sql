@@ -0,0 +1,59 @@
+-- Bank statements (Norma 43 or camt.053) imported to reconcile their movements with
+-- invoices, which receive a payment, and with expenses
+
+CREATE TABLE IF NOT EXISTS bank_statements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    format VARCHAR(10) NOT NULL CHECK (format IN ('norma43', 'camt053')),
+    file_name VARCHAR(255) NOT NULL DEFAULT '',
+    content_hash VARCHAR(64) NOT NULL UNIQUE,
+    account VARCHAR(34) NOT NULL DEFAULT '',
+    from_date DATE NOT NULL,
+    to_date DATE NOT NULL,
+    movement_count INTEGER NOT NULL DEFAULT 0,
+    imported_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    imported_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (to_date >= from_date)
+);
+
+CREATE TABLE IF NOT EXISTS bank_movements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    statement_id UUID NOT NULL REFERENCES bank_statements(id) ON DELETE CASCADE,
+    booking_date DATE NOT NULL,
+    value_date DATE NOT NULL,
+    amount DECIMAL(12,2) NOT NULL CHECK (amount <> 0),
+    concept TEXT NOT NULL DEFAULT '',
+    reference VARCHAR(140) NOT NULL DEFAULT '',
+    counterparty VARCHAR(140) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'reconciled', 'ignored')),
+    invoice_id UUID REFERENCES invoices(id) ON DELETE RESTRICT,
+    payment_id UUID UNIQUE REFERENCES payments(id) ON DELETE RESTRICT,
+    expense_id UUID REFERENCES expenses(id) ON DELETE RESTRICT,
+    auto_matched BOOLEAN NOT NULL DEFAULT false,
+    reconciled_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reconciled_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (invoice_id IS NULL OR expense_id IS NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bank_movements_statement_id ON bank_movements(statement_id, booking_date);
+CREATE INDEX IF NOT EXISTS idx_bank_movements_status ON bank_movements(status) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_bank_movements_invoice_id ON bank_movements(invoice_id);
+
+-- An expense is paid by a single movement
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bank_movements_expense_id ON bank_movements(expense_id) WHERE expense_id IS NOT NULL;
+
+DROP TRIGGER IF EXISTS update_bank_movements_updated_at ON bank_movements;
+CREATE TRIGGER update_bank_movements_updated_at
+BEFORE UPDATE ON bank_movements
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Comments for documentation
+COMMENT ON TABLE bank_statements IS 'Imported bank statement files; content_hash prevents importing a file twice';
+COMMENT ON COLUMN bank_statements.account IS 'IBAN, or bank, branch and account number of Norma 43 files';
+COMMENT ON TABLE bank_movements IS 'Movements of the imported statements and their reconciliation';
+COMMENT ON COLUMN bank_movements.amount IS 'Positive for money received, negative for money paid';
+COMMENT ON COLUMN bank_movements.status IS 'pending (reconciliation queue), reconciled or ignored';
+COMMENT ON COLUMN bank_movements.payment_id IS 'Payment recorded when an incoming movement is reconciled with an invoice';
+COMMENT ON COLUMN bank_movements.auto_matched IS 'Reconciled on import without confirmation';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS payment_webhook_events;
+DROP TRIGGER IF EXISTS update_payment_links_updated_at ON payment_links;
+DROP TABLE IF EXISTS payment_links;
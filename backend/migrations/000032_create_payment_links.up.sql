@@ -0,0 +1,41 @@
+-- Online "pay now" checkouts created at the payment provider for the outstanding balance of
+-- an invoice, and the provider webhook events already processed
+
+CREATE TABLE IF NOT EXISTS payment_links (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE RESTRICT,
+    provider VARCHAR(20) NOT NULL,
+    session_id VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    amount DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'paid', 'expired')),
+    expires_at TIMESTAMP NOT NULL,
+    payment_id UUID UNIQUE REFERENCES payments(id) ON DELETE RESTRICT,
+    paid_at TIMESTAMP,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, session_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_links_invoice_id ON payment_links(invoice_id, created_at);
+
+DROP TRIGGER IF EXISTS update_payment_links_updated_at ON payment_links;
+CREATE TRIGGER update_payment_links_updated_at
+BEFORE UPDATE ON payment_links
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS payment_webhook_events (
+    provider VARCHAR(20) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    payment_link_id UUID REFERENCES payment_links(id) ON DELETE CASCADE,
+    received_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, event_id)
+);
+
+-- Comments for documentation
+COMMENT ON TABLE payment_links IS 'Checkout sessions at the online payment provider for the outstanding balance of an invoice';
+COMMENT ON COLUMN payment_links.status IS 'open (waiting for the client), paid or expired';
+COMMENT ON COLUMN payment_links.payment_id IS 'Payment recorded when the provider confirms the checkout; NULL if the invoice was settled otherwise meanwhile';
+COMMENT ON TABLE payment_webhook_events IS 'Provider events already applied, so retried webhooks are not recorded twice';
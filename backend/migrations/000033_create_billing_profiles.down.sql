@@ -0,0 +1,57 @@
+-- Restore the trigger of 000023, without the payer columns
+CREATE OR REPLACE FUNCTION prevent_issued_invoice_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' OR NEW.deleted_at IS DISTINCT FROM OLD.deleted_at THEN
+        RAISE EXCEPTION 'issued invoices are immutable: invoice % cannot be deleted', OLD.invoice_number;
+    END IF;
+
+    IF NEW.status = 'draft'
+        OR NEW.invoice_number IS DISTINCT FROM OLD.invoice_number
+        OR NEW.client_id IS DISTINCT FROM OLD.client_id
+        OR NEW.issue_date IS DISTINCT FROM OLD.issue_date
+        OR NEW.due_date IS DISTINCT FROM OLD.due_date
+        OR NEW.description IS DISTINCT FROM OLD.description
+        OR NEW.base_amount IS DISTINCT FROM OLD.base_amount
+        OR NEW.vat_rate IS DISTINCT FROM OLD.vat_rate
+        OR NEW.vat_amount IS DISTINCT FROM OLD.vat_amount
+        OR NEW.irpf_rate IS DISTINCT FROM OLD.irpf_rate
+        OR NEW.irpf_amount IS DISTINCT FROM OLD.irpf_amount
+        OR NEW.total_amount IS DISTINCT FROM OLD.total_amount
+        OR NEW.notes IS DISTINCT FROM OLD.notes
+        OR NEW.invoice_type IS DISTINCT FROM OLD.invoice_type
+        OR NEW.rectified_invoice_id IS DISTINCT FROM OLD.rectified_invoice_id
+        OR NEW.rectification_mode IS DISTINCT FROM OLD.rectification_mode
+        OR NEW.rectification_reason IS DISTINCT FROM OLD.rectification_reason
+        OR NEW.series_id IS DISTINCT FROM OLD.series_id
+        OR NEW.number_year IS DISTINCT FROM OLD.number_year
+        OR NEW.sequence_number IS DISTINCT FROM OLD.sequence_number
+    THEN
+        RAISE EXCEPTION 'issued invoices are immutable: invoice % cannot be modified', OLD.invoice_number;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_invoices_billing_profile_id;
+ALTER TABLE invoices DROP COLUMN IF EXISTS payer_address_country;
+ALTER TABLE invoices DROP COLUMN IF EXISTS payer_address_postal_code;
+ALTER TABLE invoices DROP COLUMN IF EXISTS payer_address_province;
+ALTER TABLE invoices DROP COLUMN IF EXISTS payer_address_city;
+ALTER TABLE invoices DROP COLUMN IF EXISTS payer_address_street;
+ALTER TABLE invoices DROP COLUMN IF EXISTS payer_email;
+ALTER TABLE invoices DROP COLUMN IF EXISTS payer_tax_id;
+ALTER TABLE invoices DROP COLUMN IF EXISTS payer_name;
+ALTER TABLE invoices DROP COLUMN IF EXISTS billing_profile_id;
+
+DROP TABLE IF EXISTS client_billing_profiles;
+DROP TRIGGER IF EXISTS update_billing_profiles_updated_at ON billing_profiles;
+DROP TABLE IF EXISTS billing_profiles;
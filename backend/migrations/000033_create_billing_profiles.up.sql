@@ -0,0 +1,114 @@
+-- Billing profiles: the fiscal identity paying for the sessions of one or more clients
+-- (a parent paying for a minor, a company paying for an employee). Invoices are addressed
+-- to the payer and keep a copy of its fiscal data; the client is the beneficiary.
+
+CREATE TABLE IF NOT EXISTS billing_profiles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(200) NOT NULL,
+    tax_id VARCHAR(20) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    phone VARCHAR(20) NOT NULL DEFAULT '',
+    address_street VARCHAR(255) NOT NULL DEFAULT '',
+    address_city VARCHAR(100) NOT NULL DEFAULT '',
+    address_province VARCHAR(100) NOT NULL DEFAULT '',
+    address_postal_code VARCHAR(10) NOT NULL DEFAULT '',
+    address_country VARCHAR(2) NOT NULL DEFAULT '',
+    preferred_payment_method VARCHAR(20)
+        CHECK (preferred_payment_method IN ('cash', 'card', 'transfer', 'bizum', 'direct_debit', 'other')),
+    notes TEXT NOT NULL DEFAULT '',
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_billing_profiles_tax_id ON billing_profiles(tax_id) WHERE deleted_at IS NULL;
+
+DROP TRIGGER IF EXISTS update_billing_profiles_updated_at ON billing_profiles;
+CREATE TRIGGER update_billing_profiles_updated_at
+BEFORE UPDATE ON billing_profiles
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS client_billing_profiles (
+    client_id UUID NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    billing_profile_id UUID NOT NULL REFERENCES billing_profiles(id) ON DELETE CASCADE,
+    is_default BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (client_id, billing_profile_id)
+);
+
+-- A client has at most one default payer
+CREATE UNIQUE INDEX IF NOT EXISTS idx_client_billing_profiles_default ON client_billing_profiles(client_id) WHERE is_default;
+CREATE INDEX IF NOT EXISTS idx_client_billing_profiles_profile ON client_billing_profiles(billing_profile_id);
+
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS billing_profile_id UUID REFERENCES billing_profiles(id) ON DELETE RESTRICT;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS payer_name VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS payer_tax_id VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS payer_email VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS payer_address_street VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS payer_address_city VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS payer_address_province VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS payer_address_postal_code VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS payer_address_country VARCHAR(2) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_invoices_billing_profile_id ON invoices(billing_profile_id) WHERE billing_profile_id IS NOT NULL;
+
+-- The payer of an issued invoice cannot change either
+CREATE OR REPLACE FUNCTION prevent_issued_invoice_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' OR NEW.deleted_at IS DISTINCT FROM OLD.deleted_at THEN
+        RAISE EXCEPTION 'issued invoices are immutable: invoice % cannot be deleted', OLD.invoice_number;
+    END IF;
+
+    IF NEW.status = 'draft'
+        OR NEW.invoice_number IS DISTINCT FROM OLD.invoice_number
+        OR NEW.client_id IS DISTINCT FROM OLD.client_id
+        OR NEW.issue_date IS DISTINCT FROM OLD.issue_date
+        OR NEW.due_date IS DISTINCT FROM OLD.due_date
+        OR NEW.description IS DISTINCT FROM OLD.description
+        OR NEW.base_amount IS DISTINCT FROM OLD.base_amount
+        OR NEW.vat_rate IS DISTINCT FROM OLD.vat_rate
+        OR NEW.vat_amount IS DISTINCT FROM OLD.vat_amount
+        OR NEW.irpf_rate IS DISTINCT FROM OLD.irpf_rate
+        OR NEW.irpf_amount IS DISTINCT FROM OLD.irpf_amount
+        OR NEW.total_amount IS DISTINCT FROM OLD.total_amount
+        OR NEW.notes IS DISTINCT FROM OLD.notes
+        OR NEW.invoice_type IS DISTINCT FROM OLD.invoice_type
+        OR NEW.rectified_invoice_id IS DISTINCT FROM OLD.rectified_invoice_id
+        OR NEW.rectification_mode IS DISTINCT FROM OLD.rectification_mode
+        OR NEW.rectification_reason IS DISTINCT FROM OLD.rectification_reason
+        OR NEW.series_id IS DISTINCT FROM OLD.series_id
+        OR NEW.number_year IS DISTINCT FROM OLD.number_year
+        OR NEW.sequence_number IS DISTINCT FROM OLD.sequence_number
+        OR NEW.billing_profile_id IS DISTINCT FROM OLD.billing_profile_id
+        OR NEW.payer_name IS DISTINCT FROM OLD.payer_name
+        OR NEW.payer_tax_id IS DISTINCT FROM OLD.payer_tax_id
+        OR NEW.payer_email IS DISTINCT FROM OLD.payer_email
+        OR NEW.payer_address_street IS DISTINCT FROM OLD.payer_address_street
+        OR NEW.payer_address_city IS DISTINCT FROM OLD.payer_address_city
+        OR NEW.payer_address_province IS DISTINCT FROM OLD.payer_address_province
+        OR NEW.payer_address_postal_code IS DISTINCT FROM OLD.payer_address_postal_code
+        OR NEW.payer_address_country IS DISTINCT FROM OLD.payer_address_country
+    THEN
+        RAISE EXCEPTION 'issued invoices are immutable: invoice % cannot be modified', OLD.invoice_number;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Comments for documentation
+COMMENT ON TABLE billing_profiles IS 'Fiscal identity (name, NIF/CIF, address) paying for the sessions of one or more clients';
+COMMENT ON TABLE client_billing_profiles IS 'Payers of each client; the default one is used for new invoices';
+COMMENT ON COLUMN invoices.billing_profile_id IS 'Payer the invoice is addressed to; NULL when the client pays';
+COMMENT ON COLUMN invoices.payer_name IS 'Fiscal name of the payer when the invoice was issued';
+COMMENT ON COLUMN invoices.payer_tax_id IS 'NIF/CIF of the payer when the invoice was issued';
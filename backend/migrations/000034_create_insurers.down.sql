@@ -0,0 +1,8 @@
+-- Settlement invoices of the insurers must be removed before client_id is required again
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_client_or_payer;
+ALTER TABLE invoices ALTER COLUMN client_id SET NOT NULL;
+
+DROP TABLE IF EXISTS insurance_covered_sessions;
+DROP TABLE IF EXISTS insurance_authorisations;
+DROP TABLE IF EXISTS insurance_policies;
+DROP TABLE IF EXISTS insurers;
@@ -0,0 +1,106 @@
+-- Insurance companies (mutuas): sessions of insured clients are authorised in advance,
+-- the client pays a co-payment and the insurer is invoiced the rest once a month
+
+CREATE TABLE IF NOT EXISTS insurers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(150) NOT NULL,
+    billing_profile_id UUID NOT NULL REFERENCES billing_profiles(id) ON DELETE RESTRICT, -- Fiscal data of the settlement invoices
+    session_rate DECIMAL(10,2) NOT NULL CHECK (session_rate > 0), -- Agreed price per session before VAT
+    payment_term_days INTEGER NOT NULL DEFAULT 30 CHECK (payment_term_days >= 0),
+    notes TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_insurers_name ON insurers(LOWER(name)) WHERE deleted_at IS NULL;
+
+DROP TRIGGER IF EXISTS update_insurers_updated_at ON insurers;
+CREATE TRIGGER update_insurers_updated_at
+BEFORE UPDATE ON insurers
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Policies of the clients with an insurer
+CREATE TABLE IF NOT EXISTS insurance_policies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    client_id UUID NOT NULL REFERENCES clients(id) ON DELETE RESTRICT,
+    insurer_id UUID NOT NULL REFERENCES insurers(id) ON DELETE RESTRICT,
+    policy_number VARCHAR(50) NOT NULL,
+    copay DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (copay >= 0), -- Paid by the client per session
+    valid_from DATE NOT NULL,
+    valid_until DATE,
+    notes TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP,
+    CHECK (valid_until IS NULL OR valid_until >= valid_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_insurance_policies_client_id ON insurance_policies(client_id) WHERE deleted_at IS NULL;
+
+DROP TRIGGER IF EXISTS update_insurance_policies_updated_at ON insurance_policies;
+CREATE TRIGGER update_insurance_policies_updated_at
+BEFORE UPDATE ON insurance_policies
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Authorisations granted by the insurer for a number of sessions within some dates
+CREATE TABLE IF NOT EXISTS insurance_authorisations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    policy_id UUID NOT NULL REFERENCES insurance_policies(id) ON DELETE RESTRICT,
+    authorisation_number VARCHAR(50) NOT NULL,
+    service_type_id UUID REFERENCES service_types(id), -- Sessions it covers; any when NULL
+    sessions_authorised INTEGER NOT NULL CHECK (sessions_authorised > 0),
+    sessions_used INTEGER NOT NULL DEFAULT 0
+        CHECK (sessions_used >= 0 AND sessions_used <= sessions_authorised),
+    valid_from DATE NOT NULL,
+    valid_until DATE NOT NULL,
+    notes TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (valid_until >= valid_from)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_insurance_authorisations_number ON insurance_authorisations(policy_id, authorisation_number);
+
+DROP TRIGGER IF EXISTS update_insurance_authorisations_updated_at ON insurance_authorisations;
+CREATE TRIGGER update_insurance_authorisations_updated_at
+BEFORE UPDATE ON insurance_authorisations
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Completed appointments covered by an authorisation, with the split of their price
+CREATE TABLE IF NOT EXISTS insurance_covered_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    authorisation_id UUID NOT NULL REFERENCES insurance_authorisations(id) ON DELETE RESTRICT,
+    appointment_id UUID NOT NULL UNIQUE REFERENCES appointments(id) ON DELETE RESTRICT,
+    client_id UUID NOT NULL REFERENCES clients(id) ON DELETE RESTRICT,
+    insurer_id UUID NOT NULL REFERENCES insurers(id) ON DELETE RESTRICT,
+    service_type_id UUID REFERENCES service_types(id),
+    session_date DATE NOT NULL,
+    insurer_amount DECIMAL(10,2) NOT NULL CHECK (insurer_amount >= 0),
+    copay_amount DECIMAL(10,2) NOT NULL CHECK (copay_amount >= 0),
+    settlement_invoice_id UUID REFERENCES invoices(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_insurance_covered_sessions_insurer ON insurance_covered_sessions(insurer_id, session_date);
+CREATE INDEX IF NOT EXISTS idx_insurance_covered_sessions_authorisation ON insurance_covered_sessions(authorisation_id);
+
+-- Settlement invoices are addressed to the insurer and cover the sessions of many clients
+ALTER TABLE invoices ALTER COLUMN client_id DROP NOT NULL;
+ALTER TABLE invoices ADD CONSTRAINT invoices_client_or_payer
+    CHECK (client_id IS NOT NULL OR billing_profile_id IS NOT NULL);
+
+-- Comments for documentation
+COMMENT ON TABLE insurers IS 'Insurance companies (mutuas) paying for the sessions of their insured clients';
+COMMENT ON COLUMN insurers.billing_profile_id IS 'Fiscal data the monthly settlement invoices are addressed to';
+COMMENT ON COLUMN insurers.session_rate IS 'Price per session before VAT agreed with the insurer, paid on top of the co-payment';
+COMMENT ON TABLE insurance_policies IS 'Insurance policies of the clients';
+COMMENT ON COLUMN insurance_policies.copay IS 'Co-payment invoiced to the client for each covered session';
+COMMENT ON TABLE insurance_authorisations IS 'Pre-authorisations of the insurer for a number of sessions';
+COMMENT ON TABLE insurance_covered_sessions IS 'Completed appointments taken from an authorisation and their price split';
+COMMENT ON COLUMN insurance_covered_sessions.settlement_invoice_id IS 'Monthly invoice to the insurer including the session';
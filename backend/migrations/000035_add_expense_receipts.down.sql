@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_expenses_attachment_sha256;
+
+ALTER TABLE expenses DROP COLUMN IF EXISTS attachment_uploaded_at;
+ALTER TABLE expenses DROP COLUMN IF EXISTS attachment_sha256;
+ALTER TABLE expenses DROP COLUMN IF EXISTS attachment_size_bytes;
+ALTER TABLE expenses DROP COLUMN IF EXISTS attachment_content_type;
+ALTER TABLE expenses DROP COLUMN IF EXISTS attachment_file_name;
+ALTER TABLE expenses ALTER COLUMN attachment_path TYPE VARCHAR(255);
+
+COMMENT ON COLUMN expenses.attachment_path IS 'Path to attached invoice PDF file';
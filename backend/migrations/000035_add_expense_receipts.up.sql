@@ -0,0 +1,15 @@
+-- Receipts uploaded to the expenses: attachment_path becomes the storage key of the file,
+-- which is named after its content hash so the same file is stored once
+ALTER TABLE expenses ALTER COLUMN attachment_path TYPE VARCHAR(500);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS attachment_file_name VARCHAR(255);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS attachment_content_type VARCHAR(100);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS attachment_size_bytes BIGINT CHECK (attachment_size_bytes > 0);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS attachment_sha256 CHAR(64);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS attachment_uploaded_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_expenses_attachment_sha256 ON expenses(attachment_sha256) WHERE attachment_sha256 IS NOT NULL;
+
+-- Comments for documentation
+COMMENT ON COLUMN expenses.attachment_path IS 'Storage key of the receipt (legacy rows may hold a free-form path)';
+COMMENT ON COLUMN expenses.attachment_file_name IS 'Original name of the uploaded receipt';
+COMMENT ON COLUMN expenses.attachment_sha256 IS 'SHA-256 of the receipt, detecting the same receipt uploaded twice';
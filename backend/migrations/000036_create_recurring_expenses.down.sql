@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_expenses_needs_review;
+ALTER TABLE expenses DROP COLUMN IF EXISTS needs_review;
+ALTER TABLE expenses DROP COLUMN IF EXISTS recurring_expense_id;
+
+DROP TABLE IF EXISTS recurring_expense_occurrences;
+DROP TABLE IF EXISTS recurring_expenses;
@@ -0,0 +1,70 @@
+-- Recurring expenses (rent, utilities, subscriptions): templates generating an expense on
+-- their day of every month, with single occurrences skipped or adjusted
+
+CREATE TABLE IF NOT EXISTS recurring_expenses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    supplier VARCHAR(255) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    vat_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (vat_amount >= 0),
+    vat_deductible BOOLEAN NOT NULL DEFAULT false,
+    irpf_deductible BOOLEAN NOT NULL DEFAULT true,
+    category_id UUID NOT NULL REFERENCES expense_categories(id) ON DELETE RESTRICT,
+    subcategory_id UUID REFERENCES expense_categories(id) ON DELETE RESTRICT,
+    day_of_month INTEGER NOT NULL CHECK (day_of_month BETWEEN 1 AND 31), -- Last day of shorter months
+    start_date DATE NOT NULL,
+    end_date DATE,
+    description TEXT,
+    payment_method VARCHAR(50),
+    notes TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP,
+    CHECK (vat_amount < amount),
+    CHECK (end_date IS NULL OR end_date >= start_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_expenses_active ON recurring_expenses(start_date) WHERE deleted_at IS NULL AND is_active;
+
+DROP TRIGGER IF EXISTS update_recurring_expenses_updated_at ON recurring_expenses;
+CREATE TRIGGER update_recurring_expenses_updated_at
+BEFORE UPDATE ON recurring_expenses
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- One row per month of a template that was adjusted, skipped or generated; months without
+-- a row follow the template
+CREATE TABLE IF NOT EXISTS recurring_expense_occurrences (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    recurring_expense_id UUID NOT NULL REFERENCES recurring_expenses(id) ON DELETE CASCADE,
+    period DATE NOT NULL CHECK (EXTRACT(DAY FROM period) = 1), -- First day of the month
+    status VARCHAR(20) NOT NULL CHECK (status IN ('adjusted', 'skipped', 'generated')),
+    expense_date DATE,
+    amount DECIMAL(10,2) CHECK (amount > 0),
+    vat_amount DECIMAL(10,2) CHECK (vat_amount >= 0),
+    notes TEXT,
+    expense_id UUID REFERENCES expenses(id) ON DELETE RESTRICT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (recurring_expense_id, period),
+    CHECK ((status = 'generated') = (expense_id IS NOT NULL))
+);
+
+DROP TRIGGER IF EXISTS update_recurring_expense_occurrences_updated_at ON recurring_expense_occurrences;
+CREATE TRIGGER update_recurring_expense_occurrences_updated_at
+BEFORE UPDATE ON recurring_expense_occurrences
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Generated expenses stay flagged until someone reviews them
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS recurring_expense_id UUID REFERENCES recurring_expenses(id) ON DELETE SET NULL;
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS needs_review BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_expenses_needs_review ON expenses(expense_date) WHERE needs_review AND deleted_at IS NULL;
+
+-- Comments for documentation
+COMMENT ON TABLE recurring_expenses IS 'Templates of expenses repeated every month';
+COMMENT ON COLUMN recurring_expenses.day_of_month IS 'Day the expense is due; the last day of the month when it is shorter';
+COMMENT ON TABLE recurring_expense_occurrences IS 'Months of a recurring expense adjusted, skipped or already generated';
+COMMENT ON COLUMN expenses.recurring_expense_id IS 'Recurring expense that generated the expense';
+COMMENT ON COLUMN expenses.needs_review IS 'Generated automatically and not reviewed yet';
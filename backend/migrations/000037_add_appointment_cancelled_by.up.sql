@@ -0,0 +1,6 @@
+-- Who cancelled an appointment: late-cancellation fees are only charged for cancellations
+-- made by the client, including those the clinic records on the client's behalf
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS cancelled_by VARCHAR(10)
+    CHECK (cancelled_by IN ('client', 'clinic'));
+
+COMMENT ON COLUMN appointments.cancelled_by IS 'Party that cancelled the appointment: client or clinic';
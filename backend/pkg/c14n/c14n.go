@@ -0,0 +1,246 @@
+// Package c14n implements Canonical XML 1.0 without comments
+// (http://www.w3.org/TR/2001/REC-xml-c14n-20010315), as required to compute the
+// digests of XML signatures. Documents with a DTD are not supported: default
+// attributes and entities declared in an internal subset are ignored. Carriage
+// returns are read as line feeds, even when written as character references.
+package c14n
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Algorithm is the identifier of the canonicalization method in XML signatures
+const Algorithm = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+
+// xmlNamespace is the namespace bound to the reserved xml prefix
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// ErrNotFound is returned when no element carries the requested Id
+var ErrNotFound = errors.New("c14n: element not found")
+
+// Canonicalize returns the canonical form of the document or, when id is not empty,
+// of the element whose Id attribute is id, including the namespaces it inherits
+func Canonicalize(doc []byte, id string) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(doc))
+
+	var (
+		out      bytes.Buffer
+		names    []string                  // Qualified names of the open elements
+		scopes   = []map[string]string{{}} // In-scope namespaces of each open element
+		rendered []map[string]string       // Namespaces rendered by each open element in the output
+		apex     = -1                      // Depth of the first element in the output, -1 until found
+		seenRoot bool
+	)
+
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("c14n: %w", err)
+		}
+
+		depth := len(scopes) - 1
+		switch t := token.(type) {
+		case xml.StartElement:
+			scope := inherit(scopes[depth], t.Attr)
+			scopes = append(scopes, scope)
+			names = append(names, qualified(t.Name))
+
+			if apex < 0 && (id == "" || attrValue(t.Attr, "Id") == id) {
+				apex = depth
+				rendered = []map[string]string{{}}
+			}
+			seenRoot = true
+			if apex < 0 {
+				continue
+			}
+
+			current := writeStart(&out, t, scope, rendered[len(rendered)-1])
+			rendered = append(rendered, current)
+
+		case xml.EndElement:
+			if depth == 0 || names[depth-1] != qualified(t.Name) {
+				return nil, fmt.Errorf("c14n: unexpected end element </%s>", qualified(t.Name))
+			}
+			scopes = scopes[:depth]
+			names = names[:depth-1]
+			if apex < 0 {
+				continue
+			}
+
+			out.WriteString("</" + qualified(t.Name) + ">")
+			rendered = rendered[:len(rendered)-1]
+			if id != "" && depth-1 == apex {
+				return out.Bytes(), nil
+			}
+
+		case xml.CharData:
+			if apex >= 0 && depth > apex {
+				out.WriteString(escapeText(string(t)))
+			}
+
+		case xml.ProcInst:
+			if t.Target == "xml" || apex < 0 && id != "" {
+				continue
+			}
+			pi := "<?" + t.Target
+			if len(t.Inst) > 0 {
+				pi += " " + string(t.Inst)
+			}
+			pi += "?>"
+
+			// Outside the document element, instructions are separated by line feeds
+			switch {
+			case depth > 0:
+				out.WriteString(pi)
+			case seenRoot:
+				out.WriteString("\n" + pi)
+			default:
+				out.WriteString(pi + "\n")
+			}
+		}
+	}
+
+	if len(names) > 0 {
+		return nil, fmt.Errorf("c14n: element <%s> is not closed", names[len(names)-1])
+	}
+	if apex < 0 {
+		return nil, ErrNotFound
+	}
+	return out.Bytes(), nil
+}
+
+// inherit returns the namespaces in scope of an element declaring attrs under parent
+func inherit(parent map[string]string, attrs []xml.Attr) map[string]string {
+	scope := parent
+	copied := false
+	for _, attr := range attrs {
+		prefix, ok := declaredPrefix(attr.Name)
+		if !ok {
+			continue
+		}
+		if !copied {
+			scope = make(map[string]string, len(parent)+1)
+			for p, uri := range parent {
+				scope[p] = uri
+			}
+			copied = true
+		}
+		scope[prefix] = attr.Value
+	}
+	return scope
+}
+
+// declaredPrefix returns the prefix declared by a namespace attribute ("" for xmlns)
+func declaredPrefix(name xml.Name) (string, bool) {
+	switch {
+	case name.Space == "" && name.Local == "xmlns":
+		return "", true
+	case name.Space == "xmlns":
+		return name.Local, true
+	}
+	return "", false
+}
+
+// writeStart writes the start tag of an element and returns the namespaces rendered in
+// the output once it is open. A namespace is declared when the output parent does not
+// already render it with the same value; xml is never declared.
+func writeStart(out *bytes.Buffer, element xml.StartElement, scope, parent map[string]string) map[string]string {
+	current := parent
+	var prefixes []string
+	for prefix, uri := range scope {
+		if prefix == "xml" || parent[prefix] == uri {
+			continue
+		}
+		// An empty default namespace is only declared to undo an inherited one
+		if prefix == "" && uri == "" && parent[""] == "" {
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	if len(prefixes) > 0 {
+		current = make(map[string]string, len(parent)+len(prefixes))
+		for prefix, uri := range parent {
+			current[prefix] = uri
+		}
+	}
+
+	out.WriteString("<" + qualified(element.Name))
+	for _, prefix := range prefixes {
+		current[prefix] = scope[prefix]
+		name := "xmlns"
+		if prefix != "" {
+			name += ":" + prefix
+		}
+		out.WriteString(" " + name + `="` + escapeAttr(scope[prefix]) + `"`)
+	}
+
+	// Attributes are sorted by namespace URI, then by local name
+	type attribute struct {
+		uri  string
+		attr xml.Attr
+	}
+	var attrs []attribute
+	for _, attr := range element.Attr {
+		if _, ok := declaredPrefix(attr.Name); ok {
+			continue
+		}
+		uri := ""
+		switch attr.Name.Space {
+		case "":
+		case "xml":
+			uri = xmlNamespace
+		default:
+			uri = scope[attr.Name.Space]
+		}
+		attrs = append(attrs, attribute{uri: uri, attr: attr})
+	}
+	sort.SliceStable(attrs, func(i, j int) bool {
+		if attrs[i].uri != attrs[j].uri {
+			return attrs[i].uri < attrs[j].uri
+		}
+		return attrs[i].attr.Name.Local < attrs[j].attr.Name.Local
+	})
+	for _, a := range attrs {
+		out.WriteString(" " + qualified(a.attr.Name) + `="` + escapeAttr(a.attr.Value) + `"`)
+	}
+	out.WriteString(">")
+
+	return current
+}
+
+// attrValue returns the value of the unqualified attribute name, or ""
+func attrValue(attrs []xml.Attr, name string) string {
+	for _, attr := range attrs {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// qualified returns the name with its prefix as written in the document
+func qualified(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string { return textEscaper.Replace(s) }
+func escapeAttr(s string) string { return attrEscaper.Replace(s) }
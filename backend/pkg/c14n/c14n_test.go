@@ -0,0 +1,116 @@
+package c14n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Example 3.3 of the specification, without the DTD defaulting an attribute of e9
+func TestCanonicalize_StartAndEndTags(t *testing.T) {
+	doc := `<doc>
+   <e1   />
+   <e2   ></e2>
+   <e3   name = "elem3"   id="elem3"   />
+   <e4   name="elem4"   id="elem4"   ></e4>
+   <e5 a:attr="out" b:attr="sorted" attr2="all" attr="I'm"
+      xmlns:b="http://www.ietf.org"
+      xmlns:a="http://www.w3.org"
+      xmlns="http://example.org"/>
+   <e6 xmlns="" xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="" xmlns:a="http://www.w3.org">
+            <e9 xmlns="" xmlns:a="http://www.ietf.org"/>
+         </e8>
+      </e7>
+   </e6>
+</doc>`
+
+	expected := `<doc>
+   <e1></e1>
+   <e2></e2>
+   <e3 id="elem3" name="elem3"></e3>
+   <e4 id="elem4" name="elem4"></e4>
+   <e5 xmlns="http://example.org" xmlns:a="http://www.w3.org" xmlns:b="http://www.ietf.org" attr="I'm" attr2="all" b:attr="sorted" a:attr="out"></e5>
+   <e6 xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="">
+            <e9 xmlns:a="http://www.ietf.org"></e9>
+         </e8>
+      </e7>
+   </e6>
+</doc>`
+
+	canonical, err := Canonicalize([]byte(doc), "")
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(canonical))
+}
+
+// Example 3.4 of the specification, without carriage returns: character references and special characters
+func TestCanonicalize_CharacterModifications(t *testing.T) {
+	doc := `<doc>
+   <text>First line&#10;Second line</text>
+   <value>&#x32;</value>
+   <compute><![CDATA[value>"0" && value<"10" ?"valid":"error"]]></compute>
+   <compute expr='value>"0" &amp;&amp; value&lt;"10" ?"valid":"error"'>valid</compute>
+   <norm attr=' &apos;   &#x20;&#xa;&#9;   &apos; '/>
+</doc>`
+
+	expected := `<doc>
+   <text>First line
+Second line</text>
+   <value>2</value>
+   <compute>value&gt;"0" &amp;&amp; value&lt;"10" ?"valid":"error"</compute>
+   <compute expr="value>&quot;0&quot; &amp;&amp; value&lt;&quot;10&quot; ?&quot;valid&quot;:&quot;error&quot;">valid</compute>
+   <norm attr=" '    &#xA;&#x9;   ' "></norm>
+</doc>`
+
+	canonical, err := Canonicalize([]byte(doc), "")
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(canonical))
+}
+
+func TestCanonicalize_DropsDeclarationAndComments(t *testing.T) {
+	doc := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<?xml-stylesheet href=\"doc.xsl\"?>\n<doc><!-- comment --><a/></doc>\n<!-- trailing -->"
+
+	canonical, err := Canonicalize([]byte(doc), "")
+	require.NoError(t, err)
+	assert.Equal(t, "<?xml-stylesheet href=\"doc.xsl\"?>\n<doc><a></a></doc>", string(canonical))
+}
+
+func TestCanonicalize_ElementInheritsNamespaces(t *testing.T) {
+	doc := `<fe:Invoice xmlns:fe="urn:invoice" xmlns:unused="urn:unused">` +
+		`<Body>text</Body>` +
+		`<ds:Signature xmlns:ds="urn:dsig" Id="sig">` +
+		`<ds:SignedInfo Id="info" xmlns:x="urn:x"><ds:Ref x:b="2" a="1"/></ds:SignedInfo>` +
+		`<ds:Value xmlns:ds="urn:dsig">v</ds:Value>` +
+		`</ds:Signature>` +
+		`</fe:Invoice>`
+
+	t.Run("subtree declares every namespace in scope", func(t *testing.T) {
+		canonical, err := Canonicalize([]byte(doc), "info")
+		require.NoError(t, err)
+		assert.Equal(t,
+			`<ds:SignedInfo xmlns:ds="urn:dsig" xmlns:fe="urn:invoice" xmlns:unused="urn:unused" xmlns:x="urn:x" Id="info">`+
+				`<ds:Ref a="1" x:b="2"></ds:Ref></ds:SignedInfo>`,
+			string(canonical))
+	})
+
+	t.Run("redundant declarations are dropped", func(t *testing.T) {
+		canonical, err := Canonicalize([]byte(doc), "sig")
+		require.NoError(t, err)
+		assert.Contains(t, string(canonical), `<ds:Value>v</ds:Value>`)
+		assert.Contains(t, string(canonical), `<ds:SignedInfo xmlns:x="urn:x" Id="info">`)
+	})
+
+	t.Run("unknown id", func(t *testing.T) {
+		_, err := Canonicalize([]byte(doc), "missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestCanonicalize_MalformedDocument(t *testing.T) {
+	_, err := Canonicalize([]byte(`<doc><a></doc>`), "")
+	assert.Error(t, err)
+}
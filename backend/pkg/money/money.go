@@ -0,0 +1,352 @@
+// Package money provides an exact decimal money type stored as integer cents.
+//
+// Amounts never go through floating point arithmetic: multiplications by
+// quantities and percentages are computed exactly and rounded once, half-up
+// (away from zero) to cents. Operations between different currencies fail.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+const (
+	// EUR is the euro
+	EUR Currency = "EUR"
+
+	// DefaultCurrency is used by the zero value and by amounts read from the database,
+	// whose DECIMAL columns carry no currency
+	DefaultCurrency = EUR
+)
+
+var (
+	// ErrCurrencyMismatch is returned when combining amounts in different currencies
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+
+	// ErrInvalidAmount is returned when parsing a malformed or over-precise amount
+	ErrInvalidAmount = errors.New("money: invalid amount")
+)
+
+// Money is an exact amount of cents in a currency. The zero value is 0 in the default currency.
+type Money struct {
+	cents    int64
+	currency Currency
+}
+
+// New creates an amount from cents in the given currency
+func New(cents int64, currency Currency) Money {
+	return Money{cents: cents, currency: currency}
+}
+
+// FromCents creates an amount from cents in the default currency
+func FromCents(cents int64) Money {
+	return New(cents, DefaultCurrency)
+}
+
+// FromFloat converts a float (e.g. from configuration) rounding half-up to cents
+func FromFloat(amount float64) Money {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return Money{}
+	}
+	return FromCents(roundRat(new(big.Rat).Mul(decimalRat(amount), big.NewRat(100, 1))))
+}
+
+// Parse reads a decimal amount such as "12", "-3.5" or "1234.56" in the default currency.
+// Amounts with more than two decimals are rejected rather than silently rounded.
+func Parse(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Money{}, ErrInvalidAmount
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" || len(fracPart) > 2 || (hasDot && fracPart == "") || !isDigits(intPart) || !isDigits(fracPart) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	for len(fracPart) < 2 {
+		fracPart += "0"
+	}
+
+	cents, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	if negative {
+		cents = -cents
+	}
+	return FromCents(cents), nil
+}
+
+// MustParse is like Parse but panics on error; intended for constants and tests
+func MustParse(s string) Money {
+	m, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Sum adds all the amounts; it fails if they are not in the same currency
+func Sum(amounts ...Money) (Money, error) {
+	if len(amounts) == 0 {
+		return Money{}, nil
+	}
+
+	total := New(0, amounts[0].Currency())
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Cents returns the amount in cents
+func (m Money) Cents() int64 {
+	return m.cents
+}
+
+// Currency returns the currency of the amount
+func (m Money) Currency() Currency {
+	if m.currency == "" {
+		return DefaultCurrency
+	}
+	return m.currency
+}
+
+// IsZero returns true if the amount is zero
+func (m Money) IsZero() bool {
+	return m.cents == 0
+}
+
+// IsPositive returns true if the amount is greater than zero
+func (m Money) IsPositive() bool {
+	return m.cents > 0
+}
+
+// IsNegative returns true if the amount is less than zero
+func (m Money) IsNegative() bool {
+	return m.cents < 0
+}
+
+// Neg returns the amount with the opposite sign
+func (m Money) Neg() Money {
+	return New(-m.cents, m.Currency())
+}
+
+// Abs returns the absolute value of the amount
+func (m Money) Abs() Money {
+	if m.cents < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+// Add returns m + other
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency() != other.Currency() {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrCurrencyMismatch, m.Currency(), other.Currency())
+	}
+	return New(m.cents+other.cents, m.Currency()), nil
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) (Money, error) {
+	if m.Currency() != other.Currency() {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrCurrencyMismatch, m.Currency(), other.Currency())
+	}
+	return New(m.cents-other.cents, m.Currency()), nil
+}
+
+// Cmp compares two amounts, returning -1, 0 or +1
+func (m Money) Cmp(other Money) (int, error) {
+	if m.Currency() != other.Currency() {
+		return 0, fmt.Errorf("%w: %s <> %s", ErrCurrencyMismatch, m.Currency(), other.Currency())
+	}
+	switch {
+	case m.cents < other.cents:
+		return -1, nil
+	case m.cents > other.cents:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Equal returns true if both amounts have the same value and currency
+func (m Money) Equal(other Money) bool {
+	return m.cents == other.cents && m.Currency() == other.Currency()
+}
+
+// Mul multiplies the amount by a decimal factor (e.g. a quantity), rounding half-up to cents
+func (m Money) Mul(factor float64) Money {
+	r := new(big.Rat).Mul(big.NewRat(m.cents, 1), decimalRat(factor))
+	return New(roundRat(r), m.Currency())
+}
+
+// Percent returns rate percent of the amount (e.g. VAT), rounding half-up to cents
+func (m Money) Percent(rate float64) Money {
+	return m.MulPercent(1, rate)
+}
+
+// MulPercent returns factor * amount * rate / 100 computed exactly and rounded once,
+// e.g. quantity * unit price * (100 - discount) / 100
+func (m Money) MulPercent(factor, rate float64) Money {
+	r := new(big.Rat).Mul(big.NewRat(m.cents, 100), decimalRat(factor))
+	r.Mul(r, decimalRat(rate))
+	return New(roundRat(r), m.Currency())
+}
+
+// Ratio returns m / other as a float, for percentages and charts (0 when other is zero)
+func (m Money) Ratio(other Money) float64 {
+	if other.cents == 0 {
+		return 0
+	}
+	return float64(m.cents) / float64(other.cents)
+}
+
+// Float64 returns the amount in currency units; use only for display or ratios
+func (m Money) Float64() float64 {
+	return float64(m.cents) / 100
+}
+
+// Decimal returns the amount as a decimal string with two decimals, e.g. "-12.30"
+func (m Money) Decimal() string {
+	sign := ""
+	cents := m.cents
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// String returns the amount with its currency, e.g. "12.30 EUR"
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.Currency())
+}
+
+// MarshalJSON encodes the amount as a JSON number with two decimals
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON decodes a JSON number or string in the default currency
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	// Accept exponent notation from JSON encoders (e.g. 1e2) when it is exact to the cent
+	if strings.ContainsAny(s, "eE") {
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+		}
+		if !new(big.Rat).Mul(r, big.NewRat(100, 1)).IsInt() {
+			return fmt.Errorf("%w: %q has more than two decimals", ErrInvalidAmount, string(data))
+		}
+		s = r.FloatString(2)
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value stores the amount in a DECIMAL column
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal(), nil
+}
+
+// Scan reads the amount from a DECIMAL column in the default currency
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case []byte:
+		return m.scanDecimal(string(v))
+	case string:
+		return m.scanDecimal(v)
+	case int64:
+		*m = FromCents(v * 100)
+		return nil
+	case float64:
+		*m = FromFloat(v)
+		return nil
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+}
+
+// scanDecimal parses a database decimal, which may carry more than two decimals (e.g. SUM of NUMERIC)
+func (m *Money) scanDecimal(s string) error {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	*m = FromCents(roundRat(new(big.Rat).Mul(r, big.NewRat(100, 1))))
+	return nil
+}
+
+// decimalRat converts a float to the exact decimal it prints as (0.1 -> 1/10)
+func decimalRat(f float64) *big.Rat {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// roundRat rounds a rational number of cents half away from zero
+func roundRat(r *big.Rat) int64 {
+	num := new(big.Int).Abs(r.Num())
+	den := r.Denom()
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Lsh(rem, 1).Cmp(den) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+
+	if r.Sign() < 0 {
+		quo.Neg(quo)
+	}
+	return quo.Int64()
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,156 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]int64{
+		"0":       0,
+		"12":      1200,
+		"12.3":    1230,
+		"12.30":   1230,
+		"-0.05":   -5,
+		"+1.01":   101,
+		"1234.56": 123456,
+	}
+	for input, cents := range cases {
+		m, err := Parse(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, cents, m.Cents(), input)
+		assert.Equal(t, EUR, m.Currency(), input)
+	}
+
+	for _, input := range []string{"", "-", "1.", ".5", "1.234", "1,50", "abc", "1e3"} {
+		_, err := Parse(input)
+		assert.ErrorIs(t, err, ErrInvalidAmount, input)
+	}
+}
+
+func TestRoundsHalfUpOnce(t *testing.T) {
+	// 0.1 + 0.2 must be exactly 0.30
+	sum, err := Sum(MustParse("0.10"), MustParse("0.20"))
+	require.NoError(t, err)
+	assert.Equal(t, "0.30", sum.Decimal())
+
+	// 21% of 0.50 = 0.105 -> 0.11 (a float computation gives 0.10)
+	assert.Equal(t, int64(11), MustParse("0.50").Percent(21).Cents())
+	// 10% of 1.05 = 0.105 -> 0.11
+	assert.Equal(t, int64(11), MustParse("1.05").Percent(10).Cents())
+	// Negative amounts round away from zero
+	assert.Equal(t, int64(-11), MustParse("-0.50").Percent(21).Cents())
+	// 3 x 33.33 with a 15% discount = 84.9915 -> 84.99
+	assert.Equal(t, int64(8499), MustParse("33.33").MulPercent(3, 85).Cents())
+	assert.Equal(t, int64(4001), MustParse("26.67").Mul(1.5).Cents())
+	assert.Equal(t, int64(1235), FromFloat(12.345).Cents())
+}
+
+func TestRefusesCurrencyMixing(t *testing.T) {
+	eur := New(100, EUR)
+	usd := New(100, "USD")
+
+	_, err := eur.Add(usd)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	_, err = eur.Sub(usd)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	_, err = eur.Cmp(usd)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	_, err = Sum(eur, eur, usd)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	// The zero value is in the default currency
+	total, err := Money{}.Add(eur)
+	require.NoError(t, err)
+	assert.True(t, total.Equal(eur))
+}
+
+func TestJSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		Amount Money `json:"amount"`
+	}{Amount: MustParse("-1234.5")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount": -1234.50}`, string(data))
+
+	var decoded struct {
+		A Money  `json:"a"`
+		B Money  `json:"b"`
+		C Money  `json:"c"`
+		D *Money `json:"d"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"a": 60, "b": "12.5", "c": 1e2, "d": null}`), &decoded))
+	assert.Equal(t, int64(6000), decoded.A.Cents())
+	assert.Equal(t, int64(1250), decoded.B.Cents())
+	assert.Equal(t, int64(10000), decoded.C.Cents())
+	assert.Nil(t, decoded.D)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"a": 0.125}`), &decoded))
+}
+
+func TestSQL(t *testing.T) {
+	value, err := MustParse("12.3").Value()
+	require.NoError(t, err)
+	assert.Equal(t, "12.30", value)
+
+	var m Money
+	require.NoError(t, m.Scan([]byte("99.99")))
+	assert.Equal(t, int64(9999), m.Cents())
+	// SUM over NUMERIC columns may return more decimals
+	require.NoError(t, m.Scan("10.005000"))
+	assert.Equal(t, int64(1001), m.Cents())
+	require.NoError(t, m.Scan(int64(7)))
+	assert.Equal(t, int64(700), m.Cents())
+	require.NoError(t, m.Scan(nil))
+	assert.True(t, m.IsZero())
+}
+
+func TestProperties(t *testing.T) {
+	config := &quick.Config{MaxCount: 2000}
+
+	// Decimal representation round-trips through Parse
+	roundTrip := func(cents int64) bool {
+		cents %= 1 << 50
+		m := FromCents(cents)
+		parsed, err := Parse(m.Decimal())
+		return err == nil && parsed.Equal(m)
+	}
+	assert.NoError(t, quick.Check(roundTrip, config))
+
+	// Addition is exact, commutative and inverted by subtraction
+	addition := func(a, b int32) bool {
+		x, y := FromCents(int64(a)), FromCents(int64(b))
+		xy, err1 := x.Add(y)
+		yx, err2 := y.Add(x)
+		back, err3 := xy.Sub(y)
+		return err1 == nil && err2 == nil && err3 == nil &&
+			xy.Cents() == int64(a)+int64(b) && xy.Equal(yx) && back.Equal(x)
+	}
+	assert.NoError(t, quick.Check(addition, config))
+
+	// Percentages are rounded half-up from the exact value: never more than half a cent off
+	percent := func(cents int32, rateTenths uint16) bool {
+		rate := float64(rateTenths%1000) / 10
+		got := FromCents(int64(cents)).Percent(rate).Cents()
+		exact := float64(cents) * float64(rateTenths%1000) / 1000
+		diff := float64(got) - exact
+		return diff <= 0.5+1e-9 && diff >= -0.5-1e-9
+	}
+	assert.NoError(t, quick.Check(percent, config))
+
+	// Splitting an amount into base and the remainder keeps the total
+	split := func(cents int32, rate uint8) bool {
+		total := FromCents(int64(cents))
+		part := total.Percent(float64(rate % 101))
+		rest, err := total.Sub(part)
+		if err != nil {
+			return false
+		}
+		sum, err := Sum(part, rest)
+		return err == nil && sum.Equal(total)
+	}
+	assert.NoError(t, quick.Check(split, config))
+}
@@ -0,0 +1,283 @@
+// Package pdf is a small PDF 1.4 writer for generated documents such as invoices.
+//
+// It supports text in the standard Helvetica fonts (WinAnsi encoding, which
+// covers Spanish), lines, rectangles and JPEG/PNG images. Coordinates are in
+// points with the origin at the top-left corner of the page; text is placed
+// by its baseline.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Size is a page size in points (1/72 inch)
+type Size struct {
+	Width  float64
+	Height float64
+}
+
+// A4 is the ISO A4 page size
+var A4 = Size{Width: 595.28, Height: 841.89}
+
+// Color is an RGB color
+type Color struct {
+	R, G, B uint8
+}
+
+// Common colors
+var (
+	Black = Color{0, 0, 0}
+	White = Color{255, 255, 255}
+)
+
+// ParseHexColor parses a color such as "#1f6feb" or "1f6feb"
+func ParseHexColor(s string) (Color, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) != 6 {
+		return Color{}, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid color %q", s)
+	}
+	return Color{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)}, nil
+}
+
+// Document is a PDF document under construction
+type Document struct {
+	size         Size
+	pages        []*Page
+	images       []*Image
+	title        string
+	author       string
+	creationDate time.Time
+}
+
+// New creates an empty document with pages of the given size
+func New(size Size) *Document {
+	return &Document{size: size}
+}
+
+// SetInfo sets the title and author stored in the document metadata
+func (d *Document) SetInfo(title, author string) {
+	d.title = title
+	d.author = author
+}
+
+// SetCreationDate sets the creation date stored in the metadata (defaults to now)
+func (d *Document) SetCreationDate(t time.Time) {
+	d.creationDate = t
+}
+
+// Size returns the page size of the document
+func (d *Document) Size() Size {
+	return d.size
+}
+
+// AddPage appends a new blank page and returns it
+func (d *Document) AddPage() *Page {
+	page := &Page{doc: d, font: Helvetica, fontSize: 10, textColor: Black}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Bytes renders the document
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := d.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Write renders the document to w
+func (d *Document) Write(w io.Writer) error {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	pw := &writer{}
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	// Object numbers: catalog, page tree, fonts, images, then each page and its content
+	const catalogID, pagesID = 1, 2
+	fontID := func(f Font) int { return 3 + int(f) }
+	next := 3 + len(baseFonts)
+
+	for _, img := range d.images {
+		if img.smask != nil {
+			img.smask.objID = next
+			next++
+		}
+		img.objID = next
+		next++
+	}
+
+	pageIDs := make([]int, len(d.pages))
+	for i := range d.pages {
+		pageIDs[i] = next
+		next += 2
+	}
+	infoID := next
+
+	pw.object(catalogID, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+
+	kids := make([]string, len(pageIDs))
+	for i, id := range pageIDs {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	pw.object(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pageIDs)))
+
+	for i, name := range baseFonts {
+		pw.object(fontID(Font(i)), fmt.Sprintf(
+			"<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+
+	for _, img := range d.images {
+		if img.smask != nil {
+			pw.stream(img.smask.objID, img.smask.dictionary(0), img.smask.data)
+		}
+		smaskID := 0
+		if img.smask != nil {
+			smaskID = img.smask.objID
+		}
+		pw.stream(img.objID, img.dictionary(smaskID), img.data)
+	}
+
+	var fonts strings.Builder
+	for i := range baseFonts {
+		fmt.Fprintf(&fonts, "/%s %d 0 R ", Font(i).resourceName(), fontID(Font(i)))
+	}
+
+	for i, page := range d.pages {
+		var xobjects strings.Builder
+		for _, img := range page.images {
+			fmt.Fprintf(&xobjects, "/%s %d 0 R ", img.resourceName(), img.objID)
+		}
+
+		resources := fmt.Sprintf("/Font << %s>>", fonts.String())
+		if xobjects.Len() > 0 {
+			resources += fmt.Sprintf(" /XObject << %s>>", xobjects.String())
+		}
+
+		pw.object(pageIDs[i], fmt.Sprintf(
+			"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << %s >> /Contents %d 0 R >>",
+			pagesID, num(d.size.Width), num(d.size.Height), resources, pageIDs[i]+1))
+
+		content, err := deflate(page.content.Bytes())
+		if err != nil {
+			return err
+		}
+		pw.stream(pageIDs[i]+1, "/Filter /FlateDecode", content)
+	}
+
+	created := d.creationDate
+	if created.IsZero() {
+		created = time.Now()
+	}
+	pw.object(infoID, fmt.Sprintf("<< /Title %s /Author %s /Producer %s /CreationDate %s >>",
+		literal(d.title), literal(d.author), literal("Arnela"), literal(pdfDate(created))))
+
+	pw.trailer(catalogID, infoID)
+
+	_, err := w.Write(pw.buf.Bytes())
+	return err
+}
+
+// writer accumulates the file and the byte offset of each object for the xref table
+type writer struct {
+	buf     bytes.Buffer
+	offsets map[int]int
+}
+
+func (w *writer) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&w.buf, format, args...)
+}
+
+func (w *writer) object(id int, body string) {
+	w.begin(id)
+	w.printf("%s\nendobj\n", body)
+}
+
+func (w *writer) stream(id int, dict string, data []byte) {
+	w.begin(id)
+	w.printf("<< %s /Length %d >>\nstream\n", dict, len(data))
+	w.buf.Write(data)
+	w.printf("\nendstream\nendobj\n")
+}
+
+func (w *writer) begin(id int) {
+	if w.offsets == nil {
+		w.offsets = map[int]int{}
+	}
+	w.offsets[id] = w.buf.Len()
+	w.printf("%d 0 obj\n", id)
+}
+
+func (w *writer) trailer(rootID, infoID int) {
+	size := len(w.offsets) + 1
+	start := w.buf.Len()
+
+	w.printf("xref\n0 %d\n0000000000 65535 f \n", size)
+	for id := 1; id < size; id++ {
+		w.printf("%010d 00000 n \n", w.offsets[id])
+	}
+	w.printf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", size, rootID, infoID, start)
+}
+
+// deflate compresses a stream with zlib (FlateDecode)
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// literal encodes text as a PDF string literal in WinAnsi encoding
+func literal(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range encodeWinAnsi(s) {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// num formats a coordinate with at most two decimals
+func num(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-" {
+		return "0"
+	}
+	return s
+}
+
+// pdfDate formats a date as D:YYYYMMDDHHmmSS+HH'mm'
+func pdfDate(t time.Time) string {
+	_, offset := t.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("D:%s%c%02d'%02d'", t.Format("20060102150405"), sign, offset/3600, (offset%3600)/60)
+}
@@ -0,0 +1,118 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument_WritesValidStructure(t *testing.T) {
+	doc := New(A4)
+	doc.SetInfo("Factura F_2025_0001", "Clínica")
+	doc.SetCreationDate(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+
+	logo, err := NewImage(testPNG(t, true))
+	require.NoError(t, err)
+
+	page := doc.AddPage()
+	page.Image(logo, 40, 40, 80, 40)
+	page.SetFont(HelveticaBold, 14)
+	page.Text(40, 120, "Factura (rectificativa) nº 1 – 60,00 €")
+	page.Line(40, 130, 555, 130, 0.5, Black)
+	page.FillRect(40, 140, 100, 20, Color{230, 230, 230})
+
+	second := doc.AddPage()
+	second.Image(logo, 40, 40, 80, 40)
+
+	data, err := doc.Bytes()
+	require.NoError(t, err)
+
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+	assert.Contains(t, string(data), "/Count 2")
+	assert.Contains(t, string(data), "/SMask")
+	// The logo is embedded once even if drawn on two pages
+	assert.Equal(t, 1, bytes.Count(data, []byte("/ColorSpace /DeviceRGB")))
+
+	// startxref points to the xref table and every entry points to its object
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	require.NotNil(t, startxref)
+	offset, _ := strconv.Atoi(string(startxref[1]))
+	require.True(t, bytes.HasPrefix(data[offset:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[offset:], -1)
+	require.NotEmpty(t, entries)
+	for i, entry := range entries {
+		pos, _ := strconv.Atoi(string(entry[1]))
+		assert.True(t, bytes.HasPrefix(data[pos:], []byte(fmt.Sprintf("%d 0 obj", i+1))), "object %d", i+1)
+	}
+
+	// Page content is compressed and text is WinAnsi encoded with escaped parentheses
+	content := firstPageContent(t, data)
+	assert.Contains(t, content, "\\(rectificativa\\) n\xba 1 \x96 60,00 \x80")
+}
+
+func TestTextWidthAndWrap(t *testing.T) {
+	// "Hola" in Helvetica: 722 + 556 + 222 + 556 = 2056 / 1000 em
+	assert.InDelta(t, 20.56, TextWidth(Helvetica, 10, "Hola"), 0.001)
+	assert.Greater(t, TextWidth(HelveticaBold, 10, "Hola"), TextWidth(Helvetica, 10, "Hola"))
+	assert.Equal(t, TextWidth(Helvetica, 10, "a"), TextWidth(Helvetica, 10, "á"))
+
+	lines := WrapText(Helvetica, 10, "Sesión de psicoterapia individual\nsegunda línea", 100)
+	assert.Equal(t, []string{"Sesión de", "psicoterapia individual", "segunda línea"}, lines)
+	for _, line := range lines {
+		assert.LessOrEqual(t, TextWidth(Helvetica, 10, line), 100.0)
+	}
+}
+
+func TestNewImage(t *testing.T) {
+	opaque, err := NewImage(testPNG(t, false))
+	require.NoError(t, err)
+	w, h := opaque.Size()
+	assert.Equal(t, 4, w)
+	assert.Equal(t, 2, h)
+	assert.Nil(t, opaque.smask)
+
+	_, err = NewImage([]byte("%PDF-1.4"))
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+}
+
+func testPNG(t *testing.T, transparent bool) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			img.Set(x, y, color.NRGBA{R: 31, G: 111, B: 235, A: 255})
+		}
+	}
+	if transparent {
+		img.Set(0, 0, color.NRGBA{})
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func firstPageContent(t *testing.T, data []byte) string {
+	match := regexp.MustCompile(`(?s)<< /Filter /FlateDecode /Length (\d+) >>\nstream\n`).FindSubmatchIndex(data)
+	require.NotNil(t, match)
+	length, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+	stream := data[match[1] : match[1]+length]
+
+	r, err := zlib.NewReader(bytes.NewReader(stream))
+	require.NoError(t, err)
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(content)
+}
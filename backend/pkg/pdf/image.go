@@ -0,0 +1,135 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+)
+
+// ErrUnsupportedImage is returned for images that are not JPEG or PNG
+var ErrUnsupportedImage = errors.New("pdf: unsupported image format, use JPEG or PNG")
+
+// Image is a raster image that can be drawn on pages. An image must not be
+// drawn on documents that are being built concurrently.
+type Image struct {
+	width, height int
+	colorSpace    string
+	bits          int
+	filter        string
+	data          []byte
+	smask         *Image // Alpha channel of PNG images
+	index         int    // 1-based position in the document, 0 until drawn
+	objID         int
+}
+
+// NewImage loads a JPEG or PNG image
+func NewImage(data []byte) (*Image, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return newJPEG(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return newPNG(data)
+	default:
+		return nil, ErrUnsupportedImage
+	}
+}
+
+// Size returns the image dimensions in pixels
+func (i *Image) Size() (int, int) {
+	return i.width, i.height
+}
+
+// newJPEG embeds a JPEG as is, unless it uses CMYK, which is converted to RGB
+func newJPEG(data []byte) (*Image, error) {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: invalid JPEG: %w", err)
+	}
+
+	switch cfg.ColorModel {
+	case color.GrayModel:
+		return &Image{width: cfg.Width, height: cfg.Height, colorSpace: "DeviceGray", bits: 8, filter: "DCTDecode", data: data}, nil
+	case color.YCbCrModel, color.RGBAModel:
+		return &Image{width: cfg.Width, height: cfg.Height, colorSpace: "DeviceRGB", bits: 8, filter: "DCTDecode", data: data}, nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: invalid JPEG: %w", err)
+	}
+	return newRaster(img)
+}
+
+func newPNG(data []byte) (*Image, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: invalid PNG: %w", err)
+	}
+	return newRaster(img)
+}
+
+// newRaster stores a decoded image as compressed RGB samples with an optional alpha mask
+func newRaster(img image.Image) (*Image, error) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	rgbData := make([]byte, 0, w*h*3)
+	alpha := make([]byte, 0, w*h)
+	opaque := true
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgbData = append(rgbData, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			if c.A != 0xFF {
+				opaque = false
+			}
+		}
+	}
+
+	compressed, err := deflate(rgbData)
+	if err != nil {
+		return nil, err
+	}
+	result := &Image{width: w, height: h, colorSpace: "DeviceRGB", bits: 8, filter: "FlateDecode", data: compressed}
+
+	if !opaque {
+		mask, err := deflate(alpha)
+		if err != nil {
+			return nil, err
+		}
+		result.smask = &Image{width: w, height: h, colorSpace: "DeviceGray", bits: 8, filter: "FlateDecode", data: mask}
+	}
+
+	return result, nil
+}
+
+// dictionary returns the stream dictionary of the image XObject
+func (i *Image) dictionary(smaskID int) string {
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent %d /Filter /%s",
+		i.width, i.height, i.colorSpace, i.bits, i.filter)
+	if smaskID > 0 {
+		dict += fmt.Sprintf(" /SMask %d 0 R", smaskID)
+	}
+	return dict
+}
+
+func (i *Image) resourceName() string {
+	return fmt.Sprintf("Im%d", i.index)
+}
+
+// addImage registers an image with the document the first time it is drawn
+func (d *Document) addImage(img *Image) {
+	for _, existing := range d.images {
+		if existing == img {
+			return
+		}
+	}
+	d.images = append(d.images, img)
+	img.index = len(d.images)
+}
@@ -0,0 +1,106 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Page is a page of a document. Drawing operations use points from the top-left corner.
+type Page struct {
+	doc       *Document
+	content   bytes.Buffer
+	font      Font
+	fontSize  float64
+	textColor Color
+	images    []*Image
+}
+
+// SetFont sets the font used by the following text operations
+func (p *Page) SetFont(font Font, size float64) {
+	p.font = font
+	p.fontSize = size
+}
+
+// SetTextColor sets the color used by the following text operations
+func (p *Page) SetTextColor(c Color) {
+	p.textColor = c
+}
+
+// Text draws s with its baseline starting at (x, y)
+func (p *Page) Text(x, y float64, s string) {
+	if s == "" {
+		return
+	}
+	fmt.Fprintf(&p.content, "BT %s rg /%s %s Tf %s %s Td %s Tj ET\n",
+		rgb(p.textColor), p.font.resourceName(), num(p.fontSize), num(x), num(p.y(y)), literal(s))
+}
+
+// TextRight draws s with its baseline ending at (x, y)
+func (p *Page) TextRight(x, y float64, s string) {
+	p.Text(x-TextWidth(p.font, p.fontSize, s), y, s)
+}
+
+// TextCenter draws s with its baseline centred on (x, y)
+func (p *Page) TextCenter(x, y float64, s string) {
+	p.Text(x-TextWidth(p.font, p.fontSize, s)/2, y, s)
+}
+
+// TextBox draws s wrapped to width starting at baseline y, and returns the baseline
+// following the last line
+func (p *Page) TextBox(x, y, width, lineHeight float64, s string) float64 {
+	for _, line := range WrapText(p.font, p.fontSize, s, width) {
+		p.Text(x, y, line)
+		y += lineHeight
+	}
+	return y
+}
+
+// Line draws a straight line
+func (p *Page) Line(x1, y1, x2, y2, width float64, c Color) {
+	fmt.Fprintf(&p.content, "%s RG %s w %s %s m %s %s l S\n",
+		rgb(c), num(width), num(x1), num(p.y(y1)), num(x2), num(p.y(y2)))
+}
+
+// FillRect fills the rectangle whose top-left corner is (x, y)
+func (p *Page) FillRect(x, y, w, h float64, c Color) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n",
+		rgb(c), num(x), num(p.y(y+h)), num(w), num(h))
+}
+
+// StrokeRect outlines the rectangle whose top-left corner is (x, y)
+func (p *Page) StrokeRect(x, y, w, h, width float64, c Color) {
+	fmt.Fprintf(&p.content, "%s RG %s w %s %s %s %s re S\n",
+		rgb(c), num(width), num(x), num(p.y(y+h)), num(w), num(h))
+}
+
+// Image draws img scaled to w x h with its top-left corner at (x, y)
+func (p *Page) Image(img *Image, x, y, w, h float64) {
+	p.doc.addImage(img)
+
+	registered := false
+	for _, existing := range p.images {
+		if existing == img {
+			registered = true
+			break
+		}
+	}
+	if !registered {
+		p.images = append(p.images, img)
+	}
+
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /%s Do Q\n",
+		num(w), num(h), num(x), num(p.y(y+h)), img.resourceName())
+}
+
+// y converts a top-down coordinate to PDF user space
+func (p *Page) y(y float64) float64 {
+	return p.doc.size.Height - y
+}
+
+func rgb(c Color) string {
+	return fmt.Sprintf("%s %s %s", component(c.R), component(c.G), component(c.B))
+}
+
+func component(v uint8) string {
+	return num(float64(v) / 255)
+}
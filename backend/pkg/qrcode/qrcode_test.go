@@ -0,0 +1,184 @@
+package qrcode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomon_HelloWorld(t *testing.T) {
+	// Worked example of "HELLO WORLD" in a 1-M symbol
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ec := reedSolomonRemainder(data, reedSolomonDivisor(10))
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, ec)
+}
+
+func TestFormatInformation(t *testing.T) {
+	// Values from the format information table of the standard
+	assert.Equal(t, 0x77C4, formatInformation(Low, 0))
+	assert.Equal(t, 0x5412, formatInformation(Medium, 0))
+	assert.Equal(t, 0x355F, formatInformation(Quartile, 0))
+	assert.Equal(t, 0x1689, formatInformation(High, 0))
+	assert.Equal(t, 0x40CE, formatInformation(Medium, 5))
+	assert.Equal(t, 0x4AA0, formatInformation(Medium, 7))
+}
+
+func TestEncode_PicksSmallestVersion(t *testing.T) {
+	c, err := Encode([]byte("hello"), Medium)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.Version)
+	assert.Equal(t, 21, c.Size)
+
+	// The 16 data codewords of 1-M hold 14 bytes; 15 need version 2
+	c, err = Encode([]byte(strings.Repeat("a", 14)), Medium)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.Version)
+
+	c, err = Encode([]byte(strings.Repeat("a", 15)), Medium)
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.Version)
+
+	_, err = Encode([]byte(strings.Repeat("a", 300)), Medium)
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+func TestEncode_DecodesBack(t *testing.T) {
+	url := "https://www2.agenciatributaria.gob.es/wlpl/TIKE-CONT/ValidarQR?nif=B12345678&numserie=F_2026_0001&fecha=15-03-2026&importe=72.60"
+	inputs := []string{"", "A", url, strings.Repeat("x", 200)}
+
+	for _, input := range inputs {
+		for _, level := range []Level{Low, Medium, Quartile, High} {
+			c, err := Encode([]byte(input), level)
+			if err == ErrTooLong {
+				continue
+			}
+			require.NoError(t, err)
+			assert.Equal(t, input, string(decode(t, c)), "version %d level %d", c.Version, level)
+		}
+	}
+}
+
+func TestEncode_FinderPatterns(t *testing.T) {
+	c, err := Encode([]byte("finder"), Low)
+	require.NoError(t, err)
+
+	for _, corner := range [][2]int{{0, 0}, {c.Size - 7, 0}, {0, c.Size - 7}} {
+		for i := 0; i < 7; i++ {
+			assert.True(t, c.Black(corner[0]+i, corner[1]))
+			assert.True(t, c.Black(corner[0], corner[1]+i))
+		}
+		assert.False(t, c.Black(corner[0]+1, corner[1]+1))
+		assert.True(t, c.Black(corner[0]+3, corner[1]+3))
+	}
+	assert.False(t, c.Black(-1, 0))
+	assert.False(t, c.Black(c.Size, 0))
+}
+
+// decode reads a symbol back: format bits, unmasking, zigzag, de-interleaving,
+// error correction check and byte mode segment
+func decode(t *testing.T, c *Code) []byte {
+	t.Helper()
+
+	// Format information next to the top-left finder
+	format := 0
+	for i := 0; i <= 5; i++ {
+		if c.Black(8, i) {
+			format |= 1 << i
+		}
+	}
+	for i, xy := range [][2]int{{8, 7}, {8, 8}, {7, 8}} {
+		if c.Black(xy[0], xy[1]) {
+			format |= 1 << (6 + i)
+		}
+	}
+	for i := 9; i < 15; i++ {
+		if c.Black(14-i, 8) {
+			format |= 1 << i
+		}
+	}
+	require.Equal(t, formatInformation(c.Level, c.Mask), format)
+
+	// A blank symbol with the same function patterns tells data modules apart
+	blank := &Code{Version: c.Version, Level: c.Level, Size: c.Size}
+	blank.modules = make([][]bool, c.Size)
+	blank.isFunction = make([][]bool, c.Size)
+	for y := range blank.modules {
+		blank.modules[y] = append([]bool(nil), c.modules[y]...)
+		blank.isFunction[y] = make([]bool, c.Size)
+	}
+	blank.drawFunctionPatterns()
+	for y := range blank.modules {
+		copy(blank.modules[y], c.modules[y])
+	}
+	blank.applyMask(c.Mask)
+
+	layout := layouts[c.Version-1][c.Level]
+	total := layout.dataCodewords() + layout.ecLen*layout.blocks()
+	var bits bitBuffer
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !blank.isFunction[y][x] && len(bits) < total*8 {
+					bits = append(bits, blank.modules[y][x])
+				}
+			}
+		}
+	}
+	codewords := bits.bytes()
+	require.Len(t, codewords, total)
+
+	// De-interleave and check every block against its error correction codewords
+	blocks := make([][]byte, layout.blocks())
+	i := 0
+	for k := 0; k <= layout.data1; k++ {
+		for b := range blocks {
+			if k < layout.data1 || b >= layout.group1 {
+				blocks[b] = append(blocks[b], codewords[i])
+				i++
+			}
+		}
+	}
+	divisor := reedSolomonDivisor(layout.ecLen)
+	var data []byte
+	for b, block := range blocks {
+		ec := make([]byte, layout.ecLen)
+		for k := range ec {
+			ec[k] = codewords[layout.dataCodewords()+k*layout.blocks()+b]
+		}
+		require.Equal(t, reedSolomonRemainder(block, divisor), ec, "block %d", b)
+		data = append(data, block...)
+	}
+
+	// Byte mode segment
+	var stream bitBuffer
+	for _, d := range data {
+		stream.append(int(d), 8)
+	}
+	read := func(n int) int {
+		v := 0
+		for k := 0; k < n; k++ {
+			v <<= 1
+			if stream[0] {
+				v |= 1
+			}
+			stream = stream[1:]
+		}
+		return v
+	}
+	require.Equal(t, 0x4, read(4))
+	length := read(countBits(c.Version))
+	result := make([]byte, length)
+	for k := range result {
+		result[k] = byte(read(8))
+	}
+	return result
+}
@@ -268,7 +268,8 @@ func generateTaskID() string {
 // Default handlers (placeholders - implement actual logic)
 
 func defaultEmailHandler(ctx context.Context, task *Task) error {
-	log.Printf("EMAIL HANDLER: Sending email - %+v", task.Payload)
+	// Attachments are not logged: they carry whole documents
+	log.Printf("EMAIL HANDLER: Sending email to %v - %v", task.Payload["to"], task.Payload["subject"])
 	// TODO: Implement actual email sending via SendGrid/SMTP
 	time.Sleep(500 * time.Millisecond) // Simulate work
 	return nil
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStorage_PutGetDelete(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+
+	content := "referral letter"
+	err = store.Put(ctx, "appointments/a1/f1", strings.NewReader(content), int64(len(content)), "application/pdf")
+	require.NoError(t, err)
+
+	reader, obj, err := store.Get(ctx, "appointments/a1/f1")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+	assert.Equal(t, int64(len(content)), obj.Size)
+
+	require.NoError(t, store.Delete(ctx, "appointments/a1/f1"))
+	_, _, err = store.Get(ctx, "appointments/a1/f1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Deleting a missing object is not an error
+	assert.NoError(t, store.Delete(ctx, "appointments/a1/f1"))
+}
+
+func TestLocalStorage_RejectsKeysOutsideRoot(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "../secret", "a/../../b", "a//b", "./a"} {
+		err := store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain")
+		assert.ErrorIs(t, err, ErrInvalidKey, "key %q", key)
+	}
+}
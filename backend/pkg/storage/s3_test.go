@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "eu-south-2"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3-compatible server that
+// verifies Signature Version 4 on every request
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	if r.URL.Query().Get("X-Amz-Signature") != "" {
+		if !f.validPresignedURL(r) {
+			http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+			return
+		}
+	} else if !f.validSignature(r, body) {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = body
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[r.URL.Path])
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// validSignature re-signs the received request and compares the Authorization header
+func (f *fakeS3) validSignature(r *http.Request, body []byte) bool {
+	signedAt, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	clone, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		clone.Header.Set("Content-Type", ct)
+	}
+	signRequest(clone, body, testAccessKey, testSecretKey, testRegion, signedAt)
+
+	return clone.Header.Get("Authorization") == r.Header.Get("Authorization")
+}
+
+// validPresignedURL re-signs the query of a presigned request and checks it has not expired
+func (f *fakeS3) validPresignedURL(r *http.Request) bool {
+	query := r.URL.Query()
+	signedAt, err := time.Parse("20060102T150405Z", query.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+	expires, err := time.ParseDuration(query.Get("X-Amz-Expires") + "s")
+	if err != nil || time.Now().After(signedAt.Add(expires)) {
+		return false
+	}
+
+	signature := query.Get("X-Amz-Signature")
+	u := *r.URL
+	u.Host = r.Host
+	presignURL(&u, query, testAccessKey, testSecretKey, testRegion, signedAt, expires)
+
+	return u.Query().Get("X-Amz-Signature") == signature
+}
+
+func newTestS3Storage(t *testing.T, endpoint, secret string) *S3Storage {
+	store, err := NewS3Storage(S3Config{
+		Endpoint:  endpoint,
+		Region:    testRegion,
+		Bucket:    "arnela-files",
+		AccessKey: testAccessKey,
+		SecretKey: secret,
+	})
+	require.NoError(t, err)
+	return store
+}
+
+func TestS3Storage_PutGetDelete(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store := newTestS3Storage(t, server.URL, testSecretKey)
+
+	content := "worksheet for session 3"
+	key := "appointments/a1/worksheet 3.pdf"
+	require.NoError(t, store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "application/pdf"))
+	assert.Contains(t, fake.objects, "/arnela-files/"+key)
+
+	reader, obj, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, content, string(data))
+	assert.Equal(t, "application/pdf", obj.ContentType)
+
+	require.NoError(t, store.Delete(ctx, key))
+	_, _, err = store.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestS3Storage_WrongCredentialsAreRejected(t *testing.T) {
+	server := httptest.NewServer(newFakeS3())
+	defer server.Close()
+
+	store := newTestS3Storage(t, server.URL, "not-the-secret")
+
+	err := store.Put(context.Background(), "a/b", strings.NewReader("x"), 1, "text/plain")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+}
+
+func TestS3Storage_PresignGet(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store := newTestS3Storage(t, server.URL, testSecretKey)
+
+	content := "%PDF-1.4 receipt"
+	key := "expenses/receipts/abc"
+	require.NoError(t, store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "application/pdf"))
+
+	presigned, err := store.PresignGet(ctx, key, 5*time.Minute, "ticket 12.pdf")
+	require.NoError(t, err)
+	assert.Contains(t, presigned, "X-Amz-Expires=300")
+	assert.Contains(t, presigned, "response-content-disposition=")
+
+	// The URL works without any credentials
+	resp, err := http.Get(presigned)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, content, string(data))
+
+	// Tampering with the key invalidates the signature
+	resp, err = http.Get(strings.Replace(presigned, "/abc?", "/abd?", 1))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	_, err = store.PresignGet(ctx, key, 8*24*time.Hour, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gaston-garcia-cegid/arnela/backend/config"
+)
+
+// ErrNotFound is returned when an object does not exist in the storage backend
+var ErrNotFound = errors.New("storage: object not found")
+
+// ErrInvalidKey is returned when an object key is empty or escapes the storage root
+var ErrInvalidKey = errors.New("storage: invalid object key")
+
+// Object describes a stored file
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+}
+
+// Storage is a pluggable backend for binary files (attachments, receipts, PDFs)
+type Storage interface {
+	// Put stores the content under key, replacing any existing object
+	Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+
+	// Get opens the object stored under key; the caller must close the reader
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+
+	// Delete removes the object stored under key; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner is implemented by backends that can hand out short-lived URLs to download an
+// object directly from them
+type Presigner interface {
+	// PresignGet returns a URL valid for ttl that downloads the object stored under key
+	// as an attachment named fileName
+	PresignGet(ctx context.Context, key string, ttl time.Duration, fileName string) (string, error)
+}
+
+// NewStorage creates the storage backend selected in the configuration
+func NewStorage(cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalPath)
+	case "s3":
+		return NewS3Storage(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+// cleanKey validates an object key and normalises its separators
+func cleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(strings.ReplaceAll(key, "\\", "/"), "/")
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLSigner_Verify(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	signer := NewURLSigner("secret")
+
+	expires := now.Add(5 * time.Minute)
+	signature := signer.Sign("expenses/receipts/abc", expires)
+
+	assert.NoError(t, signer.Verify("expenses/receipts/abc", expires, signature, now))
+
+	// Another resource, expiry or secret does not match
+	assert.ErrorIs(t, signer.Verify("expenses/receipts/abd", expires, signature, now), ErrInvalidSignature)
+	assert.ErrorIs(t, signer.Verify("expenses/receipts/abc", expires.Add(time.Hour), signature, now), ErrInvalidSignature)
+	assert.ErrorIs(t, NewURLSigner("other").Verify("expenses/receipts/abc", expires, signature, now), ErrInvalidSignature)
+
+	// Expired URLs are rejected even with a valid signature
+	assert.ErrorIs(t, signer.Verify("expenses/receipts/abc", expires, signature, expires), ErrURLExpired)
+}